@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"arian-statement-parser/internal/client"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/mapping"
+	"arian-statement-parser/pkg/importer"
+)
+
+const accountsUsage = "usage: arian-statement-parser accounts rename <account> <new name> | set-type <account> chequing|savings|visa|investment|other"
+
+// runAccounts handles "accounts rename" and "accounts set-type", which fix an ariand account an
+// import got wrong without leaving the tool. Saved mappings follow a renamed account.
+func runAccounts(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("%s", accountsUsage)
+	}
+	action, name, value := args[0], args[1], strings.Join(args[2:], " ")
+
+	var changes client.AccountChanges
+	switch action {
+	case "rename":
+		changes.Name = strings.TrimSpace(value)
+	case "set-type":
+		changes.Type = importer.AccountType(strings.ToLower(value))
+		if changes.Type == pb.AccountType_ACCOUNT_UNSPECIFIED {
+			return fmt.Errorf("unknown account type %q, want chequing, savings, visa, investment or other", value)
+		}
+	default:
+		return fmt.Errorf("%s", accountsUsage)
+	}
+
+	userID := os.Getenv("USER_ID")
+	if userID == "" {
+		return fmt.Errorf("need USER_ID")
+	}
+	arianClient, err := dialUpload(userID)
+	if err != nil {
+		return err
+	}
+	defer arianClient.Close()
+
+	accounts, err := arianClient.GetAccounts(userID)
+	if err != nil {
+		return fmt.Errorf("get accounts failed: %w", err)
+	}
+	account := findAccount(accounts, name)
+	if account == nil {
+		return fmt.Errorf("no account named %q in ariand", name)
+	}
+	if changes.Name != "" {
+		if other := findAccount(accounts, changes.Name); other != nil && other.Id != account.Id {
+			return fmt.Errorf("ariand already has an account named %q", other.Name)
+		}
+	}
+
+	if err := arianClient.UpdateAccount(userID, account.Id, changes); err != nil {
+		return err
+	}
+
+	if changes.Type != pb.AccountType_ACCOUNT_UNSPECIFIED {
+		fmt.Printf("changed account %s from %s to %s\n", account.Name, account.Type, changes.Type)
+		return nil
+	}
+	fmt.Printf("renamed account %s to %s\n", account.Name, changes.Name)
+
+	mappingStore, err := mapping.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to initialize mapping store: %w", err)
+	}
+	renamed, err := mappingStore.RenameAccount(account.Name, changes.Name)
+	if err != nil {
+		return fmt.Errorf("account renamed, but failed to update its mappings: %w", err)
+	}
+	if renamed > 0 {
+		fmt.Printf("updated %d account mappings\n", renamed)
+	}
+	return nil
+}
+
+// findAccount finds an account by its name, ignoring case, or by its ID
+func findAccount(accounts []*pb.Account, name string) *pb.Account {
+	id, _ := strconv.ParseInt(name, 10, 64)
+	for _, account := range accounts {
+		if strings.EqualFold(account.Name, name) || (id != 0 && account.Id == id) {
+			return account
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+
+	"arian-statement-parser/internal/client"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+
+	"github.com/charmbracelet/huh"
+)
+
+// What to do when a statement's account type differs from its ariand account's, chosen with
+// ACCOUNT_TYPE_MISMATCH
+const (
+	mismatchAsk     = "ask"     // prompt, and proceed when nobody is around to answer
+	mismatchProceed = "proceed" // warn and upload to the account as it is
+	mismatchUpdate  = "update"  // change the ariand account's type to the statement's
+	mismatchRemap   = "remap"   // pick another account, unattended runs put the lines aside for review
+)
+
+// checkMismatchPolicy validates an ACCOUNT_TYPE_MISMATCH value, defaulting to ask
+func checkMismatchPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return mismatchAsk, nil
+	case mismatchAsk, mismatchProceed, mismatchUpdate, mismatchRemap:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown account type mismatch policy %q, want ask, proceed, update or remap", policy)
+	}
+}
+
+// resolveTypeMismatch settles a statement account whose type differs from the ariand account it is
+// mapped to, as policy says or, for ask, as the user answers. It returns whether the statement
+// account should be mapped to another account instead; the account's type is changed in place when
+// it was updated.
+func resolveTypeMismatch(backend client.Uploader, userID, accountName string, account *pb.Account, expected pb.AccountType, policy string, unattended bool, warnf func(string, ...any)) (bool, error) {
+	if expected == pb.AccountType_ACCOUNT_UNSPECIFIED || account.Type == expected {
+		return false, nil
+	}
+	mismatch := fmt.Sprintf("account '%s' type mismatch - statement expects %s but account is %s", accountName, expected, account.Type)
+
+	choice := policy
+	if choice == mismatchAsk && unattended {
+		choice = mismatchProceed
+	}
+	if choice == mismatchAsk {
+		choice = mismatchProceed
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Title(mismatch).
+					Description("What should happen?").
+					Options(
+						huh.NewOption(fmt.Sprintf("Import into %s anyway", account.Name), mismatchProceed),
+						huh.NewOption(fmt.Sprintf("Change %s to %s in ariand", account.Name, expected), mismatchUpdate),
+						huh.NewOption("Map the statement to another account", mismatchRemap),
+					).
+					Value(&choice),
+			),
+		)
+		if err := form.Run(); err != nil {
+			return false, fmt.Errorf("account type prompt failed: %w", err)
+		}
+	}
+
+	switch choice {
+	case mismatchUpdate:
+		updater, ok := backend.(client.AccountUpdater)
+		if !ok {
+			warnf("%s, which can't be changed here (continuing anyway)", mismatch)
+			return false, nil
+		}
+		if err := updater.UpdateAccount(userID, account.Id, client.AccountChanges{Type: expected}); err != nil {
+			return false, err
+		}
+		fmt.Printf("changed account %s to %s\n", account.Name, expected)
+		account.Type = expected
+		return false, nil
+	case mismatchRemap:
+		warnf("%s, mapping it again", mismatch)
+		return true, nil
+	}
+	warnf("%s (continuing anyway)", mismatch)
+	return false, nil
+}
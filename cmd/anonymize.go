@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+
+	"arian-statement-parser/internal/anonymize"
+	"arian-statement-parser/internal/parser"
+)
+
+// anonymizeOptions are the flags of anonymize
+type anonymizeOptions struct {
+	pdfPath    *string
+	jsonPath   *string
+	configPath *string
+	outPath    *string
+	seed       *uint64
+}
+
+// anonymizeFlags defines the flags of anonymize on fs
+func anonymizeFlags(fs *flag.FlagSet) *anonymizeOptions {
+	return &anonymizeOptions{
+		pdfPath:    fs.String("pdf", "", "statement or folder of statements to parse"),
+		jsonPath:   fs.String("json", "", "parser output to anonymize instead of parsing a statement"),
+		configPath: fs.String("config", "", "parser config file"),
+		outPath:    fs.String("out", "", "fixture file to write, defaults to standard output"),
+		seed:       fs.Uint64("seed", 0, "seed for the fake names and numbers, random when 0; the same seed gives the same fixture"),
+	}
+}
+
+// runAnonymize turns a real statement into a shareable parser fixture
+func runAnonymize(args []string) error {
+	fs := newFlagSet("anonymize")
+	opts := anonymizeFlags(fs)
+	fs.Parse(args)
+
+	var result *parser.ParseResult
+	switch {
+	case *opts.jsonPath != "":
+		data, err := os.ReadFile(*opts.jsonPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", *opts.jsonPath, err)
+		}
+		result = &parser.ParseResult{}
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", *opts.jsonPath, err)
+		}
+	case *opts.pdfPath != "":
+		var err error
+		result, _, err = parser.NewPythonParser().ParseStatements(*opts.pdfPath, *opts.configPath)
+		if err != nil {
+			return fmt.Errorf("parse failed: %w", err)
+		}
+	default:
+		return fmt.Errorf("need -pdf or -json")
+	}
+
+	// A known seed lets anyone check guesses at the original words, so only a chosen one is reused
+	seed := *opts.seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	fixture := anonymize.New(seed).Apply(result)
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	data = append(data, '\n')
+
+	if *opts.outPath == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(*opts.outPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *opts.outPath, err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s, review it before sharing\n", *opts.outPath)
+	return nil
+}
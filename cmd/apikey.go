@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/keyring"
+)
+
+// apiKeyAccount is the keyring entry the API key is stored under by "auth set-key"
+const apiKeyAccount = "ariand-api-key"
+
+// errNoAPIKey explains every place a key can come from
+var errNoAPIKey = errors.New("need API_KEY, API_KEY_FILE, API_KEY_CMD or a key saved with \"auth set-key\"")
+
+// apiKeySource picks where the API key is read from: API_KEY_CMD, then API_KEY_FILE, then API_KEY,
+// then the keyring. Every source but API_KEY is read again when ariand rejects the key in use.
+func apiKeySource() client.KeySource {
+	if command := os.Getenv("API_KEY_CMD"); command != "" {
+		return func() ([]string, error) {
+			output, err := exec.Command("sh", "-c", command).Output()
+			if err != nil {
+				return nil, fmt.Errorf("API_KEY_CMD failed: %w", err)
+			}
+			return keyLines(string(output)), nil
+		}
+	}
+
+	if file := os.Getenv("API_KEY_FILE"); file != "" {
+		return func() ([]string, error) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read API_KEY_FILE: %w", err)
+			}
+			return keyLines(string(data)), nil
+		}
+	}
+
+	if key := os.Getenv("API_KEY"); key != "" {
+		return func() ([]string, error) {
+			return []string{key}, nil
+		}
+	}
+
+	return func() ([]string, error) {
+		key, err := keyring.Get(apiKeyAccount)
+		if err != nil {
+			return nil, err
+		}
+		return []string{key}, nil
+	}
+}
+
+// keyLines reads one key per line, so a file can hold the new key and the old one during a rotation
+func keyLines(text string) []string {
+	var keys []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	return keys
+}
+
+// loadAPIKey returns the preferred key from source
+func loadAPIKey(source client.KeySource) (string, error) {
+	keys, err := source()
+	if errors.Is(err, keyring.ErrNotFound) || (err == nil && len(keys) == 0) {
+		return "", errNoAPIKey
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errNoAPIKey, err)
+	}
+	return keys[0], nil
+}
+
+// setAPIKey saves a key read from stdin to the keyring, so it never sits in .env or shell history
+func setAPIKey() error {
+	fmt.Fprint(os.Stderr, "API key: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("failed to read API key: %w", err)
+	}
+
+	key := strings.TrimSpace(line)
+	if key == "" {
+		return fmt.Errorf("empty API key")
+	}
+	if err := keyring.Set(apiKeyAccount, key); err != nil {
+		return err
+	}
+	fmt.Println("API key saved to keyring")
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+
+	"arian-statement-parser/internal/archive"
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/source"
+)
+
+// archiveStatements moves the statements of a run into the archive under root, renamed by namer
+// unless it is nil. Files synced from a local folder are moved from that folder, those from remote
+// sources are the downloaded copies.
+func archiveStatements(root string, namer *archive.Namer, collected *archive.Collector, statements map[string]*domain.Statement, remote source.Source, fetched []source.Fetched, warnf func(string, ...any)) {
+	originals := make(map[string]string, len(fetched))
+	if local, ok := remote.(*source.Local); ok {
+		for _, f := range fetched {
+			originals[filepath.Clean(f.LocalPath)] = filepath.Join(local.Dir(), f.Key)
+		}
+	}
+
+	archived := 0
+	for _, s := range collected.Statements(statements) {
+		s.File = cmp.Or(originals[filepath.Clean(s.File)], s.File)
+
+		var name string
+		if namer != nil {
+			var err error
+			if name, err = namer.Name(s); err != nil {
+				warnf("%v", err)
+				continue
+			}
+		}
+		if _, err := archive.Move(root, s, name); err != nil {
+			warnf("failed to archive: %v", err)
+			continue
+		}
+		archived++
+	}
+	if archived > 0 {
+		fmt.Printf("archived %d statements in %s\n", archived, root)
+	}
+}
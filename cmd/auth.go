@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"arian-statement-parser/internal/client"
+)
+
+// runAuth handles "auth test", which checks ARIAND_URL, the API key and USER_ID without parsing
+// anything, and "auth set-key", which saves the API key to the keyring
+func runAuth(args []string) error {
+	if len(args) == 1 && args[0] == "set-key" {
+		return setAPIKey()
+	}
+	if len(args) == 0 || args[0] != "test" {
+		return fmt.Errorf("usage: arian-statement-parser auth test|set-key")
+	}
+
+	serverURL := os.Getenv("ARIAND_URL")
+	userID := os.Getenv("USER_ID")
+	switch {
+	case serverURL == "":
+		return fmt.Errorf("need ARIAND_URL")
+	case userID == "":
+		return fmt.Errorf("need USER_ID")
+	}
+
+	keySource := apiKeySource()
+	apiKey, err := loadAPIKey(keySource)
+	if err != nil {
+		return err
+	}
+
+	settings, err := clientSettings()
+	if err != nil {
+		return err
+	}
+
+	arianClient, err := client.NewClientWithSettings(serverURL, apiKey, settings)
+	if err != nil {
+		return fmt.Errorf("client failed: %w", err)
+	}
+	defer arianClient.Close()
+	arianClient.SetKeySource(keySource)
+
+	user, err := arianClient.Preflight(userID)
+	if err != nil {
+		return preflightError(err, serverURL)
+	}
+
+	accounts, err := arianClient.GetAccounts(userID)
+	if err != nil {
+		return fmt.Errorf("get accounts failed: %w", err)
+	}
+
+	fmt.Printf("ok: %s reachable, API key accepted, user %s (%s) has %d accounts\n", serverURL, userID, user.Email, len(accounts))
+
+	caps, err := arianClient.Handshake()
+	switch {
+	case err != nil:
+		fmt.Printf("warning: %v\n", err)
+	case !caps.Known:
+		fmt.Println("ariand doesn't offer gRPC reflection, so its features can't be checked")
+	case len(caps.Missing()) == 0:
+		fmt.Println("ariand supports everything the importer uses")
+	default:
+		for _, f := range caps.Missing() {
+			fmt.Printf("warning: ariand is too old for %s\n", f.Name)
+		}
+	}
+	return nil
+}
+
+// preflightError adds what to check next to a failed preflight
+func preflightError(err error, serverURL string) error {
+	var hint string
+	switch {
+	case errors.Is(err, client.ErrUnreachable):
+		hint = fmt.Sprintf("check that ariand is running and ARIAND_URL (%s) points at its gRPC port; :443 uses TLS, any other port plaintext", serverURL)
+	case errors.Is(err, client.ErrKeyRejected):
+		hint = "check that the API key (API_KEY, API_KEY_FILE, API_KEY_CMD or the keyring) matches the internal key ariand was started with"
+	case errors.Is(err, client.ErrUserNotFound):
+		hint = "check that USER_ID is the UUID of an existing ariand user"
+	default:
+		return fmt.Errorf("preflight failed: %w", err)
+	}
+	return fmt.Errorf("preflight failed: %w\n  hint: %s", err, hint)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/client/fake"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/parser"
+)
+
+// benchParsers maps a backend name to a function that parses a folder without caching
+var benchParsers = map[string]func(pdfPath, configPath string) (*parser.ParseResult, []*domain.Transaction, error){
+	"python": parser.NewPythonParser().ParseStatements,
+	"csv":    parser.NewCSVParser().ParseStatements,
+	"ofx":    parser.NewOFXParser().ParseStatements,
+}
+
+// benchResult is one line of the report
+type benchResult struct {
+	Stage        string        `json:"stage"`
+	Backend      string        `json:"backend,omitempty"`
+	Concurrency  int           `json:"concurrency,omitempty"`
+	Transactions int           `json:"transactions"`
+	Duration     time.Duration `json:"duration_ns"`
+	PerSecond    float64       `json:"per_second"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// benchOptions are the flags of bench
+type benchOptions struct {
+	pdfPath    *string
+	configPath *string
+	runs       *int
+	count      *int
+	levels     *string
+	server     *bool
+	asJSON     *bool
+}
+
+// benchFlags defines the flags of bench on fs
+func benchFlags(fs *flag.FlagSet) *benchOptions {
+	return &benchOptions{
+		pdfPath:    fs.String("pdf", "", "folder of statements to time the parsers on, parse benchmarks are skipped without it"),
+		configPath: fs.String("config", "", "parser config file"),
+		runs:       fs.Int("runs", 3, "how many times to parse the statements"),
+		count:      fs.Int("n", 5000, "how many transactions to upload at each concurrency level"),
+		levels:     fs.String("concurrency", "1,2,4,8", "comma-separated upload concurrency levels to try"),
+		server:     fs.Bool("server", false, "upload to ARIAND_URL as USER_ID, into a new account, instead of the in-memory fake"),
+		asJSON:     fs.Bool("json", false, "print JSON instead of a table"),
+	}
+}
+
+// runBench measures parse and upload throughput and prints a report
+func runBench(args []string) error {
+	fs := newFlagSet("bench")
+	opts := benchFlags(fs)
+	fs.Parse(args)
+
+	var results []benchResult
+
+	if *opts.pdfPath != "" {
+		for name, parse := range benchParsers {
+			results = append(results, benchParse(name, parse, *opts.pdfPath, *opts.configPath, *opts.runs))
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "no -pdf given, skipping parse benchmarks")
+	}
+
+	var levels []int
+	for _, level := range splitList(*opts.levels) {
+		concurrency, err := strconv.Atoi(level)
+		if err != nil || concurrency < 1 {
+			return fmt.Errorf("invalid concurrency %q", level)
+		}
+		levels = append(levels, concurrency)
+	}
+	if *opts.server {
+		uploads, err := benchServer(*opts.count, levels)
+		if err != nil {
+			return err
+		}
+		results = append(results, uploads...)
+	} else {
+		for _, concurrency := range levels {
+			results = append(results, benchFake(*opts.count, concurrency))
+		}
+	}
+
+	if *opts.asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tBACKEND\tCONCURRENCY\tTRANSACTIONS\tDURATION\tTX/SEC")
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t%d\t-\t-\terror: %s\n", r.Stage, r.Backend, r.Concurrency, r.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%.0f\n", r.Stage, r.Backend, r.Concurrency, r.Transactions, r.Duration.Round(time.Millisecond), r.PerSecond)
+	}
+	return w.Flush()
+}
+
+// benchParse times repeated uncached parses of the same folder and keeps the fastest run
+func benchParse(name string, parse func(string, string) (*parser.ParseResult, []*domain.Transaction, error), pdfPath, configPath string, runs int) benchResult {
+	result := benchResult{Stage: "parse", Backend: name}
+
+	for i := 0; i < max(runs, 1); i++ {
+		start := time.Now()
+		_, transactions, err := parse(pdfPath, configPath)
+		elapsed := time.Since(start)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		if result.Duration == 0 || elapsed < result.Duration {
+			result.Duration = elapsed
+			result.Transactions = len(transactions)
+		}
+	}
+
+	result.PerSecond = float64(result.Transactions) / result.Duration.Seconds()
+	return result
+}
+
+// benchFake uploads to a fresh in-memory ariand. The fake handles one call at a time, so this
+// measures the client and the wire, not how ariand scales with concurrency.
+func benchFake(count, concurrency int) benchResult {
+	result := benchResult{Stage: "upload", Backend: "fake", Concurrency: concurrency, Transactions: count}
+
+	server := fake.New("")
+	server.AddUser(demoUserID, "bench@example.com")
+	account := server.AddAccount(demoUserID, "bench", "RBC", pb.AccountType_ACCOUNT_CHEQUING)
+	addr, err := server.Start()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer server.Stop()
+
+	arianClient, err := client.NewClient(addr, "", "")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer arianClient.Close()
+
+	return benchUpload(result, arianClient, demoUserID, int(account.Id), "BENCH")
+}
+
+// benchServer uploads to the ariand at ARIAND_URL, into an account made for the run, which is left
+// behind with its transactions
+func benchServer(count int, levels []int) ([]benchResult, error) {
+	serverURL, userID := os.Getenv("ARIAND_URL"), os.Getenv("USER_ID")
+	switch {
+	case serverURL == "":
+		return nil, fmt.Errorf("need ARIAND_URL")
+	case userID == "":
+		return nil, fmt.Errorf("need USER_ID")
+	}
+
+	keySource := apiKeySource()
+	apiKey, err := loadAPIKey(keySource)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := clientSettings()
+	if err != nil {
+		return nil, err
+	}
+	arianClient, err := client.NewClientWithSettings(serverURL, apiKey, settings)
+	if err != nil {
+		return nil, fmt.Errorf("client failed: %w", err)
+	}
+	defer arianClient.Close()
+	arianClient.SetKeySource(keySource)
+
+	if _, err := arianClient.Preflight(userID); err != nil {
+		return nil, preflightError(err, serverURL)
+	}
+	name := "bench " + time.Now().Format("2006-01-02 15:04:05")
+	account, err := arianClient.CreateAccount(userID, name, "bench", pb.AccountType_ACCOUNT_CHEQUING, "CAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account %q: %w", name, err)
+	}
+	fmt.Fprintf(os.Stderr, "uploading to account %q, delete it in ariand afterwards\n", name)
+
+	var results []benchResult
+	for _, concurrency := range levels {
+		result := benchResult{Stage: "upload", Backend: "server", Concurrency: concurrency, Transactions: count}
+		// Each level gets its own descriptions, so no level uploads what an earlier one did
+		results = append(results, benchUpload(result, arianClient, userID, int(account.Id), fmt.Sprintf("BENCH C%d", concurrency)))
+	}
+	return results, nil
+}
+
+// benchUpload pushes result.Transactions synthetic transactions through the client in batches,
+// result.Concurrency at a time
+func benchUpload(result benchResult, arianClient *client.Client, userID string, accountID int, prefix string) benchResult {
+	// Same batch size as a real import
+	const batchSize = 1000
+	count := result.Transactions
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	batches := make(chan []*domain.Transaction)
+	go func() {
+		defer close(batches)
+		for i := 0; i < count; i += batchSize {
+			batch := make([]*domain.Transaction, 0, batchSize)
+			for j := i; j < min(i+batchSize, count); j++ {
+				batch = append(batch, &domain.Transaction{
+					AccountID:   accountID,
+					TxDate:      start.AddDate(0, 0, j%3650),
+					TxAmount:    float64(j%10000)/100 + 1,
+					TxCurrency:  "CAD",
+					TxDirection: domain.Out,
+					TxDesc:      fmt.Sprintf("%s %d", prefix, j),
+				})
+			}
+			batches <- batch
+		}
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	began := time.Now()
+	for range result.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if _, errs := arianClient.CreateTransactionsBulk(userID, batch); len(errs) > 0 {
+					mu.Lock()
+					result.Error = errs[0].Error()
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	result.Duration = time.Since(began)
+	result.PerSecond = float64(count) / result.Duration.Seconds()
+	return result
+}
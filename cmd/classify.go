@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"arian-statement-parser/internal/categorize"
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+)
+
+// Classifiers, chosen with CATEGORIZE
+const (
+	classifierOff   = ""
+	classifierBayes = "bayes" // naive Bayes over the words of descriptions already categorized in ariand
+)
+
+// checkClassifier validates a CATEGORIZE value
+func checkClassifier(name string) (string, error) {
+	switch name {
+	case classifierOff, "off", "none":
+		return classifierOff, nil
+	case classifierBayes:
+		return name, nil
+	default:
+		return "", fmt.Errorf("unknown classifier %q, want bayes or off", name)
+	}
+}
+
+// suggestCategories trains the classifier on the user's categorized transactions in ariand and
+// categorizes the lines rules, policies and account defaults left alone. History that can't be
+// fetched only costs the suggestions.
+func suggestCategories(backend client.TransactionLister, lister client.CategoryLister, cfg importConfig, transactions []*domain.Transaction, warnf func(string, ...any)) {
+	uncategorized := 0
+	for _, tx := range transactions {
+		if tx.Category == "" && tx.CategoryID == nil {
+			uncategorized++
+		}
+	}
+	if uncategorized == 0 {
+		return
+	}
+
+	history, err := backend.ListTransactions(cfg.userID, int32(cfg.classifierHistory))
+	if err != nil {
+		warnf("no category suggestions: %v", err)
+		return
+	}
+
+	// Listed transactions usually carry their category, older ariand versions only the ID
+	var slugs map[int64]string
+	examples := make([]categorize.Example, 0, len(history))
+	for _, tx := range history {
+		if tx.CategoryId == nil || tx.GetDescription() == "" {
+			continue
+		}
+		slug := tx.GetCategory().GetSlug()
+		if slug == "" {
+			if slugs == nil {
+				categories, err := lister.ListCategories(cfg.userID)
+				if err != nil {
+					warnf("no category suggestions: %v", err)
+					return
+				}
+				slugs = make(map[int64]string, len(categories))
+				for _, category := range categories {
+					slugs[category.Id] = category.Slug
+				}
+			}
+			slug = slugs[*tx.CategoryId]
+		}
+		examples = append(examples, categorize.Example{Description: tx.GetDescription(), Category: slug})
+	}
+
+	model := categorize.Train(examples)
+	suggested := model.Suggest(transactions, cfg.classifierThreshold)
+	fmt.Printf("categorized %d of %d uncategorized transactions from %d in ariand\n", suggested, uncategorized, model.Examples())
+}
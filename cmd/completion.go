@@ -0,0 +1,278 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// completionName is the command the scripts complete
+const completionName = "arian-statement-parser"
+
+// Kinds of flag values the scripts complete
+const (
+	valueNone = ""      // a bool flag, nothing follows it
+	valueFile = "file"  // a path
+	valueDir  = "dir"   // a folder
+	valueAny  = "value" // anything, e.g. a number or duration
+)
+
+// completionFlag is a flag and what follows it: a kind above, or the values it accepts
+type completionFlag struct {
+	name   string
+	kind   string
+	values []string
+}
+
+// completionCommand lists the flags of a subcommand, or of an import for the empty name
+type completionCommand struct {
+	flags []completionFlag
+	args  []string // positional arguments, e.g. auth test
+}
+
+// completionHints say what follows flags that take a value, by "command -flag" or by "-flag" for
+// every command. Flags without a hint take free text, like numbers and durations.
+var completionHints = map[string]completionFlag{
+	"-pdf":           {kind: valueDir},
+	"anonymize -pdf": {kind: valueFile},
+	"-config":        {kind: valueFile},
+	"-json":          {kind: valueFile},
+	"-out":           {kind: valueFile},
+	"-record":        {kind: valueFile},
+	"-replay":        {kind: valueFile},
+	"-export":        {kind: valueFile},
+	"-retry-file":    {kind: valueFile},
+	"-archive-dir":   {kind: valueDir},
+	"-debug-dump":    {kind: valueDir},
+	"-source":        {values: []string{"s3", "sftp", "webdav", "gdrive", "dropbox"}},
+	"-login":         {values: []string{"gdrive", "dropbox"}},
+	"-transport":     {values: []string{"grpc", "connect"}},
+	"-report":        {values: []string{"markdown", "html"}},
+}
+
+// completionCommands are built from the command docs, so the scripts offer the flags the commands define
+var completionCommands = buildCompletionCommands()
+
+func buildCompletionCommands() map[string]completionCommand {
+	commands := make(map[string]completionCommand, len(commandDocs))
+	var names []string
+	for _, doc := range commandDocs {
+		if doc.name != "" {
+			names = append(names, doc.name)
+		}
+	}
+
+	for _, doc := range commandDocs {
+		command := completionCommand{args: doc.args}
+		if doc.name == "help" {
+			command.args = names
+		}
+
+		flagSetOf(doc).VisitAll(func(f *flag.Flag) {
+			cf := completionFlag{name: f.Name, kind: valueAny}
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+				cf.kind = valueNone
+			} else if hint, ok := completionHints[doc.name+" -"+f.Name]; ok {
+				cf.kind, cf.values = hint.kind, hint.values
+			} else if hint, ok := completionHints["-"+f.Name]; ok {
+				cf.kind, cf.values = hint.kind, hint.values
+			}
+			command.flags = append(command.flags, cf)
+		})
+		commands[doc.name] = command
+	}
+	return commands
+}
+
+// runCompletion prints a completion script for bash, zsh or fish
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s completion bash|zsh|fish", completionName)
+	}
+
+	var script string
+	switch args[0] {
+	case "bash":
+		script = bashCompletion()
+	case "zsh":
+		script = zshCompletion()
+	case "fish":
+		script = fishCompletion()
+	default:
+		return fmt.Errorf("unknown shell %q, want bash, zsh or fish", args[0])
+	}
+
+	_, err := os.Stdout.WriteString(script)
+	return err
+}
+
+// subcommandNames returns the subcommands in order, without the import
+func subcommandNames() []string {
+	var names []string
+	for name := range completionCommands {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func bashCompletion() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `# bash completion for %[1]s, load with: source <(%[1]s completion bash)
+_arian_statement_parser() {
+    local cur prev cmd
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    cmd=""
+    if [[ ${COMP_CWORD} -gt 1 ]]; then
+        case "${COMP_WORDS[1]}" in
+            %[2]s) cmd="${COMP_WORDS[1]}" ;;
+        esac
+    fi
+
+    case "${cmd}:${prev}" in
+`, completionName, strings.Join(subcommandNames(), "|"))
+
+	for _, name := range append([]string{""}, subcommandNames()...) {
+		for _, f := range completionCommands[name].flags {
+			var action string
+			switch {
+			case len(f.values) > 0:
+				action = fmt.Sprintf(`COMPREPLY=($(compgen -W "%s" -- "${cur}"))`, strings.Join(f.values, " "))
+			case f.kind == valueFile:
+				action = `COMPREPLY=($(compgen -f -- "${cur}"))`
+			case f.kind == valueDir:
+				action = `COMPREPLY=($(compgen -d -- "${cur}"))`
+			case f.kind == valueAny:
+				action = `COMPREPLY=()`
+			default:
+				continue
+			}
+			fmt.Fprintf(&b, "        %s:-%s) %s; return ;;\n", name, f.name, action)
+		}
+	}
+	b.WriteString("    esac\n\n    case \"${cmd}\" in\n")
+
+	for _, name := range subcommandNames() {
+		command := completionCommands[name]
+		words := append(flagWords(command.flags), command.args...)
+		fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W \"%s\" -- \"${cur}\")) ;;\n", name, strings.Join(words, " "))
+	}
+
+	// A subcommand can only come first, after that only import flags make sense
+	flags := strings.Join(flagWords(completionCommands[""].flags), " ")
+	fmt.Fprintf(&b, `        *)
+            if [[ ${COMP_CWORD} -eq 1 ]]; then
+                COMPREPLY=($(compgen -W "%s %s" -- "${cur}"))
+            else
+                COMPREPLY=($(compgen -W "%s" -- "${cur}"))
+            fi
+            ;;
+`, strings.Join(subcommandNames(), " "), flags, flags)
+
+	fmt.Fprintf(&b, `    esac
+}
+complete -o default -F _arian_statement_parser %s
+`, completionName)
+	return b.String()
+}
+
+func flagWords(flags []completionFlag) []string {
+	words := make([]string, 0, len(flags))
+	for _, f := range flags {
+		words = append(words, "-"+f.name)
+	}
+	return words
+}
+
+func zshCompletion() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `#compdef %[1]s
+# zsh completion for %[1]s, load with: source <(%[1]s completion zsh)
+
+_arian_statement_parser() {
+    local -a subcommands
+    subcommands=(%[2]s)
+
+    if (( CURRENT == 2 )) && [[ ${words[2]} != -* ]]; then
+        _describe 'command' subcommands
+        return
+    fi
+
+    case ${words[2]} in
+`, completionName, strings.Join(subcommandNames(), " "))
+
+	for _, name := range subcommandNames() {
+		// Without the program name the subcommand is the first word, so its arguments count from 1
+		fmt.Fprintf(&b, "        %s)\n            shift words; (( CURRENT-- ))\n            _arguments %s\n            ;;\n", name, zshSpecs(completionCommands[name]))
+	}
+	fmt.Fprintf(&b, "        *)\n            _arguments %s\n            ;;\n", zshSpecs(completionCommands[""]))
+
+	fmt.Fprintf(&b, `    esac
+}
+
+compdef _arian_statement_parser %s
+`, completionName)
+	return b.String()
+}
+
+// zshSpecs turns a command's flags and arguments into _arguments specs
+func zshSpecs(command completionCommand) string {
+	var specs []string
+	if command.args != nil {
+		specs = append(specs, fmt.Sprintf("'1:argument:(%s)'", strings.Join(command.args, " ")))
+	}
+	for _, f := range command.flags {
+		var action string
+		switch {
+		case len(f.values) > 0:
+			action = fmt.Sprintf(":%s:(%s)", f.name, strings.Join(f.values, " "))
+		case f.kind == valueFile:
+			action = ":file:_files"
+		case f.kind == valueDir:
+			action = ":folder:_files -/"
+		case f.kind == valueAny:
+			action = ":" + f.name + ": "
+		}
+		specs = append(specs, fmt.Sprintf("'-%s%s'", f.name, action))
+	}
+	return strings.Join(specs, " ")
+}
+
+func fishCompletion() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %[1]s, load with: %[1]s completion fish | source\n", completionName)
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_use_subcommand' -f -a '%s'\n", completionName, strings.Join(subcommandNames(), " "))
+
+	for _, name := range append([]string{""}, subcommandNames()...) {
+		command := completionCommands[name]
+		// Flag values are words too, so the import is recognized by the lack of a subcommand
+		condition := "not __fish_seen_subcommand_from " + strings.Join(subcommandNames(), " ")
+		if name != "" {
+			condition = "__fish_seen_subcommand_from " + name
+		}
+
+		if command.args != nil {
+			fmt.Fprintf(&b, "complete -c %s -n '%s' -f -a '%s'\n", completionName, condition, strings.Join(command.args, " "))
+		}
+		for _, f := range command.flags {
+			var action string
+			switch {
+			case len(f.values) > 0:
+				action = fmt.Sprintf(" -x -a '%s'", strings.Join(f.values, " "))
+			case f.kind == valueFile:
+				action = " -r -F"
+			case f.kind == valueDir:
+				action = " -x -a '(__fish_complete_directories)'"
+			case f.kind == valueAny:
+				action = " -x"
+			}
+			fmt.Fprintf(&b, "complete -c %s -n '%s' -o %s%s\n", completionName, condition, f.name, action)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/report"
+)
+
+// How an attended import asks before uploading, chosen with -confirm
+const (
+	confirmAll        = "all"         // one prompt for everything
+	confirmPerAccount = "per-account" // a prompt per statement account, declined ones are left out
+)
+
+// checkConfirm validates a -confirm value, defaulting to all
+func checkConfirm(mode string) (string, error) {
+	switch mode {
+	case "":
+		return confirmAll, nil
+	case confirmAll, confirmPerAccount:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown -confirm %q, want all or per-account", mode)
+	}
+}
+
+// confirmAccounts asks about the transactions of each statement account in turn, as label names
+// them, and splits them into those of the accounts the user approved and those declined. Each
+// prompt shows the account's count, money in and out, and dates, one row per currency, and a sample
+// of its lines as printPreview does.
+func confirmAccounts(transactions []*domain.Transaction, label func(*domain.Transaction) string, previewLines int, reader *bufio.Reader) (approved, declined []*domain.Transaction, err error) {
+	lines := make(map[string][]*domain.Transaction)
+	for _, tx := range transactions {
+		lines[label(tx)] = append(lines[label(tx)], tx)
+	}
+
+	totals := report.Build("", time.Time{}, report.Status{}, transactions, label)
+	byAccount := make(map[string][]report.Account)
+	var names []string
+	for _, account := range totals.Accounts {
+		if byAccount[account.Name] == nil {
+			names = append(names, account.Name)
+		}
+		byAccount[account.Name] = append(byAccount[account.Name], account)
+	}
+
+	yes := make(map[string]bool)
+	for i, name := range names {
+		fmt.Printf("\n%s (%d of %d):\n", name, i+1, len(names))
+		count := 0
+		for _, account := range byAccount[name] {
+			fmt.Printf("  %d transactions, in %.2f, out %.2f %s, %s to %s\n", account.Count, account.In, account.Out,
+				account.Currency, account.First.Format("2006-01-02"), account.Last.Format("2006-01-02"))
+			count += account.Count
+		}
+		printSample(os.Stdout, lines[name], previewLines)
+		fmt.Printf("upload these %d transactions? (y/N): ", count)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, nil, fmt.Errorf("read failed: %w", err)
+		}
+		response = strings.TrimSpace(strings.ToLower(response))
+		yes[name] = response == "y" || response == "yes"
+	}
+
+	for _, tx := range transactions {
+		if yes[label(tx)] {
+			approved = append(approved, tx)
+		} else {
+			declined = append(declined, tx)
+		}
+	}
+	fmt.Printf("\nuploading %d of %d transactions\n", len(approved), len(transactions))
+	return approved, declined, nil
+}
+
+// printPreview shows a sample of each account's lines, so an extraction that went wrong, with every
+// date the same or amounts in the wrong column, can be spotted before the upload prompt
+func printPreview(transactions []*domain.Transaction, label func(*domain.Transaction) string, n int) {
+	if n <= 0 {
+		return
+	}
+	lines := make(map[string][]*domain.Transaction)
+	for _, tx := range transactions {
+		lines[label(tx)] = append(lines[label(tx)], tx)
+	}
+	for _, name := range slices.Sorted(maps.Keys(lines)) {
+		fmt.Printf("\n%s, %d transactions:\n", name, len(lines[name]))
+		printSample(os.Stdout, lines[name], n)
+	}
+}
+
+// printSample writes the first and last n lines of one account by date and its n largest, or every
+// line when there are no more than 2n
+func printSample(w io.Writer, transactions []*domain.Transaction, n int) {
+	if n <= 0 {
+		return
+	}
+	byDate := slices.Clone(transactions)
+	slices.SortStableFunc(byDate, func(a, b *domain.Transaction) int { return a.TxDate.Compare(b.TxDate) })
+
+	section := func(title string, lines []*domain.Transaction) {
+		fmt.Fprintf(w, "  %s\n", title)
+		for _, tx := range lines {
+			amount := tx.TxAmount
+			if tx.TxDirection == domain.Out {
+				amount = -amount
+			}
+			fmt.Fprintf(w, "    %s %12.2f %s  %s\n", tx.TxDate.Format(time.DateOnly), amount, tx.TxCurrency, previewDescription(tx.TxDesc))
+		}
+	}
+	if len(byDate) <= 2*n {
+		section("all", byDate)
+	} else {
+		largest := slices.Clone(byDate)
+		slices.SortStableFunc(largest, func(a, b *domain.Transaction) int { return cmp.Compare(b.TxAmount, a.TxAmount) })
+		section("first", byDate[:n])
+		section("last", byDate[len(byDate)-n:])
+		section("largest", largest[:n])
+	}
+}
+
+// previewDescription keeps a description to one table cell
+func previewDescription(description string) string {
+	const width = 50
+	if runes := []rune(description); len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return description
+}
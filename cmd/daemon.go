@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"arian-statement-parser/internal/notify"
+	"arian-statement-parser/internal/schedule"
+)
+
+// runDaemon runs unattended imports on a cron schedule until interrupted
+func runDaemon(cfg importConfig, expr string, jitter time.Duration) error {
+	sched, err := schedule.Parse(expr)
+	if err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Each tick scans the local folder and/or every configured remote source
+	kinds := splitList(cfg.sourceKind)
+	if len(kinds) == 0 {
+		kinds = []string{""}
+	}
+
+	cfg.unattended = true
+
+	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule %q never fires", expr)
+		}
+		next = next.Add(schedule.Jitter(jitter))
+
+		log.Printf("next import at %s", next.Format(time.RFC3339))
+
+		select {
+		case <-ctx.Done():
+			log.Printf("stopping")
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+
+		for _, kind := range kinds {
+			runCfg := cfg
+			runCfg.sourceKind = kind
+
+			summary, err := runImport(ctx, runCfg)
+			writeResult(runCfg, summary, err)
+			if err == nil {
+				continue
+			}
+			// An interrupt mid-import is a shutdown, not a failure to report
+			if ctx.Err() != nil {
+				log.Printf("stopping")
+				return nil
+			}
+
+			log.Printf("ERROR: import from %s failed: %v", sourceLabel(kind), err)
+
+			// Failed runs never reach the upload summary, so report them here
+			summary.Errors = append(summary.Errors, err.Error())
+			summary.FinishedAt = time.Now()
+			for _, nerr := range notify.NotifyAll(ctx, cfg.notifiers, summary) {
+				log.Printf("WARN: notification failed: %v", nerr)
+			}
+		}
+	}
+}
+
+func sourceLabel(kind string) string {
+	if strings.TrimSpace(kind) == "" {
+		return "local folder"
+	}
+	return kind
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"arian-statement-parser/internal/client/fake"
+)
+
+const demoUserID = "00000000-0000-0000-0000-0000000000de"
+
+// startDemo runs an in-memory ariand so the full import flow can be tried without a server. It also
+// returns a temporary folder for the state files, so the demo's mappings, rules and other records
+// don't end up next to the real ones.
+func startDemo() (*fake.Server, string, string, error) {
+	dir, err := os.MkdirTemp("", "arian-demo-")
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to create demo state dir: %w", err)
+	}
+
+	server := fake.New("")
+	server.AddUser(demoUserID, "demo@example.com")
+	server.AddCategory("transfer")
+
+	addr, err := server.Start()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", "", fmt.Errorf("failed to start demo server: %w", err)
+	}
+	return server, dir, addr, nil
+}
+
+// printDemoResult shows what the demo server ended up holding, since nothing is persisted, and
+// removes the demo's state dir
+func printDemoResult(server *fake.Server, dir string) {
+	defer os.RemoveAll(dir)
+
+	accounts := server.Accounts()
+	transactions := server.Transactions()
+
+	fmt.Printf("\ndemo server received %d accounts and %d transactions (discarded on exit)\n", len(accounts), len(transactions))
+	for _, account := range accounts {
+		count := 0
+		for _, tx := range transactions {
+			if tx.AccountId == account.Id {
+				count++
+			}
+		}
+		fmt.Printf("  %s (%s): %d transactions\n", account.Name, account.Type, count)
+	}
+}
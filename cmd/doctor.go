@@ -0,0 +1,443 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/enrich"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/mapping"
+	"arian-statement-parser/internal/notes"
+	"arian-statement-parser/internal/notify"
+	"arian-statement-parser/internal/parser"
+	"arian-statement-parser/internal/pending"
+	"arian-statement-parser/internal/report"
+	"arian-statement-parser/internal/review"
+	"arian-statement-parser/internal/rules"
+	"arian-statement-parser/internal/schedule"
+	"arian-statement-parser/internal/state"
+	"arian-statement-parser/pkg/importer"
+)
+
+// Outcomes of a doctor check
+const (
+	checkPass = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+	checkSkip = "skip"
+)
+
+// maxClockSkew is how far the local clock may drift from ariand's before doctor complains; TLS
+// and scheduled runs start to misbehave well before a few minutes
+const maxClockSkew = 2 * time.Minute
+
+// doctorCheck is the outcome of one check, with what to do about it when it didn't pass
+type doctorCheck struct {
+	name   string
+	status string
+	detail string
+	fix    string
+}
+
+// doctorOptions are the flags of doctor
+type doctorOptions struct {
+	pdfPath    *string
+	configPath *string
+}
+
+// doctorFlags defines the flags of doctor on fs
+func doctorFlags(fs *flag.FlagSet) *doctorOptions {
+	return &doctorOptions{
+		pdfPath:    fs.String("pdf", "", "statement folder imports use, defaults to PDF_PATH; the Python checks are skipped when it only holds exports"),
+		configPath: fs.String("config", "", "parser config file to check"),
+	}
+}
+
+// runDoctor checks everything an import depends on and prints what passed, what failed and how to
+// fix it. It fails when any check does.
+func runDoctor(args []string) error {
+	fs := newFlagSet("doctor")
+	opts := doctorFlags(fs)
+	fs.Parse(args)
+
+	if *opts.pdfPath == "" {
+		*opts.pdfPath = os.Getenv("PDF_PATH")
+	}
+
+	checks := doctorPython(*opts.pdfPath)
+	checks = append(checks, doctorConfig(*opts.configPath)...)
+	checks = append(checks, doctorState()...)
+
+	arianChecks, accounts := doctorAriand()
+	checks = append(checks, arianChecks...)
+	checks = append(checks, doctorClock())
+	checks = append(checks, doctorMappings(accounts))
+
+	failed := 0
+	for _, check := range checks {
+		fmt.Printf("[%-4s] %-9s %s\n", check.status, check.name, check.detail)
+		if check.fix != "" && check.status != checkPass {
+			fmt.Printf("                 fix: %s\n", check.fix)
+		}
+		if check.status == checkFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// doctorPython checks uv and the parser checkout, unless the statement folder only holds exports
+// that the Go parsers read
+func doctorPython(pdfPath string) []doctorCheck {
+	if pdfPath != "" && os.Getenv("STATEMENT_SOURCE") == "" {
+		if entries, err := os.ReadDir(pdfPath); err == nil {
+			exports, pdfs := 0, 0
+			for _, entry := range entries {
+				switch strings.ToLower(filepath.Ext(entry.Name())) {
+				case ".pdf":
+					pdfs++
+				case ".csv", ".ofx", ".qfx", ".txt", ".json":
+					exports++
+				}
+			}
+			if pdfs == 0 && exports > 0 {
+				return []doctorCheck{{name: "python", status: checkSkip, detail: fmt.Sprintf("%s has no PDFs, so the Python parser isn't used", pdfPath)}}
+			}
+		}
+	}
+
+	var checks []doctorCheck
+	if uv, err := exec.LookPath("uv"); err != nil {
+		checks = append(checks, doctorCheck{
+			name: "python", status: checkFail, detail: "uv is not on PATH, PDF statements can't be parsed",
+			fix: "install uv (https://docs.astral.sh/uv/), it brings the Python the parser needs",
+		})
+	} else {
+		version, err := exec.Command(uv, "--version").Output()
+		if err != nil {
+			checks = append(checks, doctorCheck{name: "python", status: checkFail, detail: fmt.Sprintf("%s doesn't run: %v", uv, err), fix: "reinstall uv"})
+		} else {
+			checks = append(checks, doctorCheck{name: "python", status: checkPass, detail: strings.TrimSpace(string(version))})
+		}
+	}
+
+	script := filepath.Join("rbc-statement-parser", "main.py")
+	if _, err := os.Stat(script); err != nil {
+		checks = append(checks, doctorCheck{
+			name: "parser", status: checkFail, detail: fmt.Sprintf("%s is missing", script),
+			fix: "run from the root of the repository checkout, where rbc-statement-parser lives",
+		})
+	} else {
+		checks = append(checks, doctorCheck{name: "parser", status: checkPass, detail: script})
+	}
+	return checks
+}
+
+// doctorConfig checks the settings an import reads, the same way it does at start
+func doctorConfig(configPath string) []doctorCheck {
+	var problems []string
+	add := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	_, err := clientSettings()
+	add(err)
+	_, err = importer.CheckCardPaymentPolicy(os.Getenv("CARD_PAYMENT_POLICY"))
+	add(err)
+	_, err = importer.CheckAccentPolicy(os.Getenv("DESCRIPTION_ACCENTS"))
+	add(err)
+	_, err = checkClassifier(os.Getenv("CATEGORIZE"))
+	add(err)
+	_, err = checkMismatchPolicy(os.Getenv("ACCOUNT_TYPE_MISMATCH"))
+	add(err)
+
+	var number float64
+	var count int
+	for _, name := range []string{"GUARD_MAX_AMOUNT", "GUARD_MAX_IDENTICAL_PERCENT", "CONFIDENCE_THRESHOLD", "CATEGORIZE_THRESHOLD"} {
+		add(envFloat(name, &number))
+	}
+	for _, name := range []string{"GUARD_MAX_STATEMENT_TRANSACTIONS", "GUARD_HISTORY", "CATEGORIZE_HISTORY", "UPLOAD_CONCURRENCY", "DESCRIPTION_MAX_LENGTH", "PREVIEW_LINES"} {
+		add(envInt(name, &count))
+	}
+	var size uint64
+	add(envSize("MAX_MEMORY", &size))
+
+	if os.Getenv("MERCHANT_LLM_URL") != "" && os.Getenv("MERCHANT_LLM_MODEL") == "" {
+		add(errors.New("MERCHANT_LLM_MODEL is required with MERCHANT_LLM_URL"))
+	}
+	if data := os.Getenv("MERCHANT_DATA"); data != "" {
+		_, err := loadMerchantDirectory(data)
+		add(err)
+	}
+	if lookupURL := os.Getenv("MERCHANT_LOOKUP_URL"); lookupURL != "" {
+		_, err := enrich.NewLookup(lookupURL, nil)
+		add(err)
+	}
+
+	_, err = notes.New("NOTES_TEMPLATE", os.Getenv("NOTES_TEMPLATE"), os.Getenv("DESCRIPTION_TEMPLATE"))
+	add(err)
+	if format := os.Getenv("REPORT_FORMAT"); format != "" {
+		add(report.CheckFormat(format))
+	}
+	if webhookURL := os.Getenv("WEBHOOK_URL"); webhookURL != "" {
+		_, err := notify.NewWebhook(webhookURL, os.Getenv("WEBHOOK_FORMAT"))
+		add(err)
+	}
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		_, err := notify.NewEmail(notify.EmailConfig{
+			Host:     smtpHost,
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+			To:       splitList(os.Getenv("SMTP_TO")),
+		})
+		add(err)
+	}
+	if expr := os.Getenv("SCHEDULE"); expr != "" {
+		_, err := schedule.Parse(expr)
+		add(err)
+	}
+	if jitter := os.Getenv("SCHEDULE_JITTER"); jitter != "" {
+		if _, err := time.ParseDuration(jitter); err != nil {
+			add(fmt.Errorf("invalid SCHEDULE_JITTER: %w", err))
+		}
+	}
+	if kind := os.Getenv("STATEMENT_SOURCE"); kind != "" {
+		_, err := newSource(kind)
+		add(err)
+	}
+
+	checks := []doctorCheck{{name: "settings", status: checkPass, detail: "environment and .env are valid"}}
+	if len(problems) > 0 {
+		checks[0] = doctorCheck{
+			name: "settings", status: checkFail, detail: strings.Join(problems, "; "),
+			fix: "correct them in .env, .env.example describes every setting",
+		}
+	}
+
+	if configPath != "" {
+		if _, err := parser.LoadConfig(configPath); err != nil {
+			checks = append(checks, doctorCheck{name: "config", status: checkFail, detail: err.Error(), fix: "see Extraction Profiles in the readme for the format"})
+		} else {
+			checks = append(checks, doctorCheck{name: "config", status: checkPass, detail: configPath})
+		}
+	}
+
+	templateDir := os.Getenv("TEMPLATE_DIR")
+	if templateDir == "" {
+		templateDir = "templates"
+	}
+	if _, err := parser.LoadTemplates(templateDir); err != nil {
+		checks = append(checks, doctorCheck{name: "templates", status: checkFail, detail: err.Error(), fix: "fix or remove the template, see Text Statement Templates in the readme"})
+	}
+	if _, err := rules.NewSet(); err != nil {
+		checks = append(checks, doctorCheck{name: "rules", status: checkFail, detail: err.Error(), fix: "fix arian-rules.json, or move it away to start from the default rules"})
+	}
+	return checks
+}
+
+// doctorState checks that the working directory and the parse cache can be written, and that the
+// state files in them still load
+func doctorState() []doctorCheck {
+	var checks []doctorCheck
+
+	if err := writable("."); err != nil {
+		checks = append(checks, doctorCheck{
+			name: "state", status: checkFail, detail: fmt.Sprintf("the working directory can't be written: %v", err),
+			fix: "run from a directory you own, state files like account-mappings.txt live there",
+		})
+	} else {
+		var problems []string
+		if _, err := state.NewStore(); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if _, err := pending.NewStore(); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if _, err := review.Load(review.DefaultPath); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if _, err := enrich.LoadCache(enrich.DefaultCachePath); err != nil {
+			problems = append(problems, err.Error())
+		}
+
+		if len(problems) > 0 {
+			checks = append(checks, doctorCheck{
+				name: "state", status: checkFail, detail: strings.Join(problems, "; "),
+				fix: "repair the file named, or move it aside to start it over",
+			})
+		} else {
+			checks = append(checks, doctorCheck{name: "state", status: checkPass, detail: "working directory is writable and its state files load"})
+		}
+	}
+
+	if info, err := os.Stat(envPath); err == nil && info.Mode().Perm()&0o077 != 0 && os.Getenv("API_KEY") != "" {
+		checks = append(checks, doctorCheck{
+			name: "state", status: checkWarn, detail: fmt.Sprintf("%s holds the API key and others can read it", envPath),
+			fix: fmt.Sprintf("chmod 600 %s, or move the key to the keyring with auth set-key", envPath),
+		})
+	}
+
+	dir := os.Getenv("PARSE_CACHE_DIR")
+	if dir == "" {
+		dir, _ = parser.DefaultCacheDir()
+	}
+	if dir != "" {
+		if _, err := parser.NewCache(dir); err != nil {
+			checks = append(checks, doctorCheck{name: "cache", status: checkWarn, detail: err.Error(), fix: "set PARSE_CACHE_DIR to a writable folder, or import with -no-cache"})
+		} else if err := writable(dir); err != nil {
+			checks = append(checks, doctorCheck{name: "cache", status: checkWarn, detail: fmt.Sprintf("%s can't be written: %v", dir, err), fix: "set PARSE_CACHE_DIR to a writable folder, or import with -no-cache"})
+		} else {
+			checks = append(checks, doctorCheck{name: "cache", status: checkPass, detail: dir})
+		}
+	}
+	return checks
+}
+
+// writable creates and removes a file in dir
+func writable(dir string) error {
+	file, err := os.CreateTemp(dir, ".arian-doctor-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// doctorAriand runs the preflight and the feature handshake, returning the user's accounts when
+// ariand could be reached
+func doctorAriand() ([]doctorCheck, []*pb.Account) {
+	serverURL := os.Getenv("ARIAND_URL")
+	userID := os.Getenv("USER_ID")
+	if serverURL == "" || userID == "" {
+		return []doctorCheck{{name: "ariand", status: checkFail, detail: "ARIAND_URL or USER_ID is not set", fix: fmt.Sprintf("run %s init", completionName)}}, nil
+	}
+
+	keySource := apiKeySource()
+	apiKey, err := loadAPIKey(keySource)
+	if err != nil {
+		return []doctorCheck{{name: "ariand", status: checkFail, detail: err.Error(), fix: "set API_KEY, API_KEY_FILE or API_KEY_CMD, or run auth set-key"}}, nil
+	}
+
+	settings, err := clientSettings()
+	if err != nil {
+		return []doctorCheck{{name: "ariand", status: checkSkip, detail: "settings are invalid, see above"}}, nil
+	}
+	arianClient, err := client.NewClientWithSettings(serverURL, apiKey, settings)
+	if err != nil {
+		return []doctorCheck{{name: "ariand", status: checkFail, detail: err.Error()}}, nil
+	}
+	defer arianClient.Close()
+	arianClient.SetKeySource(keySource)
+
+	user, err := arianClient.Preflight(userID)
+	if err != nil {
+		detail, fix, _ := strings.Cut(preflightError(err, serverURL).Error(), "\n  hint: ")
+		return []doctorCheck{{name: "ariand", status: checkFail, detail: detail, fix: fix}}, nil
+	}
+	accounts, err := arianClient.GetAccounts(userID)
+	if err != nil {
+		return []doctorCheck{{name: "ariand", status: checkFail, detail: fmt.Sprintf("get accounts failed: %v", err)}}, nil
+	}
+
+	checks := []doctorCheck{{name: "ariand", status: checkPass, detail: fmt.Sprintf("%s reachable, user %s has %d accounts", serverURL, user.Email, len(accounts))}}
+
+	caps, err := arianClient.Handshake()
+	switch {
+	case err != nil:
+		checks = append(checks, doctorCheck{name: "features", status: checkWarn, detail: err.Error()})
+	case !caps.Known:
+		checks = append(checks, doctorCheck{name: "features", status: checkSkip, detail: "ariand doesn't offer gRPC reflection"})
+	case len(caps.Missing()) > 0:
+		var names []string
+		for _, f := range caps.Missing() {
+			names = append(names, f.Name)
+		}
+		checks = append(checks, doctorCheck{name: "features", status: checkWarn, detail: "ariand is too old for " + strings.Join(names, ", "), fix: "upgrade ariand"})
+	default:
+		checks = append(checks, doctorCheck{name: "features", status: checkPass, detail: "ariand supports everything the importer uses"})
+	}
+	return checks, accounts
+}
+
+// doctorClock compares the local clock with the Date header of whatever answers HTTP at ariand's
+// address. A bare gRPC server sends none, so the check is skipped then.
+func doctorClock() doctorCheck {
+	serverURL := os.Getenv("ARIAND_URL")
+	if serverURL == "" {
+		return doctorCheck{name: "clock", status: checkSkip, detail: "no ARIAND_URL to compare with"}
+	}
+
+	url := serverURL
+	if !strings.Contains(url, "://") {
+		if strings.HasSuffix(url, ":443") {
+			url = "https://" + strings.TrimSuffix(url, ":443")
+		} else {
+			url = "http://" + url
+		}
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	start := time.Now()
+	resp, err := httpClient.Head(url)
+	if err != nil {
+		return doctorCheck{name: "clock", status: checkSkip, detail: fmt.Sprintf("ariand's address doesn't answer HTTP: %v", err)}
+	}
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return doctorCheck{name: "clock", status: checkSkip, detail: "ariand's address sends no Date header to compare with"}
+	}
+
+	// The header has whole seconds and was stamped somewhere during the request
+	local := start.Add(time.Since(start) / 2)
+	skew := local.Sub(serverTime).Round(time.Second)
+	if skew.Abs() > maxClockSkew {
+		return doctorCheck{
+			name: "clock", status: checkFail, detail: fmt.Sprintf("the local clock is %s off from ariand's", skew),
+			fix: "turn on time sync, e.g. timedatectl set-ntp true",
+		}
+	}
+	return doctorCheck{name: "clock", status: checkPass, detail: fmt.Sprintf("within %s of ariand's", maxClockSkew)}
+}
+
+// doctorMappings checks the mappings file, and the accounts it names when ariand was reached
+func doctorMappings(accounts []*pb.Account) doctorCheck {
+	store, err := mapping.NewStore()
+	if err != nil {
+		return doctorCheck{name: "mappings", status: checkFail, detail: err.Error(), fix: "repair account-mappings.txt or account-settings.json"}
+	}
+
+	problems, err := store.Problems(accounts)
+	if err != nil {
+		return doctorCheck{name: "mappings", status: checkFail, detail: err.Error()}
+	}
+	if len(problems) > 0 {
+		return doctorCheck{
+			name: "mappings", status: checkFail, detail: strings.Join(problems, "; "),
+			fix: "edit account-mappings.txt, or delete the lines so the next import asks again",
+		}
+	}
+
+	detail := fmt.Sprintf("%d statement accounts mapped", len(store.Mappings))
+	if accounts == nil {
+		detail += ", not checked against ariand"
+	}
+	return doctorCheck{name: "mappings", status: checkPass, detail: detail}
+}
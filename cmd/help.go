@@ -0,0 +1,452 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// commandDoc describes a command for -help, the help subcommand and the man page
+type commandDoc struct {
+	name     string   // empty for the import
+	usage    string   // what follows the command name
+	args     []string // positional arguments it accepts, for completion
+	summary  string
+	details  string
+	flags    func(fs *flag.FlagSet) // defines its flags, the same way the command does
+	examples []example
+}
+
+type example struct {
+	command string
+	what    string
+}
+
+// commandDocs describe the import first and then every subcommand, in the order help lists them
+var commandDocs = []commandDoc{
+	{
+		usage:   "[flags]",
+		summary: "import bank statements into ariand",
+		details: "Parses the PDF statements and CSV exports in a folder, or pulled from a remote source, " +
+			"matches their accounts to ariand accounts and uploads the transactions after you confirm. " +
+			"ariand is reached with ARIAND_URL, USER_ID and API_KEY from the environment or a .env file.",
+		flags: func(fs *flag.FlagSet) { importFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser -pdf ~/statements", "import every statement in a folder"},
+			{"arian-statement-parser -pdf ~/statements -demo", "try an import against an in-memory fake of ariand"},
+			{"arian-statement-parser -pdf ~/statements -export out.csv", "write a CSV instead of uploading"},
+			{"arian-statement-parser -source s3 -schedule '0 6 * * *'", "import new statements from S3 every morning"},
+			{"arian-statement-parser -login gdrive", "authorize Google Drive as a source once"},
+		},
+	},
+	{
+		name:    "accounts",
+		usage:   "rename|set-type <account> <value>",
+		args:    []string{"rename", "set-type"},
+		summary: "rename an ariand account or change its type",
+		details: "Fixes an account an import created or matched wrong, found by name or ID. " +
+			"rename gives it a new name and points the saved account mappings at it. " +
+			"set-type changes it to chequing, savings, visa, investment or other.",
+		examples: []example{
+			{"arian-statement-parser accounts rename 'RBC VISA' 'Avion Visa'", "rename an account"},
+			{"arian-statement-parser accounts set-type 'Avion Visa' visa", "make it a credit card"},
+		},
+	},
+	{
+		name:    "anonymize",
+		usage:   "-pdf <statement> [-out <fixture>]",
+		summary: "turn a real statement into a fixture that is safe to share",
+		details: "Parses a statement, or reads parser output, and replaces names, account numbers, " +
+			"descriptions and amounts with fake ones that keep the shape of the original.",
+		flags: func(fs *flag.FlagSet) { anonymizeFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser anonymize -pdf statement.pdf -out fixture.json", "make a fixture for a bug report"},
+			{"arian-statement-parser anonymize -json parsed.json -seed 7", "anonymize parser output the same way each time"},
+		},
+	},
+	{
+		name:    "auth",
+		usage:   "test|set-key",
+		args:    []string{"test", "set-key"},
+		summary: "check ariand credentials or save the API key",
+		details: "test checks ARIAND_URL, the API key and USER_ID without parsing anything. " +
+			"set-key asks for the API key and saves it to the system keyring.",
+		examples: []example{
+			{"arian-statement-parser auth test", "check the credentials before a first import"},
+			{"arian-statement-parser auth set-key", "keep the API key in the keyring instead of .env"},
+		},
+	},
+	{
+		name:    "bench",
+		usage:   "[flags]",
+		summary: "measure parse and upload throughput",
+		details: "Times the parsers on a folder of statements and uploads generated transactions " +
+			"at several concurrency levels, to an in-memory fake of ariand or with -server to ARIAND_URL.",
+		flags: func(fs *flag.FlagSet) { benchFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser bench -pdf ~/statements", "time parsing and uploading"},
+			{"arian-statement-parser bench -n 20000 -concurrency 1,16 -json", "compare two upload levels as JSON"},
+			{"arian-statement-parser bench -server -concurrency 1,4,16", "see how a test ariand scales"},
+		},
+	},
+	{
+		name:    "completion",
+		usage:   "bash|zsh|fish",
+		args:    []string{"bash", "zsh", "fish"},
+		summary: "print a shell completion script",
+		examples: []example{
+			{"source <(arian-statement-parser completion bash)", "complete commands and flags in bash"},
+		},
+	},
+	{
+		name:    "doctor",
+		usage:   "[-pdf <folder>] [-config <file>]",
+		summary: "check everything an import depends on",
+		details: "Checks uv and the Python parser when PDFs need them, the settings in the environment and .env, " +
+			"the parser config, templates and rules, that the working directory and parse cache can be written " +
+			"and their state files load, ariand, the API key and the user, the features ariand serves, " +
+			"the local clock against ariand's and the account mappings. " +
+			"Each check passes or fails with what to do about it, and the command fails when any check does.",
+		flags: func(fs *flag.FlagSet) { doctorFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser doctor", "check the setup in the current directory"},
+			{"arian-statement-parser doctor -config profiles.json", "check a parser config too"},
+		},
+	},
+	{
+		name:    "help",
+		usage:   "[command]",
+		summary: "show help for a command",
+		examples: []example{
+			{"arian-statement-parser help upload", "show the flags of upload"},
+		},
+	},
+	{
+		name:    "init",
+		summary: "set up the connection to ariand and the account mappings",
+		details: "Asks where ariand is, the user ID and the API key, checks them against ariand, " +
+			"lists the user's accounts and optionally maps statement accounts to them. " +
+			"The answers are written to .env, starting from .env.example when there is no .env yet, " +
+			"and the API key can go to the system keyring instead.",
+		examples: []example{
+			{"arian-statement-parser init", "set up a fresh checkout"},
+		},
+	},
+	{
+		name:    "man",
+		summary: "print the man page",
+		examples: []example{
+			{"arian-statement-parser man > arian-statement-parser.1", "write the man page to a file"},
+			{"arian-statement-parser man | man -l -", "read it right away"},
+		},
+	},
+	{
+		name:    "map",
+		usage:   "prune [flags]",
+		args:    []string{"prune"},
+		summary: "remove account mappings nothing uses anymore",
+		details: "Removes the account mappings no import has used for -months months, like those of " +
+			"closed accounts, and those pointing at accounts that are gone from ariand. " +
+			"Mappings saved before imports recorded their use are only removed when their account is gone.",
+		flags: func(fs *flag.FlagSet) { pruneFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser map prune -dry-run", "list what would be removed"},
+			{"arian-statement-parser map prune -months 24", "remove mappings unused for two years"},
+		},
+	},
+	{
+		name:    "parse",
+		usage:   "[flags]",
+		summary: "print what the parsers read from statements as JSON",
+		details: "Parses statements without resolving accounts or uploading anything and prints the " +
+			"result as JSON. With -debug-dump, the Python parser also writes what it extracted from " +
+			"each PDF before parsing: its text, the layout text templates read, the HTML and the " +
+			"position of each piece of text on the page.",
+		flags: func(fs *flag.FlagSet) { parseFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser parse -pdf june.pdf | jq '.transactions[]'", "see the lines of one statement"},
+			{"arian-statement-parser parse -pdf june.pdf -debug-dump debug/", "find out why a line was misread"},
+		},
+	},
+	{
+		name:    "rename",
+		usage:   "[flags]",
+		summary: "name statement files after their bank, account and period",
+		details: "Parses statements without uploading anything and renames each file after a template " +
+			"of what its lines say: the bank, the account's name, type and last four digits, and the " +
+			"dates it covers. Files the parsers read nothing from keep their names.",
+		flags: func(fs *flag.FlagSet) { renameFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser rename -pdf ~/statements -dry-run", "see the new names first"},
+			{"arian-statement-parser rename -template '{{.End}} {{.Account}}'", "name them 2024-06-14 rbc-chequing"},
+		},
+	},
+	{
+		name:    "report",
+		usage:   "[flags]",
+		summary: "print monthly spending by category and merchant",
+		details: "Parses and categorizes statements the way an import would, without uploading " +
+			"anything, and prints what came in and went out each month.",
+		flags: func(fs *flag.FlagSet) { spendingFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser report -pdf ~/statements", "check the numbers before an import"},
+			{"arian-statement-parser report -merchants 10 -json", "list more merchants, as JSON"},
+		},
+	},
+	{
+		name:    "self-update",
+		usage:   "[-check] [-force] [-insecure]",
+		summary: "replace this binary with the latest release",
+		details: "Checks the GitHub releases for a newer version, downloads the binary for this platform, " +
+			"verifies it against the release checksums, and the checksums against their signature, " +
+			"then swaps it in place of the running binary. A build without a release key refuses to " +
+			"update unless -insecure is given. " +
+			"The Python parser next to it is not part of the binary and is updated with git.",
+		flags: func(fs *flag.FlagSet) { selfUpdateFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser self-update -check", "see whether a newer release is out"},
+			{"arian-statement-parser self-update", "install it"},
+		},
+	},
+	{
+		name:    "tx",
+		usage:   "add [flags] <amount> <description>",
+		args:    []string{"add"},
+		summary: "add a line by hand, like cash spending",
+		details: "Adds one transaction to an ariand account, Cash unless -account or TX_ACCOUNT says " +
+			"otherwise, dated today unless -date says otherwise. The amount was spent, or came in " +
+			"with a + in front. Rules categorize it like an imported line.",
+		flags: func(fs *flag.FlagSet) { txFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser tx add 4.75 Tim Hortons", "log a coffee paid in cash"},
+			{"arian-statement-parser tx add -date yesterday -category gifts +40 birthday money", "log cash you got"},
+		},
+	},
+	{
+		name:    "upload",
+		usage:   "-retry-file <report>|-review",
+		summary: "upload failed or reviewed transactions again",
+		details: "Sends the transactions of an error report from a failed import without parsing " +
+			"anything again, or goes through the lines waiting in the review queue.",
+		flags: func(fs *flag.FlagSet) { uploadFlags(fs) },
+		examples: []example{
+			{"arian-statement-parser upload -retry-file errors.json", "retry what failed in the last import"},
+			{"arian-statement-parser upload -review", "approve and upload the lines put aside for review"},
+		},
+	},
+}
+
+// docFor finds the doc of a command, the import for an empty name
+func docFor(name string) (commandDoc, bool) {
+	for _, doc := range commandDocs {
+		if doc.name == name {
+			return doc, true
+		}
+	}
+	return commandDoc{}, false
+}
+
+// newFlagSet creates the flag set of a subcommand, whose -help shows its doc
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		doc, _ := docFor(name)
+		printHelp(fs.Output(), doc, fs)
+	}
+	return fs
+}
+
+// flagSetOf defines a command's flags on a fresh flag set, for help and completion
+func flagSetOf(doc commandDoc) *flag.FlagSet {
+	fs := flag.NewFlagSet(doc.name, flag.ContinueOnError)
+	if doc.flags != nil {
+		doc.flags(fs)
+	}
+	return fs
+}
+
+// printHelp writes the usage, flags and examples of a command
+func printHelp(w io.Writer, doc commandDoc, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s\n", commandLine(completionName, doc.name, doc.usage))
+	if doc.name == "" {
+		fmt.Fprintf(w, "       %s <command> [flags]\n", completionName)
+	}
+	fmt.Fprintf(w, "\n%s\n", capitalize(doc.summary))
+	if doc.details != "" {
+		fmt.Fprintf(w, "\n%s\n", wrap(doc.details, 80))
+	}
+
+	if hasFlags(fs) {
+		fmt.Fprintf(w, "\nFlags:\n")
+		out := fs.Output()
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+		fs.SetOutput(out)
+	}
+
+	if doc.name == "" {
+		fmt.Fprintf(w, "\nCommands:\n")
+		for _, sub := range commandDocs[1:] {
+			fmt.Fprintf(w, "  %-12s %s\n", sub.name, sub.summary)
+		}
+	}
+
+	if len(doc.examples) > 0 {
+		fmt.Fprintf(w, "\nExamples:\n")
+		for i, ex := range doc.examples {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "  # %s\n  %s\n", capitalize(ex.what), ex.command)
+		}
+	}
+
+	if doc.name == "" {
+		fmt.Fprintf(w, "\nRun '%s help <command>' for the flags of a command.\n", completionName)
+	}
+}
+
+// commandLine joins the parts of a usage line that aren't empty
+func commandLine(parts ...string) string {
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	has := false
+	fs.VisitAll(func(*flag.Flag) { has = true })
+	return has
+}
+
+// runHelp prints the help of a command, or of the import and the list of commands
+func runHelp(args []string) error {
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+	doc, ok := docFor(name)
+	if !ok {
+		return fmt.Errorf("unknown command %q, run '%s help' for the list", name, completionName)
+	}
+	printHelp(os.Stdout, doc, flagSetOf(doc))
+	return nil
+}
+
+// runMan prints a man page built from the same docs and flags as help
+func runMan(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: %s man", completionName)
+	}
+	_, err := io.WriteString(os.Stdout, manPage(time.Now()))
+	return err
+}
+
+func manPage(date time.Time) string {
+	var b strings.Builder
+	importDoc := commandDocs[0]
+
+	fmt.Fprintf(&b, ".TH %s 1 %q\n", strings.ToUpper(roff(completionName)), date.Format(time.DateOnly))
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", roff(completionName), roff(importDoc.summary))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B %s\n[flags]\n.br\n.B %s\n.I command\n[flags]\n", roff(completionName), roff(completionName))
+	fmt.Fprintf(&b, ".SH DESCRIPTION\n%s\n", roff(importDoc.details))
+
+	fmt.Fprintf(&b, ".SH OPTIONS\n")
+	manFlags(&b, flagSetOf(importDoc))
+
+	fmt.Fprintf(&b, ".SH COMMANDS\n")
+	for _, doc := range commandDocs[1:] {
+		fmt.Fprintf(&b, ".SS %s\n", roff(commandLine(doc.name, doc.usage)))
+		fmt.Fprintf(&b, "%s.\n", roff(capitalize(doc.summary)))
+		if doc.details != "" {
+			fmt.Fprintf(&b, "%s\n", roff(doc.details))
+		}
+		manFlags(&b, flagSetOf(doc))
+	}
+
+	fmt.Fprintf(&b, ".SH EXAMPLES\n")
+	for _, doc := range commandDocs {
+		for _, ex := range doc.examples {
+			fmt.Fprintf(&b, ".PP\n%s:\n.RS\n.nf\n%s\n.fi\n.RE\n", roff(capitalize(ex.what)), roff(ex.command))
+		}
+	}
+
+	fmt.Fprintf(&b, ".SH ENVIRONMENT\n%s\n", roff("USER_ID, ARIAND_URL and API_KEY say where to upload. "+
+		"Every other setting, from retries to notifications, is an environment variable too; "+
+		"they can also be set in a .env file in the working directory. .env.example lists them all."))
+
+	fmt.Fprintf(&b, ".SH FILES\n")
+	for _, file := range []struct{ name, what string }{
+		{"account-mappings.txt", "which ariand account each statement account uploads to"},
+		{"account-settings.json", "default category, currency and templates per statement account"},
+		{"arian-rules.json", "categorization rules"},
+		{"arian-review.json", "lines waiting for review"},
+		{"arian-merchants.json", "cached merchant names and websites"},
+		{"errors.json", "transactions that failed to upload, for upload -retry-file"},
+	} {
+		fmt.Fprintf(&b, ".TP\n.I %s\n%s\n", roff(file.name), roff(capitalize(file.what)))
+	}
+	return b.String()
+}
+
+// manFlags lists the flags of fs as man page paragraphs
+func manFlags(b *strings.Builder, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		fmt.Fprintf(b, ".TP\n\\fB\\-%s\\fR", roff(f.Name))
+		if name != "" {
+			fmt.Fprintf(b, " \\fI%s\\fR", roff(name))
+		}
+		fmt.Fprintf(b, "\n%s", roff(capitalize(usage)))
+		if !isZeroDefault(f.DefValue) {
+			fmt.Fprintf(b, " (default %s)", roff(f.DefValue))
+		}
+		b.WriteString("\n")
+	})
+}
+
+func isZeroDefault(value string) bool {
+	switch value {
+	case "", "false", "0", "0s":
+		return true
+	}
+	return false
+}
+
+// roff escapes text for the man page
+func roff(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// wrap breaks text into lines of at most width characters
+func wrap(text string, width int) string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strings"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/notify"
+	"arian-statement-parser/internal/validate"
+)
+
+// checkHistory compares the statements of the run with the last GUARD_HISTORY transactions in
+// ariand, and reports whether the upload should go ahead. What stands out has to be confirmed with
+// a typed yes, like a guardrail. Unattended runs go ahead with the findings as warnings instead,
+// since a new card or a trip abroad stands out too.
+func checkHistory(backend client.Uploader, cfg importConfig, transactions []*domain.Transaction, summary *notify.Summary, stdin *bufio.Reader, warnf func(string, ...any)) bool {
+	lister, ok := backend.(client.TransactionLister)
+	if !ok || cfg.guardrails.History <= 0 || !supports(backend, client.FeatureHistory) {
+		return true
+	}
+
+	listed, err := lister.ListTransactions(cfg.userID, int32(cfg.guardrails.History))
+	if err != nil {
+		warnf("statements not compared with ariand: %v", err)
+		return true
+	}
+	past := make([]validate.Past, 0, len(listed))
+	for _, tx := range listed {
+		amount := tx.GetTxAmount()
+		past = append(past, validate.Past{
+			Amount:      math.Abs(float64(amount.GetUnits()) + float64(amount.GetNanos())/1e9),
+			Description: tx.GetDescription(),
+			Merchant:    tx.GetMerchant(),
+		})
+	}
+
+	findings := validate.NewHistory(past).Check(transactions)
+	if len(findings) == 0 {
+		return true
+	}
+	fmt.Println("\nthis doesn't look like what ariand already holds:")
+	for _, finding := range findings {
+		fmt.Printf("  %s\n", finding)
+		summary.Warnings = append(summary.Warnings, finding)
+	}
+	if cfg.unattended {
+		return true
+	}
+
+	fmt.Print("type 'yes' to upload anyway: ")
+	response, _ := stdin.ReadString('\n')
+	if strings.TrimSpace(response) != "yes" {
+		fmt.Println("cancelled")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,1443 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"arian-statement-parser/internal/archive"
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/dedupe"
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/enrich"
+	"arian-statement-parser/internal/export"
+	"arian-statement-parser/internal/failures"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/mapping"
+	"arian-statement-parser/internal/notes"
+	"arian-statement-parser/internal/notify"
+	"arian-statement-parser/internal/parser"
+	"arian-statement-parser/internal/pipeline"
+	"arian-statement-parser/internal/quarantine"
+	"arian-statement-parser/internal/report"
+	"arian-statement-parser/internal/review"
+	"arian-statement-parser/internal/rules"
+	"arian-statement-parser/internal/source"
+	"arian-statement-parser/internal/state"
+	"arian-statement-parser/internal/validate"
+	"arian-statement-parser/pkg/importer"
+
+	"google.golang.org/grpc"
+)
+
+// importConfig holds everything a single import run needs
+type importConfig struct {
+	pdfPath    string
+	configPath string
+	// institution is the bank every PDF is read as, "rbc" or a template's name or bank, detected per
+	// file when empty
+	institution string
+	sourceKind  string
+	userID      string
+	serverURL   string
+	apiKey      string
+	// apiKeySource reloads the key when ariand rejects it, nil in demo and replay runs
+	apiKeySource client.KeySource
+	// exportPath writes the transactions to this CSV instead of uploading them to ariand
+	exportPath string
+	// clientSettings configure retries and rate limiting of ariand calls
+	clientSettings client.Settings
+	notifiers      []notify.Notifier
+	noCache        bool
+	// strict fails a statement on a line the parsers can't read instead of skipping the line
+	strict     bool
+	recordPath string // write every ariand call to this file
+	replayPath string // answer ariand calls from this recording instead of the network
+	// skipInvalid drops transactions that fail validation instead of aborting the run
+	skipInvalid bool
+	// confirm is how an attended run asks before uploading, confirmAll or confirmPerAccount
+	confirm string
+	// previewLines is how many of the first, last and largest lines of each account are shown before
+	// the upload prompt, 0 for none
+	previewLines int
+	guardrails   validate.Guardrails
+	// cardPayments is the policy for "PAYMENT - THANK YOU" lines on card statements, see policy.go
+	cardPayments        string
+	cardPaymentCategory string
+	// interestCharges adds a line in interestCategory for the interest a card statement's summary
+	// states, when no line of the statement records it
+	interestCharges  bool
+	interestCategory string
+	// interestIncomeCategory is the category of interest paid into savings, GICs and term deposits
+	interestIncomeCategory string
+	includePending         bool
+	// lines the parser scored below this need a person to look at them before upload
+	confidenceThreshold float64
+	// classifier suggests categories for lines rules left uncategorized, trained on the last
+	// classifierHistory transactions in ariand and applied when at least classifierThreshold sure
+	classifier          string
+	classifierHistory   int
+	classifierThreshold float64
+	// merchantLLM cleans up merchant names from descriptions, nil unless MERCHANT_LLM_URL is set
+	merchantLLM *enrich.Config
+	// merchantData is "bundled" or a file of merchants to add to the bundled ones, empty for none;
+	// merchantLookup is a company search URL for websites of merchants the data doesn't know
+	merchantData   string
+	merchantLookup string
+	// notes formats notes and descriptions for accounts without templates of their own
+	notes *notes.Template
+	// descriptionLength is the longest description uploaded, longer ones are cut, 0 for no limit
+	descriptionLength int
+	// accents is the accent policy of descriptions and merchants, see importer.CheckAccentPolicy
+	accents string
+	// reportFormat writes a markdown or html report of each run into reportDir, and attaches it to
+	// notifications when reportNotify is set
+	reportFormat string
+	reportDir    string
+	reportNotify bool
+	// stateDir holds the mappings, rules, state, pending records, review queue and failure report,
+	// the working directory when empty
+	stateDir string
+	// archiveDir files the statements of a run in Institution/Account/YYYY/ folders once they are
+	// imported, renamed by archiveNamer unless it is nil
+	archiveDir   string
+	archiveNamer *archive.Namer
+	// uploadConcurrency is how many batches are in flight at once, each worker owning some accounts
+	uploadConcurrency int
+	// maxMemory stops a run whose heap grows past it, in bytes, 0 for no limit
+	maxMemory uint64
+	// typeMismatch settles statement accounts mapped to an ariand account of another type, see
+	// checkMismatchPolicy
+	typeMismatch string
+	// unattended runs never prompt: uploads are auto-confirmed and unmapped accounts are skipped
+	unattended bool
+	// results gets the summary of each run as a JSON object, nil unless -json was given
+	results io.Writer
+}
+
+// runResult is what -json prints after a run: the summary, and the error that ended it early
+type runResult struct {
+	*notify.Summary
+	Error string `json:"error,omitempty"`
+}
+
+// writeResult prints the outcome of a run as one line of JSON, when -json asked for it
+func writeResult(cfg importConfig, summary *notify.Summary, runErr error) {
+	if cfg.results == nil {
+		return
+	}
+
+	result := runResult{Summary: summary}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	}
+	// Runs that stop early never get to the upload summary
+	if summary.FinishedAt.IsZero() {
+		summary.FinishedAt = time.Now()
+	}
+	if err := json.NewEncoder(cfg.results).Encode(result); err != nil {
+		log.Printf("WARN: failed to write the result: %v", err)
+	}
+}
+
+// newBackend opens the export file when one was asked for, and connects to ariand otherwise
+func newBackend(cfg importConfig) (client.Uploader, func(), error) {
+	if cfg.exportPath != "" {
+		file, err := export.NewFile(cfg.exportPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return file, func() {
+			if err := file.Close(); err != nil {
+				log.Printf("WARN: %v", err)
+			}
+		}, nil
+	}
+	return newArianClient(cfg)
+}
+
+// checkUser makes sure the user exists before anything is sent; for ariand the preflight also
+// explains what to fix when it doesn't
+func checkUser(backend client.Uploader, cfg importConfig) error {
+	if arianClient, ok := backend.(*client.Client); ok {
+		if _, err := arianClient.Preflight(cfg.userID); err != nil {
+			return preflightError(err, cfg.serverURL)
+		}
+		return nil
+	}
+	if _, err := backend.GetUser(cfg.userID); err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	return nil
+}
+
+// checkFeatures asks ariand what it serves, so calls it lacks are worked around, and warns about
+// what this import wanted but won't get. A replay has no server to ask.
+func checkFeatures(backend client.Uploader, cfg importConfig, transactions []*domain.Transaction, warnf func(string, ...any)) {
+	arianClient, ok := backend.(*client.Client)
+	if !ok || cfg.replayPath != "" {
+		return
+	}
+
+	caps, err := arianClient.Handshake()
+	if err != nil {
+		warnf("%v, assuming it supports everything", err)
+		return
+	}
+	if !caps.Known {
+		return
+	}
+
+	var categorized, pending, merchants bool
+	for _, tx := range transactions {
+		categorized = categorized || tx.Category != ""
+		pending = pending || tx.Pending
+		merchants = merchants || tx.Merchant != ""
+	}
+
+	tooOld := func(f client.Feature, consequence string) {
+		warnf("ariand is too old for %s, %s; upgrade it to get them", f.Name, consequence)
+	}
+	if !caps.Supports(client.FeatureBulkCreate) {
+		tooOld(client.FeatureBulkCreate, "transactions are sent one per call")
+	}
+	if !caps.Supports(client.FeatureCategories) && (categorized || cfg.classifier != classifierOff) {
+		tooOld(client.FeatureCategories, "transactions are uploaded uncategorized")
+	} else if !caps.Supports(client.FeatureHistory) && cfg.classifier != classifierOff {
+		tooOld(client.FeatureHistory, "categories can't be suggested from past transactions")
+	}
+	if !caps.Supports(client.FeatureUpdate) && pending {
+		tooOld(client.FeatureUpdate, "pending transactions are uploaded as new ones and not settled once they post")
+	}
+	if !caps.Supports(client.FeatureMerchant) && merchants {
+		tooOld(client.FeatureMerchant, "merchant names are dropped")
+	}
+}
+
+// supports reports whether the backend serves a feature; only ariand can lack one
+func supports(backend client.Uploader, f client.Feature) bool {
+	if arianClient, ok := backend.(*client.Client); ok {
+		return arianClient.Supports(f)
+	}
+	return true
+}
+
+// newArianClient connects to ariand, wrapping the connection in a recorder or replayer when asked
+func newArianClient(cfg importConfig) (*client.Client, func(), error) {
+	var opts []grpc.DialOption
+	var closers []func()
+
+	if cfg.recordPath != "" {
+		recorder, err := client.NewRecorder(cfg.recordPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, recorder.DialOption())
+		closers = append(closers, func() { recorder.Close() })
+	}
+
+	if cfg.replayPath != "" {
+		replayer, err := client.NewReplayer(cfg.replayPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, replayer.DialOption())
+		closers = append(closers, func() {
+			if unused := replayer.Unused(); len(unused) > 0 {
+				log.Printf("WARN: %d recorded calls were not replayed, first is %s", len(unused), unused[0].Method)
+			}
+		})
+	}
+
+	// A daemon keeps its config for days, so each run starts from the current key
+	apiKey := cfg.apiKey
+	if cfg.apiKeySource != nil {
+		if key, err := loadAPIKey(cfg.apiKeySource); err == nil {
+			apiKey = key
+		}
+	}
+
+	// Each run counts its own calls. A replay never touches the network, so it has nothing to gain
+	// from retrying a call that wasn't recorded and no proxy to get around.
+	settings := cfg.clientSettings
+	settings.Metrics = client.NewMetrics()
+	if cfg.replayPath != "" {
+		settings.Retries = 0
+		settings.Transport = client.TransportGRPC
+	}
+
+	arianClient, err := client.NewClientWithSettings(cfg.serverURL, apiKey, settings, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.apiKeySource != nil {
+		arianClient.SetKeySource(cfg.apiKeySource)
+	}
+
+	return arianClient, func() {
+		arianClient.Close()
+		for _, closer := range closers {
+			closer()
+		}
+	}, nil
+}
+
+// promptAccount asks which ariand account a statement account belongs to, creating one when asked,
+// and saves the answer as a mapping. A created account opens at the balance the statement lines
+// imply, when there are any. An account of another type is settled as typeMismatch says.
+func promptAccount(backend client.Uploader, userID, accountName string, tx *domain.Transaction, statement []*domain.Transaction, accounts *[]*pb.Account, mappingStore *mapping.Store, typeMismatch string, warnf func(string, ...any)) (*pb.Account, error) {
+	selectedAccountID, isNewAccount, err := mapping.PromptForAccountMapping(accountName, *accounts)
+	if err != nil {
+		return nil, fmt.Errorf("mapping prompt failed: %w", err)
+	}
+
+	var matchedAccount *pb.Account
+	if isNewAccount {
+		// Create new account
+		accountType := importer.AccountType(tx.StatementAccountType)
+		newAccount, err := backend.CreateAccount(userID, accountName, tx.StatementBank, accountType, tx.TxCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("create account failed: %w", err)
+		}
+		anchorAccount(backend, userID, newAccount, statement, warnf)
+		matchedAccount = newAccount
+		*accounts = append(*accounts, newAccount)
+	} else {
+		// Use selected existing account
+		selectedAccountIDInt, _ := strconv.ParseInt(selectedAccountID, 10, 64)
+		for _, account := range *accounts {
+			if account.Id == selectedAccountIDInt {
+				matchedAccount = account
+				break
+			}
+		}
+
+		if matchedAccount == nil {
+			return nil, fmt.Errorf("selected account not found")
+		}
+
+		remap, err := resolveTypeMismatch(backend, userID, accountName, matchedAccount, importer.AccountType(tx.StatementAccountType), typeMismatch, false, warnf)
+		if err != nil {
+			return nil, err
+		}
+		if remap {
+			return promptAccount(backend, userID, accountName, tx, statement, accounts, mappingStore, typeMismatch, warnf)
+		}
+		if matchedAccount.MainCurrency != "" && matchedAccount.MainCurrency != tx.TxCurrency {
+			warnf("account '%s' currency mismatch - statement is in %s but account is %s (continuing anyway)", accountName, tx.TxCurrency, matchedAccount.MainCurrency)
+		}
+	}
+
+	// Save mapping
+	if err := mappingStore.AddMapping(accountName, matchedAccount.Name); err != nil {
+		warnf("failed to save mapping: %v", err)
+	}
+
+	return matchedAccount, nil
+}
+
+// anchorAccount starts a new account at the balance it held before its first statement line, so
+// its history adds up to what the bank shows instead of counting from zero
+func anchorAccount(backend client.Uploader, userID string, account *pb.Account, statement []*domain.Transaction, warnf func(string, ...any)) {
+	setter, ok := backend.(client.BalanceSetter)
+	if !ok {
+		return
+	}
+	balance, date, ok := importer.OpeningBalance(statement)
+	if !ok {
+		return
+	}
+	if !supports(backend, client.FeatureAnchor) {
+		warnf("ariand is too old for %s, account '%s' starts at zero; upgrade it to get them", client.FeatureAnchor.Name, account.Name)
+		return
+	}
+	if err := setter.SetOpeningBalance(userID, account.Id, balance, account.MainCurrency, date); err != nil {
+		warnf("account '%s' starts at zero: %v", account.Name, err)
+	}
+}
+
+// statementLines returns the transactions of one statement account
+func statementLines(transactions []*domain.Transaction, accountName string) []*domain.Transaction {
+	var lines []*domain.Transaction
+	for _, tx := range transactions {
+		if importer.AccountKey(tx) == accountName {
+			lines = append(lines, tx)
+		}
+	}
+	return lines
+}
+
+// handleInvalid lists validation problems, then either puts the affected rows aside for review or
+// stops the run
+func handleInvalid(transactions []*domain.Transaction, problems []validate.Problem, skip bool, queue *review.Queue, userID string, warnf func(string, ...any)) ([]*domain.Transaction, error) {
+	invalid := validate.Invalid(problems)
+	fmt.Printf("\nvalidation found %d problems in %d transactions:\n", len(problems), invalid)
+	for _, problem := range problems {
+		fmt.Printf("  %s\n", problem)
+	}
+
+	if !skip {
+		return nil, fmt.Errorf("%d invalid transactions, fix them or rerun with -skip-invalid to put them aside for review", invalid)
+	}
+
+	details := make(map[*domain.Transaction][]string)
+	var order []*domain.Transaction
+	for _, problem := range problems {
+		if _, ok := details[problem.Tx]; !ok {
+			order = append(order, problem.Tx)
+		}
+		details[problem.Tx] = append(details[problem.Tx], problem.Rule+": "+problem.Message)
+	}
+	for _, tx := range order {
+		queue.Add(userID, tx, review.ReasonInvalid, details[tx]...)
+	}
+
+	warnf("put %d invalid transactions aside for review, resolve them with: upload -review", invalid)
+	return validate.Exclude(transactions, problems), nil
+}
+
+// failureLines summarizes a failure report by status code and account
+func failureLines(report *failures.Report, accounts []*pb.Account) []string {
+	names := make(map[int]string, len(accounts))
+	for _, account := range accounts {
+		names[int(account.Id)] = account.Name
+	}
+
+	var lines []string
+	for _, group := range report.Breakdown() {
+		lines = append(lines, group.Format(names))
+	}
+	return lines
+}
+
+// newParseCache opens the parse result cache, honouring PARSE_CACHE_DIR
+func newParseCache() (*parser.Cache, error) {
+	dir := os.Getenv("PARSE_CACHE_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = parser.DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	return parser.NewCache(dir)
+}
+
+// rememberStatements records the PDFs of a run as uploaded in the parse cache, so the next version of
+// one with other bytes is diffed against what went to ariand
+func rememberStatements(parsed *parser.ParseResult, noCache bool, warnf func(string, ...any)) {
+	if noCache {
+		return
+	}
+	cache, err := newParseCache()
+	if err == nil {
+		err = cache.Remember(parsed)
+	}
+	if err != nil {
+		warnf("failed to record parsed statements: %v", err)
+	}
+}
+
+// parseStatements runs every parser over path: PDFs through the cached Python parser, text files
+// through the templates in TEMPLATE_DIR and CSV exports
+func parseStatements(path, configPath, institution string, noCache, strict bool, warnf func(string, ...any)) (*parser.ParseResult, []*domain.Transaction, error) {
+	pythonParser, templates, err := newParsers(institution, noCache, strict, warnf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, transactions, err := parser.ParseAll(pythonParser, templates, path, configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse failed: %w", err)
+	}
+	return result, transactions, nil
+}
+
+// parseEachStatement runs the same parsers as parseStatements one statement file at a time. A
+// non-nil failed gets the files the parsers fail on, as in parser.ParseEach.
+func parseEachStatement(path, configPath, institution string, noCache, strict bool, warnf func(string, ...any), fn func(*parser.ParseResult, []*domain.Transaction) error, failed func(string, error) error) error {
+	pythonParser, templates, err := newParsers(institution, noCache, strict, warnf)
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	var onFailure func(string, error) error
+	if failed != nil {
+		onFailure = func(file string, err error) error {
+			fnErr = failed(file, err)
+			return fnErr
+		}
+	}
+	err = parser.ParseEach(pythonParser, templates, path, configPath, func(result *parser.ParseResult, transactions []*domain.Transaction) error {
+		fnErr = fn(result, transactions)
+		return fnErr
+	}, onFailure)
+	// Errors from fn are the caller's own, only the parsers' need saying where they came from
+	if err != nil && fnErr == nil {
+		return fmt.Errorf("parse failed: %w", err)
+	}
+	return err
+}
+
+// newParsers sets up the Python parser with the parse cache and the text statement templates, which
+// also read the PDFs of banks other than RBC. A non-empty institution reads every PDF as its statement.
+// Strict parsers fail a statement on a line they can't read rather than skip it.
+func newParsers(institution string, noCache, strict bool, warnf func(string, ...any)) (*parser.PythonParser, *parser.TemplateParser, error) {
+	pythonParser := parser.NewPythonParser()
+	if strict {
+		pythonParser.WithStrict()
+	}
+	if !noCache {
+		cache, err := newParseCache()
+		if err != nil {
+			warnf("parse cache disabled: %v", err)
+		} else {
+			pythonParser.WithCache(cache)
+		}
+	}
+
+	templateDir := os.Getenv("TEMPLATE_DIR")
+	if templateDir == "" {
+		templateDir = "templates"
+	}
+	templates, err := parser.LoadTemplates(templateDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load templates: %w", err)
+	}
+	pythonParser.WithTemplates(templates)
+
+	if institution != "" {
+		if err := parser.CheckInstitution(institution, templates); err != nil {
+			return nil, nil, err
+		}
+		pythonParser.WithInstitution(institution)
+	}
+	return pythonParser, templates, nil
+}
+
+// memoryHint says how to get past MAX_MEMORY when a run stopped on it
+func memoryHint(err error) error {
+	if errors.Is(err, pipeline.ErrMemory) {
+		return fmt.Errorf("%w; raise MAX_MEMORY or import fewer statements at once", err)
+	}
+	return err
+}
+
+// reportParse prints what each statement file gave and records it in the run summary
+func reportParse(summary *notify.Summary, parseResult *parser.ParseResult, transactions int, warnf func(string, ...any)) {
+	fmt.Printf("files: %d/%d, transactions: %d\n",
+		parseResult.Summary.ProcessedFiles,
+		parseResult.Summary.TotalFiles,
+		parseResult.Summary.TotalTransactions)
+
+	summary.TotalFiles = parseResult.Summary.TotalFiles
+	summary.ProcessedFiles = parseResult.Summary.ProcessedFiles
+	summary.Transactions = transactions
+
+	// A folder mixing PDFs, exports and downloads says what each kind gave
+	formats := parseResult.Formats()
+	for _, format := range formats {
+		summary.Formats = append(summary.Formats, notify.FormatSummary(format))
+		if len(formats) > 1 {
+			fmt.Printf("  %s: %d/%d files, %d transactions\n", format.Format, format.ProcessedFiles, format.TotalFiles, format.Transactions)
+		}
+	}
+
+	regenerated := make(map[string]bool)
+	for _, diff := range parseResult.Diffs {
+		regenerated[diff.File] = true
+	}
+
+	for _, fileResult := range parseResult.FileResults {
+		summary.Files = append(summary.Files, notify.FileSummary{
+			File:         fileResult.File,
+			Format:       fileResult.Format,
+			Transactions: fileResult.TransactionCount,
+			Processed:    fileResult.Processed,
+			SkippedLines: len(fileResult.SkippedLines),
+			SummaryLines: fileResult.SummaryLines,
+			Statement:    fileResult.Statement,
+		})
+
+		fileName := filepath.Base(fileResult.File)
+		// Lines the parsers couldn't read were left out, -strict fails the statement instead
+		for _, line := range fileResult.SkippedLines {
+			warnf("skipped %s %s: %s", fileName, line.Where(), line.Reason)
+		}
+		if fileResult.Processed {
+			fmt.Printf("  %s: %d%s%s%s\n", fileName, fileResult.TransactionCount, describeSkipped(len(fileResult.SkippedLines)), describeSummaryLines(fileResult.SummaryLines), describeStatement(fileResult.Statement))
+		} else if institution := fileResult.Institution; institution != "" && institution != "rbc" && !regenerated[fileResult.File] {
+			warnf("no transactions extracted from %s, a %s statement no template reads; add one to TEMPLATE_DIR", fileName, institution)
+		} else if !regenerated[fileResult.File] {
+			warnf("no transactions extracted from %s", fileName)
+		}
+	}
+
+	// A statement downloaded twice under different names is read once
+	for _, duplicate := range parseResult.Duplicates {
+		copied := fmt.Sprintf("%s is a copy of %s", filepath.Base(duplicate.File), filepath.Base(duplicate.Original))
+		fmt.Printf("  %s, skipped\n", copied)
+		summary.DuplicateFiles = append(summary.DuplicateFiles, copied)
+	}
+
+	// Regenerated statements only contribute lines that differ from the version parsed before
+	for _, diff := range parseResult.Diffs {
+		fileName := filepath.Base(diff.File)
+		fmt.Printf("  %s was regenerated: %d unchanged, %d new or changed, %d removed\n",
+			fileName, diff.Unchanged, diff.Added, len(diff.Removed))
+
+		for _, tx := range diff.Removed {
+			warnf("%s: line missing from regenerated statement: %s %.2f %s", fileName, tx.Date, tx.Amount, tx.Description)
+		}
+	}
+}
+
+// describeSkipped says how many lines of a statement were left out, empty when none were
+func describeSkipped(skipped int) string {
+	switch skipped {
+	case 0:
+		return ""
+	case 1:
+		return ", 1 line skipped"
+	}
+	return fmt.Sprintf(", %d lines skipped", skipped)
+}
+
+// describeSummaryLines says how many balance and total lines were dropped from a statement, empty
+// when none were
+func describeSummaryLines(dropped int) string {
+	switch dropped {
+	case 0:
+		return ""
+	case 1:
+		return ", 1 balance line dropped"
+	}
+	return fmt.Sprintf(", %d balance lines dropped", dropped)
+}
+
+// describeStatement says what a card statement's summary stated, for the per-file parse report
+func describeStatement(statement *domain.Statement) string {
+	if statement == nil {
+		return ""
+	}
+	var parts []string
+	for _, field := range []struct {
+		name   string
+		amount *float64
+	}{
+		{"credit limit", statement.CreditLimit},
+		{"interest", statement.InterestCharged},
+		{"minimum payment", statement.MinimumPayment},
+	} {
+		if field.amount != nil {
+			parts = append(parts, fmt.Sprintf("%s %.2f", field.name, *field.amount))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// printAccountSplit breaks down each file that covers more than one statement account, like a
+// combined chequing and savings statement, by account, so a split that went wrong shows before the
+// upload. label names a statement account.
+func printAccountSplit(transactions []*domain.Transaction, label func(*domain.Transaction) string) {
+	byFile := make(map[string][]*domain.Transaction)
+	for _, tx := range transactions {
+		byFile[tx.SourceFilePath] = append(byFile[tx.SourceFilePath], tx)
+	}
+	for _, file := range slices.Sorted(maps.Keys(byFile)) {
+		split := report.Build("", time.Time{}, report.Status{}, byFile[file], label)
+		names := make(map[string]bool)
+		for _, account := range split.Accounts {
+			names[account.Name] = true
+		}
+		if len(names) < 2 {
+			continue
+		}
+		fmt.Printf("\n%s covers %d accounts:\n", filepath.Base(file), len(names))
+		for _, account := range split.Accounts {
+			fmt.Printf("  %s: %d transactions, in %.2f, out %.2f %s, %s to %s\n", account.Name, account.Count, account.In, account.Out,
+				account.Currency, account.First.Format("2006-01-02"), account.Last.Format("2006-01-02"))
+		}
+	}
+}
+
+// runImport parses statements, resolves accounts and uploads transactions once, until ctx ends
+func runImport(ctx context.Context, cfg importConfig) (*notify.Summary, error) {
+	summary := &notify.Summary{
+		RunID:     notify.NewRunID(),
+		StartedAt: time.Now(),
+	}
+
+	// warnf logs a warning and keeps it for the run summary, from any stage
+	var warnMu sync.Mutex
+	warnf := func(format string, args ...any) {
+		warnMu.Lock()
+		defer warnMu.Unlock()
+		msg := fmt.Sprintf(format, args...)
+		log.Printf("WARN: %s", msg)
+		summary.Warnings = append(summary.Warnings, msg)
+	}
+
+	// Every prompt reads through one reader, so answers piped in together aren't lost to the buffer
+	// of an earlier prompt
+	stdin := bufio.NewReader(os.Stdin)
+
+	pdfPath := cfg.pdfPath
+	sourceKind := cfg.sourceKind
+
+	// Scheduled scans of a local folder must not re-import the same files every time
+	if cfg.unattended && sourceKind == "" {
+		sourceKind = "local"
+	}
+
+	// Pull new statements from a remote source into a scratch directory
+	var (
+		remote     source.Source
+		stateStore *state.Store
+		fetched    []source.Fetched
+		// quarantined sets aside the files an unattended run fails on, nil for other runs
+		quarantined *quarantiner
+	)
+	if sourceKind != "" {
+		var err error
+		if sourceKind == "local" {
+			remote, err = source.NewLocal(pdfPath)
+		} else {
+			remote, err = newSource(sourceKind)
+		}
+		if err != nil {
+			return summary, fmt.Errorf("source config invalid: %w", err)
+		}
+
+		stateStore, err = state.NewStoreIn(cfg.stateDir)
+		if err != nil {
+			return summary, fmt.Errorf("failed to initialize state store: %w", err)
+		}
+
+		// Files a previous version failed on go back to the source before the sync, which then
+		// fetches them again
+		if cfg.unattended {
+			quarantined = newQuarantiner(remote, stateStore, warnf)
+			if err := quarantined.release(); err != nil {
+				warnf("failed to release quarantined statements: %v", err)
+			}
+		}
+
+		downloadDir, err := os.MkdirTemp("", "arian-statements-")
+		if err != nil {
+			return summary, fmt.Errorf("failed to create download dir: %w", err)
+		}
+		defer os.RemoveAll(downloadDir)
+
+		fmt.Printf("syncing %s\n", remote.Name())
+		fetched, err = source.Sync(ctx, remote, stateStore, downloadDir)
+		if err != nil {
+			return summary, fmt.Errorf("sync failed: %w", err)
+		}
+
+		if len(fetched) == 0 {
+			fmt.Println("no new statements")
+			return summary, nil
+		}
+
+		fmt.Printf("downloaded %d new statements\n", len(fetched))
+		pdfPath = downloadDir
+		if quarantined != nil {
+			quarantined.track(fetched)
+		}
+	}
+
+	ruleSet, err := rules.NewSetIn(cfg.stateDir)
+	if err != nil {
+		return summary, fmt.Errorf("failed to load rules: %w", err)
+	}
+	mappingStore, err := mapping.NewStoreIn(cfg.stateDir)
+	if err != nil {
+		return summary, fmt.Errorf("failed to initialize mapping store: %w", err)
+	}
+
+	var parseFailed func(string, error) error
+	if quarantined != nil {
+		parseFailed = func(file string, err error) error {
+			return quarantined.add(file, quarantine.StageParse, err)
+		}
+	}
+
+	// Parsing and enrichment run as stages, so an interrupt stops whichever one is busy
+	var duplicates []dedupe.Duplicate
+	collapser := dedupe.NewCollapser()
+	statements := make(map[string]*domain.Statement)
+	statementPeriods := make(map[string]validate.Period)
+	parsed := &parser.ParseResult{}
+	archived := archive.NewCollector()
+	enrichment := pipeline.New(
+		// Statements are read one file at a time and passed on right away, so the parser's output for
+		// decades of statements is never held at once
+		pipeline.Source("parse", func(ctx context.Context, emit func(*domain.Transaction) error) error {
+			fmt.Printf("parsing %s\n", pdfPath)
+			count := 0
+			err := parseEachStatement(pdfPath, cfg.configPath, cfg.institution, cfg.noCache, cfg.strict, warnf, func(result *parser.ParseResult, transactions []*domain.Transaction) error {
+				result.Transactions = nil
+				parser.Merge(parsed, result)
+				for _, file := range result.FileResults {
+					if file.Statement != nil {
+						statements[file.File] = file.Statement
+					}
+					if file.SinglePeriod() {
+						statementPeriods[file.File] = validate.StatementPeriod(file.Statement)
+					}
+				}
+				count += len(transactions)
+				archived.Add(transactions)
+				for _, tx := range transactions {
+					if err := emit(tx); err != nil {
+						return err
+					}
+				}
+				return ctx.Err()
+			}, parseFailed)
+			if err != nil {
+				return err
+			}
+			reportParse(summary, parsed, count, warnf)
+			return nil
+		}),
+		// Signs and direction rules come first, the card payment policy and everything after it go
+		// by direction
+		pipeline.Batch("signs", func(_ context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			invert := func(tx *domain.Transaction) bool { return mappingStore.InvertsSigns(importer.AccountKey(tx)) }
+			if count := importer.InvertSigns(transactions, invert); count > 0 {
+				fmt.Printf("inverting the sign of %d transactions, as account-settings.json says\n", count)
+			}
+			if count := importer.ApplyDirectionRules(transactions, ruleSet); count > 0 {
+				fmt.Printf("turning %d transactions around, as rules in arian-rules.json say\n", count)
+			}
+			return transactions, nil
+		}),
+		// Accounts can be set to upload the posting date, before anything compares dates
+		pipeline.Map("dates", func(_ context.Context, tx *domain.Transaction) (*domain.Transaction, error) {
+			mappingStore.AdjustDate(importer.AccountKey(tx), tx)
+			return tx, nil
+		}),
+		pipeline.Batch("pending", func(_ context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			if cfg.includePending {
+				return transactions, nil
+			}
+			transactions, dropped := importer.DropPending(transactions)
+			if dropped > 0 {
+				fmt.Printf("skipping %d pending transactions, pass -include-pending to import them\n", dropped)
+			}
+			return transactions, nil
+		}),
+		pipeline.Batch("card payments", func(_ context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			transactions, dropped := importer.ApplyCardPaymentPolicy(transactions, cfg.cardPayments, cfg.cardPaymentCategory)
+			if dropped > 0 {
+				fmt.Printf("skipping %d card payments\n", dropped)
+			}
+			return transactions, nil
+		}),
+		pipeline.Batch("interest", func(_ context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			importer.CategorizeInterest(transactions, cfg.interestIncomeCategory)
+			if !cfg.interestCharges {
+				return transactions, nil
+			}
+			transactions, added := importer.AddInterestCharges(transactions, statements, cfg.interestCategory)
+			if added > 0 {
+				fmt.Printf("adding %d interest charges from statement summaries\n", added)
+			}
+			return transactions, nil
+		}),
+		pipeline.Batch("loan payments", func(_ context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			split := func(tx *domain.Transaction) bool { return mappingStore.SplitsLoanPayments(importer.AccountKey(tx)) }
+			transactions, count := importer.SplitLoanPayments(transactions, split, cfg.interestCategory)
+			if count > 0 {
+				fmt.Printf("splitting %d loan payments into principal and interest\n", count)
+			}
+			return transactions, nil
+		}),
+		pipeline.Batch("rules", func(_ context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			ruleSet.Apply(transactions)
+			return transactions, nil
+		}),
+		// Merchant names come before templates, so descriptions can be rewritten with them
+		pipeline.Batch("merchants", func(ctx context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			if cfg.merchantLLM != nil {
+				cleanMerchants(ctx, *cfg.merchantLLM, cfg.stateDir, transactions, warnf)
+			}
+			if cfg.merchantData != "" {
+				if err := describeMerchants(ctx, cfg, transactions, warnf); err != nil {
+					return nil, err
+				}
+			}
+			return transactions, nil
+		}),
+		// Account defaults and templates fill in after rules, and before validation sees the currency
+		pipeline.Map("account defaults", func(_ context.Context, tx *domain.Transaction) (*domain.Transaction, error) {
+			return tx, mappingStore.Apply(importer.AccountKey(tx), tx, cfg.notes, time.Now())
+		}),
+		// Descriptions are cleaned once rules and templates have had them, and before duplicates
+		// compare them. Blank ones are named after the method, kind or category.
+		pipeline.Map("descriptions", func(_ context.Context, tx *domain.Transaction) (*domain.Transaction, error) {
+			if cfg.accents == importer.AccentsASCII {
+				tx.FoldAccents()
+			}
+			tx.CleanDescription(cfg.descriptionLength)
+			tx.FillDescription()
+			return tx, nil
+		}),
+		// Excludes see lines as they would be uploaded, categorized and with their descriptions
+		pipeline.Batch("excludes", func(_ context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			transactions, excluded := ruleSet.Exclude(transactions)
+			for name, count := range excluded {
+				if summary.Excluded == nil {
+					summary.Excluded = make(map[string]int)
+				}
+				summary.Excluded[name] += count
+			}
+			return transactions, nil
+		}),
+		pipeline.Batch("overlaps", func(_ context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+			return resolveOverlaps(transactions, cfg.unattended, warnf)
+		}),
+		// Overlapping statements repeat the same lines, only the first copy goes to ariand
+		pipeline.Map("duplicates", func(_ context.Context, tx *domain.Transaction) (*domain.Transaction, error) {
+			if kept, duplicate := collapser.Add(tx); !kept {
+				duplicates = append(duplicates, duplicate)
+				return nil, nil
+			}
+			return tx, nil
+		}),
+	)
+	enrichment.MaxMemory = cfg.maxMemory
+	transactions, err := enrichment.Run(ctx, nil)
+	summary.Stages = append(summary.Stages, enrichment.Metrics()...)
+	if err != nil {
+		return summary, memoryHint(err)
+	}
+
+	if len(summary.Excluded) > 0 {
+		fmt.Println("\nleft out, as excludes in arian-rules.json say:")
+		for _, name := range slices.Sorted(maps.Keys(summary.Excluded)) {
+			fmt.Printf("  %d by %s\n", summary.Excluded[name], name)
+		}
+	}
+
+	if len(duplicates) > 0 {
+		fmt.Printf("\ndropped %d duplicates found in more than one statement:\n", len(duplicates))
+		for _, duplicate := range duplicates {
+			fmt.Printf("  %s\n", duplicate)
+			summary.Duplicates = append(summary.Duplicates, duplicate.String())
+		}
+	}
+
+	// A missing month is easy to spot now and hard to spot in next year's reports
+	if stateStore == nil {
+		if stateStore, err = state.NewStoreIn(cfg.stateDir); err != nil {
+			return summary, fmt.Errorf("failed to initialize state store: %w", err)
+		}
+	}
+	periods := state.PeriodsOf(transactions, importer.AccountKey)
+	for _, gap := range stateStore.Gaps(periods) {
+		warnf("%s, is a statement missing?", gap)
+	}
+
+	// Lines that need a person wait in the review queue instead of failing the run, and are
+	// uploaded later with upload -review
+	queue, err := review.Load(filepath.Join(cfg.stateDir, review.DefaultPath))
+	if err != nil {
+		return summary, err
+	}
+	queueSaved := true
+	saveQueue := func() {
+		if err := queue.Save(); err != nil {
+			warnf("%v", err)
+			queueSaved = false
+		}
+	}
+
+	// Report every problem before anything is uploaded, rather than failing batch by batch. Nobody
+	// can fix them during an unattended run, so they always wait for review then, or the files they
+	// came from are quarantined.
+	problems := validate.Check(transactions, time.Now(), statementPeriods)
+	if quarantined != nil && len(problems) > 0 {
+		if transactions, problems, err = quarantined.invalid(transactions, problems); err != nil {
+			return summary, err
+		}
+	}
+	if len(problems) > 0 {
+		transactions, err = handleInvalid(transactions, problems, cfg.skipInvalid || cfg.unattended, queue, cfg.userID, warnf)
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	// Implausible data needs a deliberate yes, and can't be waved through by an unattended run
+	if warnings := cfg.guardrails.Check(transactions, statementPeriods); len(warnings) > 0 {
+		fmt.Println("\nthis looks like the PDF extraction went wrong:")
+		for _, warning := range warnings {
+			fmt.Printf("  %s\n", warning)
+			summary.Warnings = append(summary.Warnings, warning)
+		}
+
+		if cfg.unattended {
+			return summary, fmt.Errorf("%d guardrail checks failed, run interactively to confirm", len(warnings))
+		}
+
+		fmt.Print("type 'yes' to upload anyway: ")
+		response, _ := stdin.ReadString('\n')
+		if strings.TrimSpace(response) != "yes" {
+			fmt.Println("cancelled")
+			return summary, nil
+		}
+	}
+
+	reviewed, later, err := reviewLowConfidence(transactions, cfg.confidenceThreshold, cfg.unattended, ruleSet, warnf)
+	if err != nil {
+		return summary, err
+	}
+	for _, tx := range later {
+		queue.Add(cfg.userID, tx, review.ReasonConfidence, tx.ConfidenceReasons...)
+	}
+	transactions = reviewed
+
+	accountLabel := func(tx *domain.Transaction) string {
+		key := importer.AccountKey(tx)
+		if name := mappingStore.Mappings[key]; name != "" {
+			return key + " -> " + name
+		}
+		return key
+	}
+	// Lines of declined accounts wait in the review queue, to be fixed, uploaded or dropped one by one
+	if !cfg.unattended && cfg.confirm == confirmPerAccount && len(transactions) > 0 {
+		approved, declined, err := confirmAccounts(transactions, accountLabel, cfg.previewLines, stdin)
+		if err != nil {
+			return summary, err
+		}
+		for _, tx := range declined {
+			queue.Add(cfg.userID, tx, review.ReasonDeclined, "declined at the upload prompt of "+accountLabel(tx))
+		}
+		if len(declined) > 0 {
+			warnf("%d declined transactions put aside for review", len(declined))
+		}
+		transactions = approved
+	}
+	saveQueue()
+
+	if len(transactions) == 0 {
+		if queueSaved {
+			rememberStatements(parsed, cfg.noCache, warnf)
+		}
+		if remote != nil && queueSaved {
+			if err := source.MarkProcessed(stateStore, remote, fetched); err != nil {
+				warnf("failed to record processed statements: %v", err)
+			}
+		}
+		if cfg.archiveDir != "" && queueSaved {
+			archiveStatements(cfg.archiveDir, cfg.archiveNamer, archived, statements, remote, fetched, warnf)
+		}
+		return summary, nil
+	}
+
+	if !cfg.unattended && cfg.confirm != confirmPerAccount {
+		printPreview(transactions, accountLabel, cfg.previewLines)
+		printAccountSplit(transactions, accountLabel)
+		fmt.Printf("\nupload %d transactions? (y/N): ", len(transactions))
+		response, err := stdin.ReadString('\n')
+		if err != nil {
+			return summary, fmt.Errorf("read failed: %w", err)
+		}
+
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			return summary, nil
+		}
+	}
+
+	backend, closeBackend, err := newBackend(cfg)
+	if err != nil {
+		return summary, fmt.Errorf("client failed: %w", err)
+	}
+	defer closeBackend()
+
+	if err := checkUser(backend, cfg); err != nil {
+		return summary, err
+	}
+	checkFeatures(backend, cfg, transactions, warnf)
+
+	// What ariand already holds is only known once it is connected, so this comes after the prompt
+	if !checkHistory(backend, cfg, transactions, summary, stdin, warnf) {
+		return summary, nil
+	}
+
+	accounts, err := backend.GetAccounts(cfg.userID)
+	if err != nil {
+		return summary, fmt.Errorf("get accounts failed: %w", err)
+	}
+
+	if lister, ok := backend.(client.CategoryLister); ok && supports(backend, client.FeatureCategories) {
+		if history, ok := backend.(client.TransactionLister); ok && cfg.classifier != classifierOff && supports(backend, client.FeatureHistory) {
+			suggestCategories(history, lister, cfg, transactions, warnf)
+		}
+		resolveCategories(lister, cfg.userID, transactions, warnf)
+	}
+	_, createsAccounts := backend.(client.AccountCreator)
+
+	accountMatchStats := make(map[string]int)
+	askedMappings := make(map[string]bool) // Track which accounts we've already asked about
+	skippedAccounts := make(map[string]bool)
+
+	// First pass: resolve all account mappings
+	for _, tx := range transactions {
+		accountName := importer.AccountKey(tx)
+
+		mappingKey := accountName + "|" + tx.StatementAccountType
+		if askedMappings[mappingKey] {
+			continue // Already resolved this account
+		}
+		askedMappings[mappingKey] = true
+
+		var matchedAccount *pb.Account
+
+		// First, check if we have a saved mapping for this statement account
+		arianAccountName := mappingStore.FindMapping(accountName)
+
+		// Backends whose accounts are only labels get one per statement account, no questions
+		// asked, named the way a saved mapping would resolve it
+		if createsAccounts {
+			name := cmp.Or(arianAccountName, accountName)
+			if mappingStore.ResolveAccount(name, accounts) == nil {
+				newAccount, err := backend.CreateAccount(cfg.userID, name, tx.StatementBank, importer.AccountType(tx.StatementAccountType), tx.TxCurrency)
+				if err != nil {
+					return summary, fmt.Errorf("create account failed: %w", err)
+				}
+				anchorAccount(backend, cfg.userID, newAccount, statementLines(transactions, accountName), warnf)
+				accounts = append(accounts, newAccount)
+			}
+			continue
+		}
+
+		remap := false
+		if arianAccountName != "" {
+			// Use the saved mapping - resolve by account name
+			matchedAccount = mappingStore.ResolveAccount(arianAccountName, accounts)
+			if matchedAccount == nil {
+				warnf("saved mapping for '%s' points to non-existent account '%s', will re-prompt", accountName, arianAccountName)
+			} else if remap, err = resolveTypeMismatch(backend, cfg.userID, accountName, matchedAccount, importer.AccountType(tx.StatementAccountType), cfg.typeMismatch, cfg.unattended, warnf); err != nil {
+				return summary, err
+			} else if remap {
+				matchedAccount = nil
+			}
+		}
+
+		// If no saved mapping or account not found, try to match by name and type. A mapping being
+		// redone is the user's to pick, as the saved one would still win the second pass.
+		if matchedAccount == nil && !remap {
+			matchedAccount = importer.MatchAccount(accounts, accountName, tx.StatementAccountType)
+		}
+
+		// Nobody is around to answer a prompt, so this account's lines wait for review
+		if matchedAccount == nil && cfg.unattended {
+			warnf("no mapping for account '%s', put its transactions aside for review, resolve them with: upload -review", accountName)
+			skippedAccounts[accountName] = true
+			continue
+		}
+
+		// If still no match, prompt the user
+		if matchedAccount == nil {
+			if _, err := promptAccount(backend, cfg.userID, accountName, tx, statementLines(transactions, accountName), &accounts, mappingStore, cfg.typeMismatch, warnf); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	// Second pass: assign account IDs to all transactions
+	uploads := make([]*domain.Transaction, 0, len(transactions))
+	usedMappings := make(map[string]bool)
+	for _, tx := range transactions {
+		accountName := importer.AccountKey(tx)
+		if skippedAccounts[accountName] {
+			queue.Add(cfg.userID, tx, review.ReasonAccount, fmt.Sprintf("no single ariand account matches statement account %s", accountName))
+			continue
+		}
+
+		arianAccountName := mappingStore.FindMapping(accountName)
+		if arianAccountName == "" {
+			// Try to match by name and type
+			matchedAccount := importer.MatchAccount(accounts, accountName, tx.StatementAccountType)
+			if matchedAccount != nil {
+				tx.AccountID = int(matchedAccount.Id)
+				accountMatchStats[accountName]++
+			}
+		} else {
+			// Resolve account by name
+			matchedAccount := mappingStore.ResolveAccount(arianAccountName, accounts)
+			if matchedAccount != nil {
+				tx.AccountID = int(matchedAccount.Id)
+				accountMatchStats[accountName]++
+				usedMappings[accountName] = true
+			}
+		}
+
+		uploads = append(uploads, tx)
+	}
+	// map prune goes by when each mapping was last used
+	if err := mappingStore.MarkUsed(slices.Sorted(maps.Keys(usedMappings)), time.Now()); err != nil {
+		warnf("%v", err)
+	}
+
+	if problems := validate.CheckAccounts(uploads); len(problems) > 0 {
+		uploads, err = handleInvalid(uploads, problems, cfg.skipInvalid || cfg.unattended, queue, cfg.userID, warnf)
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	// A line waiting for review that made it through this time must not be uploaded twice
+	for _, tx := range uploads {
+		queue.Remove(tx)
+	}
+	saveQueue()
+	if len(queue.Entries) > 0 {
+		fmt.Printf("%d transactions wait for review in %s, resolve them with: upload -review\n", len(queue.Entries), queue.Path())
+	}
+
+	// Pending lines, and posted lines that settle them, go through their own path
+	sent := uploads
+	reconciled := 0
+	failed := failures.NewReport(summary.RunID, cfg.userID)
+	if updater, ok := backend.(client.PendingUpdater); ok && supports(backend, client.FeatureUpdate) {
+		uploads, reconciled, err = reconcilePending(updater, cfg.stateDir, cfg.userID, uploads, failed, warnf)
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	// Bulk upload transactions in batches
+	const batchSize = 1000
+	totalCreated := int32(reconciled)
+	totalErrors := len(failed.Entries)
+
+	// ariand works out running balances in the order transactions arrive, so each account's go up
+	// oldest first, and concurrent workers never share an account
+	slices.SortStableFunc(uploads, func(a, b *domain.Transaction) int { return a.TxDate.Compare(b.TxDate) })
+	byAccount := func(tx *domain.Transaction) int64 { return int64(tx.AccountID) }
+
+	var uploadMu sync.Mutex
+	sentCount := 0
+	upload := pipeline.New(pipeline.Shards("upload", cfg.uploadConcurrency, batchSize, byAccount, func(_ context.Context, batch []*domain.Transaction) ([]*domain.Transaction, error) {
+		var created int32
+		var ids []int64
+		var errors []error
+		if creator, ok := backend.(client.IDCreator); ok {
+			ids, errors = creator.CreateTransactionsWithIDs(cfg.userID, batch)
+			created = int32(len(ids))
+		} else {
+			created, errors = backend.CreateTransactionsBulk(cfg.userID, batch)
+		}
+
+		// Workers share the run totals
+		uploadMu.Lock()
+		defer uploadMu.Unlock()
+		summary.CreatedIDs = append(summary.CreatedIDs, ids...)
+		totalCreated += created
+		totalErrors += len(errors)
+
+		if len(errors) > 0 {
+			for _, err := range errors {
+				log.Printf("ERROR: %v", err)
+			}
+			failed.Add(batch, errors[0])
+		}
+
+		sentCount += len(batch)
+		fmt.Printf("%d/%d\n", sentCount, len(uploads))
+		return batch, nil
+	}))
+	upload.MaxMemory = cfg.maxMemory
+	_, err = upload.Run(ctx, uploads)
+	summary.Stages = append(summary.Stages, upload.Metrics()...)
+	if err != nil {
+		// Batches already sent stay in ariand, the rest can be imported again
+		summary.Created = int(totalCreated)
+		return summary, memoryHint(err)
+	}
+
+	fmt.Printf("\n%d ok, %d failed\n", totalCreated, len(failed.Entries))
+	// Attempts a retry recovered from show up nowhere else, yet hint at a struggling server
+	if arianClient, ok := backend.(*client.Client); ok {
+		for _, method := range arianClient.Metrics().Snapshot() {
+			if method.Failures > 0 {
+				fmt.Printf("  ariand %s: %d of %d attempts failed\n", path.Base(method.Method), method.Failures, method.Calls)
+			}
+		}
+	}
+	if cfg.exportPath != "" {
+		fmt.Printf("written to %s\n", cfg.exportPath)
+	}
+	for account, count := range accountMatchStats {
+		fmt.Printf("  %s: %d\n", account, count)
+	}
+
+	if len(failed.Entries) > 0 {
+		fmt.Println("\nfailures:")
+		for _, line := range failureLines(failed, accounts) {
+			fmt.Printf("  %s\n", line)
+		}
+
+		failedPath := filepath.Join(cfg.stateDir, failures.DefaultPath)
+		if err := failed.Save(failedPath); err != nil {
+			warnf("%v", err)
+		} else {
+			fmt.Printf("%d failed transactions written to %s, retry with: upload -retry-file %s\n", len(failed.Entries), failedPath, failedPath)
+		}
+	}
+
+	// Periods count as covered once their lines are in ariand, so a failed run is checked again
+	if totalErrors == 0 {
+		periods := state.PeriodsOf(sent, importer.AccountKey)
+		for _, accountPeriods := range periods {
+			for i := range accountPeriods {
+				accountPeriods[i].Statement = statements[accountPeriods[i].File]
+			}
+		}
+		stateStore.AddPeriods(periods)
+		if err := stateStore.Save(); err != nil {
+			warnf("failed to record statement periods: %v", err)
+		}
+	}
+
+	// Only remember remote files once everything from them made it to ariand or the review queue
+	if totalErrors == 0 && queueSaved {
+		rememberStatements(parsed, cfg.noCache, warnf)
+	}
+	if remote != nil && totalErrors == 0 && queueSaved {
+		if err := source.MarkProcessed(stateStore, remote, fetched); err != nil {
+			warnf("failed to record processed statements: %v", err)
+		}
+	}
+	// and only file them away then, so a failed run can be imported again from where it was
+	if cfg.archiveDir != "" && totalErrors == 0 && queueSaved {
+		archiveStatements(cfg.archiveDir, cfg.archiveNamer, archived, statements, remote, fetched, warnf)
+	}
+
+	summary.Created = int(totalCreated)
+	summary.Failed = len(failed.Entries)
+	summary.Errors = append(summary.Errors, failureLines(failed, accounts)...)
+	summary.FinishedAt = time.Now()
+
+	if cfg.reportFormat != "" {
+		status := report.Status{
+			Files:      summary.TotalFiles,
+			Processed:  summary.ProcessedFiles,
+			Parsed:     summary.Transactions,
+			Duplicates: len(duplicates),
+			Created:    summary.Created,
+			Pending:    reconciled,
+			Failed:     summary.Failed,
+			Review:     len(queue.Entries),
+		}
+		names := make(map[int]string, len(accounts))
+		for _, account := range accounts {
+			names[int(account.Id)] = account.Name
+		}
+		importReport := report.Build(summary.RunID, summary.StartedAt, status, sent, func(tx *domain.Transaction) string {
+			return cmp.Or(names[tx.AccountID], importer.AccountKey(tx))
+		})
+
+		if path, err := importReport.Save(cfg.reportDir, cfg.reportFormat); err != nil {
+			warnf("%v", err)
+		} else {
+			fmt.Printf("report written to %s\n", path)
+		}
+		if cfg.reportNotify {
+			if summary.Report, err = importReport.Render(cfg.reportFormat); err == nil {
+				summary.ReportFormat = cfg.reportFormat
+			}
+		}
+	}
+
+	for _, err := range notify.NotifyAll(ctx, cfg.notifiers, summary) {
+		log.Printf("WARN: notification failed: %v", err)
+	}
+
+	return summary, nil
+}
+
+// cleanMerchants names the merchant of each transaction with the configured language model. It only
+// makes the upload nicer, so a model that can't be reached costs the names, not the run. Names are
+// cached in stateDir.
+func cleanMerchants(ctx context.Context, cfg enrich.Config, stateDir string, transactions []*domain.Transaction, warnf func(string, ...any)) {
+	cache, err := enrich.LoadCache(filepath.Join(stateDir, enrich.DefaultCachePath))
+	if err != nil {
+		warnf("no merchant names: %v", err)
+		return
+	}
+	cleaner, err := enrich.New(cfg, cache)
+	if err != nil {
+		warnf("no merchant names: %v", err)
+		return
+	}
+
+	cleaned, err := cleaner.Clean(ctx, transactions)
+	if err != nil {
+		warnf("some merchant names are missing: %v", err)
+	}
+	if cleaned > 0 {
+		fmt.Printf("named the merchant of %d transactions\n", cleaned)
+	}
+}
+
+// describeMerchants adds what the merchant directory knows to each transaction, then looks up the
+// websites it doesn't know when a lookup URL is set. A broken data file stops the import, a lookup
+// that fails only costs the websites.
+func describeMerchants(ctx context.Context, cfg importConfig, transactions []*domain.Transaction, warnf func(string, ...any)) error {
+	directory, err := loadMerchantDirectory(cfg.merchantData)
+	if err != nil {
+		return err
+	}
+	if found := directory.Apply(transactions); found > 0 {
+		fmt.Printf("found %d transactions in the merchant directory\n", found)
+	}
+
+	if cfg.merchantLookup == "" {
+		return nil
+	}
+	cache, err := enrich.LoadCache(filepath.Join(cfg.stateDir, enrich.DefaultCachePath))
+	if err != nil {
+		warnf("no merchant websites: %v", err)
+		return nil
+	}
+	lookup, err := enrich.NewLookup(cfg.merchantLookup, cache)
+	if err != nil {
+		return err
+	}
+	if _, err := lookup.Websites(ctx, transactions); err != nil {
+		warnf("some merchant websites are missing: %v", err)
+	}
+	return nil
+}
+
+// loadMerchantDirectory reads the bundled merchants, plus a file unless MERCHANT_DATA is "bundled"
+func loadMerchantDirectory(data string) (*enrich.Directory, error) {
+	if data == "bundled" {
+		data = ""
+	}
+	return enrich.LoadDirectory(data)
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"arian-statement-parser/internal/client"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/keyring"
+	"arian-statement-parser/internal/mapping"
+
+	"github.com/charmbracelet/huh"
+)
+
+// envPath is the config file init writes, the same one every command loads
+const envPath = ".env"
+
+// Where init keeps the API key
+const (
+	keyInKeyring = "keyring"
+	keyInEnv     = "env"
+)
+
+// runInit walks through a first setup: where ariand is, who the user is and which key to use, checks
+// them against ariand, optionally maps statement accounts to ariand accounts and writes .env
+func runInit(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: %s init", completionName)
+	}
+
+	settings := initSettings{
+		serverURL: envOrEmpty("ARIAND_URL"),
+		userID:    envOrEmpty("USER_ID"),
+		apiKey:    envOrEmpty("API_KEY"),
+		pdfPath:   envOrEmpty("PDF_PATH"),
+		keyStore:  keyInKeyring,
+	}
+	if settings.pdfPath == "" {
+		settings.pdfPath = "input"
+	}
+
+	var user *pb.User
+	var accounts []*pb.Account
+	for {
+		if err := settings.ask(); err != nil {
+			return err
+		}
+
+		var err error
+		user, accounts, err = settings.check()
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+
+		retry := true
+		if err := huh.NewConfirm().Title("Change the settings and try again?").Value(&retry).Run(); err != nil {
+			return fmt.Errorf("prompt failed: %w", err)
+		}
+		if !retry {
+			return errors.New("nothing was written")
+		}
+	}
+
+	fmt.Printf("connected: user %s has %d accounts\n", user.Email, len(accounts))
+	for _, account := range accounts {
+		fmt.Printf("  %s (%s - %s)\n", account.Name, account.Bank, account.Type.String())
+	}
+
+	if err := settings.save(); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", envPath)
+
+	if len(accounts) > 0 {
+		if err := seedMappings(accounts); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("all set, import with: %s -pdf %s\n", completionName, settings.pdfPath)
+	return nil
+}
+
+// initSettings are the answers init asks for
+type initSettings struct {
+	serverURL string
+	userID    string
+	apiKey    string
+	keyStore  string
+	pdfPath   string
+}
+
+func (s *initSettings) ask() error {
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("ariand address").
+				Description("host:port of its gRPC API; :443 uses TLS").
+				Placeholder("api.arian.example.com:443").
+				Value(&s.serverURL).
+				Validate(required("an address")),
+			huh.NewInput().
+				Title("User ID").
+				Description("UUID of the ariand user to import for").
+				Value(&s.userID).
+				Validate(required("a user ID")),
+			huh.NewInput().
+				Title("API key").
+				Description("the internal key ariand was started with").
+				EchoMode(huh.EchoModePassword).
+				Value(&s.apiKey).
+				Validate(required("an API key")),
+			huh.NewSelect[string]().
+				Title("Keep the API key in").
+				Options(
+					huh.NewOption("The system keyring", keyInKeyring),
+					huh.NewOption(".env, in plain text", keyInEnv),
+				).
+				Value(&s.keyStore),
+			huh.NewInput().
+				Title("Statement folder").
+				Description("where imports look for PDF statements and CSV exports").
+				Value(&s.pdfPath).
+				Validate(required("a folder")),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return fmt.Errorf("prompt failed: %w", err)
+	}
+	return nil
+}
+
+// check connects to ariand with the answers and fetches the user and their accounts
+func (s *initSettings) check() (*pb.User, []*pb.Account, error) {
+	settings, err := clientSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	arianClient, err := client.NewClientWithSettings(s.serverURL, s.apiKey, settings)
+	if err != nil {
+		return nil, nil, fmt.Errorf("client failed: %w", err)
+	}
+	defer arianClient.Close()
+
+	user, err := arianClient.Preflight(s.userID)
+	if err != nil {
+		return nil, nil, preflightError(err, s.serverURL)
+	}
+
+	accounts, err := arianClient.GetAccounts(s.userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get accounts failed: %w", err)
+	}
+	return user, accounts, nil
+}
+
+// save writes the answers to .env, starting from .env.example so every other setting is listed
+func (s *initSettings) save() error {
+	values := map[string]string{
+		"ARIAND_URL": s.serverURL,
+		"USER_ID":    s.userID,
+		"PDF_PATH":   s.pdfPath,
+		"API_KEY":    s.apiKey,
+	}
+	if s.keyStore == keyInKeyring {
+		if err := keyring.Set(apiKeyAccount, s.apiKey); err != nil {
+			return err
+		}
+		// An empty API_KEY falls through to the keyring
+		values["API_KEY"] = ""
+	}
+
+	base, err := os.ReadFile(envPath)
+	if os.IsNotExist(err) {
+		base, err = os.ReadFile(".env.example")
+		if os.IsNotExist(err) {
+			base, err = nil, nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := os.WriteFile(envPath, []byte(setEnvValues(string(base), values)), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", envPath, err)
+	}
+	return nil
+}
+
+// seedMappings asks which statement accounts go to each ariand account, so the first import
+// doesn't have to
+func seedMappings(accounts []*pb.Account) error {
+	seed := true
+	err := huh.NewConfirm().
+		Title("Map statement accounts to these accounts now?").
+		Description("Otherwise the first import asks about each statement account it finds.").
+		Value(&seed).
+		Run()
+	if err != nil {
+		return fmt.Errorf("prompt failed: %w", err)
+	}
+	if !seed {
+		return nil
+	}
+
+	store, err := mapping.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to initialize mapping store: %w", err)
+	}
+
+	mapped := 0
+	for _, account := range accounts {
+		var numbers string
+		err := huh.NewInput().
+			Title(fmt.Sprintf("Statement accounts for %s (%s - %s)", account.Name, account.Bank, account.Type.String())).
+			Description("Account numbers as printed on statements, or names for CSV exports, comma-separated. Empty to skip.").
+			Value(&numbers).
+			Run()
+		if err != nil {
+			return fmt.Errorf("prompt failed: %w", err)
+		}
+
+		for _, number := range splitList(numbers) {
+			if err := store.AddMapping(number, account.Name); err != nil {
+				return err
+			}
+			mapped++
+		}
+	}
+
+	if mapped > 0 {
+		fmt.Printf("saved %d account mappings\n", mapped)
+	}
+	return nil
+}
+
+func required(what string) func(string) error {
+	return func(value string) error {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("need %s", what)
+		}
+		return nil
+	}
+}
+
+// envOrEmpty reads a setting, ignoring the placeholders .env.example ships with
+func envOrEmpty(name string) string {
+	value := os.Getenv(name)
+	if strings.HasPrefix(value, "your") {
+		return ""
+	}
+	return value
+}
+
+var envLine = regexp.MustCompile(`^(\s*#?\s*(?:export\s+)?)([A-Z0-9_]+)=(.*)$`)
+
+// setEnvValues sets keys in the text of a .env file, keeping comments and every other line. A key
+// that is only there commented out is uncommented; a key that isn't there at all is appended.
+func setEnvValues(text string, values map[string]string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	// Lines in use win over commented ones, so a key is never set twice
+	set := make(map[string]bool)
+	for _, commented := range []bool{false, true} {
+		for i, line := range lines {
+			m := envLine.FindStringSubmatch(line)
+			if m == nil || strings.Contains(m[1], "#") != commented {
+				continue
+			}
+			value, ok := values[m[2]]
+			if !ok || set[m[2]] {
+				continue
+			}
+
+			var comment string
+			if j := strings.Index(m[3], " #"); j >= 0 {
+				comment = m[3][j:]
+			}
+			prefix := strings.TrimLeft(strings.Replace(m[1], "#", "", 1), " ")
+			lines[i] = prefix + m[2] + "=" + quoteEnv(value) + comment
+			set[m[2]] = true
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if !set[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, key+"="+quoteEnv(values[key]))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// quoteEnv quotes values that would otherwise be cut at a space or a #
+func quoteEnv(value string) string {
+	if strings.ContainsAny(value, " #\"'") {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
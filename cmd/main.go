@@ -1,283 +1,547 @@
 package main
 
 import (
-	"bufio"
+	"cmp"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"arian-statement-parser/internal/archive"
 	"arian-statement-parser/internal/client"
-	pb "arian-statement-parser/internal/gen/arian/v1"
-	"arian-statement-parser/internal/mapping"
-	"arian-statement-parser/internal/parser"
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/enrich"
+	"arian-statement-parser/internal/notes"
+	"arian-statement-parser/internal/notify"
+	"arian-statement-parser/internal/report"
+	"arian-statement-parser/internal/validate"
+	"arian-statement-parser/pkg/importer"
 
 	"github.com/joho/godotenv"
 )
 
-func convertToAccountType(accountType string) pb.AccountType {
-	switch accountType {
-	case "visa":
-		return pb.AccountType_ACCOUNT_CREDIT_CARD
-	case "savings":
-		return pb.AccountType_ACCOUNT_SAVINGS
-	case "chequing":
-		return pb.AccountType_ACCOUNT_CHEQUING
-	default:
-		return pb.AccountType_ACCOUNT_UNSPECIFIED
+// splitList splits a comma separated env value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
 
-func findMatchingAccount(accounts []*pb.Account, accountName string, accountType string) *pb.Account {
-	expectedType := convertToAccountType(accountType)
-	for _, account := range accounts {
-		if account.Type == expectedType && strings.EqualFold(account.Name, accountName) {
-			return account
-		}
+// envFloat overrides *value with a numeric env var when it is set
+func envFloat(name string, value *float64) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*value = parsed
+	return nil
+}
+
+// envInt overrides *value with an integer env var when it is set
+func envInt(name string, value *int) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*value = parsed
+	return nil
+}
+
+// descriptionMax is the longest description to upload, DESCRIPTION_MAX_LENGTH or ariand's default
+func descriptionMax() (int, error) {
+	length := domain.MaxDescription
+	if err := envInt("DESCRIPTION_MAX_LENGTH", &length); err != nil {
+		return 0, err
+	}
+	return length, nil
+}
+
+// envSize overrides *value with a byte size env var when it is set, like 512MiB, 2GB or 1048576
+func envSize(name string, value *uint64) error {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return nil
+	}
+
+	number := strings.TrimRightFunc(raw, func(r rune) bool { return r < '0' || r > '9' })
+	unit := strings.ToUpper(strings.TrimSpace(raw[len(number):]))
+	multipliers := map[string]uint64{
+		"": 1, "B": 1,
+		"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+		"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+		"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
 	}
+	multiplier, ok := multipliers[unit]
+	parsed, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || !ok {
+		return fmt.Errorf("invalid %s %q, want a size like 512MiB or 2GB", name, raw)
+	}
+	*value = parsed * multiplier
 	return nil
 }
 
+// clientSettings applies ARIAND_RETRIES, ARIAND_RATE_LIMIT and ARIAND_TRANSPORT to the client defaults
+func clientSettings() (client.Settings, error) {
+	settings := client.DefaultSettings()
+	if err := envInt("ARIAND_RETRIES", &settings.Retries); err != nil {
+		return settings, err
+	}
+	if err := envFloat("ARIAND_RATE_LIMIT", &settings.RateLimit); err != nil {
+		return settings, err
+	}
+	settings.Transport = os.Getenv("ARIAND_TRANSPORT")
+	return settings, nil
+}
+
+// importOptions are the flags of an import
+type importOptions struct {
+	pdfPath        *string
+	configPath     *string
+	institution    *string
+	sourceKind     *string
+	login          *string
+	scheduleExpr   *string
+	jitter         *time.Duration
+	noCache        *bool
+	strict         *bool
+	demo           *bool
+	recordPath     *string
+	replayPath     *string
+	skipInvalid    *bool
+	confirm        *string
+	includePending *bool
+	transport      *string
+	exportPath     *string
+	reportFormat   *string
+	asJSON         *bool
+	archiveDir     *string
+	archiveRename  *bool
+}
+
+// importFlags defines the flags of an import on fs
+func importFlags(fs *flag.FlagSet) *importOptions {
+	return &importOptions{
+		pdfPath:        fs.String("pdf", "", "folder of PDF statements, CSV exports and OFX downloads, defaults to PDF_PATH"),
+		configPath:     fs.String("config", "", "parser config file with extraction profiles"),
+		institution:    fs.String("institution", "", "read every PDF as this bank's statement, rbc or a template's name or bank, instead of detecting it"),
+		sourceKind:     fs.String("source", "", "pull statements from s3, sftp, webdav, gdrive or dropbox, defaults to STATEMENT_SOURCE"),
+		login:          fs.String("login", "", "authorize gdrive or dropbox once and exit"),
+		scheduleExpr:   fs.String("schedule", "", "run as a daemon on this cron schedule, defaults to SCHEDULE"),
+		jitter:         fs.Duration("jitter", 0, "random delay added to each scheduled run, e.g. 5m"),
+		noCache:        fs.Bool("no-cache", false, "parse every statement again instead of using the parse cache"),
+		strict:         fs.Bool("strict", false, "fail a statement on any line that can't be read instead of skipping the line"),
+		demo:           fs.Bool("demo", false, "upload to an in-memory fake of ariand"),
+		recordPath:     fs.String("record", "", "write every ariand call and response to this file"),
+		replayPath:     fs.String("replay", "", "answer ariand calls from a -record file instead of the network"),
+		skipInvalid:    fs.Bool("skip-invalid", false, "put transactions that fail validation in the review queue instead of stopping"),
+		confirm:        fs.String("confirm", confirmAll, "all to approve the upload once, per-account to approve each statement account's transactions separately"),
+		includePending: fs.Bool("include-pending", false, "import transactions the bank hasn't posted yet"),
+		transport:      fs.String("transport", "", "grpc or connect, for ariand behind a proxy that blocks HTTP/2"),
+		exportPath:     fs.String("export", "", "write the transactions to this CSV file instead of uploading them"),
+		reportFormat:   fs.String("report", "", "write a markdown or html report of the run, defaults to REPORT_FORMAT"),
+		asJSON:         fs.Bool("json", false, "print the result of each run as a JSON object on stdout, and everything else on stderr"),
+		archiveDir:     fs.String("archive-dir", "", "move imported statements into Institution/Account/YYYY folders here, defaults to ARCHIVE_DIR"),
+		archiveRename:  fs.Bool("archive-rename", false, "name archived statements with RENAME_TEMPLATE, like the rename command"),
+	}
+}
+
+// commands maps subcommand names to their entry points; without one the tool runs an import
+var commands = map[string]func(args []string) error{
+	"accounts":    runAccounts,
+	"anonymize":   runAnonymize,
+	"auth":        runAuth,
+	"bench":       runBench,
+	"completion":  runCompletion,
+	"doctor":      runDoctor,
+	"help":        runHelp,
+	"init":        runInit,
+	"man":         runMan,
+	"map":         runMap,
+	"parse":       runParse,
+	"rename":      runRename,
+	"report":      runSpending,
+	"self-update": runSelfUpdate,
+	"tx":          runTx,
+	"upload":      runUpload,
+}
+
 func main() {
-	pdfPath := flag.String("pdf", "", "")
-	configPath := flag.String("config", "", "")
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			godotenv.Load()
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	flag.Usage = func() { printHelp(flag.CommandLine.Output(), commandDocs[0], flag.CommandLine) }
+	opts := importFlags(flag.CommandLine)
 	flag.Parse()
 
+	// With -json only results go to stdout. Progress, prompts and everything else printed along
+	// the way go to stderr, so a wrapper can read stdout as is.
+	var results io.Writer
+	if *opts.asJSON {
+		results = os.Stdout
+		os.Stdout = os.Stderr
+	}
+
 	godotenv.Load()
 
-	if *pdfPath == "" {
-		if envPath := os.Getenv("PDF_PATH"); envPath != "" {
-			*pdfPath = envPath
-		} else {
-			fmt.Fprintf(os.Stderr, "need -pdf flag\n")
-			os.Exit(1)
+	// Authorize a cloud source once and keep its refresh token in the keyring
+	if *opts.login != "" {
+		if err := loginSource(*opts.login); err != nil {
+			log.Fatalf("login failed: %v", err)
+		}
+		fmt.Printf("%s token saved to keyring\n", *opts.login)
+		return
+	}
+
+	if *opts.sourceKind == "" {
+		*opts.sourceKind = os.Getenv("STATEMENT_SOURCE")
+	}
+
+	if *opts.scheduleExpr == "" {
+		*opts.scheduleExpr = os.Getenv("SCHEDULE")
+	}
+
+	if *opts.jitter == 0 {
+		if envJitter := os.Getenv("SCHEDULE_JITTER"); envJitter != "" {
+			parsed, err := time.ParseDuration(envJitter)
+			if err != nil {
+				log.Fatalf("invalid SCHEDULE_JITTER: %v", err)
+			}
+			*opts.jitter = parsed
 		}
 	}
 
+	if *opts.pdfPath == "" {
+		*opts.pdfPath = os.Getenv("PDF_PATH")
+	}
+
+	if *opts.pdfPath == "" && *opts.sourceKind == "" {
+		fmt.Fprintf(os.Stderr, "need -pdf flag\n")
+		os.Exit(1)
+	}
+
 	userID := os.Getenv("USER_ID")
+	serverURL := os.Getenv("ARIAND_URL")
+	var apiKey string
+	var keySource client.KeySource
+
+	// Demo mode swaps ariand for an in-memory fake, so no credentials are needed
+	var demoDir string
+	if *opts.demo {
+		server, dir, addr, err := startDemo()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer printDemoResult(server, dir)
+		userID, serverURL, apiKey, demoDir = demoUserID, addr, "", dir
+	}
+
+	// A replay never reaches the network, so only the user ID from the recording matters
+	if *opts.replayPath != "" {
+		serverURL, apiKey = "replay.invalid:0", ""
+	}
+
+	// An export never talks to ariand, so its credentials don't matter
+	if *opts.exportPath != "" {
+		userID = cmp.Or(userID, "export")
+		serverURL = ""
+	}
+
 	if userID == "" {
 		fmt.Fprintf(os.Stderr, "need USER_ID\n")
 		os.Exit(1)
 	}
 
-	serverURL := os.Getenv("ARIAND_URL")
-	if serverURL == "" {
+	if serverURL == "" && *opts.exportPath == "" {
 		fmt.Fprintf(os.Stderr, "need ARIAND_URL\n")
 		os.Exit(1)
 	}
 
-	apiKey := os.Getenv("API_KEY")
-	if apiKey == "" {
-		fmt.Fprintf(os.Stderr, "need API_KEY\n")
-		os.Exit(1)
+	if !*opts.demo && *opts.replayPath == "" && *opts.exportPath == "" {
+		keySource = apiKeySource()
+		key, err := loadAPIKey(keySource)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		apiKey = key
 	}
 
-	pythonParser := parser.NewPythonParser()
-
-	fmt.Printf("parsing %s\n", *pdfPath)
-	parseResult, transactions, err := pythonParser.ParseStatements(*pdfPath, *configPath)
-	if err != nil {
-		log.Fatalf("parse failed: %v", err)
+	var notifiers []notify.Notifier
+	if webhookURL := os.Getenv("WEBHOOK_URL"); webhookURL != "" {
+		webhook, err := notify.NewWebhook(webhookURL, os.Getenv("WEBHOOK_FORMAT"))
+		if err != nil {
+			log.Fatalf("webhook config invalid: %v", err)
+		}
+		notifiers = append(notifiers, webhook)
 	}
 
-	fmt.Printf("files: %d/%d, transactions: %d\n",
-		parseResult.Summary.ProcessedFiles,
-		parseResult.Summary.TotalFiles,
-		parseResult.Summary.TotalTransactions)
-
-	for _, fileResult := range parseResult.FileResults {
-		fileName := filepath.Base(fileResult.File)
-		if fileResult.Processed {
-			fmt.Printf("  %s: %d\n", fileName, fileResult.TransactionCount)
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		email, err := notify.NewEmail(notify.EmailConfig{
+			Host:     smtpHost,
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+			To:       splitList(os.Getenv("SMTP_TO")),
+		})
+		if err != nil {
+			log.Fatalf("email config invalid: %v", err)
 		}
+		notifiers = append(notifiers, email)
 	}
 
-	if len(transactions) == 0 {
-		return
+	guardrails := validate.DefaultGuardrails()
+	if err := envFloat("GUARD_MAX_AMOUNT", &guardrails.MaxAmount); err != nil {
+		log.Fatal(err)
 	}
-
-	fmt.Printf("\nupload %d transactions? (y/N): ", len(transactions))
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		log.Fatalf("read failed: %v", err)
+	if err := envInt("GUARD_MAX_STATEMENT_TRANSACTIONS", &guardrails.MaxPerStatement); err != nil {
+		log.Fatal(err)
+	}
+	if err := envFloat("GUARD_MAX_IDENTICAL_PERCENT", &guardrails.MaxIdenticalPct); err != nil {
+		log.Fatal(err)
+	}
+	if err := envInt("GUARD_HISTORY", &guardrails.History); err != nil {
+		log.Fatal(err)
 	}
 
-	response = strings.TrimSpace(strings.ToLower(response))
-	if response != "y" && response != "yes" {
-		return
+	confidenceThreshold := 0.8
+	if err := envFloat("CONFIDENCE_THRESHOLD", &confidenceThreshold); err != nil {
+		log.Fatal(err)
 	}
 
-	arianClient, err := client.NewClient(serverURL, "", apiKey)
+	settings, err := clientSettings()
 	if err != nil {
-		log.Fatalf("client failed: %v", err)
+		log.Fatal(err)
+	}
+	if *opts.transport != "" {
+		settings.Transport = *opts.transport
+	}
+	// The demo fake only speaks gRPC
+	if *opts.demo {
+		settings.Transport = client.TransportGRPC
 	}
-	defer arianClient.Close()
 
-	_, err = arianClient.GetUser(userID)
+	cardPayments, err := importer.CheckCardPaymentPolicy(os.Getenv("CARD_PAYMENT_POLICY"))
 	if err != nil {
-		log.Fatalf("user not found: %v", err)
+		log.Fatal(err)
+	}
+	cardPaymentCategory := os.Getenv("CARD_PAYMENT_CATEGORY")
+	if cardPaymentCategory == "" {
+		cardPaymentCategory = "transfer"
 	}
 
-	accounts, err := arianClient.GetAccounts(userID)
+	classifier, err := checkClassifier(os.Getenv("CATEGORIZE"))
 	if err != nil {
-		log.Fatalf("get accounts failed: %v", err)
+		log.Fatal(err)
 	}
 
-	// Initialize mapping store
-	mappingStore, err := mapping.NewStore()
-	if err != nil {
-		log.Fatalf("failed to initialize mapping store: %v", err)
+	previewLines := 5
+	if err := envInt("PREVIEW_LINES", &previewLines); err != nil {
+		log.Fatal(err)
 	}
 
-	accountMatchStats := make(map[string]int)
-	askedMappings := make(map[string]bool) // Track which accounts we've already asked about
+	uploadConcurrency := 1
+	if err := envInt("UPLOAD_CONCURRENCY", &uploadConcurrency); err != nil {
+		log.Fatal(err)
+	}
+	if uploadConcurrency < 1 {
+		log.Fatal("UPLOAD_CONCURRENCY must be at least 1")
+	}
 
-	// First pass: resolve all account mappings
-	for _, tx := range transactions {
-		var accountName string
-		if tx.StatementAccountNumber != nil && *tx.StatementAccountNumber != "" {
-			accountName = *tx.StatementAccountNumber
-		} else {
-			accountName = "Unknown"
-		}
+	// The garbage collector works harder as the heap nears the limit, before the pipeline gives up
+	var maxMemory uint64
+	if err := envSize("MAX_MEMORY", &maxMemory); err != nil {
+		log.Fatal(err)
+	}
+	if maxMemory > 0 {
+		debug.SetMemoryLimit(int64(maxMemory))
+	}
+	classifierHistory := 5000
+	if err := envInt("CATEGORIZE_HISTORY", &classifierHistory); err != nil {
+		log.Fatal(err)
+	}
+	classifierThreshold := 0.9
+	if err := envFloat("CATEGORIZE_THRESHOLD", &classifierThreshold); err != nil {
+		log.Fatal(err)
+	}
 
-		mappingKey := accountName + "|" + tx.StatementAccountType
-		if askedMappings[mappingKey] {
-			continue // Already resolved this account
+	var merchantLLM *enrich.Config
+	if url := os.Getenv("MERCHANT_LLM_URL"); url != "" {
+		merchantLLM = &enrich.Config{
+			URL:    url,
+			Model:  os.Getenv("MERCHANT_LLM_MODEL"),
+			APIKey: os.Getenv("MERCHANT_LLM_API_KEY"),
 		}
-		askedMappings[mappingKey] = true
-
-		var matchedAccount *pb.Account
-
-		// First, check if we have a saved mapping for this statement account
-		arianAccountName := mappingStore.FindMapping(accountName)
-
-		if arianAccountName != "" {
-			// Use the saved mapping - resolve by account name
-			matchedAccount = mappingStore.ResolveAccount(arianAccountName, accounts)
-			if matchedAccount == nil {
-				log.Printf("WARN: saved mapping for '%s' points to non-existent account '%s', will re-prompt", accountName, arianAccountName)
-			}
+		if merchantLLM.Model == "" {
+			log.Fatal("MERCHANT_LLM_MODEL is required with MERCHANT_LLM_URL")
 		}
+	}
 
-		// If no saved mapping or account not found, try to match by name and type
-		if matchedAccount == nil {
-			matchedAccount = findMatchingAccount(accounts, accountName, tx.StatementAccountType)
+	// Bad merchant data or lookup URLs should stop a daemon at start, not at its first run
+	if data := os.Getenv("MERCHANT_DATA"); data != "" {
+		if _, err := loadMerchantDirectory(data); err != nil {
+			log.Fatal(err)
 		}
-
-		// If still no match, prompt the user
-		if matchedAccount == nil {
-			selectedAccountID, isNewAccount, err := mapping.PromptForAccountMapping(accountName, accounts)
-			if err != nil {
-				log.Fatalf("mapping prompt failed: %v", err)
-			}
-
-			if isNewAccount {
-				// Create new account
-				accountType := convertToAccountType(tx.StatementAccountType)
-				newAccount, err := arianClient.CreateAccount(userID, accountName, "RBC", accountType, "CAD")
-				if err != nil {
-					log.Fatalf("create account failed: %v", err)
-				}
-				matchedAccount = newAccount
-				accounts = append(accounts, newAccount)
-
-				// Save mapping
-				err = mappingStore.AddMapping(accountName, newAccount.Name)
-				if err != nil {
-					log.Printf("WARN: failed to save mapping: %v", err)
-				}
-			} else {
-				// Use selected existing account
-				selectedAccountIDInt, _ := strconv.ParseInt(selectedAccountID, 10, 64)
-				for _, account := range accounts {
-					if account.Id == selectedAccountIDInt {
-						matchedAccount = account
-						break
-					}
-				}
-
-				if matchedAccount == nil {
-					log.Fatalf("selected account not found")
-				}
-
-				// Save mapping
-				err = mappingStore.AddMapping(accountName, matchedAccount.Name)
-				if err != nil {
-					log.Printf("WARN: failed to save mapping: %v", err)
-				}
-
-				// Warn if types don't match
-				expectedType := convertToAccountType(tx.StatementAccountType)
-				if matchedAccount.Type != expectedType {
-					log.Printf("WARN: account '%s' type mismatch - statement expects %s but account is %s (continuing anyway)", accountName, expectedType, matchedAccount.Type)
-				}
+		if lookupURL := os.Getenv("MERCHANT_LOOKUP_URL"); lookupURL != "" {
+			if _, err := enrich.NewLookup(lookupURL, nil); err != nil {
+				log.Fatal(err)
 			}
 		}
 	}
 
-	// Second pass: assign account IDs to all transactions
-	for _, tx := range transactions {
-		var accountName string
-		if tx.StatementAccountNumber != nil && *tx.StatementAccountNumber != "" {
-			accountName = *tx.StatementAccountNumber
-		} else {
-			accountName = "Unknown"
-		}
+	noteTemplate, err := notes.New("NOTES_TEMPLATE", os.Getenv("NOTES_TEMPLATE"), os.Getenv("DESCRIPTION_TEMPLATE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	descriptionLength, err := descriptionMax()
+	if err != nil {
+		log.Fatal(err)
+	}
+	accents, err := importer.CheckAccentPolicy(os.Getenv("DESCRIPTION_ACCENTS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	typeMismatch, err := checkMismatchPolicy(os.Getenv("ACCOUNT_TYPE_MISMATCH"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	confirm, err := checkConfirm(*opts.confirm)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		arianAccountName := mappingStore.FindMapping(accountName)
-		if arianAccountName == "" {
-			// Try to match by name and type
-			matchedAccount := findMatchingAccount(accounts, accountName, tx.StatementAccountType)
-			if matchedAccount != nil {
-				tx.AccountID = int(matchedAccount.Id)
-				accountMatchStats[accountName]++
-			} else {
-				log.Fatalf("no account found for transaction with account '%s' (this shouldn't happen)", accountName)
-			}
-		} else {
-			// Resolve account by name
-			matchedAccount := mappingStore.ResolveAccount(arianAccountName, accounts)
-			if matchedAccount != nil {
-				tx.AccountID = int(matchedAccount.Id)
-				accountMatchStats[accountName]++
-			} else {
-				log.Fatalf("no account found for mapping '%s' -> '%s' (this shouldn't happen)", accountName, arianAccountName)
-			}
+	if *opts.reportFormat == "" {
+		*opts.reportFormat = os.Getenv("REPORT_FORMAT")
+	}
+	if *opts.reportFormat != "" {
+		if err := report.CheckFormat(*opts.reportFormat); err != nil {
+			log.Fatal(err)
 		}
 	}
-
-	// Bulk upload transactions in batches
-	const batchSize = 1000
-	totalCreated := int32(0)
-	totalErrors := 0
-
-	for i := 0; i < len(transactions); i += batchSize {
-		end := i + batchSize
-		if end > len(transactions) {
-			end = len(transactions)
+	reportNotify, _ := strconv.ParseBool(os.Getenv("REPORT_NOTIFY"))
+	interestCharges, _ := strconv.ParseBool(os.Getenv("INTEREST_CHARGES"))
+	interestCategory := cmp.Or(os.Getenv("INTEREST_CATEGORY"), "interest")
+	interestIncomeCategory := cmp.Or(os.Getenv("INTEREST_INCOME_CATEGORY"), "interest-income")
+
+	var archiveNamer *archive.Namer
+	if *opts.archiveRename {
+		if archiveNamer, err = archive.NewNamer(os.Getenv("RENAME_TEMPLATE")); err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		batch := transactions[i:end]
-		created, errors := arianClient.CreateTransactionsBulk(userID, batch)
-		totalCreated += created
-		totalErrors += len(errors)
+	cfg := importConfig{
+		pdfPath:                *opts.pdfPath,
+		configPath:             *opts.configPath,
+		institution:            *opts.institution,
+		sourceKind:             *opts.sourceKind,
+		userID:                 userID,
+		serverURL:              serverURL,
+		apiKey:                 apiKey,
+		apiKeySource:           keySource,
+		clientSettings:         settings,
+		exportPath:             *opts.exportPath,
+		notifiers:              notifiers,
+		noCache:                *opts.noCache,
+		strict:                 *opts.strict,
+		recordPath:             *opts.recordPath,
+		replayPath:             *opts.replayPath,
+		skipInvalid:            *opts.skipInvalid,
+		confirm:                confirm,
+		previewLines:           previewLines,
+		guardrails:             guardrails,
+		cardPayments:           cardPayments,
+		cardPaymentCategory:    cardPaymentCategory,
+		interestCharges:        interestCharges,
+		interestCategory:       interestCategory,
+		interestIncomeCategory: interestIncomeCategory,
+		includePending:         *opts.includePending,
+		uploadConcurrency:      uploadConcurrency,
+		maxMemory:              maxMemory,
+		confidenceThreshold:    confidenceThreshold,
+		classifier:             classifier,
+		classifierHistory:      classifierHistory,
+		classifierThreshold:    classifierThreshold,
+		merchantLLM:            merchantLLM,
+		merchantData:           os.Getenv("MERCHANT_DATA"),
+		merchantLookup:         os.Getenv("MERCHANT_LOOKUP_URL"),
+		notes:                  noteTemplate,
+		descriptionLength:      descriptionLength,
+		accents:                accents,
+		typeMismatch:           typeMismatch,
+		reportFormat:           *opts.reportFormat,
+		reportDir:              cmp.Or(os.Getenv("REPORT_DIR"), "reports"),
+		reportNotify:           reportNotify,
+		stateDir:               demoDir,
+		archiveDir:             cmp.Or(*opts.archiveDir, os.Getenv("ARCHIVE_DIR")),
+		archiveNamer:           archiveNamer,
+		results:                results,
+	}
 
-		if len(errors) > 0 {
-			for _, err := range errors {
-				log.Printf("ERROR: %v", err)
-			}
+	// The demo's accounts are gone on exit, so it must not leave cache entries or archived
+	// statements behind that say they were uploaded
+	if *opts.demo {
+		cfg.noCache = true
+		if cfg.archiveDir != "" {
+			fmt.Fprintln(os.Stderr, "demo runs don't archive statements")
+			cfg.archiveDir = ""
 		}
+	}
 
-		fmt.Printf("%d/%d\n", end, len(transactions))
+	if *opts.scheduleExpr != "" {
+		if err := runDaemon(cfg, *opts.scheduleExpr, *opts.jitter); err != nil {
+			os.RemoveAll(demoDir)
+			log.Fatalf("daemon failed: %v", err)
+		}
+		return
 	}
 
-	fmt.Printf("\n%d ok, %d failed\n", totalCreated, totalErrors)
-	for account, count := range accountMatchStats {
-		fmt.Printf("  %s: %d\n", account, count)
+	// The first interrupt stops the import after the step it is in, a second one quits right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+			log.Printf("stopping, interrupt again to quit now")
+		case <-finished:
+		}
+	}()
+
+	summary, err := runImport(ctx, cfg)
+	close(finished)
+	stop()
+	writeResult(cfg, summary, err)
+	if err != nil {
+		// log.Fatal skips the deferred cleanup
+		os.RemoveAll(demoDir)
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"arian-statement-parser/internal/mapping"
+)
+
+// pruneOptions are the flags of map prune
+type pruneOptions struct {
+	months *int
+	dryRun *bool
+}
+
+// pruneFlags defines the flags of map prune on fs
+func pruneFlags(fs *flag.FlagSet) *pruneOptions {
+	return &pruneOptions{
+		months: fs.Int("months", 12, "remove mappings no import has used for this many months"),
+		dryRun: fs.Bool("dry-run", false, "list the mappings that would be removed without removing them"),
+	}
+}
+
+// runMap handles "map prune", which removes the account mappings of statement accounts that stopped
+// showing up, and those pointing at accounts deleted from ariand
+func runMap(args []string) error {
+	if len(args) == 0 || args[0] != "prune" {
+		return fmt.Errorf("usage: arian-statement-parser map prune [flags]")
+	}
+	fs := newFlagSet("map")
+	opts := pruneFlags(fs)
+	fs.Parse(args[1:])
+	if *opts.months < 1 {
+		return fmt.Errorf("invalid -months %d, want at least 1", *opts.months)
+	}
+
+	userID := os.Getenv("USER_ID")
+	if userID == "" {
+		return fmt.Errorf("need USER_ID")
+	}
+	arianClient, err := dialUpload(userID)
+	if err != nil {
+		return err
+	}
+	defer arianClient.Close()
+
+	accounts, err := arianClient.GetAccounts(userID)
+	if err != nil {
+		return fmt.Errorf("get accounts failed: %w", err)
+	}
+	mappingStore, err := mapping.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to initialize mapping store: %w", err)
+	}
+
+	stale := mappingStore.Prunable(accounts, time.Now().AddDate(0, -*opts.months, 0))
+	if len(stale) == 0 {
+		fmt.Printf("nothing to prune, %d mappings in use\n", len(mappingStore.Mappings))
+		return nil
+	}
+
+	statementAccounts := make([]string, 0, len(stale))
+	for _, m := range stale {
+		fmt.Printf("  %s\n", m)
+		statementAccounts = append(statementAccounts, m.StatementAccount)
+	}
+	if *opts.dryRun {
+		fmt.Printf("would remove %d of %d mappings\n", len(stale), len(mappingStore.Mappings))
+		return nil
+	}
+
+	total := len(mappingStore.Mappings)
+	if err := mappingStore.Remove(statementAccounts...); err != nil {
+		return err
+	}
+	fmt.Printf("removed %d of %d mappings\n", len(stale), total)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"arian-statement-parser/internal/dedupe"
+	"arian-statement-parser/internal/domain"
+
+	"github.com/charmbracelet/huh"
+)
+
+const overlapDeduplicate = "__deduplicate__"
+
+// resolveOverlaps warns about statements covering the same days and lets the user drop one of them.
+// Unattended runs, and users who keep both, rely on duplicate collapsing instead.
+func resolveOverlaps(transactions []*domain.Transaction, unattended bool, warnf func(string, ...any)) ([]*domain.Transaction, error) {
+	overlaps := dedupe.FindOverlaps(transactions)
+	if len(overlaps) == 0 {
+		return transactions, nil
+	}
+
+	fmt.Printf("\n!!! %d overlapping statements, a common cause of double imports\n", len(overlaps))
+
+	excluded := make(map[string]bool)
+	for _, overlap := range overlaps {
+		warnf("overlapping statements for %s", overlap)
+		if unattended || excluded[overlap.A.File] || excluded[overlap.B.File] {
+			continue
+		}
+
+		choice := overlapDeduplicate
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Title(overlap.String()).
+					Description("How should these be imported?").
+					Options(
+						huh.NewOption("Keep both, drop lines that appear in both", overlapDeduplicate),
+						huh.NewOption(fmt.Sprintf("Exclude %s (%d lines)", filepath.Base(overlap.A.File), overlap.A.Count), overlap.A.File),
+						huh.NewOption(fmt.Sprintf("Exclude %s (%d lines)", filepath.Base(overlap.B.File), overlap.B.Count), overlap.B.File),
+					).
+					Value(&choice),
+			),
+		)
+		if err := form.Run(); err != nil {
+			return nil, fmt.Errorf("overlap prompt failed: %w", err)
+		}
+
+		if choice != overlapDeduplicate {
+			excluded[choice] = true
+		}
+	}
+
+	if len(excluded) == 0 {
+		return transactions, nil
+	}
+
+	for file := range excluded {
+		warnf("excluded %s from this run", filepath.Base(file))
+	}
+	return dedupe.ExcludeFiles(transactions, excluded), nil
+}
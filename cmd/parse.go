@@ -0,0 +1,87 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"arian-statement-parser/internal/parser"
+)
+
+// parseOptions are the flags of parse
+type parseOptions struct {
+	pdfPath     *string
+	configPath  *string
+	institution *string
+	noCache     *bool
+	strict      *bool
+	debugDump   *string
+}
+
+// parseFlags defines the flags of parse on fs
+func parseFlags(fs *flag.FlagSet) *parseOptions {
+	return &parseOptions{
+		pdfPath:     fs.String("pdf", "", "statement or folder of statements, defaults to PDF_PATH"),
+		configPath:  fs.String("config", "", "parser config file"),
+		institution: fs.String("institution", "", "read every PDF as this bank's statement, rbc or a template's name or bank, instead of detecting it"),
+		noCache:     fs.Bool("no-cache", false, "parse every statement again instead of using the parse cache"),
+		strict:      fs.Bool("strict", false, "fail a statement on any line that can't be read instead of skipping the line"),
+		debugDump:   fs.String("debug-dump", "", "folder to write the text, HTML and text positions read from each PDF to, next to the result"),
+	}
+}
+
+// runParse prints what the parsers read from statements as JSON, without uploading or resolving
+// anything. With -debug-dump, what the Python parser extracted from each PDF is written beside it.
+func runParse(args []string) error {
+	fs := newFlagSet("parse")
+	opts := parseFlags(fs)
+	fs.Parse(args)
+
+	path := cmp.Or(*opts.pdfPath, os.Getenv("PDF_PATH"))
+	if path == "" {
+		return fmt.Errorf("need -pdf")
+	}
+
+	warnf := func(format string, args ...any) {
+		log.Printf("WARN: %s", fmt.Sprintf(format, args...))
+	}
+
+	// Cached statements aren't read again, so they'd have nothing to dump
+	pythonParser, templates, err := newParsers(*opts.institution, *opts.noCache || *opts.debugDump != "", *opts.strict, warnf)
+	if err != nil {
+		return err
+	}
+	if *opts.debugDump != "" {
+		if err := os.MkdirAll(*opts.debugDump, 0o700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", *opts.debugDump, err)
+		}
+		pythonParser.WithDump(*opts.debugDump)
+	}
+
+	result, _, err := parser.ParseAll(pythonParser, templates, path, *opts.configPath)
+	if err != nil {
+		return fmt.Errorf("parse failed: %w", err)
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	data = append(data, '\n')
+
+	if *opts.debugDump == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	out := filepath.Join(*opts.debugDump, "result.json")
+	if err := os.WriteFile(out, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s and what was read from each PDF to %s; they hold your real statements, review them before sharing\n", filepath.Base(out), *opts.debugDump)
+	return nil
+}
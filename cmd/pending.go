@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/failures"
+	"arian-statement-parser/internal/pending"
+)
+
+// reconcilePending uploads pending transactions one at a time so their IDs can be kept, and turns
+// posted transactions that settle an earlier pending one into updates. It returns what is left for
+// the bulk upload and how many transactions it created or updated itself. Lines it fails to upload or
+// settle go into failed, like those of a failed bulk upload. The pending records are kept in stateDir,
+// the working directory when empty.
+func reconcilePending(backend client.PendingUpdater, stateDir, userID string, transactions []*domain.Transaction, failed *failures.Report, warnf func(string, ...any)) ([]*domain.Transaction, int, error) {
+	store, err := pending.NewStoreIn(stateDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var rest []*domain.Transaction
+	created, settled := 0, 0
+	changed := false
+
+	for _, tx := range transactions {
+		if tx.Pending {
+			if store.Uploaded(tx) {
+				continue
+			}
+			id, err := backend.CreateTransactionWithID(userID, tx)
+			if err != nil {
+				warnf("failed to upload pending %s %.2f %s: %v", tx.TxDate.Format("2006-01-02"), tx.TxAmount, tx.TxDesc, err)
+				failed.Add([]*domain.Transaction{tx}, err)
+				continue
+			}
+			store.Add(id, tx)
+			created++
+			changed = true
+			continue
+		}
+
+		record, ok := store.Match(tx)
+		if !ok {
+			rest = append(rest, tx)
+			continue
+		}
+
+		if err := backend.UpdateTransaction(userID, record.TransactionID, tx); err != nil {
+			// Leave the record for the next run or a retry rather than uploading a second copy now
+			warnf("failed to settle pending transaction %d: %v", record.TransactionID, err)
+			store.Records = append(store.Records, record)
+			failed.Add([]*domain.Transaction{tx}, err)
+			continue
+		}
+		settled++
+		changed = true
+	}
+
+	if changed {
+		if err := store.Save(); err != nil {
+			warnf("%v", err)
+		}
+	}
+
+	if created > 0 || settled > 0 {
+		fmt.Printf("pending: %d uploaded, %d settled by their posted version\n", created, settled)
+	}
+	return rest, created + settled, nil
+}
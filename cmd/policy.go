@@ -0,0 +1,47 @@
+package main
+
+import (
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+)
+
+// resolveCategories turns the category slugs set by rules and policies into ariand category IDs
+func resolveCategories(backend client.CategoryLister, userID string, transactions []*domain.Transaction, warnf func(string, ...any)) {
+	needed := false
+	for _, tx := range transactions {
+		if tx.Category != "" && tx.CategoryID == nil {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return
+	}
+
+	categories, err := backend.ListCategories(userID)
+	if err != nil {
+		warnf("transactions will be uploaded uncategorized: %v", err)
+		return
+	}
+
+	ids := make(map[string]int64, len(categories))
+	for _, category := range categories {
+		ids[category.Slug] = category.Id
+	}
+
+	missing := make(map[string]bool)
+	for _, tx := range transactions {
+		if tx.Category == "" || tx.CategoryID != nil {
+			continue
+		}
+		if id, ok := ids[tx.Category]; ok {
+			tx.CategoryID = &id
+		} else {
+			missing[tx.Category] = true
+		}
+	}
+
+	for slug := range missing {
+		warnf("no category with slug %q in ariand, matching transactions are uploaded uncategorized", slug)
+	}
+}
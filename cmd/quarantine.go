@@ -0,0 +1,132 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/quarantine"
+	"arian-statement-parser/internal/source"
+	"arian-statement-parser/internal/state"
+	"arian-statement-parser/internal/validate"
+)
+
+// quarantiner sets aside the statement files an unattended run fails on, so one bad file neither
+// stops the others from importing nor fails every run after it
+type quarantiner struct {
+	dir    *quarantine.Dir
+	remote source.Source
+	store  *state.Store
+	local  string            // the watched folder of a local source, whose own files are moved
+	keys   map[string]string // synced file -> its key in the source
+	warnf  func(string, ...any)
+}
+
+// newQuarantiner opens the quarantine of a source: QUARANTINE_DIR, or else a quarantine folder in
+// the watched folder, or in the working directory for remote sources
+func newQuarantiner(remote source.Source, store *state.Store, warnf func(string, ...any)) *quarantiner {
+	q := &quarantiner{remote: remote, store: store, keys: make(map[string]string), warnf: warnf}
+	dir := "quarantine"
+	if local, ok := remote.(*source.Local); ok {
+		q.local = local.Dir()
+		dir = filepath.Join(q.local, "quarantine")
+	}
+	q.dir = quarantine.Open(cmp.Or(os.Getenv("QUARANTINE_DIR"), dir))
+	return q
+}
+
+// release puts back the files quarantined by another version of the parsers, so the sync after it
+// tries them again
+func (q *quarantiner) release() error {
+	released, err := q.dir.Release(q.remote.Name(), version, q.local)
+	keys := make([]string, 0, len(released))
+	for _, entry := range released {
+		keys = append(keys, entry.Key)
+	}
+	if err := q.store.Unmark(q.remote.Name(), keys...); err != nil {
+		return fmt.Errorf("failed to record released statements: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	if len(released) > 0 {
+		fmt.Printf("retrying %d quarantined statements with parser version %s\n", len(released), version)
+	}
+	return nil
+}
+
+// track records which synced file is which key of the source
+func (q *quarantiner) track(fetched []source.Fetched) {
+	for _, f := range fetched {
+		q.keys[filepath.Clean(f.LocalPath)] = f.Key
+	}
+}
+
+// add quarantines a synced file that failed at stage, and records it as processed so it isn't
+// synced again until it's released
+func (q *quarantiner) add(file, stage string, cause error) error {
+	key, ok := q.keys[filepath.Clean(file)]
+	if !ok {
+		return fmt.Errorf("%s: %w", filepath.Base(file), cause)
+	}
+
+	// A local source's file is moved out of the watched folder, the scratch copy goes with the run
+	path := file
+	if q.local != "" {
+		path = filepath.Join(q.local, key)
+	}
+	entry := quarantine.Entry{
+		Source:  q.remote.Name(),
+		Key:     key,
+		Stage:   stage,
+		Error:   cause.Error(),
+		Version: version,
+	}
+	if err := q.dir.Add(path, entry); err != nil {
+		return err
+	}
+	if err := q.store.MarkProcessed(q.remote.Name(), key); err != nil {
+		return fmt.Errorf("failed to record quarantined statement: %w", err)
+	}
+
+	// The Python parser's output follows its error, the sidecar keeps it
+	reason, _, _ := strings.Cut(cause.Error(), "\n")
+	q.warnf("quarantined %s in %s after its %s failed: %s", filepath.Base(path), q.dir.Path(), stage, reason)
+	return nil
+}
+
+// invalid quarantines the files with lines that failed validation, and drops all their lines. It
+// returns the transactions left and the problems of lines from no synced file.
+func (q *quarantiner) invalid(transactions []*domain.Transaction, problems []validate.Problem) ([]*domain.Transaction, []validate.Problem, error) {
+	details := make(map[string][]string)
+	var files []string
+	var rest []validate.Problem
+	for _, problem := range problems {
+		file := filepath.Clean(problem.Tx.SourceFilePath)
+		if _, ok := q.keys[file]; !ok {
+			rest = append(rest, problem)
+			continue
+		}
+		if _, ok := details[file]; !ok {
+			files = append(files, file)
+		}
+		details[file] = append(details[file], problem.String())
+	}
+
+	for _, file := range files {
+		if err := q.add(file, quarantine.StageValidation, fmt.Errorf("%d problems: %s", len(details[file]), strings.Join(details[file], "; "))); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	kept := transactions[:0]
+	for _, tx := range transactions {
+		if _, ok := details[filepath.Clean(tx.SourceFilePath)]; !ok {
+			kept = append(kept, tx)
+		}
+	}
+	return kept, rest, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/failures"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/mapping"
+	"arian-statement-parser/internal/review"
+	"arian-statement-parser/internal/rules"
+	"arian-statement-parser/internal/validate"
+	"arian-statement-parser/pkg/importer"
+)
+
+// runReviewQueue walks through the lines put aside for review, asks about each one and uploads what
+// the user settled. Lines left for later, or still invalid, stay in the queue.
+func runReviewQueue() error {
+	queue, err := review.Load(review.DefaultPath)
+	if err != nil {
+		return err
+	}
+	if len(queue.Entries) == 0 {
+		fmt.Println("nothing to review")
+		return nil
+	}
+
+	userID := os.Getenv("USER_ID")
+	if userID == "" {
+		userID = queue.UserID
+	}
+	if userID != queue.UserID {
+		return fmt.Errorf("review queue belongs to user %s, USER_ID is %s", queue.UserID, userID)
+	}
+
+	warnf := func(format string, args ...any) {
+		log.Printf("WARN: %s", fmt.Sprintf(format, args...))
+	}
+	typeMismatch, err := checkMismatchPolicy(os.Getenv("ACCOUNT_TYPE_MISMATCH"))
+	if err != nil {
+		return err
+	}
+
+	arianClient, err := dialUpload(userID)
+	if err != nil {
+		return err
+	}
+	defer arianClient.Close()
+
+	accounts, err := arianClient.GetAccounts(userID)
+	if err != nil {
+		return fmt.Errorf("get accounts failed: %w", err)
+	}
+	mappingStore, err := mapping.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to initialize mapping store: %w", err)
+	}
+	ruleSet, err := rules.NewSet()
+	if err != nil {
+		return fmt.Errorf("failed to load rules: %w", err)
+	}
+
+	fmt.Printf("%d transactions wait for review\n", len(queue.Entries))
+
+	entries := queue.Entries
+	queue.Entries = nil
+
+	resolved := make(map[string]*pb.Account)
+	var ready []*domain.Transaction
+	dropped := 0
+	for _, entry := range entries {
+		tx := entry.Transaction()
+
+		if tx.AccountID == 0 {
+			account, err := reviewAccount(arianClient, userID, tx, &accounts, mappingStore, resolved, typeMismatch, warnf)
+			if err != nil {
+				return err
+			}
+			tx.AccountID = int(account.Id)
+		}
+
+		// A missing account was the only problem with these, and the user just settled it
+		if entry.Reason != review.ReasonAccount {
+			line := fmt.Sprintf("%s (%s, %s)", describeLine(tx), sourceOf(tx), entry.Reason)
+			choice, err := askReview(tx, line, entry.Details, ruleSet)
+			if err != nil {
+				return err
+			}
+
+			switch choice {
+			case reviewSkip:
+				dropped++
+				continue
+			case reviewLater:
+				queue.Entries = append(queue.Entries, entry)
+				continue
+			case reviewEdit:
+				if err := editAmount(tx); err != nil {
+					return err
+				}
+			}
+			tx.Confidence = 1
+		}
+
+		ready = append(ready, tx)
+	}
+
+	// Rules made along the way also cover the lines that were only waiting for an account
+	ruleSet.Apply(ready)
+
+	// Settling the account or the amount may not have fixed everything that was wrong. Dates were
+	// held to their statement's period when the lines were imported.
+	if problems := validate.Check(ready, time.Now(), nil); len(problems) > 0 {
+		ready, err = handleInvalid(ready, problems, true, queue, userID, warnf)
+		if err != nil {
+			return err
+		}
+	}
+
+	resolveCategories(arianClient, userID, ready, warnf)
+	report := failures.NewReport("", userID)
+	uploads, created, err := reconcilePending(arianClient, "", userID, ready, report, warnf)
+	if err != nil {
+		return err
+	}
+
+	const batchSize = 1000
+	totalCreated := int32(created)
+	for i := 0; i < len(uploads); i += batchSize {
+		end := min(i+batchSize, len(uploads))
+		batch := uploads[i:end]
+
+		created, errors := arianClient.CreateTransactionsBulk(userID, batch)
+		totalCreated += created
+		if len(errors) > 0 {
+			report.Add(batch, errors[0])
+		}
+
+		fmt.Printf("%d/%d\n", end, len(uploads))
+	}
+
+	if err := queue.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d ok, %d failed, %d dropped, %d still waiting for review\n", totalCreated, len(report.Entries), dropped, len(queue.Entries))
+	if len(report.Entries) > 0 {
+		for _, line := range failureLines(report, accounts) {
+			fmt.Printf("  %s\n", line)
+		}
+		if err := report.Save(failures.DefaultPath); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d failed transactions written to %s, retry with: upload -retry-file %s", len(report.Entries), failures.DefaultPath, failures.DefaultPath)
+	}
+	return nil
+}
+
+// reviewAccount finds the ariand account for a queued line, asking once per statement account when
+// neither a saved mapping nor a unique name match settles it
+func reviewAccount(backend client.Uploader, userID string, tx *domain.Transaction, accounts *[]*pb.Account, mappingStore *mapping.Store, resolved map[string]*pb.Account, typeMismatch string, warnf func(string, ...any)) (*pb.Account, error) {
+	accountName := importer.AccountKey(tx)
+	if account, ok := resolved[accountName]; ok {
+		return account, nil
+	}
+
+	account := mappingStore.ResolveAccount(mappingStore.FindMapping(accountName), *accounts)
+	if account != nil {
+		if err := mappingStore.MarkUsed([]string{accountName}, time.Now()); err != nil {
+			warnf("%v", err)
+		}
+	}
+	if account == nil {
+		account = importer.MatchAccount(*accounts, accountName, tx.StatementAccountType)
+	}
+	if account == nil {
+		var err error
+		if account, err = promptAccount(backend, userID, accountName, tx, nil, accounts, mappingStore, typeMismatch, warnf); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved[accountName] = account
+	return account, nil
+}
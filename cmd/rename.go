@@ -0,0 +1,109 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"arian-statement-parser/internal/archive"
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/parser"
+)
+
+// renameOptions are the flags of rename
+type renameOptions struct {
+	pdfPath     *string
+	configPath  *string
+	institution *string
+	template    *string
+	noCache     *bool
+	dryRun      *bool
+}
+
+// renameFlags defines the flags of rename on fs
+func renameFlags(fs *flag.FlagSet) *renameOptions {
+	return &renameOptions{
+		pdfPath:     fs.String("pdf", "", "folder of statements, defaults to PDF_PATH"),
+		configPath:  fs.String("config", "", "parser config file"),
+		institution: fs.String("institution", "", "read every PDF as this bank's statement, rbc or a template's name or bank, instead of detecting it"),
+		template:    fs.String("template", "", "name template, defaults to RENAME_TEMPLATE or "+archive.DefaultNameTemplate),
+		noCache:     fs.Bool("no-cache", false, "parse every statement again instead of using the parse cache"),
+		dryRun:      fs.Bool("dry-run", false, "print the new names without renaming anything"),
+	}
+}
+
+// runRename names statement files after their bank, account and period, read by the parsers, and
+// uploads nothing
+func runRename(args []string) error {
+	fs := newFlagSet("rename")
+	opts := renameFlags(fs)
+	fs.Parse(args)
+
+	if *opts.pdfPath == "" {
+		*opts.pdfPath = os.Getenv("PDF_PATH")
+	}
+	if *opts.pdfPath == "" {
+		return fmt.Errorf("need -pdf")
+	}
+	namer, err := archive.NewNamer(cmp.Or(*opts.template, os.Getenv("RENAME_TEMPLATE")))
+	if err != nil {
+		return err
+	}
+
+	warnf := func(format string, args ...any) {
+		log.Printf("WARN: %s", fmt.Sprintf(format, args...))
+	}
+
+	// A statement the parsers can't read can't be named, but needn't keep the others from it
+	collected := archive.NewCollector()
+	statements := make(map[string]*domain.Statement)
+	err = parseEachStatement(*opts.pdfPath, *opts.configPath, *opts.institution, *opts.noCache, false, warnf, func(result *parser.ParseResult, transactions []*domain.Transaction) error {
+		collected.Add(transactions)
+		for _, file := range result.FileResults {
+			if file.Statement != nil {
+				statements[file.File] = file.Statement
+			}
+			if !file.Processed {
+				warnf("can't name %s, no transactions were read from it", filepath.Base(file.File))
+			}
+		}
+		return nil
+	}, func(file string, err error) error {
+		reason, _, _ := strings.Cut(err.Error(), "\n")
+		warnf("can't name %s: %s", filepath.Base(file), reason)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	renamed := 0
+	for _, s := range collected.Statements(statements) {
+		name, err := namer.Name(s)
+		if err != nil {
+			return err
+		}
+		from := filepath.Base(s.File)
+		if *opts.dryRun {
+			fmt.Printf("%s -> %s%s\n", from, name, strings.ToLower(filepath.Ext(s.File)))
+			continue
+		}
+
+		dst, err := archive.Rename(s, name)
+		if err != nil {
+			return err
+		}
+		if dst != s.File {
+			fmt.Printf("%s -> %s\n", from, filepath.Base(dst))
+			renamed++
+		}
+	}
+	if !*opts.dryRun {
+		fmt.Printf("renamed %d statements\n", renamed)
+	}
+	return nil
+}
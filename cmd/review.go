@@ -0,0 +1,219 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/rules"
+
+	"github.com/charmbracelet/huh"
+)
+
+const (
+	reviewUpload = "upload"
+	reviewEdit   = "edit"
+	reviewSkip   = "skip"
+	reviewLater  = "later"
+	reviewRule   = "rule"
+)
+
+// reviewLowConfidence makes the user look at every line the parser wasn't sure about before it is
+// uploaded. Lines left for later, and every such line during an unattended run, are returned
+// separately for the review queue.
+func reviewLowConfidence(transactions []*domain.Transaction, threshold float64, unattended bool, ruleSet *rules.Set, warnf func(string, ...any)) ([]*domain.Transaction, []*domain.Transaction, error) {
+	var doubtful int
+	for _, tx := range transactions {
+		if tx.Confidence < threshold {
+			doubtful++
+		}
+	}
+	if doubtful == 0 {
+		return transactions, nil, nil
+	}
+
+	fmt.Printf("\n%d transactions need review, the parser was not sure it read them right\n", doubtful)
+
+	kept := make([]*domain.Transaction, 0, len(transactions))
+	var later []*domain.Transaction
+	for _, tx := range transactions {
+		if tx.Confidence >= threshold {
+			kept = append(kept, tx)
+			continue
+		}
+
+		line := describeDoubtful(tx)
+		if unattended {
+			warnf("put low-confidence transaction %s aside for review", line)
+			later = append(later, tx)
+			continue
+		}
+
+		choice, err := askReview(tx, line, tx.ConfidenceReasons, ruleSet)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch choice {
+		case reviewSkip:
+			warnf("skipped low-confidence transaction %s", line)
+			continue
+		case reviewLater:
+			later = append(later, tx)
+			continue
+		case reviewEdit:
+			if err := editAmount(tx); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		// Reviewed by a person, so it is no longer in doubt
+		tx.Confidence = 1
+		kept = append(kept, tx)
+	}
+
+	// Rules made during review also cover the lines nobody had to look at
+	ruleSet.Apply(kept)
+
+	return kept, later, nil
+}
+
+// askReview asks what to do with a line that needs a person. Making a rule for it categorizes the
+// line and asks again, since that says nothing about whether it was read right.
+func askReview(tx *domain.Transaction, line string, reasons []string, ruleSet *rules.Set) (string, error) {
+	for {
+		description := strings.Join(reasons, "\n")
+		if tx.Category != "" {
+			description += "\ncategory: " + tx.Category
+		}
+
+		choice := reviewUpload
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Title(line).
+					Description(strings.TrimSpace(description)).
+					Options(
+						huh.NewOption("Upload as shown", reviewUpload),
+						huh.NewOption("Fix the amount first", reviewEdit),
+						huh.NewOption("Always categorize descriptions like this as...", reviewRule),
+						huh.NewOption("Skip it", reviewSkip),
+						huh.NewOption("Leave it for later", reviewLater),
+					).
+					Value(&choice),
+			),
+		)
+		if err := form.Run(); err != nil {
+			return "", fmt.Errorf("review prompt failed: %w", err)
+		}
+
+		if choice != reviewRule {
+			return choice, nil
+		}
+		if err := addRule(tx, ruleSet); err != nil {
+			return "", err
+		}
+	}
+}
+
+// addRule asks for a category and a description pattern, saves them as a rule and categorizes tx
+func addRule(tx *domain.Transaction, ruleSet *rules.Set) error {
+	category := tx.Category
+	pattern := rules.PatternFor(tx.TxDesc)
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Category").
+				Description("ariand category slug").
+				Value(&category).
+				Validate(func(s string) error {
+					if strings.TrimSpace(s) == "" {
+						return fmt.Errorf("need a category")
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Descriptions matching").
+				Description("regular expression, suggested from "+strconv.Quote(tx.TxDesc)).
+				Value(&pattern).
+				Validate(func(s string) error {
+					re, err := regexp.Compile(s)
+					if err != nil {
+						return fmt.Errorf("not a valid pattern")
+					}
+					if !re.MatchString(tx.TxDesc) {
+						return fmt.Errorf("does not match this line")
+					}
+					return nil
+				}),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return fmt.Errorf("review prompt failed: %w", err)
+	}
+
+	category = strings.TrimSpace(category)
+	if err := ruleSet.Add(rules.Rule{Description: pattern, Category: category}); err != nil {
+		return err
+	}
+	fmt.Printf("added rule: descriptions matching %s go to %s\n", pattern, category)
+
+	tx.Category = category
+	tx.CategoryID = nil
+	return nil
+}
+
+// editAmount asks for the signed amount as it should appear, negative for money out
+func editAmount(tx *domain.Transaction) error {
+	signed := tx.TxAmount
+	if tx.TxDirection == domain.Out {
+		signed = -signed
+	}
+	value := strconv.FormatFloat(signed, 'f', 2, 64)
+
+	input := huh.NewInput().
+		Title("Amount").
+		Description("negative for money out").
+		Value(&value).
+		Validate(func(s string) error {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil {
+				return fmt.Errorf("not a number")
+			}
+			return nil
+		})
+	if err := huh.NewForm(huh.NewGroup(input)).Run(); err != nil {
+		return fmt.Errorf("review prompt failed: %w", err)
+	}
+
+	amount, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	tx.TxDirection = domain.In
+	if amount < 0 {
+		tx.TxDirection = domain.Out
+		amount = -amount
+	}
+	tx.TxAmount = amount
+	return nil
+}
+
+func describeDoubtful(tx *domain.Transaction) string {
+	return fmt.Sprintf("%s (%s, confidence %.0f%%)", describeLine(tx), sourceOf(tx), tx.Confidence*100)
+}
+
+// sourceOf names the file a line was read from, with its page and line when the parser knows them
+func sourceOf(tx *domain.Transaction) string {
+	return cmp.Or(tx.Source(), filepath.Base(tx.SourceFilePath))
+}
+
+func describeLine(tx *domain.Transaction) string {
+	sign := ""
+	if tx.TxDirection == domain.Out {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s %s%.2f %s %q", tx.TxDate.Format(time.DateOnly), sign, tx.TxAmount, tx.TxCurrency, tx.TxDesc)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"arian-statement-parser/internal/update"
+)
+
+// version is the release this binary was built from, set with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// selfUpdateOptions are the flags of self-update
+type selfUpdateOptions struct {
+	check    *bool
+	force    *bool
+	insecure *bool
+}
+
+// selfUpdateFlags defines the flags of self-update on fs
+func selfUpdateFlags(fs *flag.FlagSet) *selfUpdateOptions {
+	return &selfUpdateOptions{
+		check:    fs.Bool("check", false, "only say whether a newer release is out"),
+		force:    fs.Bool("force", false, "install the latest release even if it isn't newer, e.g. over a dev build"),
+		insecure: fs.Bool("insecure", false, "install a build without a release key after checking the checksum alone"),
+	}
+}
+
+// runSelfUpdate replaces the running binary with the latest release after checking its checksum
+// and signature
+func runSelfUpdate(args []string) error {
+	fs := newFlagSet("self-update")
+	opts := selfUpdateFlags(fs)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	updater := update.New()
+	updater.Insecure = *opts.insecure
+
+	release, err := updater.Latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	newer := update.Newer(release.Tag, version)
+	if !newer && !*opts.force {
+		if version == "dev" {
+			fmt.Printf("this is a development build, the latest release is %s; use -force to install it\n", release.Tag)
+		} else {
+			fmt.Printf("%s is the latest release\n", version)
+		}
+		return nil
+	}
+	if *opts.check {
+		fmt.Printf("%s is out, this is %s\n", release.Tag, version)
+		return nil
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find this binary: %w", err)
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return fmt.Errorf("failed to find this binary: %w", err)
+	}
+
+	data, err := updater.Download(ctx, release)
+	if errors.Is(err, update.ErrNoAsset) {
+		return fmt.Errorf("%w; build it from source with go build -o %s ./cmd", err, completionName)
+	}
+	if errors.Is(err, update.ErrNoKey) {
+		return fmt.Errorf("%w; install a release build, or use -insecure to trust the checksum alone", err)
+	}
+	if err != nil {
+		return err
+	}
+	if updater.PublicKey == "" {
+		fmt.Fprintln(os.Stderr, "warning: -insecure, only the checksum was verified")
+	}
+
+	if err := update.Replace(path, data); err != nil {
+		return err
+	}
+	fmt.Printf("updated %s from %s to %s\n", path, version, release.Tag)
+	return nil
+}
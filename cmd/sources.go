@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"arian-statement-parser/internal/source"
+)
+
+// newSource builds a remote statement source from environment settings
+func newSource(kind string) (source.Source, error) {
+	switch kind {
+	case "s3":
+		return source.NewS3(source.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Prefix:          os.Getenv("S3_PREFIX"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	case "sftp":
+		return source.NewSFTP(source.SFTPConfig{
+			Host:         os.Getenv("SFTP_HOST"),
+			Port:         os.Getenv("SFTP_PORT"),
+			User:         os.Getenv("SFTP_USER"),
+			Dir:          os.Getenv("SFTP_DIR"),
+			IdentityFile: os.Getenv("SFTP_IDENTITY_FILE"),
+		})
+	case "webdav":
+		return source.NewWebDAV(source.WebDAVConfig{
+			URL:      os.Getenv("WEBDAV_URL"),
+			Username: os.Getenv("WEBDAV_USERNAME"),
+			Password: os.Getenv("WEBDAV_PASSWORD"),
+		})
+	case "gdrive":
+		return source.NewGoogleDrive(os.Getenv("GDRIVE_FOLDER_ID"), os.Getenv("GDRIVE_CLIENT_ID"), os.Getenv("GDRIVE_CLIENT_SECRET"))
+	case "dropbox":
+		return source.NewDropbox(os.Getenv("DROPBOX_FOLDER"), os.Getenv("DROPBOX_APP_KEY"), os.Getenv("DROPBOX_APP_SECRET"))
+	default:
+		return nil, fmt.Errorf("unknown source %q", kind)
+	}
+}
+
+// loginSource authorizes a cloud source once and keeps its refresh token in the keyring
+func loginSource(kind string) error {
+	var cfg source.OAuthConfig
+	switch kind {
+	case "gdrive":
+		cfg = source.GoogleDriveOAuth(os.Getenv("GDRIVE_CLIENT_ID"), os.Getenv("GDRIVE_CLIENT_SECRET"))
+	case "dropbox":
+		cfg = source.DropboxOAuth(os.Getenv("DROPBOX_APP_KEY"), os.Getenv("DROPBOX_APP_SECRET"))
+	default:
+		return fmt.Errorf("login not supported for %q", kind)
+	}
+
+	return source.Login(context.Background(), cfg)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"arian-statement-parser/internal/dedupe"
+	"arian-statement-parser/internal/enrich"
+	"arian-statement-parser/internal/mapping"
+	"arian-statement-parser/internal/rules"
+	"arian-statement-parser/internal/spending"
+	"arian-statement-parser/pkg/importer"
+)
+
+// spendingOptions are the flags of report
+type spendingOptions struct {
+	pdfPath        *string
+	configPath     *string
+	institution    *string
+	noCache        *bool
+	includePending *bool
+	merchants      *int
+	asJSON         *bool
+}
+
+// spendingFlags defines the flags of report on fs
+func spendingFlags(fs *flag.FlagSet) *spendingOptions {
+	return &spendingOptions{
+		pdfPath:        fs.String("pdf", "", "folder of statements, defaults to PDF_PATH"),
+		configPath:     fs.String("config", "", "parser config file"),
+		institution:    fs.String("institution", "", "read every PDF as this bank's statement, rbc or a template's name or bank, instead of detecting it"),
+		noCache:        fs.Bool("no-cache", false, "parse every statement again instead of using the parse cache"),
+		includePending: fs.Bool("include-pending", false, "count transactions the bank hasn't posted yet"),
+		merchants:      fs.Int("merchants", 5, "how many merchants to list per month"),
+		asJSON:         fs.Bool("json", false, "print JSON instead of tables"),
+	}
+}
+
+// runSpending parses statements without uploading anything and prints what was spent each month,
+// by category and by merchant, as a sanity check before an import
+func runSpending(args []string) error {
+	fs := newFlagSet("report")
+	opts := spendingFlags(fs)
+	fs.Parse(args)
+
+	if *opts.pdfPath == "" {
+		*opts.pdfPath = os.Getenv("PDF_PATH")
+	}
+	if *opts.pdfPath == "" {
+		return fmt.Errorf("need -pdf")
+	}
+
+	warnf := func(format string, args ...any) {
+		log.Printf("WARN: %s", fmt.Sprintf(format, args...))
+	}
+
+	_, transactions, err := parseStatements(*opts.pdfPath, *opts.configPath, *opts.institution, *opts.noCache, false, warnf)
+	if err != nil {
+		return err
+	}
+
+	// Categorize the way an import would, so the summary shows what ariand is about to get
+	if !*opts.includePending {
+		transactions, _ = importer.DropPending(transactions)
+	}
+	policy, err := importer.CheckCardPaymentPolicy(os.Getenv("CARD_PAYMENT_POLICY"))
+	if err != nil {
+		return err
+	}
+	transferCategory := os.Getenv("CARD_PAYMENT_CATEGORY")
+	if transferCategory == "" {
+		transferCategory = "transfer"
+	}
+	transactions, _ = importer.ApplyCardPaymentPolicy(transactions, policy, transferCategory)
+
+	ruleSet, err := rules.NewSet()
+	if err != nil {
+		return fmt.Errorf("failed to load rules: %w", err)
+	}
+	ruleSet.Apply(transactions)
+
+	// Merchant names cleaned up by earlier imports are used as they are, nothing is sent anywhere
+	if cache, err := enrich.LoadCache(enrich.DefaultCachePath); err != nil {
+		warnf("%v", err)
+	} else {
+		cache.Apply(transactions)
+	}
+	if data := os.Getenv("MERCHANT_DATA"); data != "" {
+		directory, err := loadMerchantDirectory(data)
+		if err != nil {
+			return err
+		}
+		directory.Apply(transactions)
+	}
+
+	mappingStore, err := mapping.NewStore()
+	if err != nil {
+		return fmt.Errorf("failed to initialize mapping store: %w", err)
+	}
+	for _, tx := range transactions {
+		if err := mappingStore.Apply(importer.AccountKey(tx), tx, nil, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	transactions, _ = dedupe.Collapse(transactions)
+
+	months := spending.Summarize(transactions, map[string]bool{transferCategory: true})
+	for i := range months {
+		months[i].Merchants = months[i].Merchants[:min(len(months[i].Merchants), *opts.merchants)]
+	}
+
+	if *opts.asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(months)
+	}
+
+	if len(months) == 0 {
+		fmt.Println("no transactions")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, month := range months {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s %s\tout %.2f\tin %.2f\n", month.Month, month.Currency, month.Out, month.In)
+		for _, total := range month.Categories {
+			fmt.Fprintf(w, "  %s\t%.2f\t%d\n", total.Name, total.Amount, total.Count)
+		}
+		if len(month.Merchants) > 0 {
+			fmt.Fprintf(w, "  top merchants\t\t\n")
+			for _, total := range month.Merchants {
+				fmt.Fprintf(w, "    %s\t%.2f\t%d\n", total.Name, total.Amount, total.Count)
+			}
+		}
+	}
+	return w.Flush()
+}
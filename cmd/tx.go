@@ -0,0 +1,187 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/rules"
+	"arian-statement-parser/pkg/importer"
+)
+
+// txOptions are the flags of tx add
+type txOptions struct {
+	account  *string
+	date     *string
+	category *string
+	currency *string
+	notes    *string
+	create   *bool
+}
+
+// txFlags defines the flags of tx add on fs
+func txFlags(fs *flag.FlagSet) *txOptions {
+	return &txOptions{
+		account:  fs.String("account", "", "ariand account to add the line to, defaults to TX_ACCOUNT or Cash"),
+		date:     fs.String("date", "", "day it happened, YYYY-MM-DD or yesterday, defaults to today"),
+		category: fs.String("category", "", "ariand category slug, else the rules in arian-rules.json decide"),
+		currency: fs.String("currency", "", "currency of the amount, defaults to the account's"),
+		notes:    fs.String("notes", "", "notes to keep with the line"),
+		create:   fs.Bool("create", false, "create the account when ariand has none by that name"),
+	}
+}
+
+// runTx handles "tx add", which logs a line by hand, like cash spending no statement will show
+func runTx(args []string) error {
+	if len(args) == 0 || args[0] != "add" {
+		return fmt.Errorf("usage: arian-statement-parser tx add [flags] <amount> <description>")
+	}
+	fs := newFlagSet("tx")
+	opts := txFlags(fs)
+
+	// Flags may come before or after the amount and description
+	var positional []string
+	for rest := args[1:]; ; {
+		fs.Parse(rest)
+		rest = fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		rest = rest[1:]
+	}
+	if len(positional) < 2 {
+		return fmt.Errorf("usage: arian-statement-parser tx add [flags] <amount> <description>")
+	}
+
+	amount, direction, err := parseEntryAmount(positional[0])
+	if err != nil {
+		return err
+	}
+	date, err := parseEntryDate(*opts.date, time.Now())
+	if err != nil {
+		return err
+	}
+	length, err := descriptionMax()
+	if err != nil {
+		return err
+	}
+	accents, err := importer.CheckAccentPolicy(os.Getenv("DESCRIPTION_ACCENTS"))
+	if err != nil {
+		return err
+	}
+
+	userID := os.Getenv("USER_ID")
+	if userID == "" {
+		return fmt.Errorf("need USER_ID")
+	}
+	arianClient, err := dialUpload(userID)
+	if err != nil {
+		return err
+	}
+	defer arianClient.Close()
+
+	name := cmp.Or(*opts.account, os.Getenv("TX_ACCOUNT"), "Cash")
+	accounts, err := arianClient.GetAccounts(userID)
+	if err != nil {
+		return fmt.Errorf("get accounts failed: %w", err)
+	}
+	var account *pb.Account
+	for _, a := range accounts {
+		if strings.EqualFold(a.Name, name) {
+			account = a
+			break
+		}
+	}
+	if account == nil {
+		if !*opts.create {
+			return fmt.Errorf("no account named %q in ariand, pass -create to add it", name)
+		}
+		account, err = arianClient.CreateAccount(userID, name, name, pb.AccountType_ACCOUNT_OTHER, cmp.Or(strings.ToUpper(*opts.currency), "CAD"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created account %s\n", account.Name)
+	}
+
+	tx := &domain.Transaction{
+		AccountID:   int(account.Id),
+		TxDate:      date,
+		TxAmount:    amount,
+		TxCurrency:  cmp.Or(strings.ToUpper(*opts.currency), account.MainCurrency, "CAD"),
+		TxDirection: direction,
+		TxDesc:      strings.Join(positional[1:], " "),
+		UserNotes:   *opts.notes,
+		Category:    *opts.category,
+		Confidence:  1,
+	}
+
+	ruleSet, err := rules.NewSet()
+	if err != nil {
+		return err
+	}
+	ruleSet.Apply([]*domain.Transaction{tx})
+	// As in an import, rules see the description as typed
+	if accents == importer.AccentsASCII {
+		tx.FoldAccents()
+	}
+	tx.CleanDescription(length)
+	warnf := func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", fmt.Sprintf(format, args...))
+	}
+	resolveCategories(arianClient, userID, []*domain.Transaction{tx}, warnf)
+
+	id, err := arianClient.CreateTransactionWithID(userID, tx)
+	if err != nil {
+		return err
+	}
+
+	sign := "-"
+	if direction == domain.In {
+		sign = "+"
+	}
+	category := ""
+	if tx.CategoryID != nil {
+		category = ", " + tx.Category
+	}
+	fmt.Printf("added %s %s%.2f %s %q to %s (id %d%s)\n", date.Format("2006-01-02"), sign, amount, tx.TxCurrency, tx.TxDesc, account.Name, id, category)
+	return nil
+}
+
+// parseEntryAmount reads an amount typed by hand. A plain amount was spent, one with a + in front
+// came in.
+func parseEntryAmount(raw string) (float64, domain.Direction, error) {
+	direction := domain.Out
+	value := strings.TrimSpace(raw)
+	if rest, ok := strings.CutPrefix(value, "+"); ok {
+		direction, value = domain.In, rest
+	}
+	value = strings.NewReplacer("$", "", ",", "").Replace(value)
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount <= 0 {
+		return 0, direction, fmt.Errorf("invalid amount %q, want e.g. 12.50, or +20 for money in", raw)
+	}
+	return amount, direction, nil
+}
+
+// parseEntryDate reads the day of a line typed by hand, today when raw is empty
+func parseEntryDate(raw string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(raw))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, want YYYY-MM-DD", raw)
+	}
+	return date, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/failures"
+	"arian-statement-parser/pkg/importer"
+)
+
+// uploadOptions are the flags of upload
+type uploadOptions struct {
+	retryFile   *string
+	reviewQueue *bool
+}
+
+// uploadFlags defines the flags of upload on fs
+func uploadFlags(fs *flag.FlagSet) *uploadOptions {
+	return &uploadOptions{
+		retryFile:   fs.String("retry-file", "", "error report of a failed import to upload again, e.g. errors.json"),
+		reviewQueue: fs.Bool("review", false, "go through the review queue and upload what you approve"),
+	}
+}
+
+// runUpload re-sends transactions from an error report, or the ones waiting for review, without
+// parsing anything again
+func runUpload(args []string) error {
+	fs := newFlagSet("upload")
+	opts := uploadFlags(fs)
+	fs.Parse(args)
+
+	if *opts.reviewQueue {
+		return runReviewQueue()
+	}
+	if *opts.retryFile == "" {
+		return fmt.Errorf("need -retry-file or -review")
+	}
+
+	report, err := failures.Load(*opts.retryFile)
+	if err != nil {
+		return err
+	}
+	if len(report.Entries) == 0 {
+		fmt.Println("nothing to retry")
+		return nil
+	}
+
+	userID := os.Getenv("USER_ID")
+	if userID == "" {
+		userID = report.UserID
+	}
+	if userID != report.UserID {
+		return fmt.Errorf("report belongs to user %s, USER_ID is %s", report.UserID, userID)
+	}
+
+	arianClient, err := dialUpload(userID)
+	if err != nil {
+		return err
+	}
+	defer arianClient.Close()
+
+	warnf := func(format string, args ...any) {
+		log.Printf("WARN: %s", fmt.Sprintf(format, args...))
+	}
+
+	// A call that timed out or lost its connection may have been carried out, so those lines are
+	// only sent again when ariand doesn't have them yet
+	var sure, unsure []*domain.Transaction
+	entryOf := make(map[*domain.Transaction]failures.Entry, len(report.Entries))
+	for i, tx := range report.Transactions() {
+		entryOf[tx] = report.Entries[i]
+		if report.Entries[i].MaybeCreated() {
+			unsure = append(unsure, tx)
+		} else {
+			sure = append(sure, tx)
+		}
+	}
+	var held []*domain.Transaction
+	existing := 0
+	if len(unsure) > 0 {
+		missing, err := importer.LeaveOutExisting(arianClient, userID, unsure)
+		if err != nil {
+			warnf("%d transactions may already be in ariand and are left in %s: %v", len(unsure), *opts.retryFile, err)
+			held = unsure
+		} else {
+			existing = len(unsure) - len(missing)
+			sure = append(sure, missing...)
+		}
+	}
+
+	// Pending lines and the posted lines that settle them go through the pending records again, so
+	// a retry doesn't add a second copy
+	remaining := failures.NewReport(report.RunID, userID)
+	transactions, reconciled, err := reconcilePending(arianClient, "", userID, sure, remaining, warnf)
+	if err != nil {
+		return err
+	}
+
+	const batchSize = 1000
+	totalCreated := int32(reconciled)
+	for i := 0; i < len(transactions); i += batchSize {
+		end := min(i+batchSize, len(transactions))
+		batch := transactions[i:end]
+
+		created, errors := arianClient.CreateTransactionsBulk(userID, batch)
+		totalCreated += created
+		if len(errors) > 0 {
+			remaining.Add(batch, errors[0])
+		}
+
+		fmt.Printf("%d/%d\n", end, len(transactions))
+	}
+
+	fmt.Printf("\n%d ok, %d already in ariand, %d failed\n", totalCreated, existing, len(remaining.Entries))
+	if len(remaining.Entries) > 0 {
+		// Names only make the breakdown readable, so a failed lookup falls back to IDs
+		accounts, _ := arianClient.GetAccounts(userID)
+		for _, line := range failureLines(remaining, accounts) {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	// Only the lines that went through leave the file, so the same command can be run again and
+	// failures another run added meanwhile stay
+	failing := failures.NewReport("", userID)
+	failing.Entries = remaining.Entries
+	for _, tx := range held {
+		failing.Entries = append(failing.Entries, entryOf[tx])
+	}
+	var done []failures.Entry
+	for _, e := range report.Entries {
+		if !failing.Has(e) {
+			done = append(done, e)
+		}
+	}
+	if err := failures.Settle(*opts.retryFile, done); err != nil {
+		return err
+	}
+	if len(failing.Entries) == 0 {
+		fmt.Printf("everything uploaded, cleared from %s\n", *opts.retryFile)
+		return nil
+	}
+	if err := remaining.Save(*opts.retryFile); err != nil {
+		return err
+	}
+	return fmt.Errorf("%d transactions still failing, see %s", len(failing.Entries), *opts.retryFile)
+}
+
+// dialUpload connects to ariand from the environment and checks the user exists
+func dialUpload(userID string) (*client.Client, error) {
+	serverURL := os.Getenv("ARIAND_URL")
+	if serverURL == "" {
+		return nil, fmt.Errorf("need ARIAND_URL")
+	}
+
+	keySource := apiKeySource()
+	apiKey, err := loadAPIKey(keySource)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := clientSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	arianClient, err := client.NewClientWithSettings(serverURL, apiKey, settings)
+	if err != nil {
+		return nil, fmt.Errorf("client failed: %w", err)
+	}
+	arianClient.SetKeySource(keySource)
+
+	if _, err := arianClient.Preflight(userID); err != nil {
+		arianClient.Close()
+		return nil, preflightError(err, serverURL)
+	}
+	return arianClient, nil
+}
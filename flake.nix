@@ -50,7 +50,7 @@
           '')
 
           (writeShellScriptBin "run" ''
-            go run cmd/main.go
+            go run ./cmd
           '')
 
           (writeShellScriptBin "fmt" ''
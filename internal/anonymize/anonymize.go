@@ -0,0 +1,291 @@
+package anonymize
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/parser"
+)
+
+// keepWords are banking terms that drive parsing and categorization, so they survive scrambling
+var keepWords = map[string]bool{
+	"PAYMENT": true, "THANK": true, "YOU": true, "PAIEMENT": true, "MERCI": true,
+	"DEPOSIT": true, "PAYROLL": true, "TRANSFER": true, "E-TRANSFER": true, "SENT": true,
+	"RECEIVED": true, "CHEQUE": true, "ATM": true, "WITHDRAWAL": true, "INTEREST": true,
+	"FEE": true, "FEES": true, "MONTHLY": true, "REFUND": true, "RETURN": true,
+	"PURCHASE": true, "ONLINE": true, "BANKING": true, "BILL": true, "PAY": true,
+	"INTERAC": true, "CONTACTLESS": true, "VISA": true, "DEBIT": true, "CREDIT": true,
+	"BALANCE": true, "PREVIOUS": true, "TOTAL": true, "CASH": true, "ADVANCE": true,
+	"ON": true, "QC": true, "BC": true, "AB": true, "MB": true, "NS": true, "NB": true,
+	"SK": true, "PE": true, "NL": true, "CA": true, "USD": true, "CAD": true, "-": true, "/": true,
+}
+
+// Anonymizer scrambles identifying details of a parse result deterministically for a given seed
+type Anonymizer struct {
+	seed uint64
+}
+
+// New makes an anonymizer. Anyone who knows the seed can check guesses at the original words
+// against the fixture, so it should be random unless a fixture has to be made again.
+func New(seed uint64) *Anonymizer {
+	return &Anonymizer{seed: seed}
+}
+
+// Apply returns a copy of result that only holds what is safe to share. Descriptions, merchants,
+// notes, amounts, balances, codes, account numbers and file names are replaced by fake ones of the same shape. Dates,
+// methods, categories and the other fields that say nothing about whose statement it is are copied.
+// Everything else is left out, so a field added to the parser output later is never shared before
+// it was looked at here.
+func (a *Anonymizer) Apply(result *parser.ParseResult) *parser.ParseResult {
+	out := &parser.ParseResult{Summary: result.Summary}
+
+	files := make(map[string]string)
+	fileName := func(path string) string {
+		if name, ok := files[path]; ok {
+			return name
+		}
+		name := fmt.Sprintf("/statements/statement-%d%s", len(files)+1, strings.ToLower(filepath.Ext(path)))
+		files[path] = name
+		return name
+	}
+
+	running := make(map[string]*runningBalance)
+	for i, tx := range result.Transactions {
+		amount := a.shiftAmount(tx.Amount, i)
+		safe := parser.PythonTransaction{
+			Date:         tx.Date,
+			Amount:       amount,
+			Method:       tx.Method,
+			Category:     tx.Category,
+			Description:  a.scrambleText(tx.Description),
+			PostingDate:  tx.PostingDate,
+			AccountType:  tx.AccountType,
+			SourceFile:   fileName(tx.SourceFile),
+			Pending:      tx.Pending,
+			Confidence:   tx.Confidence,
+			Currency:     tx.Currency,
+			Bank:         tx.Bank,
+			BankCategory: tx.BankCategory,
+			CategorySlug: tx.CategorySlug,
+			Page:         tx.Page,
+			Line:         tx.Line,
+		}
+		balance := running[tx.SourceFile]
+		if balance == nil {
+			balance = &runningBalance{}
+			running[tx.SourceFile] = balance
+		}
+		safe.Balance = balance.next(a, tx.SourceFile, tx.Amount, amount, tx.Balance)
+		safe.Notes = a.scrambleText(tx.Notes)
+		safe.Merchant = a.scrambleText(tx.Merchant)
+		if tx.OriginalAmount != nil {
+			// Scaled like the amount, so the exchange rate stays plausible
+			original := a.shiftAmount(*tx.OriginalAmount, i)
+			if tx.Amount != 0 {
+				original = math.Round(*tx.OriginalAmount*amount/tx.Amount*100) / 100
+			}
+			safe.OriginalAmount = &original
+			safe.OriginalCurrency = tx.OriginalCurrency
+		}
+		if tx.Code != nil {
+			code := a.scrambleDigits(*tx.Code)
+			safe.Code = &code
+		}
+		if tx.AccountNumber != nil {
+			number := a.scrambleDigits(*tx.AccountNumber)
+			safe.AccountNumber = &number
+		}
+
+		out.Transactions = append(out.Transactions, safe)
+	}
+
+	for _, fr := range result.FileResults {
+		safe := parser.FileResult{
+			File:             fileName(fr.File),
+			TransactionCount: fr.TransactionCount,
+			Processed:        fr.Processed,
+			Format:           fr.Format,
+			Institution:      fr.Institution,
+			SummaryLines:     fr.SummaryLines,
+			Text:             a.scrambleLayout(fr.Text),
+		}
+		if fr.Statement != nil {
+			safe.Statement = &domain.Statement{ClosingDate: fr.Statement.ClosingDate}
+		}
+		// Reasons quote the line they couldn't read
+		for _, skipped := range fr.SkippedLines {
+			skipped.Reason = a.scrambleText(skipped.Reason)
+			safe.SkippedLines = append(safe.SkippedLines, skipped)
+		}
+		out.FileResults = append(out.FileResults, safe)
+	}
+
+	return out
+}
+
+// scrambleText swaps every word outside the keep list for a pseudo-word of the same shape
+func (a *Anonymizer) scrambleText(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		if keepWords[strings.ToUpper(word)] {
+			continue
+		}
+		words[i] = a.scrambleWord(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// scrambleLayout scrambles text the way scrambleText does, but keeps its spacing and line breaks,
+// the layout templates read, along with month names, days and years, so dates still parse
+func (a *Anonymizer) scrambleLayout(text string) string {
+	var b strings.Builder
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := text[start:end]
+		if keepWords[strings.ToUpper(word)] || keepDate(word) {
+			b.WriteString(word)
+		} else {
+			b.WriteString(a.scrambleWord(word))
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			flush(i)
+			b.WriteRune(r)
+		} else if start < 0 {
+			start = i
+		}
+	}
+	flush(len(text))
+	return b.String()
+}
+
+// months are the month names and abbreviations of English and French statements
+var months = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(`JANUARY FEBRUARY MARCH APRIL MAY JUNE JULY AUGUST SEPTEMBER OCTOBER
+		NOVEMBER DECEMBER JANVIER FÉVRIER MARS AVRIL MAI JUIN JUILLET AOÛT SEPTEMBRE OCTOBRE NOVEMBRE
+		DÉCEMBRE JAN FEB MAR APR JUN JUL AUG SEP SEPT OCT NOV DEC JANV FÉVR AVR JUIL DÉC`) {
+		months[name] = true
+	}
+}
+
+// keepDate reports whether word is a month name, a day or a year, which say little on their own
+func keepDate(word string) bool {
+	word = strings.TrimRight(word, ".,")
+	if months[strings.ToUpper(word)] {
+		return true
+	}
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return len(word) == 1 || len(word) == 2 || (len(word) == 4 && (strings.HasPrefix(word, "19") || strings.HasPrefix(word, "20")))
+}
+
+// scrambleWord keeps length, case, digits-vs-letters and punctuation, but not the content
+func (a *Anonymizer) scrambleWord(word string) string {
+	rng := a.rngFor(word)
+
+	var b strings.Builder
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			b.WriteRune(rune('A' + rng.IntN(26)))
+		case unicode.IsLower(r):
+			b.WriteRune(rune('a' + rng.IntN(26)))
+		case unicode.IsDigit(r):
+			b.WriteRune(rune('0' + rng.IntN(10)))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// scrambleDigits replaces digits but keeps separators so number formats stay recognizable
+func (a *Anonymizer) scrambleDigits(value string) string {
+	rng := a.rngFor(value)
+
+	var b strings.Builder
+	for _, r := range value {
+		if unicode.IsDigit(r) {
+			b.WriteRune(rune('0' + rng.IntN(10)))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// shiftAmount scales an amount by 50-150%, keeping its sign and cent precision
+func (a *Anonymizer) shiftAmount(amount float64, index int) float64 {
+	if amount == 0 {
+		return 0
+	}
+
+	rng := a.rngFor(fmt.Sprintf("amount-%d-%f", index, amount))
+	factor := 0.5 + rng.Float64()
+
+	shifted := math.Round(amount*factor*100) / 100
+	if shifted == 0 {
+		shifted = math.Copysign(0.01, amount)
+	}
+	return shifted
+}
+
+// runningBalance shifts the balances of one file so they still add up with the shifted amounts
+type runningBalance struct {
+	started           bool
+	factor            float64
+	original, shifted float64
+	// Amounts since the last printed balance, as statements often print one per day
+	originalSum, shiftedSum float64
+}
+
+// next takes a line's original and shifted amounts and returns its shifted balance, if it has one.
+// The first balance of a file is scaled like an amount, later ones move by the shifted amounts.
+func (r *runningBalance) next(a *Anonymizer, file string, original, shifted float64, balance *float64) *float64 {
+	r.originalSum += original
+	r.shiftedSum += shifted
+	if balance == nil {
+		return nil
+	}
+
+	var value float64
+	switch {
+	case !r.started:
+		r.factor = 0.5 + a.rngFor("balance-"+file).Float64()
+		value = *balance * r.factor
+	case r.originalSum != 0:
+		// The change is the lines' sum or its negation, by the account's sign convention
+		value = r.shifted + (*balance-r.original)*r.shiftedSum/r.originalSum
+	default:
+		value = r.shifted + (*balance-r.original)*r.factor
+	}
+	value = math.Round(value*100) / 100
+
+	r.started = true
+	r.original, r.shifted = *balance, value
+	r.originalSum, r.shiftedSum = 0, 0
+	return &value
+}
+
+// rngFor derives a generator from the seed and value, so equal inputs scramble identically
+func (a *Anonymizer) rngFor(value string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	return rand.New(rand.NewPCG(a.seed, h.Sum64()))
+}
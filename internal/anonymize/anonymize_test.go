@@ -0,0 +1,102 @@
+package anonymize
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/parser"
+)
+
+func TestApplyLeavesNothingBehind(t *testing.T) {
+	code, number, limit, owed, foreign := "REF884213", "4510 1234 5678 9012", 7300.0, 4481.2, 91.37
+	result := &parser.ParseResult{
+		Transactions: []parser.PythonTransaction{{
+			Date:              "2024-03-05",
+			Amount:            -123.45,
+			Method:            "pos",
+			Description:       "GROCERIA MARCHAND TORONTO",
+			Code:              &code,
+			AccountNumber:     &number,
+			AccountType:       "visa",
+			AccountName:       "JANE Q CARDHOLDER",
+			SourceFile:        "/home/jane/Downloads/jane-visa-march.pdf",
+			Balance:           &owed,
+			Notes:             "split with JANE",
+			Merchant:          "Groceria Marchand",
+			OriginalAmount:    &foreign,
+			OriginalCurrency:  "USD",
+			ConfidenceReasons: []string{"balance 4,481.20 does not add up"},
+		}},
+		FileResults: []parser.FileResult{{
+			File:         "/home/jane/Downloads/jane-visa-march.pdf",
+			Text:         "JANE Q CARDHOLDER\n\nMAR 05   GROCERIA MARCHAND    123.45\n  PREVIOUS BALANCE  4,481.20",
+			Statement:    &domain.Statement{ClosingDate: "2024-03-26", CreditLimit: &limit},
+			SkippedLines: []parser.SkippedLine{{Line: 4, Reason: "no amount in GROCERIA MARCHAND"}},
+		}},
+	}
+
+	fixture := New(42).Apply(result)
+	data, err := json.Marshal(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"GROCERIA", "MARCHAND", "884213", "9012", "JANE", "jane", "123.45", "4,481.20", "4481.2", "7300", "Groceria", "91.37"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("fixture still holds %q: %s", secret, data)
+		}
+	}
+
+	tx := fixture.Transactions[0]
+	if tx.Date != "2024-03-05" || tx.Method != "pos" || tx.AccountType != "visa" || tx.Amount >= 0 {
+		t.Errorf("fixture lost what the parser needs: %+v", tx)
+	}
+	if rate, want := *tx.OriginalAmount/tx.Amount, 91.37/-123.45; tx.OriginalCurrency != "USD" || math.Abs(rate-want) > 0.001 {
+		t.Errorf("original amount = %v %s, rate %.4f, want %.4f", *tx.OriginalAmount, tx.OriginalCurrency, rate, want)
+	}
+	if fixture.FileResults[0].Statement.ClosingDate != "2024-03-26" {
+		t.Errorf("statement = %+v", fixture.FileResults[0].Statement)
+	}
+
+	text := fixture.FileResults[0].Text
+	if lines := strings.Split(text, "\n"); len(lines) != 4 || !strings.HasPrefix(lines[2], "MAR 05   ") || !strings.HasPrefix(lines[3], "  PREVIOUS BALANCE  ") {
+		t.Errorf("text lost its layout: %q", text)
+	}
+
+	// The same seed gives the same fixture, another one doesn't
+	if again := New(42).Apply(result); again.Transactions[0].Description != tx.Description {
+		t.Error("the same seed scrambled differently")
+	}
+	if other := New(43).Apply(result); other.Transactions[0].Description == tx.Description {
+		t.Error("another seed scrambled the same")
+	}
+}
+
+func TestApplyKeepsBalancesAddingUp(t *testing.T) {
+	balance := func(value float64) *float64 { return &value }
+	// A chequing account, where debits lower the balance, with two lines on the 6th
+	result := &parser.ParseResult{Transactions: []parser.PythonTransaction{
+		{Date: "2024-03-05", Amount: -40.10, Description: "GROCERIA", SourceFile: "a.pdf", Balance: balance(1959.90)},
+		{Date: "2024-03-06", Amount: -12.25, Description: "CAFE", SourceFile: "a.pdf"},
+		{Date: "2024-03-06", Amount: 1500, Description: "PAYROLL", SourceFile: "a.pdf", Balance: balance(3447.65)},
+		{Date: "2024-03-07", Amount: -80, Description: "PHARMACIE", SourceFile: "a.pdf", Balance: balance(3367.65)},
+	}}
+
+	fixture := New(42).Apply(result).Transactions
+	for i, tx := range fixture {
+		if (tx.Balance == nil) != (result.Transactions[i].Balance == nil) {
+			t.Fatalf("line %d balance = %v", i, tx.Balance)
+		}
+		if tx.Balance != nil && *tx.Balance == *result.Transactions[i].Balance {
+			t.Errorf("line %d kept its balance", i)
+		}
+	}
+	if got, want := *fixture[2].Balance-*fixture[0].Balance, fixture[1].Amount+fixture[2].Amount; math.Abs(got-want) > 0.005 {
+		t.Errorf("balance moved by %.2f, lines add up to %.2f", got, want)
+	}
+	if got, want := *fixture[3].Balance-*fixture[2].Balance, fixture[3].Amount; math.Abs(got-want) > 0.005 {
+		t.Errorf("balance moved by %.2f, line is %.2f", got, want)
+	}
+}
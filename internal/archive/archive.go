@@ -0,0 +1,188 @@
+package archive
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Statement is a statement file and what its lines say about it
+type Statement struct {
+	File        string // where the file is now
+	Institution string // the bank as the parsers name it, e.g. RBC
+	Account     string // the account's name
+	Type        string // chequing, savings, visa and so on
+	Number      string // last four digits of the account number, empty when it has none
+	// Start is the date of the first line, End the statement's closing date or else its last line's
+	Start time.Time
+	End   time.Time
+}
+
+// label names the statement's account folder: its name, and the number's last digits to tell two
+// accounts of the same kind apart
+func (s Statement) label() string {
+	return cmp.Or(clean(strings.TrimSpace(s.Account+" "+s.Number)), "Unknown")
+}
+
+// Collector gathers the statements transactions came from, in the order they're first seen
+type Collector struct {
+	statements map[string]*Statement
+	order      []string
+}
+
+func NewCollector() *Collector {
+	return &Collector{statements: make(map[string]*Statement)}
+}
+
+// Add records the statement each line came from. A file with several accounts is filed under the
+// account of its first line.
+func (c *Collector) Add(transactions []*domain.Transaction) {
+	for _, tx := range transactions {
+		s, ok := c.statements[tx.SourceFilePath]
+		if !ok {
+			s = &Statement{
+				File:        tx.SourceFilePath,
+				Institution: tx.StatementBank,
+				Account:     cmp.Or(tx.StatementAccountName, tx.StatementAccountType),
+				Type:        tx.StatementAccountType,
+				Start:       tx.TxDate,
+			}
+			if tx.StatementAccountNumber != nil {
+				number := strings.ReplaceAll(*tx.StatementAccountNumber, " ", "")
+				s.Number = number[max(0, len(number)-4):]
+			}
+			c.statements[tx.SourceFilePath] = s
+			c.order = append(c.order, tx.SourceFilePath)
+		}
+		if tx.TxDate.Before(s.Start) {
+			s.Start = tx.TxDate
+		}
+		if tx.TxDate.After(s.End) {
+			s.End = tx.TxDate
+		}
+	}
+}
+
+// Statements returns the collected statements. A card statement's closing date from closing, keyed
+// by file, is its end even when its last line is days earlier.
+func (c *Collector) Statements(closing map[string]*domain.Statement) []Statement {
+	statements := make([]Statement, 0, len(c.order))
+	for _, file := range c.order {
+		s := *c.statements[file]
+		if date, ok := closing[file].Closing(); ok {
+			s.End = date
+		}
+		statements = append(statements, s)
+	}
+	return statements
+}
+
+// unsafeChars can't be in a folder or file name on some system the archive may be synced to
+var unsafeChars = strings.NewReplacer("/", "-", `\`, "-", ":", "-", "*", "-", "?", "-", `"`, "-", "<", "-", ">", "-", "|", "-")
+
+// clean makes a name safe to use as a folder or file name
+func clean(name string) string {
+	return strings.Trim(unsafeChars.Replace(strings.TrimSpace(name)), ". ")
+}
+
+// Path returns where a statement goes under root: Institution/Account/YYYY/, named name with the
+// file's extension, or keeping its file name when name is empty
+func Path(root string, s Statement, name string) string {
+	file := filepath.Base(s.File)
+	if name != "" {
+		file = name + strings.ToLower(filepath.Ext(s.File))
+	}
+	return filepath.Join(root, cmp.Or(clean(s.Institution), "Unknown"), s.label(), s.End.Format("2006"), file)
+}
+
+// Move files a statement in the archive under root, named as Path says, and returns where it went
+func Move(root string, s Statement, name string) (string, error) {
+	dst := Path(root, s, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create archive folder: %w", err)
+	}
+	return place(s.File, dst)
+}
+
+// Rename names a statement name, with its extension, in the folder it is in and returns its new path
+func Rename(s Statement, name string) (string, error) {
+	return place(s.File, filepath.Join(filepath.Dir(s.File), name+strings.ToLower(filepath.Ext(s.File))))
+}
+
+// place moves src to dst. When a file is already at dst, src is only removed if it has the same
+// bytes, else it goes under a numbered name.
+func place(src, dst string) (string, error) {
+	ext := filepath.Ext(dst)
+	base := strings.TrimSuffix(dst, ext)
+	for n := 2; ; n++ {
+		// Renaming a file to the numbered name it already has
+		if dst == src {
+			return dst, nil
+		}
+		same, err := sameFile(src, dst)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to move %s: %w", filepath.Base(src), err)
+		}
+		if same {
+			return dst, os.Remove(src)
+		}
+		dst = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+
+	if err := move(src, dst); err != nil {
+		return "", fmt.Errorf("failed to move %s: %w", filepath.Base(src), err)
+	}
+	return dst, nil
+}
+
+// sameFile reports whether the file at dst has the bytes of src, fs.ErrNotExist when there is none
+func sameFile(src, dst string) (bool, error) {
+	existing, err := os.ReadFile(dst)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(existing, data), nil
+}
+
+// move renames src to dst, copying when they are on different file systems
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMove(t *testing.T) {
+	root := t.TempDir()
+	incoming := t.TempDir()
+	date := time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	write := func(name, data string) string {
+		path := filepath.Join(incoming, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	file := func(path string) string {
+		dst, err := Move(root, Statement{File: path, Institution: "RBC", Account: "Chequing", Number: "1234", End: date}, "2024-03-14 Chequing 1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dst
+	}
+
+	want := filepath.Join(root, "RBC", "Chequing 1234", "2024", "2024-03-14 Chequing 1234.pdf")
+	if got := file(write("March.PDF", "first")); got != want {
+		t.Fatalf("archived to %s, want %s", got, want)
+	}
+	// The same statement again is dropped, a different one with the same name is kept beside it
+	if got := file(write("March.PDF", "first")); got != want {
+		t.Fatalf("archived the same file to %s, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(incoming, "March.PDF")); !os.IsNotExist(err) {
+		t.Fatalf("the second copy is still there: %v", err)
+	}
+	numbered := filepath.Join(root, "RBC", "Chequing 1234", "2024", "2024-03-14 Chequing 1234 (2).pdf")
+	if got := file(write("March.pdf", "second")); got != numbered {
+		t.Fatalf("archived a different file to %s, want %s", got, numbered)
+	}
+}
+
+func TestName(t *testing.T) {
+	end := time.Date(2024, 6, 14, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		template string
+		s        Statement
+		want     string
+	}{
+		{"", Statement{Institution: "RBC", Type: "visa", Number: "1234", End: end}, "rbc-visa-1234-2024-06"},
+		// A field the statement lacks leaves no dashes behind
+		{"", Statement{Institution: "TD", Type: "chequing", End: end}, "td-chequing-2024-06"},
+		{"{{.End}} {{.Account}}", Statement{Account: "RBC Day to Day Banking", End: end}, "2024-06-14 rbc-day-to-day-banking"},
+	}
+	for _, c := range cases {
+		namer, err := NewNamer(c.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := namer.Name(c.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%q named %q, want %q", c.template, got, c.want)
+		}
+	}
+
+	if _, err := NewNamer("{{.Branch}}"); err == nil {
+		t.Error("a template with an unknown field compiled")
+	}
+}
@@ -0,0 +1,88 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultNameTemplate names a statement like rbc-visa-1234-2024-06
+const DefaultNameTemplate = "{{.Bank}}-{{.Type}}-{{.Number}}-{{.Year}}-{{.Month}}"
+
+// NameFields are what a name template can refer to. Words are lowercase and joined by dashes, so
+// names sort and type the same on every system.
+type NameFields struct {
+	Bank    string // e.g. rbc
+	Account string // the account's name, e.g. rbc-chequing
+	Type    string // chequing, savings, visa and so on
+	Number  string // last four digits of the account number, empty when it has none
+	Year    string // of the statement's end, as are Month and Day
+	Month   string // 01-12
+	Day     string
+	Start   string // YYYY-MM-DD of the first line
+	End     string // YYYY-MM-DD of the closing date, or the last line
+}
+
+// nonWord runs are what slug turns into a single dash, and dashes runs are left by empty fields
+var (
+	nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+	dashes  = regexp.MustCompile(`-{2,}`)
+)
+
+// slug lowercases s and joins its words with dashes
+func slug(s string) string {
+	return strings.Trim(nonWord.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// Namer names statement files after a template
+type Namer struct {
+	template *template.Template
+}
+
+// NewNamer compiles a name template, DefaultNameTemplate when text is empty
+func NewNamer(text string) (*Namer, error) {
+	if text == "" {
+		text = DefaultNameTemplate
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+
+	// Field names are only checked when a template runs, so try it once before any file depends on it
+	n := &Namer{template: tmpl}
+	if _, err := n.Name(Statement{End: time.Now()}); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Name returns the name of a statement without its extension. Fields a statement lacks leave no
+// doubled or trailing dashes behind.
+func (n *Namer) Name(s Statement) (string, error) {
+	fields := NameFields{
+		Bank:    slug(s.Institution),
+		Account: slug(s.Account),
+		Type:    slug(s.Type),
+		Number:  slug(s.Number),
+		Year:    s.End.Format("2006"),
+		Month:   s.End.Format("01"),
+		Day:     s.End.Format("02"),
+		Start:   s.Start.Format(time.DateOnly),
+		End:     s.End.Format(time.DateOnly),
+	}
+
+	var b bytes.Buffer
+	if err := n.template.Execute(&b, fields); err != nil {
+		return "", fmt.Errorf("invalid name template: %w", err)
+	}
+	name := clean(dashes.ReplaceAllString(b.String(), "-"))
+	name = strings.Trim(name, "-_ ")
+	if name == "" {
+		return "", fmt.Errorf("name template gave %s an empty name", s.File)
+	}
+	return name, nil
+}
@@ -0,0 +1,148 @@
+package categorize
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Example is a transaction whose category a person already settled
+type Example struct {
+	Description string
+	Category    string // ariand category slug
+}
+
+// Model is a multinomial naive Bayes classifier over the words of a description. It is small enough
+// to train from scratch on every run, so it always reflects the categories as they are in ariand.
+type Model struct {
+	// MinExamples is how many examples a category needs before the model will suggest it
+	MinExamples int
+
+	docs     map[string]int            // examples per category
+	words    map[string]map[string]int // word counts per category
+	totals   map[string]int            // words per category
+	vocab    map[string]bool
+	examples int
+}
+
+// Train builds a model from examples; examples without a category are ignored
+func Train(examples []Example) *Model {
+	m := &Model{
+		MinExamples: 3,
+		docs:        make(map[string]int),
+		words:       make(map[string]map[string]int),
+		totals:      make(map[string]int),
+		vocab:       make(map[string]bool),
+	}
+
+	for _, example := range examples {
+		if example.Category == "" {
+			continue
+		}
+		tokens := tokenize(example.Description)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		m.examples++
+		m.docs[example.Category]++
+		if m.words[example.Category] == nil {
+			m.words[example.Category] = make(map[string]int)
+		}
+		for _, token := range tokens {
+			m.words[example.Category][token]++
+			m.totals[example.Category]++
+			m.vocab[token] = true
+		}
+	}
+
+	return m
+}
+
+// Examples reports how many examples the model was trained on
+func (m *Model) Examples() int {
+	return m.examples
+}
+
+// Predict returns the most likely category for a description and how likely it is, from 0 to 1.
+// Words the model never saw say nothing, so a description made only of them gets no suggestion.
+func (m *Model) Predict(description string) (string, float64) {
+	var known []string
+	for _, token := range tokenize(description) {
+		if m.vocab[token] {
+			known = append(known, token)
+		}
+	}
+	if len(known) == 0 {
+		return "", 0
+	}
+
+	categories := make([]string, 0, len(m.docs))
+	for category, docs := range m.docs {
+		if docs >= m.MinExamples {
+			categories = append(categories, category)
+		}
+	}
+	if len(categories) == 0 {
+		return "", 0
+	}
+	sort.Strings(categories)
+
+	// Log probabilities with add-one smoothing, turned back into a distribution at the end
+	scores := make([]float64, len(categories))
+	vocab := float64(len(m.vocab))
+	for i, category := range categories {
+		score := math.Log(float64(m.docs[category]) / float64(m.examples))
+		for _, token := range known {
+			score += math.Log(float64(m.words[category][token]+1) / (float64(m.totals[category]) + vocab))
+		}
+		scores[i] = score
+	}
+
+	best := 0
+	for i := range scores {
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	var sum float64
+	for _, score := range scores {
+		sum += math.Exp(score - scores[best])
+	}
+	return categories[best], 1 / sum
+}
+
+// tokenize splits a description into lowercase words, dropping the numbers and single letters that
+// change from one statement line to the next
+func tokenize(description string) []string {
+	words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		if len([]rune(word)) > 1 {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// Suggest sets the category of every transaction that still has none to what the model predicts,
+// when it is at least threshold sure. It returns how many transactions it categorized.
+func (m *Model) Suggest(transactions []*domain.Transaction, threshold float64) int {
+	suggested := 0
+	for _, tx := range transactions {
+		if tx.Category != "" || tx.CategoryID != nil {
+			continue
+		}
+		if category, confidence := m.Predict(tx.TxDesc); category != "" && confidence >= threshold {
+			tx.Category = category
+			suggested++
+		}
+	}
+	return suggested
+}
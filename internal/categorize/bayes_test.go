@@ -0,0 +1,84 @@
+package categorize
+
+import (
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func examples() []Example {
+	var out []Example
+	for _, description := range []string{"LOBLAWS #1042", "LOBLAWS 0077 TORONTO", "LOBLAWS 1042", "LOBLAWS #0077", "NO FRILLS 3321", "NO FRILLS 118 TORONTO"} {
+		out = append(out, Example{Description: description, Category: "groceries"})
+	}
+	for _, description := range []string{"TIM HORTONS #2231", "STARBUCKS 0420 TORONTO", "TIM HORTONS 77"} {
+		out = append(out, Example{Description: description, Category: "coffee"})
+	}
+	out = append(out, Example{Description: "NETFLIX.COM", Category: "subscriptions"})
+	out = append(out, Example{Description: "UNCATEGORIZED THING"})
+	return out
+}
+
+func TestPredict(t *testing.T) {
+	model := Train(examples())
+
+	if model.Examples() != 10 {
+		t.Errorf("Examples() = %d, want 10", model.Examples())
+	}
+
+	tests := []struct {
+		description string
+		category    string
+		sure        bool
+	}{
+		{"LOBLAWS #9999 MISSISSAUGA", "groceries", true},
+		{"TIM HORTONS #0001", "coffee", true},
+		{"NETFLIX.COM", "", false},        // one example is too few to suggest it
+		{"SHELL 0042 TORONTO", "", false}, // toronto alone says little either way
+		{"ACME WIDGETS", "", false},
+	}
+
+	for _, tt := range tests {
+		category, confidence := model.Predict(tt.description)
+		if tt.category == "" {
+			if category != "" && confidence >= 0.8 {
+				t.Errorf("Predict(%q) = %q at %.2f, want no confident suggestion", tt.description, category, confidence)
+			}
+			continue
+		}
+		if category != tt.category {
+			t.Errorf("Predict(%q) = %q, want %q", tt.description, category, tt.category)
+		}
+		if tt.sure && confidence < 0.8 {
+			t.Errorf("Predict(%q) confidence = %.2f, want at least 0.8", tt.description, confidence)
+		}
+	}
+}
+
+func TestSuggestKeepsCategories(t *testing.T) {
+	model := Train(examples())
+	id := int64(4)
+	transactions := []*domain.Transaction{
+		{TxDesc: "LOBLAWS 5512"},
+		{TxDesc: "LOBLAWS 5513", Category: "household"},
+		{TxDesc: "LOBLAWS 5514", CategoryID: &id},
+		{TxDesc: "ACME WIDGETS"},
+	}
+
+	if got := model.Suggest(transactions, 0.8); got != 1 {
+		t.Errorf("Suggest() = %d, want 1", got)
+	}
+	want := []string{"groceries", "household", "", ""}
+	for i, tx := range transactions {
+		if tx.Category != want[i] {
+			t.Errorf("transaction %d category = %q, want %q", i, tx.Category, want[i])
+		}
+	}
+}
+
+func TestSuggestEmptyModel(t *testing.T) {
+	tx := &domain.Transaction{TxDesc: "LOBLAWS 5512"}
+	if got := Train(nil).Suggest([]*domain.Transaction{tx}, 0); got != 0 || tx.Category != "" {
+		t.Errorf("empty model categorized %d transactions as %q", got, tx.Category)
+	}
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// KeySource loads the current API keys, preferred first. While ariand is being moved to a new key
+// both can be listed, so either one being accepted keeps the run going.
+type KeySource func() ([]string, error)
+
+// apiKey is the key sent with every call, replaced when a reload finds one ariand accepts
+type apiKey struct {
+	mu     sync.Mutex
+	value  string
+	source KeySource
+}
+
+func (k *apiKey) get() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.value
+}
+
+// SetKeySource lets the client reload its API key when ariand rejects the current one
+func (c *Client) SetKeySource(source KeySource) {
+	c.key.mu.Lock()
+	defer c.key.mu.Unlock()
+	c.key.source = source
+}
+
+// authInterceptor sends the API key with every call. When ariand rejects it, the key source is
+// read again and the call is retried once with each new key, so a key rotated on the server mid-run
+// only needs the file, command or keyring entry updated.
+func authInterceptor(key *apiKey, logger *log.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		current := key.get()
+		err := invoker(withKey(ctx, current), method, req, reply, cc, opts...)
+		if status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+
+		key.mu.Lock()
+		source := key.source
+		key.mu.Unlock()
+		if source == nil {
+			return err
+		}
+
+		keys, loadErr := source()
+		if loadErr != nil {
+			logger.Warn("failed to reload API key", "error", loadErr)
+			return err
+		}
+
+		tried := []string{current}
+		for _, candidate := range keys {
+			if candidate == "" || slices.Contains(tried, candidate) {
+				continue
+			}
+			tried = append(tried, candidate)
+
+			retryErr := invoker(withKey(ctx, candidate), method, req, reply, cc, opts...)
+			if status.Code(retryErr) == codes.Unauthenticated {
+				continue
+			}
+
+			key.mu.Lock()
+			key.value = candidate
+			key.mu.Unlock()
+			logger.Info("API key was rejected, switched to a reloaded key", "method", method)
+			return retryErr
+		}
+
+		return err
+	}
+}
+
+// withKey sets the x-internal-key header ariand checks, keeping any other outgoing metadata
+func withKey(ctx context.Context, key string) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set("x-internal-key", key)
+	return metadata.NewOutgoingContext(ctx, md)
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"math"
 	"os"
+	"strings"
+	"time"
 
 	"arian-statement-parser/internal/domain"
 	pb "arian-statement-parser/internal/gen/arian/v1"
@@ -15,51 +18,95 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// connection is a gRPC connection, or the connect transport standing in for one
+type connection interface {
+	grpc.ClientConnInterface
+	Close() error
+}
+
 type Client struct {
-	conn          *grpc.ClientConn
+	conn          connection
 	accountClient pb.AccountServiceClient
 	txClient      pb.TransactionServiceClient
 	userClient    pb.UserServiceClient
-	authToken     string
+	catClient     pb.CategoryServiceClient
+	key           *apiKey
+	caps          *Capabilities // nil until Handshake, when every feature is assumed
+	metrics       *Metrics
 	log           *log.Logger
 }
 
-func NewClient(arianURL, _, authToken string) (*Client, error) {
-	// Use TLS credentials for port 443, insecure for others
-	var creds credentials.TransportCredentials
-	if arianURL[len(arianURL)-4:] == ":443" {
-		creds = credentials.NewTLS(&tls.Config{})
-	} else {
-		creds = insecure.NewCredentials()
+// NewClient dials ariand with DefaultSettings; extra options such as a Recorder or Replayer are applied to the connection
+func NewClient(arianURL, _, authToken string, opts ...grpc.DialOption) (*Client, error) {
+	return NewClientWithSettings(arianURL, authToken, DefaultSettings(), opts...)
+}
+
+// NewClientWithSettings dials ariand with the interceptor chain described by settings
+func NewClientWithSettings(arianURL, authToken string, settings Settings, opts ...grpc.DialOption) (*Client, error) {
+	c := &Client{
+		key:     &apiKey{value: authToken},
+		metrics: settings.Metrics,
+		log:     settings.Logger,
+	}
+	if c.log == nil {
+		c.log = log.NewWithOptions(os.Stderr, log.Options{Prefix: "grpc-client"})
 	}
 
-	conn, err := grpc.NewClient(arianURL, grpc.WithTransportCredentials(creds))
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to gRPC server: %w", err)
+	var conn connection
+	switch settings.Transport {
+	case "", TransportGRPC:
+		// Use TLS credentials for port 443, insecure for others
+		var creds credentials.TransportCredentials
+		if strings.HasSuffix(arianURL, ":443") {
+			creds = credentials.NewTLS(&tls.Config{})
+		} else {
+			creds = insecure.NewCredentials()
+		}
+
+		opts = append([]grpc.DialOption{
+			grpc.WithTransportCredentials(creds),
+			grpc.WithChainUnaryInterceptor(c.interceptors(settings)...),
+		}, opts...)
+		grpcConn, err := grpc.NewClient(arianURL, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to gRPC server: %w", err)
+		}
+		conn = grpcConn
+	case TransportConnect:
+		if len(opts) > 0 {
+			return nil, errDialOptionsNeedGRPC
+		}
+		conn = newConnectConn(arianURL, c.interceptors(settings))
+	default:
+		return nil, fmt.Errorf("unknown transport %q, expected %s or %s", settings.Transport, TransportGRPC, TransportConnect)
 	}
 
-	return &Client{
-		conn:          conn,
-		accountClient: pb.NewAccountServiceClient(conn),
-		txClient:      pb.NewTransactionServiceClient(conn),
-		userClient:    pb.NewUserServiceClient(conn),
-		authToken:     authToken,
-		log:           log.NewWithOptions(os.Stderr, log.Options{Prefix: "grpc-client"}),
-	}, nil
+	c.conn = conn
+	c.accountClient = pb.NewAccountServiceClient(conn)
+	c.txClient = pb.NewTransactionServiceClient(conn)
+	c.userClient = pb.NewUserServiceClient(conn)
+	c.catClient = pb.NewCategoryServiceClient(conn)
+	return c, nil
 }
 
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
+// Metrics returns the call counters, nil when the client was built without them
+func (c *Client) Metrics() *Metrics {
+	return c.metrics
+}
+
 // GetUser retrieves a user by UUID
 func (c *Client) GetUser(userUUID string) (*pb.User, error) {
-	ctx := c.withAuth(context.Background())
+	ctx := context.Background()
 
 	req := &pb.GetUserRequest{
 		Id: userUUID,
@@ -74,8 +121,10 @@ func (c *Client) GetUser(userUUID string) (*pb.User, error) {
 	return resp.User, nil
 }
 
+// GetAccounts lists every account of the user. ListAccounts has no paging fields, so ariand
+// always returns the full list in one response.
 func (c *Client) GetAccounts(userID string) ([]*pb.Account, error) {
-	ctx := c.withAuth(context.Background())
+	ctx := context.Background()
 
 	req := &pb.ListAccountsRequest{
 		UserId: userID,
@@ -91,7 +140,7 @@ func (c *Client) GetAccounts(userID string) ([]*pb.Account, error) {
 }
 
 func (c *Client) CreateAccount(userID, accountName, bank string, accountType pb.AccountType, mainCurrency string) (*pb.Account, error) {
-	ctx := c.withAuth(context.Background())
+	ctx := context.Background()
 
 	req := &pb.CreateAccountRequest{
 		UserId:       userID,
@@ -115,21 +164,151 @@ func (c *Client) CreateAccount(userID, accountName, bank string, accountType pb.
 	return resp.Account, nil
 }
 
+// AccountChanges are the fields of an account UpdateAccount changes, zero values are left alone
+type AccountChanges struct {
+	Name string
+	Type pb.AccountType
+}
+
+// UpdateAccount renames an account or changes its type, as changes says
+func (c *Client) UpdateAccount(userID string, accountID int64, changes AccountChanges) error {
+	ctx := context.Background()
+
+	req := &pb.UpdateAccountRequest{
+		UserId:     userID,
+		Id:         accountID,
+		UpdateMask: &fieldmaskpb.FieldMask{},
+	}
+	if changes.Name != "" {
+		req.Name = &changes.Name
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "name")
+	}
+	if changes.Type != pb.AccountType_ACCOUNT_UNSPECIFIED {
+		req.AccountType = &changes.Type
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "account_type")
+	}
+	if len(req.UpdateMask.Paths) == 0 {
+		return nil
+	}
+
+	if _, err := c.accountClient.UpdateAccount(ctx, req); err != nil {
+		return fmt.Errorf("failed to update account: %w", err)
+	}
+
+	c.log.Info("successfully updated account", "account_id", accountID, "fields", req.UpdateMask.Paths)
+	return nil
+}
+
+// SetOpeningBalance anchors an account's running balance: balance is what it held at the end of date
+func (c *Client) SetOpeningBalance(userID string, accountID int64, balance float64, currency string, date time.Time) error {
+	if !c.Supports(FeatureAnchor) {
+		return fmt.Errorf("failed to set opening balance: %w", ErrUnsupported)
+	}
+	ctx := context.Background()
+
+	units := int64(balance)
+	req := &pb.UpdateAccountRequest{
+		UserId:     userID,
+		Id:         accountID,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"anchor_balance", "anchor_date"}},
+		AnchorBalance: &money.Money{
+			CurrencyCode: currency,
+			Units:        units,
+			Nanos:        int32(math.Round((balance - float64(units)) * 1e9)),
+		},
+		AnchorDate: timestamppb.New(date),
+	}
+
+	if _, err := c.accountClient.UpdateAccount(ctx, req); err != nil {
+		return fmt.Errorf("failed to set opening balance: %w", err)
+	}
+
+	c.log.Info("opening balance set", "account_id", accountID, "balance", balance, "date", date.Format(time.DateOnly))
+	return nil
+}
+
+// ListCategories lists every category, reading as many pages as ariand needs
+func (c *Client) ListCategories(userID string) ([]*pb.Category, error) {
+	if !c.Supports(FeatureCategories) {
+		return nil, fmt.Errorf("failed to list categories: %w", ErrUnsupported)
+	}
+	ctx := context.Background()
+
+	categories, err := paginate(0, func(offset, size int32) (page[*pb.Category], error) {
+		resp, err := c.catClient.ListCategories(ctx, &pb.ListCategoriesRequest{
+			UserId: userID,
+			Limit:  &size,
+			Offset: &offset,
+		})
+		if err != nil {
+			return page[*pb.Category]{}, err
+		}
+		return page[*pb.Category]{items: resp.Categories, total: resp.TotalCount}, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+
+	c.log.Info("successfully fetched categories", "count", len(categories))
+	return categories, nil
+}
+
+// ListTransactions returns up to limit of the user's transactions, 0 for all of them
 func (c *Client) ListTransactions(userID string, limit int32) ([]*pb.Transaction, error) {
-	ctx := c.withAuth(context.Background())
+	if !c.Supports(FeatureHistory) {
+		return nil, fmt.Errorf("failed to list transactions: %w", ErrUnsupported)
+	}
 
-	req := &pb.ListTransactionsRequest{
-		UserId: userID,
-		Limit:  &limit,
+	transactions, err := c.listTransactions(&pb.ListTransactionsRequest{UserId: userID}, int(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Info("successfully fetched transactions", "count", len(transactions))
+	return transactions, nil
+}
+
+// ListTransactionsBetween returns the transactions of some accounts dated from start to end,
+// inclusive
+func (c *Client) ListTransactionsBetween(userID string, accountIDs []int64, start, end time.Time) ([]*pb.Transaction, error) {
+	if !c.Supports(FeatureHistory) {
+		return nil, fmt.Errorf("failed to list transactions: %w", ErrUnsupported)
 	}
 
-	resp, err := c.txClient.ListTransactions(ctx, req)
+	return c.listTransactions(&pb.ListTransactionsRequest{
+		UserId:     userID,
+		AccountIds: accountIDs,
+		StartDate:  timestamppb.New(start),
+		EndDate:    timestamppb.New(end),
+	}, 0)
+}
+
+// listTransactions pages through the transactions matching the filters of base. Pages follow
+// ariand's cursor when it returns one and the offset otherwise.
+func (c *Client) listTransactions(base *pb.ListTransactionsRequest, limit int) ([]*pb.Transaction, error) {
+	ctx := context.Background()
+
+	var cursor *pb.Cursor
+	transactions, err := paginate(limit, func(offset, size int32) (page[*pb.Transaction], error) {
+		req := proto.Clone(base).(*pb.ListTransactionsRequest)
+		req.Limit = &size
+		if cursor != nil {
+			req.Cursor = cursor
+		} else if offset > 0 {
+			req.Offset = &offset
+		}
+
+		resp, err := c.txClient.ListTransactions(ctx, req)
+		if err != nil {
+			return page[*pb.Transaction]{}, err
+		}
+		cursor = resp.NextCursor
+		return page[*pb.Transaction]{items: resp.Transactions, total: resp.TotalCount, more: cursor != nil}, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list transactions: %w", err)
 	}
-
-	c.log.Info("successfully fetched transactions", "count", len(resp.Transactions))
-	return resp.Transactions, nil
+	return transactions, nil
 }
 
 func (c *Client) CreateTransaction(userID string, tx *domain.Transaction) error {
@@ -145,38 +324,42 @@ func (c *Client) CreateTransaction(userID string, tx *domain.Transaction) error
 }
 
 func (c *Client) CreateTransactionsBulk(userID string, transactions []*domain.Transaction) (int32, []error) {
+	created, _, errs := c.createTransactions(userID, transactions)
+	return created, errs
+}
+
+// CreateTransactionsWithIDs creates transactions like CreateTransactionsBulk, returning the IDs
+// ariand assigned to them instead of a count
+func (c *Client) CreateTransactionsWithIDs(userID string, transactions []*domain.Transaction) ([]int64, []error) {
+	_, ids, errs := c.createTransactions(userID, transactions)
+	return ids, errs
+}
+
+func (c *Client) createTransactions(userID string, transactions []*domain.Transaction) (int32, []int64, []error) {
 	if len(transactions) == 0 {
-		return 0, nil
+		return 0, nil, nil
 	}
 
-	ctx := c.withAuth(context.Background())
+	// An ariand that takes one transaction per request gets one request per transaction
+	if len(transactions) > 1 && !c.Supports(FeatureBulkCreate) {
+		var created int32
+		var ids []int64
+		var errs []error
+		for _, tx := range transactions {
+			n, txIDs, txErrs := c.createTransactions(userID, []*domain.Transaction{tx})
+			created += n
+			ids = append(ids, txIDs...)
+			errs = append(errs, txErrs...)
+		}
+		return created, ids, errs
+	}
+
+	ctx := context.Background()
 
 	// Convert domain transactions to gRPC TransactionInput
 	inputs := make([]*pb.TransactionInput, 0, len(transactions))
 	for _, tx := range transactions {
-		input := &pb.TransactionInput{
-			AccountId: int64(tx.AccountID),
-			TxDate:    timestamppb.New(tx.TxDate),
-			TxAmount: &money.Money{
-				CurrencyCode: tx.TxCurrency,
-				Units:        int64(tx.TxAmount),
-				Nanos:        int32((tx.TxAmount - float64(int64(tx.TxAmount))) * 1e9),
-			},
-			Direction: c.convertDirection(tx.TxDirection),
-		}
-
-		// Optional fields
-		if tx.TxDesc != "" {
-			input.Description = &tx.TxDesc
-		}
-		if tx.Merchant != "" {
-			input.Merchant = &tx.Merchant
-		}
-		if tx.UserNotes != "" {
-			input.UserNotes = &tx.UserNotes
-		}
-
-		inputs = append(inputs, input)
+		inputs = append(inputs, c.toInput(tx))
 	}
 
 	req := &pb.CreateTransactionRequest{
@@ -189,19 +372,94 @@ func (c *Client) CreateTransactionsBulk(userID string, transactions []*domain.Tr
 		// check for duplicate transaction (conflict)
 		if grpcStatus := status.Code(err); grpcStatus == codes.AlreadyExists {
 			c.log.Info("skipping duplicate transactions")
-			return 0, nil // not a fatal error, just duplicates
+			return 0, nil, nil // not a fatal error, just duplicates
 		}
-		return 0, []error{fmt.Errorf("failed to create transactions: %w", err)}
+		return 0, nil, []error{fmt.Errorf("failed to create transactions: %w", err)}
+	}
+
+	ids := make([]int64, 0, len(resp.Transactions))
+	for _, created := range resp.Transactions {
+		ids = append(ids, created.Id)
 	}
 
 	c.log.Info("transactions created successfully", "count", resp.CreatedCount)
-	return resp.CreatedCount, nil
+	return resp.CreatedCount, ids, nil
+}
+
+// CreateTransactionWithID creates a single transaction and returns the ID ariand assigned to it
+func (c *Client) CreateTransactionWithID(userID string, tx *domain.Transaction) (int64, error) {
+	ctx := context.Background()
+
+	req := &pb.CreateTransactionRequest{
+		UserId:       userID,
+		Transactions: []*pb.TransactionInput{c.toInput(tx)},
+	}
+
+	resp, err := c.txClient.CreateTransaction(ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	if len(resp.Transactions) == 0 {
+		return 0, fmt.Errorf("transaction was not created")
+	}
+
+	return resp.Transactions[0].Id, nil
 }
 
-// withAuth adds authentication metadata to the context
-func (c *Client) withAuth(ctx context.Context) context.Context {
-	md := metadata.Pairs("x-internal-key", c.authToken)
-	return metadata.NewOutgoingContext(ctx, md)
+// UpdateTransaction overwrites the date, amount, direction and description of an existing transaction
+func (c *Client) UpdateTransaction(userID string, id int64, tx *domain.Transaction) error {
+	if !c.Supports(FeatureUpdate) {
+		return fmt.Errorf("failed to update transaction: %w", ErrUnsupported)
+	}
+	ctx := context.Background()
+
+	input := c.toInput(tx)
+	req := &pb.UpdateTransactionRequest{
+		UserId:      userID,
+		Id:          id,
+		UpdateMask:  &fieldmaskpb.FieldMask{Paths: []string{"tx_date", "tx_amount", "direction", "description"}},
+		TxDate:      input.TxDate,
+		TxAmount:    input.TxAmount,
+		Direction:   &input.Direction,
+		Description: &tx.TxDesc,
+	}
+
+	if _, err := c.txClient.UpdateTransaction(ctx, req); err != nil {
+		return fmt.Errorf("failed to update transaction: %w", err)
+	}
+
+	c.log.Info("transaction updated", "id", id)
+	return nil
+}
+
+// toInput converts a domain transaction to a gRPC TransactionInput
+func (c *Client) toInput(tx *domain.Transaction) *pb.TransactionInput {
+	input := &pb.TransactionInput{
+		AccountId: int64(tx.AccountID),
+		TxDate:    timestamppb.New(tx.TxDate),
+		TxAmount: &money.Money{
+			CurrencyCode: tx.TxCurrency,
+			Units:        int64(tx.TxAmount),
+			Nanos:        int32((tx.TxAmount - float64(int64(tx.TxAmount))) * 1e9),
+		},
+		Direction: c.convertDirection(tx.TxDirection),
+	}
+
+	// Optional fields
+	if tx.TxDesc != "" {
+		input.Description = &tx.TxDesc
+	}
+	if tx.Merchant != "" {
+		input.Merchant = &tx.Merchant
+	}
+	if notes := tx.Notes(); notes != "" {
+		input.UserNotes = &notes
+	}
+	if tx.CategoryID != nil {
+		input.CategoryId = tx.CategoryID
+	}
+
+	return input
 }
 
 // convertDirection converts domain Direction to gRPC TransactionDirection
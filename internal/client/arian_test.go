@@ -0,0 +1,297 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/client/fake"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+)
+
+const testUser = "00000000-0000-0000-0000-000000000001"
+
+func startFake(t *testing.T) (*fake.Server, *Client) {
+	t.Helper()
+
+	server := fake.New("test-key")
+	server.AddUser(testUser, "test@example.com")
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+
+	c, err := NewClient(addr, "", "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return server, c
+}
+
+func TestImportFlowAgainstFake(t *testing.T) {
+	server, c := startFake(t)
+
+	if _, err := c.GetUser(testUser); err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+
+	account, err := c.CreateAccount(testUser, "chequing 1234", "RBC", pb.AccountType_ACCOUNT_CHEQUING, "CAD")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	accounts, err := c.GetAccounts(testUser)
+	if err != nil || len(accounts) != 1 {
+		t.Fatalf("GetAccounts = %d accounts, %v", len(accounts), err)
+	}
+
+	date := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	txs := []*domain.Transaction{
+		{AccountID: int(account.Id), TxDate: date, TxAmount: 12.5, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "COFFEE"},
+		{AccountID: int(account.Id), TxDate: date, TxAmount: 1000, TxCurrency: "CAD", TxDirection: domain.In, TxDesc: "PAYROLL"},
+	}
+
+	created, errs := c.CreateTransactionsBulk(testUser, txs)
+	if len(errs) > 0 || created != 2 {
+		t.Fatalf("CreateTransactionsBulk = %d, %v", created, errs)
+	}
+
+	// A second upload of the same lines is reported as duplicates, not an error
+	created, errs = c.CreateTransactionsBulk(testUser, txs)
+	if len(errs) > 0 || created != 0 {
+		t.Fatalf("re-upload = %d, %v", created, errs)
+	}
+
+	listed, err := c.ListTransactions(testUser, 10)
+	if err != nil || len(listed) != 2 {
+		t.Fatalf("ListTransactions = %d, %v", len(listed), err)
+	}
+	if got := server.Transactions()[0].GetDescription(); got != "COFFEE" {
+		t.Errorf("stored description = %q", got)
+	}
+}
+
+func TestFakeRejectsBadKey(t *testing.T) {
+	server := fake.New("test-key")
+	server.AddUser(testUser, "test@example.com")
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	c, err := NewClient(addr, "", "wrong-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.GetUser(testUser); err == nil {
+		t.Fatal("expected an authentication error")
+	}
+}
+
+func TestPreflightDiagnostics(t *testing.T) {
+	server := fake.New("test-key")
+	server.AddUser(testUser, "test@example.com")
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	// Without retries the stopped server is reported at once
+	c, err := NewClientWithSettings(addr, "test-key", Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Preflight(testUser); err != nil {
+		t.Fatalf("Preflight with valid credentials: %v", err)
+	}
+
+	if _, err := c.Preflight("00000000-0000-0000-0000-00000000dead"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: got %v, want ErrUserNotFound", err)
+	}
+
+	wrongKey, err := NewClient(addr, "", "wrong-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrongKey.Close()
+	if _, err := wrongKey.Preflight(testUser); !errors.Is(err, ErrKeyRejected) {
+		t.Errorf("wrong key: got %v, want ErrKeyRejected", err)
+	}
+
+	// Nothing listens on a port the fake just gave up
+	server.Stop()
+	if _, err := c.Preflight(testUser); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("stopped server: got %v, want ErrUnreachable", err)
+	}
+}
+
+func TestReloadsRotatedKey(t *testing.T) {
+	server := fake.New("new-key")
+	server.AddUser(testUser, "test@example.com")
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	c, err := NewClient(addr, "", "old-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.GetUser(testUser); err == nil {
+		t.Fatal("old key accepted without a key source")
+	}
+
+	loads := 0
+	c.SetKeySource(func() ([]string, error) {
+		loads++
+		return []string{"old-key", "wrong-key", "new-key"}, nil
+	})
+
+	if _, err := c.GetUser(testUser); err != nil {
+		t.Fatalf("GetUser after rotation: %v", err)
+	}
+	if _, err := c.GetAccounts(testUser); err != nil {
+		t.Fatalf("GetAccounts with reloaded key: %v", err)
+	}
+	if loads != 1 {
+		t.Errorf("key source loaded %d times, want once", loads)
+	}
+}
+
+func TestListsReadEveryPage(t *testing.T) {
+	server := fake.New("test-key")
+	server.PageLimit = 3
+	server.AddUser(testUser, "test@example.com")
+	for _, slug := range []string{"groceries", "rent", "fuel", "dining", "transfer", "income", "fees"} {
+		server.AddCategory(slug)
+	}
+	account := server.AddAccount(testUser, "chequing 1234", "RBC", pb.AccountType_ACCOUNT_CHEQUING)
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	c, err := NewClient(addr, "", "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	categories, err := c.ListCategories(testUser)
+	if err != nil || len(categories) != 7 {
+		t.Fatalf("ListCategories = %d categories, %v; want all 7 across pages of 3", len(categories), err)
+	}
+
+	date := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	var txs []*domain.Transaction
+	for i := range 8 {
+		txs = append(txs, &domain.Transaction{AccountID: int(account.Id), TxDate: date, TxAmount: float64(i + 1), TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "COFFEE"})
+	}
+	if _, errs := c.CreateTransactionsBulk(testUser, txs); len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+
+	if all, err := c.ListTransactions(testUser, 0); err != nil || len(all) != 8 {
+		t.Errorf("ListTransactions(0) = %d, %v; want 8", len(all), err)
+	}
+	if some, err := c.ListTransactions(testUser, 5); err != nil || len(some) != 5 {
+		t.Errorf("ListTransactions(5) = %d, %v; want 5", len(some), err)
+	}
+}
+
+func TestConnectTransport(t *testing.T) {
+	server := fake.New("test-key")
+	server.AddUser(testUser, "test@example.com")
+	url, err := server.StartConnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	settings := DefaultSettings()
+	settings.Transport = TransportConnect
+	c, err := NewClientWithSettings(url, "test-key", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Preflight(testUser); err != nil {
+		t.Fatalf("Preflight over connect: %v", err)
+	}
+	account, err := c.CreateAccount(testUser, "chequing 1234", "RBC", pb.AccountType_ACCOUNT_CHEQUING, "CAD")
+	if err != nil {
+		t.Fatalf("CreateAccount over connect: %v", err)
+	}
+
+	tx := &domain.Transaction{AccountID: int(account.Id), TxDate: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC), TxAmount: 12.5, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "COFFEE"}
+	if created, errs := c.CreateTransactionsBulk(testUser, []*domain.Transaction{tx}); created != 1 || len(errs) > 0 {
+		t.Fatalf("CreateTransactionsBulk over connect = %d, %v", created, errs)
+	}
+	// Errors keep their gRPC codes, so the duplicate is still recognized
+	if created, errs := c.CreateTransactionsBulk(testUser, []*domain.Transaction{tx}); created != 0 || len(errs) > 0 {
+		t.Errorf("duplicate over connect = %d, %v; want it skipped", created, errs)
+	}
+
+	if _, err := c.Preflight("00000000-0000-0000-0000-00000000dead"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user over connect: got %v, want ErrUserNotFound", err)
+	}
+
+	wrongKey, err := NewClientWithSettings(url, "wrong-key", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrongKey.Close()
+	if _, err := wrongKey.Preflight(testUser); !errors.Is(err, ErrKeyRejected) {
+		t.Errorf("wrong key over connect: got %v, want ErrKeyRejected", err)
+	}
+}
+
+func TestCreateTransactionsWithIDs(t *testing.T) {
+	server, c := startFake(t)
+	account := server.AddAccount(testUser, "chequing", "RBC", pb.AccountType_ACCOUNT_CHEQUING)
+
+	date := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	ids, errs := c.CreateTransactionsWithIDs(testUser, []*domain.Transaction{
+		{AccountID: int(account.Id), TxDate: date, TxAmount: 12.5, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "COFFEE"},
+		{AccountID: int(account.Id), TxDate: date, TxAmount: 1000, TxCurrency: "CAD", TxDirection: domain.In, TxDesc: "PAYROLL"},
+	})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	stored := server.Transactions()
+	if len(ids) != 2 || len(stored) != 2 || ids[0] != stored[0].Id || ids[1] != stored[1].Id {
+		t.Fatalf("ids = %v, stored %d transactions", ids, len(stored))
+	}
+}
+
+func TestUpdateAccount(t *testing.T) {
+	server, c := startFake(t)
+	account := server.AddAccount(testUser, "visa", "RBC", pb.AccountType_ACCOUNT_CHEQUING)
+
+	if err := c.UpdateAccount(testUser, account.Id, AccountChanges{Type: pb.AccountType_ACCOUNT_CREDIT_CARD}); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := c.GetAccounts(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Type != pb.AccountType_ACCOUNT_CREDIT_CARD || accounts[0].Name != "visa" {
+		t.Fatalf("accounts after update = %v", accounts)
+	}
+}
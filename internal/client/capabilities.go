@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	pb "arian-statement-parser/internal/gen/arian/v1"
+
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrUnsupported means ariand is too old for a call the client was asked to make
+var ErrUnsupported = errors.New("not supported by this ariand")
+
+// Feature is something the importer relies on that older ariand releases may not serve: a method,
+// or a field of a request message
+type Feature struct {
+	Name     string
+	Method   string // full method name, e.g. /arian.v1.CategoryService/ListCategories
+	Message  string // full message name whose Field is needed
+	Field    string
+	Repeated bool // the field must be a list
+}
+
+// Features the importer adapts to
+var (
+	FeatureBulkCreate = Feature{Name: "bulk uploads", Message: "arian.v1.CreateTransactionRequest", Field: "transactions", Repeated: true}
+	FeatureCategories = Feature{Name: "categories", Method: pb.CategoryService_ListCategories_FullMethodName}
+	FeatureHistory    = Feature{Name: "listing transactions", Method: pb.TransactionService_ListTransactions_FullMethodName}
+	FeatureUpdate     = Feature{Name: "updating transactions", Method: pb.TransactionService_UpdateTransaction_FullMethodName}
+	FeatureMerchant   = Feature{Name: "merchant names", Message: "arian.v1.TransactionInput", Field: "merchant"}
+	FeatureAnchor     = Feature{Name: "opening balances", Method: pb.AccountService_UpdateAccount_FullMethodName}
+)
+
+// Features lists every feature, in the order auth test prints them
+var Features = []Feature{FeatureBulkCreate, FeatureCategories, FeatureHistory, FeatureUpdate, FeatureMerchant, FeatureAnchor}
+
+// Capabilities are the services ariand said it serves, through gRPC server reflection
+type Capabilities struct {
+	// Known is false when ariand doesn't offer reflection or the transport can't ask; every
+	// feature is assumed then, as it was before the handshake existed
+	Known   bool
+	methods map[string]bool
+	fields  map[string]map[string]bool // message name to field name to whether it is repeated
+}
+
+// Supports reports whether ariand serves a feature
+func (c *Capabilities) Supports(f Feature) bool {
+	if c == nil || !c.Known {
+		return true
+	}
+	if f.Method != "" && !c.methods[f.Method] {
+		return false
+	}
+	if f.Message != "" {
+		repeated, ok := c.fields[f.Message][f.Field]
+		if !ok || (f.Repeated && !repeated) {
+			return false
+		}
+	}
+	return true
+}
+
+// Missing returns the features of Features ariand lacks
+func (c *Capabilities) Missing() []Feature {
+	var missing []Feature
+	for _, f := range Features {
+		if !c.Supports(f) {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+// Handshake asks ariand which services it serves and remembers the answer, so later calls can work
+// around what it lacks. A server without reflection is not an error, its capabilities are unknown.
+func (c *Client) Handshake() (*Capabilities, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+	defer cancel()
+
+	caps, err := reflect(withKey(ctx, c.key.get()), reflectionpb.NewServerReflectionClient(c.conn))
+	if status.Code(err) == codes.Unimplemented {
+		caps, err = &Capabilities{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to ask ariand what it supports: %w", err)
+	}
+
+	c.caps = caps
+	return caps, nil
+}
+
+// Supports reports whether ariand serves a feature, assuming it does before a handshake
+func (c *Client) Supports(f Feature) bool {
+	return c.caps.Supports(f)
+}
+
+// reflect lists the arian services and reads the methods and request fields from their descriptors
+func reflect(ctx context.Context, stub reflectionpb.ServerReflectionClient) (*Capabilities, error) {
+	stream, err := stub.ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	ask := func(req *reflectionpb.ServerReflectionRequest) (*reflectionpb.ServerReflectionResponse, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, status.Error(codes.Code(e.ErrorCode), e.ErrorMessage)
+		}
+		return resp, nil
+	}
+
+	resp, err := ask(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	caps := &Capabilities{Known: true, methods: make(map[string]bool), fields: make(map[string]map[string]bool)}
+	seen := make(map[string]bool)
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		if !strings.HasPrefix(service.Name, "arian.") {
+			continue
+		}
+
+		resp, err := ask(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service.Name},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, file); err != nil {
+				return nil, fmt.Errorf("failed to decode descriptor: %w", err)
+			}
+			if seen[file.GetName()] {
+				continue
+			}
+			seen[file.GetName()] = true
+			caps.add(file)
+		}
+	}
+	return caps, nil
+}
+
+// add records the services and top-level messages of a file
+func (c *Capabilities) add(file *descriptorpb.FileDescriptorProto) {
+	prefix := file.GetPackage()
+	if prefix != "" {
+		prefix += "."
+	}
+
+	for _, service := range file.GetService() {
+		for _, method := range service.GetMethod() {
+			c.methods["/"+prefix+service.GetName()+"/"+method.GetName()] = true
+		}
+	}
+	for _, message := range file.GetMessageType() {
+		fields := make(map[string]bool, len(message.GetField()))
+		for _, field := range message.GetField() {
+			fields[field.GetName()] = field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		c.fields[prefix+message.GetName()] = fields
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/client/fake"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestHandshakeAgainstFake(t *testing.T) {
+	_, c := startFake(t)
+
+	caps, err := c.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.Known {
+		t.Fatal("expected the fake to answer reflection")
+	}
+	if missing := caps.Missing(); len(missing) > 0 {
+		t.Fatalf("fake is missing %v", missing)
+	}
+}
+
+// startOld serves only the user service, like an ariand from before the others existed
+func startOld(t *testing.T, reflect bool) *Client {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterUserServiceServer(server, fake.New(""))
+	if reflect {
+		reflection.Register(server)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	c, err := NewClient(listener.Addr().String(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestHandshakeAgainstOldServer(t *testing.T) {
+	c := startOld(t, true)
+
+	caps, err := c.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.Known {
+		t.Fatal("expected reflection to answer")
+	}
+	for _, f := range []Feature{FeatureBulkCreate, FeatureCategories, FeatureHistory, FeatureUpdate} {
+		if c.Supports(f) {
+			t.Errorf("expected %s to be missing", f.Name)
+		}
+	}
+
+	if _, err := c.ListCategories(testUser); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("ListCategories = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestHandshakeWithoutReflection(t *testing.T) {
+	c := startOld(t, false)
+
+	caps, err := c.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Known {
+		t.Fatal("expected unknown capabilities without reflection")
+	}
+	if !c.Supports(FeatureCategories) {
+		t.Fatal("expected every feature to be assumed")
+	}
+}
+
+func TestBulkFallsBackToSingleCalls(t *testing.T) {
+	server, c := startFake(t)
+	account := server.AddAccount(testUser, "chequing", "RBC", pb.AccountType_ACCOUNT_CHEQUING)
+
+	// An ariand whose request holds a single transaction
+	c.caps = &Capabilities{
+		Known:   true,
+		methods: map[string]bool{pb.TransactionService_CreateTransaction_FullMethodName: true},
+		fields:  map[string]map[string]bool{"arian.v1.CreateTransactionRequest": {"transactions": false}},
+	}
+
+	date := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	txs := []*domain.Transaction{
+		{AccountID: int(account.Id), TxDate: date, TxAmount: 12.5, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "COFFEE"},
+		{AccountID: int(account.Id), TxDate: date, TxAmount: 1000, TxCurrency: "CAD", TxDirection: domain.In, TxDesc: "PAYROLL"},
+	}
+	created, errs := c.CreateTransactionsBulk(testUser, txs)
+	if len(errs) > 0 || created != 2 {
+		t.Fatalf("CreateTransactionsBulk = %d, %v", created, errs)
+	}
+
+	for _, method := range c.Metrics().Snapshot() {
+		if method.Method == pb.TransactionService_CreateTransaction_FullMethodName && method.Calls != 2 {
+			t.Fatalf("made %d create calls, want one per transaction", method.Calls)
+		}
+	}
+}
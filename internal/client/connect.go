@@ -0,0 +1,191 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Transports a client can talk to ariand over
+const (
+	TransportGRPC    = "grpc"    // HTTP/2 gRPC, the default
+	TransportConnect = "connect" // Connect unary calls, plain HTTP POSTs that pass HTTP/1.1 proxies
+)
+
+// connectCodes maps the error codes of the Connect protocol to gRPC codes, so callers check one set
+var connectCodes = map[string]codes.Code{
+	"canceled":            codes.Canceled,
+	"unknown":             codes.Unknown,
+	"invalid_argument":    codes.InvalidArgument,
+	"deadline_exceeded":   codes.DeadlineExceeded,
+	"not_found":           codes.NotFound,
+	"already_exists":      codes.AlreadyExists,
+	"permission_denied":   codes.PermissionDenied,
+	"resource_exhausted":  codes.ResourceExhausted,
+	"failed_precondition": codes.FailedPrecondition,
+	"aborted":             codes.Aborted,
+	"out_of_range":        codes.OutOfRange,
+	"unimplemented":       codes.Unimplemented,
+	"internal":            codes.Internal,
+	"unavailable":         codes.Unavailable,
+	"data_loss":           codes.DataLoss,
+	"unauthenticated":     codes.Unauthenticated,
+}
+
+// connectConn sends unary calls with the Connect protocol: a POST of the binary request to
+// /<service>/<method>, answered by the binary response or a JSON error. It stands in for a
+// grpc.ClientConn, so the generated service clients and the interceptor chain work unchanged.
+type connectConn struct {
+	baseURL      string
+	http         *http.Client
+	interceptors []grpc.UnaryClientInterceptor
+}
+
+// newConnectConn takes ariand's address as host:port, using HTTPS for port 443 like the gRPC
+// transport, or a full http:// or https:// URL
+func newConnectConn(address string, interceptors []grpc.UnaryClientInterceptor) *connectConn {
+	baseURL := address
+	if !strings.Contains(address, "://") {
+		if strings.HasSuffix(address, ":443") {
+			baseURL = "https://" + strings.TrimSuffix(address, ":443")
+		} else {
+			baseURL = "http://" + address
+		}
+	}
+
+	return &connectConn{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		http:         &http.Client{},
+		interceptors: interceptors,
+	}
+}
+
+// Invoke runs the interceptors, then the HTTP call
+func (c *connectConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	invoker := c.call
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.interceptors[i], invoker
+		invoker = func(ctx context.Context, method string, req, reply any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return interceptor(ctx, method, req, reply, nil, next, opts...)
+		}
+	}
+	return invoker(ctx, method, args, reply, nil, opts...)
+}
+
+// NewStream is never needed, every ariand call the importer makes is unary
+func (c *connectConn) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Error(codes.Unimplemented, "streaming calls are not supported over the connect transport")
+}
+
+func (c *connectConn) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+func (c *connectConn) call(ctx context.Context, method string, args, reply any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+	body, err := proto.Marshal(args.(proto.Message))
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to encode request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, bytes.NewReader(body))
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/proto")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 1), 10))
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		// Only a connection that was never made says ariand didn't get the call. A connection lost
+		// after that may have lost the answer to a call ariand carried out.
+		if notSent(err) {
+			return status.Errorf(codes.Unavailable, "%v", err)
+		}
+		return status.Errorf(codes.Unknown, "%v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return status.Errorf(codes.Unknown, "failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return connectError(resp.StatusCode, data)
+	}
+
+	if err := proto.Unmarshal(data, reply.(proto.Message)); err != nil {
+		return status.Errorf(codes.Internal, "failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// notSent reports whether err is from before the request left, resolving ariand's name or dialing it
+func notSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// connectError reads the JSON error body, falling back to the HTTP status for proxies that answer
+// with an error page of their own
+func connectError(httpStatus int, body []byte) error {
+	var payload struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		if code, ok := connectCodes[payload.Code]; ok {
+			return status.Error(code, payload.Message)
+		}
+	}
+
+	code := codes.Unknown
+	switch httpStatus {
+	case http.StatusBadRequest:
+		code = codes.Internal
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		code = codes.Unavailable
+	}
+	return status.Errorf(code, "HTTP %d: %s", httpStatus, strings.TrimSpace(string(body)))
+}
+
+// compile-time check that the generated clients accept a connectConn
+var _ grpc.ClientConnInterface = (*connectConn)(nil)
+
+// errDialOptionsNeedGRPC explains why a recording can't be made over the connect transport
+var errDialOptionsNeedGRPC = errors.New("recording and replaying need the " + TransportGRPC + " transport")
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "arian-statement-parser/internal/gen/arian/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConnectErrorCodes(t *testing.T) {
+	// A server that reads the call, then drops the connection before answering
+	dropped := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer dropped.Close()
+
+	// A port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name    string
+		address string
+		want    codes.Code
+	}{
+		{"never connected", closed, codes.Unavailable},
+		{"answer lost", dropped.URL, codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newConnectConn(tt.address, nil)
+			err := conn.Invoke(context.Background(), "/arian.v1.UserService/GetUser", &pb.GetUserRequest{}, &pb.GetUserResponse{})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %v (%v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
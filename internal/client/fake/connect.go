@@ -0,0 +1,105 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	pb "arian-statement-parser/internal/gen/arian/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// StartConnect serves the fake over the Connect protocol on plain HTTP/1.1 and returns its base URL.
+// The unary handlers come from the generated service descriptions, so every method the gRPC fake
+// has is served the same way.
+func (s *Server) StartConnect() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to listen: %w", err)
+	}
+
+	methods := make(map[string]grpc.MethodDesc)
+	for _, service := range []grpc.ServiceDesc{pb.UserService_ServiceDesc, pb.AccountService_ServiceDesc, pb.TransactionService_ServiceDesc, pb.CategoryService_ServiceDesc} {
+		for _, method := range service.Methods {
+			methods["/"+service.ServiceName+"/"+method.MethodName] = method
+		}
+	}
+
+	s.http = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, ok := methods[r.URL.Path]
+		if !ok || r.Method != http.MethodPost {
+			writeConnectError(w, status.Error(codes.Unimplemented, r.URL.Path+" not found"))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeConnectError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+
+		md := metadata.MD{}
+		for key, values := range r.Header {
+			md.Append(strings.ToLower(key), values...)
+		}
+		ctx := metadata.NewIncomingContext(r.Context(), md)
+
+		decode := func(v any) error { return proto.Unmarshal(body, v.(proto.Message)) }
+		reply, err := method.Handler(s, ctx, decode, s.authorize)
+		if err != nil {
+			writeConnectError(w, err)
+			return
+		}
+
+		data, err := proto.Marshal(reply.(proto.Message))
+		if err != nil {
+			writeConnectError(w, status.Error(codes.Internal, err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/proto")
+		w.Write(data)
+	})}
+
+	go s.http.Serve(listener)
+	return "http://" + listener.Addr().String(), nil
+}
+
+// writeConnectError answers with the Connect JSON error body for a gRPC status
+func writeConnectError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	httpStatus := http.StatusInternalServerError
+	switch st.Code() {
+	case codes.Unauthenticated:
+		httpStatus = http.StatusUnauthorized
+	case codes.NotFound, codes.Unimplemented:
+		httpStatus = http.StatusNotFound
+	case codes.AlreadyExists:
+		httpStatus = http.StatusConflict
+	case codes.InvalidArgument:
+		httpStatus = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(map[string]string{"code": snakeCase(st.Code().String()), "message": st.Message()})
+}
+
+// snakeCase turns a code name like AlreadyExists into already_exists
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
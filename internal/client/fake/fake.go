@@ -0,0 +1,376 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	pb "arian-statement-parser/internal/gen/arian/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server is an in-memory stand-in for ariand covering the calls the importer makes
+type Server struct {
+	pb.UnimplementedUserServiceServer
+	pb.UnimplementedAccountServiceServer
+	pb.UnimplementedTransactionServiceServer
+	pb.UnimplementedCategoryServiceServer
+
+	apiKey string
+	// PageLimit caps list pages like ariand does, 0 means no cap
+	PageLimit int
+
+	mu           sync.Mutex
+	users        map[string]*pb.User
+	accounts     map[int64]*pb.Account
+	transactions []*pb.Transaction
+	categories   []*pb.Category
+	nextID       int64
+
+	grpc     *grpc.Server
+	http     *http.Server
+	listener net.Listener
+}
+
+// New returns an empty fake; an empty apiKey accepts any caller
+func New(apiKey string) *Server {
+	return &Server{
+		apiKey:   apiKey,
+		users:    make(map[string]*pb.User),
+		accounts: make(map[int64]*pb.Account),
+	}
+}
+
+// AddUser seeds a user so GetUser succeeds for it
+func (s *Server) AddUser(id, email string) *pb.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := &pb.User{Id: id, Email: email, CreatedAt: timestamppb.Now()}
+	s.users[id] = user
+	return user
+}
+
+// AddAccount seeds an account owned by userID
+func (s *Server) AddAccount(userID, name, bank string, accountType pb.AccountType) *pb.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addAccount(userID, name, bank, accountType, "CAD")
+}
+
+func (s *Server) addAccount(userID, name, bank string, accountType pb.AccountType, currency string) *pb.Account {
+	s.nextID++
+	now := timestamppb.Now()
+	account := &pb.Account{
+		Id:           s.nextID,
+		OwnerId:      userID,
+		Name:         name,
+		Bank:         bank,
+		Type:         accountType,
+		MainCurrency: currency,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.accounts[account.Id] = account
+	return account
+}
+
+// AddCategory seeds a category, visible to every user
+func (s *Server) AddCategory(slug string) *pb.Category {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	category := &pb.Category{Id: s.nextID, Slug: slug}
+	s.categories = append(s.categories, category)
+	return category
+}
+
+// Accounts returns a snapshot of every stored account ordered by ID
+func (s *Server) Accounts() []*pb.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := make([]*pb.Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Id < accounts[j].Id })
+	return accounts
+}
+
+// Transactions returns a snapshot of every stored transaction in insertion order
+func (s *Server) Transactions() []*pb.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*pb.Transaction(nil), s.transactions...)
+}
+
+// Start serves the fake on a loopback port and returns the address to dial
+func (s *Server) Start() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to listen: %w", err)
+	}
+
+	s.listener = listener
+	s.grpc = grpc.NewServer(grpc.UnaryInterceptor(s.authorize))
+	pb.RegisterUserServiceServer(s.grpc, s)
+	pb.RegisterAccountServiceServer(s.grpc, s)
+	pb.RegisterTransactionServiceServer(s.grpc, s)
+	pb.RegisterCategoryServiceServer(s.grpc, s)
+	reflection.Register(s.grpc)
+
+	go s.grpc.Serve(listener)
+	return listener.Addr().String(), nil
+}
+
+// Stop shuts the server down, dropping in-flight calls
+func (s *Server) Stop() {
+	if s.grpc != nil {
+		s.grpc.Stop()
+	}
+	if s.http != nil {
+		s.http.Close()
+	}
+}
+
+// authorize mirrors ariand's x-internal-key check
+func (s *Server) authorize(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.apiKey != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if keys := md.Get("x-internal-key"); len(keys) == 0 || keys[0] != s.apiKey {
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) GetUser(_ context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[req.Id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "user %s not found", req.Id)
+	}
+	return &pb.GetUserResponse{User: user}, nil
+}
+
+func (s *Server) ListAccounts(_ context.Context, req *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	var accounts []*pb.Account
+	for _, account := range s.Accounts() {
+		if account.OwnerId == req.UserId {
+			accounts = append(accounts, account)
+		}
+	}
+	return &pb.ListAccountsResponse{Accounts: accounts}, nil
+}
+
+func (s *Server) GetAccount(_ context.Context, req *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[req.Id]
+	if !ok || account.OwnerId != req.UserId {
+		return nil, status.Errorf(codes.NotFound, "account %d not found", req.Id)
+	}
+	return &pb.GetAccountResponse{Account: account}, nil
+}
+
+func (s *Server) CreateAccount(_ context.Context, req *pb.CreateAccountRequest) (*pb.CreateAccountResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[req.UserId]; !ok {
+		return nil, status.Errorf(codes.NotFound, "user %s not found", req.UserId)
+	}
+
+	account := s.addAccount(req.UserId, req.Name, req.Bank, req.Type, req.MainCurrency)
+	account.Alias = req.Alias
+	account.AnchorBalance = req.AnchorBalance
+	account.Colors = req.Colors
+	return &pb.CreateAccountResponse{Account: account}, nil
+}
+
+// UpdateAccount applies the fields named in the update mask
+func (s *Server) UpdateAccount(_ context.Context, req *pb.UpdateAccountRequest) (*pb.UpdateAccountResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[req.Id]
+	if !ok || account.OwnerId != req.UserId {
+		return nil, status.Errorf(codes.NotFound, "account %d not found", req.Id)
+	}
+
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		switch path {
+		case "anchor_balance":
+			account.AnchorBalance = req.AnchorBalance
+		case "anchor_date":
+			account.AnchorDate = req.AnchorDate
+		case "name":
+			account.Name = req.GetName()
+		case "account_type":
+			account.Type = req.GetAccountType()
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update path %s", path)
+		}
+	}
+	account.UpdatedAt = timestamppb.Now()
+	return &pb.UpdateAccountResponse{}, nil
+}
+
+// CreateTransaction stores the batch, rejecting it whole with AlreadyExists if any line is a duplicate
+func (s *Server) CreateTransaction(_ context.Context, req *pb.CreateTransactionRequest) (*pb.CreateTransactionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(s.transactions))
+	for _, tx := range s.transactions {
+		seen[fingerprint(tx.AccountId, tx.TxDate, tx.TxAmount.GetUnits(), tx.TxAmount.GetNanos(), tx.GetDescription())] = true
+	}
+
+	for _, input := range req.Transactions {
+		account, ok := s.accounts[input.AccountId]
+		if !ok || account.OwnerId != req.UserId {
+			return nil, status.Errorf(codes.NotFound, "account %d not found", input.AccountId)
+		}
+		if input.TxDate == nil || input.TxAmount == nil {
+			return nil, status.Error(codes.InvalidArgument, "tx_date and tx_amount are required")
+		}
+		if seen[fingerprint(input.AccountId, input.TxDate, input.TxAmount.Units, input.TxAmount.Nanos, input.GetDescription())] {
+			return nil, status.Error(codes.AlreadyExists, "duplicate transaction")
+		}
+	}
+
+	created := make([]*pb.Transaction, 0, len(req.Transactions))
+	for _, input := range req.Transactions {
+		s.nextID++
+		now := timestamppb.Now()
+		tx := &pb.Transaction{
+			Id:            s.nextID,
+			TxDate:        input.TxDate,
+			TxAmount:      input.TxAmount,
+			Direction:     input.Direction,
+			AccountId:     input.AccountId,
+			Description:   input.Description,
+			Merchant:      input.Merchant,
+			UserNotes:     input.UserNotes,
+			CategoryId:    input.CategoryId,
+			ForeignAmount: input.ForeignAmount,
+			ExchangeRate:  input.ExchangeRate,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			AccountName:   &s.accounts[input.AccountId].Name,
+		}
+		s.transactions = append(s.transactions, tx)
+		created = append(created, tx)
+	}
+
+	return &pb.CreateTransactionResponse{Transactions: created, CreatedCount: int32(len(created))}, nil
+}
+
+// UpdateTransaction applies the fields named in the update mask
+func (s *Server) UpdateTransaction(_ context.Context, req *pb.UpdateTransactionRequest) (*pb.UpdateTransactionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tx := range s.transactions {
+		if tx.Id != req.Id || s.accounts[tx.AccountId].OwnerId != req.UserId {
+			continue
+		}
+
+		for _, path := range req.GetUpdateMask().GetPaths() {
+			switch path {
+			case "tx_date":
+				tx.TxDate = req.TxDate
+			case "tx_amount":
+				tx.TxAmount = req.TxAmount
+			case "direction":
+				tx.Direction = req.GetDirection()
+			case "description":
+				tx.Description = req.Description
+			case "merchant":
+				tx.Merchant = req.Merchant
+			case "user_notes":
+				tx.UserNotes = req.UserNotes
+			case "category_id":
+				tx.CategoryId = req.CategoryId
+			default:
+				return nil, status.Errorf(codes.InvalidArgument, "unsupported update path %s", path)
+			}
+		}
+		tx.UpdatedAt = timestamppb.Now()
+		return &pb.UpdateTransactionResponse{}, nil
+	}
+
+	return nil, status.Errorf(codes.NotFound, "transaction %d not found", req.Id)
+}
+
+func (s *Server) ListTransactions(_ context.Context, req *pb.ListTransactionsRequest) (*pb.ListTransactionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []*pb.Transaction
+	for _, tx := range s.transactions {
+		if s.accounts[tx.AccountId].OwnerId != req.UserId {
+			continue
+		}
+		if req.AccountId != nil && tx.AccountId != *req.AccountId {
+			continue
+		}
+		if len(req.AccountIds) > 0 && !slices.Contains(req.AccountIds, tx.AccountId) {
+			continue
+		}
+		if req.StartDate != nil && tx.TxDate.AsTime().Before(req.StartDate.AsTime()) {
+			continue
+		}
+		if req.EndDate != nil && tx.TxDate.AsTime().After(req.EndDate.AsTime()) {
+			continue
+		}
+		matched = append(matched, tx)
+	}
+
+	total := int64(len(matched))
+	return &pb.ListTransactionsResponse{Transactions: pageOf(matched, req.Offset, req.Limit, s.PageLimit), TotalCount: total}, nil
+}
+
+func (s *Server) ListCategories(_ context.Context, req *pb.ListCategoriesRequest) (*pb.ListCategoriesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &pb.ListCategoriesResponse{Categories: pageOf(s.categories, req.Offset, req.Limit, s.PageLimit), TotalCount: int64(len(s.categories))}, nil
+}
+
+// pageOf applies a request's offset and limit, never handing out more than pageLimit items
+func pageOf[T any](items []T, offset, limit *int32, pageLimit int) []T {
+	if offset != nil {
+		items = items[min(int(*offset), len(items)):]
+	}
+	size := len(items)
+	if limit != nil {
+		size = min(size, int(*limit))
+	}
+	if pageLimit > 0 {
+		size = min(size, pageLimit)
+	}
+	return items[:size]
+}
+
+func fingerprint(accountID int64, date *timestamppb.Timestamp, units int64, nanos int32, desc string) string {
+	return fmt.Sprintf("%d|%s|%d.%09d|%s", accountID, date.AsTime().Format(time.DateOnly), units, nanos, desc)
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Settings configure the interceptors every call to ariand passes through, outermost first:
+// auth, logging, retry, metrics, rate limit. Dial options given to NewClient, such as a Recorder,
+// run after all of them.
+type Settings struct {
+	Retries   int           // extra attempts when ariand is unavailable or busy, 0 turns retries off
+	Backoff   time.Duration // wait before the first retry, doubled for each one after
+	RateLimit float64       // calls per second, 0 for no limit
+	Metrics   *Metrics      // nil turns metrics off
+	Transport string        // TransportGRPC (the default when empty) or TransportConnect
+	Logger    *log.Logger   // nil logs to stderr
+}
+
+// DefaultSettings retry a few times and don't limit the call rate
+func DefaultSettings() Settings {
+	return Settings{
+		Retries: 3,
+		Backoff: 500 * time.Millisecond,
+		Metrics: NewMetrics(),
+	}
+}
+
+// interceptors builds the chain for settings in the documented order
+func (c *Client) interceptors(settings Settings) []grpc.UnaryClientInterceptor {
+	chain := []grpc.UnaryClientInterceptor{
+		authInterceptor(c.key, c.log),
+		loggingInterceptor(c.log),
+	}
+	if settings.Retries > 0 {
+		chain = append(chain, retryInterceptor(settings.Retries, settings.Backoff))
+	}
+	if settings.Metrics != nil {
+		chain = append(chain, metricsInterceptor(settings.Metrics))
+	}
+	if settings.RateLimit > 0 {
+		chain = append(chain, rateLimitInterceptor(settings.RateLimit))
+	}
+	return chain
+}
+
+// loggingInterceptor logs every call with its duration and status code at debug level
+func loggingInterceptor(logger *log.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		logger.Debug("call", "method", method, "code", status.Code(err), "duration", time.Since(start).Round(time.Millisecond))
+		return err
+	}
+}
+
+// retryable reports whether a call to method that failed with code can be made again. Unavailable
+// doesn't say whether ariand got the call, as a connection can drop before the answer arrives, so
+// calls that create or update something are only repeated when ariand turned them away as too many.
+// A second CreateTransaction would add the transaction twice.
+func retryable(method string, code codes.Code) bool {
+	switch code {
+	case codes.ResourceExhausted:
+		return true
+	case codes.Unavailable:
+		return idempotent(method)
+	default:
+		return false
+	}
+}
+
+// idempotent reports whether making a call twice does no more than making it once
+func idempotent(method string) bool {
+	name := path.Base(method)
+	return !strings.HasPrefix(name, "Create") && !strings.HasPrefix(name, "Update")
+}
+
+// retryInterceptor repeats calls that failed with ResourceExhausted, and calls that don't change
+// anything that failed with Unavailable, waiting backoff before the first retry and twice as long
+// before each one after
+func retryInterceptor(retries int, backoff time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		wait := backoff
+		for attempt := 0; attempt < retries && retryable(method, status.Code(err)); attempt++ {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+			wait *= 2
+			err = invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return err
+	}
+}
+
+// MethodMetrics counts the attempts made for one method
+type MethodMetrics struct {
+	Method   string
+	Calls    int
+	Failures int
+	Total    time.Duration
+}
+
+// Metrics collects per-method call counts and latency; safe for concurrent use
+type Metrics struct {
+	mu      sync.Mutex
+	methods map[string]*MethodMetrics
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{methods: make(map[string]*MethodMetrics)}
+}
+
+func (m *Metrics) record(method string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.methods[method]
+	if !ok {
+		entry = &MethodMetrics{Method: method}
+		m.methods[method] = entry
+	}
+	entry.Calls++
+	entry.Total += elapsed
+	if err != nil {
+		entry.Failures++
+	}
+}
+
+// Snapshot returns a copy of the counters, sorted by method
+func (m *Metrics) Snapshot() []MethodMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]MethodMetrics, 0, len(m.methods))
+	for _, entry := range m.methods {
+		snapshot = append(snapshot, *entry)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Method < snapshot[j].Method })
+	return snapshot
+}
+
+// metricsInterceptor records every attempt, so retried calls count once per try
+func metricsInterceptor(metrics *Metrics) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		metrics.record(method, time.Since(start), err)
+		return err
+	}
+}
+
+// rateLimitInterceptor spaces calls at least 1/perSecond apart, across every goroutine using the connection
+func rateLimitInterceptor(perSecond float64) grpc.UnaryClientInterceptor {
+	interval := time.Duration(float64(time.Second) / perSecond)
+	var mu sync.Mutex
+	var next time.Time
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		mu.Lock()
+		now := time.Now()
+		slot := next
+		if slot.Before(now) {
+			slot = now
+		}
+		next = slot.Add(interval)
+		mu.Unlock()
+
+		if wait := time.Until(slot); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			case <-time.After(wait):
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
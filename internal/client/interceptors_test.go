@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// scriptedInvoker fails with the given codes in turn, then succeeds
+func scriptedInvoker(calls *int, failures ...codes.Code) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*calls++
+		if *calls <= len(failures) {
+			return status.Error(failures[*calls-1], "scripted")
+		}
+		return nil
+	}
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	retry := retryInterceptor(2, time.Millisecond)
+
+	var calls int
+	if err := retry(context.Background(), "/m", nil, nil, nil, scriptedInvoker(&calls, codes.Unavailable, codes.ResourceExhausted)); err != nil {
+		t.Fatalf("expected success on the third attempt, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("made %d attempts, want 3", calls)
+	}
+
+	calls = 0
+	err := retry(context.Background(), "/m", nil, nil, nil, scriptedInvoker(&calls, codes.Unavailable, codes.Unavailable, codes.Unavailable))
+	if status.Code(err) != codes.Unavailable || calls != 3 {
+		t.Errorf("gave up after %d attempts with %v, want 3 attempts and Unavailable", calls, err)
+	}
+
+	calls = 0
+	err = retry(context.Background(), "/m", nil, nil, nil, scriptedInvoker(&calls, codes.InvalidArgument))
+	if status.Code(err) != codes.InvalidArgument || calls != 1 {
+		t.Errorf("retried a rejected request: %d attempts, %v", calls, err)
+	}
+
+	// ariand may have created the transaction before the connection dropped
+	const create = "/arian.v1.TransactionService/CreateTransaction"
+	calls = 0
+	err = retry(context.Background(), create, nil, nil, nil, scriptedInvoker(&calls, codes.Unavailable))
+	if status.Code(err) != codes.Unavailable || calls != 1 {
+		t.Errorf("retried an unavailable create: %d attempts, %v", calls, err)
+	}
+	calls = 0
+	if err := retry(context.Background(), create, nil, nil, nil, scriptedInvoker(&calls, codes.ResourceExhausted)); err != nil || calls != 2 {
+		t.Errorf("rate limited create: %d attempts, %v; want it retried", calls, err)
+	}
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	metrics := NewMetrics()
+	record := metricsInterceptor(metrics)
+
+	var calls int
+	invoker := scriptedInvoker(&calls, codes.Unavailable)
+	record(context.Background(), "/b", nil, nil, nil, invoker)
+	record(context.Background(), "/b", nil, nil, nil, invoker)
+	record(context.Background(), "/a", nil, nil, nil, invoker)
+
+	snapshot := metrics.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Method != "/a" || snapshot[1].Calls != 2 || snapshot[1].Failures != 1 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	limit := rateLimitInterceptor(100)
+
+	var calls int
+	start := time.Now()
+	for range 5 {
+		if err := limit(context.Background(), "/m", nil, nil, nil, scriptedInvoker(&calls)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 calls at 100/s took %v, want at least 40ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limit(context.Background(), "/m", nil, nil, nil, scriptedInvoker(&calls))
+	if err := limit(ctx, "/m", nil, nil, nil, scriptedInvoker(&calls)); status.Code(err) != codes.Canceled {
+		t.Errorf("cancelled wait returned %v, want Canceled", err)
+	}
+}
+
+func TestAuthInterceptorSendsKey(t *testing.T) {
+	key := &apiKey{value: "k1"}
+	auth := authInterceptor(key, log.New(io.Discard))
+
+	var sent []string
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = append(sent, md.Get("x-internal-key")...)
+		if md.Get("x-internal-key")[0] != "k2" {
+			return status.Error(codes.Unauthenticated, "bad key")
+		}
+		return nil
+	}
+
+	if err := auth(context.Background(), "/m", nil, nil, nil, invoker); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("without a key source the rejection should pass through, got %v", err)
+	}
+
+	key.source = func() ([]string, error) { return []string{"k1", "k2"}, nil }
+	if err := auth(context.Background(), "/m", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("reloaded key rejected: %v", err)
+	}
+	if key.get() != "k2" {
+		t.Errorf("key is %q after reload, want k2", key.get())
+	}
+
+	key.source = func() ([]string, error) { return nil, errors.New("vault locked") }
+	if err := auth(context.Background(), "/m", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("current key should still work: %v", err)
+	}
+	if want := []string{"k1", "k1", "k2", "k2"}; !slices.Equal(sent, want) {
+		t.Errorf("sent keys %v, want %v", sent, want)
+	}
+}
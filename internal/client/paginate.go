@@ -0,0 +1,52 @@
+package client
+
+import (
+	"fmt"
+)
+
+// pageSize is what each list call asks for. ariand may cap it lower, so a short page alone never ends a listing.
+const pageSize = 500
+
+// maxPages stops a server that ignores the offset from looping forever
+const maxPages = 10000
+
+// page is one response of a paginated list call
+type page[T any] struct {
+	items []T
+	total int64 // 0 when the server doesn't report it
+	more  bool  // the server handed out a cursor for the next page
+}
+
+// paginate calls fetch with growing offsets until the listing is complete: an empty page, the
+// reported total reached, or a cursor-based listing without a next cursor. limit caps the result,
+// 0 means everything.
+func paginate[T any](limit int, fetch func(offset int32, size int32) (page[T], error)) ([]T, error) {
+	var all []T
+	for n := 0; n < maxPages; n++ {
+		size := int32(pageSize)
+		if limit > 0 && limit-len(all) < pageSize {
+			size = int32(limit - len(all))
+		}
+
+		p, err := fetch(int32(len(all)), size)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, p.items...)
+
+		switch {
+		case len(p.items) == 0:
+			return all, nil
+		case limit > 0 && len(all) >= limit:
+			return all[:limit], nil
+		case p.total > 0 && int64(len(all)) >= p.total:
+			return all, nil
+		case p.total == 0 && !p.more:
+			// Nothing says there is more, so a page short of what was asked for is the last
+			if len(p.items) < int(size) {
+				return all, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("listing did not end after %d pages", maxPages)
+}
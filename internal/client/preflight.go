@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "arian-statement-parser/internal/gen/arian/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons a preflight can fail, each needing a different fix
+var (
+	ErrUnreachable  = errors.New("ariand is unreachable")
+	ErrKeyRejected  = errors.New("API key rejected")
+	ErrUserNotFound = errors.New("user ID not found")
+)
+
+// preflightTimeout bounds the first call, so a wrong address fails fast instead of hanging
+const preflightTimeout = 15 * time.Second
+
+// Preflight checks the server, the API key and the user in one call before anything is parsed or
+// uploaded. The error wraps ErrUnreachable, ErrKeyRejected or ErrUserNotFound when the cause is known.
+func (c *Client) Preflight(userID string) (*pb.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+	defer cancel()
+
+	resp, err := c.userClient.GetUser(ctx, &pb.GetUserRequest{Id: userID})
+	if err == nil {
+		return resp.User, nil
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return nil, fmt.Errorf("%w: %s", ErrUnreachable, status.Convert(err).Message())
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return nil, fmt.Errorf("%w: %s", ErrKeyRejected, status.Convert(err).Message())
+	case codes.NotFound, codes.InvalidArgument:
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, status.Convert(err).Message())
+	}
+	return nil, fmt.Errorf("failed to get user: %w", err)
+}
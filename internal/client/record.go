@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Interaction is one recorded unary call; auth metadata is never written
+type Interaction struct {
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Code     string          `json:"code,omitempty"`
+	Message  string          `json:"message,omitempty"`
+}
+
+// Recorder appends every call made through the client to a JSON lines file
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	return &Recorder{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// DialOption installs the recording interceptor on a connection
+func (r *Recorder) DialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(r.intercept)
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+func (r *Recorder) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	callErr := invoker(ctx, method, req, reply, cc, opts...)
+
+	interaction := Interaction{Method: method}
+	if msg, ok := req.(proto.Message); ok {
+		interaction.Request, _ = protojson.Marshal(msg)
+	}
+	if callErr != nil {
+		st := status.Convert(callErr)
+		interaction.Code = st.Code().String()
+		interaction.Message = st.Message()
+	} else if msg, ok := reply.(proto.Message); ok {
+		interaction.Response, _ = protojson.Marshal(msg)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(interaction); err != nil {
+		return fmt.Errorf("failed to record %s: %w", method, err)
+	}
+	return callErr
+}
+
+// Replayer answers calls from a recording instead of the network
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+func NewReplayer(path string) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	r := &Replayer{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("failed to parse recording line %d: %w", line, err)
+		}
+		r.interactions = append(r.interactions, interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	r.used = make([]bool, len(r.interactions))
+	return r, nil
+}
+
+// DialOption installs the replay interceptor; the connection is never actually dialed
+func (r *Replayer) DialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(r.intercept)
+}
+
+// intercept returns the first unused recording with the same method and an equal request
+func (r *Replayer) intercept(_ context.Context, method string, req, reply any, _ *grpc.ClientConn, _ grpc.UnaryInvoker, _ ...grpc.CallOption) error {
+	reqMsg, ok := req.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "replay: %s request is not a proto message", method)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Method != method {
+			continue
+		}
+
+		recorded := reqMsg.ProtoReflect().New().Interface()
+		if err := protojson.Unmarshal(interaction.Request, recorded); err != nil || !proto.Equal(recorded, reqMsg) {
+			continue
+		}
+		r.used[i] = true
+
+		if interaction.Code != "" {
+			return status.Error(parseCode(interaction.Code), interaction.Message)
+		}
+
+		replyMsg, ok := reply.(proto.Message)
+		if !ok {
+			return status.Errorf(codes.Internal, "replay: %s reply is not a proto message", method)
+		}
+		if err := protojson.Unmarshal(interaction.Response, replyMsg); err != nil {
+			return status.Errorf(codes.Internal, "replay: bad recorded response for %s: %v", method, err)
+		}
+		return nil
+	}
+
+	return status.Errorf(codes.Unavailable, "replay: no recorded %s call matches this request", method)
+}
+
+// Unused lists recorded calls that were never replayed, a sign the run diverged from the recording
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// parseCode reverses codes.Code.String, falling back to Unknown
+func parseCode(name string) codes.Code {
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if c.String() == name {
+			return c
+		}
+	}
+	return codes.Unknown
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/client/fake"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	date := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	session := func(c *Client) (int32, []*pb.Transaction, error) {
+		if _, err := c.GetUser(testUser); err != nil {
+			return 0, nil, err
+		}
+		account, err := c.CreateAccount(testUser, "visa 9876", "RBC", pb.AccountType_ACCOUNT_CREDIT_CARD, "CAD")
+		if err != nil {
+			return 0, nil, err
+		}
+		created, errs := c.CreateTransactionsBulk(testUser, []*domain.Transaction{
+			{AccountID: int(account.Id), TxDate: date, TxAmount: 42.1, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "GROCERY"},
+		})
+		if len(errs) > 0 {
+			return 0, nil, errs[0]
+		}
+		listed, err := c.ListTransactions(testUser, 10)
+		return created, listed, err
+	}
+
+	// Record against the fake
+	server := fake.New("test-key")
+	server.AddUser(testUser, "test@example.com")
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, err := NewClient(addr, "", "test-key", recorder.DialOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCreated, wantListed, err := session(live)
+	live.Close()
+	recorder.Close()
+	if err != nil {
+		t.Fatalf("recorded session failed: %v", err)
+	}
+
+	// Replay with nothing listening
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Retrying an unmatched call would only find the same recordings again
+	offline, err := NewClientWithSettings("replay.invalid:0", "", Settings{}, replayer.DialOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer offline.Close()
+
+	created, listed, err := session(offline)
+	if err != nil {
+		t.Fatalf("replayed session failed: %v", err)
+	}
+	if created != wantCreated || len(listed) != len(wantListed) || listed[0].GetDescription() != "GROCERY" {
+		t.Errorf("replay = %d created, %d listed; recorded %d, %d", created, len(listed), wantCreated, len(wantListed))
+	}
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("%d recorded calls left over", len(unused))
+	}
+
+	// A request that was never recorded fails instead of silently succeeding
+	if _, err := offline.GetUser("someone-else"); err == nil {
+		t.Error("expected unmatched call to fail")
+	}
+}
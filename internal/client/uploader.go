@@ -0,0 +1,73 @@
+package client
+
+import (
+	"time"
+
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+)
+
+// Uploader is the backend an import sends accounts and transactions to. Client is the one that
+// talks to ariand (or the fake); internal/export writes the same calls to a file instead.
+type Uploader interface {
+	GetUser(userID string) (*pb.User, error)
+	GetAccounts(userID string) ([]*pb.Account, error)
+	CreateAccount(userID, accountName, bank string, accountType pb.AccountType, mainCurrency string) (*pb.Account, error)
+	CreateTransaction(userID string, tx *domain.Transaction) error
+	CreateTransactionsBulk(userID string, transactions []*domain.Transaction) (int32, []error)
+	Close() error
+}
+
+// CategoryLister is an Uploader that knows ariand's categories, so rule categories can become IDs
+type CategoryLister interface {
+	ListCategories(userID string) ([]*pb.Category, error)
+}
+
+// TransactionLister is an Uploader that can read back what was uploaded, to learn categories from it
+type TransactionLister interface {
+	ListTransactions(userID string, limit int32) ([]*pb.Transaction, error)
+}
+
+// RangeLister is an Uploader that can find what it already holds for some accounts and dates, so an
+// upload can leave those out
+type RangeLister interface {
+	ListTransactionsBetween(userID string, accountIDs []int64, start, end time.Time) ([]*pb.Transaction, error)
+}
+
+// IDCreator is an Uploader that can say which IDs the transactions it created got
+type IDCreator interface {
+	CreateTransactionsWithIDs(userID string, transactions []*domain.Transaction) ([]int64, []error)
+}
+
+// PendingUpdater is an Uploader that can settle a pending transaction in place once it posts
+type PendingUpdater interface {
+	CreateTransactionWithID(userID string, tx *domain.Transaction) (int64, error)
+	UpdateTransaction(userID string, id int64, tx *domain.Transaction) error
+}
+
+// BalanceSetter is an Uploader that can start an account's running balance somewhere other than zero
+type BalanceSetter interface {
+	SetOpeningBalance(userID string, accountID int64, balance float64, currency string, date time.Time) error
+}
+
+// AccountUpdater is an Uploader that can rename an account or change its type
+type AccountUpdater interface {
+	UpdateAccount(userID string, accountID int64, changes AccountChanges) error
+}
+
+// AccountCreator is an Uploader whose accounts are only labels, so every statement account gets
+// one without asking
+type AccountCreator interface {
+	CreatesAccounts() bool
+}
+
+var (
+	_ Uploader          = (*Client)(nil)
+	_ CategoryLister    = (*Client)(nil)
+	_ TransactionLister = (*Client)(nil)
+	_ PendingUpdater    = (*Client)(nil)
+	_ IDCreator         = (*Client)(nil)
+	_ RangeLister       = (*Client)(nil)
+	_ BalanceSetter     = (*Client)(nil)
+	_ AccountUpdater    = (*Client)(nil)
+)
@@ -0,0 +1,81 @@
+package dedupe
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Duplicate is a transaction dropped because another statement in the run already has it
+type Duplicate struct {
+	Tx     *domain.Transaction
+	KeptIn string // source file whose copy is uploaded
+}
+
+func (d Duplicate) String() string {
+	return fmt.Sprintf("%s %.2f %q from %s, already in %s",
+		d.Tx.TxDate.Format(time.DateOnly), d.Tx.TxAmount, d.Tx.TxDesc, filepath.Base(d.Tx.SourceFilePath), filepath.Base(d.KeptIn))
+}
+
+// key identifies a statement line independently of the file it came from
+func key(tx *domain.Transaction) string {
+	number := ""
+	if tx.StatementAccountNumber != nil {
+		number = *tx.StatementAccountNumber
+	}
+	return fmt.Sprintf("%s|%s|%s|%d|%.2f|%s|%s",
+		tx.StatementAccountType, number, tx.TxDate.Format(time.DateOnly), tx.TxDirection, tx.TxAmount, tx.TxDesc, tx.ReferenceCode)
+}
+
+// Collapser drops repeated lines as they arrive, so a run doesn't need every transaction at once to
+// find them. Identical lines within one file are real (two coffees on the same day), so for each
+// line it keeps as many copies as the file holding the most of them.
+type Collapser struct {
+	kept    map[string]int
+	keptIn  map[string]string
+	perFile map[string]map[string]int
+}
+
+// NewCollapser creates a Collapser that has seen nothing yet
+func NewCollapser() *Collapser {
+	return &Collapser{
+		kept:    make(map[string]int),
+		keptIn:  make(map[string]string),
+		perFile: make(map[string]map[string]int),
+	}
+}
+
+// Add reports whether tx is kept, and the duplicate it is otherwise
+func (c *Collapser) Add(tx *domain.Transaction) (bool, Duplicate) {
+	k := key(tx)
+	if c.perFile[k] == nil {
+		c.perFile[k] = make(map[string]int)
+	}
+	c.perFile[k][tx.SourceFilePath]++
+
+	if c.perFile[k][tx.SourceFilePath] > c.kept[k] {
+		c.kept[k]++
+		c.keptIn[k] = tx.SourceFilePath
+		return true, Duplicate{}
+	}
+	return false, Duplicate{Tx: tx, KeptIn: c.keptIn[k]}
+}
+
+// Collapse drops lines repeated across statement files, such as two PDFs covering the same days
+func Collapse(transactions []*domain.Transaction) ([]*domain.Transaction, []Duplicate) {
+	collapser := NewCollapser()
+
+	var out []*domain.Transaction
+	var dropped []Duplicate
+	for _, tx := range transactions {
+		if kept, duplicate := collapser.Add(tx); kept {
+			out = append(out, tx)
+		} else {
+			dropped = append(dropped, duplicate)
+		}
+	}
+
+	return out, dropped
+}
@@ -0,0 +1,67 @@
+package dedupe
+
+import (
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// line is a card purchase on day d of March 2024 read from file
+func line(file string, d int, amount float64, desc string) *domain.Transaction {
+	return &domain.Transaction{
+		TxDate:               time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC),
+		TxAmount:             amount,
+		TxDesc:               desc,
+		TxDirection:          domain.Out,
+		StatementAccountType: "visa",
+		SourceFilePath:       file,
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []*domain.Transaction
+		kept    int
+		dropped []string // files the dropped lines came from
+	}{
+		{
+			name:  "two coffees in one file",
+			lines: []*domain.Transaction{line("a.pdf", 5, 4.5, "COFFEE"), line("a.pdf", 5, 4.5, "COFFEE")},
+			kept:  2,
+		},
+		{
+			name:    "the same line in two files",
+			lines:   []*domain.Transaction{line("a.pdf", 5, 4.5, "COFFEE"), line("b.pdf", 5, 4.5, "COFFEE")},
+			kept:    1,
+			dropped: []string{"b.pdf"},
+		},
+		{
+			name: "two coffees in one file, one in the other",
+			lines: []*domain.Transaction{
+				line("a.pdf", 5, 4.5, "COFFEE"), line("b.pdf", 5, 4.5, "COFFEE"), line("a.pdf", 5, 4.5, "COFFEE"),
+			},
+			kept:    2,
+			dropped: []string{"b.pdf"},
+		},
+		{
+			name:  "different amounts",
+			lines: []*domain.Transaction{line("a.pdf", 5, 4.5, "COFFEE"), line("b.pdf", 5, 5.5, "COFFEE")},
+			kept:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped := Collapse(tt.lines)
+			if len(kept) != tt.kept || len(dropped) != len(tt.dropped) {
+				t.Fatalf("kept %d and dropped %v, want %d and %v", len(kept), dropped, tt.kept, tt.dropped)
+			}
+			for i, duplicate := range dropped {
+				if duplicate.Tx.SourceFilePath != tt.dropped[i] || duplicate.KeptIn != "a.pdf" {
+					t.Errorf("dropped %s", duplicate)
+				}
+			}
+		})
+	}
+}
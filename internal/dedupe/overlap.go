@@ -0,0 +1,100 @@
+package dedupe
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Period is the date range a statement file covers for one account, taken from its first and last line
+type Period struct {
+	File     string
+	From, To time.Time
+	Count    int
+}
+
+// Overlap is a pair of files whose periods for the same account intersect
+type Overlap struct {
+	Account string
+	A, B    Period
+}
+
+func (o Overlap) String() string {
+	return fmt.Sprintf("%s: %s (%s to %s) overlaps %s (%s to %s)", o.Account,
+		filepath.Base(o.A.File), o.A.From.Format(time.DateOnly), o.A.To.Format(time.DateOnly),
+		filepath.Base(o.B.File), o.B.From.Format(time.DateOnly), o.B.To.Format(time.DateOnly))
+}
+
+// accountKey groups statements of the same account across files
+func accountKey(tx *domain.Transaction) string {
+	if tx.StatementAccountNumber != nil && *tx.StatementAccountNumber != "" {
+		return tx.StatementAccountType + " " + *tx.StatementAccountNumber
+	}
+	return tx.StatementAccountType
+}
+
+// FindOverlaps lists every pair of files in the run that cover intersecting dates for one account
+func FindOverlaps(transactions []*domain.Transaction) []Overlap {
+	periods := make(map[string]map[string]*Period) // account -> file -> period
+	for _, tx := range transactions {
+		account := accountKey(tx)
+		if periods[account] == nil {
+			periods[account] = make(map[string]*Period)
+		}
+
+		p := periods[account][tx.SourceFilePath]
+		if p == nil {
+			p = &Period{File: tx.SourceFilePath, From: tx.TxDate, To: tx.TxDate}
+			periods[account][tx.SourceFilePath] = p
+		}
+		if tx.TxDate.Before(p.From) {
+			p.From = tx.TxDate
+		}
+		if tx.TxDate.After(p.To) {
+			p.To = tx.TxDate
+		}
+		p.Count++
+	}
+
+	accounts := make([]string, 0, len(periods))
+	for account := range periods {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	var overlaps []Overlap
+	for _, account := range accounts {
+		var files []Period
+		for _, p := range periods[account] {
+			files = append(files, *p)
+		}
+		sort.Slice(files, func(i, j int) bool {
+			if !files[i].From.Equal(files[j].From) {
+				return files[i].From.Before(files[j].From)
+			}
+			return files[i].File < files[j].File
+		})
+
+		// A day both files have lines on counts, as statements that meet on a day can hold its lines twice
+		for i := range files {
+			for j := i + 1; j < len(files) && !files[j].From.After(files[i].To); j++ {
+				overlaps = append(overlaps, Overlap{Account: account, A: files[i], B: files[j]})
+			}
+		}
+	}
+	return overlaps
+}
+
+// ExcludeFiles drops every transaction that came from one of the given files
+func ExcludeFiles(transactions []*domain.Transaction, files map[string]bool) []*domain.Transaction {
+	kept := make([]*domain.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		if !files[tx.SourceFilePath] {
+			kept = append(kept, tx)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,58 @@
+package dedupe
+
+import (
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestFindOverlaps(t *testing.T) {
+	chequing := line("cheq.pdf", 10, 20, "ATM")
+	chequing.StatementAccountType = "chequing"
+
+	tests := []struct {
+		name  string
+		lines []*domain.Transaction
+		want  [][2]string // the files of each overlap
+	}{
+		{
+			name:  "statements a day apart",
+			lines: []*domain.Transaction{line("feb.pdf", 1, 1, "A"), line("feb.pdf", 14, 1, "B"), line("mar.pdf", 15, 1, "C")},
+		},
+		{
+			name:  "statements that share a day",
+			lines: []*domain.Transaction{line("feb.pdf", 1, 1, "A"), line("feb.pdf", 14, 1, "B"), line("mar.pdf", 14, 1, "C")},
+			want:  [][2]string{{"feb.pdf", "mar.pdf"}},
+		},
+		{
+			name:  "one statement inside another",
+			lines: []*domain.Transaction{line("q1.pdf", 1, 1, "A"), line("q1.pdf", 30, 1, "B"), line("mar.pdf", 10, 1, "C")},
+			want:  [][2]string{{"q1.pdf", "mar.pdf"}},
+		},
+		{
+			name:  "different accounts",
+			lines: []*domain.Transaction{line("visa.pdf", 1, 1, "A"), line("visa.pdf", 30, 1, "B"), chequing},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overlaps := FindOverlaps(tt.lines)
+			if len(overlaps) != len(tt.want) {
+				t.Fatalf("overlaps = %v, want %v", overlaps, tt.want)
+			}
+			for i, overlap := range overlaps {
+				if overlap.A.File != tt.want[i][0] || overlap.B.File != tt.want[i][1] {
+					t.Errorf("overlap = %s, want %v", overlap, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExcludeFiles(t *testing.T) {
+	lines := []*domain.Transaction{line("a.pdf", 1, 1, "A"), line("b.pdf", 1, 1, "A"), line("a.pdf", 2, 1, "B")}
+	kept := ExcludeFiles(lines, map[string]bool{"a.pdf": true})
+	if len(kept) != 1 || kept[0].SourceFilePath != "b.pdf" {
+		t.Errorf("kept %v", kept)
+	}
+}
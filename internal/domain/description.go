@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"cmp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxDescription is the longest description uploaded by default, in characters. ariand rejects a
+// whole request over a description it won't store, so longer ones are cut to fit.
+const MaxDescription = 255
+
+// CleanDescription makes the description safe to upload: control characters and bytes that aren't
+// UTF-8, which PDFs leave behind, are dropped, accents are composed with their letters, runs of
+// whitespace become one space, and a
+// description longer than max characters is cut at a word with an ellipsis. The full description
+// is kept in the user notes when it was cut. A max of 0 or less leaves the length alone.
+// CleanDescription reports whether the description changed.
+func (t *Transaction) CleanDescription(max int) bool {
+	cleaned := cleanText(t.TxDesc)
+	full := cleaned
+	if max > 0 {
+		cleaned = truncate(cleaned, max)
+	}
+	if cleaned == t.TxDesc {
+		return false
+	}
+
+	if cleaned != full {
+		if t.UserNotes != "" {
+			t.UserNotes += "\n"
+		}
+		t.UserNotes += "description: " + full
+	}
+	t.TxDesc = cleaned
+	return true
+}
+
+// methodDescriptions name lines that have nothing but their method to go by, money out first
+var methodDescriptions = map[Method][2]string{
+	MethodPOS:       {"Debit Card Purchase", "Debit Card Refund"},
+	MethodCard:      {"Card Purchase", "Card Refund"},
+	MethodATM:       {"ATM Withdrawal", "ATM Deposit"},
+	MethodETransfer: {"e-Transfer Sent", "e-Transfer Received"},
+	MethodPreAuth:   {"Pre-authorized Debit", "Pre-authorized Credit"},
+	MethodCheque:    {"Cheque", "Cheque Deposit"},
+	MethodOnline:    {"Online Payment", "Online Transfer In"},
+	MethodDeposit:   {"Withdrawal", "Deposit"},
+	MethodFee:       {"Service Charge", "Fee Rebate"},
+	MethodTrade:     {"Purchase of Securities", "Sale of Securities"},
+	MethodDividend:  {"Dividend", "Dividend"},
+}
+
+// FillDescription makes up a description for a line that has none, which is no use in ariand: the
+// merchant when there is one, else what the method, kind or category says, with the reference code
+// after it, like "ATM Withdrawal #0234". FillDescription reports whether it filled one in.
+func (t *Transaction) FillDescription() bool {
+	if strings.TrimSpace(t.TxDesc) != "" {
+		return false
+	}
+
+	description := t.Merchant
+	if description == "" {
+		description = t.placeholder()
+	}
+	if t.ReferenceCode != "" {
+		description += " #" + t.ReferenceCode
+	}
+	t.TxDesc = description
+	t.Provenance = append(t.Provenance, "description: filled in, the statement had none")
+	return true
+}
+
+// slugSeparators are how category slugs write spaces
+var slugSeparators = strings.NewReplacer("_", " ", "-", " ")
+
+// placeholder names the line by the most telling thing known about it
+func (t *Transaction) placeholder() string {
+	side := 0
+	if t.TxDirection == In {
+		side = 1
+	}
+	if names, ok := methodDescriptions[t.Method]; ok {
+		return names[side]
+	}
+	switch t.Kind {
+	case KindCardPayment:
+		return "Card Payment"
+	case KindInterest:
+		return "Interest"
+	case KindRefund:
+		return "Refund"
+	}
+	if category := cmp.Or(t.BankCategory, t.Category); category != "" {
+		first, size := utf8.DecodeRuneInString(category)
+		return string(unicode.ToUpper(first)) + slugSeparators.Replace(category[size:])
+	}
+	return [2]string{"Withdrawal", "Deposit"}[side]
+}
+
+// FoldAccents spells the description and merchant without accents, "Café Dépôt" as "Cafe Depot"
+func (t *Transaction) FoldAccents() {
+	t.TxDesc = foldAccents(t.TxDesc)
+	t.Merchant = foldAccents(t.Merchant)
+}
+
+// ligatures are letters that don't decompose into a letter and an accent
+var ligatures = strings.NewReplacer("œ", "oe", "Œ", "OE", "æ", "ae", "Æ", "AE", "ß", "ss")
+
+func foldAccents(text string) string {
+	decomposed := norm.NFD.String(ligatures.Replace(text))
+	return norm.NFC.String(strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, decomposed))
+}
+
+// cleanText drops what isn't printable text, composes accents with their letters and collapses
+// whitespace
+func cleanText(text string) string {
+	text = norm.NFC.String(strings.ToValidUTF8(text, ""))
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r), r == utf8.RuneError:
+			// Zero-width and direction marks too, which show as nothing but don't compare equal
+			return -1
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// truncate cuts text to at most max characters, at the last space when there is one in the second
+// half, so a word isn't split, and marks the cut with an ellipsis
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	if max == 1 {
+		return "…"
+	}
+
+	cut := runes[:max-1]
+	if space := strings.LastIndex(string(cut), " "); space >= 0 && utf8.RuneCountInString(string(cut)[:space]) >= max/2 {
+		cut = []rune(string(cut)[:space])
+	}
+	return strings.TrimRight(string(cut), " ") + "…"
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanDescription(t *testing.T) {
+	tests := []struct {
+		desc  string
+		max   int
+		want  string
+		notes string
+	}{
+		{"COFFEE SHOP", 255, "COFFEE SHOP", ""},
+		{"  COFFEE\x00 \t\nSHOP\u200b ", 255, "COFFEE SHOP", ""},
+		{"CAF\xe9 ROYAL", 255, "CAF ROYAL", ""},
+		{"AMAZON MARKETPLACE PAYMENTS", 20, "AMAZON MARKETPLACE…", "description: AMAZON MARKETPLACE PAYMENTS"},
+		{"SUPERCALIFRAGILISTIC", 10, "SUPERCALI…", "description: SUPERCALIFRAGILISTIC"},
+		{strings.Repeat("A ", 200), 0, strings.TrimSpace(strings.Repeat("A ", 200)), ""},
+	}
+	for _, test := range tests {
+		tx := &Transaction{TxDesc: test.desc}
+		tx.CleanDescription(test.max)
+		if tx.TxDesc != test.want || tx.UserNotes != test.notes {
+			t.Errorf("CleanDescription(%q, %d) = %q with notes %q, want %q with notes %q", test.desc, test.max, tx.TxDesc, tx.UserNotes, test.want, test.notes)
+		}
+		if n := len([]rune(tx.TxDesc)); test.max > 0 && n > test.max {
+			t.Errorf("CleanDescription(%q, %d) left %d characters", test.desc, test.max, n)
+		}
+	}
+}
+
+func TestFillDescription(t *testing.T) {
+	tests := []struct {
+		tx   Transaction
+		want string
+	}{
+		{Transaction{TxDesc: "COFFEE SHOP", Method: MethodATM}, "COFFEE SHOP"},
+		{Transaction{Method: MethodATM, TxDirection: Out, ReferenceCode: "0234"}, "ATM Withdrawal #0234"},
+		{Transaction{TxDesc: " ", Method: MethodETransfer, TxDirection: In}, "e-Transfer Received"},
+		{Transaction{Merchant: "Blue Bottle Coffee", Method: MethodPOS}, "Blue Bottle Coffee"},
+		{Transaction{Kind: KindInterest, TxDirection: In}, "Interest"},
+		{Transaction{BankCategory: "eating_out"}, "Eating out"},
+		{Transaction{TxDirection: In}, "Deposit"},
+	}
+	for _, test := range tests {
+		tx := test.tx
+		filled := tx.FillDescription()
+		if tx.TxDesc != test.want {
+			t.Errorf("FillDescription(%+v) = %q, want %q", test.tx, tx.TxDesc, test.want)
+		}
+		if filled != (test.tx.TxDesc != test.want) || filled != (len(tx.Provenance) == 1) {
+			t.Errorf("FillDescription(%+v) reported %v with provenance %q", test.tx, filled, tx.Provenance)
+		}
+	}
+}
+
+func TestFoldAccents(t *testing.T) {
+	// The e and its accent are two characters here, as some PDFs write them
+	tx := &Transaction{TxDesc: "CAFÉ DÉPÔT MONTRE\u0301AL", Merchant: "Bœuf & Cie"}
+	tx.FoldAccents()
+	if tx.TxDesc != "CAFE DEPOT MONTREAL" || tx.Merchant != "Boeuf & Cie" {
+		t.Fatalf("FoldAccents() = %q, %q", tx.TxDesc, tx.Merchant)
+	}
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// Statement is what a card statement's summary says besides its lines. Fields the statement
+// doesn't print are nil.
+type Statement struct {
+	CreditLimit     *float64 `json:"credit_limit,omitempty"`
+	InterestCharged *float64 `json:"interest_charged,omitempty"`
+	MinimumPayment  *float64 `json:"minimum_payment,omitempty"`
+	// ClosingDate is the last day the statement covers, as YYYY-MM-DD
+	ClosingDate string `json:"closing_date,omitempty"`
+}
+
+// Closing returns the last day the statement covers, if it says
+func (s *Statement) Closing() (time.Time, bool) {
+	if s == nil || s.ClosingDate == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(time.DateOnly, s.ClosingDate)
+	return date, err == nil
+}
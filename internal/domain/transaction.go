@@ -1,6 +1,11 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
 
 type Direction int
 
@@ -9,19 +14,157 @@ const (
 	Out
 )
 
+// Kind classifies what a statement line represents beyond its direction
+type Kind int
+
+const (
+	KindUnknown     Kind = iota
+	KindPurchase         // card spending
+	KindRefund           // merchant credit back to a card
+	KindCardPayment      // paying the card off, money moving between your own accounts
+	KindInterest         // interest paid on savings, a GIC or a term deposit
+)
+
+// Method is how the money moved, as far as the statement line tells
+type Method string
+
+const (
+	MethodUnknown   Method = ""
+	MethodPOS       Method = "pos"        // debit card purchase
+	MethodCard      Method = "card"       // credit card purchase or credit
+	MethodATM       Method = "atm"        // cash withdrawal or deposit at a machine
+	MethodETransfer Method = "e-transfer" // Interac e-Transfer
+	MethodPreAuth   Method = "pre-auth"   // pre-authorized debit, bill autopay
+	MethodCheque    Method = "cheque"
+	MethodOnline    Method = "online" // online or telephone banking, bill payments
+	MethodDeposit   Method = "deposit"
+	MethodFee       Method = "fee"      // service charges and interest
+	MethodTrade     Method = "trade"    // buying or selling a security, the cash side of it
+	MethodDividend  Method = "dividend" // dividends and fund distributions
+)
+
 type Transaction struct {
-	AccountID   int
-	EmailID     string
-	TxDate      time.Time
+	AccountID int
+	EmailID   string
+	TxDate    time.Time
+	// PostingDate is when the bank posted the line, zero when the statement doesn't say
+	PostingDate time.Time
 	TxAmount    float64
 	TxCurrency  string
 	TxDirection Direction
 	TxDesc      string
 	Merchant    string
 	UserNotes   string
+	Kind        Kind
+	Pending     bool // not yet posted by the bank, amount and description may still change
+	// Balance is the account balance after the line, when the statement says
+	Balance *float64
+	// Loan is how a loan payment divides between principal and interest, nil for other lines
+	Loan *LoanSplit
+	// Confidence is how sure the parser is it read the line right, from 0 to 1
+	Confidence        float64
+	ConfidenceReasons []string
+	// ReferenceCode is the cheque number or bank reference printed on the statement line
+	ReferenceCode string
+	Method        Method
+	// Original is the line in the currency it was converted from or to, nil when no currency changed
+	Original *Money
+	// BankCategory is the category the bank's export gave the line, e.g. Monzo's eating_out
+	BankCategory string
+	// Category is a category slug picked by rules or policies, resolved to CategoryID before upload
+	Category   string
+	CategoryID *int64
 	// Account matching info from statement
 	StatementAccountNumber *string
 	StatementAccountType   string
 	StatementAccountName   string
+	StatementBank          string
 	SourceFilePath         string
+	// SourcePage and SourceLine locate the line in its file, 0 when the parser can't tell
+	SourcePage int
+	SourceLine int
+	// Provenance says what changed the line from how it was read, e.g. "direction: in, by rule 3",
+	// one entry per change
+	Provenance []string
+}
+
+// Money is an amount in a currency
+type Money struct {
+	Amount   float64
+	Currency string
+}
+
+// LoanSplit is the principal and interest parts of a loan payment
+type LoanSplit struct {
+	Principal float64
+	Interest  float64
+}
+
+// Notes returns the user notes with statement metadata appended as "key: value" lines, which is
+// where ariand keeps details that have no field of their own
+func (t *Transaction) Notes() string {
+	var lines []string
+	if t.UserNotes != "" {
+		lines = append(lines, t.UserNotes)
+	}
+	if t.ReferenceCode != "" {
+		lines = append(lines, "ref: "+t.ReferenceCode)
+	}
+	if t.Method != MethodUnknown {
+		lines = append(lines, "method: "+string(t.Method))
+	}
+	// The rate is what one unit of the original currency cost in the line's
+	if t.Original != nil && t.Original.Amount != 0 {
+		lines = append(lines, fmt.Sprintf("fx: %.2f %s @ %.4f", t.Original.Amount, t.Original.Currency, t.TxAmount/t.Original.Amount))
+	}
+	if t.BankCategory != "" {
+		lines = append(lines, "bank category: "+t.BankCategory)
+	}
+	if t.Loan != nil {
+		lines = append(lines, fmt.Sprintf("principal: %.2f", t.Loan.Principal), fmt.Sprintf("interest: %.2f", t.Loan.Interest))
+	}
+	lines = append(lines, t.Provenance...)
+	if source := t.Source(); source != "" {
+		lines = append(lines, "source: "+source)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Invert turns the line around, for a statement that signs its amounts the other way: money in
+// becomes money out, and the balance changes sign. The kind depends on the direction, so it is left
+// for the caller to work out again.
+func (t *Transaction) Invert() {
+	if t.TxDirection == In {
+		t.TxDirection = Out
+	} else {
+		t.TxDirection = In
+	}
+	if t.Balance != nil {
+		balance := -*t.Balance
+		t.Balance = &balance
+	}
+}
+
+// String names the direction the way rules and notes write it, "in" or "out"
+func (d Direction) String() string {
+	if d == In {
+		return "in"
+	}
+	return "out"
+}
+
+// Source says where the line was read, e.g. "june.pdf, page 2, line 14", or is empty when the
+// parser didn't say
+func (t *Transaction) Source() string {
+	if t.SourcePage == 0 && t.SourceLine == 0 {
+		return ""
+	}
+	source := filepath.Base(t.SourceFilePath)
+	if t.SourcePage > 0 {
+		source += fmt.Sprintf(", page %d", t.SourcePage)
+	}
+	if t.SourceLine > 0 {
+		source += fmt.Sprintf(", line %d", t.SourceLine)
+	}
+	return source
 }
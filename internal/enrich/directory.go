@@ -0,0 +1,203 @@
+package enrich
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+//go:embed merchants.json
+var bundled []byte
+
+// Merchant is what the directory knows about a business
+type Merchant struct {
+	Name     string `json:"name"`
+	Match    string `json:"match,omitempty"` // regular expression over the statement description
+	Website  string `json:"website,omitempty"`
+	Category string `json:"category,omitempty"` // ariand category slug for lines nothing else categorized
+
+	re *regexp.Regexp
+}
+
+// Directory looks up merchants by description or by name. The bundled list covers common Canadian
+// merchants; entries from a file of your own come first, so they can correct it.
+type Directory struct {
+	merchants []Merchant
+}
+
+// LoadDirectory reads the bundled merchants, plus the ones in path if it isn't empty
+func LoadDirectory(path string) (*Directory, error) {
+	var own []Merchant
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read merchant data: %w", err)
+		}
+		if err := json.Unmarshal(data, &own); err != nil {
+			return nil, fmt.Errorf("failed to parse merchant data: %w", err)
+		}
+	}
+
+	var builtin []Merchant
+	if err := json.Unmarshal(bundled, &builtin); err != nil {
+		return nil, fmt.Errorf("failed to parse bundled merchant data: %w", err)
+	}
+
+	d := &Directory{merchants: append(own, builtin...)}
+	for i := range d.merchants {
+		m := &d.merchants[i]
+		if m.Name == "" {
+			return nil, fmt.Errorf("merchant %d has no name", i+1)
+		}
+		if m.Match == "" {
+			continue
+		}
+		re, err := regexp.Compile(m.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match for merchant %s: %w", m.Name, err)
+		}
+		m.re = re
+	}
+	return d, nil
+}
+
+// Lookup finds the merchant of a transaction, by its merchant name when it has one and by its
+// description otherwise
+func (d *Directory) Lookup(tx *domain.Transaction) *Merchant {
+	for i := range d.merchants {
+		m := &d.merchants[i]
+		if tx.Merchant != "" && strings.EqualFold(tx.Merchant, m.Name) {
+			return m
+		}
+		if m.re != nil && m.re.MatchString(tx.TxDesc) {
+			return m
+		}
+	}
+	return nil
+}
+
+// Apply fills in the merchant, website and category of every transaction the directory knows.
+// Names and categories already set, by rules or the language model, are kept. It returns how many
+// transactions were found.
+func (d *Directory) Apply(transactions []*domain.Transaction) int {
+	found := 0
+	for _, tx := range transactions {
+		m := d.Lookup(tx)
+		if m == nil {
+			continue
+		}
+		found++
+
+		if tx.Merchant == "" {
+			tx.Merchant = m.Name
+		}
+		if m.Category != "" && tx.Category == "" && tx.CategoryID == nil {
+			tx.Category = m.Category
+		}
+		addWebsite(tx, m.Website)
+	}
+	return found
+}
+
+// addWebsite records a website as a "website:" line in the notes, since ariand has no field for it
+func addWebsite(tx *domain.Transaction, website string) {
+	if website == "" || strings.Contains(tx.UserNotes, "website: ") {
+		return
+	}
+	if tx.UserNotes != "" {
+		tx.UserNotes += "\n"
+	}
+	tx.UserNotes += "website: " + website
+}
+
+// Lookup asks a company search API for the websites of merchants the directory doesn't know, like
+// Clearbit's autocomplete: GET with the name in the query, answered by a JSON array of objects
+// with a "domain". Only merchant names are sent, and answers are cached.
+type Lookup struct {
+	url    string // with {name} where the merchant name goes
+	client *http.Client
+	cache  *Cache
+}
+
+// NewLookup creates a lookup against endpoint, e.g. https://autocomplete.clearbit.com/v1/companies/suggest?query={name}
+func NewLookup(endpoint string, cache *Cache) (*Lookup, error) {
+	if !strings.Contains(endpoint, "{name}") {
+		return nil, fmt.Errorf("lookup URL %q has no {name}", endpoint)
+	}
+	return &Lookup{url: endpoint, client: &http.Client{Timeout: 15 * time.Second}, cache: cache}, nil
+}
+
+// Websites adds the website of every named merchant that has none yet. It returns how many
+// transactions got one. Websites found before an error are kept.
+func (l *Lookup) Websites(ctx context.Context, transactions []*domain.Transaction) (int, error) {
+	asked := false
+	var err error
+	for _, tx := range transactions {
+		if tx.Merchant == "" || strings.Contains(tx.UserNotes, "website: ") {
+			continue
+		}
+		if _, ok := l.cache.Websites[tx.Merchant]; !ok {
+			var website string
+			if website, err = l.find(ctx, tx.Merchant); err != nil {
+				break
+			}
+			l.cache.Websites[tx.Merchant] = website
+			asked = true
+		}
+	}
+
+	if asked {
+		if saveErr := l.cache.Save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+
+	added := 0
+	for _, tx := range transactions {
+		if website := l.cache.Websites[tx.Merchant]; tx.Merchant != "" && website != "" && !strings.Contains(tx.UserNotes, "website: ") {
+			addWebsite(tx, website)
+			added++
+		}
+	}
+	return added, err
+}
+
+// find returns the domain of the first company the API suggests, or nothing if it knows none
+func (l *Lookup) find(ctx context.Context, name string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(l.url, "{name}", url.QueryEscape(name)), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build lookup request: %w", err)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("lookup of %s returned %s: %s", name, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var companies []struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&companies); err != nil {
+		return "", fmt.Errorf("failed to decode lookup of %s: %w", name, err)
+	}
+	if len(companies) == 0 {
+		return "", nil
+	}
+	return companies[0].Domain, nil
+}
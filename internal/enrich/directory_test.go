@@ -0,0 +1,117 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestDirectoryApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "merchants.json")
+	own := `[{"name": "Corner Deli", "match": "(?i)^CORNER DELI", "category": "restaurants"},
+		{"name": "Tim Hortons", "match": "(?i)TIM HORTONS", "website": "timhortons.com", "category": "snacks"}]`
+	if err := os.WriteFile(path, []byte(own), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	directory, err := LoadDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := []*domain.Transaction{
+		{TxDesc: "TIM HORTONS #2231 TORONTO"},
+		{TxDesc: "AMZN Mktp CA*2K4LL1", Category: "books", UserNotes: "gift"},
+		{TxDesc: "SQ *NETFLX", Merchant: "Netflix"},
+		{TxDesc: "CORNER DELI 42"},
+		{TxDesc: "E-TRANSFER SENT"},
+	}
+	if found := directory.Apply(transactions); found != 4 {
+		t.Errorf("Apply() = %d, want 4", found)
+	}
+
+	want := []domain.Transaction{
+		{Merchant: "Tim Hortons", Category: "snacks", UserNotes: "website: timhortons.com"},
+		{Merchant: "Amazon", Category: "books", UserNotes: "gift\nwebsite: amazon.ca"},
+		{Merchant: "Netflix", Category: "subscriptions", UserNotes: "website: netflix.com"},
+		{Merchant: "Corner Deli", Category: "restaurants"},
+		{},
+	}
+	for i, tx := range transactions {
+		if tx.Merchant != want[i].Merchant || tx.Category != want[i].Category || tx.UserNotes != want[i].UserNotes {
+			t.Errorf("transaction %d = %q, %q, %q, want %q, %q, %q", i, tx.Merchant, tx.Category, tx.UserNotes,
+				want[i].Merchant, want[i].Category, want[i].UserNotes)
+		}
+	}
+
+	// Applying again, as a retried import would, adds nothing twice
+	directory.Apply(transactions[:1])
+	if transactions[0].UserNotes != "website: timhortons.com" {
+		t.Errorf("notes after second Apply = %q", transactions[0].UserNotes)
+	}
+}
+
+func TestLoadDirectoryRejectsBadPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "merchants.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "Broken", "match": "(unclosed"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDirectory(path); err == nil {
+		t.Error("LoadDirectory accepted an invalid pattern")
+	}
+}
+
+func TestLookupWebsites(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		queries = append(queries, query)
+		companies := []map[string]string{}
+		if query == "Blue Bottle Coffee" {
+			companies = append(companies, map[string]string{"name": "Blue Bottle", "domain": "bluebottlecoffee.com"})
+		}
+		json.NewEncoder(w).Encode(companies)
+	}))
+	defer server.Close()
+
+	cache, err := LoadCache(filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup, err := NewLookup(server.URL+"/suggest?query={name}", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := []*domain.Transaction{
+		{Merchant: "Blue Bottle Coffee"},
+		{Merchant: "Blue Bottle Coffee"},
+		{Merchant: "Corner Deli"},
+		{Merchant: "Tim Hortons", UserNotes: "website: timhortons.ca"},
+		{TxDesc: "no merchant"},
+	}
+	added, err := lookup.Websites(context.Background(), transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 {
+		t.Errorf("Websites() = %d, want 2", added)
+	}
+	if transactions[1].UserNotes != "website: bluebottlecoffee.com" || transactions[2].UserNotes != "" {
+		t.Errorf("notes = %q, %q", transactions[1].UserNotes, transactions[2].UserNotes)
+	}
+	if len(queries) != 2 {
+		t.Errorf("looked up %q, want each unknown merchant once", queries)
+	}
+}
+
+func TestNewLookupNeedsName(t *testing.T) {
+	if _, err := NewLookup("https://example.com/suggest", nil); err == nil {
+		t.Error("NewLookup accepted a URL without {name}")
+	}
+}
@@ -0,0 +1,246 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// DefaultCachePath is where cleaned merchant names are kept, next to the other state files
+const DefaultCachePath = "arian-merchants.json"
+
+// batchSize is how many descriptions go into one request
+const batchSize = 40
+
+const prompt = `You turn bank statement descriptions into clean merchant names.
+For each numbered description, give the name of the business as a person would write it, e.g.
+"SQ *BLUE BOTTLE COFFEE #42 TORONTO ON" is "Blue Bottle Coffee" and "AMZN Mktp CA*2K4LL1" is "Amazon".
+Use an empty string when there is no merchant, e.g. for transfers, fees or interest.
+Answer with only a JSON array of strings, one per description, in the same order.`
+
+// Config is an OpenAI-compatible chat completions endpoint, like OpenAI itself, Ollama or llama.cpp
+type Config struct {
+	URL    string // base URL, e.g. http://localhost:11434/v1
+	Model  string
+	APIKey string // optional for local servers
+}
+
+// Cleaner asks a language model for merchant names. Only descriptions are ever sent, never amounts,
+// dates or accounts, and every answer is cached so a description is only sent once.
+type Cleaner struct {
+	cfg    Config
+	client *http.Client
+	cache  *Cache
+}
+
+// New creates a cleaner that caches names in cache
+func New(cfg Config, cache *Cache) (*Cleaner, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("no endpoint configured")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("no model configured")
+	}
+
+	return &Cleaner{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 2 * time.Minute},
+		cache:  cache,
+	}, nil
+}
+
+// Clean sets the merchant of every transaction that has none, asking the model about descriptions
+// the cache doesn't know yet. It returns how many transactions got a merchant. Names found before an
+// error are kept, so the next run picks up where this one stopped.
+func (c *Cleaner) Clean(ctx context.Context, transactions []*domain.Transaction) (int, error) {
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, tx := range transactions {
+		description := strings.TrimSpace(tx.TxDesc)
+		if tx.Merchant != "" || description == "" || seen[description] {
+			continue
+		}
+		seen[description] = true
+		if _, ok := c.cache.Names[description]; !ok {
+			unknown = append(unknown, description)
+		}
+	}
+	sort.Strings(unknown)
+
+	var err error
+	for start := 0; start < len(unknown); start += batchSize {
+		batch := unknown[start:min(start+batchSize, len(unknown))]
+		var names []string
+		if names, err = c.ask(ctx, batch); err != nil {
+			break
+		}
+		for i, description := range batch {
+			c.cache.Names[description] = names[i]
+		}
+	}
+
+	if len(unknown) > 0 {
+		if saveErr := c.cache.Save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return c.cache.Apply(transactions), err
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// ask sends one batch of descriptions and returns a name for each
+func (c *Cleaner) ask(ctx context.Context, descriptions []string) ([]string, error) {
+	var list strings.Builder
+	for i, description := range descriptions {
+		fmt.Fprintf(&list, "%d. %s\n", i+1, description)
+	}
+
+	body, err := json.Marshal(chatRequest{
+		Model: c.cfg.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: list.String()},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.cfg.URL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", c.cfg.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s returned %s: %s", c.cfg.URL, resp.Status, bytes.TrimSpace(body))
+	}
+
+	var chat chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chat); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(chat.Choices) == 0 {
+		return nil, fmt.Errorf("%s returned no answer", c.cfg.URL)
+	}
+
+	return parseNames(chat.Choices[0].Message.Content, len(descriptions))
+}
+
+// parseNames reads the JSON array out of an answer; models like to wrap it in a code block
+func parseNames(answer string, want int) ([]string, error) {
+	start := strings.Index(answer, "[")
+	end := strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("answer has no JSON array: %.80q", answer)
+	}
+
+	var names []string
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &names); err != nil {
+		return nil, fmt.Errorf("failed to parse answer: %w", err)
+	}
+	if len(names) != want {
+		return nil, fmt.Errorf("answer has %d names for %d descriptions", len(names), want)
+	}
+
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	return names, nil
+}
+
+// Cache maps statement descriptions to merchant names, and merchant names to websites. An empty
+// value means there is none, so it isn't asked for again.
+type Cache struct {
+	Names    map[string]string `json:"names"`
+	Websites map[string]string `json:"websites,omitempty"`
+
+	path string
+}
+
+// LoadCache reads the cache at path; a missing file is an empty cache
+func LoadCache(path string) (*Cache, error) {
+	cache := &Cache{Names: make(map[string]string), Websites: make(map[string]string), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merchant cache: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, fmt.Errorf("failed to parse merchant cache: %w", err)
+	}
+	if cache.Names == nil {
+		cache.Names = make(map[string]string)
+	}
+	if cache.Websites == nil {
+		cache.Websites = make(map[string]string)
+	}
+	return cache, nil
+}
+
+// Apply sets the merchant of transactions whose description is cached, without asking anything.
+// It returns how many transactions got a merchant.
+func (c *Cache) Apply(transactions []*domain.Transaction) int {
+	applied := 0
+	for _, tx := range transactions {
+		if tx.Merchant != "" {
+			continue
+		}
+		if name := c.Names[strings.TrimSpace(tx.TxDesc)]; name != "" {
+			tx.Merchant = name
+			applied++
+		}
+	}
+	return applied
+}
+
+// Save writes the cache
+func (c *Cache) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode merchant cache: %w", err)
+	}
+	if err := os.WriteFile(c.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write merchant cache: %w", err)
+	}
+	return nil
+}
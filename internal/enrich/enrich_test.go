@@ -0,0 +1,110 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestClean(t *testing.T) {
+	var asked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request to %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		list := req.Messages[len(req.Messages)-1].Content
+		asked = append(asked, list)
+
+		var names []string
+		for _, line := range strings.Split(strings.TrimSpace(list), "\n") {
+			if strings.Contains(line, "BLUE BOTTLE") {
+				names = append(names, "Blue Bottle Coffee")
+			} else {
+				names = append(names, "")
+			}
+		}
+		answer, _ := json.Marshal(names)
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": "```json\n" + string(answer) + "\n```"}}},
+		})
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "merchants.json")
+	cache, err := LoadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleaner, err := New(Config{URL: server.URL + "/v1/", Model: "test", APIKey: "secret"}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := []*domain.Transaction{
+		{TxDesc: "SQ *BLUE BOTTLE #42", TxAmount: 5.25},
+		{TxDesc: "SQ *BLUE BOTTLE #42", TxAmount: 6.10},
+		{TxDesc: "MONTHLY FEE"},
+		{TxDesc: "AMZN MKTP", Merchant: "Amazon"},
+	}
+	cleaned, err := cleaner.Clean(context.Background(), transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleaned != 2 {
+		t.Errorf("Clean() = %d, want 2", cleaned)
+	}
+	want := []string{"Blue Bottle Coffee", "Blue Bottle Coffee", "", "Amazon"}
+	for i, tx := range transactions {
+		if tx.Merchant != want[i] {
+			t.Errorf("transaction %d merchant = %q, want %q", i, tx.Merchant, want[i])
+		}
+	}
+
+	if len(asked) != 1 {
+		t.Fatalf("asked %d times, want 1", len(asked))
+	}
+	if strings.Contains(asked[0], "AMZN") || strings.Contains(asked[0], "5.25") {
+		t.Errorf("sent more than unknown descriptions: %q", asked[0])
+	}
+
+	// A second run answers from the saved cache, fees included
+	cache, err = LoadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleaner, _ = New(Config{URL: server.URL + "/v1", Model: "test", APIKey: "secret"}, cache)
+	again := []*domain.Transaction{{TxDesc: "SQ *BLUE BOTTLE #42"}, {TxDesc: "MONTHLY FEE"}}
+	if cleaned, err := cleaner.Clean(context.Background(), again); err != nil || cleaned != 1 {
+		t.Errorf("Clean() from cache = %d, %v, want 1", cleaned, err)
+	}
+	if len(asked) != 1 {
+		t.Errorf("asked again for cached descriptions")
+	}
+}
+
+func TestParseNames(t *testing.T) {
+	if _, err := parseNames(`["Amazon"]`, 2); err == nil {
+		t.Error("accepted too few names")
+	}
+	if _, err := parseNames("I can't help with that", 1); err == nil {
+		t.Error("accepted an answer without JSON")
+	}
+	names, err := parseNames(`Sure: [" Amazon ", ""]`, 2)
+	if err != nil || names[0] != "Amazon" || names[1] != "" {
+		t.Errorf("parseNames() = %q, %v", names, err)
+	}
+}
@@ -0,0 +1,169 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+)
+
+// header is the first row of every export; amounts are signed, negative for money out
+var header = []string{"date", "account", "account_type", "bank", "currency", "amount", "description", "merchant", "method", "category", "pending", "reference", "notes", "source_file"}
+
+// File writes an import to a CSV instead of ariand, for checking a run or feeding another tool.
+// Accounts exist only for the length of the run.
+type File struct {
+	mu       sync.Mutex
+	file     *os.File
+	writer   *csv.Writer
+	accounts []*pb.Account
+	written  int
+}
+
+// NewFile creates (or truncates) path and writes the header row
+func NewFile(path string) (*File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	writer := csv.NewWriter(f)
+	if err := writer.Write(header); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write export header: %w", err)
+	}
+	return &File{file: f, writer: writer}, nil
+}
+
+// GetUser accepts any user, there is nobody to look up
+func (f *File) GetUser(userID string) (*pb.User, error) {
+	return &pb.User{Id: userID}, nil
+}
+
+func (f *File) GetAccounts(string) ([]*pb.Account, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*pb.Account(nil), f.accounts...), nil
+}
+
+func (f *File) CreateAccount(userID, accountName, bank string, accountType pb.AccountType, mainCurrency string) (*pb.Account, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	account := &pb.Account{
+		Id:           int64(len(f.accounts) + 1),
+		OwnerId:      userID,
+		Name:         accountName,
+		Bank:         bank,
+		Type:         accountType,
+		MainCurrency: mainCurrency,
+	}
+	f.accounts = append(f.accounts, account)
+	return account, nil
+}
+
+// CreatesAccounts tells the import to make an account for every statement account without prompting
+func (f *File) CreatesAccounts() bool {
+	return true
+}
+
+func (f *File) CreateTransaction(userID string, tx *domain.Transaction) error {
+	_, errs := f.CreateTransactionsBulk(userID, []*domain.Transaction{tx})
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (f *File) CreateTransactionsBulk(_ string, transactions []*domain.Transaction) (int32, []error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, tx := range transactions {
+		if err := f.writer.Write(f.row(tx)); err != nil {
+			return int32(f.written), []error{fmt.Errorf("failed to write export: %w", err)}
+		}
+		f.written++
+	}
+	f.writer.Flush()
+	if err := f.writer.Error(); err != nil {
+		return 0, []error{fmt.Errorf("failed to write export: %w", err)}
+	}
+	return int32(len(transactions)), nil
+}
+
+func (f *File) row(tx *domain.Transaction) []string {
+	var account, accountType, bank string
+	for _, a := range f.accounts {
+		if a.Id == int64(tx.AccountID) {
+			account, accountType, bank = a.Name, convertAccountType(a.Type), a.Bank
+		}
+	}
+
+	amount := tx.TxAmount
+	if tx.TxDirection == domain.Out {
+		amount = -amount
+	}
+
+	return []string{
+		tx.TxDate.Format(time.DateOnly),
+		account,
+		accountType,
+		bank,
+		tx.TxCurrency,
+		strconv.FormatFloat(amount, 'f', 2, 64),
+		tx.TxDesc,
+		tx.Merchant,
+		string(tx.Method),
+		tx.Category,
+		strconv.FormatBool(tx.Pending),
+		tx.ReferenceCode,
+		tx.UserNotes,
+		tx.SourceFilePath,
+	}
+}
+
+// Written is how many transactions the file holds so far
+func (f *File) Written() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.written
+}
+
+// Close flushes the CSV and closes the file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.writer.Flush()
+	if err := f.writer.Error(); err != nil {
+		f.file.Close()
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return f.file.Close()
+}
+
+func convertAccountType(accountType pb.AccountType) string {
+	switch accountType {
+	case pb.AccountType_ACCOUNT_CHEQUING:
+		return "chequing"
+	case pb.AccountType_ACCOUNT_SAVINGS:
+		return "savings"
+	case pb.AccountType_ACCOUNT_CREDIT_CARD:
+		return "visa"
+	case pb.AccountType_ACCOUNT_INVESTMENT:
+		return "investment"
+	}
+	return "other"
+}
+
+var (
+	_ client.Uploader       = (*File)(nil)
+	_ client.AccountCreator = (*File)(nil)
+)
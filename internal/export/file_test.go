@@ -0,0 +1,65 @@
+package export
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+)
+
+func TestFileWritesUploads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backend client.Uploader = file
+
+	account, err := backend.CreateAccount("u1", "RBC Visa", "RBC", pb.AccountType_ACCOUNT_CREDIT_CARD, "CAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accounts, _ := backend.GetAccounts("u1"); len(accounts) != 1 {
+		t.Fatalf("GetAccounts = %d accounts, want 1", len(accounts))
+	}
+
+	date := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	created, errs := backend.CreateTransactionsBulk("u1", []*domain.Transaction{
+		{AccountID: int(account.Id), TxDate: date, TxAmount: 12.5, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "COFFEE", Method: domain.MethodCard, Category: "dining"},
+		{AccountID: int(account.Id), TxDate: date, TxAmount: 300, TxCurrency: "CAD", TxDirection: domain.In, TxDesc: "PAYMENT - THANK YOU", ReferenceCode: "123"},
+	})
+	if created != 2 || len(errs) > 0 {
+		t.Fatalf("CreateTransactionsBulk = %d, %v", created, errs)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want header and 2 transactions", len(rows))
+	}
+	want := []string{"2025-03-14", "RBC Visa", "visa", "RBC", "CAD", "-12.50", "COFFEE", "", "card", "dining", "false", "", "", ""}
+	for i, cell := range want {
+		if rows[1][i] != cell {
+			t.Errorf("%s = %q, want %q", rows[0][i], rows[1][i], cell)
+		}
+	}
+	if rows[2][5] != "300.00" || rows[2][11] != "123" {
+		t.Errorf("second row amount %q and reference %q, want 300.00 and 123", rows[2][5], rows[2][11])
+	}
+}
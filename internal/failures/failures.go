@@ -0,0 +1,335 @@
+package failures
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"arian-statement-parser/internal/domain"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultPath is where a failed import leaves its report, next to the other state files
+const DefaultPath = "errors.json"
+
+// Entry is one transaction that ariand refused, with enough detail to upload it again
+type Entry struct {
+	Fingerprint string    `json:"fingerprint"`
+	AccountID   int       `json:"account_id"`
+	Date        time.Time `json:"date"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	Direction   string    `json:"direction"`
+	Description string    `json:"description,omitempty"`
+	Merchant    string    `json:"merchant,omitempty"`
+	UserNotes   string    `json:"user_notes,omitempty"`
+	CategoryID  *int64    `json:"category_id,omitempty"`
+	Reference   string    `json:"reference_code,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	Pending     bool      `json:"pending,omitempty"` // so a retry uploads it as pending, or settles it
+	SourceFile  string    `json:"source_file,omitempty"`
+	Last4       string    `json:"account_last4,omitempty"` // statement account number, masked
+	Code        string    `json:"code"`
+	Message     string    `json:"message"`
+	// Page and Line are where the line is in its file, see domain.Transaction.Source
+	Page int `json:"source_page,omitempty"`
+	Line int `json:"source_line,omitempty"`
+	// The rest of the line, which goes into its kind and its notes in ariand, so a retry uploads
+	// the same thing the import would have
+	PostingDate      time.Time   `json:"posting_date,omitzero"`
+	Kind             domain.Kind `json:"kind,omitempty"`
+	Balance          *float64    `json:"balance,omitempty"`
+	OriginalAmount   float64     `json:"original_amount,omitempty"`
+	OriginalCurrency string      `json:"original_currency,omitempty"`
+	Principal        *float64    `json:"principal,omitempty"`
+	Interest         *float64    `json:"interest,omitempty"`
+	BankCategory     string      `json:"bank_category,omitempty"`
+	Provenance       []string    `json:"provenance,omitempty"`
+}
+
+// Report is the errors.json written when uploads fail
+type Report struct {
+	RunID     string    `json:"run_id,omitempty"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Entries   []Entry   `json:"entries"`
+}
+
+func NewReport(runID, userID string) *Report {
+	return &Report{RunID: runID, UserID: userID, CreatedAt: time.Now().UTC()}
+}
+
+// Fingerprint identifies a transaction by account, day, amount and description
+func Fingerprint(tx *domain.Transaction) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d|%s|%.2f|%s", tx.AccountID, tx.TxDate.Format(time.DateOnly), tx.TxAmount, tx.TxDesc))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Add records every transaction of a failed batch against the error ariand returned for it
+func (r *Report) Add(batch []*domain.Transaction, err error) {
+	st := statusOf(err)
+	for _, tx := range batch {
+		direction := "out"
+		if tx.TxDirection == domain.In {
+			direction = "in"
+		}
+		entry := Entry{
+			Fingerprint:  Fingerprint(tx),
+			AccountID:    tx.AccountID,
+			Date:         tx.TxDate,
+			Amount:       tx.TxAmount,
+			Currency:     tx.TxCurrency,
+			Direction:    direction,
+			Description:  tx.TxDesc,
+			Merchant:     tx.Merchant,
+			UserNotes:    tx.UserNotes,
+			CategoryID:   tx.CategoryID,
+			Reference:    tx.ReferenceCode,
+			Method:       string(tx.Method),
+			Pending:      tx.Pending,
+			SourceFile:   tx.SourceFilePath,
+			Last4:        last4(tx.StatementAccountNumber),
+			Code:         st.Code().String(),
+			Message:      st.Message(),
+			Page:         tx.SourcePage,
+			Line:         tx.SourceLine,
+			PostingDate:  tx.PostingDate,
+			Kind:         tx.Kind,
+			Balance:      tx.Balance,
+			BankCategory: tx.BankCategory,
+			Provenance:   tx.Provenance,
+		}
+		if tx.Original != nil {
+			entry.OriginalAmount = tx.Original.Amount
+			entry.OriginalCurrency = tx.Original.Currency
+		}
+		if tx.Loan != nil {
+			principal, interest := tx.Loan.Principal, tx.Loan.Interest
+			entry.Principal, entry.Interest = &principal, &interest
+		}
+		r.Entries = append(r.Entries, entry)
+	}
+}
+
+// statusOf finds the gRPC status under any wrapping, so the message is ariand's and not our prefix
+func statusOf(err error) *status.Status {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		return grpcErr.GRPCStatus()
+	}
+	return status.Convert(err)
+}
+
+// last4 keeps the last four digits of a statement account number
+func last4(number *string) string {
+	if number == nil {
+		return ""
+	}
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, *number)
+	return digits[max(len(digits)-4, 0):]
+}
+
+// Group is a set of failures sharing a status code, account and message
+type Group struct {
+	Code      string
+	AccountID int
+	Last4     string
+	Message   string
+	Count     int
+}
+
+// Breakdown groups entries so systematic problems stand out, largest group first
+func (r *Report) Breakdown() []Group {
+	index := make(map[Group]int)
+	var groups []Group
+	for _, e := range r.Entries {
+		key := Group{Code: e.Code, AccountID: e.AccountID, Last4: e.Last4, Message: e.Message}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, key)
+		}
+		groups[i].Count++
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	return groups
+}
+
+// Format renders a group like "12 InvalidArgument on account Visa ••1234: amount must be positive"
+func (g Group) Format(accountNames map[int]string) string {
+	account := accountNames[g.AccountID]
+	if account == "" {
+		account = fmt.Sprintf("#%d", g.AccountID)
+	}
+	if g.Last4 != "" {
+		account += " ••" + g.Last4
+	}
+	return fmt.Sprintf("%d %s on account %s: %s", g.Count, g.Code, account, g.Message)
+}
+
+// Transactions rebuilds the domain transactions for a retry
+func (r *Report) Transactions() []*domain.Transaction {
+	transactions := make([]*domain.Transaction, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		direction := domain.Out
+		if e.Direction == "in" {
+			direction = domain.In
+		}
+		var number *string
+		if e.Last4 != "" {
+			number = &e.Last4
+		}
+		tx := &domain.Transaction{
+			AccountID:              e.AccountID,
+			TxDate:                 e.Date,
+			TxAmount:               e.Amount,
+			TxCurrency:             e.Currency,
+			TxDirection:            direction,
+			TxDesc:                 e.Description,
+			Merchant:               e.Merchant,
+			UserNotes:              e.UserNotes,
+			CategoryID:             e.CategoryID,
+			ReferenceCode:          e.Reference,
+			Method:                 domain.Method(e.Method),
+			Pending:                e.Pending,
+			SourceFilePath:         e.SourceFile,
+			StatementAccountNumber: number,
+			SourcePage:             e.Page,
+			SourceLine:             e.Line,
+			PostingDate:            e.PostingDate,
+			Kind:                   e.Kind,
+			Balance:                e.Balance,
+			BankCategory:           e.BankCategory,
+			Provenance:             e.Provenance,
+		}
+		if e.OriginalAmount != 0 || e.OriginalCurrency != "" {
+			tx.Original = &domain.Money{Amount: e.OriginalAmount, Currency: e.OriginalCurrency}
+		}
+		if e.Principal != nil && e.Interest != nil {
+			tx.Loan = &domain.LoanSplit{Principal: *e.Principal, Interest: *e.Interest}
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions
+}
+
+// Load reads a report written by Save
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read error report: %w", err)
+	}
+
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("failed to parse error report: %w", err)
+	}
+	return report, nil
+}
+
+// Save adds the report's entries to the report at path, so the failures of an earlier run stay until
+// they are retried. A line that failed again replaces its old entry.
+func (r *Report) Save(path string) error {
+	merged := &Report{RunID: r.RunID, UserID: r.UserID, CreatedAt: r.CreatedAt}
+	existing, err := Load(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case existing.UserID != r.UserID && len(existing.Entries) > 0:
+		return fmt.Errorf("%s holds failures of user %s, retry or move it before saving those of %s", path, existing.UserID, r.UserID)
+	default:
+		merged.CreatedAt = existing.CreatedAt
+		merged.Entries = existing.Entries
+	}
+
+	index := make(map[string]int, len(merged.Entries))
+	for i, e := range merged.Entries {
+		index[e.key()] = i
+	}
+	for _, e := range r.Entries {
+		if i, ok := index[e.key()]; ok {
+			merged.Entries[i] = e
+			continue
+		}
+		index[e.key()] = len(merged.Entries)
+		merged.Entries = append(merged.Entries, e)
+	}
+	return merged.write(path)
+}
+
+// Settle takes the entries that went through out of the report at path, and removes the file once
+// nothing is left in it
+func Settle(path string, done []Entry) error {
+	report, err := Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	settled := make(map[string]bool, len(done))
+	for _, e := range done {
+		settled[e.key()] = true
+	}
+	report.Entries = slices.DeleteFunc(report.Entries, func(e Entry) bool { return settled[e.key()] })
+	if len(report.Entries) == 0 {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove error report: %w", err)
+		}
+		return nil
+	}
+	return report.write(path)
+}
+
+// Has reports whether the report holds an entry for the same statement line as e
+func (r *Report) Has(e Entry) bool {
+	return slices.ContainsFunc(r.Entries, func(other Entry) bool { return other.key() == e.key() })
+}
+
+// key identifies the statement line an entry came from, so two identical coffees stay apart
+func (e Entry) key() string {
+	return fmt.Sprintf("%s|%d|%d", e.Fingerprint, e.Page, e.Line)
+}
+
+// MaybeCreated reports whether ariand may have stored the entry's transaction before the call
+// failed, as when the connection dropped or the call timed out
+func (e Entry) MaybeCreated() bool {
+	switch e.Code {
+	case codes.Unavailable.String(), codes.DeadlineExceeded.String(), codes.Unknown.String(), codes.Canceled.String():
+		return true
+	}
+	return false
+}
+
+// write replaces the report at path
+func (r *Report) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode error report: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write error report: %w", err)
+	}
+	return nil
+}
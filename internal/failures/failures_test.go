@@ -0,0 +1,139 @@
+package failures
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryKeepsTheLine(t *testing.T) {
+	balance := 1250.40
+	payment := &domain.Transaction{
+		AccountID:      3,
+		TxDate:         time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		PostingDate:    time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC),
+		TxAmount:       640,
+		TxCurrency:     "CAD",
+		TxDirection:    domain.Out,
+		TxDesc:         "MORTGAGE PAYMENT",
+		ReferenceCode:  "884213",
+		Method:         domain.MethodPOS,
+		Kind:           domain.KindCardPayment,
+		Balance:        &balance,
+		Loan:           &domain.LoanSplit{Principal: 410, Interest: 230},
+		Original:       &domain.Money{Amount: 470.12, Currency: "USD"},
+		BankCategory:   "housing",
+		Provenance:     []string{"direction: out, by rule 3"},
+		SourceFilePath: "/statements/mortgage.pdf",
+		SourcePage:     2,
+		SourceLine:     14,
+	}
+
+	report := NewReport("run", "user")
+	report.Add([]*domain.Transaction{payment}, fmt.Errorf("failed to upload: %w", status.Error(codes.InvalidArgument, "bad amount")))
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	if err := report.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry := loaded.Entries[0]; entry.Code != "InvalidArgument" || entry.Message != "bad amount" {
+		t.Errorf("entry = %s: %s", entry.Code, entry.Message)
+	}
+	retry := loaded.Transactions()[0]
+	if retry.Notes() != payment.Notes() {
+		t.Errorf("notes after retry:\n%s\nwant:\n%s", retry.Notes(), payment.Notes())
+	}
+	if retry.Kind != payment.Kind || !retry.PostingDate.Equal(payment.PostingDate) || *retry.Balance != balance {
+		t.Errorf("retry lost the line: %+v", retry)
+	}
+}
+
+func TestSaveKeepsEarlierRuns(t *testing.T) {
+	line := func(desc string, page, line int) *domain.Transaction {
+		return &domain.Transaction{
+			AccountID:  3,
+			TxDate:     time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			TxAmount:   4.75,
+			TxDesc:     desc,
+			SourcePage: page,
+			SourceLine: line,
+		}
+	}
+	refused := status.Error(codes.InvalidArgument, "bad amount")
+	path := filepath.Join(t.TempDir(), DefaultPath)
+
+	first := NewReport("first", "user")
+	first.Add([]*domain.Transaction{line("COFFEE", 1, 4), line("COFFEE", 1, 5)}, refused)
+	if err := first.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	second := NewReport("second", "user")
+	second.Add([]*domain.Transaction{line("COFFEE", 1, 5), line("RENT", 2, 1)}, status.Error(codes.Unavailable, "down"))
+	if err := second.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(loaded.Entries))
+	}
+	if loaded.Entries[1].Code != "Unavailable" {
+		t.Errorf("the line that failed again kept code %s", loaded.Entries[1].Code)
+	}
+
+	stranger := NewReport("other", "someone")
+	stranger.Add([]*domain.Transaction{line("BOOKS", 3, 1)}, refused)
+	if err := stranger.Save(path); err == nil {
+		t.Error("saved another user's failures into the report")
+	}
+
+	if err := Settle(path, loaded.Entries[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Entries) != 1 || loaded.Entries[0].Line != 1 {
+		t.Errorf("after settling: %+v", loaded.Entries)
+	}
+	if err := Settle(path, loaded.Entries); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("report left behind once empty: %v", err)
+	}
+}
+
+func TestMaybeCreated(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want bool
+	}{
+		{codes.Unavailable, true},
+		{codes.DeadlineExceeded, true},
+		{codes.Unknown, true},
+		{codes.ResourceExhausted, false},
+		{codes.InvalidArgument, false},
+	}
+	for _, tt := range tests {
+		if got := (Entry{Code: tt.code.String()}).MaybeCreated(); got != tt.want {
+			t.Errorf("MaybeCreated(%s) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
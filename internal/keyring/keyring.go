@@ -0,0 +1,80 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Service is the keyring service name all secrets are stored under
+const Service = "arian-statement-parser"
+
+// ErrNotFound is returned when no secret exists for the given account
+var ErrNotFound = errors.New("secret not found in keyring")
+
+// Get reads a secret from the OS keyring (Secret Service on Linux, Keychain on macOS)
+func Get(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", Service, "account", account)
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", Service, "-a", account, "-w")
+	default:
+		return "", fmt.Errorf("keyring not supported on %s", runtime.GOOS)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("keyring lookup failed: %w", err)
+	}
+
+	secret := strings.TrimRight(stdout.String(), "\n")
+	if secret == "" {
+		return "", ErrNotFound
+	}
+
+	return secret, nil
+}
+
+// Set stores or replaces a secret in the OS keyring
+func Set(account, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		// secret-tool reads the secret from stdin so it never shows up in ps
+		cmd = exec.Command("secret-tool", "store", "--label", Service+" "+account, "service", Service, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	case "darwin":
+		// security -i reads the command from stdin, which keeps the secret out of ps as well
+		if strings.ContainsAny(secret, "\r\n") {
+			return errors.New("keyring secrets can't span lines")
+		}
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			quote(Service), quote(account), quote(secret)))
+	default:
+		return fmt.Errorf("keyring not supported on %s", runtime.GOOS)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keyring store failed: %w\nOutput: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// quote makes an argument one word for security -i, which splits its input like a shell
+func quote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
@@ -0,0 +1,40 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzLoad feeds arbitrary mapping files to the loader, which must never panic
+func FuzzLoad(f *testing.F) {
+	f.Add("# Account mappings: statement_account -> arian_account\n01234-5678901: Chequing\n4321: Visa\n")
+	f.Add("no separator here\n:\n  : empty key\n# comment: ignored\n")
+	f.Add("a:b:c\n\n\n")
+
+	f.Fuzz(func(t *testing.T, content string) {
+		path := filepath.Join(t.TempDir(), "account-mappings.txt")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		store := &Store{filePath: path, Mappings: make(map[string]string)}
+		if err := store.Load(); err != nil {
+			return
+		}
+
+		// Whatever loaded must survive a save/load round trip
+		if err := store.Save(); err != nil {
+			t.Fatal(err)
+		}
+		reloaded := &Store{filePath: path, Mappings: make(map[string]string)}
+		if err := reloaded.Load(); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range store.Mappings {
+			if reloaded.Mappings[k] != v {
+				t.Fatalf("mapping %q: got %q after round trip, want %q", k, reloaded.Mappings[k], v)
+			}
+		}
+	})
+}
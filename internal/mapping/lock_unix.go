@@ -0,0 +1,14 @@
+//go:build unix
+
+package mapping
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile holds an exclusive advisory lock on file until it is closed, waiting for other runs
+// that hold it
+func lockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package mapping
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile holds an exclusive lock on file until it is closed, waiting for other runs that hold it
+func lockFile(file *os.File) error {
+	return windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, new(windows.Overlapped))
+}
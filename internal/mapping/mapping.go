@@ -2,33 +2,54 @@ package mapping
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/statefile"
 )
 
 // Store manages account mappings
 type Store struct {
-	filePath string
-	Mappings map[string]string // statement account number -> arian account name
+	filePath     string
+	settingsPath string
+	usagePath    string
+	Mappings     map[string]string    // statement account number -> arian account name
+	Settings     map[string]Settings  // statement account number -> defaults for its transactions
+	Usage        map[string]time.Time // statement account number -> when an import last used its mapping
 }
 
 // NewStore creates a new mapping store
 func NewStore() (*Store, error) {
-	// Get current working directory
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	return NewStoreIn("")
+}
+
+// NewStoreIn creates a mapping store backed by files in dir, the working directory when empty
+func NewStoreIn(dir string) (*Store, error) {
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+		dir = cwd
 	}
 
-	filePath := filepath.Join(cwd, "account-mappings.txt")
+	filePath := filepath.Join(dir, "account-mappings.txt")
 
 	store := &Store{
-		filePath: filePath,
-		Mappings: make(map[string]string),
+		filePath:     filePath,
+		settingsPath: filepath.Join(dir, "account-settings.json"),
+		usagePath:    filepath.Join(dir, "account-mapping-usage.json"),
+		Mappings:     make(map[string]string),
+		Settings:     make(map[string]Settings),
+		Usage:        make(map[string]time.Time),
 	}
 
 	// Load existing mappings if file exists
@@ -38,18 +59,34 @@ func NewStore() (*Store, error) {
 		}
 	}
 
+	if err := store.loadSettings(); err != nil {
+		return nil, err
+	}
+	if err := store.loadUsage(); err != nil {
+		return nil, err
+	}
+
 	return store, nil
 }
 
+// mappingsSchema is the format of account-mappings.txt, whose version is a comment under the
+// header. Version 1 only added the version.
+var mappingsSchema = statefile.Schema{Name: "account-mappings.txt", Version: 1, Migrations: []statefile.Migration{nil}}
+
+// versionComment starts the line of the mappings file that holds its version
+const versionComment = "# schema_version:"
+
 // Load reads mappings from disk
 func (s *Store) Load() error {
-	file, err := os.Open(s.filePath)
+	data, err := os.ReadFile(s.filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open mappings file: %w", err)
 	}
-	defer file.Close()
+	if data, err = mappingsSchema.Upgrade(data, mappingsVersion(data)); err != nil {
+		return err
+	}
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
@@ -73,32 +110,70 @@ func (s *Store) Load() error {
 	return nil
 }
 
-// Save writes mappings to disk
+// mappingsVersion finds the version comment of a mappings file, 0 for a file without one
+func mappingsVersion(data []byte) int {
+	for line := range strings.Lines(string(data)) {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), versionComment); ok {
+			version, err := strconv.Atoi(strings.TrimSpace(rest))
+			if err != nil {
+				return -1
+			}
+			return version
+		}
+	}
+	return 0
+}
+
+// Save writes mappings to disk, through a temp file so a crash or a run reading them at the same
+// time never sees half a file, keeping the mappings it replaces as a backup
 func (s *Store) Save() error {
-	file, err := os.Create(s.filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create mappings file: %w", err)
+	var b strings.Builder
+	b.WriteString("# Account mappings: statement_account -> arian_account\n")
+	fmt.Fprintf(&b, "%s %d\n", versionComment, mappingsSchema.Version)
+
+	// Write mappings in sorted order for consistency
+	statementAccounts := make([]string, 0, len(s.Mappings))
+	for statementAccount := range s.Mappings {
+		statementAccounts = append(statementAccounts, statementAccount)
+	}
+	sort.Strings(statementAccounts)
+	for _, statementAccount := range statementAccounts {
+		fmt.Fprintf(&b, "%s: %s\n", statementAccount, s.Mappings[statementAccount])
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
+	if err := statefile.Write(s.filePath, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to write mappings: %w", err)
+	}
+	return nil
+}
 
-	// Write header comment
-	_, err = writer.WriteString("# Account mappings: statement_account -> arian_account\n")
+// update changes the mappings and their usage with change and saves them, holding a lock so two
+// runs at once, like a daemon and an import by hand, don't save over each other's changes. What
+// another run saved since this one loaded is read back first.
+func (s *Store) update(change func()) error {
+	lock, err := os.OpenFile(s.filePath+".lock", os.O_CREATE|os.O_RDWR, 0o600)
 	if err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
+		return fmt.Errorf("failed to open mappings lock: %w", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("failed to lock mappings: %w", err)
 	}
 
-	// Write mappings in sorted order for consistency
-	for statementAccount, arianAccount := range s.Mappings {
-		_, err = writer.WriteString(fmt.Sprintf("%s: %s\n", statementAccount, arianAccount))
-		if err != nil {
-			return fmt.Errorf("failed to write mapping: %w", err)
-		}
+	s.Mappings = make(map[string]string)
+	if err := s.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.Usage = make(map[string]time.Time)
+	if err := s.loadUsage(); err != nil {
+		return err
 	}
 
-	return nil
+	change()
+	if err := s.Save(); err != nil {
+		return err
+	}
+	return s.saveUsage()
 }
 
 // FindMapping looks up an existing mapping
@@ -108,8 +183,71 @@ func (s *Store) FindMapping(statementAccountNumber string) string {
 
 // AddMapping adds a new mapping
 func (s *Store) AddMapping(statementAccountNumber, arianAccountName string) error {
-	s.Mappings[statementAccountNumber] = arianAccountName
-	return s.Save()
+	return s.update(func() {
+		s.Mappings[statementAccountNumber] = arianAccountName
+	})
+}
+
+// RenameAccount points the mappings to the ariand account oldName at newName instead, after the
+// account was renamed in ariand, and returns how many it changed
+func (s *Store) RenameAccount(oldName, newName string) (int, error) {
+	renamed := 0
+	err := s.update(func() {
+		for statementAccount, arianAccount := range s.Mappings {
+			if strings.EqualFold(arianAccount, oldName) {
+				s.Mappings[statementAccount] = newName
+				renamed++
+			}
+		}
+	})
+	return renamed, err
+}
+
+// Problems lists what Load silently skips or overrides in the mappings file, and, when accounts is
+// not nil, mappings to accounts that don't exist
+func (s *Store) Problems(accounts []*pb.Account) ([]string, error) {
+	var problems []string
+
+	data, err := os.ReadFile(s.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open mappings file: %w", err)
+	}
+
+	firstLine := make(map[string]int)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			problems = append(problems, fmt.Sprintf("line %d is not \"statement account: arian account\": %q", i+1, line))
+			continue
+		}
+
+		statementAccount := strings.TrimSpace(parts[0])
+		if first, ok := firstLine[statementAccount]; ok {
+			problems = append(problems, fmt.Sprintf("%s is mapped on lines %d and %d, the last one wins", statementAccount, first, i+1))
+			continue
+		}
+		firstLine[statementAccount] = i + 1
+	}
+
+	if accounts != nil {
+		statementAccounts := make([]string, 0, len(s.Mappings))
+		for statementAccount := range s.Mappings {
+			statementAccounts = append(statementAccounts, statementAccount)
+		}
+		sort.Strings(statementAccounts)
+
+		for _, statementAccount := range statementAccounts {
+			if s.ResolveAccount(s.Mappings[statementAccount], accounts) == nil {
+				problems = append(problems, fmt.Sprintf("%s is mapped to %q, which is not an ariand account", statementAccount, s.Mappings[statementAccount]))
+			}
+		}
+	}
+	return problems, nil
 }
 
 // ResolveAccount finds an account by name from a list of accounts
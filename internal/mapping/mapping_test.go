@@ -0,0 +1,181 @@
+package mapping
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "arian-statement-parser/internal/gen/arian/v1"
+)
+
+func TestProblems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "account-mappings.txt")
+	content := "# Account mappings: statement_account -> arian_account\n" +
+		"1234: Chequing\n" +
+		"garbage\n" +
+		"5678: Old Visa\n" +
+		"1234: Savings\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &Store{filePath: path, Mappings: make(map[string]string)}
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := []*pb.Account{{Name: "chequing"}, {Name: "Savings"}}
+	problems, err := store.Problems(accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"line 3", "lines 2 and 5", "5678 is mapped to \"Old Visa\""}
+	if len(problems) != len(want) {
+		t.Fatalf("problems = %q", problems)
+	}
+	for i, w := range want {
+		if !strings.Contains(problems[i], w) {
+			t.Errorf("problem %d = %q, want it to mention %s", i, problems[i], w)
+		}
+	}
+
+	// Without accounts only the file itself is checked
+	if problems, _ := store.Problems(nil); len(problems) != 2 {
+		t.Fatalf("problems without accounts = %q", problems)
+	}
+}
+
+func TestRenameAccount(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "account-mappings.txt")
+	store := &Store{filePath: path, usagePath: filepath.Join(dir, "account-mapping-usage.json"), Mappings: map[string]string{"1234": "Chequing", "5678": "Visa", "9012": "chequing"}}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed, err := store.RenameAccount("CHEQUING", "Everyday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed != 2 || store.Mappings["1234"] != "Everyday" || store.Mappings["9012"] != "Everyday" || store.Mappings["5678"] != "Visa" {
+		t.Fatalf("renamed %d, mappings = %v", renamed, store.Mappings)
+	}
+
+	saved := &Store{filePath: path, Mappings: make(map[string]string)}
+	if err := saved.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Mappings["1234"] != "Everyday" {
+		t.Fatalf("saved mappings = %v", saved.Mappings)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	store := &Store{
+		filePath:  filepath.Join(dir, "account-mappings.txt"),
+		usagePath: filepath.Join(dir, "account-mapping-usage.json"),
+		Mappings:  map[string]string{"1234": "Chequing", "5678": "Old Visa", "9012": "Savings", "3456": "Closed"},
+		Usage:     make(map[string]time.Time),
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := store.MarkUsed([]string{"1234"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkUsed([]string{"9012"}, now.AddDate(-2, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 5678 points at a deleted account, 9012 went unused for two years, 3456 was never seen used
+	accounts := []*pb.Account{{Name: "Chequing"}, {Name: "Savings"}, {Name: "Closed"}}
+	stale := store.Prunable(accounts, now.AddDate(-1, 0, 0))
+	if len(stale) != 2 || stale[0].StatementAccount != "5678" || !stale[0].Missing || stale[1].StatementAccount != "9012" {
+		t.Fatalf("Prunable = %v", stale)
+	}
+
+	if err := store.Remove("5678", "9012"); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Store{filePath: store.filePath, usagePath: store.usagePath, Mappings: make(map[string]string), Usage: make(map[string]time.Time)}
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.loadUsage(); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Mappings) != 2 || len(loaded.Usage) != 1 || !loaded.Usage["1234"].Equal(now) {
+		t.Fatalf("after Remove, mappings = %v, usage = %v", loaded.Mappings, loaded.Usage)
+	}
+}
+
+func TestConcurrentStores(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *Store {
+		return &Store{
+			filePath:  filepath.Join(dir, "account-mappings.txt"),
+			usagePath: filepath.Join(dir, "account-mapping-usage.json"),
+			Mappings:  make(map[string]string),
+			Usage:     make(map[string]time.Time),
+		}
+	}
+
+	// Two runs that loaded the same mappings each add their own, and neither loses the other's
+	stores := []*Store{open(), open()}
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 20 {
+				if err := store.AddMapping(fmt.Sprintf("%d-%d", i, j), "Chequing"); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded := open()
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Mappings) != 40 {
+		t.Fatalf("saved %d mappings, want 40", len(loaded.Mappings))
+	}
+}
+
+func TestOldFormats(t *testing.T) {
+	dir := t.TempDir()
+	store := &Store{
+		filePath:  filepath.Join(dir, "account-mappings.txt"),
+		usagePath: filepath.Join(dir, "account-mapping-usage.json"),
+		Mappings:  make(map[string]string),
+		Usage:     make(map[string]time.Time),
+	}
+	// Files from before versioning: mappings without the version line, usage as a bare map
+	os.WriteFile(store.filePath, []byte("# Account mappings: statement_account -> arian_account\n1234:Chequing\n"), 0644)
+	os.WriteFile(store.usagePath, []byte(`{"1234": "2026-06-01T00:00:00Z"}`), 0644)
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.loadUsage(); err != nil {
+		t.Fatal(err)
+	}
+	if store.Mappings["1234"] != "Chequing" || store.Usage["1234"].IsZero() {
+		t.Fatalf("mappings = %v, usage = %v", store.Mappings, store.Usage)
+	}
+
+	// A file from a newer version is refused rather than misread
+	os.WriteFile(store.filePath, []byte("# schema_version: 99\n1234:Chequing\n"), 0644)
+	if err := store.Load(); err == nil || !strings.Contains(err.Error(), "update arian-statement-parser") {
+		t.Fatalf("Load of a newer file: %v", err)
+	}
+}
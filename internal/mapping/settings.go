@@ -0,0 +1,128 @@
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/notes"
+)
+
+// Settings are applied to every transaction from a mapped statement account
+type Settings struct {
+	Category    string `json:"category,omitempty"`    // ariand category slug for lines no rule categorized
+	Notes       string `json:"notes,omitempty"`       // template, e.g. "Imported from {{.SourceFile}}"
+	Description string `json:"description,omitempty"` // template replacing the statement description
+	Currency    string `json:"currency,omitempty"`    // replaces the currency read from the statement
+	// LoanPayments is "single" to keep a loan payment one transaction with its breakdown in the
+	// notes, the default, or "split" for a principal and an interest transaction per payment
+	LoanPayments string `json:"loan_payments,omitempty"`
+	// Sign is "as-is" for statements whose money out is negative, the default, or "inverted" for
+	// ones that print money out as positive
+	Sign string `json:"sign,omitempty"`
+	// Date is the date lines are uploaded with: "transaction" for the day the purchase was made, the
+	// default, "posted" for the day the bank posted it, or "weekday" for the transaction date with
+	// weekends moved to the Monday after
+	Date string `json:"date,omitempty"`
+
+	template *notes.Template
+}
+
+// loadSettings reads the settings file next to the mappings, if there is one
+func (s *Store) loadSettings() error {
+	data, err := os.ReadFile(s.settingsPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read account settings: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.Settings); err != nil {
+		return fmt.Errorf("failed to parse account settings: %w", err)
+	}
+
+	for account, settings := range s.Settings {
+		if settings.template, err = notes.New(account, settings.Notes, settings.Description); err != nil {
+			return fmt.Errorf("account settings: %w", err)
+		}
+		settings.Currency = strings.ToUpper(strings.TrimSpace(settings.Currency))
+		switch settings.LoanPayments {
+		case "", "single", "split":
+		default:
+			return fmt.Errorf("account settings: %s has loan_payments %q, expected single or split", account, settings.LoanPayments)
+		}
+		switch settings.Sign {
+		case "", "as-is", "inverted":
+		default:
+			return fmt.Errorf("account settings: %s has sign %q, expected as-is or inverted", account, settings.Sign)
+		}
+		switch settings.Date {
+		case "", "transaction", "posted", "weekday":
+		default:
+			return fmt.Errorf("account settings: %s has date %q, expected transaction, posted or weekday", account, settings.Date)
+		}
+		s.Settings[account] = settings
+	}
+
+	return nil
+}
+
+// Apply sets the default category and currency for a transaction from a statement account, then
+// formats its notes and description with the account's templates, or the global ones where it has none.
+// A category from rules or policies wins over the default.
+func (s *Store) Apply(statementAccount string, tx *domain.Transaction, global *notes.Template, now time.Time) error {
+	settings, ok := s.Settings[statementAccount]
+	if !ok {
+		return global.Apply(tx, statementAccount, now)
+	}
+
+	if settings.Currency != "" {
+		tx.TxCurrency = settings.Currency
+	}
+	if settings.Category != "" && tx.Category == "" && tx.CategoryID == nil {
+		tx.Category = settings.Category
+	}
+
+	return settings.template.Or(global).Apply(tx, statementAccount, now)
+}
+
+// AdjustDate moves a transaction from a statement account to the date its settings upload it with,
+// keeping the statement's date in its provenance, and reports whether the date changed. Lines whose
+// statement has no posting date keep their transaction date.
+func (s *Store) AdjustDate(statementAccount string, tx *domain.Transaction) bool {
+	date := tx.TxDate
+	switch s.Settings[statementAccount].Date {
+	case "posted":
+		if !tx.PostingDate.IsZero() {
+			date = tx.PostingDate
+		}
+	case "weekday":
+		switch date.Weekday() {
+		case time.Saturday:
+			date = date.AddDate(0, 0, 2)
+		case time.Sunday:
+			date = date.AddDate(0, 0, 1)
+		}
+	}
+	if date.Equal(tx.TxDate) {
+		return false
+	}
+	tx.Provenance = append(tx.Provenance, "date: "+s.Settings[statementAccount].Date+", the statement dates it "+tx.TxDate.Format("2006-01-02"))
+	tx.TxDate = date
+	return true
+}
+
+// InvertsSigns reports whether a statement account's amounts are read with the opposite sign
+func (s *Store) InvertsSigns(statementAccount string) bool {
+	return s.Settings[statementAccount].Sign == "inverted"
+}
+
+// SplitsLoanPayments reports whether a statement account's loan payments become a principal and an
+// interest transaction each
+func (s *Store) SplitsLoanPayments(statementAccount string) bool {
+	return s.Settings[statementAccount].LoanPayments == "split"
+}
@@ -0,0 +1,90 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestSettingsApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "account-settings.json")
+	content := `{
+  "4321": {"category": "travel", "notes": "Imported from {{.SourceFile}}", "currency": "usd"}
+}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &Store{settingsPath: path, Settings: make(map[string]Settings)}
+	if err := store.loadSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := &domain.Transaction{TxCurrency: "CAD", SourceFilePath: "/statements/visa-2024-03.pdf"}
+	ruled := &domain.Transaction{TxCurrency: "CAD", Category: "cash", UserNotes: "split with Sam", SourceFilePath: "visa.pdf"}
+	other := &domain.Transaction{TxCurrency: "CAD"}
+
+	now := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	for account, tx := range map[string]*domain.Transaction{"4321": plain, "4321 ": other} {
+		if err := store.Apply(account, tx, nil, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Apply("4321", ruled, nil, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if plain.Category != "travel" || plain.TxCurrency != "USD" || plain.UserNotes != "Imported from visa-2024-03.pdf" {
+		t.Errorf("plain: got category %q, currency %q, notes %q", plain.Category, plain.TxCurrency, plain.UserNotes)
+	}
+	if ruled.Category != "cash" || ruled.UserNotes != "split with Sam\nImported from visa.pdf" {
+		t.Errorf("ruled: got category %q, notes %q", ruled.Category, ruled.UserNotes)
+	}
+	if other.Category != "" || other.TxCurrency != "CAD" || other.UserNotes != "" {
+		t.Errorf("unmapped account was changed: %+v", other)
+	}
+}
+
+func TestSettingsRejectBadTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "account-settings.json")
+	if err := os.WriteFile(path, []byte(`{"4321": {"notes": "{{.SourceFile"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &Store{settingsPath: path, Settings: make(map[string]Settings)}
+	if err := store.loadSettings(); err == nil {
+		t.Fatal("expected an error for an unterminated template")
+	}
+}
+
+func TestAdjustDate(t *testing.T) {
+	store := &Store{Settings: map[string]Settings{"4321": {Date: "posted"}, "1234": {Date: "weekday"}}}
+	saturday := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	monday := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	card := &domain.Transaction{TxDate: saturday, PostingDate: monday}
+	if !store.AdjustDate("4321", card) || !card.TxDate.Equal(monday) {
+		t.Fatalf("posted: date %v", card.TxDate)
+	}
+	if len(card.Provenance) != 1 || card.Provenance[0] != "date: posted, the statement dates it 2024-03-02" {
+		t.Fatalf("provenance = %q", card.Provenance)
+	}
+
+	// Without a posting date the transaction date stays
+	unposted := &domain.Transaction{TxDate: saturday}
+	if store.AdjustDate("4321", unposted) || !unposted.TxDate.Equal(saturday) {
+		t.Fatalf("unposted: date %v", unposted.TxDate)
+	}
+
+	debit := &domain.Transaction{TxDate: saturday, PostingDate: saturday}
+	if !store.AdjustDate("1234", debit) || !debit.TxDate.Equal(monday) {
+		t.Fatalf("weekday: date %v", debit.TxDate)
+	}
+	other := &domain.Transaction{TxDate: saturday, PostingDate: monday}
+	if store.AdjustDate("5678", other) {
+		t.Fatalf("an account without a date setting moved to %v", other.TxDate)
+	}
+}
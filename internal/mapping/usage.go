@@ -0,0 +1,137 @@
+package mapping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/statefile"
+)
+
+// usageFile is account-mapping-usage.json, kept next to the mappings so the mappings file stays
+// one line per account
+type usageFile struct {
+	SchemaVersion int                  `json:"schema_version"`
+	Used          map[string]time.Time `json:"used"`
+}
+
+// usageSchema is the format of the usage file. Version 1 moved the times, which were the whole
+// file, under used next to the version.
+var usageSchema = statefile.Schema{Name: "account-mapping-usage.json", Version: 1, Migrations: []statefile.Migration{nestUsage}}
+
+func nestUsage(data []byte) ([]byte, error) {
+	var used map[string]time.Time
+	if err := json.Unmarshal(data, &used); err != nil {
+		return nil, err
+	}
+	return json.Marshal(usageFile{Used: used})
+}
+
+// loadUsage reads when each mapping was last used
+func (s *Store) loadUsage() error {
+	data, err := statefile.Read(s.usagePath, statefile.CheckJSON[map[string]any])
+	if os.IsNotExist(err) {
+		return nil
+	}
+	// Usage only tells which mappings are stale, and one with no recorded use never is, so it can
+	// start over. The damaged file becomes the backup of the next save.
+	if errors.Is(err, statefile.ErrDamaged) {
+		log.Printf("WARN: %v, starting mapping usage over", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read mapping usage: %w", err)
+	}
+	if data, err = usageSchema.UpgradeJSON(data); err != nil {
+		return err
+	}
+	var usage usageFile
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return fmt.Errorf("failed to parse mapping usage %s: %w", s.usagePath, err)
+	}
+	if usage.Used != nil {
+		s.Usage = usage.Used
+	}
+	return nil
+}
+
+// saveUsage writes when each mapping was last used
+func (s *Store) saveUsage() error {
+	data, err := json.MarshalIndent(usageFile{SchemaVersion: usageSchema.Version, Used: s.Usage}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode mapping usage: %w", err)
+	}
+	if err := statefile.Write(s.usagePath, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write mapping usage: %w", err)
+	}
+	return nil
+}
+
+// MarkUsed records that an import found the statement accounts' lines through their mappings at now
+func (s *Store) MarkUsed(statementAccounts []string, now time.Time) error {
+	if len(statementAccounts) == 0 {
+		return nil
+	}
+	return s.update(func() {
+		for _, statementAccount := range statementAccounts {
+			s.Usage[statementAccount] = now.UTC().Truncate(time.Second)
+		}
+	})
+}
+
+// Stale is a mapping Prunable found no use for
+type Stale struct {
+	StatementAccount string
+	ArianAccount     string
+	// LastUsed is when an import last used the mapping, zero when none has since usage was recorded
+	LastUsed time.Time
+	// Missing is set when the mapping points at an account ariand doesn't have
+	Missing bool
+}
+
+func (m Stale) String() string {
+	if m.Missing {
+		return fmt.Sprintf("%s -> %s, which is not an ariand account", m.StatementAccount, m.ArianAccount)
+	}
+	return fmt.Sprintf("%s -> %s, last used %s", m.StatementAccount, m.ArianAccount, m.LastUsed.Format("2006-01-02"))
+}
+
+// Prunable lists the mappings last used before before, and, when accounts is not nil, those
+// pointing at accounts that don't exist. Mappings with no recorded use, such as ones saved before
+// usage was recorded, are only listed for a missing account.
+func (s *Store) Prunable(accounts []*pb.Account, before time.Time) []Stale {
+	statementAccounts := make([]string, 0, len(s.Mappings))
+	for statementAccount := range s.Mappings {
+		statementAccounts = append(statementAccounts, statementAccount)
+	}
+	sort.Strings(statementAccounts)
+
+	var stale []Stale
+	for _, statementAccount := range statementAccounts {
+		mapping := Stale{
+			StatementAccount: statementAccount,
+			ArianAccount:     s.Mappings[statementAccount],
+			LastUsed:         s.Usage[statementAccount],
+			Missing:          accounts != nil && s.ResolveAccount(s.Mappings[statementAccount], accounts) == nil,
+		}
+		if mapping.Missing || (!mapping.LastUsed.IsZero() && mapping.LastUsed.Before(before)) {
+			stale = append(stale, mapping)
+		}
+	}
+	return stale
+}
+
+// Remove deletes the mappings of the statement accounts, and what was recorded about their use
+func (s *Store) Remove(statementAccounts ...string) error {
+	return s.update(func() {
+		for _, statementAccount := range statementAccounts {
+			delete(s.Mappings, statementAccount)
+			delete(s.Usage, statementAccount)
+		}
+	})
+}
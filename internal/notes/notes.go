@@ -0,0 +1,161 @@
+package notes
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Template formats the notes and description of transactions before upload
+type Template struct {
+	// Notes is added after whatever notes the parser found, e.g. "Imported from {{.SourceFile}}"
+	Notes string
+	// Description replaces the statement description, e.g. "{{.Description}} ({{.Method}})"
+	Description string
+
+	notes       *template.Template
+	description *template.Template
+}
+
+// Fields are what a template can refer to
+type Fields struct {
+	Date        string  // YYYY-MM-DD
+	Amount      float64 // negative for money out
+	Currency    string
+	Description string // as printed on the statement
+	Merchant    string // cleaned up name, empty unless MERCHANT_LLM_URL is set
+	Method      string
+	Category    string // slug picked by rules, policies or account defaults
+	Reference   string // cheque number or bank reference
+	Pending     bool
+	Account     string // statement account number, or the account name for CSV exports
+	AccountType string
+	AccountName string
+	Bank        string
+	SourceFile  string // base name of the statement file
+	Page        int    // page of the statement the line is on, 0 when unknown
+	Line        int    // the line's place on that page, or its row in a CSV, 0 when unknown
+	ImportedAt  string // YYYY-MM-DD
+}
+
+// New compiles a template; either part may be empty to leave that field as the parser read it
+func New(name, notes, description string) (*Template, error) {
+	t := &Template{Notes: notes, Description: description}
+
+	var err error
+	if notes != "" {
+		if t.notes, err = template.New(name).Option("missingkey=error").Parse(notes); err != nil {
+			return nil, fmt.Errorf("invalid notes template for %s: %w", name, err)
+		}
+	}
+	if description != "" {
+		if t.description, err = template.New(name).Option("missingkey=error").Parse(description); err != nil {
+			return nil, fmt.Errorf("invalid description template for %s: %w", name, err)
+		}
+	}
+
+	// Field names are only checked when a template runs, so try it once before any upload depends on it
+	if err := t.Apply(&domain.Transaction{}, name, time.Now()); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	return t, nil
+}
+
+// Or fills the parts this template leaves empty from fallback
+func (t *Template) Or(fallback *Template) *Template {
+	if t == nil {
+		return fallback
+	}
+	if fallback == nil {
+		return t
+	}
+
+	merged := *t
+	if merged.notes == nil {
+		merged.Notes, merged.notes = fallback.Notes, fallback.notes
+	}
+	if merged.description == nil {
+		merged.Description, merged.description = fallback.Description, fallback.description
+	}
+	return &merged
+}
+
+// Apply renders the template for a transaction from the given statement account
+func (t *Template) Apply(tx *domain.Transaction, account string, now time.Time) error {
+	if t == nil || (t.notes == nil && t.description == nil) {
+		return nil
+	}
+
+	fields := fieldsOf(tx, account, now)
+
+	if t.description != nil {
+		description, err := render(t.description, fields)
+		if err != nil {
+			return fmt.Errorf("failed to render description for %s: %w", account, err)
+		}
+		// An empty result would fail validation, the statement text is better than nothing
+		if description != "" {
+			tx.TxDesc = description
+		}
+	}
+
+	if t.notes != nil {
+		note, err := render(t.notes, fields)
+		if err != nil {
+			return fmt.Errorf("failed to render notes for %s: %w", account, err)
+		}
+		if note != "" {
+			if tx.UserNotes != "" {
+				tx.UserNotes += "\n"
+			}
+			tx.UserNotes += note
+		}
+	}
+
+	return nil
+}
+
+func fieldsOf(tx *domain.Transaction, account string, now time.Time) Fields {
+	amount := tx.TxAmount
+	if tx.TxDirection == domain.Out {
+		amount = -amount
+	}
+
+	var sourceFile string
+	if tx.SourceFilePath != "" {
+		sourceFile = filepath.Base(tx.SourceFilePath)
+	}
+
+	return Fields{
+		Date:        tx.TxDate.Format(time.DateOnly),
+		Amount:      amount,
+		Currency:    tx.TxCurrency,
+		Description: tx.TxDesc,
+		Merchant:    tx.Merchant,
+		Method:      string(tx.Method),
+		Category:    tx.Category,
+		Reference:   tx.ReferenceCode,
+		Pending:     tx.Pending,
+		Account:     account,
+		AccountType: tx.StatementAccountType,
+		AccountName: tx.StatementAccountName,
+		Bank:        tx.StatementBank,
+		SourceFile:  sourceFile,
+		Page:        tx.SourcePage,
+		Line:        tx.SourceLine,
+		ImportedAt:  now.Format(time.DateOnly),
+	}
+}
+
+func render(t *template.Template, fields Fields) (string, error) {
+	var out strings.Builder
+	if err := t.Execute(&out, fields); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
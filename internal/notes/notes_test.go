@@ -0,0 +1,60 @@
+package notes
+
+import (
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestApply(t *testing.T) {
+	global, err := New("global", "Imported {{.ImportedAt}} from {{.SourceFile}}", "{{.Description}} ({{.Method}})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := New("4321", "{{.Bank}} {{.Account}}: {{printf \"%.2f\" .Amount}} {{.Currency}}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTx := func() *domain.Transaction {
+		return &domain.Transaction{
+			TxDate:         time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			TxAmount:       12.5,
+			TxCurrency:     "CAD",
+			TxDirection:    domain.Out,
+			TxDesc:         "COFFEE",
+			Method:         domain.MethodPOS,
+			UserNotes:      "asset: 1 BTC",
+			StatementBank:  "RBC",
+			SourceFilePath: "/statements/chequing.pdf",
+		}
+	}
+	now := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	tx := newTx()
+	if err := global.Apply(tx, "01234-5678901", now); err != nil {
+		t.Fatal(err)
+	}
+	if tx.TxDesc != "COFFEE (pos)" || tx.UserNotes != "asset: 1 BTC\nImported 2024-04-01 from chequing.pdf" {
+		t.Errorf("global: got description %q, notes %q", tx.TxDesc, tx.UserNotes)
+	}
+
+	// The account template has its own notes but takes the description from the global one
+	tx = newTx()
+	if err := account.Or(global).Apply(tx, "4321", now); err != nil {
+		t.Fatal(err)
+	}
+	if tx.TxDesc != "COFFEE (pos)" || tx.UserNotes != "asset: 1 BTC\nRBC 4321: -12.50 CAD" {
+		t.Errorf("account: got description %q, notes %q", tx.TxDesc, tx.UserNotes)
+	}
+}
+
+func TestNewRejectsUnknownFields(t *testing.T) {
+	if _, err := New("global", "{{.Memo}}", ""); err == nil {
+		t.Fatal("expected an error for a field transactions don't have")
+	}
+	if _, err := New("global", "", "{{.Description"); err == nil {
+		t.Fatal("expected an error for an unterminated template")
+	}
+}
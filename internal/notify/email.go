@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailConfig holds SMTP settings for the email notifier
+type EmailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Email sends run summaries over SMTP
+type Email struct {
+	cfg EmailConfig
+}
+
+// NewEmail creates an email notifier, defaulting to the submission port
+func NewEmail(cfg EmailConfig) (*Email, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("smtp host is required")
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required")
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+
+	return &Email{cfg: cfg}, nil
+}
+
+// sendTimeout bounds a whole send, so a server that stops answering can't hold up the run
+const sendTimeout = time.Minute
+
+func (e *Email) Notify(ctx context.Context, summary *Summary) error {
+	msg := e.buildMessage(summary)
+	addr := net.JoinHostPort(e.cfg.Host, e.cfg.Port)
+
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)
+	}
+
+	// Port 465 speaks TLS from the first byte, everything else upgrades via STARTTLS
+	netDialer := &net.Dialer{Timeout: 15 * time.Second}
+	var conn net.Conn
+	var err error
+	if e.cfg.Port == "465" {
+		dialer := &tls.Dialer{NetDialer: netDialer, Config: &tls.Config{ServerName: e.cfg.Host}}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = netDialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+
+	// net/smtp has no context, so the connection carries the deadline and is closed on cancel
+	deadline := time.Now().Add(sendTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, e.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer client.Close()
+
+	if e.cfg.Port != "465" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: e.cfg.Host}); err != nil {
+				return fmt.Errorf("smtp STARTTLS failed: %w", err)
+			}
+		}
+	}
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+	if err := client.Mail(e.cfg.From); err != nil {
+		return fmt.Errorf("smtp MAIL failed: %w", err)
+	}
+	for _, rcpt := range e.cfg.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT %s failed: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write email body: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish email body: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// buildMessage renders an RFC 5322 plain-text message
+func (e *Email) buildMessage(summary *Summary) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", summary.Title())
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	body := summary.Text()
+	if !summary.StartedAt.IsZero() && !summary.FinishedAt.IsZero() {
+		body += fmt.Sprintf("\n\nrun %s took %s", summary.RunID, summary.FinishedAt.Sub(summary.StartedAt).Round(time.Second))
+	}
+
+	// An HTML report goes alongside the plain text, so mail clients without HTML still get the summary
+	if summary.Report != "" && summary.ReportFormat == "html" {
+		boundary := "arian-" + summary.RunID
+		fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+		fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n", boundary)
+		b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+		fmt.Fprintf(&b, "\r\n--%s\r\nContent-Type: text/html; charset=utf-8\r\n\r\n", boundary)
+		b.WriteString(strings.ReplaceAll(summary.Report, "\n", "\r\n"))
+		fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+		return b.Bytes()
+	}
+
+	if summary.Report != "" {
+		body += "\n\n" + summary.Report
+	}
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/pipeline"
+)
+
+// FileSummary holds the outcome for a single statement file
+type FileSummary struct {
+	File         string `json:"file"`
+	Format       string `json:"format,omitempty"` // pdf, csv, ofx or text
+	Transactions int    `json:"transactions"`
+	Processed    bool   `json:"processed"`
+	// SkippedLines is how many lines couldn't be read and were left out
+	SkippedLines int `json:"skipped_lines,omitempty"`
+	// SummaryLines is how many lines only restated a balance or total and were dropped
+	SummaryLines int `json:"summary_lines,omitempty"`
+	// Statement is what a card statement's summary says
+	Statement *domain.Statement `json:"statement,omitempty"`
+}
+
+// FormatSummary holds the outcome for the files of one format
+type FormatSummary struct {
+	Format         string `json:"format"`
+	TotalFiles     int    `json:"total_files"`
+	ProcessedFiles int    `json:"processed_files"`
+	Transactions   int    `json:"transactions"`
+}
+
+// Summary describes the outcome of an import run
+type Summary struct {
+	RunID          string        `json:"run_id"`
+	StartedAt      time.Time     `json:"started_at"`
+	FinishedAt     time.Time     `json:"finished_at"`
+	TotalFiles     int           `json:"total_files"`
+	ProcessedFiles int           `json:"processed_files"`
+	Transactions   int           `json:"transactions"`
+	Created        int           `json:"created"`
+	Failed         int           `json:"failed"`
+	CreatedIDs     []int64       `json:"created_ids,omitempty"` // when the backend reports them
+	Files          []FileSummary `json:"files"`
+	Duplicates     []string      `json:"duplicates,omitempty"`
+	// Excluded counts the lines each exclude in the rules file left out
+	Excluded       map[string]int `json:"excluded,omitempty"`
+	DuplicateFiles []string       `json:"duplicate_files,omitempty"` // copies of a file read once
+	Errors         []string       `json:"errors,omitempty"`
+	Warnings       []string       `json:"warnings,omitempty"`
+	// Formats count the files and transactions of each kind of file, for runs that mix them
+	Formats []FormatSummary `json:"formats,omitempty"`
+	// Stages say how many transactions went through each step of the import and how long it took
+	Stages []pipeline.Metrics `json:"stages,omitempty"`
+	// Report is the rendered import report when one should go out with the summary
+	Report       string `json:"report,omitempty"`
+	ReportFormat string `json:"report_format,omitempty"` // markdown or html
+}
+
+// Notifier delivers a run summary somewhere
+type Notifier interface {
+	Notify(ctx context.Context, summary *Summary) error
+}
+
+// NewRunID returns a short random identifier for an import run
+func NewRunID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Title returns a one-line headline for the summary
+func (s *Summary) Title() string {
+	if s.Failed > 0 {
+		return fmt.Sprintf("arian import %s: %d ok, %d failed", s.RunID, s.Created, s.Failed)
+	}
+	return fmt.Sprintf("arian import %s: %d transactions imported", s.RunID, s.Created)
+}
+
+// Text renders the summary as plain text suitable for chat messages
+func (s *Summary) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "files: %d/%d, transactions: %d\n", s.ProcessedFiles, s.TotalFiles, s.Transactions)
+	if len(s.Formats) > 1 {
+		for _, f := range s.Formats {
+			fmt.Fprintf(&b, "  %s: %d/%d files, %d transactions\n", f.Format, f.ProcessedFiles, f.TotalFiles, f.Transactions)
+		}
+	}
+	fmt.Fprintf(&b, "created: %d, failed: %d\n", s.Created, s.Failed)
+
+	for _, f := range s.Files {
+		if f.Processed {
+			fmt.Fprintf(&b, "  %s: %d", filepath.Base(f.File), f.Transactions)
+			if f.SkippedLines > 0 {
+				fmt.Fprintf(&b, ", %d lines skipped", f.SkippedLines)
+			}
+			if f.SummaryLines > 0 {
+				fmt.Fprintf(&b, ", %d balance lines dropped", f.SummaryLines)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(s.Duplicates) > 0 {
+		b.WriteString("dropped duplicates:\n")
+		for _, d := range s.Duplicates {
+			fmt.Fprintf(&b, "  %s\n", d)
+		}
+	}
+
+	if len(s.Excluded) > 0 {
+		b.WriteString("excluded:\n")
+		for _, name := range slices.Sorted(maps.Keys(s.Excluded)) {
+			fmt.Fprintf(&b, "  %d by %s\n", s.Excluded[name], name)
+		}
+	}
+
+	if len(s.DuplicateFiles) > 0 {
+		b.WriteString("skipped copies:\n")
+		for _, d := range s.DuplicateFiles {
+			fmt.Fprintf(&b, "  %s\n", d)
+		}
+	}
+
+	if len(s.Errors) > 0 {
+		b.WriteString("errors:\n")
+		for _, e := range s.Errors {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+
+	if len(s.Warnings) > 0 {
+		b.WriteString("warnings:\n")
+		for _, w := range s.Warnings {
+			fmt.Fprintf(&b, "  %s\n", w)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// NotifyAll sends the summary to every notifier, collecting failures
+func NotifyAll(ctx context.Context, notifiers []Notifier, summary *Summary) []error {
+	var errs []error
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, summary); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
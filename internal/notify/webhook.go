@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+	FormatNtfy  = "ntfy"
+)
+
+// Webhook posts run summaries to an HTTP endpoint
+type Webhook struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+// NewWebhook creates a webhook notifier; format is one of json, slack or ntfy
+func NewWebhook(url, format string) (*Webhook, error) {
+	if format == "" {
+		format = FormatJSON
+	}
+
+	switch format {
+	case FormatJSON, FormatSlack, FormatNtfy:
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+
+	return &Webhook{
+		url:    url,
+		format: format,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, summary *Summary) error {
+	req, err := w.buildRequest(ctx, summary)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
+
+// buildRequest shapes the payload for the configured target
+func (w *Webhook) buildRequest(ctx context.Context, summary *Summary) (*http.Request, error) {
+	var body []byte
+	contentType := "application/json"
+
+	// Chat messages can show a markdown report, not an HTML one; the JSON payload carries either
+	text := summary.Text()
+	if summary.Report != "" && summary.ReportFormat == "markdown" {
+		text += "\n\n" + summary.Report
+	}
+
+	switch w.format {
+	case FormatSlack:
+		payload := map[string]string{
+			"text": fmt.Sprintf("*%s*\n```\n%s\n```", summary.Title(), text),
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode slack payload: %w", err)
+		}
+		body = data
+	case FormatNtfy:
+		// ntfy takes the message as the raw body and metadata as headers
+		body = []byte(text)
+		contentType = "text/plain; charset=utf-8"
+	default:
+		data, err := json.Marshal(summary)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode summary: %w", err)
+		}
+		body = data
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	if w.format == FormatNtfy {
+		req.Header.Set("Title", summary.Title())
+		req.Header.Set("Tags", "receipt")
+		if summary.Failed > 0 {
+			req.Header.Set("Priority", "high")
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+)
+
+// backupExt is the extension of ariand's backup exports
+const backupExt = ".json"
+
+// BackupParser reads the transactions of ariand backups, the JSON an Arian export downloads, to
+// move them to another ariand or user
+type BackupParser struct{}
+
+func NewBackupParser() *BackupParser {
+	return &BackupParser{}
+}
+
+// ParseStatements parses every .json file under path; files that aren't backups, or hold no
+// transactions, are reported as not processed
+func (p *BackupParser) ParseStatements(path string, _ string) (*ParseResult, []*domain.Transaction, error) {
+	files, err := listFiles(path, backupExt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &ParseResult{}
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		rows, err := parseBackup(data, file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(file), err)
+		}
+
+		result.Transactions = append(result.Transactions, rows...)
+		result.FileResults = append(result.FileResults, FileResult{
+			File:             file,
+			TransactionCount: len(rows),
+			Processed:        len(rows) > 0,
+		})
+		result.Summary.TotalFiles++
+		if len(rows) > 0 {
+			result.Summary.ProcessedFiles++
+		}
+	}
+	result.Summary.TotalTransactions = len(result.Transactions)
+
+	transactions, err := toTransactions(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, transactions, nil
+}
+
+// backupAccountTypes maps the account types of a backup to the parser's
+var backupAccountTypes = map[string]string{
+	"chequing":    "chequing",
+	"checking":    "chequing",
+	"savings":     "savings",
+	"credit_card": "visa",
+	"credit":      "visa",
+	"investment":  "investment",
+}
+
+// backupAmount converts a backup's money to a number
+func backupAmount(m *money.Money) float64 {
+	return math.Round((float64(m.GetUnits())+float64(m.GetNanos())/1e9)*100) / 100
+}
+
+// parseBackup reads the transactions of a backup. A JSON file that isn't one has none.
+func parseBackup(data []byte, file string) ([]PythonTransaction, error) {
+	var backup pb.Backup
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, &backup); err != nil {
+		return nil, nil
+	}
+
+	accounts := make(map[string]*pb.AccountData, len(backup.GetAccounts()))
+	for _, account := range backup.GetAccounts() {
+		accounts[account.GetName()] = account
+	}
+
+	var transactions []PythonTransaction
+	for i, line := range backup.GetTransactions() {
+		if line.GetTxDate() == nil || line.GetTxAmount() == nil {
+			return nil, fmt.Errorf("transaction %d has no date or amount", i+1)
+		}
+
+		// Backups keep amounts unsigned, with the direction beside them
+		amount := backupAmount(line.GetTxAmount())
+		switch direction := strings.ToLower(line.GetTxDirection()); {
+		case strings.Contains(direction, "out") || strings.Contains(direction, "debit"):
+			amount = -math.Abs(amount)
+		case direction != "":
+			amount = math.Abs(amount)
+		}
+
+		account := accounts[line.GetAccountName()]
+		accountType := strings.TrimPrefix(strings.ToLower(account.GetAccountType()), "account_")
+		tx := PythonTransaction{
+			Date:         line.GetTxDate().AsTime().UTC().Truncate(24 * time.Hour).Format(csvDateLayout),
+			Amount:       amount,
+			Description:  line.GetTxDesc(),
+			AccountType:  cmp.Or(backupAccountTypes[accountType], "chequing"),
+			AccountName:  line.GetAccountName(),
+			SourceFile:   file,
+			Currency:     strings.ToUpper(cmp.Or(line.GetTxAmount().GetCurrencyCode(), account.GetMainCurrency())),
+			Notes:        line.GetUserNotes(),
+			Bank:         cmp.Or(account.GetBank(), "Arian"),
+			Merchant:     line.GetMerchant(),
+			CategorySlug: line.GetCategorySlug(),
+		}
+		if line.BalanceAfter != nil {
+			balance := backupAmount(line.GetBalanceAfter())
+			tx.Balance = &balance
+		}
+		if foreign := line.GetForeignAmount(); foreign != nil && foreign.GetCurrencyCode() != "" {
+			original := backupAmount(foreign)
+			tx.OriginalAmount, tx.OriginalCurrency = &original, foreign.GetCurrencyCode()
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
@@ -0,0 +1,366 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Cache stores the parser's JSON output per statement file so unchanged PDFs skip Python entirely
+type Cache struct {
+	dir string
+}
+
+// cacheEntry is the cached parse output for one file. The text of another bank's PDF is kept rather
+// than what the templates read from it, so changed templates apply without Python.
+type cacheEntry struct {
+	Transactions []PythonTransaction `json:"transactions"`
+	Statement    *domain.Statement   `json:"statement,omitempty"`
+	Institution  string              `json:"institution,omitempty"`
+	Text         string              `json:"text,omitempty"`
+}
+
+// DefaultCacheDir returns the per-user cache location for parse results
+func DefaultCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache dir: %w", err)
+	}
+	return filepath.Join(base, "arian-statement-parser", "parse"), nil
+}
+
+// NewCache creates a cache rooted at dir
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Key hashes the statement together with the parser's code, see PythonParser.codeHash, so an updated
+// parser reads every statement again, the parser config, since categories and excludes change the
+// output, and the institution it is read as, if one was given
+func (c *Cache) Key(pdfPath, configPath, institution, code string) (string, error) {
+	h := sha256.New()
+
+	if err := hashFile(h, pdfPath); err != nil {
+		return "", err
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(code))
+	if configPath != "" {
+		h.Write([]byte{0})
+		if err := hashFile(h, configPath); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if institution != "" {
+		h.Write([]byte{0})
+		h.Write([]byte(strings.ToLower(institution)))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get returns the cached transactions for key, if any, and the statement summary, institution and
+// text in a FileResult
+func (c *Cache) Get(key string) ([]PythonTransaction, FileResult, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, FileResult{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, FileResult{}, false // treat a corrupt entry as a miss
+	}
+
+	return entry.Transactions, FileResult{Statement: entry.Statement, Institution: entry.Institution, Text: entry.Text}, true
+}
+
+// Put stores the transactions parsed from one file, with the statement summary, institution and
+// text of its FileResult
+func (c *Cache) Put(key string, transactions []PythonTransaction, file FileResult) error {
+	data, err := json.Marshal(cacheEntry{Transactions: transactions, Statement: file.Statement, Institution: file.Institution, Text: file.Text})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a half-written entry
+	tmp := c.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return os.Rename(tmp, c.path(key))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// codeHash hashes the Python parser's code and locked dependencies, everything in its checkout that
+// decides what it reads from a PDF. It is worked out once per parser.
+func (p *PythonParser) codeHash() (string, error) {
+	if p.code != "" {
+		return p.code, nil
+	}
+
+	h := sha256.New()
+	err := filepath.WalkDir(p.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			// The virtualenv, git and bytecode are not the parser's code
+			if path != p.dir && (strings.HasPrefix(name, ".") || name == "__pycache__") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(name) != ".py" && name != "uv.lock" && name != "pyproject.toml" {
+			return nil
+		}
+		rel, err := filepath.Rel(p.dir, path)
+		if err != nil {
+			return err
+		}
+		h.Write([]byte(filepath.ToSlash(rel)))
+		h.Write([]byte{0})
+		return hashFile(h, path)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: no parser in %s", ErrParserUnavailable, p.dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to hash the parser in %s: %w", p.dir, err)
+	}
+	p.code = hex.EncodeToString(h.Sum(nil))
+	return p.code, nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// parseWithCache serves unchanged files from the cache and runs Python only on the rest. A statement
+// whose bytes changed since the version last uploaded is diffed against it, whether its new version
+// was parsed now or by an earlier run that never got to upload it. The statements read are only
+// remembered as uploaded once the caller says so, see Cache.Remember.
+func (p *PythonParser) parseWithCache(pdfPath, configPath string) (*ParseResult, error) {
+	files, err := statementFiles(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := p.codeHash()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ParseResult{seen: make(statementIndex)}
+	keys := make(map[string]string)
+	parsed := make(map[string][]PythonTransaction)
+	infos := make(map[string]FileResult)
+	var misses []string
+
+	for _, file := range files {
+		key, err := p.cache.Key(file, configPath, p.institution, code)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", file, err)
+		}
+		keys[file] = key
+
+		if cached, info, ok := p.cache.Get(key); ok {
+			for i := range cached {
+				cached[i].SourceFile = file // the same PDF may have moved since it was cached
+			}
+			parsed[file], infos[file] = cached, info
+			continue
+		}
+		misses = append(misses, file)
+	}
+
+	if len(misses) > 0 {
+		fresh, freshInfos, err := p.parseFiles(misses, configPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range misses {
+			if err := p.cache.Put(keys[file], fresh[file], freshInfos[file]); err != nil {
+				return nil, err
+			}
+			parsed[file], infos[file] = fresh[file], freshInfos[file]
+		}
+	}
+
+	index := p.cache.loadIndex()
+	for _, file := range files {
+		identity := statementIdentity(file, parsed[file], infos[file].Statement)
+		result.seen[identity] = keys[file]
+
+		// Same statement, different bytes: the bank regenerated it, only keep what changed
+		previousKey, seen := index[identity]
+		previous, _, ok := p.cache.Get(previousKey)
+		if !seen || previousKey == keys[file] || !ok {
+			result.add(file, parsed[file], infos[file])
+			continue
+		}
+
+		upload, diff := diffStatement(file, previous, parsed[file])
+		result.add(file, upload, infos[file])
+		result.Diffs = append(result.Diffs, diff)
+	}
+
+	// Python sorts by date across all files, keep that contract for mixed cached/fresh runs
+	sort.SliceStable(result.Transactions, func(i, j int) bool {
+		return result.Transactions[i].Date < result.Transactions[j].Date
+	})
+
+	return result, nil
+}
+
+// parseFiles runs Python once over a scratch dir holding copies of files, grouping output and
+// file results by original path
+func (p *PythonParser) parseFiles(files []string, configPath string) (map[string][]PythonTransaction, map[string]FileResult, error) {
+	tmpDir, err := os.MkdirTemp("", "arian-parse-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create scratch dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	original := make(map[string]string)
+	for i, file := range files {
+		scratch := filepath.Join(tmpDir, fmt.Sprintf("%03d-%s", i, filepath.Base(file)))
+		if err := copyFile(file, scratch); err != nil {
+			return nil, nil, err
+		}
+		original[filepath.Base(scratch)] = file
+	}
+
+	output, err := p.run(tmpDir, configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var parsed ParseResult
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse JSON output: %w", err)
+	}
+
+	byFile := make(map[string][]PythonTransaction, len(files))
+	for _, file := range files {
+		byFile[file] = []PythonTransaction{} // cache empty results too
+	}
+	for _, tx := range parsed.Transactions {
+		file, ok := original[filepath.Base(tx.SourceFile)]
+		if !ok {
+			return nil, nil, fmt.Errorf("parser returned unknown source file %s", tx.SourceFile)
+		}
+		tx.SourceFile = file
+		byFile[file] = append(byFile[file], tx)
+	}
+
+	infos := make(map[string]FileResult)
+	for _, fileResult := range parsed.FileResults {
+		if file, ok := original[filepath.Base(fileResult.File)]; ok {
+			infos[file] = fileResult
+		}
+	}
+
+	return byFile, infos, nil
+}
+
+// add appends one file's transactions and keeps the summary in sync, with the statement summary,
+// institution and text of info
+func (r *ParseResult) add(file string, transactions []PythonTransaction, info FileResult) {
+	r.Transactions = append(r.Transactions, transactions...)
+	r.FileResults = append(r.FileResults, FileResult{
+		File:             file,
+		TransactionCount: len(transactions),
+		Processed:        len(transactions) > 0,
+		Statement:        info.Statement,
+		Institution:      info.Institution,
+		Text:             info.Text,
+	})
+
+	r.Summary.TotalFiles++
+	r.Summary.TotalTransactions += len(transactions)
+	if len(transactions) > 0 {
+		r.Summary.ProcessedFiles++
+	}
+}
+
+// statementFiles mirrors the Python CLI: a single PDF or every PDF directly inside a directory
+func statementFiles(pdfPath string) ([]string, error) {
+	abs, err := filepath.Abs(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", pdfPath, err)
+	}
+
+	if !info.IsDir() {
+		if !strings.EqualFold(filepath.Ext(abs), ".pdf") {
+			return nil, fmt.Errorf("%s is not a PDF", pdfPath)
+		}
+		return []string{abs}, nil
+	}
+
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", pdfPath, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".pdf") {
+			files = append(files, filepath.Join(abs, entry.Name()))
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no PDF files found in %s", pdfPath)
+	}
+
+	return files, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
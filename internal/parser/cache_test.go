@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestStatementIdentity(t *testing.T) {
+	number := "4510 **** **** 1234"
+	lines := func(dates ...string) []PythonTransaction {
+		var txs []PythonTransaction
+		for _, date := range dates {
+			txs = append(txs, PythonTransaction{Date: date, AccountType: "visa", AccountNumber: &number})
+		}
+		return txs
+	}
+
+	march := statementIdentity("/in/statement.pdf", lines("2024-03-02", "2024-02-27"), nil)
+	if regenerated := statementIdentity("/other/statement.pdf", lines("2024-02-27", "2024-03-05"), nil); regenerated != march {
+		t.Errorf("regenerated statement = %q, want %q", regenerated, march)
+	}
+	if april := statementIdentity("/in/statement.pdf", lines("2024-03-28", "2024-04-20"), nil); april == march {
+		t.Errorf("next month's statement of the same name passed for the same: %q", april)
+	}
+
+	closesMarch := statementIdentity("/in/statement.pdf", lines("2024-03-02"), &domain.Statement{ClosingDate: "2024-03-26"})
+	closesApril := statementIdentity("/in/statement.pdf", lines("2024-03-02"), &domain.Statement{ClosingDate: "2024-04-26"})
+	if closesMarch == closesApril {
+		t.Errorf("statements closing on different days share %q", closesMarch)
+	}
+}
+
+func TestRemember(t *testing.T) {
+	cache, err := NewCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.loadIndex()) != 0 {
+		t.Fatal("new cache has an index")
+	}
+
+	// Reading statements changes nothing until they were uploaded
+	result := &ParseResult{}
+	Merge(result, &ParseResult{seen: statementIndex{"statement.pdf|visa": "a"}})
+	Merge(result, &ParseResult{seen: statementIndex{"other.pdf|visa": "b"}})
+	if len(cache.loadIndex()) != 0 {
+		t.Fatal("parsing wrote the index")
+	}
+
+	if err := cache.Remember(result); err != nil {
+		t.Fatal(err)
+	}
+	index := cache.loadIndex()
+	if len(index) != 2 || index["statement.pdf|visa"] != "a" || index["other.pdf|visa"] != "b" {
+		t.Errorf("index = %v", index)
+	}
+
+	// Releases before the index had a version wrote the statements as the whole file
+	if err := os.WriteFile(cache.indexPath(), []byte(`{"statement.pdf|visa": "a"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if index := cache.loadIndex(); len(index) != 1 || index["statement.pdf|visa"] != "a" {
+		t.Errorf("unversioned index = %v", index)
+	}
+}
+
+func TestCodeHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		code, err := NewPythonParser().WithDir(dir).codeHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	write("main.py", "print()")
+	write("rbc/parse.py", "x = 1")
+	before := hash()
+
+	// The virtualenv, bytecode and anything but code change nothing
+	write(".venv/lib/site.py", "y = 2")
+	write("rbc/__pycache__/parse.cpython-312.pyc", "bytes")
+	write("README.md", "notes")
+	if hash() != before {
+		t.Error("files that aren't the parser's code changed the hash")
+	}
+
+	write("rbc/parse.py", "x = 2")
+	if hash() == before {
+		t.Error("a changed parser kept its hash")
+	}
+
+	if _, err := NewPythonParser().WithDir(filepath.Join(dir, "missing")).codeHash(); !errors.Is(err, ErrParserUnavailable) {
+		t.Errorf("missing parser: %v", err)
+	}
+}
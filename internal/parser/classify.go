@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// cardPaymentPattern matches how RBC, in English and French, and US card issuers print a payment toward the card balance
+var cardPaymentPattern = regexp.MustCompile(`(?i)^(PAYMENT - THANK YOU|PAIEMENT - MERCI|PAYMENT RECEIVED|AUTOMATIC PAYMENT|PAYMENT THANK YOU|CAPITAL ONE (ONLINE|AUTOPAY|MOBILE) PYMT)\b`)
+
+// interestIncomePattern matches interest paid on savings, GICs and term deposits, in English and
+// French, including the yearly summary of interest paid for tax purposes
+var interestIncomePattern = regexp.MustCompile(`(?i)\binterest\b|\bint[ée]r[êe]ts?\b|\b(GIC|term deposit) INT\b|\bINT (PAID|GIC)\b`)
+
+// Classify works out the kind of a line again after its direction changed, see classify
+func Classify(tx *domain.Transaction) domain.Kind {
+	amount := tx.TxAmount
+	if tx.TxDirection == domain.Out {
+		amount = -amount
+	}
+	return classify(tx.StatementAccountType, amount, tx.TxDesc)
+}
+
+// classify tells card payments apart from refunds, both credits on a card statement, and spots
+// interest paid into other accounts. Loan statements list interest as part of each payment.
+func classify(accountType string, amount float64, description string) domain.Kind {
+	if accountType == "loan" {
+		return domain.KindUnknown
+	}
+	if accountType != "visa" {
+		if amount > 0 && interestIncomePattern.MatchString(description) {
+			return domain.KindInterest
+		}
+		return domain.KindUnknown
+	}
+
+	switch {
+	case amount < 0:
+		return domain.KindPurchase
+	case cardPaymentPattern.MatchString(description):
+		return domain.KindCardPayment
+	default:
+		return domain.KindRefund
+	}
+}
+
+// methodPatterns infer the method from RBC description prefixes, English or French, checked in order
+var methodPatterns = []struct {
+	pattern *regexp.Regexp
+	method  domain.Method
+}{
+	{regexp.MustCompile(`(?i)\b(ATM|ABM|GAB)\b|cash withdrawal|retrait au guichet`), domain.MethodATM},
+	{regexp.MustCompile(`(?i)e-?transfer|interac.*transfer|virement interac`), domain.MethodETransfer},
+	{regexp.MustCompile(`(?i)interac purchase|contactless interac|\bPOS\b|debit card purchase|achat interac|achat par carte de débit`), domain.MethodPOS},
+	{regexp.MustCompile(`(?i)\bcheque\b|\bchq\b|chèque`), domain.MethodCheque},
+	{regexp.MustCompile(`(?i)pre-?auth|\bPAD\b|\bMSP\b|insurance|autopay|préautorisé|preautorise|assurance`), domain.MethodPreAuth},
+	{regexp.MustCompile(`(?i)online banking|telephone banking|bill payment|online transfer|services bancaires en ligne|paiement de facture|virement en ligne`), domain.MethodOnline},
+	{regexp.MustCompile(`(?i)service charge|monthly fee|\bfee\b|interest|frais|intérêts?\b`), domain.MethodFee},
+	{regexp.MustCompile(`(?i)deposit|payroll|dépôt|depot|paie\b`), domain.MethodDeposit},
+}
+
+// parserMethods maps method values the parser emits that already mean something
+var parserMethods = map[string]domain.Method{
+	"atm":        domain.MethodATM,
+	"card":       domain.MethodCard,
+	"pos":        domain.MethodPOS,
+	"e-transfer": domain.MethodETransfer,
+	"pre-auth":   domain.MethodPreAuth,
+	"cheque":     domain.MethodCheque,
+	"online":     domain.MethodOnline,
+	"deposit":    domain.MethodDeposit,
+	"fee":        domain.MethodFee,
+	"trade":      domain.MethodTrade,
+	"dividend":   domain.MethodDividend,
+}
+
+// inferMethod uses the parser's method when it is specific. The RBC parser only reports the
+// account kind ("chequing", "visa"), so the description decides in that case.
+func inferMethod(parserMethod, accountType, description string) domain.Method {
+	if method, ok := parserMethods[strings.ToLower(parserMethod)]; ok {
+		return method
+	}
+
+	for _, p := range methodPatterns {
+		if p.pattern.MatchString(description) {
+			return p.method
+		}
+	}
+
+	if accountType == "visa" {
+		return domain.MethodCard
+	}
+	return domain.MethodUnknown
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fiatCurrencies are the cash sides of a trade on Canadian exchanges; everything else is an asset
+var fiatCurrencies = map[string]bool{
+	"CAD": true,
+	"USD": true,
+}
+
+// cryptoLeg is one side of an exchange row: what was credited or debited, and in which asset
+type cryptoLeg struct {
+	quantity float64
+	asset    string
+}
+
+func (l cryptoLeg) fiat() bool {
+	return fiatCurrencies[l.asset]
+}
+
+// cryptoRow is the fiat movement of an exchange row, ready to become a transaction.
+// Rows that never touch fiat, like sending bitcoin to a wallet, move no money and are skipped.
+func cryptoRow(date, kind string, credited, debited cryptoLeg, exchange, file string) (PythonTransaction, bool) {
+	tx := PythonTransaction{
+		Date:        date,
+		AccountType: "investment",
+		AccountName: exchange,
+		SourceFile:  file,
+		Bank:        exchange,
+	}
+
+	switch {
+	case debited.fiat() && credited.asset != "" && !credited.fiat():
+		tx.Amount = -debited.quantity
+		tx.Currency = debited.asset
+		tx.Description = "Buy " + credited.asset
+		tx.Notes = fmt.Sprintf("asset: %s %s @ %.2f %s", formatQuantity(credited.quantity), credited.asset, debited.quantity/credited.quantity, debited.asset)
+	case credited.fiat() && debited.asset != "" && !debited.fiat():
+		tx.Amount = credited.quantity
+		tx.Currency = credited.asset
+		tx.Description = "Sell " + debited.asset
+		tx.Notes = fmt.Sprintf("asset: -%s %s @ %.2f %s", formatQuantity(debited.quantity), debited.asset, credited.quantity/debited.quantity, credited.asset)
+	case credited.fiat():
+		tx.Amount = credited.quantity
+		tx.Currency = credited.asset
+		tx.Description = exchange + " deposit"
+		tx.Method = "deposit"
+	case debited.fiat():
+		tx.Amount = -debited.quantity
+		tx.Currency = debited.asset
+		tx.Description = exchange + " withdrawal"
+		tx.Method = "online"
+	default:
+		return PythonTransaction{}, false
+	}
+
+	tx.Category = kind
+	return tx, true
+}
+
+// formatQuantity prints asset quantities without float noise or trailing zeros
+func formatQuantity(quantity float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.8f", quantity), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// shakepayFormat reads Shakepay's transaction history export
+var shakepayFormat = csvFormat{
+	name: "Shakepay",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "amount debited", "asset debited", "amount credited", "asset credited")
+	},
+	parse: func(rows []csvRow, file string) ([]PythonTransaction, error) {
+		var transactions []PythonTransaction
+		for _, row := range rows {
+			date, err := parseCSVDate(row.get("date"))
+			if err != nil {
+				return nil, row.fail(err)
+			}
+
+			credited, err := readLeg(row, "amount credited", "asset credited")
+			if err != nil {
+				return nil, row.fail(err)
+			}
+			debited, err := readLeg(row, "amount debited", "asset debited")
+			if err != nil {
+				return nil, row.fail(err)
+			}
+
+			kind := row.get("transaction type")
+			if kind == "" {
+				kind = row.get("type")
+			}
+
+			if tx, ok := cryptoRow(date, kind, credited, debited, "Shakepay", file); ok {
+				tx.Line = row.line
+				transactions = append(transactions, tx)
+			}
+		}
+		return transactions, nil
+	},
+}
+
+// newtonFormat reads Newton's transaction history export
+var newtonFormat = csvFormat{
+	name: "Newton",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "type", "received quantity", "received currency", "sent quantity", "sent currency")
+	},
+	parse: func(rows []csvRow, file string) ([]PythonTransaction, error) {
+		var transactions []PythonTransaction
+		for _, row := range rows {
+			date, err := parseCSVDate(row.get("date"))
+			if err != nil {
+				return nil, row.fail(err)
+			}
+
+			credited, err := readLeg(row, "received quantity", "received currency")
+			if err != nil {
+				return nil, row.fail(err)
+			}
+			debited, err := readLeg(row, "sent quantity", "sent currency")
+			if err != nil {
+				return nil, row.fail(err)
+			}
+
+			if tx, ok := cryptoRow(date, row.get("type"), credited, debited, "Newton", file); ok {
+				tx.Line = row.line
+				transactions = append(transactions, tx)
+			}
+		}
+		return transactions, nil
+	},
+}
+
+// readLeg reads a quantity and asset column pair, quantities are unsigned in both exports
+func readLeg(row csvRow, quantityColumn, assetColumn string) (cryptoLeg, error) {
+	quantity, err := row.amount(quantityColumn)
+	if err != nil {
+		return cryptoLeg{}, err
+	}
+	if quantity < 0 {
+		quantity = -quantity
+	}
+
+	asset := strings.ToUpper(row.get(assetColumn))
+	if quantity == 0 {
+		asset = ""
+	}
+	return cryptoLeg{quantity: quantity, asset: asset}, nil
+}
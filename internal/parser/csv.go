@@ -0,0 +1,413 @@
+package parser
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// csvDateLayout is the date format CSV formats emit, matching the Python parser's output
+const csvDateLayout = "2006-01-02T15:04:05"
+
+// csvFormat turns one kind of exported CSV into parser rows
+type csvFormat struct {
+	name string
+	// detect reports whether a header row belongs to this format
+	detect func(header []string) bool
+	// parse converts the data rows, addressed through the header column index
+	parse func(rows []csvRow, file string) ([]PythonTransaction, error)
+}
+
+// csvFormats are tried in order against each file's header
+var csvFormats = []csvFormat{
+	wealthsimpleFormat,
+	shakepayFormat,
+	newtonFormat,
+	paypalFormat,
+	stripeFormat,
+	chaseFormat,
+	bofaFormat,
+	capitalOneFormat,
+	monzoFormat,
+	starlingFormat,
+	revolutFormat,
+	wiseFormat,
+	arianExportFormat,
+	mintFormat,
+	monarchFormat,
+	splitwiseFormat,
+}
+
+// maxPreamble is how many rows above the header an export may print, like Bank of America's summary
+const maxPreamble = 10
+
+// csvRow looks up cells by header name, case-insensitively
+type csvRow struct {
+	line    int
+	columns map[string]int
+	cells   []string
+	numbers string // number format of the export, see parseAmount
+	member  string // your column in exports with one per person, see Config.SplitwiseName
+}
+
+// get returns the trimmed cell under column, or "" when the export doesn't have it
+func (r csvRow) get(column string) string {
+	i, ok := r.columns[strings.ToLower(column)]
+	if !ok || i >= len(r.cells) {
+		return ""
+	}
+	return strings.TrimSpace(r.cells[i])
+}
+
+// first returns the first non-empty cell among columns, so exports with renamed columns share one parser
+func (r csvRow) first(columns ...string) string {
+	for _, column := range columns {
+		if value := r.get(column); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// amount reads the first non-empty cell among columns as a number in the export's format
+func (r csvRow) amount(columns ...string) (float64, error) {
+	return parseAmount(r.first(columns...), r.numbers)
+}
+
+// fail reports that the row couldn't be read, which skips it unless parsing is strict
+func (r csvRow) fail(err error) error {
+	return &lineError{line: r.line, err: err}
+}
+
+// lineError is a line of a file the parsers couldn't read
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (e *lineError) Unwrap() error {
+	return e.err
+}
+
+// CSVParser reads activity exports from banks and exchanges that don't issue parseable PDFs
+type CSVParser struct {
+	// numberFormats maps an export (by institution, e.g. "PayPal") to its number format
+	numberFormats map[string]string
+	// signs maps an export to its sign convention, SignInverted to flip every amount
+	signs map[string]string
+	// splitwiseName is your column in Splitwise exports
+	splitwiseName string
+	// strict fails a file on the first row it can't read instead of skipping the row
+	strict bool
+}
+
+func NewCSVParser() *CSVParser {
+	return &CSVParser{}
+}
+
+// ParseStatements parses every .csv file under path; files in an unknown format are reported as not processed.
+// The parser config at configPath, if any, sets the number format per export.
+func (p *CSVParser) ParseStatements(path string, configPath string) (*ParseResult, []*domain.Transaction, error) {
+	files, err := listFiles(path, ".csv")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if configPath != "" {
+		config, err := LoadConfig(configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.numberFormats = config.NumberFormats
+		p.signs = config.Signs
+		p.splitwiseName = config.SplitwiseName
+	}
+
+	result := &ParseResult{}
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+
+		rows, skipped, err := p.parseFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result.Transactions = append(result.Transactions, rows...)
+		result.FileResults = append(result.FileResults, FileResult{
+			File:             file,
+			TransactionCount: len(rows),
+			Processed:        len(rows) > 0,
+			SkippedLines:     skipped,
+		})
+		result.Summary.TotalFiles++
+		if len(rows) > 0 {
+			result.Summary.ProcessedFiles++
+		}
+	}
+	result.Summary.TotalTransactions = len(result.Transactions)
+
+	transactions, err := toTransactions(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, transactions, nil
+}
+
+// parseFile detects the format from the header and parses the rest of the file. Unless the parser
+// is strict, rows the format can't read are left out and returned as skipped.
+func (p *CSVParser) parseFile(file string) ([]PythonTransaction, []SkippedLine, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+
+	reader := csv.NewReader(strings.NewReader(decodeText(data)))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	// Rows before the first one a format recognizes as its header are a summary, not data
+	var format *csvFormat
+	var columns map[string]int
+	line := 1
+	for ; format == nil && line <= maxPreamble+1; line++ {
+		header, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		columns = make(map[string]int, len(header))
+		for i, name := range header {
+			// Excel likes to prefix UTF-8 exports with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+			header[i] = strings.ToLower(strings.TrimSpace(name))
+			columns[header[i]] = i
+		}
+
+		for i := range csvFormats {
+			if csvFormats[i].detect(header) {
+				format = &csvFormats[i]
+				break
+			}
+		}
+	}
+	if format == nil {
+		return nil, nil, nil
+	}
+
+	var numbers string
+	for institution, numberFormat := range p.numberFormats {
+		if strings.EqualFold(institution, format.name) {
+			numbers = numberFormat
+		}
+	}
+
+	var rows []csvRow
+	for ; ; line++ {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s line %d: %w", file, line, err)
+		}
+		if len(cells) == 1 && strings.TrimSpace(cells[0]) == "" {
+			continue
+		}
+		rows = append(rows, csvRow{line: line, columns: columns, cells: cells, numbers: numbers, member: strings.ToLower(p.splitwiseName)})
+	}
+	if numbers == NumberFormatAuto {
+		if numbers, err = detectNumberFormat(csvAmounts(rows)); err != nil {
+			return nil, nil, fmt.Errorf("failed to read the amounts of %s as %s export: %w, set its number format in the parser config", filepath.Base(file), format.name, err)
+		}
+		for i := range rows {
+			rows[i].numbers = numbers
+		}
+	}
+
+	// A format stops at the first row it can't read, so each bad row is taken out and the rest
+	// parsed again
+	var skipped []SkippedLine
+	for {
+		transactions, err := format.parse(rows, file)
+		if err == nil {
+			if p.inverted(format.name) {
+				invertAmounts(transactions)
+			}
+			return transactions, skipped, nil
+		}
+		var bad *lineError
+		if !p.strict && errors.As(err, &bad) {
+			before := len(rows)
+			rows = slices.DeleteFunc(rows, func(row csvRow) bool { return row.line == bad.line })
+			if len(rows) < before {
+				skipped = append(skipped, SkippedLine{Line: bad.line, Reason: bad.err.Error()})
+				continue
+			}
+		}
+		return nil, nil, fmt.Errorf("failed to parse %s as %s export: %w", filepath.Base(file), format.name, err)
+	}
+}
+
+// amountShape matches cells that could be amounts, signed or in parentheses, with or without a
+// currency symbol
+var amountShape = regexp.MustCompile(`^\(?[-+]?[$€£]?[-+]?\d[\d.,' \x{a0}\x{202f}]*[$€£]?\)?$`)
+
+// dottedDate matches dates like 05.03.2024, which look like amounts with points
+var dottedDate = regexp.MustCompile(`^\d{1,4}\.\d{1,2}\.\d{2,4}$`)
+
+// csvAmounts returns the cells of rows that look like amounts, leaving out date columns, for
+// detectNumberFormat
+func csvAmounts(rows []csvRow) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	dates := make(map[int]bool)
+	for name, i := range rows[0].columns {
+		dates[i] = strings.Contains(name, "date") || strings.Contains(name, "time")
+	}
+
+	var amounts []string
+	for _, row := range rows {
+		for i, cell := range row.cells {
+			cell = strings.TrimSpace(cell)
+			if !dates[i] && amountShape.MatchString(cell) && !dottedDate.MatchString(cell) {
+				amounts = append(amounts, cell)
+			}
+		}
+	}
+	return amounts
+}
+
+// inverted reports whether the parser config says the export signs its amounts the other way round
+func (p *CSVParser) inverted(name string) bool {
+	for institution, sign := range p.signs {
+		if strings.EqualFold(institution, name) {
+			return sign == SignInverted
+		}
+	}
+	return false
+}
+
+// invertAmounts flips the sign of every amount and balance, for an export whose money out is
+// positive
+func invertAmounts(transactions []PythonTransaction) {
+	for i := range transactions {
+		transactions[i].Amount = -transactions[i].Amount
+		if balance := transactions[i].Balance; balance != nil {
+			inverted := -*balance
+			transactions[i].Balance = &inverted
+		}
+	}
+}
+
+// hasColumns reports whether header contains every column, in any order
+func hasColumns(header []string, columns ...string) bool {
+	present := make(map[string]bool, len(header))
+	for _, name := range header {
+		present[name] = true
+	}
+	for _, column := range columns {
+		if !present[column] {
+			return false
+		}
+	}
+	return true
+}
+
+// doubt lowers a row's confidence, keeping the lowest score and every reason
+func doubt(pt *PythonTransaction, confidence float64, reason string) {
+	if pt.Confidence == nil || confidence < *pt.Confidence {
+		pt.Confidence = &confidence
+	}
+	pt.ConfidenceReasons = append(pt.ConfidenceReasons, reason)
+}
+
+// csvDateLayouts are the date formats seen across exports, tried in order
+var csvDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05-07",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+	"1/2/2006",
+	"01/02/06",
+	"1/2/06",
+	"Jan 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+}
+
+// dayFirstLayouts are the dates of UK and European exports, which put the day before the month
+var dayFirstLayouts = []string{
+	"02/01/2006 15:04:05",
+	"02/01/2006",
+	"2/1/2006",
+	"02/01/06",
+	"02-01-2006 15:04:05",
+	"02-01-2006",
+	"2006-01-02",
+}
+
+// parseCSVDate reads a date in any known layout and formats it the way toTransactions expects.
+// Timestamps keep their calendar day as exported; statements are about the day, not the instant.
+func parseCSVDate(raw string) (string, error) {
+	return parseCSVDateIn(raw, csvDateLayouts)
+}
+
+// parseCSVDateIn is parseCSVDate for exports whose dates only come in layouts
+func parseCSVDateIn(raw string, layouts []string) (string, error) {
+	value := englishMonths(strings.TrimSpace(raw))
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Format(csvDateLayout), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", raw)
+}
+
+// listFiles returns path itself when it is a file with the extension, or the matching files directly inside it
+func listFiles(path, ext string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		if strings.EqualFold(filepath.Ext(path), ext) {
+			return []string{path}, nil
+		}
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ext) {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/statefile"
+)
+
+// FileDiff describes how a regenerated statement differs from the previously parsed version
+type FileDiff struct {
+	File      string
+	Unchanged int                 // lines already seen in the previous version, not uploaded again
+	Added     int                 // lines that are new or changed
+	Removed   []PythonTransaction // lines from the previous version missing in the new one
+}
+
+// statementIndex remembers which cache entry was last seen for each statement
+type statementIndex map[string]string // statement identity -> cache key
+
+// indexFile is index.json in the cache directory
+type indexFile struct {
+	SchemaVersion int            `json:"schema_version"`
+	Statements    statementIndex `json:"statements"`
+}
+
+// indexSchema is the format of the statement index. Version 1 moved the statements, which were the
+// whole file, under statements next to the version.
+var indexSchema = statefile.Schema{Name: "the statement index", Version: 1, Migrations: []statefile.Migration{nestIndex}}
+
+func nestIndex(data []byte) ([]byte, error) {
+	var statements statementIndex
+	if err := json.Unmarshal(data, &statements); err != nil {
+		return nil, err
+	}
+	return json.Marshal(indexFile{Statements: statements})
+}
+
+func (c *Cache) indexPath() string {
+	return filepath.Join(c.dir, "index.json")
+}
+
+// loadIndex reads which cache entry was last seen for each statement. The index only saves
+// uploading a regenerated statement again, so one that can't be read starts over and is rebuilt as
+// files are parsed again.
+func (c *Cache) loadIndex() statementIndex {
+	data, err := statefile.Read(c.indexPath(), statefile.CheckJSON[map[string]any])
+	if os.IsNotExist(err) {
+		return make(statementIndex)
+	}
+	if err == nil {
+		data, err = indexSchema.UpgradeJSON(data)
+	}
+	var index indexFile
+	if err == nil {
+		err = json.Unmarshal(data, &index)
+	}
+	if err != nil {
+		log.Printf("WARN: failed to read the statement index, starting it over: %v", err)
+		return make(statementIndex)
+	}
+	if index.Statements == nil {
+		return make(statementIndex)
+	}
+	return index.Statements
+}
+
+func (c *Cache) saveIndex(index statementIndex) error {
+	data, err := json.MarshalIndent(indexFile{SchemaVersion: indexSchema.Version, Statements: index}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode statement index: %w", err)
+	}
+	if err := statefile.Write(c.indexPath(), data); err != nil {
+		return fmt.Errorf("failed to write statement index: %w", err)
+	}
+	return nil
+}
+
+// Remember records the statements of result as uploaded, so their next version with other bytes is
+// diffed against these. Call it only once the upload succeeded: a statement that never made it to
+// ariand has nothing to diff against.
+func (c *Cache) Remember(result *ParseResult) error {
+	if len(result.seen) == 0 {
+		return nil
+	}
+	index := c.loadIndex()
+	maps.Copy(index, result.seen)
+	return c.saveIndex(index)
+}
+
+// statementIdentity names a statement independently of its bytes: file name, account and period.
+// Banks give every download the same name, so without the period March's statement would pass for a
+// regenerated February. The period is the closing date when the statement has one, else the months
+// of its first and last lines.
+func statementIdentity(file string, transactions []PythonTransaction, statement *domain.Statement) string {
+	identity := filepath.Base(file)
+	if len(transactions) == 0 {
+		return identity
+	}
+
+	tx := transactions[0]
+	identity += "|" + tx.AccountType
+	if tx.AccountNumber != nil {
+		identity += "|" + *tx.AccountNumber
+	}
+	if statement != nil && statement.ClosingDate != "" {
+		return identity + "|" + statement.ClosingDate
+	}
+	first, last := tx.Date, tx.Date
+	for _, tx := range transactions {
+		first, last = min(first, tx.Date), max(last, tx.Date)
+	}
+	return identity + "|" + month(first) + ".." + month(last)
+}
+
+// month cuts a YYYY-MM-DD date to its month
+func month(date string) string {
+	if len(date) < len("2006-01") {
+		return date
+	}
+	return date[:len("2006-01")]
+}
+
+// lineFingerprint identifies a statement line across regenerated PDFs
+func lineFingerprint(tx PythonTransaction) string {
+	return fmt.Sprintf("%s|%.2f|%s", tx.Date, tx.Amount, tx.Description)
+}
+
+// diffStatement splits the new parse into lines to upload and reports lines that disappeared
+func diffStatement(file string, previous, current []PythonTransaction) ([]PythonTransaction, FileDiff) {
+	// Count fingerprints so identical lines on the same day are matched one to one
+	seen := make(map[string]int, len(previous))
+	for _, tx := range previous {
+		seen[lineFingerprint(tx)]++
+	}
+
+	diff := FileDiff{File: file}
+	var upload []PythonTransaction
+	for _, tx := range current {
+		fp := lineFingerprint(tx)
+		if seen[fp] > 0 {
+			seen[fp]--
+			diff.Unchanged++
+			continue
+		}
+		upload = append(upload, tx)
+	}
+	diff.Added = len(upload)
+
+	for _, tx := range previous {
+		fp := lineFingerprint(tx)
+		if seen[fp] > 0 {
+			seen[fp]--
+			diff.Removed = append(diff.Removed, tx)
+		}
+	}
+
+	return upload, diff
+}
@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// decodeText returns the contents of a CSV, OFX or text statement as UTF-8. Excel and older bank
+// systems save exports in Windows-1252, whose accents aren't valid UTF-8, so a file that isn't
+// valid UTF-8 is read as Windows-1252, in which every byte is a character.
+func decodeText(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(decoded)
+}
+
+// normalizeText composes accents with their letters, so "é" written as "e" and a combining accent,
+// as some PDFs and Macs do, matches rules and merchants written with "é"
+func normalizeText(text string) string {
+	if norm.NFC.IsNormalString(text) {
+		return text
+	}
+	return norm.NFC.String(text)
+}
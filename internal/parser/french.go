@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// frenchMonths maps the month names and abbreviations on Québec statements to the English ones
+// time.Parse knows; full names come first so "juillet" isn't read as "juil"
+var frenchMonths = []struct{ french, english string }{
+	{"janvier", "January"}, {"février", "February"}, {"fevrier", "February"}, {"avril", "April"},
+	{"juillet", "July"}, {"septembre", "September"}, {"octobre", "October"}, {"novembre", "November"},
+	{"décembre", "December"}, {"decembre", "December"},
+	{"janv", "Jan"}, {"févr", "Feb"}, {"fevr", "Feb"}, {"fév", "Feb"}, {"mars", "Mar"}, {"avr", "Apr"},
+	{"mai", "May"}, {"juin", "Jun"}, {"juil", "Jul"}, {"août", "Aug"}, {"aout", "Aug"}, {"sept", "Sep"},
+	{"déc", "Dec"},
+}
+
+// frenchMonthPattern matches one French month as a whole word, with the period abbreviations often carry
+var frenchMonthPattern = func() *regexp.Regexp {
+	names := make([]string, len(frenchMonths))
+	for i, m := range frenchMonths {
+		names[i] = regexp.QuoteMeta(m.french)
+	}
+	return regexp.MustCompile(`(?i)(^|[^\p{L}])(` + strings.Join(names, "|") + `)\.?($|[^\p{L}])`)
+}()
+
+// englishMonths rewrites French month names in a date, "5 déc. 2024" becomes "5 Dec 2024"
+func englishMonths(value string) string {
+	return frenchMonthPattern.ReplaceAllStringFunc(value, func(match string) string {
+		m := frenchMonthPattern.FindStringSubmatch(match)
+		for _, month := range frenchMonths {
+			if strings.EqualFold(m[2], month.french) {
+				return m[1] + month.english + m[3]
+			}
+		}
+		return match
+	})
+}
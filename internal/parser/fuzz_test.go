@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzParseJSONOutput makes sure malformed parser output is rejected with an error, never a panic
+func FuzzParseJSONOutput(f *testing.F) {
+	fixtures, _ := filepath.Glob(filepath.Join("testdata", "python", "*.json"))
+	for _, fixture := range fixtures {
+		if data, err := os.ReadFile(fixture); err == nil {
+			f.Add(string(data))
+		}
+	}
+	f.Add(`{"transactions":[{"date":"not a date","amount":1}]}`)
+	f.Add(`{"transactions":null,"file_results":[{}],"summary":{}}`)
+	f.Add(``)
+
+	p := NewPythonParser()
+	f.Fuzz(func(t *testing.T, output string) {
+		result, transactions, err := p.parseJSONOutput(output)
+		if err != nil {
+			return
+		}
+		if len(transactions) != len(result.Transactions) {
+			t.Fatalf("got %d transactions from %d parsed", len(transactions), len(result.Transactions))
+		}
+		for _, tx := range transactions {
+			if tx.TxAmount < 0 {
+				t.Fatalf("amount %v should be non-negative after direction split", tx.TxAmount)
+			}
+		}
+	})
+}
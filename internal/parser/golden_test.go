@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files from current parser output")
+
+// goldenParsers maps a testdata subdirectory to the function that turns one fixture into output
+var goldenParsers = map[string]func(t *testing.T, input string) any{
+	"python":   parsePythonFixture,
+	"csv":      parseCSVFixture,
+	"ofx":      parseOFXFixture,
+	"backup":   parseBackupFixture,
+	"template": parseTemplateFixture,
+}
+
+// TestGolden runs every fixture in testdata/<parser>/ and compares against its .golden.json
+func TestGolden(t *testing.T) {
+	for name, parse := range goldenParsers {
+		inputs, err := filepath.Glob(filepath.Join("testdata", name, "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, input := range inputs {
+			if strings.HasSuffix(input, ".golden.json") || strings.HasSuffix(input, ".config.json") {
+				continue
+			}
+
+			t.Run(name+"/"+filepath.Base(input), func(t *testing.T) {
+				got, err := json.MarshalIndent(parse(t, input), "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, '\n')
+
+				golden := strings.TrimSuffix(input, filepath.Ext(input)) + ".golden.json"
+				if *update {
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("missing golden file, run go test ./internal/parser -update: %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("output differs from %s, run with -update if the change is intended\n--- got\n%s", golden, got)
+				}
+			})
+		}
+	}
+}
+
+// parsePythonFixture accepts captured parser JSON, or a real PDF when uv and the parser checkout are available
+func parsePythonFixture(t *testing.T, input string) any {
+	t.Helper()
+
+	var output []byte
+	switch strings.ToLower(filepath.Ext(input)) {
+	case ".json":
+		data, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output = data
+	case ".pdf":
+		if _, err := exec.LookPath("uv"); err != nil {
+			t.Skip("uv not installed")
+		}
+		if _, err := os.Stat("../../rbc-statement-parser/main.py"); err != nil {
+			t.Skip("rbc-statement-parser checkout missing")
+		}
+
+		abs, err := filepath.Abs(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := NewPythonParser()
+		wd, _ := os.Getwd()
+		if err := os.Chdir("../.."); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chdir(wd)
+
+		output, err = p.run(abs, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Skipf("unsupported fixture %s", input)
+	}
+
+	// PDFs of other banks come back as text for the built-in templates
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, transactions, err := NewPythonParser().WithTemplates(templates).parseJSONOutput(string(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Source paths differ per machine, so keep only the file name
+	for _, tx := range transactions {
+		tx.SourceFilePath = filepath.Base(tx.SourceFilePath)
+	}
+
+	return struct {
+		Summary      any `json:"summary"`
+		Transactions any `json:"transactions"`
+	}{result.Summary, transactions}
+}
+
+// parseCSVFixture runs one exported CSV through the CSV parser
+func parseCSVFixture(t *testing.T, input string) any {
+	t.Helper()
+
+	// Exports that need settings, like Splitwise's name, have a parser config beside them
+	config := strings.TrimSuffix(input, filepath.Ext(input)) + ".config.json"
+	if _, err := os.Stat(config); err != nil {
+		config = ""
+	}
+	result, transactions, err := NewCSVParser().ParseStatements(input, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tx := range transactions {
+		tx.SourceFilePath = filepath.Base(tx.SourceFilePath)
+	}
+
+	return struct {
+		Summary      any `json:"summary"`
+		Transactions any `json:"transactions"`
+	}{result.Summary, transactions}
+}
+
+// parseOFXFixture runs one OFX or QFX download through the OFX parser
+func parseOFXFixture(t *testing.T, input string) any {
+	t.Helper()
+
+	result, transactions, err := NewOFXParser().ParseStatements(input, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tx := range transactions {
+		tx.SourceFilePath = filepath.Base(tx.SourceFilePath)
+	}
+
+	return struct {
+		Summary      any `json:"summary"`
+		Transactions any `json:"transactions"`
+	}{result.Summary, transactions}
+}
+
+// parseBackupFixture runs one ariand backup through the backup parser
+func parseBackupFixture(t *testing.T, input string) any {
+	t.Helper()
+
+	result, transactions, err := NewBackupParser().ParseStatements(input, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tx := range transactions {
+		tx.SourceFilePath = filepath.Base(tx.SourceFilePath)
+	}
+
+	return struct {
+		Summary      any `json:"summary"`
+		Transactions any `json:"transactions"`
+	}{result.Summary, transactions}
+}
+
+// parseTemplateFixture runs one text statement through the templates in testdata/templates
+func parseTemplateFixture(t *testing.T, input string) any {
+	t.Helper()
+
+	templates, err := LoadTemplates(filepath.Join("testdata", "templates"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, transactions, err := templates.ParseStatements(input, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tx := range transactions {
+		tx.SourceFilePath = filepath.Base(tx.SourceFilePath)
+	}
+
+	return struct {
+		Summary      any `json:"summary"`
+		Transactions any `json:"transactions"`
+	}{result.Summary, transactions}
+}
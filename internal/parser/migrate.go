@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"cmp"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Exports of budgeting apps, read to move their history into ariand. Their lines were categorized
+// and named by the app already, which is kept: the app's category as the bank's category, the
+// merchant as the merchant.
+
+// migratedAccountTypes guesses an account's type from the name a budgeting app gave it, which is
+// all its exports say about the account
+var migratedAccountTypes = []struct {
+	pattern     *regexp.Regexp
+	accountType string
+}{
+	{regexp.MustCompile(`(?i)\b(visa|mastercard|amex|american express|credit|card)\b`), "visa"},
+	{regexp.MustCompile(`(?i)\b(saving|savings|tfsa|high interest|hisa)\b`), "savings"},
+	{regexp.MustCompile(`(?i)\b(rrsp|brokerage|investment|invest|401k|ira|resp)\b`), "investment"},
+	{regexp.MustCompile(`(?i)\b(loan|mortgage|line of credit|loc)\b`), "loan"},
+}
+
+// migratedAccountType is the type of a budgeting app's account, chequing unless its name says
+func migratedAccountType(name string) string {
+	for _, t := range migratedAccountTypes {
+		if t.pattern.MatchString(name) {
+			return t.accountType
+		}
+	}
+	return "chequing"
+}
+
+// arianExportFormat reads the CSV this tool writes with -export, so an export can be uploaded
+// later or to another ariand. Its categories are ariand's slugs already.
+var arianExportFormat = csvFormat{
+	name: "Arian",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "account", "account_type", "bank", "currency", "amount", "description", "merchant", "category", "source_file")
+	},
+	parse: parseArianExport,
+}
+
+func parseArianExport(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		date, err := parseCSVDate(row.get("date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		pending, _ := strconv.ParseBool(row.get("pending"))
+
+		tx := PythonTransaction{
+			Date:         date,
+			Amount:       amount,
+			Method:       row.get("method"),
+			Description:  row.get("description"),
+			AccountType:  cmp.Or(row.get("account_type"), "chequing"),
+			AccountName:  row.get("account"),
+			SourceFile:   file,
+			Line:         row.line,
+			Pending:      pending,
+			Currency:     strings.ToUpper(row.get("currency")),
+			Notes:        row.get("notes"),
+			Bank:         cmp.Or(row.get("bank"), "Arian"),
+			Merchant:     row.get("merchant"),
+			CategorySlug: row.get("category"),
+		}
+		if reference := row.get("reference"); reference != "" {
+			tx.Code = &reference
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// mintFormat reads Mint's transactions.csv. Amounts are unsigned, the transaction type says which
+// way the money went, and always in US dollars.
+var mintFormat = csvFormat{
+	name: "Mint",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "description", "original description", "amount", "transaction type", "category", "account name")
+	},
+	parse: parseMint,
+}
+
+func parseMint(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		date, err := parseCSVDate(row.get("date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		if strings.EqualFold(row.get("transaction type"), "debit") {
+			amount = -amount
+		}
+		account := row.get("account name")
+
+		transactions = append(transactions, PythonTransaction{
+			Date:         date,
+			Amount:       amount,
+			Description:  row.first("original description", "description"),
+			AccountType:  migratedAccountType(account),
+			AccountName:  account,
+			SourceFile:   file,
+			Line:         row.line,
+			Currency:     "USD",
+			Notes:        migratedNotes(row.get("notes"), row.get("labels")),
+			Bank:         "Mint",
+			Merchant:     row.get("description"),
+			BankCategory: row.get("category"),
+		})
+	}
+	return transactions, nil
+}
+
+// monarchFormat reads Monarch Money's transaction export, whose amounts are signed
+var monarchFormat = csvFormat{
+	name: "Monarch",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "merchant", "category", "account", "original statement", "amount")
+	},
+	parse: parseMonarch,
+}
+
+func parseMonarch(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		date, err := parseCSVDate(row.get("date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		account := row.get("account")
+
+		transactions = append(transactions, PythonTransaction{
+			Date:         date,
+			Amount:       amount,
+			Description:  row.first("original statement", "merchant"),
+			AccountType:  migratedAccountType(account),
+			AccountName:  account,
+			SourceFile:   file,
+			Line:         row.line,
+			Notes:        migratedNotes(row.get("notes"), row.get("tags")),
+			Bank:         "Monarch",
+			Merchant:     row.get("merchant"),
+			BankCategory: row.get("category"),
+		})
+	}
+	return transactions, nil
+}
+
+// migratedNotes joins a line's notes with the labels or tags the app had on it
+func migratedNotes(notes, tags string) string {
+	if tags == "" {
+		return notes
+	}
+	return strings.TrimSpace(notes + "\ntags: " + tags)
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Number formats a CSV export or template can declare, written the way they print a thousand and a quarter.
+// Without one, the format is worked out from all the amounts of the file, see detectNumberFormat.
+const (
+	NumberFormatAuto  = ""
+	NumberFormatPoint = "1,234.56" // English
+	NumberFormatComma = "1.234,56" // most of continental Europe
+	NumberFormatSpace = "1 234,56" // French, Québec
+)
+
+// checkNumberFormat rejects number formats parseAmount doesn't know
+func checkNumberFormat(format string) error {
+	switch format {
+	case NumberFormatAuto, NumberFormatPoint, NumberFormatComma, NumberFormatSpace:
+		return nil
+	}
+	return fmt.Errorf("unknown number format %q, expected %q, %q or %q", format, NumberFormatPoint, NumberFormatComma, NumberFormatSpace)
+}
+
+// groupSeparators never mark decimals, whatever the format
+var groupSeparators = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "")
+
+// cleanAmount strips what never decides the format: parentheses, currency symbols and spaces
+func cleanAmount(raw string) (value string, negative bool) {
+	value = strings.TrimSpace(raw)
+	negative = strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	value = strings.Trim(value, "()")
+	value = strings.NewReplacer("$", "", "€", "", "£", "").Replace(value)
+	return groupSeparators.Replace(value), negative
+}
+
+// parseAmount reads an amount with thousands separators, currency symbols or parentheses for
+// negatives. Without a format, the amount alone has to show it.
+func parseAmount(raw, format string) (float64, error) {
+	value, negative := cleanAmount(raw)
+	if value == "" {
+		return 0, nil
+	}
+
+	if format == NumberFormatAuto {
+		detected, err := detectNumberFormat([]string{raw})
+		if err != nil {
+			return 0, fmt.Errorf("%w, declare the number format", err)
+		}
+		format = detected
+	}
+	switch format {
+	case NumberFormatPoint:
+		value = strings.ReplaceAll(value, ",", "")
+	case NumberFormatComma, NumberFormatSpace:
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", raw)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// detectNumberFormat works out the decimal mark from all the amounts of a file, since one amount
+// rarely shows it. An amount shows a point as the decimal mark when a comma comes before it, when it
+// is the only mark and is not followed by exactly three digits, or when commas appear more than once,
+// and the other way round for a comma. Amounts like "1.500" could be either and show nothing. When
+// no amount shows the mark but some could be either, or amounts show both, there is no telling what
+// the file means and it fails, so the format has to be declared.
+func detectNumberFormat(values []string) (string, error) {
+	var point, comma, either string
+	for _, raw := range values {
+		value, _ := cleanAmount(raw)
+		switch decimalMark(value) {
+		case '.':
+			point = cmp.Or(point, raw)
+		case ',':
+			comma = cmp.Or(comma, raw)
+		case '?':
+			either = cmp.Or(either, raw)
+		}
+	}
+
+	switch {
+	case point != "" && comma != "":
+		return "", fmt.Errorf("amounts %q and %q are written in different number formats", point, comma)
+	case comma != "":
+		return NumberFormatComma, nil
+	case point == "" && either != "":
+		return "", fmt.Errorf("amount %q could be read with a point or a comma as the decimal mark", either)
+	}
+	return NumberFormatPoint, nil
+}
+
+// decimalMark reports the decimal mark a cleaned amount shows: '.', ',', '?' when it could be either,
+// or 0 when it has no mark at all
+func decimalMark(value string) rune {
+	comma, point := strings.LastIndex(value, ","), strings.LastIndex(value, ".")
+	switch {
+	case comma >= 0 && point >= 0 && comma > point:
+		return ','
+	case comma >= 0 && point >= 0:
+		return '.'
+	case strings.Count(value, ",") > 1:
+		return '.'
+	case strings.Count(value, ".") > 1:
+		return ','
+	case comma >= 0 && len(value)-comma-1 == 3:
+		return '?'
+	case comma >= 0:
+		return ','
+	case point >= 0 && len(value)-point-1 == 3:
+		return '?'
+	case point >= 0:
+		return '.'
+	}
+	return 0
+}
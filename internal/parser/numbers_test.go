@@ -0,0 +1,56 @@
+package parser
+
+import "testing"
+
+func TestDetectNumberFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string // "" for a file that can't be read without a declared format
+	}{
+		{"english", []string{"1,500", "12.50", "(3.99)"}, NumberFormatPoint},
+		{"european", []string{"1.500", "12,50", "€3,99"}, NumberFormatComma},
+		{"french", []string{"1 234,56", "1 500"}, NumberFormatComma},
+		{"both marks", []string{"1.234,56"}, NumberFormatComma},
+		{"grouping only", []string{"1,234,567"}, NumberFormatPoint},
+		{"whole amounts", []string{"12", "-40", ""}, NumberFormatPoint},
+		{"only thousands", []string{"1.500", "2.250"}, ""},
+		{"only three decimals", []string{"1,500", "12"}, ""},
+		{"mixed", []string{"12.50", "3,99"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectNumberFormat(tt.values)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("detectNumberFormat(%q) = %q, want an error", tt.values, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("detectNumberFormat(%q) = %q, %v, want %q", tt.values, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		raw, format string
+		want        float64
+	}{
+		{"1,500", NumberFormatPoint, 1500},
+		{"1.500", NumberFormatComma, 1500},
+		{"1 500,25", NumberFormatSpace, 1500.25},
+		{"(3.99)", NumberFormatPoint, -3.99},
+		{"$12.5", NumberFormatAuto, 12.5},
+	}
+	for _, tt := range tests {
+		if got, err := parseAmount(tt.raw, tt.format); err != nil || got != tt.want {
+			t.Errorf("parseAmount(%q, %q) = %v, %v, want %v", tt.raw, tt.format, got, err, tt.want)
+		}
+	}
+	if _, err := parseAmount("1.500", NumberFormatAuto); err == nil {
+		t.Error("parseAmount guessed at 1.500")
+	}
+}
@@ -0,0 +1,297 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// ofxExts are the extensions of OFX downloads; Quicken's .qfx is OFX with an extra tag
+var ofxExts = []string{".ofx", ".qfx"}
+
+// ofxMethods maps OFX transaction types to methods, credits, debits and others are left to the description
+var ofxMethods = map[string]string{
+	"pos":         "pos",
+	"atm":         "atm",
+	"check":       "cheque",
+	"fee":         "fee",
+	"srvchg":      "fee",
+	"int":         "fee",
+	"div":         "dividend",
+	"dep":         "deposit",
+	"directdep":   "deposit",
+	"directdebit": "pre-auth",
+	"repeatpmt":   "pre-auth",
+	"xfer":        "online",
+	"payment":     "online",
+}
+
+// ofxAccountTypes maps ACCTTYPE of a bank account to the parser's account types
+var ofxAccountTypes = map[string]string{
+	"checking":   "chequing",
+	"savings":    "savings",
+	"moneymrkt":  "savings",
+	"creditline": "visa",
+}
+
+// ofxToken is a tag of an OFX file with the text that follows it. OFX 1.x is SGML, whose elements
+// are never closed, and 2.x is XML, so only the aggregates' closing tags can be relied on.
+type ofxToken struct {
+	tag     string
+	closing bool
+	value   string
+}
+
+// ofxTokens splits an OFX file into tags, skipping the header and XML declarations
+func ofxTokens(data string) []ofxToken {
+	var tokens []ofxToken
+	for {
+		start := strings.IndexByte(data, '<')
+		if start < 0 {
+			return tokens
+		}
+		end := strings.IndexByte(data[start:], '>')
+		if end < 0 {
+			return tokens
+		}
+		tag := data[start+1 : start+end]
+		data = data[start+end+1:]
+		if tag == "" || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+
+		token := ofxToken{tag: strings.ToUpper(strings.TrimSpace(tag))}
+		if token.tag[0] == '/' {
+			token.tag, token.closing = token.tag[1:], true
+		}
+		next := strings.IndexByte(data, '<')
+		if next < 0 {
+			next = len(data)
+		}
+		token.value = html.UnescapeString(strings.TrimSpace(data[:next]))
+		tokens = append(tokens, token)
+	}
+}
+
+// ofxStatement is one account's statement in an OFX file, which can hold several
+type ofxStatement struct {
+	card         bool
+	fields       map[string]string // CURDEF, ACCTID, ACCTTYPE
+	balance      string            // LEDGERBAL's BALAMT
+	balanceDate  string            // and DTASOF
+	transactions []map[string]string
+}
+
+// readOFX finds the statements of bank accounts and cards in an OFX file, and the institution's name
+func readOFX(data string) ([]*ofxStatement, string) {
+	var (
+		statements []*ofxStatement
+		statement  *ofxStatement
+		tx         map[string]string
+		org        string
+		ledger     bool
+	)
+	for _, token := range ofxTokens(data) {
+		switch {
+		case token.tag == "STMTRS" || token.tag == "CCSTMTRS":
+			if token.closing {
+				if statement != nil {
+					statements = append(statements, statement)
+				}
+				statement = nil
+			} else {
+				statement = &ofxStatement{card: token.tag == "CCSTMTRS", fields: make(map[string]string)}
+			}
+		case token.tag == "STMTTRN" && statement != nil:
+			if token.closing {
+				if tx != nil {
+					statement.transactions = append(statement.transactions, tx)
+				}
+				tx = nil
+			} else {
+				tx = make(map[string]string)
+			}
+		case token.tag == "LEDGERBAL":
+			ledger = !token.closing
+		case token.closing:
+		case token.tag == "ORG" && org == "":
+			org = token.value
+		case tx != nil:
+			tx[token.tag] = token.value
+		case statement != nil && ledger && token.tag == "BALAMT":
+			statement.balance = token.value
+		case statement != nil && ledger && token.tag == "DTASOF":
+			statement.balanceDate = token.value
+		case statement != nil:
+			statement.fields[token.tag] = token.value
+		}
+	}
+	return statements, org
+}
+
+// parseOFXDate reads an OFX date, YYYYMMDD followed by an optional time and time zone
+func parseOFXDate(raw string) (string, error) {
+	if len(raw) < 8 {
+		return "", fmt.Errorf("unrecognized date %q", raw)
+	}
+	date, err := time.Parse("20060102", raw[:8])
+	if err != nil {
+		return "", fmt.Errorf("unrecognized date %q", raw)
+	}
+	return date.Format(csvDateLayout), nil
+}
+
+// parseOFXAmount reads an OFX amount, which never groups thousands but may have a decimal comma
+func parseOFXAmount(raw string) (float64, error) {
+	return parseAmount(strings.Replace(raw, ",", ".", 1), NumberFormatPoint)
+}
+
+// OFXParser reads OFX and QFX downloads, which most banks offer for Quicken and other money apps
+type OFXParser struct{}
+
+func NewOFXParser() *OFXParser {
+	return &OFXParser{}
+}
+
+// ParseStatements parses every .ofx and .qfx file under path; files without bank or card statements
+// are reported as not processed
+func (p *OFXParser) ParseStatements(path string, _ string) (*ParseResult, []*domain.Transaction, error) {
+	var files []string
+	for _, ext := range ofxExts {
+		found, err := listFiles(path, ext)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, found...)
+	}
+
+	result := &ParseResult{}
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		rows, err := parseOFX(decodeText(data), file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(file), err)
+		}
+
+		result.Transactions = append(result.Transactions, rows...)
+		result.FileResults = append(result.FileResults, FileResult{
+			File:             file,
+			TransactionCount: len(rows),
+			Processed:        len(rows) > 0,
+		})
+		result.Summary.TotalFiles++
+		if len(rows) > 0 {
+			result.Summary.ProcessedFiles++
+		}
+	}
+	result.Summary.TotalTransactions = len(result.Transactions)
+
+	transactions, err := toTransactions(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, transactions, nil
+}
+
+// parseOFX reads the lines of every statement in an OFX file
+func parseOFX(data, file string) ([]PythonTransaction, error) {
+	statements, org := readOFX(data)
+	// Some downloads leave the institution out of the sign-on
+	bank := org
+	if bank == "" {
+		bank = "OFX"
+	}
+
+	var transactions []PythonTransaction
+	for _, statement := range statements {
+		accountType, label := "visa", "Card"
+		if !statement.card {
+			accountType = ofxAccountTypes[strings.ToLower(statement.fields["ACCTTYPE"])]
+			if accountType == "" {
+				accountType = "chequing"
+			}
+			label = map[string]string{"chequing": "Chequing", "savings": "Savings", "visa": "Line of Credit"}[accountType]
+		}
+		var number *string
+		if id := statement.fields["ACCTID"]; id != "" {
+			number = &id
+		}
+		currency := strings.ToUpper(statement.fields["CURDEF"])
+
+		first := len(transactions)
+		for _, fields := range statement.transactions {
+			posted, err := parseOFXDate(fields["DTPOSTED"])
+			if err != nil {
+				return nil, err
+			}
+			date := posted
+			if fields["DTUSER"] != "" {
+				if date, err = parseOFXDate(fields["DTUSER"]); err != nil {
+					return nil, err
+				}
+			}
+			amount, err := parseOFXAmount(fields["TRNAMT"])
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s: %w", fields["FITID"], err)
+			}
+
+			tx := PythonTransaction{
+				Date:          date,
+				PostingDate:   posted,
+				Amount:        amount,
+				Method:        ofxMethods[strings.ToLower(fields["TRNTYPE"])],
+				Description:   cmp.Or(fields["NAME"], fields["MEMO"]),
+				AccountNumber: number,
+				AccountType:   accountType,
+				AccountName:   strings.TrimSpace(org + " " + label),
+				SourceFile:    file,
+				Currency:      currency,
+				Bank:          bank,
+			}
+			// NAME is often cut short, MEMO has the rest of what the bank printed
+			if memo := fields["MEMO"]; memo != "" && memo != tx.Description {
+				tx.Notes = "memo: " + memo
+			}
+			if check := fields["CHECKNUM"]; check != "" {
+				tx.Code = &check
+				tx.Method = "cheque"
+			} else if id := fields["FITID"]; id != "" {
+				tx.Code = &id
+			}
+			transactions = append(transactions, tx)
+		}
+
+		// The ledger balance is as of the end of the statement, so it goes on the last line. A card's
+		// is what is owed, which the card exports leave out too.
+		if statement.card || statement.balance == "" || len(transactions) == first {
+			continue
+		}
+		balance, err := parseOFXAmount(statement.balance)
+		if err != nil {
+			continue
+		}
+		last := first
+		for i := first; i < len(transactions); i++ {
+			if transactions[i].Date >= transactions[last].Date {
+				last = i
+			}
+		}
+		if asOf, err := parseOFXDate(statement.balanceDate); err != nil || asOf >= transactions[last].Date {
+			transactions[last].Balance = &balance
+		}
+	}
+	return transactions, nil
+}
@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"math"
+	"strings"
+)
+
+// paypalFormat reads PayPal's activity download (Activity > Statements > Activity download, CSV)
+var paypalFormat = csvFormat{
+	name: "PayPal",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "name", "type", "status", "currency", "gross", "fee", "transaction id")
+	},
+	parse: parsePayPal,
+}
+
+// paypalRow is one line of the export; PayPal writes several per purchase
+type paypalRow struct {
+	date        string
+	name        string
+	kind        string
+	status      string
+	currency    string
+	gross       float64
+	fee         float64
+	id          string
+	reference   string
+	itemTitle   string
+	description string
+	line        int // row in the file
+}
+
+// conversion reports whether the row is one side of a currency conversion
+func (r paypalRow) conversion() bool {
+	return strings.Contains(strings.ToLower(r.kind), "currency conversion")
+}
+
+// funding reports whether the row is money pulled in from a bank or card to pay for something
+func (r paypalRow) funding() bool {
+	kind := strings.ToLower(r.kind)
+	return strings.Contains(kind, "deposit to pp account") || strings.Contains(kind, "card deposit") ||
+		strings.Contains(kind, "card funding") || strings.Contains(kind, "bank deposit")
+}
+
+func parsePayPal(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var lines []paypalRow
+	for _, row := range rows {
+		status := strings.ToLower(row.get("status"))
+		if status != "completed" && status != "pending" {
+			continue // denied, reversed and cancelled lines never moved money
+		}
+
+		date, err := parseCSVDate(row.get("date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		gross, err := row.amount("gross")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		fee, err := row.amount("fee")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+
+		lines = append(lines, paypalRow{
+			date:        date,
+			name:        row.get("name"),
+			kind:        row.get("type"),
+			status:      status,
+			currency:    strings.ToUpper(row.get("currency")),
+			gross:       gross,
+			fee:         fee,
+			id:          row.get("transaction id"),
+			reference:   row.get("reference txn id"),
+			itemTitle:   row.get("item title"),
+			description: row.get("subject"),
+			line:        row.line,
+		})
+	}
+
+	// Conversion and funding rows point at the payment they belong to through the reference column
+	ids := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		ids[line.id] = true
+	}
+
+	groups := make(map[string][]paypalRow)
+	var order []string
+	for _, line := range lines {
+		root := line.id
+		if line.reference != "" && ids[line.reference] && (line.conversion() || line.funding()) {
+			root = line.reference
+		}
+		if _, ok := groups[root]; !ok {
+			order = append(order, root)
+		}
+		groups[root] = append(groups[root], line)
+	}
+
+	var transactions []PythonTransaction
+	for _, root := range order {
+		transactions = append(transactions, paypalTransactions(groups[root], file)...)
+	}
+	return transactions, nil
+}
+
+// paypalTransactions merges one payment's rows into a single transaction, plus a separate fee line.
+// A payment in another currency is recorded in the currency it was converted from, with the
+// original amount and rate in the notes; the funding pulled from the bank is folded in.
+func paypalTransactions(group []paypalRow, file string) []PythonTransaction {
+	primary := -1
+	for i, line := range group {
+		if !line.conversion() && !line.funding() {
+			primary = i
+			break
+		}
+	}
+
+	// Only conversions or only a top-up: cash moving within PayPal or in from the bank
+	if primary == -1 {
+		for _, line := range group {
+			if line.funding() {
+				return []PythonTransaction{paypalTransaction(line, line.gross, line.currency, "PayPal top-up", "deposit", file)}
+			}
+		}
+		return nil
+	}
+
+	main := group[primary]
+	amount, currency := main.gross, main.currency
+
+	// A payment in another currency is recorded in the one it was converted from, the payment's own
+	// amount is the original
+	var original *float64
+	var originalCurrency string
+	for _, line := range group {
+		if !line.conversion() || line.currency == main.currency || math.Signbit(line.gross) != math.Signbit(main.gross) {
+			continue
+		}
+		amount, currency = line.gross, line.currency
+		if main.gross != 0 {
+			gross := main.gross
+			original, originalCurrency = &gross, main.currency
+		}
+		break
+	}
+
+	description := main.name
+	switch {
+	case strings.Contains(strings.ToLower(main.kind), "withdrawal"):
+		description = "PayPal withdrawal"
+	case description == "" && main.description != "":
+		description = main.description
+	case description == "":
+		description = main.kind
+	}
+	if main.itemTitle != "" {
+		description += " - " + main.itemTitle
+	}
+
+	payment := paypalTransaction(main, amount, currency, description, "online", file)
+	payment.OriginalAmount, payment.OriginalCurrency = original, originalCurrency
+	transactions := []PythonTransaction{payment}
+
+	// Fees are their own line so they can be categorized apart from the sale they came out of
+	if main.fee != 0 {
+		fee := paypalTransaction(main, main.fee, main.currency, "PayPal fee: "+description, "fee", file)
+		transactions = append(transactions, fee)
+	}
+
+	return transactions
+}
+
+func paypalTransaction(line paypalRow, amount float64, currency, description, method, file string) PythonTransaction {
+	var code *string
+	if line.id != "" {
+		id := line.id
+		code = &id
+	}
+
+	return PythonTransaction{
+		Date:        line.date,
+		Amount:      amount,
+		Method:      method,
+		Category:    line.kind,
+		Code:        code,
+		Description: description,
+		AccountType: "chequing",
+		AccountName: "PayPal",
+		SourceFile:  file,
+		Line:        line.line,
+		Pending:     line.status == "pending",
+		Currency:    currency,
+		Bank:        "PayPal",
+	}
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// profileColumns are the chequing columns a profile may move, matching DEFAULT_COLUMNS in the Python parser
+var profileColumns = map[string]bool{
+	"date":        true,
+	"description": true,
+	"withdrawal":  true,
+	"deposit":     true,
+	"balance":     true,
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Region is a horizontal band of a page the parser should not read, such as a promo box
+type Region struct {
+	Page   int     `json:"page,omitempty"` // 1-based, 0 means every page
+	Top    float64 `json:"top"`
+	Bottom float64 `json:"bottom"`
+}
+
+// Profile tunes extraction for one statement layout. The parser picks the first profile with a
+// header keyword on the statement's first page.
+type Profile struct {
+	Name           string                  `json:"name"`
+	HeaderKeywords []string                `json:"header_keywords"`
+	Layout         string                  `json:"layout,omitempty"`       // chequing, visa, investment or loan
+	AccountType    string                  `json:"account_type,omitempty"` // overrides detection
+	Columns        map[string][][2]float64 `json:"columns,omitempty"`      // left offsets in points
+	IgnoreRegions  []Region                `json:"ignore_regions,omitempty"`
+	// CurrencySections adds headings (regexes) that switch the currency of the lines below them
+	CurrencySections map[string][]string `json:"currency_sections,omitempty"`
+}
+
+// Config is the parser config file passed to the Python parser with -config
+type Config struct {
+	Format     string              `json:"format,omitempty"`
+	Categories map[string][]string `json:"categories,omitempty"`
+	Excludes   []string            `json:"excludes,omitempty"`
+	Profiles   []Profile           `json:"profiles,omitempty"`
+	// NumberFormats sets how a CSV export writes amounts, by institution, e.g. {"PayPal": "1.234,56"}
+	NumberFormats map[string]string `json:"number_formats,omitempty"`
+	// Signs sets how a CSV export signs amounts, by institution, e.g. {"Chase": "inverted"} for an
+	// export that writes purchases as positive numbers
+	Signs map[string]string `json:"signs,omitempty"`
+	// SummaryLines adds patterns (regexes) for descriptions of lines that restate a balance or total
+	// rather than move money, matched from the start of the description
+	SummaryLines []string `json:"summary_lines,omitempty"`
+	// SplitwiseName is your name in Splitwise exports, which have a column per member of the group
+	SplitwiseName string `json:"splitwise_name,omitempty"`
+}
+
+// LoadConfig reads and checks the parser config. The Python parser silently ignores a config it
+// can't read, so mistakes are caught here before they turn into missing transactions.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parser config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse parser config %s: %w", path, err)
+	}
+
+	for i, profile := range config.Profiles {
+		if err := profile.check(); err != nil {
+			return nil, fmt.Errorf("profile %d in %s: %w", i+1, path, err)
+		}
+	}
+
+	for institution, format := range config.NumberFormats {
+		if !knownCSVFormat(institution) {
+			return nil, fmt.Errorf("number format for %q in %s: no CSV export by that name", institution, path)
+		}
+		if err := checkNumberFormat(format); err != nil {
+			return nil, fmt.Errorf("number format for %q in %s: %w", institution, path, err)
+		}
+	}
+
+	for institution, sign := range config.Signs {
+		if !knownCSVFormat(institution) {
+			return nil, fmt.Errorf("sign for %q in %s: no CSV export by that name", institution, path)
+		}
+		// Each export's columns are read by its own parser, so only the sign of the result can change
+		if sign != SignAsIs && sign != SignInverted {
+			return nil, fmt.Errorf("sign for %q in %s: unknown sign %q, expected %s or %s", institution, path, sign, SignAsIs, SignInverted)
+		}
+	}
+
+	if _, err := compileSummaryLines(config.SummaryLines); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// knownCSVFormat reports whether name is one of the CSV exports, ignoring case
+func knownCSVFormat(name string) bool {
+	for _, format := range csvFormats {
+		if strings.EqualFold(format.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Profile) check() error {
+	if len(p.HeaderKeywords) == 0 {
+		return fmt.Errorf("%s needs at least one header keyword", p.label())
+	}
+	switch p.Layout {
+	case "", "chequing", "visa", "investment", "loan":
+	default:
+		return fmt.Errorf("%s has unknown layout %q, expected chequing, visa, investment or loan", p.label(), p.Layout)
+	}
+	if len(p.Columns) > 0 && p.Layout != "" && p.Layout != "chequing" {
+		return fmt.Errorf("%s sets columns, which only apply to the chequing layout", p.label())
+	}
+
+	for column, ranges := range p.Columns {
+		if !profileColumns[column] {
+			return fmt.Errorf("%s has unknown column %q", p.label(), column)
+		}
+		for _, r := range ranges {
+			if r[0] >= r[1] {
+				return fmt.Errorf("%s column %s range [%g, %g] is empty", p.label(), column, r[0], r[1])
+			}
+		}
+	}
+
+	for _, region := range p.IgnoreRegions {
+		if region.Page < 0 || region.Top >= region.Bottom {
+			return fmt.Errorf("%s has an invalid ignore region (page %d, %g to %g)", p.label(), region.Page, region.Top, region.Bottom)
+		}
+	}
+
+	for currency, headings := range p.CurrencySections {
+		if !currencyCode.MatchString(currency) {
+			return fmt.Errorf("%s has currency section %q, expected a code like USD", p.label(), currency)
+		}
+		if len(headings) == 0 {
+			return fmt.Errorf("%s has no headings for currency section %s", p.label(), currency)
+		}
+	}
+
+	return nil
+}
+
+func (p Profile) label() string {
+	if p.Name != "" {
+		return fmt.Sprintf("profile %q", p.Name)
+	}
+	return "profile"
+}
@@ -2,14 +2,22 @@ package parser
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"arian-statement-parser/internal/domain"
 )
 
+// ErrParserUnavailable is returned when the Python parser couldn't be started or was killed, which
+// says nothing about the statement it was given
+var ErrParserUnavailable = errors.New("python parser unavailable")
+
 type PythonTransaction struct {
 	Date          string  `json:"date"`
 	Amount        float64 `json:"amount"`
@@ -22,12 +30,72 @@ type PythonTransaction struct {
 	AccountType   string  `json:"account_type"`
 	AccountName   string  `json:"account_name"`
 	SourceFile    string  `json:"source_file"`
+	Pending       bool    `json:"pending,omitempty"`
+	// Confidence is 0-1, left out by parsers (and rows) that have no doubts about a line
+	Confidence        *float64 `json:"confidence,omitempty"`
+	ConfidenceReasons []string `json:"confidence_reasons,omitempty"`
+	// Balance is the account balance after the line, left out until the statement prints one
+	Balance *float64 `json:"balance,omitempty"`
+	// Principal and Interest split a loan payment, only loan statements have them
+	Principal *float64 `json:"principal,omitempty"`
+	Interest  *float64 `json:"interest,omitempty"`
+	// Set by the CSV formats; the RBC parser leaves them empty, but for the securities it puts in
+	// the notes of investment statements
+	Currency string `json:"currency,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+	Bank     string `json:"bank,omitempty"`
+	// BankCategory is the bank's own category of the line, from exports that have one
+	BankCategory string `json:"bank_category,omitempty"`
+	// OriginalAmount and OriginalCurrency are the line in the currency it was converted from or to,
+	// for spending abroad and conversions between currencies
+	OriginalAmount   *float64 `json:"original_amount,omitempty"`
+	OriginalCurrency string   `json:"original_currency,omitempty"`
+	// Merchant and CategorySlug come from the exports of budgeting apps and ariand, which named and
+	// categorized the line already
+	Merchant     string `json:"merchant,omitempty"`
+	CategorySlug string `json:"category_slug,omitempty"`
+	// Page and Line say where the line is in the file: the page of a PDF and its place among the
+	// lines found there, the page and line of a text statement, or the row of a CSV
+	Page int `json:"page,omitempty"`
+	Line int `json:"line,omitempty"`
 }
 
 type FileResult struct {
 	File             string `json:"file"`
 	TransactionCount int    `json:"transaction_count"`
 	Processed        bool   `json:"processed"`
+	// Format is the kind of file, one of the Format constants, set by ParseAll
+	Format string `json:"format,omitempty"`
+	// Statement is what a card statement's summary says, nil for other statements
+	Statement *domain.Statement `json:"statement,omitempty"`
+	// Institution is the bank the Python parser took a PDF for, "rbc" or another it recognized,
+	// empty when it recognized none
+	Institution string `json:"institution,omitempty"`
+	// Text is the text of a PDF the RBC parser didn't read, laid out like pdftotext -layout, until
+	// the templates have had it
+	Text string `json:"text,omitempty"`
+	// SkippedLines are the lines of a statement that couldn't be read and were left out, see
+	// PythonParser.WithStrict
+	SkippedLines []SkippedLine `json:"skipped_lines,omitempty"`
+	// SummaryLines counts the lines dropped for only restating a balance or total, like "PREVIOUS
+	// BALANCE", which TransactionCount leaves out
+	SummaryLines int `json:"summary_lines,omitempty"`
+}
+
+// SkippedLine is a line of a statement left out because it couldn't be read
+type SkippedLine struct {
+	// Page is set for text read from a PDF, whose lines are counted from the top of their page
+	Page   int    `json:"page,omitempty"`
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// Where says where the line is, like "page 2 line 14"
+func (s SkippedLine) Where() string {
+	if s.Page > 0 {
+		return fmt.Sprintf("page %d line %d", s.Page, s.Line)
+	}
+	return fmt.Sprintf("line %d", s.Line)
 }
 
 type ParseResult struct {
@@ -38,47 +106,161 @@ type ParseResult struct {
 		ProcessedFiles    int `json:"processed_files"`
 		TotalTransactions int `json:"total_transactions"`
 	} `json:"summary"`
+	// Diffs lists statements that replaced a previously parsed version, only filled when caching
+	Diffs []FileDiff `json:"-"`
+	// Duplicates lists files that were skipped for having the same bytes as one read before them
+	Duplicates []DuplicateFile `json:"-"`
+
+	// seen are the cache entries of the statements read, for Cache.Remember
+	seen statementIndex
 }
 
 type PythonParser struct {
 	pythonPath string
 	scriptPath string
+	dir        string // the parser checkout, where uv runs
+	cache      *Cache
+	// templates read the PDFs of other banks, from the text the Python parser hands back
+	templates *TemplateParser
+	// institution is the bank every PDF is read as, detected per file when empty
+	institution string
+	// dumpDir is where the parser writes what it read from each PDF, for debugging
+	dumpDir string
+	// strict fails a statement on a line that can't be read instead of skipping it, see WithStrict
+	strict bool
+	// code is the hash of the parser's code, once worked out, see codeHash
+	code string
 }
 
 func NewPythonParser() *PythonParser {
 	return &PythonParser{
 		pythonPath: "uv",
 		scriptPath: "rbc-statement-parser/main.py",
+		dir:        "rbc-statement-parser",
 	}
 }
 
+// WithDir runs the parser from a checkout somewhere other than rbc-statement-parser in the working
+// directory
+func (p *PythonParser) WithDir(dir string) *PythonParser {
+	p.dir = dir
+	p.scriptPath = filepath.Join(dir, "main.py")
+	p.code = ""
+	return p
+}
+
+// WithCache enables per-file caching of parse results
+func (p *PythonParser) WithCache(cache *Cache) *PythonParser {
+	p.cache = cache
+	return p
+}
+
+// WithTemplates reads the PDFs the Python parser finds are from a bank other than RBC with
+// templates, by the text it hands back
+func (p *PythonParser) WithTemplates(templates *TemplateParser) *PythonParser {
+	p.templates = templates
+	return p
+}
+
+// WithInstitution reads every PDF as institution's statement rather than the bank detected on it:
+// "rbc" for the RBC parser, or a template's name or bank, see CheckInstitution
+func (p *PythonParser) WithInstitution(institution string) *PythonParser {
+	p.institution = institution
+	return p
+}
+
+// WithStrict fails a statement on the first line that matched but couldn't be read, like a date in
+// another layout, instead of leaving the line out and reporting it in FileResult.SkippedLines
+func (p *PythonParser) WithStrict() *PythonParser {
+	p.strict = true
+	return p
+}
+
+// WithDump has the parser write the text, HTML and text positions it reads from each PDF to dir.
+// Only PDFs it parses are written, so the cache is best left off.
+func (p *PythonParser) WithDump(dir string) *PythonParser {
+	p.dumpDir = dir
+	return p
+}
+
 func (p *PythonParser) ParseStatements(pdfPath string, configPath string) (*ParseResult, []*domain.Transaction, error) {
-	// Build command args with JSON format
-	// Only prepend ../ if the path is relative
-	pythonPdfPath := pdfPath
-	if !filepath.IsAbs(pdfPath) {
-		pythonPdfPath = "../" + pdfPath
+	if p.cache != nil {
+		result, err := p.parseWithCache(pdfPath, configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		// A statement read leniently before was cached with the lines it left out
+		if p.strict {
+			for _, file := range result.FileResults {
+				if len(file.SkippedLines) > 0 {
+					line := file.SkippedLines[0]
+					return nil, nil, fmt.Errorf("failed to parse %s: %s: %s", filepath.Base(file.File), line.Where(), line.Reason)
+				}
+			}
+		}
+
+		if err := p.readTexts(result); err != nil {
+			return nil, nil, err
+		}
+		transactions, err := toTransactions(result)
+		if err != nil {
+			return nil, nil, err
+		}
+		return result, transactions, nil
+	}
+
+	output, err := p.run(pdfPath, configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Parse JSON output
+	return p.parseJSONOutput(string(output))
+}
+
+// run executes the Python parser and returns its raw JSON output
+func (p *PythonParser) run(pdfPath string, configPath string) ([]byte, error) {
+	// Build command args with JSON format. The parser runs in its own directory, so relative paths
+	// are made absolute first.
+	pythonPdfPath, err := filepath.Abs(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", pdfPath, err)
 	}
 
 	args := []string{"run", "python", "main.py", pythonPdfPath, "--format", "json"}
 	if configPath != "" {
-		pythonConfigPath := configPath
-		if !filepath.IsAbs(configPath) {
-			pythonConfigPath = "../" + configPath
+		pythonConfigPath, err := filepath.Abs(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", configPath, err)
 		}
 		args = append(args, "--config", pythonConfigPath)
 	}
+	if p.institution != "" {
+		args = append(args, "--institution", p.institution)
+	}
+	if p.strict {
+		args = append(args, "--strict")
+	}
+	if p.dumpDir != "" {
+		dumpDir, err := filepath.Abs(p.dumpDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", p.dumpDir, err)
+		}
+		args = append(args, "--dump", dumpDir)
+	}
 
-	// Execute Python script with uv from the rbc-statement-parser directory
+	// Execute Python script with uv from the parser directory
 	cmd := exec.Command(p.pythonPath, args...)
-	cmd.Dir = "rbc-statement-parser" // Set working directory
+	cmd.Dir = p.dir
 	output, err := cmd.CombinedOutput()
+	if exitErr := (*exec.ExitError)(nil); err != nil && (!errors.As(err, &exitErr) || exitErr.ExitCode() < 0) {
+		return nil, fmt.Errorf("%w: %w\nOutput: %s", ErrParserUnavailable, err, string(output))
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to execute Python parser: %w\nOutput: %s", err, string(output))
+		return nil, fmt.Errorf("failed to execute Python parser: %w\nOutput: %s", err, string(output))
 	}
 
-	// Parse JSON output
-	return p.parseJSONOutput(string(output))
+	return output, nil
 }
 
 func (p *PythonParser) parseJSONOutput(output string) (*ParseResult, []*domain.Transaction, error) {
@@ -87,14 +269,74 @@ func (p *PythonParser) parseJSONOutput(output string) (*ParseResult, []*domain.T
 	if err := json.Unmarshal([]byte(output), &result); err != nil {
 		return nil, nil, fmt.Errorf("failed to parse JSON output: %w", err)
 	}
+	if err := p.readTexts(&result); err != nil {
+		return nil, nil, err
+	}
+
+	transactions, err := toTransactions(&result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &result, transactions, nil
+}
+
+// readTexts reads the text of the PDFs the RBC parser didn't with the templates, adding what they
+// find to result
+func (p *PythonParser) readTexts(result *ParseResult) error {
+	read := false
+	for i := range result.FileResults {
+		file := &result.FileResults[i]
+		text := file.Text
+		file.Text = ""
+		if text == "" || p.templates == nil || strings.EqualFold(p.institution, "rbc") {
+			continue
+		}
+
+		template := p.templates.match(text, p.institution)
+		if template == nil {
+			continue
+		}
+		rows, skipped, err := template.parse(text, file.File, p.strict)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s with template %s: %w", filepath.Base(file.File), template.Name, err)
+		}
+		file.SkippedLines = skipped
+		if len(rows) == 0 {
+			continue
+		}
 
+		result.Transactions = append(result.Transactions, rows...)
+		file.TransactionCount, file.Processed = len(rows), true
+		result.Summary.ProcessedFiles++
+		result.Summary.TotalTransactions += len(rows)
+		read = true
+	}
+
+	// Python sorts by date across all files, keep that contract with the lines the templates added
+	if read {
+		sort.SliceStable(result.Transactions, func(i, j int) bool {
+			return result.Transactions[i].Date < result.Transactions[j].Date
+		})
+	}
+	return nil
+}
+
+// toTransactions converts parser output into domain transactions
+func toTransactions(result *ParseResult) ([]*domain.Transaction, error) {
 	var transactions []*domain.Transaction
 
 	for _, pt := range result.Transactions {
 		// Parse date
 		txDate, err := time.Parse("2006-01-02T15:04:05", pt.Date)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to parse date %s: %w", pt.Date, err)
+			return nil, fmt.Errorf("failed to parse date %s: %w", pt.Date, err)
+		}
+		var postingDate time.Time
+		if pt.PostingDate != "" {
+			if postingDate, err = time.Parse("2006-01-02T15:04:05", pt.PostingDate); err != nil {
+				return nil, fmt.Errorf("failed to parse posting date %s: %w", pt.PostingDate, err)
+			}
 		}
 
 		// Determine direction and make amount positive
@@ -107,20 +349,67 @@ func (p *PythonParser) parseJSONOutput(output string) (*ParseResult, []*domain.T
 			direction = domain.In
 		}
 
+		var code string
+		if pt.Code != nil {
+			code = *pt.Code
+		}
+
+		currency := pt.Currency
+		if currency == "" {
+			currency = "CAD" // RBC only names the currency in US dollar sections
+		}
+
+		bank := pt.Bank
+		if bank == "" {
+			bank = "RBC"
+		}
+
+		confidence := 1.0
+		if pt.Confidence != nil {
+			confidence = *pt.Confidence
+		}
+
+		var original *domain.Money
+		if pt.OriginalAmount != nil && pt.OriginalCurrency != "" {
+			original = &domain.Money{Amount: math.Abs(*pt.OriginalAmount), Currency: strings.ToUpper(pt.OriginalCurrency)}
+		}
+
+		var loan *domain.LoanSplit
+		if pt.Principal != nil && pt.Interest != nil {
+			loan = &domain.LoanSplit{Principal: *pt.Principal, Interest: *pt.Interest}
+		}
+
 		tx := &domain.Transaction{
 			TxDate:                 txDate,
+			PostingDate:            postingDate,
 			TxAmount:               amount,
-			TxCurrency:             "CAD", // Default to CAD for RBC statements
+			TxCurrency:             currency,
 			TxDirection:            direction,
-			TxDesc:                 pt.Description,
+			TxDesc:                 normalizeText(pt.Description),
+			Merchant:               normalizeText(pt.Merchant),
+			UserNotes:              pt.Notes,
+			Kind:                   classify(pt.AccountType, pt.Amount, pt.Description),
+			Pending:                pt.Pending,
+			Balance:                pt.Balance,
+			Loan:                   loan,
+			BankCategory:           pt.BankCategory,
+			Category:               pt.CategorySlug,
+			Original:               original,
+			Confidence:             confidence,
+			ConfidenceReasons:      pt.ConfidenceReasons,
+			ReferenceCode:          code,
+			Method:                 inferMethod(pt.Method, pt.AccountType, pt.Description),
 			StatementAccountNumber: pt.AccountNumber,
 			StatementAccountType:   pt.AccountType,
 			StatementAccountName:   pt.AccountName,
+			StatementBank:          bank,
 			SourceFilePath:         pt.SourceFile,
+			SourcePage:             pt.Page,
+			SourceLine:             pt.Line,
 		}
 
 		transactions = append(transactions, tx)
 	}
 
-	return &result, transactions, nil
+	return transactions, nil
 }
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitwiseColumns are the columns of a Splitwise export that aren't a member of the group
+var splitwiseColumns = map[string]bool{
+	"date":        true,
+	"description": true,
+	"category":    true,
+	"cost":        true,
+	"currency":    true,
+}
+
+// splitwiseFormat reads a group or friend's export from Splitwise. Each member of the group has a
+// column with what each expense did to their balance: positive when they're owed, negative when
+// they owe.
+var splitwiseFormat = csvFormat{
+	name: "Splitwise",
+	detect: func(header []string) bool {
+		return len(header) > len(splitwiseColumns) && hasColumns(header, "date", "description", "category", "cost", "currency")
+	},
+	parse: parseSplitwise,
+}
+
+// parseSplitwise turns your column into lines of a Splitwise account, whose balance is what the
+// others owe you. A shared expense is your side of it, a settlement the money that went between
+// you, which the bank statement has too.
+func parseSplitwise(rows []csvRow, file string) ([]PythonTransaction, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	if rows[0].member == "" {
+		return nil, fmt.Errorf("set splitwise_name in the parser config to say which column is yours")
+	}
+	if _, ok := rows[0].columns[rows[0].member]; !ok || splitwiseColumns[rows[0].member] {
+		return nil, fmt.Errorf("no column for %q, the splitwise_name in the parser config", rows[0].member)
+	}
+
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		// The export ends with everyone's balance, which isn't a line
+		if strings.EqualFold(row.get("description"), "total balance") {
+			continue
+		}
+		// Neither are expenses you weren't part of
+		if row.get(row.member) == "" {
+			continue
+		}
+		amount, err := row.amount(row.member)
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		if amount == 0 {
+			continue
+		}
+		date, err := parseCSVDate(row.get("date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		cost, err := row.amount("cost")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		currency := strings.ToUpper(row.get("currency"))
+
+		tx := PythonTransaction{
+			Date:        date,
+			Amount:      amount,
+			Description: row.get("description"),
+			AccountType: "other",
+			AccountName: "Splitwise",
+			SourceFile:  file,
+			Line:        row.line,
+			Currency:    currency,
+			Bank:        "Splitwise",
+		}
+		if category := row.get("category"); strings.EqualFold(category, "payment") {
+			// Settling up moves money to or from a bank account, like a card payment
+			tx.Method = "online"
+			tx.CategorySlug = "transfer"
+		} else {
+			tx.BankCategory = category
+			tx.Notes = fmt.Sprintf("shared: %.2f %s", cost, currency)
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Kinds of statement file, as FileResult.Format names them
+const (
+	FormatPDF    = "pdf"
+	FormatCSV    = "csv"
+	FormatOFX    = "ofx"
+	FormatText   = "text"
+	FormatBackup = "backup"
+)
+
+// FormatStats are how many files of one format a run read, and what they gave
+type FormatStats struct {
+	Format         string `json:"format"`
+	TotalFiles     int    `json:"total_files"`
+	ProcessedFiles int    `json:"processed_files"`
+	Transactions   int    `json:"transactions"`
+}
+
+// ParseAll parses PDF statements under path with the Python parser, CSV exports with the CSV
+// parser, OFX downloads with the OFX parser, text statements with the user's templates and ariand
+// backups with the backup parser, merging everything into one result. Without any other files the
+// Python parser runs alone, keeping its error for a folder with nothing to parse. The CSV and text
+// statements are read as strictly as pdfParser is, see PythonParser.WithStrict. Lines that only
+// restate a balance or total, like "PREVIOUS BALANCE", are dropped and counted in their file's
+// SummaryLines.
+func ParseAll(pdfParser *PythonParser, templates *TemplateParser, path, configPath string) (*ParseResult, []*domain.Transaction, error) {
+	var config *Config
+	if configPath != "" {
+		var err error
+		if config, err = LoadConfig(configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	csvFiles, err := listFiles(path, ".csv")
+	if err != nil {
+		return nil, nil, err
+	}
+	var ofxFiles []string
+	for _, ext := range ofxExts {
+		found, err := listFiles(path, ext)
+		if err != nil {
+			return nil, nil, err
+		}
+		ofxFiles = append(ofxFiles, found...)
+	}
+	var textFiles []string
+	if templates != nil && len(templates.Templates()) > 0 {
+		if textFiles, err = listFiles(path, ".txt"); err != nil {
+			return nil, nil, err
+		}
+	}
+	pdfFiles, err := listFiles(path, ".pdf")
+	if err != nil {
+		return nil, nil, err
+	}
+	backupFiles, err := listFiles(path, backupExt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &ParseResult{}
+	var transactions []*domain.Transaction
+
+	if len(pdfFiles) > 0 || (len(csvFiles) == 0 && len(ofxFiles) == 0 && len(textFiles) == 0 && len(backupFiles) == 0) {
+		pdfResult, pdfTransactions, err := pdfParser.ParseStatements(path, configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		setFormat(pdfResult, FormatPDF)
+		Merge(result, pdfResult)
+		transactions = append(transactions, pdfTransactions...)
+	}
+
+	if len(csvFiles) > 0 {
+		csvParser := NewCSVParser()
+		csvParser.strict = pdfParser.strict
+		csvResult, csvTransactions, err := csvParser.ParseStatements(path, configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		setFormat(csvResult, FormatCSV)
+		Merge(result, csvResult)
+		transactions = append(transactions, csvTransactions...)
+	}
+
+	if len(ofxFiles) > 0 {
+		ofxResult, ofxTransactions, err := NewOFXParser().ParseStatements(path, configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		setFormat(ofxResult, FormatOFX)
+		Merge(result, ofxResult)
+		transactions = append(transactions, ofxTransactions...)
+	}
+
+	if len(textFiles) > 0 {
+		textResult, textTransactions, err := templates.parseStatements(path, pdfParser.strict)
+		if err != nil {
+			return nil, nil, err
+		}
+		setFormat(textResult, FormatText)
+		Merge(result, textResult)
+		transactions = append(transactions, textTransactions...)
+	}
+
+	if len(backupFiles) > 0 {
+		backupResult, backupTransactions, err := NewBackupParser().ParseStatements(path, configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		setFormat(backupResult, FormatBackup)
+		Merge(result, backupResult)
+		transactions = append(transactions, backupTransactions...)
+	}
+
+	transactions, err = dropDuplicates(result, transactions)
+	if err != nil {
+		return nil, nil, err
+	}
+	transactions, err = dropSummaryLines(result, transactions, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, transactions, nil
+}
+
+// DuplicateFile is a statement file with the same bytes as one read before it, e.g. a download
+// saved twice as statement.pdf and statement (1).pdf
+type DuplicateFile struct {
+	File     string
+	Original string
+}
+
+// findDuplicates returns the files with the same bytes as an earlier one in files
+func findDuplicates(files []string) ([]DuplicateFile, error) {
+	seen := make(map[[sha256.Size]byte]string, len(files))
+	var duplicates []DuplicateFile
+	for _, file := range files {
+		h := sha256.New()
+		if err := hashFile(h, file); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		sum := [sha256.Size]byte(h.Sum(nil))
+		if original, ok := seen[sum]; ok {
+			duplicates = append(duplicates, DuplicateFile{File: file, Original: original})
+			continue
+		}
+		seen[sum] = file
+	}
+	return duplicates, nil
+}
+
+// dropDuplicates takes the files of result that repeat an earlier one, and their lines, out of
+// result, and lists them in its Duplicates. Parsers that read a whole folder at once have read
+// them by then, ParseEach skips them before parsing.
+func dropDuplicates(result *ParseResult, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+	if len(result.FileResults) < 2 {
+		return transactions, nil
+	}
+	files := make([]string, 0, len(result.FileResults))
+	for _, file := range result.FileResults {
+		files = append(files, file.File)
+	}
+	duplicates, err := findDuplicates(files)
+	if err != nil || len(duplicates) == 0 {
+		return transactions, err
+	}
+
+	dropped := make(map[string]bool, len(duplicates))
+	for _, duplicate := range duplicates {
+		dropped[duplicate.File] = true
+	}
+	result.FileResults = slices.DeleteFunc(result.FileResults, func(file FileResult) bool {
+		if !dropped[file.File] {
+			return false
+		}
+		result.Summary.TotalFiles--
+		result.Summary.TotalTransactions -= file.TransactionCount
+		if file.Processed {
+			result.Summary.ProcessedFiles--
+		}
+		return true
+	})
+	result.Transactions = slices.DeleteFunc(result.Transactions, func(tx PythonTransaction) bool { return dropped[tx.SourceFile] })
+	result.Duplicates = append(result.Duplicates, duplicates...)
+	return slices.DeleteFunc(transactions, func(tx *domain.Transaction) bool { return dropped[tx.SourceFilePath] }), nil
+}
+
+// SinglePeriod reports whether the file is one statement of one period, a PDF or text statement,
+// rather than an export, migration or backup that can span years
+func (f FileResult) SinglePeriod() bool {
+	return f.Format == FormatPDF || f.Format == FormatText
+}
+
+// setFormat marks every file of result as format
+func setFormat(result *ParseResult, format string) {
+	for i := range result.FileResults {
+		result.FileResults[i].Format = format
+	}
+}
+
+// Formats tallies the files of result by format, in the order ParseAll reads them. Formats without
+// files are left out.
+func (r *ParseResult) Formats() []FormatStats {
+	var stats []FormatStats
+	for _, format := range []string{FormatPDF, FormatCSV, FormatOFX, FormatText, FormatBackup} {
+		tally := FormatStats{Format: format}
+		for _, file := range r.FileResults {
+			if file.Format != format {
+				continue
+			}
+			tally.TotalFiles++
+			tally.Transactions += file.TransactionCount
+			if file.Processed {
+				tally.ProcessedFiles++
+			}
+		}
+		if tally.TotalFiles > 0 {
+			stats = append(stats, tally)
+		}
+	}
+	return stats
+}
+
+// Merge adds src's files, rows and counts to dst
+func Merge(dst, src *ParseResult) {
+	dst.Transactions = append(dst.Transactions, src.Transactions...)
+	dst.FileResults = append(dst.FileResults, src.FileResults...)
+	dst.Diffs = append(dst.Diffs, src.Diffs...)
+	dst.Duplicates = append(dst.Duplicates, src.Duplicates...)
+	if len(src.seen) > 0 {
+		if dst.seen == nil {
+			dst.seen = make(statementIndex)
+		}
+		maps.Copy(dst.seen, src.seen)
+	}
+	dst.Summary.TotalFiles += src.Summary.TotalFiles
+	dst.Summary.ProcessedFiles += src.Summary.ProcessedFiles
+	dst.Summary.TotalTransactions += src.Summary.TotalTransactions
+}
+
+// ParseEach parses the statements under path one file at a time, in the order ParseAll reads them,
+// handing each file's result to fn before reading the next. Only one file's parser output is held at
+// once, which keeps imports of decades of statements small; without the cache it costs a Python
+// start per PDF. Files with the same bytes as one before them are skipped, each handed to fn as a
+// result of its own that only lists it in Duplicates.
+//
+// A file the parsers fail on ends the run with the error, unless failed is given: it gets the file
+// and the error instead, and the files after it are still read when it returns nil. Errors of a
+// parser that couldn't run at all, ErrParserUnavailable, always end the run.
+func ParseEach(pdfParser *PythonParser, templates *TemplateParser, path, configPath string, fn func(*ParseResult, []*domain.Transaction) error, failed func(file string, err error) error) error {
+	var files []string
+	for _, ext := range []string{".pdf", ".csv", ".ofx", ".qfx", ".txt", backupExt} {
+		if ext == ".txt" && (templates == nil || len(templates.Templates()) == 0) {
+			continue
+		}
+		found, err := listFiles(path, ext)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+
+	// A single file, or a folder with nothing to parse, gets ParseAll's result or error
+	if len(files) <= 1 {
+		result, transactions, err := ParseAll(pdfParser, templates, path, configPath)
+		if err != nil && len(files) == 1 && failed != nil && !errors.Is(err, ErrParserUnavailable) {
+			return failed(files[0], err)
+		}
+		if err != nil {
+			return err
+		}
+		return fn(result, transactions)
+	}
+
+	duplicates, err := findDuplicates(files)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if i := slices.IndexFunc(duplicates, func(d DuplicateFile) bool { return d.File == file }); i >= 0 {
+			if err := fn(&ParseResult{Duplicates: duplicates[i : i+1]}, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		result, transactions, err := ParseAll(pdfParser, templates, file, configPath)
+		if err != nil && failed != nil && !errors.Is(err, ErrParserUnavailable) {
+			if err := failed(file, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(result, transactions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestParseEach(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"newton.csv", "wealthsimple-cash.csv"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "csv", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, all, err := ParseAll(NewPythonParser(), nil, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls, total := 0, 0
+	err = ParseEach(NewPythonParser(), nil, dir, "", func(result *ParseResult, transactions []*domain.Transaction) error {
+		calls++
+		total += len(transactions)
+		if len(result.FileResults) != 1 {
+			t.Fatalf("got %d files in one call, want 1", len(result.FileResults))
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || total != len(all) {
+		t.Fatalf("ParseEach made %d calls with %d transactions, ParseAll read %d", calls, total, len(all))
+	}
+}
+
+func TestParseAllMixedFormats(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"csv/monzo.csv", "csv/newton.csv", "ofx/td-chequing.ofx", "ofx/card.qfx", "template/chase-card.txt"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(name)), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, transactions, err := ParseAll(NewPythonParser(), templates, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Summary.TotalFiles != 5 || result.Summary.ProcessedFiles != 5 || len(transactions) != result.Summary.TotalTransactions {
+		t.Fatalf("summary = %+v with %d transactions", result.Summary, len(transactions))
+	}
+
+	formats := result.Formats()
+	if len(formats) != 3 {
+		t.Fatalf("formats = %+v, want csv, ofx and text", formats)
+	}
+	for i, want := range []FormatStats{
+		{Format: FormatCSV, TotalFiles: 2, ProcessedFiles: 2},
+		{Format: FormatOFX, TotalFiles: 2, ProcessedFiles: 2, Transactions: 7},
+		{Format: FormatText, TotalFiles: 1, ProcessedFiles: 1, Transactions: 4},
+	} {
+		got := formats[i]
+		if got.Format != want.Format || got.TotalFiles != want.TotalFiles || got.ProcessedFiles != want.ProcessedFiles ||
+			(want.Transactions != 0 && got.Transactions != want.Transactions) {
+			t.Errorf("formats[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestDuplicateFiles(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", "csv", "newton.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"newton.csv", "newton (1).csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, once, err := ParseAll(NewPythonParser(), nil, filepath.Join(dir, "newton.csv"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, all, err := ParseAll(NewPythonParser(), nil, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(once) || len(result.Duplicates) != 1 || result.Summary.TotalFiles != 1 {
+		t.Fatalf("ParseAll read %d transactions from %d files with %d duplicates, want %d from 1 with 1",
+			len(all), result.Summary.TotalFiles, len(result.Duplicates), len(once))
+	}
+
+	merged := &ParseResult{}
+	total := 0
+	err = ParseEach(NewPythonParser(), nil, dir, "", func(result *ParseResult, transactions []*domain.Transaction) error {
+		Merge(merged, result)
+		total += len(transactions)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(once) || len(merged.Duplicates) != 1 || filepath.Base(merged.Duplicates[0].File) != "newton.csv" {
+		t.Fatalf("ParseEach read %d transactions with duplicates %+v, want %d and newton.csv", total, merged.Duplicates, len(once))
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", "csv", "newton.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, "someday,DEPOSIT,50,CAD,,,,,\n"...)
+	file := filepath.Join(dir, "newton.csv")
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, _, err := ParseAll(NewPythonParser(), nil, file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := result.FileResults[0].SkippedLines
+	if len(skipped) != 1 || skipped[0].Line != 6 || result.FileResults[0].TransactionCount == 0 {
+		t.Fatalf("lenient parse skipped %+v and read %d lines, want line 6 skipped and the rest read", skipped, result.FileResults[0].TransactionCount)
+	}
+
+	if _, _, err := ParseAll(NewPythonParser().WithStrict(), nil, file, ""); err == nil {
+		t.Fatal("strict parse read a file with an invalid date")
+	}
+}
+
+func TestSummaryLines(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "chase.csv")
+	data := "Transaction Date,Post Date,Description,Category,Type,Amount,Memo\n" +
+		"01/01/2024,01/01/2024,PREVIOUS BALANCE,,Adjustment,-120.00,\n" +
+		"01/03/2024,01/04/2024,NEW BALANCE ATHLETICS,Shopping,Sale,-89.99,\n" +
+		"01/04/2024,01/04/2024,NEW BALANCE #0421,Shopping,Sale,-64.50,\n" +
+		"01/05/2024,01/05/2024,Solde reporté,,Adjustment,-120.00,\n" +
+		"01/31/2024,01/31/2024,\"NEW BALANCE $1,200.00\",,Adjustment,-1200.00,\n" +
+		"01/31/2024,01/31/2024,SUBTOTAL PURCHASES,,Adjustment,-89.99,\n"
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(config, []byte(`{"summary_lines": ["subtotal"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, transactions, err := ParseAll(NewPythonParser(), nil, file, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 2 || transactions[0].TxDesc != "NEW BALANCE ATHLETICS" || transactions[1].TxDesc != "NEW BALANCE #0421" {
+		t.Fatalf("kept %d lines, want only the two New Balance purchases", len(transactions))
+	}
+	if got := result.FileResults[0]; got.SummaryLines != 4 || got.TransactionCount != 2 || len(result.Transactions) != 2 {
+		t.Fatalf("file counts %d dropped and %d read, want 4 and 2", got.SummaryLines, got.TransactionCount)
+	}
+
+	balances := filepath.Join(dir, "balances.csv")
+	data = "Transaction Date,Post Date,Description,Category,Type,Amount,Memo\n" +
+		"01/01/2024,01/01/2024,PREVIOUS BALANCE,,Adjustment,-120.00,\n"
+	if err := os.WriteFile(balances, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	result, _, err = ParseAll(NewPythonParser(), nil, balances, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FileResults[0].Processed || result.Summary.ProcessedFiles != 0 {
+		t.Fatal("a file of balance lines only counts as processed")
+	}
+
+	if err := os.WriteFile(config, []byte(`{"summary_lines": ["("]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(config); err == nil {
+		t.Fatal("LoadConfig accepted an invalid summary line pattern")
+	}
+}
+
+func TestWindows1252CSV(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chase.csv")
+	data := "Transaction Date,Post Date,Description,Category,Type,Amount,Memo\n" +
+		"01/03/2024,01/04/2024,CAF\xc9 D\xc9P\xd4T MONTR\xc9AL,Food & Drink,Sale,-4.50,\n"
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, transactions, err := ParseAll(NewPythonParser(), nil, file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 1 {
+		t.Fatalf("read %d lines, want 1", len(transactions))
+	}
+	if got := transactions[0].TxDesc; got != "CAFÉ DÉPÔT MONTRÉAL" {
+		t.Fatalf("description = %q, want CAFÉ DÉPÔT MONTRÉAL", got)
+	}
+}
+
+func TestInvertedCSV(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "chase.csv")
+	data := "Transaction Date,Post Date,Description,Category,Type,Amount,Memo\n" +
+		"01/03/2024,01/04/2024,GROCERY STORE,Groceries,Sale,23.45,\n"
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(config, []byte(`{"signs": {"chase": "inverted"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, transactions, err := ParseAll(NewPythonParser(), nil, file, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 1 || transactions[0].TxDirection != domain.Out || transactions[0].Kind != domain.KindPurchase {
+		t.Fatalf("read %+v, want one purchase", transactions)
+	}
+
+	if err := os.WriteFile(config, []byte(`{"signs": {"Chase": "columns"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(config); err == nil {
+		t.Fatal("columns was accepted for a CSV export")
+	}
+}
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"strings"
+)
+
+// stripeFormat reads Stripe's itemized balance transactions report (Reports > Balance, or the
+// payout reconciliation report) as well as the older Balance > Export CSV
+var stripeFormat = csvFormat{
+	name: "Stripe",
+	detect: func(header []string) bool {
+		return hasColumns(header, "balance_transaction_id", "reporting_category", "gross", "fee", "net") ||
+			hasColumns(header, "id", "type", "amount", "fee", "net", "currency", "created (utc)")
+	},
+	parse: parseStripe,
+}
+
+func parseStripe(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		date, err := parseCSVDate(row.first("created_utc", "created (utc)", "created"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		gross, err := row.amount("gross", "amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		fee, err := row.amount("fee")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+
+		id := row.first("balance_transaction_id", "id")
+		category := strings.ToLower(row.first("reporting_category", "type"))
+		source := row.first("source_id", "source")
+		payout := row.first("automatic_payout_id", "transfer")
+
+		tx := PythonTransaction{
+			Date:        date,
+			Amount:      gross,
+			Category:    category,
+			Description: row.get("description"),
+			AccountType: "other",
+			AccountName: "Stripe",
+			SourceFile:  file,
+			Line:        row.line,
+			Currency:    strings.ToUpper(row.get("currency")),
+			Bank:        "Stripe",
+		}
+		if id != "" {
+			tx.Code = &id
+		}
+
+		switch category {
+		case "payout", "transfer":
+			// The matching deposit shows up on the bank statement, the payout ID ties the two together
+			tx.Description = "Stripe payout"
+			tx.Method = "online"
+			if source != "" {
+				tx.Code = &source
+			}
+		case "fee", "stripe_fee", "tax":
+			tx.Method = "fee"
+		case "charge", "payment":
+			tx.Method = "card"
+		}
+
+		if tx.Description == "" {
+			tx.Description = "Stripe " + strings.ReplaceAll(category, "_", " ")
+		}
+		if payout != "" && category != "payout" && category != "transfer" {
+			tx.Notes = "payout: " + payout
+		}
+
+		if gross != 0 {
+			transactions = append(transactions, tx)
+		}
+
+		// Processing fees come out of each charge; a line of their own keeps income at the gross amount
+		if fee != 0 {
+			feeTx := tx
+			feeTx.Amount = -fee
+			feeTx.Method = "fee"
+			feeTx.Description = "Stripe fee: " + tx.Description
+			transactions = append(transactions, feeTx)
+		}
+	}
+
+	return transactions, nil
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// summaryLinePatterns match the descriptions of lines that restate a statement's balance or totals
+// rather than move money, in English and French. The PDF parser sometimes passes them on as
+// transactions.
+var summaryLinePatterns = []string{
+	`(previous|opening|closing|starting|ending|beginning) balance`,
+	// Only alone or with an amount, as New Balance is also a shoe store, "NEW BALANCE #0421"
+	`new balance:?\s*(-?\$?[\d,]+\.\d{2}\s*)?$`,
+	`balance (forward|brought forward|carried forward)`,
+	`(brought|carried) forward`,
+	`total (for|of) (the )?(period|statement)`,
+	`solde (pr[ée]c[ée]dent|d'ouverture|de cl[ôo]ture|report[ée]|ant[ée]rieur)`,
+	`nouveau solde`,
+	`total (de|pour) la p[ée]riode`,
+}
+
+var summaryLines = mustCompileSummaryLines(summaryLinePatterns)
+
+// compileSummaryLines matches each pattern from the start of a description to the end of a word,
+// so a merchant that only mentions a balance further in is kept. Go's \b only knows ASCII letters,
+// which would stop "reporté" from ending a word.
+func compileSummaryLines(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(`(?i)^\s*(?:` + pattern + `)(?:$|[^\pL\pN])`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid summary line %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func mustCompileSummaryLines(patterns []string) []*regexp.Regexp {
+	compiled, err := compileSummaryLines(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+// isSummaryLine reports whether description is a balance or total line rather than a transaction
+func isSummaryLine(description string, extra []*regexp.Regexp) bool {
+	description = normalizeText(description)
+	for _, re := range slices.Concat(summaryLines, extra) {
+		if re.MatchString(description) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropSummaryLines takes the lines of result that only restate a balance or total out of result
+// and transactions, counting them in each file's SummaryLines. Backups hold what ariand had, which
+// was checked when it was imported, and are left alone.
+func dropSummaryLines(result *ParseResult, transactions []*domain.Transaction, config *Config) ([]*domain.Transaction, error) {
+	var extra []*regexp.Regexp
+	if config != nil {
+		var err error
+		if extra, err = compileSummaryLines(config.SummaryLines); err != nil {
+			return nil, err
+		}
+	}
+
+	backups := make(map[string]bool)
+	for _, file := range result.FileResults {
+		if file.Format == FormatBackup {
+			backups[file.File] = true
+		}
+	}
+
+	dropped := make(map[string]int)
+	result.Transactions = slices.DeleteFunc(result.Transactions, func(tx PythonTransaction) bool {
+		return !backups[tx.SourceFile] && isSummaryLine(tx.Description, extra)
+	})
+	transactions = slices.DeleteFunc(transactions, func(tx *domain.Transaction) bool {
+		if backups[tx.SourceFilePath] || !isSummaryLine(tx.TxDesc, extra) {
+			return false
+		}
+		dropped[tx.SourceFilePath]++
+		return true
+	})
+
+	for i, file := range result.FileResults {
+		n := dropped[file.File]
+		if n == 0 {
+			continue
+		}
+		result.FileResults[i].SummaryLines = n
+		result.FileResults[i].TransactionCount -= n
+		result.Summary.TotalTransactions -= n
+		// A file of balance lines only had no transactions to read after all
+		if result.FileResults[i].TransactionCount == 0 && file.Processed {
+			result.FileResults[i].Processed = false
+			result.Summary.ProcessedFiles--
+		}
+	}
+	return transactions, nil
+}
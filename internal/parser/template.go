@@ -0,0 +1,461 @@
+package parser
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sign conventions a template can declare for its amount column
+const (
+	SignAsIs     = "as-is"    // negative amounts are money out, like a bank account
+	SignInverted = "inverted" // positive amounts are money out, like most credit card statements
+	SignColumns  = "columns"  // separate debit and credit groups, whichever is filled decides
+)
+
+// Template describes a plain text statement layout for banks without a dedicated parser
+type Template struct {
+	Name string `yaml:"name"`
+	// Detect must match somewhere in the text for the template to be used on a file
+	Detect        string `yaml:"detect"`
+	AccountType   string `yaml:"account_type"`
+	AccountName   string `yaml:"account_name"`
+	AccountNumber string `yaml:"account_number"` // regex, first group is the number
+	Bank          string `yaml:"bank"`
+	Currency      string `yaml:"currency"`
+	DateLayout    string `yaml:"date_layout"`
+	Year          string `yaml:"year"` // regex for layouts without a year, first group is the year
+	Sign          string `yaml:"sign"`
+	NumberFormat  string `yaml:"number_format"` // e.g. "1.234,56", worked out from the statement when empty
+	// Lines are tried in order on every line of text, with named groups date, description and
+	// amount (or debit and credit)
+	Lines []string `yaml:"lines"`
+
+	file          string
+	detect        *regexp.Regexp
+	accountNumber *regexp.Regexp
+	year          *regexp.Regexp
+	lines         []*regexp.Regexp
+}
+
+// compile checks the template and prepares its patterns
+func (t *Template) compile() error {
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(t.file), filepath.Ext(t.file))
+	}
+	if t.Detect == "" || len(t.Lines) == 0 || t.DateLayout == "" {
+		return fmt.Errorf("template %s needs detect, date_layout and lines", t.Name)
+	}
+	if t.AccountType == "" {
+		t.AccountType = "chequing"
+	}
+	if t.AccountName == "" {
+		t.AccountName = t.Name
+	}
+	if t.Bank == "" {
+		t.Bank = t.Name
+	}
+	if t.Sign == "" {
+		t.Sign = SignAsIs
+	}
+	if t.Sign != SignAsIs && t.Sign != SignInverted && t.Sign != SignColumns {
+		return fmt.Errorf("template %s has unknown sign %q, expected %s, %s or %s", t.Name, t.Sign, SignAsIs, SignInverted, SignColumns)
+	}
+
+	if err := checkNumberFormat(t.NumberFormat); err != nil {
+		return fmt.Errorf("template %s: %w", t.Name, err)
+	}
+
+	var err error
+	if t.detect, err = regexp.Compile(t.Detect); err != nil {
+		return fmt.Errorf("template %s has an invalid detect pattern: %w", t.Name, err)
+	}
+	if t.AccountNumber != "" {
+		if t.accountNumber, err = regexp.Compile(t.AccountNumber); err != nil {
+			return fmt.Errorf("template %s has an invalid account_number pattern: %w", t.Name, err)
+		}
+	}
+	if t.Year != "" {
+		if t.year, err = regexp.Compile(t.Year); err != nil {
+			return fmt.Errorf("template %s has an invalid year pattern: %w", t.Name, err)
+		}
+	}
+
+	for i, line := range t.Lines {
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return fmt.Errorf("template %s line pattern %d is invalid: %w", t.Name, i+1, err)
+		}
+
+		groups := make(map[string]bool)
+		for _, name := range re.SubexpNames() {
+			groups[name] = true
+		}
+		hasAmount := groups["amount"] || (t.Sign == SignColumns && (groups["debit"] || groups["credit"]))
+		if !groups["date"] || !groups["description"] || !hasAmount {
+			return fmt.Errorf("template %s line pattern %d needs named groups date, description and amount (or debit/credit)", t.Name, i+1)
+		}
+		t.lines = append(t.lines, re)
+	}
+
+	return nil
+}
+
+// parse extracts transactions from a statement's text. A matched line that can't be read fails the
+// statement when strict, else it is left out and returned as skipped.
+func (t *Template) parse(text, file string, strict bool) ([]PythonTransaction, []SkippedLine, error) {
+	var accountNumber *string
+	if t.accountNumber != nil {
+		if m := t.accountNumber.FindStringSubmatch(text); len(m) > 1 {
+			number := strings.TrimSpace(m[1])
+			accountNumber = &number
+		}
+	}
+
+	year := ""
+	if t.year != nil {
+		if m := t.year.FindStringSubmatch(text); len(m) > 1 {
+			year = m[1]
+			// US statements print the period as 12/14/23 - 01/13/24
+			if len(year) == 2 {
+				year = "20" + year
+			}
+		}
+	}
+
+	// A form feed starts each page of pdftotext's output, lines are counted from the top of theirs
+	paged := strings.Contains(text, "\f")
+	page, top := 1, 0
+
+	// Lines are matched first, so the number format can be worked out from all their amounts
+	type match struct {
+		page, line int
+		groups     map[string]string
+	}
+	var matches []match
+	var amounts []string
+	for n, line := range strings.Split(text, "\n") {
+		for strings.HasPrefix(line, "\f") {
+			line = line[1:]
+			page++
+			top = n
+		}
+		line = strings.TrimRight(line, "\r ")
+		for _, re := range t.lines {
+			m := re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+
+			groups := make(map[string]string)
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					groups[name] = strings.TrimSpace(m[i])
+				}
+			}
+			amounts = append(amounts, groups["amount"], groups["debit"], groups["credit"])
+			matches = append(matches, match{page: page, line: n - top + 1, groups: groups})
+			break
+		}
+	}
+
+	numbers := t.NumberFormat
+	if numbers == NumberFormatAuto {
+		var err error
+		if numbers, err = detectNumberFormat(amounts); err != nil {
+			return nil, nil, fmt.Errorf("failed to read the amounts with template %q: %w, set its number_format", t.Name, err)
+		}
+	}
+
+	var transactions []PythonTransaction
+	var skipped []SkippedLine
+	for _, m := range matches {
+		groups := m.groups
+		date, err := t.parseDate(groups["date"], year)
+		var amount float64
+		if err == nil {
+			amount, err = t.amount(groups, numbers)
+		}
+		if err != nil {
+			bad := SkippedLine{Line: m.line, Reason: err.Error()}
+			if paged {
+				bad.Page = m.page
+			}
+			if strict {
+				return nil, nil, fmt.Errorf("%s: %w", bad.Where(), err)
+			}
+			skipped = append(skipped, bad)
+			continue
+		}
+
+		tx := PythonTransaction{
+			Date:          date,
+			Amount:        amount,
+			Description:   strings.Join(strings.Fields(groups["description"]), " "),
+			AccountNumber: accountNumber,
+			AccountType:   t.AccountType,
+			AccountName:   t.AccountName,
+			SourceFile:    file,
+			Line:          m.line,
+			Currency:      t.Currency,
+			Bank:          t.Bank,
+		}
+		if paged {
+			tx.Page = m.page
+		}
+
+		if t.Sign == SignColumns && groups["debit"] != "" && groups["credit"] != "" {
+			doubt(&tx, 0.5, "both debit and credit columns are filled")
+		}
+		if tx.Description == "" {
+			doubt(&tx, 0.5, "empty description")
+		}
+
+		transactions = append(transactions, tx)
+	}
+
+	if year != "" && !t.datesHaveYear() {
+		rollBackDecember(transactions)
+	}
+	return transactions, skipped, nil
+}
+
+// datesHaveYear reports whether the date layout reads the year from the line itself
+func (t *Template) datesHaveYear() bool {
+	return strings.Contains(t.DateLayout, "2006") || strings.Contains(t.DateLayout, "06")
+}
+
+// rollBackDecember moves the December lines of a statement that runs into January back a year. The
+// year pattern finds the closing year, which only the January lines share.
+func rollBackDecember(transactions []PythonTransaction) {
+	var december, january bool
+	for _, tx := range transactions {
+		if date, err := time.Parse(csvDateLayout, tx.Date); err == nil {
+			december = december || date.Month() == time.December
+			january = january || date.Month() == time.January
+		}
+	}
+	if !december || !january {
+		return
+	}
+	for i, tx := range transactions {
+		if date, err := time.Parse(csvDateLayout, tx.Date); err == nil && date.Month() == time.December {
+			transactions[i].Date = date.AddDate(-1, 0, 0).Format(csvDateLayout)
+		}
+	}
+}
+
+func (t *Template) parseDate(raw, year string) (string, error) {
+	layout, value := t.DateLayout, englishMonths(raw)
+	if year != "" && !t.datesHaveYear() {
+		layout += " 2006"
+		value += " " + year
+	}
+
+	date, err := time.Parse(layout, value)
+	if err != nil {
+		return "", fmt.Errorf("date %q does not match layout %q", raw, t.DateLayout)
+	}
+	return date.Format(csvDateLayout), nil
+}
+
+// amount applies the template's sign convention so money out is negative, reading numbers in the
+// statement's number format
+func (t *Template) amount(groups map[string]string, numbers string) (float64, error) {
+	if t.Sign == SignColumns && groups["amount"] == "" {
+		debit, err := parseAmount(groups["debit"], numbers)
+		if err != nil {
+			return 0, err
+		}
+		credit, err := parseAmount(groups["credit"], numbers)
+		if err != nil {
+			return 0, err
+		}
+		if debit < 0 {
+			debit = -debit
+		}
+		return credit - debit, nil
+	}
+
+	amount, err := parseAmount(groups["amount"], numbers)
+	if err != nil {
+		return 0, err
+	}
+	if t.Sign == SignInverted {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// TemplateParser reads plain text statements (for example pdftotext -layout output) using user templates
+type TemplateParser struct {
+	templates []*Template
+}
+
+// builtinTemplates read the text of US bank PDFs, after pdftotext -layout
+//
+//go:embed templates/*.yaml
+var builtinTemplates embed.FS
+
+// LoadTemplates reads every .yaml or .yml template in dir, followed by the built-in ones, so a
+// template of the user's wins over a built-in one that detects the same statement. A missing or
+// empty dir means only the built-in templates.
+func LoadTemplates(dir string) (*TemplateParser, error) {
+	p := &TemplateParser{}
+	if dir != "" {
+		if err := p.loadDir(os.DirFS(dir), ".", dir); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.loadDir(builtinTemplates, "templates", "built-in"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// loadDir adds the templates in dir of fsys, with label naming the place in errors
+func (p *TemplateParser) loadDir(fsys fs.FS, dir, label string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read template dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read template %s: %w", entry.Name(), err)
+		}
+
+		template := &Template{file: filepath.Join(label, entry.Name())}
+		if err := yaml.Unmarshal(data, template); err != nil {
+			return fmt.Errorf("failed to parse template %s: %w", entry.Name(), err)
+		}
+		if err := template.compile(); err != nil {
+			return err
+		}
+		p.templates = append(p.templates, template)
+	}
+	return nil
+}
+
+// Templates returns the loaded templates in file name order
+func (p *TemplateParser) Templates() []*Template {
+	return p.templates
+}
+
+// match finds the template for a statement's text: the first whose detect pattern matches it, among
+// those institution names by template or bank name when it isn't empty. A named template is used
+// even when its pattern doesn't match, since the user said which bank the statement is from.
+func (p *TemplateParser) match(text, institution string) *Template {
+	var named *Template
+	for _, template := range p.templates {
+		if institution != "" && !template.names(institution) {
+			continue
+		}
+		if template.detect.MatchString(text) {
+			return template
+		}
+		if named == nil && institution != "" {
+			named = template
+		}
+	}
+	return named
+}
+
+// names reports whether institution is the template's name or bank, ignoring case
+func (t *Template) names(institution string) bool {
+	return strings.EqualFold(t.Name, institution) || strings.EqualFold(t.Bank, institution)
+}
+
+// CheckInstitution makes sure every PDF can be read as institution's statement: "rbc", or the name
+// or bank of one of the templates
+func CheckInstitution(institution string, templates *TemplateParser) error {
+	if strings.EqualFold(institution, "rbc") {
+		return nil
+	}
+	var names []string
+	if templates != nil {
+		for _, template := range templates.templates {
+			if template.names(institution) {
+				return nil
+			}
+			names = append(names, template.Name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("unknown institution %q, expected rbc", institution)
+	}
+	return fmt.Errorf("unknown institution %q, expected rbc or a template: %s", institution, strings.Join(names, ", "))
+}
+
+// ParseStatements parses every .txt file under path with the first template whose detect pattern matches it
+func (p *TemplateParser) ParseStatements(path string, _ string) (*ParseResult, []*domain.Transaction, error) {
+	return p.parseStatements(path, false)
+}
+
+// parseStatements is ParseStatements, failing a file on a line it can't read when strict
+func (p *TemplateParser) parseStatements(path string, strict bool) (*ParseResult, []*domain.Transaction, error) {
+	files, err := listFiles(path, ".txt")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &ParseResult{}
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		text := decodeText(data)
+
+		var rows []PythonTransaction
+		var skipped []SkippedLine
+		if template := p.match(text, ""); template != nil {
+			if rows, skipped, err = template.parse(text, file, strict); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse %s with template %s: %w", filepath.Base(file), template.Name, err)
+			}
+		}
+
+		result.Transactions = append(result.Transactions, rows...)
+		result.FileResults = append(result.FileResults, FileResult{
+			File:             file,
+			TransactionCount: len(rows),
+			Processed:        len(rows) > 0,
+			SkippedLines:     skipped,
+		})
+		result.Summary.TotalFiles++
+		if len(rows) > 0 {
+			result.Summary.ProcessedFiles++
+		}
+	}
+	result.Summary.TotalTransactions = len(result.Transactions)
+
+	transactions, err := toTransactions(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, transactions, nil
+}
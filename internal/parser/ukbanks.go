@@ -0,0 +1,275 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// monzoFormat reads Monzo's CSV export, from the app or the web. Amounts are signed and in the
+// account's currency, with the amount in the currency spent beside them.
+var monzoFormat = csvFormat{
+	name: "Monzo",
+	detect: func(header []string) bool {
+		return hasColumns(header, "transaction id", "date", "time", "type", "name", "category", "amount", "currency", "local amount", "local currency")
+	},
+	parse: parseMonzo,
+}
+
+// monzoMethods maps Monzo's transaction types to methods, others are left to the description
+var monzoMethods = map[string]string{
+	"card payment":         "pos",
+	"faster payment":       "online",
+	"monzo-to-monzo":       "online",
+	"pot transfer":         "online",
+	"direct debit":         "pre-auth",
+	"bacs (direct credit)": "deposit",
+	"atm":                  "atm",
+}
+
+func parseMonzo(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		date, err := parseCSVDateIn(row.get("date"), dayFirstLayouts)
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount", "money out", "money in")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		currency := strings.ToUpper(row.get("currency"))
+
+		tx := PythonTransaction{
+			Date:         date,
+			Amount:       amount,
+			Method:       monzoMethods[strings.ToLower(row.get("type"))],
+			Description:  row.first("name", "description"),
+			AccountType:  "chequing",
+			AccountName:  "Monzo",
+			SourceFile:   file,
+			Line:         row.line,
+			Currency:     currency,
+			Bank:         "Monzo",
+			Notes:        row.get("notes and #tags"),
+			BankCategory: row.get("category"),
+		}
+		if id := row.get("transaction id"); id != "" {
+			tx.Code = &id
+		}
+
+		// Spending abroad keeps what was paid in the other currency
+		if local := strings.ToUpper(row.get("local currency")); local != "" && local != currency {
+			if localAmount, err := row.amount("local amount"); err == nil && localAmount != 0 {
+				tx.OriginalAmount, tx.OriginalCurrency = &localAmount, local
+			}
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// starlingFormat reads Starling's statement CSV. The amount and balance columns name the
+// account's currency, e.g. "Amount (GBP)".
+var starlingFormat = csvFormat{
+	name: "Starling",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "counter party", "reference", "type", "spending category") && currencyColumn(header, "amount") != ""
+	},
+	parse: parseStarling,
+}
+
+// starlingMethods maps Starling's transaction types to methods, others are left to the description
+var starlingMethods = map[string]string{
+	"contactless":      "pos",
+	"chip and pin":     "pos",
+	"online payment":   "pos",
+	"apple pay":        "pos",
+	"google pay":       "pos",
+	"faster payment":   "online",
+	"standing order":   "online",
+	"direct debit":     "pre-auth",
+	"deposit interest": "fee",
+	"atm":              "atm",
+	"cash withdrawal":  "atm",
+}
+
+func parseStarling(rows []csvRow, file string) ([]PythonTransaction, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	var header []string
+	for column := range rows[0].columns {
+		header = append(header, column)
+	}
+	currency := currencyColumn(header, "amount")
+
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		date, err := parseCSVDateIn(row.get("date"), dayFirstLayouts)
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount (" + strings.ToLower(currency) + ")")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+
+		tx := PythonTransaction{
+			Date:         date,
+			Amount:       amount,
+			Method:       starlingMethods[strings.ToLower(row.get("type"))],
+			Description:  row.first("counter party", "reference"),
+			AccountType:  "chequing",
+			AccountName:  "Starling",
+			SourceFile:   file,
+			Line:         row.line,
+			Currency:     currency,
+			Bank:         "Starling",
+			Notes:        row.get("notes"),
+			BankCategory: row.get("spending category"),
+		}
+		if reference := row.get("reference"); reference != "" && reference != tx.Description {
+			tx.Notes = joinLines(tx.Notes, "reference: "+reference)
+		}
+		if balance := row.get("balance (" + strings.ToLower(currency) + ")"); balance != "" {
+			if value, err := parseAmount(balance, row.numbers); err == nil {
+				tx.Balance = &value
+			}
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// revolutFormat reads Revolut's account statement CSV. One export covers every currency, and each
+// currency is its own pocket, so its own account.
+var revolutFormat = csvFormat{
+	name: "Revolut",
+	detect: func(header []string) bool {
+		return hasColumns(header, "type", "product", "started date", "completed date", "description", "amount", "fee", "currency", "state")
+	},
+	parse: parseRevolut,
+}
+
+// revolutMethods maps Revolut's transaction types to methods, others are left to the description
+var revolutMethods = map[string]string{
+	"card_payment": "pos",
+	"card_refund":  "pos",
+	"transfer":     "online",
+	"exchange":     "online",
+	"topup":        "deposit",
+	"atm":          "atm",
+	"fee":          "fee",
+	"interest":     "fee",
+}
+
+func parseRevolut(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	// An exchange is a row in each pocket, started at the same instant
+	exchanges := make(map[string][]int)
+	for _, row := range rows {
+		state := strings.ToLower(row.get("state"))
+		if state != "completed" && state != "pending" {
+			continue // reverted, declined and failed lines never moved money
+		}
+
+		date, err := parseCSVDate(row.first("started date", "completed date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		fee, err := row.amount("fee")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		currency := strings.ToUpper(row.get("currency"))
+
+		name := "Revolut " + currency
+		if product := row.get("product"); product != "" && !strings.EqualFold(product, "current") {
+			name = "Revolut " + product + " " + currency
+		}
+
+		tx := PythonTransaction{
+			Date:         date,
+			Amount:       amount,
+			Method:       revolutMethods[strings.ToLower(row.get("type"))],
+			Description:  row.get("description"),
+			AccountType:  "chequing",
+			AccountName:  name,
+			SourceFile:   file,
+			Line:         row.line,
+			Currency:     currency,
+			Bank:         "Revolut",
+			Pending:      state == "pending",
+			BankCategory: row.get("category"),
+		}
+		if strings.EqualFold(row.get("product"), "savings") {
+			tx.AccountType = "savings"
+		}
+		if balance := row.get("balance"); balance != "" && !tx.Pending {
+			if value, err := parseAmount(balance, row.numbers); err == nil {
+				tx.Balance = &value
+			}
+		}
+
+		// Fees come on top of the amount; a line of their own keeps the spending at what was paid,
+		// and notes the line it was charged on. The balance is after both, so it goes with the fee.
+		feeTx := tx
+		if fee != 0 {
+			feeTx.Amount = -math.Abs(fee)
+			feeTx.Method = "fee"
+			feeTx.Description = "Revolut fee: " + tx.Description
+			feeTx.Notes = fmt.Sprintf("fee for: %s %.2f %s", date[:len("2006-01-02")], amount, currency)
+			tx.Balance = nil
+		}
+
+		if amount != 0 {
+			if strings.EqualFold(row.get("type"), "exchange") {
+				started := row.get("started date")
+				exchanges[started] = append(exchanges[started], len(transactions))
+			}
+			transactions = append(transactions, tx)
+		}
+		if fee != 0 {
+			transactions = append(transactions, feeTx)
+		}
+	}
+
+	// Each side of an exchange keeps the other as its original amount
+	for _, pair := range exchanges {
+		if len(pair) != 2 {
+			continue
+		}
+		from, to := &transactions[pair[0]], &transactions[pair[1]]
+		if from.Currency == to.Currency || math.Signbit(from.Amount) == math.Signbit(to.Amount) {
+			continue
+		}
+		fromAmount, toAmount := from.Amount, to.Amount
+		from.OriginalAmount, from.OriginalCurrency = &toAmount, to.Currency
+		to.OriginalAmount, to.OriginalCurrency = &fromAmount, from.Currency
+	}
+	return transactions, nil
+}
+
+// currencyColumn finds the currency a column is labelled with, "GBP" for "amount (gbp)"
+func currencyColumn(header []string, column string) string {
+	prefix := column + " ("
+	for _, name := range header {
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ")") && len(name) == len(prefix)+4 {
+			return strings.ToUpper(name[len(prefix) : len(prefix)+3])
+		}
+	}
+	return ""
+}
+
+// joinLines adds line to notes on a line of its own
+func joinLines(notes, line string) string {
+	if notes == "" {
+		return line
+	}
+	return notes + "\n" + line
+}
@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// chaseFileNumber finds the last four digits Chase puts in its export names, e.g. Chase1234_Activity_20240115.CSV
+var chaseFileNumber = regexp.MustCompile(`(?i)^chase(\d{4})_`)
+
+// chaseFormat reads Chase's checking and credit card activity downloads. Checking exports have
+// Details and Posting Date, card exports Transaction Date and Post Date; both sign amounts like a
+// bank account, money out negative.
+var chaseFormat = csvFormat{
+	name: "Chase",
+	detect: func(header []string) bool {
+		return hasColumns(header, "details", "posting date", "description", "amount", "type") ||
+			hasColumns(header, "transaction date", "post date", "description", "category", "type", "amount")
+	},
+	parse: parseChase,
+}
+
+func parseChase(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var number *string
+	if m := chaseFileNumber.FindStringSubmatch(filepath.Base(file)); m != nil {
+		number = &m[1]
+	}
+
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		card := row.get("transaction date") != ""
+		date, err := parseCSVDate(row.first("transaction date", "posting date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		posted, err := parseCSVDate(row.first("post date", "posting date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+
+		tx := PythonTransaction{
+			Date:          date,
+			PostingDate:   posted,
+			Amount:        amount,
+			Description:   row.get("description"),
+			AccountNumber: number,
+			AccountType:   "chequing",
+			AccountName:   "Chase Checking",
+			SourceFile:    file,
+			Line:          row.line,
+			Currency:      "USD",
+			Bank:          "Chase",
+		}
+		if card {
+			tx.AccountType = "visa"
+			tx.AccountName = "Chase Card"
+		}
+		if balance := row.get("balance"); balance != "" {
+			if value, err := parseAmount(balance, row.numbers); err == nil {
+				tx.Balance = &value
+			}
+		}
+		if code := row.get("check or slip #"); code != "" {
+			tx.Code = &code
+			tx.Method = "cheque"
+		}
+		if strings.EqualFold(row.get("type"), "fee") {
+			tx.Method = "fee"
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// bofaFormat reads Bank of America downloads: checking and savings, which put a summary above the
+// header, and credit cards, whose payee column is the description
+var bofaFormat = csvFormat{
+	name: "Bank of America",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "description", "amount", "running bal.") ||
+			hasColumns(header, "posted date", "reference number", "payee", "amount")
+	},
+	parse: parseBofA,
+}
+
+func parseBofA(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		// The opening balance is printed as a row without an amount
+		if row.get("amount") == "" {
+			continue
+		}
+		card := row.get("payee") != ""
+		date, err := parseCSVDate(row.first("posted date", "date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+
+		tx := PythonTransaction{
+			Date:        date,
+			Amount:      amount,
+			Description: row.first("payee", "description"),
+			AccountType: "chequing",
+			AccountName: "Bank of America Checking",
+			SourceFile:  file,
+			Line:        row.line,
+			Currency:    "USD",
+			Bank:        "Bank of America",
+		}
+		if card {
+			tx.AccountType = "visa"
+			tx.AccountName = "Bank of America Card"
+		}
+		if balance := row.get("running bal."); balance != "" {
+			if value, err := parseAmount(balance, row.numbers); err == nil {
+				tx.Balance = &value
+			}
+		}
+		if code := row.get("reference number"); code != "" {
+			tx.Code = &code
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// capitalOneFormat reads Capital One credit card downloads, with separate debit and credit
+// columns, and Capital One 360 bank downloads, whose amounts are unsigned with a type beside them
+var capitalOneFormat = csvFormat{
+	name: "Capital One",
+	detect: func(header []string) bool {
+		return hasColumns(header, "transaction date", "posted date", "card no.", "description", "debit", "credit") ||
+			hasColumns(header, "account number", "transaction description", "transaction date", "transaction type", "transaction amount")
+	},
+	parse: parseCapitalOne,
+}
+
+func parseCapitalOne(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		date, err := parseCSVDate(row.get("transaction date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+
+		tx := PythonTransaction{
+			Date:        date,
+			Description: row.first("description", "transaction description"),
+			SourceFile:  file,
+			Line:        row.line,
+			Currency:    "USD",
+			Bank:        "Capital One",
+		}
+
+		if _, card := row.columns["card no."]; card {
+			if tx.PostingDate, err = parseCSVDate(row.get("posted date")); err != nil {
+				return nil, row.fail(err)
+			}
+			debit, err := row.amount("debit")
+			if err != nil {
+				return nil, row.fail(err)
+			}
+			credit, err := row.amount("credit")
+			if err != nil {
+				return nil, row.fail(err)
+			}
+			tx.Amount = credit - debit
+			tx.AccountType = "visa"
+			tx.AccountName = "Capital One Card"
+			if number := row.get("card no."); number != "" {
+				tx.AccountNumber = &number
+			}
+			if debit != 0 && credit != 0 {
+				doubt(&tx, 0.5, "both debit and credit columns are filled")
+			}
+		} else {
+			amount, err := row.amount("transaction amount")
+			if err != nil {
+				return nil, row.fail(err)
+			}
+			if amount < 0 {
+				amount = -amount
+			}
+			switch strings.ToLower(row.get("transaction type")) {
+			case "debit":
+				tx.Amount = -amount
+			case "credit":
+				tx.Amount = amount
+			default:
+				tx.Amount = amount
+				doubt(&tx, 0.5, fmt.Sprintf("unknown transaction type %q, read as money in", row.get("transaction type")))
+			}
+			tx.AccountType = "chequing"
+			tx.AccountName = "Capital One 360"
+			if number := row.get("account number"); number != "" {
+				tx.AccountNumber = &number
+			}
+			if balance := row.get("balance"); balance != "" {
+				if value, err := parseAmount(balance, row.numbers); err == nil {
+					tx.Balance = &value
+				}
+			}
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// wealthsimpleFormat reads the monthly activity CSV from Wealthsimple Cash and Trade:
+// date,transaction,description,amount,balance[,currency]
+var wealthsimpleFormat = csvFormat{
+	name: "Wealthsimple",
+	detect: func(header []string) bool {
+		return hasColumns(header, "date", "transaction", "description", "amount", "balance")
+	},
+	parse: parseWealthsimple,
+}
+
+// wealthsimpleMethods maps Wealthsimple activity codes to parser methods
+var wealthsimpleMethods = map[string]string{
+	"E_TRFIN":  "e-transfer",
+	"E_TRFOUT": "e-transfer",
+	"AFT_IN":   "online",
+	"AFT_OUT":  "online",
+	"EFT":      "online",
+	"SPEND":    "card",
+	"REFUND":   "card",
+	"CASHBACK": "card",
+	"INT":      "fee",
+	"FEE":      "fee",
+	"DEP":      "deposit",
+	"CONT":     "deposit",
+	"BUY":      "trade",
+	"SELL":     "trade",
+	"DIV":      "dividend",
+}
+
+// wealthsimpleTradeCodes only appear on investment accounts
+var wealthsimpleTradeCodes = map[string]bool{
+	"BUY":  true,
+	"SELL": true,
+	"DIV":  true,
+	"CONT": true,
+	"NRT":  true,
+}
+
+// wealthsimpleTradePattern pulls the asset out of "AAPL - Apple Inc.: Bought 2.0000 shares (executed at ...)"
+var wealthsimpleTradePattern = regexp.MustCompile(`^(\S+) - .*?: (Bought|Sold) ([\d.,]+) shares?`)
+
+func parseWealthsimple(rows []csvRow, file string) ([]PythonTransaction, error) {
+	// One export covers one account, so the codes in it tell Cash from Trade
+	accountType, accountName := "chequing", "Wealthsimple Cash"
+	for _, row := range rows {
+		if wealthsimpleTradeCodes[strings.ToUpper(row.get("transaction"))] {
+			accountType, accountName = "investment", "Wealthsimple Trade"
+			break
+		}
+	}
+
+	var previous *float64
+	transactions := make([]PythonTransaction, 0, len(rows))
+	for _, row := range rows {
+		date, err := parseCSVDate(row.get("date"))
+		if err != nil {
+			return nil, row.fail(err)
+		}
+
+		amount, err := row.amount("amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+
+		code := strings.ToUpper(row.get("transaction"))
+		description := row.get("description")
+
+		var notes string
+		if m := wealthsimpleTradePattern.FindStringSubmatch(description); m != nil {
+			quantity := strings.ReplaceAll(m[3], ",", "")
+			if m[2] == "Sold" {
+				quantity = "-" + quantity
+			}
+			notes = fmt.Sprintf("asset: %s %s", quantity, m[1])
+		}
+
+		tx := PythonTransaction{
+			Date:        date,
+			Amount:      amount,
+			Method:      wealthsimpleMethods[code],
+			Category:    code,
+			Description: description,
+			AccountType: accountType,
+			AccountName: accountName,
+			SourceFile:  file,
+			Line:        row.line,
+			Currency:    strings.ToUpper(row.get("currency")),
+			Notes:       notes,
+			Bank:        "Wealthsimple",
+		}
+
+		// Each row carries the balance after it, so a row that doesn't add up was read wrong
+		if balance, err := row.amount("balance"); err == nil && row.get("balance") != "" {
+			if previous != nil && math.Abs(*previous+amount-balance) > 0.005 {
+				doubt(&tx, 0.5, fmt.Sprintf("balance %.2f does not follow from the previous %.2f", balance, *previous))
+			}
+			previous = &balance
+			tx.Balance = &balance
+		}
+
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
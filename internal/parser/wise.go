@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// wiseFormat reads the statement CSV Wise exports for one currency balance. Amounts are what left or
+// reached the balance, fees included.
+var wiseFormat = csvFormat{
+	name: "Wise",
+	detect: func(header []string) bool {
+		return (hasColumns(header, "transferwise id") || hasColumns(header, "id")) &&
+			hasColumns(header, "date", "amount", "currency", "description", "running balance", "exchange from", "exchange to", "total fees")
+	},
+	parse: parseWise,
+}
+
+// wiseMethods maps the prefix of a Wise ID, which says what kind of movement it was, to a method
+var wiseMethods = map[string]string{
+	"CARD":           "pos",
+	"TRANSFER":       "online",
+	"BALANCE":        "online",
+	"DIRECT_DEBIT":   "pre-auth",
+	"DEPOSIT":        "deposit",
+	"ACCRUAL_CHARGE": "fee",
+	"FEE":            "fee",
+}
+
+func parseWise(rows []csvRow, file string) ([]PythonTransaction, error) {
+	var transactions []PythonTransaction
+	for _, row := range rows {
+		date, err := parseCSVDateIn(row.first("date time", "date"), dayFirstLayouts)
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		amount, err := row.amount("amount")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		fee, err := row.amount("total fees")
+		if err != nil {
+			return nil, row.fail(err)
+		}
+		fee = math.Abs(fee)
+		currency := strings.ToUpper(row.get("currency"))
+		id := row.first("transferwise id", "id")
+
+		prefix, _, _ := strings.Cut(id, "-")
+		tx := PythonTransaction{
+			Date:        date,
+			Amount:      amount + fee,
+			Method:      wiseMethods[strings.ToUpper(prefix)],
+			Description: row.first("merchant", "payee name", "payer name", "description"),
+			AccountType: "chequing",
+			AccountName: "Wise " + currency,
+			SourceFile:  file,
+			Line:        row.line,
+			Currency:    currency,
+			Bank:        "Wise",
+			Notes:       row.get("note"),
+		}
+		if id != "" {
+			tx.Code = &id
+		}
+
+		// A conversion or spending in another currency keeps the amount on the other side
+		from, to := strings.ToUpper(row.get("exchange from")), strings.ToUpper(row.get("exchange to"))
+		if from != "" && to != "" && from != to {
+			rate, _ := row.amount("exchange rate")
+			switch {
+			case currency == from:
+				if converted, err := row.amount("exchange to amount"); err == nil && converted != 0 {
+					original := math.Copysign(converted, tx.Amount)
+					tx.OriginalAmount, tx.OriginalCurrency = &original, to
+				}
+			case currency == to && rate != 0:
+				original := math.Round(tx.Amount/rate*100) / 100
+				tx.OriginalAmount, tx.OriginalCurrency = &original, from
+			}
+		}
+
+		var balance *float64
+		if running := row.get("running balance"); running != "" {
+			if value, err := parseAmount(running, row.numbers); err == nil {
+				balance = &value
+			}
+		}
+
+		// The fee is its own line with the same Wise ID, and the balance is after both
+		if fee == 0 {
+			tx.Balance = balance
+			transactions = append(transactions, tx)
+			continue
+		}
+		feeTx := tx
+		feeTx.Amount = -fee
+		feeTx.Method = "fee"
+		feeTx.Description = "Wise fee: " + tx.Description
+		feeTx.Notes = fmt.Sprintf("fee for: %s %.2f %s", date[:len("2006-01-02")], tx.Amount, currency)
+		feeTx.OriginalAmount, feeTx.OriginalCurrency = nil, ""
+		feeTx.Balance = balance
+		if tx.Amount != 0 {
+			transactions = append(transactions, tx)
+		}
+		transactions = append(transactions, feeTx)
+	}
+	return transactions, nil
+}
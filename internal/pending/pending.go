@@ -0,0 +1,161 @@
+package pending
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/statefile"
+)
+
+// postingWindow is how long after a pending date the posted version may appear
+const postingWindow = 7 * 24 * time.Hour
+
+// Record is a pending transaction already uploaded to ariand, waiting for its posted version
+type Record struct {
+	TransactionID int64            `json:"transaction_id"`
+	AccountID     int              `json:"account_id"`
+	Date          time.Time        `json:"date"`
+	Amount        float64          `json:"amount"`
+	Direction     domain.Direction `json:"direction"`
+	Description   string           `json:"description"`
+}
+
+// Store persists uploaded pending transactions so a later import can update them instead of duplicating
+type Store struct {
+	filePath string
+	// SchemaVersion is the version of the file's format, see schema
+	SchemaVersion int      `json:"schema_version"`
+	Records       []Record `json:"records"`
+
+	// claimed are the records Uploaded or Add already matched to a line of this run
+	claimed map[int64]bool
+}
+
+// schema is the format of arian-pending.json. Version 1 only added the version.
+var schema = statefile.Schema{Name: "arian-pending.json", Version: 1, Migrations: []statefile.Migration{nil}}
+
+// NewStore creates a pending store backed by a file in the working directory
+func NewStore() (*Store, error) {
+	return NewStoreIn("")
+}
+
+// NewStoreIn creates a pending store backed by a file in dir, the working directory when empty
+func NewStoreIn(dir string) (*Store, error) {
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+		dir = cwd
+	}
+
+	store := &Store{filePath: filepath.Join(dir, "arian-pending.json")}
+
+	// Load existing records if file exists
+	if _, err := os.Stat(store.filePath); err == nil {
+		if err := store.Load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// Load reads records from disk, from their backup when the file is damaged
+func (s *Store) Load() error {
+	data, err := statefile.Read(s.filePath, statefile.CheckJSON[Store])
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read pending file: %w", err)
+	}
+	if data, err = schema.UpgradeJSON(data); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return fmt.Errorf("failed to parse pending file: %w", err)
+	}
+
+	return nil
+}
+
+// Save writes records to disk
+func (s *Store) Save() error {
+	s.SchemaVersion = schema.Version
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pending records: %w", err)
+	}
+
+	if err := statefile.Write(s.filePath, data); err != nil {
+		return fmt.Errorf("failed to write pending file: %w", err)
+	}
+
+	return nil
+}
+
+// Add remembers an uploaded pending transaction
+func (s *Store) Add(id int64, tx *domain.Transaction) {
+	s.claim(id)
+	s.Records = append(s.Records, Record{
+		TransactionID: id,
+		AccountID:     tx.AccountID,
+		Date:          tx.TxDate,
+		Amount:        tx.TxAmount,
+		Direction:     tx.TxDirection,
+		Description:   tx.TxDesc,
+	})
+}
+
+// Uploaded reports whether this pending transaction was already sent by an earlier run. Each record
+// stands for one line, so of two identical pending lines only as many count as uploaded as there
+// are records for them.
+func (s *Store) Uploaded(tx *domain.Transaction) bool {
+	for _, r := range s.Records {
+		if !s.claimed[r.TransactionID] && r.AccountID == tx.AccountID && r.Amount == tx.TxAmount &&
+			r.Direction == tx.TxDirection && r.Description == tx.TxDesc && r.Date.Equal(tx.TxDate) {
+			s.claim(r.TransactionID)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) claim(id int64) {
+	if s.claimed == nil {
+		s.claimed = make(map[int64]bool)
+	}
+	s.claimed[id] = true
+}
+
+// Match finds and removes the pending record a posted transaction settles. Banks often reword the
+// description and shift the date when posting, so amount, direction and account must match and the
+// date must fall within the posting window; a matching description breaks ties.
+func (s *Store) Match(tx *domain.Transaction) (Record, bool) {
+	best := -1
+	for i, r := range s.Records {
+		if r.AccountID != tx.AccountID || r.Amount != tx.TxAmount || r.Direction != tx.TxDirection {
+			continue
+		}
+		if tx.TxDate.Before(r.Date) || tx.TxDate.Sub(r.Date) > postingWindow {
+			continue
+		}
+		if best == -1 || (r.Description == tx.TxDesc && s.Records[best].Description != tx.TxDesc) {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		return Record{}, false
+	}
+
+	record := s.Records[best]
+	s.Records = append(s.Records[:best], s.Records[best+1:]...)
+	return record, true
+}
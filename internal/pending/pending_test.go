@@ -0,0 +1,82 @@
+package pending
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func pendingLine(description string, day int) *domain.Transaction {
+	return &domain.Transaction{
+		AccountID:   1,
+		TxDate:      time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC),
+		TxAmount:    4.5,
+		TxDirection: domain.Out,
+		TxDesc:      description,
+		Pending:     true,
+	}
+}
+
+func TestUploaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arian-pending.json")
+	store := &Store{filePath: path}
+
+	// Two identical coffees are two pending lines, the second is not the first uploaded again
+	first, second := pendingLine("COFFEE", 5), pendingLine("COFFEE", 5)
+	if store.Uploaded(first) {
+		t.Fatal("empty store reported a line as uploaded")
+	}
+	store.Add(1, first)
+	if store.Uploaded(second) {
+		t.Fatal("second identical line counted as the first")
+	}
+	store.Add(2, second)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next run sees both, and a third identical line is new
+	next := &Store{filePath: path}
+	if err := next.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !next.Uploaded(pendingLine("COFFEE", 5)) || !next.Uploaded(pendingLine("COFFEE", 5)) {
+		t.Error("lines of the earlier run not recognized")
+	}
+	if next.Uploaded(pendingLine("COFFEE", 5)) {
+		t.Error("a third line matched a record already claimed")
+	}
+	if next.Uploaded(pendingLine("TEA", 5)) {
+		t.Error("a different line matched")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	store := &Store{filePath: filepath.Join(t.TempDir(), "arian-pending.json")}
+	store.Add(1, pendingLine("SQ *COFFEE", 5))
+	store.Add(2, pendingLine("COFFEE", 5))
+
+	tests := []struct {
+		name   string
+		posted *domain.Transaction
+		want   int64
+		ok     bool
+	}{
+		{"before the pending date", pendingLine("COFFEE", 4), 0, false},
+		{"after the posting window", pendingLine("COFFEE", 13), 0, false},
+		{"same description wins", pendingLine("COFFEE", 6), 2, true},
+		{"reworded description", pendingLine("COFFEE SHOP", 6), 1, true},
+		{"all settled", pendingLine("COFFEE", 6), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.posted.Pending = false
+			record, ok := store.Match(tt.posted)
+			if ok != tt.ok || record.TransactionID != tt.want {
+				t.Errorf("Match = %d, %v; want %d, %v", record.TransactionID, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
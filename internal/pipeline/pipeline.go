@@ -0,0 +1,299 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// DefaultBuffer is how many transactions may wait between two stages before the earlier one blocks
+const DefaultBuffer = 256
+
+// memoryCheckEvery is how many transactions pass between two looks at the heap
+const memoryCheckEvery = 1024
+
+// ErrMemory means a run held more than the pipeline's MaxMemory
+var ErrMemory = errors.New("memory limit exceeded")
+
+// Stage is one step of an import, fed by the stage before it and feeding the one after. Build one
+// with Source, Map, Batch or Chunks.
+type Stage struct {
+	Name string
+	run  func(ctx context.Context, in <-chan *domain.Transaction, emit func(*domain.Transaction) error) error
+}
+
+// Source starts a pipeline with transactions from elsewhere, like a parser, ignoring its input
+func Source(name string, fn func(ctx context.Context, emit func(*domain.Transaction) error) error) Stage {
+	return Stage{Name: name, run: func(ctx context.Context, in <-chan *domain.Transaction, emit func(*domain.Transaction) error) error {
+		for range in {
+		}
+		return fn(ctx, emit)
+	}}
+}
+
+// Map handles transactions one at a time as they arrive. A nil result drops the transaction.
+func Map(name string, fn func(ctx context.Context, tx *domain.Transaction) (*domain.Transaction, error)) Stage {
+	return Stage{Name: name, run: func(ctx context.Context, in <-chan *domain.Transaction, emit func(*domain.Transaction) error) error {
+		for tx := range in {
+			out, err := fn(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if out != nil {
+				if err := emit(out); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}}
+}
+
+// Batch collects every transaction before handing them over, for steps that need the whole run,
+// like finding duplicates across statements
+func Batch(name string, fn func(ctx context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error)) Stage {
+	return Chunks(name, 0, fn)
+}
+
+// Chunks hands transactions over in groups of size as they arrive, the last one possibly smaller.
+// A size of 0 or less waits for all of them.
+func Chunks(name string, size int, fn func(ctx context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error)) Stage {
+	return Stage{Name: name, run: func(ctx context.Context, in <-chan *domain.Transaction, emit func(*domain.Transaction) error) error {
+		var chunk []*domain.Transaction
+		flush := func() error {
+			// A stopped run mustn't start another chunk, like an upload batch
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			out, err := fn(ctx, chunk)
+			if err != nil {
+				return err
+			}
+			for _, tx := range out {
+				if err := emit(tx); err != nil {
+					return err
+				}
+			}
+			chunk = nil
+			return nil
+		}
+
+		for tx := range in {
+			chunk = append(chunk, tx)
+			if size > 0 && len(chunk) == size {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		// A batch stage runs even for no transactions, so it can say there was nothing to do
+		if len(chunk) > 0 || size <= 0 {
+			return flush()
+		}
+		return nil
+	}}
+}
+
+// Shards spreads transactions over workers by key, each handing its share to fn in groups of size as
+// Chunks does. Every transaction with one key goes to the same worker in the order it arrived, so fn
+// never sees a key's transactions out of order, while different keys proceed side by side. fn must
+// be safe to call from several goroutines.
+func Shards(name string, workers, size int, key func(*domain.Transaction) int64, fn func(ctx context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error)) Stage {
+	if workers <= 1 {
+		return Chunks(name, size, fn)
+	}
+
+	return Stage{Name: name, run: func(ctx context.Context, in <-chan *domain.Transaction, emit func(*domain.Transaction) error) error {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		// The pipeline counts what a stage sends, which mustn't happen from two goroutines at once
+		var emitMu sync.Mutex
+		emitOne := func(tx *domain.Transaction) error {
+			emitMu.Lock()
+			defer emitMu.Unlock()
+			return emit(tx)
+		}
+
+		worker := Chunks(name, size, fn)
+		queues := make([]chan *domain.Transaction, workers)
+		var wg sync.WaitGroup
+		for i := range queues {
+			queues[i] = make(chan *domain.Transaction, DefaultBuffer)
+			wg.Add(1)
+			go func(queue chan *domain.Transaction) {
+				defer wg.Done()
+				if err := worker.run(ctx, queue, emitOne); err != nil {
+					cancel(err)
+				}
+				for range queue {
+				}
+			}(queues[i])
+		}
+
+	route:
+		for tx := range in {
+			select {
+			case queues[uint64(key(tx))%uint64(workers)] <- tx:
+			case <-ctx.Done():
+				break route
+			}
+		}
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+		return context.Cause(ctx)
+	}}
+}
+
+// Metrics describe what a stage did in one run
+type Metrics struct {
+	Stage string        `json:"stage"`
+	In    int           `json:"in"`
+	Out   int           `json:"out"`
+	Time  time.Duration `json:"time_ns"` // from the stage starting until it returned
+}
+
+// Pipeline runs stages concurrently, each one feeding the next through a bounded channel, so a
+// slow stage holds back the ones before it instead of piling up work
+type Pipeline struct {
+	Stages []Stage
+	Buffer int // channel size between stages, DefaultBuffer when 0
+	// MaxMemory stops the run with ErrMemory once the heap holds more bytes than this, rather than
+	// letting the system kill the process. 0 for no limit.
+	MaxMemory uint64
+
+	mu      sync.Mutex
+	metrics []Metrics
+}
+
+// New creates a pipeline of stages
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{Stages: stages}
+}
+
+// Run feeds transactions through every stage and returns what comes out of the last one. The first
+// stage error, or ctx ending, stops every stage and is returned.
+func (p *Pipeline) Run(ctx context.Context, transactions []*domain.Transaction) ([]*domain.Transaction, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	buffer := p.Buffer
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	metrics := make([]Metrics, len(p.Stages))
+	var wg sync.WaitGroup
+	var emitted atomic.Int64
+
+	source := make(chan *domain.Transaction, buffer)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(source)
+		for _, tx := range transactions {
+			select {
+			case source <- tx:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	in := source
+	for i, stage := range p.Stages {
+		out := make(chan *domain.Transaction, buffer)
+		metrics[i].Stage = stage.Name
+
+		wg.Add(1)
+		go func(stage Stage, in <-chan *domain.Transaction, out chan<- *domain.Transaction, m *Metrics) {
+			defer wg.Done()
+			defer close(out)
+			// Whatever the stage didn't read must not block the one before it
+			defer func() {
+				for range in {
+				}
+			}()
+
+			emit := func(tx *domain.Transaction) error {
+				select {
+				case out <- tx:
+					m.Out++
+					if p.MaxMemory > 0 && emitted.Add(1)%memoryCheckEvery == 0 {
+						return p.checkMemory()
+					}
+					return nil
+				case <-ctx.Done():
+					return context.Cause(ctx)
+				}
+			}
+
+			start := time.Now()
+			err := stage.run(ctx, in, emit)
+			m.Time = time.Since(start)
+			if err != nil {
+				cancel(fmt.Errorf("%s: %w", stage.Name, err))
+			}
+		}(stage, in, out, &metrics[i])
+		in = out
+	}
+
+	var result []*domain.Transaction
+	for tx := range in {
+		result = append(result, tx)
+	}
+	wg.Wait()
+
+	// What a stage got is what the one before it sent
+	for i := range metrics {
+		if i == 0 {
+			metrics[i].In = len(transactions)
+		} else {
+			metrics[i].In = metrics[i-1].Out
+		}
+	}
+
+	p.mu.Lock()
+	p.metrics = metrics
+	p.mu.Unlock()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkMemory fails once the live heap is over MaxMemory even after a collection
+func (p *Pipeline) checkMemory() error {
+	if heapBytes() <= p.MaxMemory {
+		return nil
+	}
+	runtime.GC()
+	if used := heapBytes(); used > p.MaxMemory {
+		return fmt.Errorf("%w: %d MiB in use, the limit is %d MiB", ErrMemory, used>>20, p.MaxMemory>>20)
+	}
+	return nil
+}
+
+// heapBytes is the memory taken by heap objects, live or not yet collected
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// Metrics returns what each stage did in the last run
+func (p *Pipeline) Metrics() []Metrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Metrics(nil), p.metrics...)
+}
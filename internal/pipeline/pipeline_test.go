@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func transactions(n int) []*domain.Transaction {
+	txs := make([]*domain.Transaction, n)
+	for i := range txs {
+		txs[i] = &domain.Transaction{TxAmount: float64(i + 1)}
+	}
+	return txs
+}
+
+func TestRun(t *testing.T) {
+	var chunks []int
+	p := New(
+		Map("drop odd", func(_ context.Context, tx *domain.Transaction) (*domain.Transaction, error) {
+			if int(tx.TxAmount)%2 == 1 {
+				return nil, nil
+			}
+			return tx, nil
+		}),
+		Batch("double", func(_ context.Context, txs []*domain.Transaction) ([]*domain.Transaction, error) {
+			for _, tx := range txs {
+				tx.TxAmount *= 2
+			}
+			return txs, nil
+		}),
+		Chunks("upload", 3, func(_ context.Context, txs []*domain.Transaction) ([]*domain.Transaction, error) {
+			chunks = append(chunks, len(txs))
+			return txs, nil
+		}),
+	)
+	p.Buffer = 1
+
+	out, err := p.Run(context.Background(), transactions(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 5 {
+		t.Fatalf("got %d transactions, want 5", len(out))
+	}
+	for i, tx := range out {
+		if want := float64((i + 1) * 4); tx.TxAmount != want {
+			t.Fatalf("transaction %d = %v, want %v in order", i, tx.TxAmount, want)
+		}
+	}
+	if len(chunks) != 2 || chunks[0] != 3 || chunks[1] != 2 {
+		t.Fatalf("chunks = %v, want [3 2]", chunks)
+	}
+
+	metrics := p.Metrics()
+	if len(metrics) != 3 || metrics[0].In != 10 || metrics[0].Out != 5 || metrics[2].Stage != "upload" || metrics[2].In != 5 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+}
+
+func TestSource(t *testing.T) {
+	p := New(Source("parse", func(_ context.Context, emit func(*domain.Transaction) error) error {
+		for _, tx := range transactions(4) {
+			if err := emit(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	out, err := p.Run(context.Background(), nil)
+	if err != nil || len(out) != 4 {
+		t.Fatalf("got %d transactions, %v", len(out), err)
+	}
+}
+
+func TestStageErrorStopsEverything(t *testing.T) {
+	failed := errors.New("ariand is down")
+	p := New(
+		Map("slow", func(ctx context.Context, tx *domain.Transaction) (*domain.Transaction, error) {
+			return tx, nil
+		}),
+		Chunks("upload", 2, func(context.Context, []*domain.Transaction) ([]*domain.Transaction, error) {
+			return nil, failed
+		}),
+	)
+	p.Buffer = 1
+
+	_, err := p.Run(context.Background(), transactions(1000))
+	if !errors.Is(err, failed) {
+		t.Fatalf("err = %v, want the stage error", err)
+	}
+	if got := err.Error(); got != "upload: ariand is down" {
+		t.Fatalf("err = %q, want it to name the stage", got)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(Map("stuck", func(ctx context.Context, tx *domain.Transaction) (*domain.Transaction, error) {
+		cancel()
+		<-ctx.Done()
+		return tx, nil
+	}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Run(ctx, transactions(1000))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't stop after the context was cancelled")
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	p := New(Map("copy", func(_ context.Context, tx *domain.Transaction) (*domain.Transaction, error) {
+		return tx, nil
+	}))
+	p.MaxMemory = 1 // less than any heap
+
+	_, err := p.Run(context.Background(), transactions(2*memoryCheckEvery))
+	if !errors.Is(err, ErrMemory) {
+		t.Fatalf("err = %v, want ErrMemory", err)
+	}
+
+	p.MaxMemory = 1 << 40
+	if _, err := p.Run(context.Background(), transactions(2*memoryCheckEvery)); err != nil {
+		t.Fatalf("err = %v under a generous limit", err)
+	}
+}
+
+func TestShards(t *testing.T) {
+	// Three accounts, each with its transactions in date order
+	var txs []*domain.Transaction
+	for day := range 50 {
+		for account := 1; account <= 3; account++ {
+			txs = append(txs, &domain.Transaction{AccountID: account, TxDate: time.Date(2024, 1, 1+day, 0, 0, 0, 0, time.UTC)})
+		}
+	}
+
+	var mu sync.Mutex
+	seen := make(map[int][]time.Time)
+	p := New(Shards("upload", 3, 4, func(tx *domain.Transaction) int64 { return int64(tx.AccountID) },
+		func(_ context.Context, batch []*domain.Transaction) ([]*domain.Transaction, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, tx := range batch {
+				seen[tx.AccountID] = append(seen[tx.AccountID], tx.TxDate)
+			}
+			return batch, nil
+		}))
+
+	out, err := p.Run(context.Background(), txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(txs) || p.Metrics()[0].Out != len(txs) {
+		t.Fatalf("got %d transactions, want %d", len(out), len(txs))
+	}
+	for account, dates := range seen {
+		if len(dates) != 50 {
+			t.Fatalf("account %d: %d transactions", account, len(dates))
+		}
+		for i := 1; i < len(dates); i++ {
+			if dates[i].Before(dates[i-1]) {
+				t.Fatalf("account %d: %s sent after %s", account, dates[i].Format(time.DateOnly), dates[i-1].Format(time.DateOnly))
+			}
+		}
+	}
+}
+
+func TestShardsError(t *testing.T) {
+	failed := errors.New("ariand is down")
+	var mu sync.Mutex
+	calls := 0
+	p := New(Shards("upload", 4, 1, func(tx *domain.Transaction) int64 { return int64(tx.TxAmount) },
+		func(context.Context, []*domain.Transaction) ([]*domain.Transaction, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			return nil, failed
+		}))
+	p.Buffer = 1
+
+	if _, err := p.Run(context.Background(), transactions(1000)); !errors.Is(err, failed) {
+		t.Fatalf("err = %v, want the worker error", err)
+	}
+	if calls >= 1000 {
+		t.Fatalf("fn ran %d times, the error should have stopped the workers", calls)
+	}
+}
@@ -0,0 +1,164 @@
+package quarantine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Stages a file can fail at
+const (
+	StageParse      = "parse"
+	StageValidation = "validation"
+)
+
+// sidecarSuffix is added to a quarantined file's name for the file saying why it is there
+const sidecarSuffix = ".error.json"
+
+// Entry is what the sidecar next to a quarantined file says about it
+type Entry struct {
+	File   string `json:"file"`   // name of the file in the quarantine dir
+	Source string `json:"source"` // the statement source it came from, e.g. local:/statements
+	Key    string `json:"key"`    // the file's key in that source
+	Stage  string `json:"stage"`  // parse or validation
+	Error  string `json:"error"`
+	// Version is the version of the parsers that failed on the file; it is retried once another
+	// version runs
+	Version       string    `json:"version"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// Dir is a folder of statement files an unattended run couldn't import, each with a sidecar
+// <file>.error.json saying why
+type Dir struct {
+	path string
+}
+
+// Open returns the quarantine at path, which is created when the first file is added
+func Open(path string) *Dir {
+	return &Dir{path: path}
+}
+
+// Path returns the folder of the quarantine
+func (d *Dir) Path() string {
+	return d.path
+}
+
+// Add moves file into the quarantine and writes its sidecar. A file of the same name that is
+// already there is replaced.
+func (d *Dir) Add(file string, entry Entry) error {
+	if err := os.MkdirAll(d.path, 0o700); err != nil {
+		return fmt.Errorf("failed to create quarantine dir: %w", err)
+	}
+
+	entry.File = filepath.Base(file)
+	if entry.QuarantinedAt.IsZero() {
+		entry.QuarantinedAt = time.Now().UTC()
+	}
+	dst := filepath.Join(d.path, entry.File)
+	if err := move(file, dst); err != nil {
+		return fmt.Errorf("failed to quarantine %s: %w", entry.File, err)
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode quarantine entry: %w", err)
+	}
+	if err := os.WriteFile(dst+sidecarSuffix, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write quarantine entry: %w", err)
+	}
+	return nil
+}
+
+// List reads the sidecar of every quarantined file. An unreadable sidecar is skipped, so one
+// broken file doesn't hide the others.
+func (d *Dir) List() ([]Entry, error) {
+	entries, err := os.ReadDir(d.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine dir: %w", err)
+	}
+
+	var quarantined []Entry
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), sidecarSuffix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d.path, e.Name()))
+		if err != nil {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.File == "" {
+			continue
+		}
+		quarantined = append(quarantined, entry)
+	}
+	return quarantined, nil
+}
+
+// Release takes back the files of source that a version other than version failed on: each is
+// moved into restore, or deleted when restore is empty because the source still has it, and its
+// sidecar is removed. It returns the released entries.
+func (d *Dir) Release(source, version, restore string) ([]Entry, error) {
+	quarantined, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var released []Entry
+	for _, entry := range quarantined {
+		if entry.Source != source || entry.Version == version {
+			continue
+		}
+
+		file := filepath.Join(d.path, entry.File)
+		if restore != "" {
+			err = move(file, filepath.Join(restore, entry.File))
+		} else {
+			err = os.Remove(file)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return released, fmt.Errorf("failed to release %s: %w", entry.File, err)
+		}
+		if err := os.Remove(file + sidecarSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return released, fmt.Errorf("failed to release %s: %w", entry.File, err)
+		}
+		released = append(released, entry)
+	}
+	return released, nil
+}
+
+// move renames src to dst, copying when they are on different file systems
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
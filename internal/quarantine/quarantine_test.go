@@ -0,0 +1,50 @@
+package quarantine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelease(t *testing.T) {
+	watched := t.TempDir()
+	dir := Open(filepath.Join(watched, "quarantine"))
+
+	for _, name := range []string{"old.pdf", "current.pdf", "other.pdf"} {
+		if err := os.WriteFile(filepath.Join(watched, name), []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add := func(name, source, version string) {
+		entry := Entry{Source: source, Key: name, Stage: StageParse, Error: "boom", Version: version}
+		if err := dir.Add(filepath.Join(watched, name), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("old.pdf", "local:a", "v1")
+	add("current.pdf", "local:a", "v2")
+	add("other.pdf", "local:b", "v1")
+
+	if _, err := os.Stat(filepath.Join(watched, "old.pdf")); !os.IsNotExist(err) {
+		t.Fatalf("old.pdf is still in the watched folder: %v", err)
+	}
+
+	released, err := dir.Release("local:a", "v2", watched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || released[0].Key != "old.pdf" {
+		t.Fatalf("released %+v, want only old.pdf", released)
+	}
+	if _, err := os.Stat(filepath.Join(watched, "old.pdf")); err != nil {
+		t.Fatalf("old.pdf wasn't restored: %v", err)
+	}
+
+	left, err := dir.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 {
+		t.Fatalf("%d files left in quarantine, want 2", len(left))
+	}
+}
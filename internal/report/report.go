@@ -0,0 +1,323 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Formats a report can be written in
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// largestCount is how many transactions the largest transactions table lists
+const largestCount = 10
+
+// Report summarizes what an import sent to ariand
+type Report struct {
+	RunID      string
+	StartedAt  time.Time
+	Status     Status
+	Accounts   []Account
+	Categories []Category
+	Largest    []Line
+}
+
+// Status is how the run went as a whole
+type Status struct {
+	Files      int
+	Processed  int // files that had transactions
+	Parsed     int
+	Duplicates int
+	Created    int // including pending lines
+	Pending    int // pending lines created, or settled by their posted version
+	Failed     int
+	Review     int // waiting in the review queue
+}
+
+// Account totals the transactions of one account in one currency
+type Account struct {
+	Name     string
+	Currency string
+	Count    int
+	In       float64
+	Out      float64
+	First    time.Time
+	Last     time.Time
+	Files    int
+}
+
+// Net is what came in minus what went out
+func (a Account) Net() float64 {
+	return a.In - a.Out
+}
+
+// Category totals the transactions of one category in one currency
+type Category struct {
+	Slug     string
+	Currency string
+	Count    int
+	In       float64
+	Out      float64
+}
+
+// Line is one transaction in the largest transactions table
+type Line struct {
+	Date        time.Time
+	Account     string
+	Amount      float64 // negative for money out
+	Currency    string
+	Description string
+	Category    string
+}
+
+// Build summarizes the uploaded transactions; accountName labels each one for the per-account tables
+func Build(runID string, startedAt time.Time, status Status, transactions []*domain.Transaction, accountName func(*domain.Transaction) string) *Report {
+	r := &Report{RunID: runID, StartedAt: startedAt, Status: status}
+
+	accounts := make(map[string]*Account)
+	files := make(map[string]map[string]bool)
+	categories := make(map[string]*Category)
+	var lines []Line
+
+	for _, tx := range transactions {
+		name := accountName(tx)
+		amount := tx.TxAmount
+		if tx.TxDirection == domain.Out {
+			amount = -amount
+		}
+		category := tx.Category
+		if category == "" {
+			category = "uncategorized"
+		}
+
+		key := name + "|" + tx.TxCurrency
+		account, ok := accounts[key]
+		if !ok {
+			account = &Account{Name: name, Currency: tx.TxCurrency, First: tx.TxDate, Last: tx.TxDate}
+			accounts[key] = account
+			files[key] = make(map[string]bool)
+		}
+		account.Count++
+		addAmount(&account.In, &account.Out, amount)
+		if tx.TxDate.Before(account.First) {
+			account.First = tx.TxDate
+		}
+		if tx.TxDate.After(account.Last) {
+			account.Last = tx.TxDate
+		}
+		if tx.SourceFilePath != "" {
+			files[key][tx.SourceFilePath] = true
+		}
+
+		key = category + "|" + tx.TxCurrency
+		if categories[key] == nil {
+			categories[key] = &Category{Slug: category, Currency: tx.TxCurrency}
+		}
+		categories[key].Count++
+		addAmount(&categories[key].In, &categories[key].Out, amount)
+
+		lines = append(lines, Line{
+			Date:        tx.TxDate,
+			Account:     name,
+			Amount:      amount,
+			Currency:    tx.TxCurrency,
+			Description: tx.TxDesc,
+			Category:    tx.Category,
+		})
+	}
+
+	for key, account := range accounts {
+		account.Files = len(files[key])
+		r.Accounts = append(r.Accounts, *account)
+	}
+	sort.Slice(r.Accounts, func(i, j int) bool {
+		if r.Accounts[i].Name != r.Accounts[j].Name {
+			return r.Accounts[i].Name < r.Accounts[j].Name
+		}
+		return r.Accounts[i].Currency < r.Accounts[j].Currency
+	})
+
+	for _, category := range categories {
+		r.Categories = append(r.Categories, *category)
+	}
+	// Where the money went matters most, so the biggest spending comes first
+	sort.Slice(r.Categories, func(i, j int) bool {
+		a, b := r.Categories[i], r.Categories[j]
+		if a.Out != b.Out {
+			return a.Out > b.Out
+		}
+		if a.In != b.In {
+			return a.In > b.In
+		}
+		return a.Slug+a.Currency < b.Slug+b.Currency
+	})
+
+	sort.SliceStable(lines, func(i, j int) bool { return math.Abs(lines[i].Amount) > math.Abs(lines[j].Amount) })
+	r.Largest = lines[:min(len(lines), largestCount)]
+
+	return r
+}
+
+func addAmount(in, out *float64, amount float64) {
+	if amount < 0 {
+		*out -= amount
+	} else {
+		*in += amount
+	}
+}
+
+// Render writes the report as markdown or HTML
+func (r *Report) Render(format string) (string, error) {
+	var b bytes.Buffer
+	var err error
+	switch format {
+	case FormatMarkdown:
+		err = markdownTemplate.Execute(&b, r)
+	case FormatHTML:
+		err = htmlTemplate.Execute(&b, r)
+	default:
+		return "", CheckFormat(format)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to render report: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Save renders the report into dir as import-<date>-<run id>.md or .html and returns the path
+func (r *Report) Save(dir, format string) (string, error) {
+	content, err := r.Render(format)
+	if err != nil {
+		return "", err
+	}
+
+	ext := ".md"
+	if format == FormatHTML {
+		ext = ".html"
+	}
+	path := filepath.Join(dir, fmt.Sprintf("import-%s-%s%s", r.StartedAt.Format(time.DateOnly), r.RunID, ext))
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create report dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return path, nil
+}
+
+// CheckFormat rejects formats Render doesn't know
+func CheckFormat(format string) error {
+	switch format {
+	case FormatMarkdown, FormatHTML:
+		return nil
+	}
+	return fmt.Errorf("unknown report format %q, expected %s or %s", format, FormatMarkdown, FormatHTML)
+}
+
+var funcs = map[string]any{
+	"date":   func(t time.Time) string { return t.Format(time.DateOnly) },
+	"money":  func(amount float64) string { return fmt.Sprintf("%.2f", amount) },
+	"cell":   func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
+	"status": func(s Status) string { return s.text() },
+}
+
+// text is a one-line verdict on the run
+func (s Status) text() string {
+	switch {
+	case s.Failed > 0:
+		return fmt.Sprintf("%d transactions failed to upload", s.Failed)
+	case s.Review > 0:
+		return fmt.Sprintf("everything uploaded, %d transactions wait for review", s.Review)
+	default:
+		return "everything uploaded"
+	}
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(funcs).Parse(`# Import {{.RunID}}
+
+{{date .StartedAt}}, {{status .Status}}.
+
+| | |
+| --- | --- |
+| Files with transactions | {{.Status.Processed}} of {{.Status.Files}} |
+| Transactions parsed | {{.Status.Parsed}} |
+| Duplicates dropped | {{.Status.Duplicates}} |
+| Created | {{.Status.Created}} |
+| Pending lines created or settled | {{.Status.Pending}} |
+| Failed | {{.Status.Failed}} |
+| Waiting for review | {{.Status.Review}} |
+
+## Accounts
+
+| Account | Currency | Transactions | In | Out | Net | From | To | Files |
+| --- | --- | ---: | ---: | ---: | ---: | --- | --- | ---: |
+{{range .Accounts}}| {{cell .Name}} | {{.Currency}} | {{.Count}} | {{money .In}} | {{money .Out}} | {{money .Net}} | {{date .First}} | {{date .Last}} | {{.Files}} |
+{{end}}
+## Categories
+
+| Category | Currency | Transactions | In | Out |
+| --- | --- | ---: | ---: | ---: |
+{{range .Categories}}| {{cell .Slug}} | {{.Currency}} | {{.Count}} | {{money .In}} | {{money .Out}} |
+{{end}}
+## Largest Transactions
+
+| Date | Account | Amount | Description | Category |
+| --- | --- | ---: | --- | --- |
+{{range .Largest}}| {{date .Date}} | {{cell .Account}} | {{money .Amount}} {{.Currency}} | {{cell .Description}} | {{cell .Category}} |
+{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Import {{.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+</style>
+</head>
+<body>
+<h1>Import {{.RunID}}</h1>
+<p>{{date .StartedAt}}, {{status .Status}}.</p>
+<table>
+<tr><td>Files with transactions</td><td class="num">{{.Status.Processed}} of {{.Status.Files}}</td></tr>
+<tr><td>Transactions parsed</td><td class="num">{{.Status.Parsed}}</td></tr>
+<tr><td>Duplicates dropped</td><td class="num">{{.Status.Duplicates}}</td></tr>
+<tr><td>Created</td><td class="num">{{.Status.Created}}</td></tr>
+<tr><td>Pending lines created or settled</td><td class="num">{{.Status.Pending}}</td></tr>
+<tr><td>Failed</td><td class="num">{{.Status.Failed}}</td></tr>
+<tr><td>Waiting for review</td><td class="num">{{.Status.Review}}</td></tr>
+</table>
+<h2>Accounts</h2>
+<table>
+<tr><th>Account</th><th>Currency</th><th>Transactions</th><th>In</th><th>Out</th><th>Net</th><th>From</th><th>To</th><th>Files</th></tr>
+{{range .Accounts}}<tr><td>{{.Name}}</td><td>{{.Currency}}</td><td class="num">{{.Count}}</td><td class="num">{{money .In}}</td><td class="num">{{money .Out}}</td><td class="num">{{money .Net}}</td><td>{{date .First}}</td><td>{{date .Last}}</td><td class="num">{{.Files}}</td></tr>
+{{end}}</table>
+<h2>Categories</h2>
+<table>
+<tr><th>Category</th><th>Currency</th><th>Transactions</th><th>In</th><th>Out</th></tr>
+{{range .Categories}}<tr><td>{{.Slug}}</td><td>{{.Currency}}</td><td class="num">{{.Count}}</td><td class="num">{{money .In}}</td><td class="num">{{money .Out}}</td></tr>
+{{end}}</table>
+<h2>Largest Transactions</h2>
+<table>
+<tr><th>Date</th><th>Account</th><th>Amount</th><th>Description</th><th>Category</th></tr>
+{{range .Largest}}<tr><td>{{date .Date}}</td><td>{{.Account}}</td><td class="num">{{money .Amount}} {{.Currency}}</td><td>{{.Description}}</td><td>{{.Category}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
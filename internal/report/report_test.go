@@ -0,0 +1,62 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestBuild(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	transactions := []*domain.Transaction{
+		{AccountID: 1, TxDate: day(3), TxAmount: 2500, TxCurrency: "CAD", TxDirection: domain.In, TxDesc: "PAYROLL", SourceFilePath: "a.pdf"},
+		{AccountID: 1, TxDate: day(1), TxAmount: 45.5, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "GROCER | MAIN ST", Category: "groceries", SourceFilePath: "a.pdf"},
+		{AccountID: 1, TxDate: day(9), TxAmount: 12, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: "COFFEE", Category: "groceries", SourceFilePath: "b.pdf"},
+		{AccountID: 2, TxDate: day(5), TxAmount: 80, TxCurrency: "USD", TxDirection: domain.Out, TxDesc: "HOTEL", Category: "travel", SourceFilePath: "visa.pdf"},
+	}
+	names := map[int]string{1: "Chequing", 2: "Visa"}
+
+	r := Build("run1", day(10), Status{Created: 4}, transactions, func(tx *domain.Transaction) string { return names[tx.AccountID] })
+
+	if len(r.Accounts) != 2 {
+		t.Fatalf("got %d account rows, want 2", len(r.Accounts))
+	}
+	chequing := r.Accounts[0]
+	if chequing.Name != "Chequing" || chequing.Count != 3 || chequing.In != 2500 || chequing.Out != 57.5 || chequing.Files != 2 {
+		t.Errorf("chequing totals: %+v", chequing)
+	}
+	if !chequing.First.Equal(day(1)) || !chequing.Last.Equal(day(9)) {
+		t.Errorf("chequing covers %s to %s", chequing.First, chequing.Last)
+	}
+
+	if r.Categories[0].Slug != "travel" || r.Categories[1].Slug != "groceries" || r.Categories[2].Slug != "uncategorized" {
+		t.Errorf("categories in the wrong order: %+v", r.Categories)
+	}
+	if r.Largest[0].Description != "PAYROLL" || r.Largest[1].Amount != -80 {
+		t.Errorf("largest transactions: %+v", r.Largest)
+	}
+
+	markdown, err := r.Render(FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"| Chequing | CAD | 3 | 2500.00 | 57.50 | 2442.50 | 2024-03-01 | 2024-03-09 | 2 |", `GROCER \| MAIN ST`} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown report is missing %q:\n%s", want, markdown)
+		}
+	}
+
+	html, err := r.Render(FormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, "<td>Visa</td>") {
+		t.Errorf("html report is missing the Visa row:\n%s", html)
+	}
+
+	if _, err := r.Render("pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,262 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+	"arian-statement-parser/internal/statefile"
+)
+
+// DefaultPath is where lines waiting for a person are kept between runs, next to the other state files
+const DefaultPath = "arian-review.json"
+
+// Reasons a line is put aside instead of uploaded
+const (
+	ReasonAccount    = "account"    // no single ariand account fits its statement account
+	ReasonInvalid    = "invalid"    // it failed validation
+	ReasonConfidence = "confidence" // the parser was not sure it read it right
+	ReasonDeclined   = "declined"   // its account was declined at a per-account upload prompt
+)
+
+// Entry is one transaction waiting for review, with everything needed to upload it later
+type Entry struct {
+	Reason        string    `json:"reason"`
+	Details       []string  `json:"details,omitempty"`
+	AddedAt       time.Time `json:"added_at"`
+	AccountID     int       `json:"account_id,omitempty"`
+	Date          time.Time `json:"date"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	Direction     string    `json:"direction"`
+	Description   string    `json:"description,omitempty"`
+	Merchant      string    `json:"merchant,omitempty"`
+	UserNotes     string    `json:"user_notes,omitempty"`
+	Category      string    `json:"category,omitempty"`
+	CategoryID    *int64    `json:"category_id,omitempty"`
+	Reference     string    `json:"reference_code,omitempty"`
+	Method        string    `json:"method,omitempty"`
+	Pending       bool      `json:"pending,omitempty"`
+	Confidence    float64   `json:"confidence"`
+	AccountNumber string    `json:"statement_account_number,omitempty"`
+	AccountType   string    `json:"statement_account_type,omitempty"`
+	AccountName   string    `json:"statement_account_name,omitempty"`
+	Bank          string    `json:"statement_bank,omitempty"`
+	SourceFile    string    `json:"source_file,omitempty"`
+	// Page and Line are where the line is in its file, see domain.Transaction.Source
+	Page int `json:"source_page,omitempty"`
+	Line int `json:"source_line,omitempty"`
+	// The rest of the line, which goes into its kind and its notes in ariand
+	PostingDate      time.Time   `json:"posting_date,omitzero"`
+	Kind             domain.Kind `json:"kind,omitempty"`
+	Balance          *float64    `json:"balance,omitempty"`
+	OriginalAmount   float64     `json:"original_amount,omitempty"`
+	OriginalCurrency string      `json:"original_currency,omitempty"`
+	Principal        *float64    `json:"principal,omitempty"`
+	Interest         *float64    `json:"interest,omitempty"`
+	BankCategory     string      `json:"bank_category,omitempty"`
+	Provenance       []string    `json:"provenance,omitempty"`
+}
+
+// Queue is the review file; entries stay in it until they are uploaded or dropped
+type Queue struct {
+	// SchemaVersion is the version of the file's format, see schema
+	SchemaVersion int     `json:"schema_version"`
+	UserID        string  `json:"user_id"`
+	Entries       []Entry `json:"entries"`
+
+	path string
+}
+
+// schema is the format of the review file. Version 1 only added the version, version 2 the place of
+// each line in its file and what else the line said.
+var schema = statefile.Schema{Name: "the review queue", Version: 2, Migrations: []statefile.Migration{nil, nil}}
+
+// Load reads the queue at path; a missing file is an empty queue, a damaged one is read from its
+// backup
+func Load(path string) (*Queue, error) {
+	queue := &Queue{path: path}
+
+	data, err := statefile.Read(path, statefile.CheckJSON[Queue])
+	if os.IsNotExist(err) {
+		return queue, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read review queue: %w", err)
+	}
+	if data, err = schema.UpgradeJSON(data); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, queue); err != nil {
+		return nil, fmt.Errorf("failed to parse review queue: %w", err)
+	}
+	return queue, nil
+}
+
+// Add puts a transaction aside for review. A line already waiting, say from a statement that was
+// imported again, only gets its reason updated. Identical lines at different places in a file, like
+// two coffees on the same day, are kept apart.
+func (q *Queue) Add(userID string, tx *domain.Transaction, reason string, details ...string) {
+	q.UserID = userID
+	entry := entryOf(tx, reason, details)
+
+	for i, existing := range q.Entries {
+		if existing.key() == entry.key() {
+			entry.AddedAt = existing.AddedAt
+			q.Entries[i] = entry
+			return
+		}
+	}
+	q.Entries = append(q.Entries, entry)
+}
+
+// Remove takes a transaction out of the queue once it was uploaded some other way, e.g. by an
+// interactive import of the same statement
+func (q *Queue) Remove(tx *domain.Transaction) bool {
+	key := entryOf(tx, "", nil).key()
+	for i, existing := range q.Entries {
+		if existing.key() == key {
+			q.Entries = append(q.Entries[:i], q.Entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the queue, or removes the file once nothing is left to review
+func (q *Queue) Save() error {
+	if len(q.Entries) == 0 {
+		// Its backups go too, or a damaged queue could bring back lines that were uploaded since
+		if err := statefile.Remove(q.path); err != nil {
+			return fmt.Errorf("failed to remove review queue: %w", err)
+		}
+		return nil
+	}
+
+	q.SchemaVersion = schema.Version
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode review queue: %w", err)
+	}
+	if err := statefile.Write(q.path, data); err != nil {
+		return fmt.Errorf("failed to write review queue: %w", err)
+	}
+	return nil
+}
+
+// Path returns where the queue is kept
+func (q *Queue) Path() string {
+	return q.path
+}
+
+func entryOf(tx *domain.Transaction, reason string, details []string) Entry {
+	direction := "out"
+	if tx.TxDirection == domain.In {
+		direction = "in"
+	}
+	var number string
+	if tx.StatementAccountNumber != nil {
+		number = *tx.StatementAccountNumber
+	}
+
+	entry := Entry{
+		Reason:        reason,
+		Details:       details,
+		AddedAt:       time.Now().UTC(),
+		AccountID:     tx.AccountID,
+		Date:          tx.TxDate,
+		Amount:        tx.TxAmount,
+		Currency:      tx.TxCurrency,
+		Direction:     direction,
+		Description:   tx.TxDesc,
+		Merchant:      tx.Merchant,
+		UserNotes:     tx.UserNotes,
+		Category:      tx.Category,
+		CategoryID:    tx.CategoryID,
+		Reference:     tx.ReferenceCode,
+		Method:        string(tx.Method),
+		Pending:       tx.Pending,
+		Confidence:    tx.Confidence,
+		AccountNumber: number,
+		AccountType:   tx.StatementAccountType,
+		AccountName:   tx.StatementAccountName,
+		Bank:          tx.StatementBank,
+		SourceFile:    tx.SourceFilePath,
+		Page:          tx.SourcePage,
+		Line:          tx.SourceLine,
+		PostingDate:   tx.PostingDate,
+		Kind:          tx.Kind,
+		Balance:       tx.Balance,
+		BankCategory:  tx.BankCategory,
+		Provenance:    tx.Provenance,
+	}
+	if tx.Original != nil {
+		entry.OriginalAmount = tx.Original.Amount
+		entry.OriginalCurrency = tx.Original.Currency
+	}
+	if tx.Loan != nil {
+		principal, interest := tx.Loan.Principal, tx.Loan.Interest
+		entry.Principal, entry.Interest = &principal, &interest
+	}
+	return entry
+}
+
+// key identifies the statement line an entry came from. The file is compared by name, since remote
+// statements are downloaded to a new folder each run.
+func (e Entry) key() string {
+	return fmt.Sprintf("%s|%d|%d|%s|%s|%s|%s|%.2f|%s|%s", filepath.Base(e.SourceFile), e.Page, e.Line,
+		e.AccountNumber, e.AccountName, e.Date.Format(time.DateOnly), e.Direction, e.Amount, e.Description, e.Reference)
+}
+
+// Transaction rebuilds the domain transaction for upload
+func (e Entry) Transaction() *domain.Transaction {
+	direction := domain.Out
+	if e.Direction == "in" {
+		direction = domain.In
+	}
+	var number *string
+	if e.AccountNumber != "" {
+		n := e.AccountNumber
+		number = &n
+	}
+
+	tx := &domain.Transaction{
+		AccountID:              e.AccountID,
+		TxDate:                 e.Date,
+		TxAmount:               e.Amount,
+		TxCurrency:             e.Currency,
+		TxDirection:            direction,
+		TxDesc:                 e.Description,
+		Merchant:               e.Merchant,
+		UserNotes:              e.UserNotes,
+		Pending:                e.Pending,
+		Confidence:             e.Confidence,
+		ReferenceCode:          e.Reference,
+		Method:                 domain.Method(e.Method),
+		Category:               e.Category,
+		CategoryID:             e.CategoryID,
+		StatementAccountNumber: number,
+		StatementAccountType:   e.AccountType,
+		StatementAccountName:   e.AccountName,
+		StatementBank:          e.Bank,
+		SourceFilePath:         e.SourceFile,
+		SourcePage:             e.Page,
+		SourceLine:             e.Line,
+		PostingDate:            e.PostingDate,
+		Kind:                   e.Kind,
+		Balance:                e.Balance,
+		BankCategory:           e.BankCategory,
+		Provenance:             e.Provenance,
+	}
+	if e.OriginalAmount != 0 || e.OriginalCurrency != "" {
+		tx.Original = &domain.Money{Amount: e.OriginalAmount, Currency: e.OriginalCurrency}
+	}
+	if e.Principal != nil && e.Interest != nil {
+		tx.Loan = &domain.LoanSplit{Principal: *e.Principal, Interest: *e.Interest}
+	}
+	return tx
+}
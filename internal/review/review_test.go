@@ -0,0 +1,133 @@
+package review
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestQueueRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arian-review.json")
+	queue, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	number := "01234-5678901"
+	coffee := &domain.Transaction{
+		TxDate:                 time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		TxAmount:               4.5,
+		TxCurrency:             "CAD",
+		TxDirection:            domain.Out,
+		TxDesc:                 "COFFEE",
+		Confidence:             0.5,
+		Method:                 domain.MethodPOS,
+		StatementAccountNumber: &number,
+		StatementAccountType:   "chequing",
+		StatementBank:          "RBC",
+		SourceFilePath:         "/statements/chequing.pdf",
+	}
+	refund := &domain.Transaction{TxDate: coffee.TxDate, TxAmount: 4.5, TxCurrency: "CAD", TxDirection: domain.In, TxDesc: "COFFEE"}
+
+	queue.Add("user", coffee, ReasonConfidence, "balance does not add up")
+	queue.Add("user", refund, ReasonInvalid, "amount: is zero")
+	// Importing the statement again updates the entry instead of adding a second one
+	queue.Add("user", coffee, ReasonAccount, "no mapping")
+	if len(queue.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(queue.Entries))
+	}
+	if err := queue.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.UserID != "user" || len(reloaded.Entries) != 2 {
+		t.Fatalf("got user %q with %d entries after reload", reloaded.UserID, len(reloaded.Entries))
+	}
+
+	entry := reloaded.Entries[0]
+	if entry.Reason != ReasonAccount || len(entry.Details) != 1 || entry.Details[0] != "no mapping" {
+		t.Errorf("entry kept reason %q and details %v", entry.Reason, entry.Details)
+	}
+	tx := entry.Transaction()
+	if *tx.StatementAccountNumber != number || tx.TxDirection != domain.Out || tx.TxAmount != 4.5 || tx.Method != domain.MethodPOS || tx.SourceFilePath != coffee.SourceFilePath {
+		t.Errorf("transaction did not survive the round trip: %+v", tx)
+	}
+
+	// Once everything is settled the file goes away
+	if !reloaded.Remove(coffee) || !reloaded.Remove(refund) || reloaded.Remove(refund) {
+		t.Fatal("Remove did not find each entry exactly once")
+	}
+	if err := reloaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("empty queue left %s behind", path)
+	}
+}
+
+func TestQueueKeepsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arian-review.json")
+	queue, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance := 120.5
+	coffee := func(line int) *domain.Transaction {
+		return &domain.Transaction{
+			TxDate:         time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			PostingDate:    time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC),
+			TxAmount:       4.5,
+			TxCurrency:     "CAD",
+			TxDirection:    domain.Out,
+			TxDesc:         "COFFEE",
+			Kind:           domain.KindPurchase,
+			Balance:        &balance,
+			Original:       &domain.Money{Amount: 3.3, Currency: "USD"},
+			Loan:           &domain.LoanSplit{Principal: 4, Interest: 0.5},
+			BankCategory:   "Restaurants",
+			Provenance:     []string{"date: posted"},
+			SourceFilePath: "/tmp/sync-1/visa.pdf",
+			SourcePage:     2,
+			SourceLine:     line,
+		}
+	}
+
+	// Two coffees on the same day are two lines
+	queue.Add("user", coffee(7), ReasonConfidence)
+	queue.Add("user", coffee(8), ReasonConfidence)
+	if len(queue.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(queue.Entries))
+	}
+	// The same statement downloaded to another folder is the same lines
+	again := coffee(8)
+	again.SourceFilePath = "/tmp/sync-2/visa.pdf"
+	queue.Add("user", again, ReasonAccount)
+	if len(queue.Entries) != 2 || queue.Entries[1].Reason != ReasonAccount {
+		t.Fatalf("entries = %+v", queue.Entries)
+	}
+	if err := queue.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := reloaded.Entries[0].Transaction()
+	want := coffee(7)
+	if !tx.PostingDate.Equal(want.PostingDate) || tx.Kind != want.Kind || *tx.Balance != balance || *tx.Original != *want.Original ||
+		*tx.Loan != *want.Loan || tx.BankCategory != want.BankCategory || len(tx.Provenance) != 1 || tx.SourcePage != 2 || tx.SourceLine != 7 {
+		t.Errorf("transaction did not survive the round trip: %+v", tx)
+	}
+	if tx.Notes() != want.Notes() {
+		t.Errorf("notes = %q, want %q", tx.Notes(), want.Notes())
+	}
+}
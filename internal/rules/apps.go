@@ -0,0 +1,161 @@
+package rules
+
+import (
+	"strings"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// appCategories translates the categories of budgeting apps to ariand category slugs, for the
+// exports imported to move away from them or, for Splitwise, to keep shared costs in ariand. It is
+// keyed by the app, as the parser names the bank, then by the app's category in lower case. The
+// apps' default categories are covered; ones a user made up in the app are left to rules.
+var appCategories = map[string]map[string]string{
+	"mint": {
+		"auto & transport":           "transport",
+		"gas & fuel":                 "gas",
+		"parking":                    "transport",
+		"public transportation":      "transport",
+		"ride share":                 "transport",
+		"service & parts":            "transport",
+		"auto insurance":             "insurance",
+		"bills & utilities":          "utilities",
+		"utilities":                  "utilities",
+		"internet":                   "utilities",
+		"television":                 "utilities",
+		"mobile phone":               "phone",
+		"home phone":                 "phone",
+		"food & dining":              "restaurants",
+		"restaurants":                "restaurants",
+		"fast food":                  "restaurants",
+		"groceries":                  "groceries",
+		"coffee shops":               "coffee",
+		"alcohol & bars":             "alcohol",
+		"entertainment":              "entertainment",
+		"movies & dvds":              "entertainment",
+		"music":                      "subscriptions",
+		"shopping":                   "shopping",
+		"clothing":                   "shopping",
+		"electronics & software":     "shopping",
+		"books":                      "shopping",
+		"health & fitness":           "health",
+		"doctor":                     "health",
+		"dentist":                    "health",
+		"gym":                        "health",
+		"pharmacy":                   "pharmacy",
+		"health insurance":           "insurance",
+		"mortgage & rent":            "rent",
+		"home":                       "home",
+		"home improvement":           "home",
+		"furnishings":                "home",
+		"travel":                     "travel",
+		"air travel":                 "travel",
+		"hotel":                      "travel",
+		"rental car & taxi":          "travel",
+		"gifts & donations":          "gifts",
+		"gift":                       "gifts",
+		"charity":                    "gifts",
+		"fees & charges":             "fees",
+		"atm fee":                    "fees",
+		"bank fee":                   "fees",
+		"finance charge":             "interest",
+		"late fee":                   "fees",
+		"service fee":                "fees",
+		"income":                     "income",
+		"paycheck":                   "income",
+		"bonus":                      "income",
+		"interest income":            "interest",
+		"taxes":                      "taxes",
+		"education":                  "education",
+		"tuition":                    "education",
+		"pets":                       "pets",
+		"cash & atm":                 "cash",
+		"atm":                        "cash",
+		"transfer":                   "transfer",
+		"credit card payment":        "transfer",
+		"transfer for cash spending": "transfer",
+	},
+	"monarch": {
+		"gas":                        "gas",
+		"auto maintenance":           "transport",
+		"parking & tolls":            "transport",
+		"public transit":             "transport",
+		"taxi & ride shares":         "transport",
+		"gas & electric":             "utilities",
+		"water":                      "utilities",
+		"garbage":                    "utilities",
+		"internet & cable":           "utilities",
+		"phone":                      "phone",
+		"groceries":                  "groceries",
+		"restaurants & bars":         "restaurants",
+		"coffee shops":               "coffee",
+		"entertainment & recreation": "entertainment",
+		"shopping":                   "shopping",
+		"clothing":                   "shopping",
+		"electronics":                "shopping",
+		"furniture & housewares":     "home",
+		"home improvement":           "home",
+		"rent":                       "rent",
+		"mortgage":                   "rent",
+		"medical":                    "health",
+		"dentist":                    "health",
+		"fitness":                    "health",
+		"insurance":                  "insurance",
+		"travel & vacation":          "travel",
+		"gifts":                      "gifts",
+		"charity":                    "gifts",
+		"financial fees":             "fees",
+		"paychecks":                  "income",
+		"other income":               "income",
+		"interest":                   "interest",
+		"taxes":                      "taxes",
+		"education":                  "education",
+		"pets":                       "pets",
+		"cash & atm":                 "cash",
+		"transfer":                   "transfer",
+		"credit card payment":        "transfer",
+	},
+	"splitwise": {
+		"groceries":          "groceries",
+		"dining out":         "restaurants",
+		"liquor":             "alcohol",
+		"rent":               "rent",
+		"mortgage":           "rent",
+		"electricity":        "utilities",
+		"heat/gas":           "utilities",
+		"water":              "utilities",
+		"trash":              "utilities",
+		"tv/phone/internet":  "utilities",
+		"household supplies": "home",
+		"furniture":          "home",
+		"maintenance":        "home",
+		"cleaning":           "home",
+		"gas/fuel":           "gas",
+		"parking":            "transport",
+		"taxi":               "transport",
+		"bus/train":          "transport",
+		"car":                "transport",
+		"plane":              "travel",
+		"hotel":              "travel",
+		"movies":             "entertainment",
+		"games":              "entertainment",
+		"sports":             "entertainment",
+		"music":              "entertainment",
+		"clothing":           "shopping",
+		"electronics":        "shopping",
+		"medical expenses":   "health",
+		"insurance":          "insurance",
+		"gifts":              "gifts",
+		"pets":               "pets",
+		"education":          "education",
+	},
+}
+
+// appCategory returns the ariand category of a line exported from a budgeting app, empty when
+// the line isn't from one or the table doesn't know its category
+func appCategory(tx *domain.Transaction) string {
+	if tx.BankCategory == "" {
+		return ""
+	}
+	return appCategories[strings.ToLower(tx.StatementBank)][strings.ToLower(strings.TrimSpace(tx.BankCategory))]
+}
@@ -0,0 +1,155 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Exclude leaves transactions matching every condition it specifies out of an import, like
+// everything from an account someone else keeps track of, or the cents a bank rounds up
+type Exclude struct {
+	// Name is what the summary calls the exclude, else its conditions are listed
+	Name string `json:"name,omitempty"`
+	// Account is a statement account number or name. Digits alone match the account numbers ending
+	// in them, so "••9876" and "9876" both match 05172-5169876.
+	Account string `json:"account,omitempty"`
+	// Under matches amounts below it, in the line's currency
+	Under float64 `json:"under,omitempty"`
+	// Transfers matches card payments and lines categorized as transfers, money moving between
+	// accounts that both end up in ariand
+	Transfers    bool          `json:"transfers,omitempty"`
+	Method       domain.Method `json:"method,omitempty"`
+	Description  string        `json:"description,omitempty"` // regular expression
+	AccountType  string        `json:"account_type,omitempty"`
+	BankCategory string        `json:"bank_category,omitempty"`
+	Category     string        `json:"category,omitempty"` // ariand category slug
+
+	rule Rule
+}
+
+func (e *Exclude) compile(n int) error {
+	if e.Account == "" && e.Under == 0 && !e.Transfers && e.Method == domain.MethodUnknown &&
+		e.Description == "" && e.AccountType == "" && e.BankCategory == "" && e.Category == "" {
+		return fmt.Errorf("exclude %d has no conditions and would leave out everything", n)
+	}
+	if e.Under < 0 {
+		return fmt.Errorf("exclude %d has a negative under", n)
+	}
+	e.rule = Rule{Method: e.Method, AccountType: e.AccountType, BankCategory: e.BankCategory}
+	if e.Description != "" {
+		re, err := regexp.Compile(e.Description)
+		if err != nil {
+			return fmt.Errorf("exclude %d has an invalid description pattern: %w", n, err)
+		}
+		e.rule.description = re
+	}
+	return nil
+}
+
+// matches reports whether every condition of the exclude holds for tx
+func (e *Exclude) matches(tx *domain.Transaction) bool {
+	if e.Account != "" && !matchesAccount(e.Account, tx) {
+		return false
+	}
+	if e.Under > 0 && tx.TxAmount >= e.Under {
+		return false
+	}
+	if e.Transfers && tx.Kind != domain.KindCardPayment && tx.Category != "transfer" {
+		return false
+	}
+	if e.Category != "" && e.Category != tx.Category {
+		return false
+	}
+	return e.rule.matches(tx)
+}
+
+// matchesAccount compares an exclude's account to the statement account of tx, ignoring the dots,
+// dashes and spaces account numbers are written with
+func matchesAccount(account string, tx *domain.Transaction) bool {
+	want := alphanumeric(account)
+	if want == "" {
+		return false
+	}
+	number := ""
+	if tx.StatementAccountNumber != nil {
+		number = alphanumeric(*tx.StatementAccountNumber)
+	}
+	if _, err := strconv.ParseUint(want, 10, 64); err == nil && number != "" {
+		return strings.HasSuffix(number, want)
+	}
+	return strings.EqualFold(want, number) || strings.EqualFold(want, alphanumeric(tx.StatementAccountName))
+}
+
+func alphanumeric(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// String names the exclude in the summary
+func (e *Exclude) String() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	var conditions []string
+	if e.Account != "" {
+		conditions = append(conditions, "account "+e.Account)
+	}
+	if e.Under > 0 {
+		conditions = append(conditions, "under "+strconv.FormatFloat(e.Under, 'f', -1, 64))
+	}
+	if e.Transfers {
+		conditions = append(conditions, "transfers")
+	}
+	if e.Method != domain.MethodUnknown {
+		conditions = append(conditions, "method "+string(e.Method))
+	}
+	if e.Description != "" {
+		conditions = append(conditions, "description "+e.Description)
+	}
+	if e.AccountType != "" {
+		conditions = append(conditions, "account type "+e.AccountType)
+	}
+	if e.BankCategory != "" {
+		conditions = append(conditions, "bank category "+e.BankCategory)
+	}
+	if e.Category != "" {
+		conditions = append(conditions, "category "+e.Category)
+	}
+	return strings.Join(conditions, ", ")
+}
+
+// Exclude leaves out the transactions an exclude matches and counts them by the first exclude that
+// matched each one
+func (s *Set) Exclude(transactions []*domain.Transaction) ([]*domain.Transaction, map[string]int) {
+	if len(s.Excludes) == 0 {
+		return transactions, nil
+	}
+	var excluded map[string]int
+	kept := make([]*domain.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		matched := false
+		for i := range s.Excludes {
+			if s.Excludes[i].matches(tx) {
+				if excluded == nil {
+					excluded = make(map[string]int)
+				}
+				excluded[s.Excludes[i].String()]++
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			kept = append(kept, tx)
+		}
+	}
+	return kept, excluded
+}
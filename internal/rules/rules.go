@@ -0,0 +1,238 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Rule sets a category, a direction or both on transactions matching every condition it specifies
+type Rule struct {
+	Method      domain.Method `json:"method,omitempty"`
+	Description string        `json:"description,omitempty"` // regular expression
+	AccountType string        `json:"account_type,omitempty"`
+	// BankCategory is the category the bank's export gave the line, matched ignoring case
+	BankCategory string `json:"bank_category,omitempty"`
+	Category     string `json:"category,omitempty"` // ariand category slug
+	// Direction is "in" or "out", for lines a bank lists the wrong way round, like refunds printed
+	// as charges
+	Direction string `json:"direction,omitempty"`
+
+	description *regexp.Regexp
+}
+
+// DefaultRules apply when there is no rules file yet
+var DefaultRules = []Rule{
+	{Method: domain.MethodATM, Category: "cash"},
+}
+
+// Set is the ordered rule list from the rules file; the first matching rule wins. Excludes leave
+// lines out of imports altogether.
+type Set struct {
+	filePath string
+	Rules    []Rule    `json:"rules"`
+	Excludes []Exclude `json:"excludes,omitempty"`
+}
+
+// NewSet loads the rules file from the working directory, falling back to DefaultRules
+func NewSet() (*Set, error) {
+	return NewSetIn("")
+}
+
+// NewSetIn loads the rules file from dir, the working directory when empty
+func NewSetIn(dir string) (*Set, error) {
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+		dir = cwd
+	}
+
+	return LoadSet(filepath.Join(dir, "arian-rules.json"))
+}
+
+// LoadSet loads the rules file at path, falling back to DefaultRules when there is none
+func LoadSet(path string) (*Set, error) {
+	set := &Set{filePath: path}
+
+	if _, err := os.Stat(set.filePath); err == nil {
+		if err := set.Load(); err != nil {
+			return nil, err
+		}
+	} else {
+		set.Rules = append([]Rule(nil), DefaultRules...)
+	}
+
+	return set, set.compile()
+}
+
+// Load reads rules from disk
+func (s *Set) Load() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return fmt.Errorf("failed to parse rules file: %w", err)
+	}
+
+	return nil
+}
+
+// Save writes rules to disk
+func (s *Set) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode rules: %w", err)
+	}
+
+	if err := os.WriteFile(s.filePath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write rules file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Set) compile() error {
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		if rule.Category == "" && rule.Direction == "" {
+			return fmt.Errorf("rule %d has no category or direction", i+1)
+		}
+		if rule.Direction != "" && rule.Direction != domain.In.String() && rule.Direction != domain.Out.String() {
+			return fmt.Errorf("rule %d has direction %q, expected in or out", i+1, rule.Direction)
+		}
+		if rule.Description == "" {
+			continue
+		}
+		re, err := regexp.Compile(rule.Description)
+		if err != nil {
+			return fmt.Errorf("rule %d has an invalid description pattern: %w", i+1, err)
+		}
+		rule.description = re
+	}
+	for i := range s.Excludes {
+		if err := s.Excludes[i].compile(i + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches reports whether every condition of the rule holds for tx
+func (r *Rule) matches(tx *domain.Transaction) bool {
+	if r.Method != domain.MethodUnknown && r.Method != tx.Method {
+		return false
+	}
+	if r.AccountType != "" && r.AccountType != tx.StatementAccountType {
+		return false
+	}
+	if r.BankCategory != "" && !strings.EqualFold(r.BankCategory, tx.BankCategory) {
+		return false
+	}
+	if r.description != nil && !r.description.MatchString(tx.TxDesc) {
+		return false
+	}
+	return true
+}
+
+// Add appends a rule and saves the rules file
+func (s *Set) Add(rule Rule) error {
+	s.Rules = append(s.Rules, rule)
+	if err := s.compile(); err != nil {
+		s.Rules = s.Rules[:len(s.Rules)-1]
+		return err
+	}
+	return s.Save()
+}
+
+// PatternFor suggests a description pattern for lines like description: its first few words, up to
+// the first store or reference number, so other visits to the same place match too
+func PatternFor(description string) string {
+	var words []string
+	anchored := true
+	for _, word := range strings.Fields(description) {
+		if strings.HasPrefix(word, "#") || !strings.ContainsFunc(word, unicode.IsLetter) {
+			if len(words) > 0 {
+				break
+			}
+			anchored = false
+			continue
+		}
+		words = append(words, regexp.QuoteMeta(word))
+		if len(words) == 3 {
+			break
+		}
+	}
+	if len(words) == 0 {
+		return "(?i)^" + regexp.QuoteMeta(strings.TrimSpace(description)) + "$"
+	}
+
+	pattern := "(?i)" + strings.Join(words, `\s+`)
+	if anchored {
+		pattern = "(?i)^" + strings.Join(words, `\s+`)
+	}
+	// A word boundary keeps "BAR" from matching "BARBER", but only works after a letter or digit
+	last := []rune(words[len(words)-1])
+	if r := last[len(last)-1]; unicode.IsLetter(r) || unicode.IsDigit(r) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+// Apply categorizes transactions that have no category yet and returns how many it changed. Lines
+// from a budgeting app's export no rule matched get the app's category, translated.
+func (s *Set) Apply(transactions []*domain.Transaction) int {
+	applied := 0
+	for _, tx := range transactions {
+		if tx.Category != "" || tx.CategoryID != nil {
+			continue
+		}
+		for i := range s.Rules {
+			if s.Rules[i].Category != "" && s.Rules[i].matches(tx) {
+				tx.Category = s.Rules[i].Category
+				break
+			}
+		}
+		if tx.Category == "" {
+			tx.Category = appCategory(tx)
+		}
+		if tx.Category != "" {
+			applied++
+		}
+	}
+	return applied
+}
+
+// ApplyDirections sets the direction of transactions the first rule with a direction matches, and
+// returns those it turned around. Each keeps a note of the rule that did it. This runs before
+// anything that goes by direction, like card payment policies, while Apply runs later.
+func (s *Set) ApplyDirections(transactions []*domain.Transaction) []*domain.Transaction {
+	var changed []*domain.Transaction
+	for _, tx := range transactions {
+		for i := range s.Rules {
+			rule := &s.Rules[i]
+			if rule.Direction == "" || !rule.matches(tx) {
+				continue
+			}
+			if rule.Direction != tx.TxDirection.String() {
+				tx.TxDirection = domain.Out
+				if rule.Direction == domain.In.String() {
+					tx.TxDirection = domain.In
+				}
+				tx.Provenance = append(tx.Provenance, fmt.Sprintf("direction: %s, by rule %d", rule.Direction, i+1))
+				changed = append(changed, tx)
+			}
+			break
+		}
+	}
+	return changed
+}
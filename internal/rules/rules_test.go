@@ -0,0 +1,177 @@
+package rules
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestPatternFor(t *testing.T) {
+	tests := []struct {
+		description string
+		pattern     string
+		matches     []string
+		misses      []string
+	}{
+		{"BLUE BOTTLE #0042 TORONTO ON", `(?i)^BLUE\s+BOTTLE\b`, []string{"Blue Bottle 17 Montreal"}, []string{"BLUE BOTTLES"}},
+		{"SQ *GROWLER BAR 4412", `(?i)^SQ\s+\*GROWLER\s+BAR\b`, []string{"SQ *GROWLER BAR 7"}, []string{"SQ *GROWLER BARBER"}},
+		{"0042 NETFLIX.COM", `(?i)NETFLIX\.COM\b`, []string{"1234 NETFLIX.COM"}, nil},
+		{"12345", `(?i)^12345$`, nil, []string{"123456"}},
+	}
+
+	for _, tt := range tests {
+		pattern := PatternFor(tt.description)
+		if pattern != tt.pattern {
+			t.Errorf("PatternFor(%q) = %q, want %q", tt.description, pattern, tt.pattern)
+			continue
+		}
+		re := regexp.MustCompile(pattern)
+		for _, s := range append(tt.matches, tt.description) {
+			if !re.MatchString(s) {
+				t.Errorf("%s does not match %q", pattern, s)
+			}
+		}
+		for _, s := range tt.misses {
+			if re.MatchString(s) {
+				t.Errorf("%s matches %q", pattern, s)
+			}
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	set := &Set{filePath: filepath.Join(t.TempDir(), "arian-rules.json"), Rules: append([]Rule(nil), DefaultRules...)}
+	if err := set.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := set.Add(Rule{Description: "(", Category: "coffee"}); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+	if err := set.Add(Rule{Description: PatternFor("BLUE BOTTLE #0042"), Category: "coffee"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := &Set{filePath: set.filePath}
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Rules) != 2 {
+		t.Fatalf("got %d rules after reload, want the default and the new one", len(reloaded.Rules))
+	}
+
+	tx := &domain.Transaction{TxDesc: "BLUE BOTTLE #0099"}
+	if reloaded.Apply([]*domain.Transaction{tx}); tx.Category != "coffee" {
+		t.Errorf("new rule did not categorize %q, got %q", tx.TxDesc, tx.Category)
+	}
+}
+
+func TestBankCategory(t *testing.T) {
+	set := &Set{Rules: []Rule{{BankCategory: "eating_out", Category: "dining"}}}
+	if err := set.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := []*domain.Transaction{
+		{TxDesc: "PRET A MANGER", BankCategory: "Eating_Out"},
+		{TxDesc: "TESCO", BankCategory: "groceries"},
+		{TxDesc: "COFFEE"},
+	}
+	if applied := set.Apply(transactions); applied != 1 || transactions[0].Category != "dining" {
+		t.Fatalf("applied %d, categories %q, %q, %q", applied, transactions[0].Category, transactions[1].Category, transactions[2].Category)
+	}
+}
+
+func TestAppCategory(t *testing.T) {
+	set := &Set{Rules: []Rule{{BankCategory: "coffee shops", Category: "snacks"}}}
+	if err := set.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := []*domain.Transaction{
+		{TxDesc: "Loblaws", StatementBank: "Mint", BankCategory: "Groceries"},
+		// A rule on the app's category comes first
+		{TxDesc: "Starbucks", StatementBank: "Monarch", BankCategory: "Coffee Shops"},
+		{TxDesc: "Gift for mom", StatementBank: "Mint", BankCategory: "Mom"},
+		{TxDesc: "TESCO", StatementBank: "Monzo", BankCategory: "groceries"},
+	}
+	if applied := set.Apply(transactions); applied != 2 {
+		t.Fatalf("applied %d", applied)
+	}
+	for i, want := range []string{"groceries", "snacks", "", ""} {
+		if got := transactions[i].Category; got != want {
+			t.Errorf("%s: category %q, want %q", transactions[i].TxDesc, got, want)
+		}
+	}
+}
+
+func TestApplyDirections(t *testing.T) {
+	set := &Set{Rules: []Rule{
+		{Description: "(?i)refund|return", AccountType: "visa", Direction: "in"},
+		{Description: "(?i)return", Category: "shopping"},
+	}}
+	if err := set.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	refund := &domain.Transaction{TxDesc: "RETURN - OUTDOOR CO", TxDirection: domain.Out, StatementAccountType: "visa"}
+	credited := &domain.Transaction{TxDesc: "REFUND AMAZON", TxDirection: domain.In, StatementAccountType: "visa"}
+	chequing := &domain.Transaction{TxDesc: "RETURN ITEM FEE", TxDirection: domain.Out, StatementAccountType: "chequing"}
+
+	changed := set.ApplyDirections([]*domain.Transaction{refund, credited, chequing})
+	if len(changed) != 1 || changed[0] != refund || refund.TxDirection != domain.In {
+		t.Fatalf("changed %d lines, refund direction %v", len(changed), refund.TxDirection)
+	}
+	if len(refund.Provenance) != 1 || refund.Provenance[0] != "direction: in, by rule 1" {
+		t.Fatalf("provenance = %q", refund.Provenance)
+	}
+	if credited.Provenance != nil || chequing.TxDirection != domain.Out {
+		t.Fatalf("lines the rule left alone changed: %+v, %+v", credited, chequing)
+	}
+
+	// The direction rule has no category, so the category comes from the next rule
+	set.Apply([]*domain.Transaction{refund})
+	if refund.Category != "shopping" {
+		t.Fatalf("category = %q, want shopping", refund.Category)
+	}
+
+	if err := (&Set{Rules: []Rule{{Direction: "sideways"}}}).compile(); err == nil {
+		t.Fatal("a direction other than in or out was accepted")
+	}
+}
+
+func TestExclude(t *testing.T) {
+	set := &Set{Excludes: []Exclude{
+		{Name: "partner's card", Account: "••9876"},
+		{Under: 0.05},
+		{Transfers: true},
+	}}
+	if err := set.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	partner, chequing := "4510-1234-5678-9876", "05172-5163878"
+	transactions := []*domain.Transaction{
+		{TxDesc: "GROCERY", TxAmount: 80, StatementAccountNumber: &partner},
+		{TxDesc: "ROUND UP", TxAmount: 0.02, StatementAccountNumber: &chequing},
+		{TxDesc: "PAYMENT THANK YOU", TxAmount: 500, Kind: domain.KindCardPayment, StatementAccountNumber: &chequing},
+		{TxDesc: "TO SAVINGS", TxAmount: 200, Category: "transfer", StatementAccountNumber: &chequing},
+		{TxDesc: "COFFEE", TxAmount: 4.5, StatementAccountNumber: &chequing},
+	}
+	kept, excluded := set.Exclude(transactions)
+	if len(kept) != 1 || kept[0].TxDesc != "COFFEE" {
+		t.Fatalf("kept %v", kept)
+	}
+	if excluded["partner's card"] != 1 || excluded["under 0.05"] != 1 || excluded["transfers"] != 2 {
+		t.Fatalf("excluded = %v", excluded)
+	}
+
+	if err := (&Set{Excludes: []Exclude{{Name: "everything"}}}).compile(); err == nil {
+		t.Fatal("an exclude without conditions was accepted")
+	}
+}
@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"step from a value", "5/15 * * * *", at(2024, 9, 2, 10, 0), at(2024, 9, 2, 10, 5)},
+		{"step from a value wraps the hour", "5/15 * * * *", at(2024, 9, 2, 10, 50), at(2024, 9, 2, 11, 5)},
+		{"step over everything", "*/30 * * * *", at(2024, 9, 2, 10, 0), at(2024, 9, 2, 10, 30)},
+		{"strictly after", "0 7 * * *", at(2024, 9, 2, 7, 0), at(2024, 9, 3, 7, 0)},
+		{"ranges skip the weekend", "0 8-18 * * 1-5", at(2024, 9, 6, 18, 30), at(2024, 9, 9, 8, 0)},
+		{"7 is Sunday", "0 8 * * 7", at(2024, 9, 2, 0, 0), at(2024, 9, 8, 8, 0)},
+		{"day of month or day of week", "0 9 10 * 5", at(2024, 9, 7, 0, 0), at(2024, 9, 10, 9, 0)},
+		{"day of week or day of month", "0 9 10 * 5", at(2024, 9, 10, 9, 0), at(2024, 9, 13, 9, 0)},
+		{"leap day", "0 0 29 2 *", at(2024, 3, 1, 0, 0), at(2028, 2, 29, 0, 0)},
+		{"macro", "@monthly", at(2024, 9, 2, 0, 0), at(2024, 10, 1, 0, 0)},
+		{"never fires", "0 0 30 2 *", at(2024, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from.Format(time.RFC3339), got.Format(time.RFC3339), tt.want.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"* * * * 8",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) accepted it", expr)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DropboxKeyring is the keyring account holding the Dropbox refresh token
+const DropboxKeyring = "dropbox"
+
+// DropboxOAuth returns the OAuth client config for a Dropbox app
+func DropboxOAuth(appKey, appSecret string) OAuthConfig {
+	return OAuthConfig{
+		AuthURL:      "https://www.dropbox.com/oauth2/authorize",
+		TokenURL:     "https://api.dropboxapi.com/oauth2/token",
+		ClientID:     appKey,
+		ClientSecret: appSecret,
+		ExtraParams:  map[string]string{"token_access_type": "offline"},
+		KeyringName:  DropboxKeyring,
+	}
+}
+
+// Dropbox reads statements from a single Dropbox folder
+type Dropbox struct {
+	folder string
+	oauth  *oauthClient
+}
+
+func NewDropbox(folder, appKey, appSecret string) (*Dropbox, error) {
+	if appKey == "" {
+		return nil, fmt.Errorf("dropbox app key is required")
+	}
+
+	// The API expects "" for the root and a leading slash everywhere else
+	if folder == "/" {
+		folder = ""
+	} else if folder != "" && folder[0] != '/' {
+		folder = "/" + folder
+	}
+
+	return &Dropbox{
+		folder: folder,
+		oauth:  newOAuthClient(DropboxOAuth(appKey, appSecret)),
+	}, nil
+}
+
+func (d *Dropbox) Name() string {
+	return "dropbox:" + d.folder
+}
+
+type dropboxListResult struct {
+	Entries []struct {
+		Tag            string    `json:".tag"`
+		ID             string    `json:"id"`
+		Name           string    `json:"name"`
+		Size           int64     `json:"size"`
+		ServerModified time.Time `json:"server_modified"`
+	} `json:"entries"`
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+func (d *Dropbox) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+
+	endpoint := "https://api.dropboxapi.com/2/files/list_folder"
+	var body any = map[string]any{"path": d.folder}
+
+	for {
+		var result dropboxListResult
+		if err := d.rpc(ctx, endpoint, body, &result); err != nil {
+			return nil, err
+		}
+
+		for _, e := range result.Entries {
+			if e.Tag != "file" {
+				continue
+			}
+			objects = append(objects, Object{Key: e.ID, Name: e.Name, Size: e.Size, ModTime: e.ServerModified})
+		}
+
+		if !result.HasMore {
+			break
+		}
+		endpoint = "https://api.dropboxapi.com/2/files/list_folder/continue"
+		body = map[string]string{"cursor": result.Cursor}
+	}
+
+	return objects, nil
+}
+
+func (d *Dropbox) Download(ctx context.Context, key string, w io.Writer) error {
+	arg, err := json.Marshal(map[string]string{"path": key})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://content.dropboxapi.com/2/files/download", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Dropbox-API-Arg", string(arg))
+
+	resp, err := d.oauth.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (d *Dropbox) rpc(ctx context.Context, endpoint string, body any, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.oauth.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode dropbox response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local treats a directory on disk as a source so repeated scans only pick up new files
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) (*Local, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory %s: %w", dir, err)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", abs, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", abs)
+	}
+
+	return &Local{dir: abs}, nil
+}
+
+func (l *Local) Name() string {
+	return "local:" + l.dir
+}
+
+func (l *Local) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", l.dir, err)
+	}
+
+	var objects []Object
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue // removed while scanning
+		}
+		objects = append(objects, Object{Key: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+
+	return objects, nil
+}
+
+func (l *Local) Download(ctx context.Context, key string, w io.Writer) error {
+	file, err := os.Open(filepath.Join(l.dir, key))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// Dir returns the absolute path of the folder
+func (l *Local) Dir() string {
+	return l.dir
+}
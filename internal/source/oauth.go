@@ -0,0 +1,225 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"arian-statement-parser/internal/keyring"
+)
+
+// OAuthConfig describes an OAuth 2.0 client for a cloud storage provider
+type OAuthConfig struct {
+	AuthURL      string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	ExtraParams  map[string]string // provider specific auth URL params
+	KeyringName  string            // keyring account holding the refresh token
+	Loopback     bool              // use a localhost redirect instead of pasting the code
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+// oauthClient keeps a fresh access token derived from the refresh token in the keyring
+type oauthClient struct {
+	cfg    OAuthConfig
+	http   *http.Client
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newOAuthClient(cfg OAuthConfig) *oauthClient {
+	return &oauthClient{cfg: cfg, http: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+// do sends req with a bearer token and fails on non-2xx responses
+func (c *oauthClient) do(req *http.Request) (*http.Response, error) {
+	token, err := c.accessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+func (c *oauthClient) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expiry.Add(-time.Minute)) {
+		return c.token, nil
+	}
+
+	refresh, err := keyring.Get(c.cfg.KeyringName)
+	if err != nil {
+		return "", fmt.Errorf("no %s token, run with -login first: %w", c.cfg.KeyringName, err)
+	}
+
+	tok, err := exchange(ctx, c.http, c.cfg, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refresh},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.token = tok.AccessToken
+	c.expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// Login runs the interactive authorization code flow and stores the refresh token in the keyring
+func Login(ctx context.Context, cfg OAuthConfig) error {
+	state := randomState()
+	params := url.Values{
+		"client_id":     {cfg.ClientID},
+		"response_type": {"code"},
+		"state":         {state},
+	}
+	if len(cfg.Scopes) > 0 {
+		params.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	for k, v := range cfg.ExtraParams {
+		params.Set(k, v)
+	}
+
+	var (
+		code        string
+		redirectURI string
+		err         error
+	)
+	if cfg.Loopback {
+		code, redirectURI, err = loopbackCode(ctx, cfg, params, state)
+	} else {
+		fmt.Printf("open this URL, approve access and paste the code below:\n\n  %s?%s\n\ncode: ", cfg.AuthURL, params.Encode())
+		code, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		code = strings.TrimSpace(code)
+	}
+	if err != nil {
+		return err
+	}
+	if code == "" {
+		return fmt.Errorf("no authorization code received")
+	}
+
+	form := url.Values{
+		"grant_type": {"authorization_code"},
+		"code":       {code},
+	}
+	if redirectURI != "" {
+		form.Set("redirect_uri", redirectURI)
+	}
+
+	tok, err := exchange(ctx, http.DefaultClient, cfg, form)
+	if err != nil {
+		return err
+	}
+	if tok.RefreshToken == "" {
+		return fmt.Errorf("provider did not return a refresh token")
+	}
+
+	return keyring.Set(cfg.KeyringName, tok.RefreshToken)
+}
+
+// loopbackCode catches the redirect on a random localhost port
+func loopbackCode(ctx context.Context, cfg OAuthConfig, params url.Values, state string) (string, string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to start callback listener: %w", err)
+	}
+	defer listener.Close()
+
+	redirectURI := "http://" + listener.Addr().String()
+	params.Set("redirect_uri", redirectURI)
+
+	codes := make(chan string, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "authorized, you can close this tab")
+		select {
+		case codes <- r.URL.Query().Get("code"):
+		default:
+		}
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	fmt.Printf("open this URL and approve access:\n\n  %s?%s\n\n", cfg.AuthURL, params.Encode())
+
+	select {
+	case code := <-codes:
+		return code, redirectURI, nil
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+}
+
+func exchange(ctx context.Context, client *http.Client, cfg OAuthConfig, form url.Values) (*tokenResponse, error) {
+	form.Set("client_id", cfg.ClientID)
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tok tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tok.Error != "" || tok.AccessToken == "" {
+		return nil, fmt.Errorf("token request rejected: %s %s", tok.Error, tok.Description)
+	}
+
+	return &tok, nil
+}
+
+func randomState() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,130 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// SFTPConfig holds connection settings for an SFTP server
+type SFTPConfig struct {
+	Host         string
+	Port         string
+	User         string
+	Dir          string
+	IdentityFile string // optional, otherwise the ssh agent/default keys are used
+}
+
+// SFTP reads statements from a remote directory using the system sftp client in batch mode
+type SFTP struct {
+	cfg      SFTPConfig
+	sftpPath string
+}
+
+func NewSFTP(cfg SFTPConfig) (*SFTP, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("sftp host is required")
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "."
+	}
+
+	sftpPath, err := exec.LookPath("sftp")
+	if err != nil {
+		return nil, fmt.Errorf("sftp client not found in PATH: %w", err)
+	}
+
+	return &SFTP{cfg: cfg, sftpPath: sftpPath}, nil
+}
+
+func (s *SFTP) Name() string {
+	return "sftp:" + s.target() + ":" + s.cfg.Dir
+}
+
+func (s *SFTP) List(ctx context.Context) ([]Object, error) {
+	output, err := s.run(ctx, fmt.Sprintf("ls -1 %s\n", quoteSFTP(s.cfg.Dir)))
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "sftp>") {
+			continue // batch mode echoes each command
+		}
+
+		key := line
+		if !strings.Contains(line, "/") {
+			key = path.Join(s.cfg.Dir, line)
+		}
+		objects = append(objects, Object{Key: key})
+	}
+
+	return objects, nil
+}
+
+func (s *SFTP) Download(ctx context.Context, key string, w io.Writer) error {
+	tmp, err := os.CreateTemp("", "arian-sftp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if _, err := s.run(ctx, fmt.Sprintf("get %s %s\n", quoteSFTP(key), quoteSFTP(tmpPath))); err != nil {
+		return err
+	}
+
+	file, err := os.Open(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to open downloaded file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (s *SFTP) target() string {
+	if s.cfg.User != "" {
+		return s.cfg.User + "@" + s.cfg.Host
+	}
+	return s.cfg.Host
+}
+
+// run executes a batch script; -b makes sftp abort on the first failing command
+func (s *SFTP) run(ctx context.Context, script string) (string, error) {
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if s.cfg.Port != "" {
+		args = append(args, "-P", s.cfg.Port)
+	}
+	if s.cfg.IdentityFile != "" {
+		args = append(args, "-i", s.cfg.IdentityFile)
+	}
+	args = append(args, s.target())
+
+	cmd := exec.CommandContext(ctx, s.sftpPath, args...)
+	cmd.Stdin = strings.NewReader(script)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("sftp failed: %w\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// quoteSFTP quotes a path for the sftp batch command parser
+func quoteSFTP(p string) string {
+	return `"` + strings.ReplaceAll(p, `"`, `\"`) + `"`
+}
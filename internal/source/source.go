@@ -0,0 +1,134 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"arian-statement-parser/internal/state"
+)
+
+// Object describes a statement file available from a remote source
+type Object struct {
+	Key     string
+	Name    string // file name when the key is an opaque ID, defaults to the key
+	Size    int64
+	ModTime time.Time
+}
+
+func (o Object) fileName() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return o.Key
+}
+
+// Source lists and downloads statement files from somewhere other than local disk
+type Source interface {
+	// Name identifies the source in the state store, e.g. "s3:bucket/prefix"
+	Name() string
+	List(ctx context.Context) ([]Object, error)
+	Download(ctx context.Context, key string, w io.Writer) error
+}
+
+// Fetched links a downloaded file to the remote key it came from
+type Fetched struct {
+	Key       string
+	LocalPath string
+}
+
+// statementExts are the file types the parsers read: PDF statements, CSV activity exports, OFX
+// downloads, text statements for templates and ariand backups
+var statementExts = map[string]bool{
+	".pdf":  true,
+	".csv":  true,
+	".ofx":  true,
+	".qfx":  true,
+	".txt":  true,
+	".json": true,
+}
+
+// Sync downloads every statement from src that is not yet marked processed into dir. It fails
+// before downloading anything when two of them would get the same local name.
+func Sync(ctx context.Context, src Source, store *state.Store, dir string) ([]Fetched, error) {
+	objects, err := src.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", src.Name(), err)
+	}
+
+	var fetched []Fetched
+	keys := make(map[string]string) // local name -> key
+	for _, obj := range objects {
+		if !statementExts[strings.ToLower(path.Ext(obj.fileName()))] {
+			continue // not something the parsers understand
+		}
+		if store.IsProcessed(src.Name(), obj.Key) {
+			continue
+		}
+
+		name := obj.localName()
+		if other, ok := keys[name]; ok {
+			return nil, fmt.Errorf("%s and %s of %s would both be downloaded as %s", other, obj.Key, src.Name(), name)
+		}
+		keys[name] = obj.Key
+		fetched = append(fetched, Fetched{Key: obj.Key, LocalPath: filepath.Join(dir, name)})
+	}
+
+	for _, f := range fetched {
+		if err := download(ctx, src, f.Key, f.LocalPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return fetched, nil
+}
+
+// MarkProcessed records all fetched keys as imported
+func MarkProcessed(store *state.Store, src Source, fetched []Fetched) error {
+	keys := make([]string, 0, len(fetched))
+	for _, f := range fetched {
+		keys = append(keys, f.Key)
+	}
+	return store.MarkProcessed(src.Name(), keys...)
+}
+
+func download(ctx context.Context, src Source, key, localPath string) error {
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", localPath, err)
+	}
+	defer file.Close()
+
+	if err := src.Download(ctx, key, file); err != nil {
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// localName is the file name obj is downloaded as. Keys that are paths are flattened, which keeps
+// them apart. Display names, which two files in a drive may share, get the ID in front.
+func (o Object) localName() string {
+	if o.Name == "" {
+		return flatten(o.Key)
+	}
+	id := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, o.Key)
+	return id + "_" + flatten(o.Name)
+}
+
+// flatten turns a remote path into a file name
+func flatten(key string) string {
+	key = strings.TrimPrefix(key, "/")
+	return strings.ReplaceAll(key, "/", "_")
+}
@@ -0,0 +1,60 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"arian-statement-parser/internal/state"
+)
+
+// drive is a source whose files have IDs for keys, like Google Drive
+type drive struct{ objects []Object }
+
+func (d *drive) Name() string                           { return "drive:test" }
+func (d *drive) List(context.Context) ([]Object, error) { return d.objects, nil }
+func (d *drive) Download(_ context.Context, key string, w io.Writer) error {
+	_, err := fmt.Fprintf(w, "contents of %s", key)
+	return err
+}
+
+func TestSyncSameNames(t *testing.T) {
+	store := &state.Store{}
+	src := &drive{objects: []Object{{Key: "id:a1", Name: "statement.pdf"}, {Key: "id:b2", Name: "statement.pdf"}}}
+	dir := t.TempDir()
+
+	fetched, err := Sync(context.Background(), src, store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 2 || fetched[0].LocalPath == fetched[1].LocalPath {
+		t.Fatalf("fetched = %+v", fetched)
+	}
+	for _, f := range fetched {
+		data, err := os.ReadFile(f.LocalPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, []byte("contents of "+f.Key)) {
+			t.Errorf("%s holds %q", f.LocalPath, data)
+		}
+	}
+	if got := filepath.Base(fetched[0].LocalPath); got != "id_a1_statement.pdf" {
+		t.Errorf("local name = %s", got)
+	}
+
+	// Paths flatten to names that can still clash
+	src = &drive{objects: []Object{{Key: "a/b_c.pdf"}, {Key: "a_b/c.pdf"}}}
+	dir = t.TempDir()
+	if _, err := Sync(context.Background(), src, store, dir); err == nil || !strings.Contains(err.Error(), "a_b_c.pdf") {
+		t.Fatalf("err = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("downloaded %d files before failing", len(entries))
+	}
+}
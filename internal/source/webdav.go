@@ -0,0 +1,145 @@
+package source
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// WebDAVConfig holds connection settings for a WebDAV share, e.g. a Nextcloud folder
+type WebDAVConfig struct {
+	URL      string // folder URL, e.g. https://cloud.example.com/remote.php/dav/files/me/Scans
+	Username string
+	Password string
+}
+
+// WebDAV reads statements from a single WebDAV collection
+type WebDAV struct {
+	cfg    WebDAVConfig
+	base   *url.URL
+	client *http.Client
+}
+
+func NewWebDAV(cfg WebDAVConfig) (*WebDAV, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webdav url is required")
+	}
+
+	base, err := url.Parse(strings.TrimRight(cfg.URL, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid webdav url: %w", err)
+	}
+
+	return &WebDAV{
+		cfg:    cfg,
+		base:   base,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (d *WebDAV) Name() string {
+	return "webdav:" + d.base.String()
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop><d:getcontentlength/><d:getlastmodified/><d:resourcetype/></d:prop>
+</d:propfind>`
+
+func (d *WebDAV) List(ctx context.Context) ([]Object, error) {
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", d.base.String(), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	// Depth infinity is disabled on most servers, so only the folder itself is scanned
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to decode webdav listing: %w", err)
+	}
+
+	var objects []Object
+	for _, r := range ms.Responses {
+		if len(r.Propstat) == 0 || r.Propstat[0].Prop.ResourceType.Collection != nil {
+			continue // skip the folder itself and subfolders
+		}
+
+		name, err := url.PathUnescape(path.Base(r.Href))
+		if err != nil {
+			continue
+		}
+
+		prop := r.Propstat[0].Prop
+		modTime, _ := http.ParseTime(prop.LastModified)
+		objects = append(objects, Object{Key: name, Size: prop.ContentLength, ModTime: modTime})
+	}
+
+	return objects, nil
+}
+
+func (d *WebDAV) Download(ctx context.Context, key string, w io.Writer) error {
+	fileURL := d.base.ResolveReference(&url.URL{Path: key})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// do adds credentials and fails on non-2xx responses
+func (d *WebDAV) do(req *http.Request) (*http.Response, error) {
+	if d.cfg.Username != "" {
+		req.SetBasicAuth(d.cfg.Username, d.cfg.Password)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("webdav request failed: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("webdav returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
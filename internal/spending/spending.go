@@ -0,0 +1,136 @@
+package spending
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Month is the spending in one currency during one calendar month
+type Month struct {
+	Month      string  `json:"month"` // YYYY-MM
+	Currency   string  `json:"currency"`
+	In         float64 `json:"in"`
+	Out        float64 `json:"out"`
+	Categories []Total `json:"categories"`
+	Merchants  []Total `json:"merchants"`
+}
+
+// Total is the money spent at one merchant or in one category, largest first
+type Total struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+	Count  int     `json:"count"`
+}
+
+// Summarize groups money out by month, currency, category and merchant. Card payments, and lines in
+// the skipped categories (transfers, say), move money between your own accounts, so they count
+// neither as spending nor as income.
+func Summarize(transactions []*domain.Transaction, skip map[string]bool) []Month {
+	type key struct{ month, currency string }
+	months := make(map[key]*Month)
+	categories := make(map[key]map[string]*Total)
+	merchants := make(map[key]map[string]*Total)
+
+	for _, tx := range transactions {
+		if tx.Kind == domain.KindCardPayment || skip[tx.Category] {
+			continue
+		}
+
+		k := key{tx.TxDate.Format("2006-01"), tx.TxCurrency}
+		month, ok := months[k]
+		if !ok {
+			month = &Month{Month: k.month, Currency: k.currency}
+			months[k] = month
+			categories[k] = make(map[string]*Total)
+			merchants[k] = make(map[string]*Total)
+		}
+
+		if tx.TxDirection == domain.In {
+			month.In += tx.TxAmount
+			continue
+		}
+		month.Out += tx.TxAmount
+
+		category := tx.Category
+		if category == "" {
+			category = "uncategorized"
+		}
+		add(categories[k], category, tx.TxAmount)
+		add(merchants[k], Merchant(tx), tx.TxAmount)
+	}
+
+	result := make([]Month, 0, len(months))
+	for k, month := range months {
+		month.Categories = sorted(categories[k])
+		month.Merchants = sorted(merchants[k])
+		result = append(result, *month)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Month != result[j].Month {
+			return result[i].Month < result[j].Month
+		}
+		return result[i].Currency < result[j].Currency
+	})
+	return result
+}
+
+func add(totals map[string]*Total, name string, amount float64) {
+	if totals[name] == nil {
+		totals[name] = &Total{Name: name}
+	}
+	totals[name].Amount += amount
+	totals[name].Count++
+}
+
+func sorted(totals map[string]*Total) []Total {
+	list := make([]Total, 0, len(totals))
+	for _, total := range totals {
+		list = append(list, *total)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Amount != list[j].Amount {
+			return list[i].Amount > list[j].Amount
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// merchantWords is how many words of a description name the merchant
+const merchantWords = 3
+
+// Merchant names who got paid. Statements have no merchant column, so it is the start of the
+// description without store numbers, card references and processor prefixes like "SQ *".
+func Merchant(tx *domain.Transaction) string {
+	if tx.Merchant != "" {
+		return tx.Merchant
+	}
+
+	description := strings.ToUpper(tx.TxDesc)
+	// "SQ *BLUE BOTTLE" and "PAYPAL *STEAM" are paid through a processor, the merchant comes after it
+	if i := strings.Index(description, "*"); i >= 0 && i < 12 {
+		description = description[i+1:]
+	}
+
+	// A store number ends the name, whatever follows it is usually the city
+	var words []string
+	for _, word := range strings.Fields(description) {
+		if strings.HasPrefix(word, "#") || !strings.ContainsFunc(word, unicode.IsLetter) {
+			if len(words) > 0 {
+				break
+			}
+			continue
+		}
+		words = append(words, word)
+		if len(words) == merchantWords {
+			break
+		}
+	}
+	if len(words) == 0 {
+		return strings.TrimSpace(tx.TxDesc)
+	}
+	return strings.Join(words, " ")
+}
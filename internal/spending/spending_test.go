@@ -0,0 +1,45 @@
+package spending
+
+import (
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestSummarize(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC) }
+	out := func(date time.Time, amount float64, description, category string) *domain.Transaction {
+		return &domain.Transaction{TxDate: date, TxAmount: amount, TxCurrency: "CAD", TxDirection: domain.Out, TxDesc: description, Category: category}
+	}
+
+	transactions := []*domain.Transaction{
+		out(day(3, 2), 40, "SQ *BLUE BOTTLE #0042 TORONTO", "coffee"),
+		out(day(3, 9), 12, "BLUE BOTTLE 0042", "coffee"),
+		out(day(3, 11), 95.5, "LOBLAWS 1234", ""),
+		out(day(3, 20), 500, "WWW PAYMENT - 4521 RBC VISA", "transfer"),
+		{TxDate: day(3, 15), TxAmount: 2500, TxCurrency: "CAD", TxDirection: domain.In, TxDesc: "PAYROLL"},
+		{TxDate: day(3, 21), TxAmount: 500, TxCurrency: "CAD", TxDirection: domain.In, TxDesc: "PAYMENT - THANK YOU", Kind: domain.KindCardPayment},
+		out(day(4, 1), 30, "BLUE BOTTLE", "coffee"),
+	}
+
+	months := Summarize(transactions, map[string]bool{"transfer": true})
+	if len(months) != 2 {
+		t.Fatalf("got %d months, want 2", len(months))
+	}
+
+	march := months[0]
+	if march.Month != "2024-03" || march.Out != 147.5 || march.In != 2500 {
+		t.Errorf("march: %+v", march)
+	}
+	if len(march.Categories) != 2 || march.Categories[0] != (Total{Name: "uncategorized", Amount: 95.5, Count: 1}) {
+		t.Errorf("march categories: %+v", march.Categories)
+	}
+	if march.Merchants[0] != (Total{Name: "LOBLAWS", Amount: 95.5, Count: 1}) || march.Merchants[1] != (Total{Name: "BLUE BOTTLE", Amount: 52, Count: 2}) {
+		t.Errorf("march merchants: %+v", march.Merchants)
+	}
+
+	if months[1].Month != "2024-04" || months[1].Out != 30 {
+		t.Errorf("april: %+v", months[1])
+	}
+}
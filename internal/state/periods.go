@@ -0,0 +1,131 @@
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// minGap is how many days without a statement it takes to suspect a missing one. A month-end line
+// on one statement and the next month's on the following one are less apart than this.
+const minGap = 28
+
+// Period is the span of dates one imported statement covered, from its first line to its last
+type Period struct {
+	File  string    `json:"file"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Statement is what the statement's summary said, for card statements
+	Statement *domain.Statement `json:"statement,omitempty"`
+}
+
+// Gap is a stretch between two statements of an account that no statement covered
+type Gap struct {
+	Account       string
+	Before, After Period
+}
+
+func (g Gap) String() string {
+	return fmt.Sprintf("account %s has no statement from %s to %s, between %s and %s",
+		g.Account, g.Before.End.AddDate(0, 0, 1).Format(time.DateOnly), g.After.Start.AddDate(0, 0, -1).Format(time.DateOnly),
+		filepath.Base(g.Before.File), filepath.Base(g.After.File))
+}
+
+// PeriodsOf works out the period of each statement file in transactions, per account as key names it
+func PeriodsOf(transactions []*domain.Transaction, key func(*domain.Transaction) string) map[string][]Period {
+	type fileKey struct{ account, file string }
+	spans := make(map[fileKey]*Period)
+	var order []fileKey
+	for _, tx := range transactions {
+		k := fileKey{key(tx), tx.SourceFilePath}
+		date := tx.TxDate.UTC().Truncate(24 * time.Hour)
+		period, ok := spans[k]
+		if !ok {
+			spans[k] = &Period{File: tx.SourceFilePath, Start: date, End: date}
+			order = append(order, k)
+			continue
+		}
+		if date.Before(period.Start) {
+			period.Start = date
+		}
+		if date.After(period.End) {
+			period.End = date
+		}
+	}
+
+	periods := make(map[string][]Period)
+	for _, k := range order {
+		periods[k.account] = append(periods[k.account], *spans[k])
+	}
+	return periods
+}
+
+// AddPeriods remembers the periods of imported statements. Call Save to keep them.
+func (s *Store) AddPeriods(periods map[string][]Period) {
+	if s.Periods == nil {
+		s.Periods = make(map[string][]Period)
+	}
+	for account, added := range periods {
+		for _, period := range added {
+			if !containsPeriod(s.Periods[account], period) {
+				s.Periods[account] = append(s.Periods[account], period)
+			}
+		}
+	}
+}
+
+// Gaps finds the stretches a new import leaves uncovered, next to what earlier imports covered. Only
+// gaps that touch one of the new periods are reported, old ones were reported when they appeared.
+func (s *Store) Gaps(periods map[string][]Period) []Gap {
+	accounts := make([]string, 0, len(periods))
+	for account := range periods {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	var gaps []Gap
+	for _, account := range accounts {
+		all := append([]Period(nil), s.Periods[account]...)
+		for _, period := range periods[account] {
+			if !containsPeriod(all, period) {
+				all = append(all, period)
+			}
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Start.Before(all[j].Start) })
+
+		covered := all[0]
+		for _, next := range all[1:] {
+			if isGap(covered, next) && (containsPeriod(periods[account], covered) || containsPeriod(periods[account], next)) {
+				gaps = append(gaps, Gap{Account: account, Before: covered, After: next})
+			}
+			if next.End.After(covered.End) {
+				covered = next
+			}
+		}
+	}
+	return gaps
+}
+
+// isGap reports whether a statement looks missing between two periods: enough days with no line,
+// and the statements closing in months that aren't consecutive. The month test keeps quiet accounts
+// whose only line each month lands on different days from looking like gaps.
+func isGap(before, after Period) bool {
+	days := int(after.Start.Sub(before.End).Hours()/24) - 1
+	if days < minGap {
+		return false
+	}
+	months := (after.End.Year()-before.End.Year())*12 + int(after.End.Month()) - int(before.End.Month())
+	return months > 1
+}
+
+func containsPeriod(periods []Period, period Period) bool {
+	for _, p := range periods {
+		if p.Start.Equal(period.Start) && p.End.Equal(period.End) {
+			return true
+		}
+	}
+	return false
+}
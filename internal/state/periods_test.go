@@ -0,0 +1,86 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func statement(file string, dates ...string) []*domain.Transaction {
+	var txs []*domain.Transaction
+	for _, date := range dates {
+		txs = append(txs, &domain.Transaction{TxDate: day(date), SourceFilePath: file, StatementAccountName: "chequing"})
+	}
+	return txs
+}
+
+func byName(tx *domain.Transaction) string { return tx.StatementAccountName }
+
+func TestGaps(t *testing.T) {
+	cases := []struct {
+		name       string
+		earlier    [][]*domain.Transaction
+		imported   [][]*domain.Transaction
+		wantBefore string // file before the gap, empty for none
+	}{
+		{
+			name:     "consecutive cycles",
+			earlier:  [][]*domain.Transaction{statement("jan.pdf", "2024-01-15", "2024-02-14")},
+			imported: [][]*domain.Transaction{statement("feb.pdf", "2024-02-15", "2024-03-14")},
+		},
+		{
+			name:       "missing cycle",
+			earlier:    [][]*domain.Transaction{statement("jan.pdf", "2024-01-15", "2024-02-14")},
+			imported:   [][]*domain.Transaction{statement("mar.pdf", "2024-03-15", "2024-04-14")},
+			wantBefore: "jan.pdf",
+		},
+		{
+			name:       "missing month in one import",
+			imported:   [][]*domain.Transaction{statement("jan.pdf", "2024-01-31"), statement("mar.pdf", "2024-03-31")},
+			wantBefore: "jan.pdf",
+		},
+		{
+			name:     "one quiet line a month",
+			earlier:  [][]*domain.Transaction{statement("feb.pdf", "2024-02-01")},
+			imported: [][]*domain.Transaction{statement("mar.pdf", "2024-03-31")},
+		},
+		{
+			name:     "old gap between earlier imports",
+			earlier:  [][]*domain.Transaction{statement("jan.pdf", "2024-01-31"), statement("apr.pdf", "2024-04-30")},
+			imported: [][]*domain.Transaction{statement("may.pdf", "2024-05-31")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := &Store{}
+			for _, txs := range c.earlier {
+				store.AddPeriods(PeriodsOf(txs, byName))
+			}
+			var imported []*domain.Transaction
+			for _, txs := range c.imported {
+				imported = append(imported, txs...)
+			}
+
+			gaps := store.Gaps(PeriodsOf(imported, byName))
+			if c.wantBefore == "" {
+				if len(gaps) > 0 {
+					t.Fatalf("unexpected gaps: %v", gaps)
+				}
+				return
+			}
+			if len(gaps) != 1 || gaps[0].Before.File != c.wantBefore {
+				t.Fatalf("gaps = %v, want one after %s", gaps, c.wantBefore)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"arian-statement-parser/internal/statefile"
+)
+
+// Store persists which remote statement objects have already been imported, and which periods the
+// imported statements of each account covered
+type Store struct {
+	filePath string
+	// SchemaVersion is the version of the file's format, see schema
+	SchemaVersion int                             `json:"schema_version"`
+	Processed     map[string]map[string]time.Time `json:"processed"`         // source -> object key -> import time
+	Periods       map[string][]Period             `json:"periods,omitempty"` // statement account -> statement periods
+}
+
+// schema is the format of arian-state.json. Version 1 only added the version.
+var schema = statefile.Schema{Name: "arian-state.json", Version: 1, Migrations: []statefile.Migration{nil}}
+
+// NewStore creates a new state store backed by a file in the working directory
+func NewStore() (*Store, error) {
+	return NewStoreIn("")
+}
+
+// NewStoreIn creates a state store backed by a file in dir, the working directory when empty
+func NewStoreIn(dir string) (*Store, error) {
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+		dir = cwd
+	}
+
+	store := &Store{
+		filePath:  filepath.Join(dir, "arian-state.json"),
+		Processed: make(map[string]map[string]time.Time),
+	}
+
+	// Load existing state if file exists
+	if _, err := os.Stat(store.filePath); err == nil {
+		if err := store.Load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// Load reads state from disk, from its backup when the file is damaged
+func (s *Store) Load() error {
+	data, err := statefile.Read(s.filePath, statefile.CheckJSON[Store])
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if data, err = schema.UpgradeJSON(data); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	if s.Processed == nil {
+		s.Processed = make(map[string]map[string]time.Time)
+	}
+
+	return nil
+}
+
+// Save writes state to disk
+func (s *Store) Save() error {
+	s.SchemaVersion = schema.Version
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := statefile.Write(s.filePath, data); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// IsProcessed reports whether a key from the given source was already imported
+func (s *Store) IsProcessed(source, key string) bool {
+	_, ok := s.Processed[source][key]
+	return ok
+}
+
+// MarkProcessed records keys from a source as imported and saves the store
+func (s *Store) MarkProcessed(source string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if s.Processed[source] == nil {
+		s.Processed[source] = make(map[string]time.Time)
+	}
+
+	now := time.Now().UTC()
+	for _, key := range keys {
+		s.Processed[source][key] = now
+	}
+
+	return s.Save()
+}
+
+// Unmark forgets that keys from a source were imported, so the next sync fetches them again, and
+// saves the store
+func (s *Store) Unmark(source string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	for _, key := range keys {
+		delete(s.Processed[source], key)
+	}
+
+	return s.Save()
+}
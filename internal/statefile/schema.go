@@ -0,0 +1,52 @@
+package statefile
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Migration upgrades a state file from one version of its format to the next
+type Migration func(data []byte) ([]byte, error)
+
+// Schema is the version of a state file's format and how to upgrade files written at older
+// versions, so a change to the format doesn't break the files of earlier releases
+type Schema struct {
+	// Name names the file in errors
+	Name    string
+	Version int
+	// Migrations[i] upgrades a file at version i to version i+1, nil when only the version changed.
+	// Version 0 is a file written before versions were recorded.
+	Migrations []Migration
+}
+
+// Upgrade migrates data written at version to the schema's version. A file from a newer release
+// is refused rather than read wrong.
+func (s Schema) Upgrade(data []byte, version int) ([]byte, error) {
+	if version > s.Version {
+		return nil, fmt.Errorf("%s is version %d of its format, newer than the %d this build reads; update arian-statement-parser", s.Name, version, s.Version)
+	}
+	if version < 0 {
+		return nil, fmt.Errorf("%s has an invalid version %d", s.Name, version)
+	}
+	for v := version; v < s.Version; v++ {
+		if s.Migrations[v] == nil {
+			continue
+		}
+		var err error
+		if data, err = s.Migrations[v](data); err != nil {
+			return nil, fmt.Errorf("failed to upgrade %s from version %d: %w", s.Name, v, err)
+		}
+	}
+	return data, nil
+}
+
+// UpgradeJSON upgrades a JSON state file, which records its version as schema_version
+func (s Schema) UpgradeJSON(data []byte) ([]byte, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to read the version of %s: %w", s.Name, err)
+	}
+	return s.Upgrade(data, header.SchemaVersion)
+}
@@ -0,0 +1,35 @@
+package statefile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUpgrade(t *testing.T) {
+	schema := Schema{
+		Name:    "test.json",
+		Version: 3,
+		Migrations: []Migration{
+			nil,
+			func(data []byte) ([]byte, error) {
+				return bytes.ReplaceAll(data, []byte(`"name"`), []byte(`"account"`)), nil
+			},
+			func(data []byte) ([]byte, error) {
+				return bytes.ReplaceAll(data, []byte(`}`), []byte(`, "bank": ""}`)), nil
+			},
+		},
+	}
+
+	got, err := schema.UpgradeJSON([]byte(`{"name": "Visa"}`))
+	if err != nil || string(got) != `{"account": "Visa", "bank": ""}` {
+		t.Fatalf("UpgradeJSON from 0 = %s, %v", got, err)
+	}
+	got, err = schema.UpgradeJSON([]byte(`{"schema_version": 2, "account": "Visa"}`))
+	if err != nil || string(got) != `{"schema_version": 2, "account": "Visa", "bank": ""}` {
+		t.Fatalf("UpgradeJSON from 2 = %s, %v", got, err)
+	}
+	if _, err := schema.UpgradeJSON([]byte(`{"schema_version": 4}`)); err == nil || !strings.Contains(err.Error(), "update arian-statement-parser") {
+		t.Fatalf("UpgradeJSON of a newer file = %v", err)
+	}
+}
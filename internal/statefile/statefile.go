@@ -0,0 +1,117 @@
+// Package statefile reads and writes the files the tool keeps its state in between runs, such as
+// the account mappings and the review queue. Writes go through a temp file, so a crash never leaves
+// half a file, and keep the versions they replace, so a file that was damaged anyway can be rolled
+// back instead of stopping every run.
+package statefile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// Backups is how many earlier versions of a file Write keeps, as path.1 (the newest) to path.3
+const Backups = 3
+
+// Write replaces the file at path with data, keeping the version it replaces as path.1
+func Write(path string, data []byte) error {
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		for i := Backups - 1; i >= 1; i-- {
+			if err := os.Rename(backupPath(path, i), backupPath(path, i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := writeAtomic(backupPath(path, 1), current); err != nil {
+			return err
+		}
+	}
+	return writeAtomic(path, data)
+}
+
+// Remove deletes the file at path and its backups, so a file created there later can't be rolled
+// back to state that was done with
+func Remove(path string) error {
+	for i := Backups; i >= 1; i-- {
+		if err := os.Remove(backupPath(path, i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ErrDamaged is what Read returns, wrapped, for a file that is damaged and has no backup that reads
+var ErrDamaged = errors.New("damaged with no backup to restore")
+
+// Read returns the file at path, or an error that os.IsNotExist reports for a missing one. When
+// check rejects its data, say a file cut short by a full disk, the newest backup check accepts is
+// put back in its place and returned instead, the damaged file is kept as path.corrupt and a warning
+// says what happened. With no such backup Read fails with ErrDamaged and leaves the file alone:
+// starting the review queue or the pending records over would lose lines or upload them twice, so
+// each caller decides whether its state can start empty.
+func Read(path string, check func(data []byte) error) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	damage := check(data)
+	if damage == nil {
+		return data, nil
+	}
+
+	for i := 1; i <= Backups; i++ {
+		backup, err := os.ReadFile(backupPath(path, i))
+		if err != nil || check(backup) != nil {
+			continue
+		}
+		corrupt := path + ".corrupt"
+		if err := os.Rename(path, corrupt); err != nil {
+			return nil, fmt.Errorf("%s is damaged (%v) and can't be moved aside: %w", path, damage, err)
+		}
+		if err := writeAtomic(path, backup); err != nil {
+			return nil, fmt.Errorf("failed to restore %s from %s: %w", path, backupPath(path, i), err)
+		}
+		log.Printf("WARN: %s is damaged (%v), restored the version saved before it from %s; the damaged file is %s", path, damage, backupPath(path, i), corrupt)
+		return backup, nil
+	}
+	return nil, fmt.Errorf("%s is %w (%v); fix or remove it", path, ErrDamaged, damage)
+}
+
+// CheckJSON is a check for Read that accepts data that decodes as a T
+func CheckJSON[T any](data []byte) error {
+	return json.Unmarshal(data, new(T))
+}
+
+func backupPath(path string, i int) string {
+	return path + "." + strconv.Itoa(i)
+}
+
+// writeAtomic writes data to a temp file next to path, flushed to disk, and renames it over path
+func writeAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
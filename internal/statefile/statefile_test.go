@@ -0,0 +1,82 @@
+package statefile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	for i := range 5 {
+		if err := Write(path, fmt.Appendf(nil, `{"version": %d}`, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, want := range []string{`{"version": 4}`, `{"version": 3}`, `{"version": 2}`, `{"version": 1}`} {
+		name := path
+		if i > 0 {
+			name = backupPath(path, i)
+		}
+		got, err := os.ReadFile(name)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(name), got, err, want)
+		}
+	}
+	if _, err := os.Stat(backupPath(path, Backups+1)); !os.IsNotExist(err) {
+		t.Errorf("kept more than %d backups", Backups)
+	}
+
+	if err := Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := filepath.Glob(path + "*"); len(matches) > 0 {
+		t.Errorf("Remove left %v", matches)
+	}
+}
+
+func TestReadRecovers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := Write(path, []byte(`{"version": 1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(path, []byte(`{"version": 2}`)); err != nil {
+		t.Fatal(err)
+	}
+	// A crash on an older build cut the file short
+	if err := os.WriteFile(path, []byte(`{"vers`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Read(path, CheckJSON[map[string]int])
+	if err != nil || string(data) != `{"version": 1}` {
+		t.Fatalf("Read = %q, %v, want the backup", data, err)
+	}
+	if restored, _ := os.ReadFile(path); string(restored) != `{"version": 1}` {
+		t.Errorf("restored file = %q", restored)
+	}
+	if corrupt, _ := os.ReadFile(path + ".corrupt"); string(corrupt) != `{"vers` {
+		t.Errorf("corrupt file = %q", corrupt)
+	}
+
+	// Without a backup that reads, the file stays for someone to look at
+	if err := Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := Read(path, CheckJSON[map[string]int]); !errors.Is(err, ErrDamaged) {
+		t.Fatalf("Read without backups = %q, %v, want ErrDamaged", data, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("damaged file without backups was moved: %v", err)
+	}
+
+	if _, err := Read(filepath.Join(t.TempDir(), "missing.json"), CheckJSON[map[string]int]); !os.IsNotExist(err) {
+		t.Fatalf("Read of a missing file = %v", err)
+	}
+}
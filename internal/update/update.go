@@ -0,0 +1,281 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRepo is where releases are published
+const DefaultRepo = "xhos/arian-statement-parser"
+
+// Release files besides the binaries: sha256sum output for every binary, and an ed25519 signature
+// of it in base64
+const (
+	ChecksumsName = "checksums.txt"
+	SignatureName = "checksums.txt.sig"
+)
+
+// PublicKey is the base64 ed25519 key releases are signed with. Release builds set it with
+// -ldflags "-X arian-statement-parser/internal/update.PublicKey=..."; without it nothing is installed
+// unless the updater is told to trust checksums alone.
+var PublicKey string
+
+// ErrNoAsset means the release has no binary for this platform
+var ErrNoAsset = errors.New("no binary for this platform in the release")
+
+// ErrNoKey means this build has no release key to check a download's signature with
+var ErrNoKey = errors.New("this build has no release key to check the signature with")
+
+// Release is a published GitHub release
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Updater finds, checks and installs releases
+type Updater struct {
+	Repo      string
+	API       string // GitHub API base URL
+	PublicKey string // base64 ed25519 key
+	// Insecure installs with checksums alone when there is no key. Anyone who can change the
+	// release can change its checksums too, so they only catch a broken download.
+	Insecure bool
+	client   *http.Client
+}
+
+// New creates an updater for the published releases
+func New() *Updater {
+	return &Updater{
+		Repo:      DefaultRepo,
+		API:       "https://api.github.com",
+		PublicKey: PublicKey,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Latest returns the newest release
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	body, err := u.get(ctx, fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimRight(u.API, "/"), u.Repo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for releases: %w", err)
+	}
+
+	var release Release
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	return &release, nil
+}
+
+// AssetName is the binary for a platform, e.g. arian-statement-parser_linux_amd64
+func AssetName(goos, goarch string) string {
+	name := fmt.Sprintf("arian-statement-parser_%s_%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Download fetches this platform's binary from a release and checks it against the release
+// checksums, and the checksums against their signature. Without a public key it fails with
+// ErrNoKey, unless the updater is Insecure.
+func (u *Updater) Download(ctx context.Context, release *Release) ([]byte, error) {
+	if u.PublicKey == "" && !u.Insecure {
+		return nil, ErrNoKey
+	}
+
+	assets := make(map[string]Asset, len(release.Assets))
+	for _, asset := range release.Assets {
+		assets[asset.Name] = asset
+	}
+
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	binary, ok := assets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoAsset, name)
+	}
+	checksumsAsset, ok := assets[ChecksumsName]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s, refusing an unverified binary", release.Tag, ChecksumsName)
+	}
+
+	checksums, err := u.get(ctx, checksumsAsset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checksums: %w", err)
+	}
+
+	if u.PublicKey != "" {
+		signatureAsset, ok := assets[SignatureName]
+		if !ok {
+			return nil, fmt.Errorf("release %s is not signed", release.Tag)
+		}
+		signature, err := u.get(ctx, signatureAsset.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download signature: %w", err)
+		}
+		if err := Verify(u.PublicKey, checksums, signature); err != nil {
+			return nil, err
+		}
+	}
+
+	want, err := Checksum(checksums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := u.get(ctx, binary.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	if got := sha256.Sum256(data); hex.EncodeToString(got[:]) != want {
+		return nil, fmt.Errorf("checksum mismatch for %s, the download is corrupt or was tampered with", name)
+	}
+	return data, nil
+}
+
+// Verify checks a base64 ed25519 signature of data
+func Verify(publicKey string, data, signature []byte) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("bad signature on %s", ChecksumsName)
+	}
+	return nil
+}
+
+// Checksum finds the sha256 of a file in sha256sum output
+func Checksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks binary mode with a * before the name
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
+
+// Replace swaps the binary at path for data. The new binary is written next to it and renamed over
+// it, so a failure leaves the old one in place.
+func Replace(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".arian-statement-parser-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to make new binary executable: %w", err)
+	}
+
+	// Windows can't replace a running binary, but it can rename it out of the way
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return fmt.Errorf("failed to move old binary: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// Newer reports whether version a is newer than b, both like v1.2.3. A version that isn't one,
+// like a development build, can't be compared, so it is neither newer nor older than a release.
+func Newer(a, b string) bool {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] > vb[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version = strings.TrimPrefix(version, "v")
+	// Pre-release and build suffixes don't take part in the comparison
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	fields := strings.Split(version, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+func (u *Updater) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s returned %s: %s", url, resp.Status, bytes.TrimSpace(body))
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,225 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.3.0", false},
+		{"v2.0.0-rc1", "v1.9.9", true},
+		{"v1.0.0", "dev", false},
+		{"nightly", "v1.0.0", false},
+	}
+	for _, c := range cases {
+		if got := Newer(c.a, c.b); got != c.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	sums := []byte("abc123  other_file\nDEF456 *arian-statement-parser_linux_amd64\n")
+	got, err := Checksum(sums, "arian-statement-parser_linux_amd64")
+	if err != nil || got != "def456" {
+		t.Fatalf("Checksum = %q, %v", got, err)
+	}
+	if _, err := Checksum(sums, "missing"); err == nil {
+		t.Fatal("expected an error for a file without a checksum")
+	}
+}
+
+// release serves a latest release with this platform's binary, its checksums and their signature
+func release(t *testing.T, binary []byte, sums string, signature string) *httptest.Server {
+	t.Helper()
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	files := map[string]string{
+		"/dl/" + name:          string(binary),
+		"/dl/" + ChecksumsName: sums,
+	}
+	if signature != "" {
+		files["/dl/"+SignatureName] = signature
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/"+DefaultRepo+"/releases/latest" {
+			var assets []string
+			for path := range files {
+				assets = append(assets, fmt.Sprintf(`{"name":%q,"browser_download_url":%q}`, strings.TrimPrefix(path, "/dl/"), server.URL+path))
+			}
+			fmt.Fprintf(w, `{"tag_name":"v1.2.3","assets":[%s]}`, strings.Join(assets, ","))
+			return
+		}
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func checksums(binary []byte) string {
+	sum := sha256.Sum256(binary)
+	return hex.EncodeToString(sum[:]) + "  " + AssetName(runtime.GOOS, runtime.GOARCH) + "\n"
+}
+
+func TestDownload(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := []byte("new binary")
+	sums := checksums(binary)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte(sums)))
+
+	t.Run("signed", func(t *testing.T) {
+		server := release(t, binary, sums, signature)
+		u := New()
+		u.API = server.URL
+		u.PublicKey = base64.StdEncoding.EncodeToString(public)
+
+		rel, err := u.Latest(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rel.Tag != "v1.2.3" {
+			t.Fatalf("tag = %q", rel.Tag)
+		}
+		data, err := u.Download(context.Background(), rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(binary) {
+			t.Fatalf("downloaded %q", data)
+		}
+	})
+
+	t.Run("tampered binary", func(t *testing.T) {
+		server := release(t, []byte("evil binary"), sums, signature)
+		u := New()
+		u.API = server.URL
+		u.PublicKey = base64.StdEncoding.EncodeToString(public)
+
+		rel, err := u.Latest(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.Download(context.Background(), rel); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Fatalf("expected a checksum mismatch, got %v", err)
+		}
+	})
+
+	t.Run("tampered checksums", func(t *testing.T) {
+		evil := []byte("evil binary")
+		server := release(t, evil, checksums(evil), signature)
+		u := New()
+		u.API = server.URL
+		u.PublicKey = base64.StdEncoding.EncodeToString(public)
+
+		rel, err := u.Latest(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.Download(context.Background(), rel); err == nil || !strings.Contains(err.Error(), "bad signature") {
+			t.Fatalf("expected a bad signature, got %v", err)
+		}
+	})
+
+	t.Run("unsigned with a key", func(t *testing.T) {
+		server := release(t, binary, sums, "")
+		u := New()
+		u.API = server.URL
+		u.PublicKey = base64.StdEncoding.EncodeToString(public)
+
+		rel, err := u.Latest(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.Download(context.Background(), rel); err == nil || !strings.Contains(err.Error(), "not signed") {
+			t.Fatalf("expected an unsigned release to be refused, got %v", err)
+		}
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		server := release(t, binary, sums, signature)
+		u := New()
+		u.API = server.URL
+		u.PublicKey = ""
+
+		rel, err := u.Latest(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.Download(context.Background(), rel); !errors.Is(err, ErrNoKey) {
+			t.Fatalf("expected ErrNoKey, got %v", err)
+		}
+
+		u.Insecure = true
+		if data, err := u.Download(context.Background(), rel); err != nil || string(data) != string(binary) {
+			t.Fatalf("insecure download = %q, %v", data, err)
+		}
+	})
+
+	t.Run("no binary for this platform", func(t *testing.T) {
+		u := New()
+		u.Insecure = true
+		_, err := u.Download(context.Background(), &Release{Tag: "v1.2.3"})
+		if !errors.Is(err, ErrNoAsset) {
+			t.Fatalf("expected ErrNoAsset, got %v", err)
+		}
+	})
+}
+
+func TestReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arian-statement-parser")
+	if err := os.WriteFile(path, []byte("old"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Fatalf("binary = %q", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o700 {
+		t.Fatalf("mode = %v, want the old one", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("left %d files behind", len(entries)-1)
+	}
+}
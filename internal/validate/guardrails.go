@@ -0,0 +1,108 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// minIdenticalSample keeps tiny statements, where a repeated amount is normal, out of the identical-amount check
+const minIdenticalSample = 10
+
+// Guardrails are limits that parsed data should stay within; a zero value disables that check
+type Guardrails struct {
+	MaxAmount       float64 // largest single transaction
+	MaxPerStatement int     // most transactions in one statement file, or in each month of an export
+	MaxIdenticalPct float64 // highest share of one amount within a statement, in percent
+	History         int     // past transactions in ariand a batch is compared with, see History
+}
+
+func DefaultGuardrails() Guardrails {
+	return Guardrails{
+		MaxAmount:       50000,
+		MaxPerStatement: 500,
+		MaxIdenticalPct: 50,
+		History:         1000,
+	}
+}
+
+// Check returns a warning for every limit the batch exceeds; all of them usually mean extraction went wrong.
+// Files in periods are statements of one period. Any other file, like an export of several years,
+// may hold MaxPerStatement transactions for each month it covers.
+func (g Guardrails) Check(transactions []*domain.Transaction, periods map[string]Period) []string {
+	var warnings []string
+
+	byFile := make(map[string][]*domain.Transaction)
+	for _, tx := range transactions {
+		if g.MaxAmount > 0 && tx.TxAmount > g.MaxAmount {
+			warnings = append(warnings, fmt.Sprintf("%s: %s %.2f %q exceeds the %.2f limit",
+				filepath.Base(tx.SourceFilePath), tx.TxDate.Format(time.DateOnly), tx.TxAmount, tx.TxDesc, g.MaxAmount))
+		}
+		byFile[tx.SourceFilePath] = append(byFile[tx.SourceFilePath], tx)
+	}
+
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		fileTxs := byFile[file]
+		name := filepath.Base(file)
+
+		limit, within := g.MaxPerStatement, "one statement"
+		if _, statement := periods[file]; !statement {
+			months := monthsCovered(fileTxs)
+			limit, within = limit*months, fmt.Sprintf("%d months", months)
+			if months == 1 {
+				within = "one month"
+			}
+		}
+		if limit > 0 && len(fileTxs) > limit {
+			warnings = append(warnings, fmt.Sprintf("%s: %d transactions, more than the %d expected in %s",
+				name, len(fileTxs), limit, within))
+		}
+
+		if g.MaxIdenticalPct > 0 && len(fileTxs) >= minIdenticalSample {
+			amount, count := mostCommonAmount(fileTxs)
+			if pct := float64(count) / float64(len(fileTxs)) * 100; pct > g.MaxIdenticalPct {
+				warnings = append(warnings, fmt.Sprintf("%s: %.0f%% of transactions are %.2f, more than the %.0f%% limit",
+					name, pct, amount, g.MaxIdenticalPct))
+			}
+		}
+	}
+
+	return warnings
+}
+
+// monthsCovered counts the months from the first transaction to the last, at least one
+func monthsCovered(transactions []*domain.Transaction) int {
+	first, last := transactions[0].TxDate, transactions[0].TxDate
+	for _, tx := range transactions {
+		if tx.TxDate.Before(first) {
+			first = tx.TxDate
+		}
+		if tx.TxDate.After(last) {
+			last = tx.TxDate
+		}
+	}
+	return (last.Year()-first.Year())*12 + int(last.Month()-first.Month()) + 1
+}
+
+// mostCommonAmount finds the amount repeated most often, compared in whole cents
+func mostCommonAmount(transactions []*domain.Transaction) (float64, int) {
+	counts := make(map[int64]int)
+	var best int64
+	for _, tx := range transactions {
+		cents := int64(tx.TxAmount*100 + 0.5)
+		counts[cents]++
+		if counts[cents] > counts[best] {
+			best = cents
+		}
+	}
+	return float64(best) / 100, counts[best]
+}
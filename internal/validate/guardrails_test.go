@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestGuardrails(t *testing.T) {
+	// lines makes count lines of file, perDay a day from March 2024
+	lines := func(file string, count, perDay int, amount func(i int) float64) []*domain.Transaction {
+		var out []*domain.Transaction
+		for i := range count {
+			out = append(out, &domain.Transaction{
+				TxDate:         time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i/perDay),
+				TxAmount:       amount(i),
+				TxDesc:         fmt.Sprintf("LINE %d", i),
+				SourceFilePath: file,
+			})
+		}
+		return out
+	}
+	varied := func(i int) float64 { return float64(i%97) + 1 }
+	guardrails := Guardrails{MaxAmount: 50000, MaxPerStatement: 100, MaxIdenticalPct: 50}
+
+	tests := []struct {
+		name    string
+		lines   []*domain.Transaction
+		periods map[string]Period
+		want    []string // a part of each warning
+	}{
+		{
+			name:    "ordinary statement",
+			lines:   lines("/in/march.pdf", 90, 3, varied),
+			periods: map[string]Period{"/in/march.pdf": {}},
+		},
+		{
+			name:    "statement with too many lines",
+			lines:   lines("/in/march.pdf", 120, 4, varied),
+			periods: map[string]Period{"/in/march.pdf": {}},
+			want:    []string{"120 transactions, more than the 100 expected in one statement"},
+		},
+		{
+			name:  "export over three years",
+			lines: lines("/in/export.csv", 1000, 1, varied),
+		},
+		{
+			name:  "export with too many lines for its months",
+			lines: lines("/in/export.csv", 250, 5, varied),
+			want:  []string{"250 transactions, more than the 200 expected in 2 months"},
+		},
+		{
+			name:  "huge amount",
+			lines: lines("/in/march.pdf", 1, 1, func(int) float64 { return 75000 }),
+			want:  []string{"exceeds the 50000.00 limit"},
+		},
+		{
+			name:  "one amount over and over",
+			lines: lines("/in/march.pdf", 20, 1, func(i int) float64 { return float64(i%3/2*10 + 5) }),
+			want:  []string{"of transactions are 5.00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := guardrails.Check(tt.lines, tt.periods)
+			if len(warnings) != len(tt.want) {
+				t.Fatalf("warnings = %q, want %q", warnings, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(warnings[i], want) {
+					t.Errorf("warning %q, want %q", warnings[i], want)
+				}
+			}
+		})
+	}
+}
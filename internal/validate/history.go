@@ -0,0 +1,135 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"arian-statement-parser/internal/domain"
+)
+
+const (
+	minHistory       = 50  // past transactions a profile needs before it says anything
+	minHistorySample = 10  // lines a statement needs before it is compared
+	maxMedianShift   = 10  // how many times larger or smaller than usual a statement's median may be
+	maxOutlierShare  = 0.2 // share of a statement allowed above the 99th percentile of the past
+	minFamiliar      = 0.1 // share of a statement's merchants that must have been seen before
+	minMerchants     = 20  // merchants the past needs before an unfamiliar mix counts
+)
+
+// Past is a transaction already in ariand, as far as comparing with it goes
+type Past struct {
+	Amount      float64
+	Description string
+	Merchant    string
+}
+
+// History profiles past transactions, so a statement whose amounts or merchants look nothing like
+// them, which usually means the extraction went wrong, is flagged before upload
+type History struct {
+	amounts   []float64 // sorted
+	merchants map[string]bool
+}
+
+// NewHistory profiles past transactions
+func NewHistory(past []Past) *History {
+	h := &History{merchants: make(map[string]bool)}
+	for _, p := range past {
+		h.amounts = append(h.amounts, p.Amount)
+		for _, name := range []string{p.Merchant, p.Description} {
+			if key := merchantKey(name); key != "" {
+				h.merchants[key] = true
+			}
+		}
+	}
+	sort.Float64s(h.amounts)
+	return h
+}
+
+// Check returns a warning for every statement file whose amounts or merchants stray from the past:
+// a median amount ten times off, as when amounts land in the wrong column or lose their decimal
+// point, too many amounts larger than nearly anything before, or merchants that were almost never
+// seen. Too little history, or too few lines in a file, says nothing.
+func (h *History) Check(transactions []*domain.Transaction) []string {
+	if len(h.amounts) < minHistory {
+		return nil
+	}
+	usual := percentile(h.amounts, 0.5)
+	high := percentile(h.amounts, 0.99)
+
+	byFile := make(map[string][]*domain.Transaction)
+	for _, tx := range transactions {
+		byFile[tx.SourceFilePath] = append(byFile[tx.SourceFilePath], tx)
+	}
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var warnings []string
+	for _, file := range files {
+		fileTxs := byFile[file]
+		if len(fileTxs) < minHistorySample {
+			continue
+		}
+		name := filepath.Base(file)
+
+		amounts := make([]float64, 0, len(fileTxs))
+		outliers, familiar := 0, 0
+		for _, tx := range fileTxs {
+			amounts = append(amounts, tx.TxAmount)
+			if tx.TxAmount > high {
+				outliers++
+			}
+			if h.merchants[merchantKey(tx.Merchant)] || h.merchants[merchantKey(tx.TxDesc)] {
+				familiar++
+			}
+		}
+		slices.Sort(amounts)
+
+		if median := percentile(amounts, 0.5); usual > 0 && (median >= usual*maxMedianShift || median*maxMedianShift <= usual) {
+			warnings = append(warnings, fmt.Sprintf("%s: the median amount is %.2f, against %.2f in ariand", name, median, usual))
+		}
+		if share := float64(outliers) / float64(len(fileTxs)); share > maxOutlierShare {
+			warnings = append(warnings, fmt.Sprintf("%s: %d of %d amounts are above %.2f, which only 1%% of the transactions in ariand are",
+				name, outliers, len(fileTxs), high))
+		}
+		if share := float64(familiar) / float64(len(fileTxs)); len(h.merchants) >= minMerchants && share < minFamiliar {
+			warnings = append(warnings, fmt.Sprintf("%s: %d of %d descriptions match a merchant seen in ariand", name, familiar, len(fileTxs)))
+		}
+	}
+	return warnings
+}
+
+// percentile reads the value at share p of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// merchantKey reduces a description to its first two words with letters, without what isn't a
+// letter, so "TIM HORTONS #1234" and "Tim Hortons 0987" compare equal
+func merchantKey(description string) string {
+	var words []string
+	for _, word := range strings.Fields(description) {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) {
+				return unicode.ToUpper(r)
+			}
+			return -1
+		}, word)
+		if word == "" {
+			continue
+		}
+		if words = append(words, word); len(words) == 2 {
+			break
+		}
+	}
+	return strings.Join(words, " ")
+}
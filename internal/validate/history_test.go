@@ -0,0 +1,50 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestHistory(t *testing.T) {
+	var past []Past
+	for i := range 200 {
+		past = append(past, Past{Amount: float64(5 + i%60), Description: fmt.Sprintf("STORE%c %d", 'A'+i%26, i)})
+	}
+	history := NewHistory(past)
+
+	statement := func(file string, amount func(i int) float64, description func(i int) string) []*domain.Transaction {
+		var lines []*domain.Transaction
+		for i := range 20 {
+			lines = append(lines, &domain.Transaction{TxAmount: amount(i), TxDesc: description(i), SourceFilePath: file})
+		}
+		return lines
+	}
+	familiar := func(i int) string { return fmt.Sprintf("STORE%c #%d", 'A'+i%26, 1000+i) }
+
+	var batch []*domain.Transaction
+	batch = append(batch, statement("/in/good.pdf", func(i int) float64 { return float64(10 + i) }, familiar)...)
+	// Amounts that lost their decimal point
+	batch = append(batch, statement("/in/cents.pdf", func(i int) float64 { return float64(1000 + 100*i) }, familiar)...)
+	// Descriptions that are really dates and amounts
+	batch = append(batch, statement("/in/garbled.pdf", func(i int) float64 { return float64(10 + i) }, func(i int) string { return fmt.Sprintf("03 %02d 12.50", i) })...)
+
+	warnings := history.Check(batch)
+	if len(warnings) != 3 {
+		t.Fatalf("warnings = %q", warnings)
+	}
+	for _, w := range warnings {
+		if strings.HasPrefix(w, "good.pdf") {
+			t.Errorf("flagged a statement like the past: %s", w)
+		}
+	}
+	if !strings.HasPrefix(warnings[0], "cents.pdf: the median") || !strings.HasPrefix(warnings[1], "cents.pdf: 20 of 20 amounts") || !strings.HasPrefix(warnings[2], "garbled.pdf: 0 of 20") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	if got := NewHistory(past[:10]).Check(batch); got != nil {
+		t.Errorf("too little history still warned: %q", got)
+	}
+}
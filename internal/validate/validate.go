@@ -0,0 +1,159 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// How far a date may sit from the period of its statement before it looks misread. A statement that
+// gives its closing day is checked against that, allowing for the longest period a statement covers
+// and for lines posted after it closed. One that doesn't is checked against the middle of its own
+// dates, which a misread date won't move.
+const (
+	periodSlack  = 45 * 24 * time.Hour // either side of the middle of a statement's dates
+	maxPeriod    = 62 * 24 * time.Hour // before the closing day
+	closingGrace = 7 * 24 * time.Hour  // after the closing day
+)
+
+// Period is what is known of the time one statement covers. Only statements of a single period,
+// like a month of a PDF statement, have one; exports, migrations and backups span years and may
+// hold any date.
+type Period struct {
+	// Closing is the last day the statement covers, zero when it doesn't say
+	Closing time.Time
+}
+
+// StatementPeriod is the period of a single statement, closing when its summary says so
+func StatementPeriod(statement *domain.Statement) Period {
+	closing, _ := statement.Closing()
+	return Period{Closing: closing}
+}
+
+// iso4217 lists active currency codes
+var iso4217 = strings.Fields(`
+AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BRL BSD BTN BWP BYN BZD
+CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD
+GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT
+LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR
+NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP
+STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XOF
+XPF YER ZAR ZMW ZWL`)
+
+var currencies = func() map[string]bool {
+	set := make(map[string]bool, len(iso4217))
+	for _, code := range iso4217 {
+		set[code] = true
+	}
+	return set
+}()
+
+// Problem is one rule a transaction failed
+type Problem struct {
+	Tx      *domain.Transaction
+	Rule    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s %s %.2f %q (%s): %s",
+		filepath.Base(p.Tx.SourceFilePath), p.Tx.TxDate.Format(time.DateOnly), p.Tx.TxAmount, p.Tx.TxDesc, p.Rule, p.Message)
+}
+
+// Check runs the content rules that don't need ariand: amount, date, description and currency.
+// Dates are only held to a statement period for the files periods has, by SourceFilePath; nil
+// periods checks none.
+func Check(transactions []*domain.Transaction, now time.Time, periods map[string]Period) []Problem {
+	middles := statementMiddles(transactions, periods)
+
+	var problems []Problem
+	add := func(tx *domain.Transaction, rule, format string, args ...any) {
+		problems = append(problems, Problem{Tx: tx, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, tx := range transactions {
+		if tx.TxAmount == 0 {
+			add(tx, "amount", "amount is zero")
+		}
+
+		if tx.TxDate.After(now) {
+			add(tx, "date", "date is in the future")
+		} else if closing := periods[tx.SourceFilePath].Closing; !closing.IsZero() {
+			if tx.TxDate.After(closing.Add(closingGrace)) || tx.TxDate.Before(closing.Add(-maxPeriod)) {
+				add(tx, "date", "date is outside the statement period (closing %s)", closing.Format(time.DateOnly))
+			}
+		} else if middle, ok := middles[tx.SourceFilePath]; ok {
+			if gap := tx.TxDate.Sub(middle); gap > periodSlack || gap < -periodSlack {
+				add(tx, "date", "date is outside the statement period (around %s)", middle.Format(time.DateOnly))
+			}
+		}
+
+		if strings.TrimSpace(tx.TxDesc) == "" {
+			add(tx, "description", "description is empty")
+		}
+
+		if !currencies[tx.TxCurrency] {
+			add(tx, "currency", "%q is not an ISO 4217 currency code", tx.TxCurrency)
+		}
+	}
+
+	return problems
+}
+
+// CheckAccounts reports transactions that were never matched to an ariand account
+func CheckAccounts(transactions []*domain.Transaction) []Problem {
+	var problems []Problem
+	for _, tx := range transactions {
+		if tx.AccountID == 0 {
+			problems = append(problems, Problem{Tx: tx, Rule: "account", Message: "no ariand account resolved"})
+		}
+	}
+	return problems
+}
+
+// Exclude drops every transaction that has at least one problem
+func Exclude(transactions []*domain.Transaction, problems []Problem) []*domain.Transaction {
+	invalid := make(map[*domain.Transaction]bool, len(problems))
+	for _, p := range problems {
+		invalid[p.Tx] = true
+	}
+
+	kept := make([]*domain.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		if !invalid[tx] {
+			kept = append(kept, tx)
+		}
+	}
+	return kept
+}
+
+// Invalid counts the distinct transactions behind a list of problems
+func Invalid(problems []Problem) int {
+	seen := make(map[*domain.Transaction]bool, len(problems))
+	for _, p := range problems {
+		seen[p.Tx] = true
+	}
+	return len(seen)
+}
+
+// statementMiddles finds the median date of each single statement that doesn't say when it closed
+func statementMiddles(transactions []*domain.Transaction, periods map[string]Period) map[string]time.Time {
+	dates := make(map[string][]time.Time)
+	for _, tx := range transactions {
+		if period, ok := periods[tx.SourceFilePath]; !ok || !period.Closing.IsZero() {
+			continue
+		}
+		dates[tx.SourceFilePath] = append(dates[tx.SourceFilePath], tx.TxDate)
+	}
+
+	middles := make(map[string]time.Time, len(dates))
+	for file, fileDates := range dates {
+		sort.Slice(fileDates, func(i, j int) bool { return fileDates[i].Before(fileDates[j]) })
+		middles[file] = fileDates[len(fileDates)/2]
+	}
+	return middles
+}
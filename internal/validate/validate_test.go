@@ -0,0 +1,117 @@
+package validate
+
+import (
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+func TestCheckDates(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	line := func(file string, date time.Time) *domain.Transaction {
+		return &domain.Transaction{TxDate: date, TxAmount: 10, TxDesc: "COFFEE", TxCurrency: "CAD", SourceFilePath: file}
+	}
+	// Five years of monthly lines, as a CSV export or a backup holds
+	export := func(file string) []*domain.Transaction {
+		var lines []*domain.Transaction
+		for i := range 60 {
+			lines = append(lines, line(file, day(2019, time.June, 1).AddDate(0, i, 0)))
+		}
+		return lines
+	}
+	month := func(file string, odd ...time.Time) []*domain.Transaction {
+		var lines []*domain.Transaction
+		for d := 1; d <= 28; d += 3 {
+			lines = append(lines, line(file, day(2024, time.March, d)))
+		}
+		for _, date := range odd {
+			lines = append(lines, line(file, date))
+		}
+		return lines
+	}
+
+	tests := []struct {
+		name    string
+		lines   []*domain.Transaction
+		periods map[string]Period
+		flagged []time.Time
+	}{
+		{
+			name:  "export without a period",
+			lines: export("/in/export.csv"),
+		},
+		{
+			name:    "export passed with a period",
+			lines:   export("/in/export.csv"),
+			periods: map[string]Period{"/in/other.pdf": {}},
+		},
+		{
+			name:    "statement with a misread year",
+			lines:   month("/in/march.pdf", day(2023, time.March, 14)),
+			periods: map[string]Period{"/in/march.pdf": {}},
+			flagged: []time.Time{day(2023, time.March, 14)},
+		},
+		{
+			name:    "same statement without a period",
+			lines:   month("/in/march.pdf", day(2023, time.March, 14)),
+			periods: nil,
+		},
+		{
+			name:    "statement with a closing day",
+			lines:   month("/in/march.pdf", day(2024, time.April, 3), day(2024, time.April, 20), day(2023, time.December, 20)),
+			periods: map[string]Period{"/in/march.pdf": {Closing: day(2024, time.March, 31)}},
+			flagged: []time.Time{day(2024, time.April, 20), day(2023, time.December, 20)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var flagged []time.Time
+			for _, problem := range Check(tt.lines, now, tt.periods) {
+				if problem.Rule != "date" {
+					t.Errorf("unexpected problem: %s", problem)
+					continue
+				}
+				flagged = append(flagged, problem.Tx.TxDate)
+			}
+			if len(flagged) != len(tt.flagged) {
+				t.Fatalf("flagged %v, want %v", flagged, tt.flagged)
+			}
+			for i := range flagged {
+				if !flagged[i].Equal(tt.flagged[i]) {
+					t.Errorf("flagged %v, want %v", flagged, tt.flagged)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckContent(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		tx   domain.Transaction
+		rule string
+	}{
+		{"valid", domain.Transaction{TxDate: date, TxAmount: 1, TxDesc: "A", TxCurrency: "CAD"}, ""},
+		{"zero amount", domain.Transaction{TxDate: date, TxDesc: "A", TxCurrency: "CAD"}, "amount"},
+		{"future", domain.Transaction{TxDate: now.AddDate(0, 0, 1), TxAmount: 1, TxDesc: "A", TxCurrency: "CAD"}, "date"},
+		{"empty description", domain.Transaction{TxDate: date, TxAmount: 1, TxDesc: " ", TxCurrency: "CAD"}, "description"},
+		{"currency", domain.Transaction{TxDate: date, TxAmount: 1, TxDesc: "A", TxCurrency: "CDN"}, "currency"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Check([]*domain.Transaction{&tt.tx}, now, nil)
+			switch {
+			case tt.rule == "" && len(problems) > 0:
+				t.Errorf("problems = %v", problems)
+			case tt.rule != "" && (len(problems) != 1 || problems[0].Rule != tt.rule):
+				t.Errorf("problems = %v, want one %s", problems, tt.rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package importer
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// OpeningBalance works out what an account held before the first of its statement lines, from the
+// balance the statement prints after a line and the amounts up to it. date is the day before that
+// first line, the last one the balance describes. ok is false when no line carries a balance.
+// Pending lines are left out, statements don't count them.
+func OpeningBalance(transactions []*Transaction) (balance float64, date time.Time, ok bool) {
+	var posted []*Transaction
+	for _, tx := range transactions {
+		if !tx.Pending {
+			posted = append(posted, tx)
+		}
+	}
+	// Lines of one day keep the statement's order, the balance follows it
+	slices.SortStableFunc(posted, func(a, b *Transaction) int { return a.TxDate.Compare(b.TxDate) })
+
+	var moved float64
+	for _, tx := range posted {
+		if tx.TxDirection == Out {
+			moved -= tx.TxAmount
+		} else {
+			moved += tx.TxAmount
+		}
+		if tx.Balance != nil {
+			opening := math.Round((*tx.Balance-moved)*100) / 100
+			return opening, posted[0].TxDate.AddDate(0, 0, -1), true
+		}
+	}
+	return 0, time.Time{}, false
+}
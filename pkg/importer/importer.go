@@ -0,0 +1,71 @@
+// Package importer is the statement import pipeline as a library, for programs that want to embed
+// it instead of running the CLI. It never prompts and never prints.
+//
+// An import is three steps:
+//
+//	parsed, err := importer.Parse(importer.ParseOptions{Path: "statements"})
+//	resolved, err := importer.Resolve(importer.ResolveOptions{Transactions: parsed.Transactions, Periods: parsed.Periods, Accounts: accounts})
+//	uploaded, err := importer.Upload(ctx, importer.UploadOptions{Backend: c, UserID: user, Transactions: resolved.Ready})
+//
+// Upload leaves out transactions ariand already has, so running the same import twice creates
+// nothing the second time.
+package importer
+
+import (
+	"io"
+
+	"arian-statement-parser/internal/client"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/rules"
+
+	"github.com/charmbracelet/log"
+)
+
+// Transaction is one statement line
+type Transaction = domain.Transaction
+
+// Direction is whether money came in or went out
+type Direction = domain.Direction
+
+// Directions of a Transaction
+const (
+	In  = domain.In
+	Out = domain.Out
+)
+
+// Account is an ariand account
+type Account = pb.Account
+
+// Client talks to ariand
+type Client = client.Client
+
+// Backend is where transactions are uploaded: a Client, or anything else that takes them
+type Backend = client.Uploader
+
+// Rules categorize and rewrite transactions, see LoadRules
+type Rules = rules.Set
+
+// Connect dials ariand at address, e.g. localhost:55555, with an API key. The client keeps quiet
+// and retries the way the CLI does; close it when done.
+func Connect(address, apiKey string) (*Client, error) {
+	settings := client.DefaultSettings()
+	settings.Logger = log.New(io.Discard)
+
+	c, err := client.NewClientWithSettings(address, apiKey, settings)
+	if err != nil {
+		return nil, err
+	}
+	// Older ariand releases lack some calls; knowing which lets Upload work around them
+	if _, err := c.Handshake(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadRules reads a rules file in the format of arian-rules.json, or the default rules when path
+// doesn't exist
+func LoadRules(path string) (*Rules, error) {
+	return rules.LoadSet(path)
+}
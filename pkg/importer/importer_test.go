@@ -0,0 +1,248 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"arian-statement-parser/internal/client/fake"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+)
+
+const testUser = "00000000-0000-0000-0000-000000000001"
+
+func TestImportTwice(t *testing.T) {
+	server := fake.New("test-key")
+	server.AddUser(testUser, "test@example.com")
+	server.AddAccount(testUser, "Wealthsimple Cash", "Wealthsimple", pb.AccountType_ACCOUNT_CHEQUING)
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+
+	c, err := Connect(addr, "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	accounts, err := c.GetAccounts(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func() *Uploaded {
+		t.Helper()
+		parsed, err := Parse(ParseOptions{Path: "../../internal/parser/testdata/csv/wealthsimple-cash.csv"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resolved, err := Resolve(ResolveOptions{
+			Transactions: parsed.Transactions,
+			Accounts:     accounts,
+			Now:          time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resolved.Ready) != len(parsed.Transactions) || len(resolved.Unresolved) > 0 || len(resolved.Invalid) > 0 {
+			t.Fatalf("resolved %d of %d, %d unresolved, invalid: %v", len(resolved.Ready), len(parsed.Transactions), len(resolved.Unresolved), resolved.Invalid)
+		}
+
+		uploaded, err := Upload(context.Background(), UploadOptions{Backend: c, UserID: testUser, Transactions: resolved.Ready})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uploaded.Failed) > 0 {
+			t.Fatalf("failed: %v", uploaded.Failed)
+		}
+		return uploaded
+	}
+
+	first := upload()
+	if first.Created == 0 || len(first.IDs) != first.Created || first.Existing != 0 {
+		t.Fatalf("first upload = %+v", first)
+	}
+
+	second := upload()
+	if second.Created != 0 || second.Existing != first.Created {
+		t.Fatalf("second upload = %+v, want everything found in ariand", second)
+	}
+	if got := len(server.Transactions()); got != first.Created {
+		t.Fatalf("ariand holds %d transactions, want %d", got, first.Created)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	number := "1234"
+	card := func(desc string, amount float64) *Transaction {
+		return &Transaction{
+			TxDate: time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), TxAmount: amount, TxCurrency: "CAD", TxDesc: desc,
+			StatementAccountNumber: &number, StatementAccountType: "visa", SourceFilePath: "visa.pdf",
+		}
+	}
+	pending := card("HOLD", 5)
+	pending.Pending = true
+	zero := card("NOTHING", 0)
+	unknown := card("ELSEWHERE", 9)
+	unknown.StatementAccountType = "chequing"
+
+	resolved, err := Resolve(ResolveOptions{
+		Transactions: []*Transaction{card("COFFEE", 4.5), pending, zero, unknown},
+		Accounts:     []*Account{{Id: 7, Name: "Visa", Type: pb.AccountType_ACCOUNT_CREDIT_CARD}},
+		Mappings:     map[string]string{number: "visa"},
+		Now:          time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mapping wins over the statement account type, so the chequing line lands on Visa too
+	if len(resolved.Ready) != 2 || resolved.Ready[0].AccountID != 7 {
+		t.Fatalf("ready = %v", resolved.Ready)
+	}
+	if len(resolved.Invalid) != 1 || resolved.Invalid[0].Transaction != zero {
+		t.Fatalf("invalid = %v", resolved.Invalid)
+	}
+	if resolved.Skipped != 1 {
+		t.Fatalf("skipped = %d, want the pending line", resolved.Skipped)
+	}
+
+	resolved, err = Resolve(ResolveOptions{Transactions: []*Transaction{unknown}, Now: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved.Unresolved) != 1 {
+		t.Fatalf("unresolved = %v", resolved.Unresolved)
+	}
+
+	if _, err := Resolve(ResolveOptions{CardPayments: "keep"}); err == nil {
+		t.Fatal("expected an unknown card payment policy to be refused")
+	}
+}
+
+func TestOpeningBalance(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	balance := func(b float64) *float64 { return &b }
+	hold := &Transaction{TxDate: day(1), TxAmount: 80, TxDirection: Out, Pending: true}
+
+	// The statement prints no balance until after the second line of the 3rd
+	transactions := []*Transaction{
+		{TxDate: day(3), TxAmount: 20, TxDirection: Out},
+		{TxDate: day(3), TxAmount: 500, TxDirection: In, Balance: balance(1480)},
+		{TxDate: day(2), TxAmount: 4.5, TxDirection: Out},
+		hold,
+		{TxDate: day(9), TxAmount: 30, TxDirection: Out, Balance: balance(1450)},
+	}
+	opening, date, ok := OpeningBalance(transactions)
+	if !ok || opening != 1004.5 || !date.Equal(day(1)) {
+		t.Fatalf("OpeningBalance = %v on %s, %v, want 1004.5 on %s", opening, date.Format(time.DateOnly), ok, day(1).Format(time.DateOnly))
+	}
+
+	if _, _, ok := OpeningBalance([]*Transaction{{TxDate: day(3), TxAmount: 20}}); ok {
+		t.Fatal("expected no opening balance without a printed one")
+	}
+}
+
+func TestAddInterestCharges(t *testing.T) {
+	number := "1234"
+	line := func(file, desc string, amount float64) *Transaction {
+		return &Transaction{
+			TxDate: time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), TxAmount: amount, TxDirection: Out, TxCurrency: "CAD", TxDesc: desc,
+			StatementAccountNumber: &number, StatementAccountType: "visa", SourceFilePath: file,
+		}
+	}
+	amount := func(a float64) *float64 { return &a }
+	transactions := []*Transaction{
+		line("may.pdf", "COFFEE", 4.5),
+		line("june.pdf", "COFFEE", 4.5),
+		line("june.pdf", "PURCHASE INTEREST", 12.34),
+	}
+	statements := map[string]*Statement{
+		"may.pdf":  {InterestCharged: amount(7.89), ClosingDate: "2024-05-14"},
+		"june.pdf": {InterestCharged: amount(12.34), ClosingDate: "2024-06-14"},
+		"none.pdf": {InterestCharged: amount(1)},
+	}
+
+	transactions, added := AddInterestCharges(transactions, statements, "interest")
+	if added != 1 || len(transactions) != 4 {
+		t.Fatalf("added %d, %d transactions, want only May's interest", added, len(transactions))
+	}
+	interest := transactions[3]
+	if interest.TxAmount != 7.89 || interest.Category != "interest" || interest.SourceFilePath != "may.pdf" ||
+		interest.TxDate.Format(time.DateOnly) != "2024-05-14" || AccountKey(interest) != number {
+		t.Fatalf("interest line = %+v", interest)
+	}
+}
+
+func TestSplitLoanPayments(t *testing.T) {
+	payment := func(account string) *Transaction {
+		return &Transaction{
+			TxDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), TxAmount: 1200, TxDirection: In, TxDesc: "REGULAR PAYMENT",
+			Loan: &domain.LoanSplit{Principal: 812.40, Interest: 387.60}, StatementAccountName: account, StatementAccountType: "loan",
+		}
+	}
+	transactions := []*Transaction{payment("mortgage"), payment("car loan")}
+
+	split := func(tx *Transaction) bool { return tx.StatementAccountName == "mortgage" }
+	transactions, count := SplitLoanPayments(transactions, split, "interest")
+	if count != 1 || len(transactions) != 3 {
+		t.Fatalf("split %d, %d transactions, want the mortgage payment in two", count, len(transactions))
+	}
+
+	principal, interest, single := transactions[0], transactions[1], transactions[2]
+	if principal.TxAmount != 812.40 || principal.TxDesc != "REGULAR PAYMENT - PRINCIPAL" || principal.Loan != nil {
+		t.Fatalf("principal = %+v", principal)
+	}
+	if interest.TxAmount != 387.60 || interest.Category != "interest" || interest.Method != domain.MethodFee || interest.TxDirection != In {
+		t.Fatalf("interest = %+v", interest)
+	}
+	if principal.UserNotes != "payment: 2024-03-01 1200.00" || interest.UserNotes != principal.UserNotes {
+		t.Fatalf("notes don't link the parts: %q, %q", principal.UserNotes, interest.UserNotes)
+	}
+	if single.Loan == nil || !strings.Contains(single.Notes(), "principal: 812.40\ninterest: 387.60") {
+		t.Fatalf("unsplit payment notes = %q", single.Notes())
+	}
+}
+
+func TestCategorizeInterest(t *testing.T) {
+	transactions := []*Transaction{
+		{TxDesc: "GIC INT", Kind: domain.KindInterest},
+		{TxDesc: "INTEREST PAID", Kind: domain.KindInterest, Category: "savings"},
+		{TxDesc: "COFFEE"},
+	}
+	if categorized := CategorizeInterest(transactions, "interest-income"); categorized != 1 {
+		t.Fatalf("categorized %d, want 1", categorized)
+	}
+	if transactions[0].Category != "interest-income" || transactions[1].Category != "savings" || transactions[2].Category != "" {
+		t.Fatalf("categories = %q, %q, %q", transactions[0].Category, transactions[1].Category, transactions[2].Category)
+	}
+}
+
+func TestInvertSigns(t *testing.T) {
+	// A card export that lists purchases as positive, read as if it didn't
+	card := func(desc string, direction Direction, kind domain.Kind) *Transaction {
+		return &Transaction{TxAmount: 50, TxDirection: direction, TxDesc: desc, Kind: kind, StatementAccountName: "card", StatementAccountType: "visa"}
+	}
+	purchase := card("GROCERY STORE", In, domain.KindRefund)
+	payment := card("PAYMENT - THANK YOU", Out, domain.KindPurchase)
+	other := card("GROCERY STORE", Out, domain.KindPurchase)
+	other.StatementAccountName = "other card"
+
+	invert := func(tx *Transaction) bool { return tx.StatementAccountName == "card" }
+	if count := InvertSigns([]*Transaction{purchase, payment, other}, invert); count != 2 {
+		t.Fatalf("inverted %d, want 2", count)
+	}
+	if purchase.TxDirection != Out || purchase.Kind != domain.KindPurchase {
+		t.Errorf("purchase = %v, kind %v", purchase.TxDirection, purchase.Kind)
+	}
+	if payment.TxDirection != In || payment.Kind != domain.KindCardPayment {
+		t.Errorf("payment = %v, kind %v", payment.TxDirection, payment.Kind)
+	}
+	if other.TxDirection != Out || other.Kind != domain.KindPurchase {
+		t.Errorf("other account was changed: %v, kind %v", other.TxDirection, other.Kind)
+	}
+}
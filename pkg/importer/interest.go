@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"math"
+	"regexp"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// Statement is what a card statement's summary says besides its lines
+type Statement = domain.Statement
+
+// interestLine matches a statement line that already records interest, in English or French
+var interestLine = regexp.MustCompile(`(?i)interest|int[ée]r[êe]t`)
+
+// AddInterestCharges adds a line for the interest each card statement says it charged, on the
+// statement's closing day and in category, unless the statement already lists one for that
+// amount. statements are keyed by file, as Parsed has them. The new line belongs to the account of
+// the statement's other lines, so a statement with none gets nothing. Returns the transactions and
+// how many were added.
+func AddInterestCharges(transactions []*Transaction, statements map[string]*Statement, category string) ([]*Transaction, int) {
+	byFile := make(map[string][]*Transaction)
+	for _, tx := range transactions {
+		byFile[tx.SourceFilePath] = append(byFile[tx.SourceFilePath], tx)
+	}
+
+	added := 0
+	for file, statement := range statements {
+		lines := byFile[file]
+		if statement == nil || statement.InterestCharged == nil || *statement.InterestCharged <= 0 || len(lines) == 0 {
+			continue
+		}
+		interest := *statement.InterestCharged
+		if listsInterest(lines, interest) {
+			continue
+		}
+
+		// US dollar sections are accounts of their own, the summary is about the main one
+		account := lines[0]
+		closing := lines[0].TxDate
+		for _, tx := range lines {
+			if tx.TxCurrency == "CAD" && account.TxCurrency != "CAD" {
+				account = tx
+			}
+			if tx.TxDate.After(closing) {
+				closing = tx.TxDate
+			}
+		}
+		if date, ok := statement.Closing(); ok {
+			closing = date
+		}
+
+		transactions = append(transactions, &Transaction{
+			TxDate:                 closing,
+			TxAmount:               interest,
+			TxCurrency:             account.TxCurrency,
+			TxDirection:            Out,
+			TxDesc:                 "INTEREST CHARGED",
+			Confidence:             1,
+			Method:                 domain.MethodFee,
+			Category:               category,
+			StatementAccountNumber: account.StatementAccountNumber,
+			StatementAccountType:   account.StatementAccountType,
+			StatementAccountName:   account.StatementAccountName,
+			StatementBank:          account.StatementBank,
+			SourceFilePath:         file,
+		})
+		added++
+	}
+	return transactions, added
+}
+
+// CategorizeInterest puts interest paid into savings, GICs and term deposits in category, unless
+// something already categorized it. Returns how many lines it categorized.
+func CategorizeInterest(transactions []*Transaction, category string) int {
+	categorized := 0
+	for _, tx := range transactions {
+		if tx.Kind == domain.KindInterest && tx.Category == "" && tx.CategoryID == nil {
+			tx.Category = category
+			categorized++
+		}
+	}
+	return categorized
+}
+
+// listsInterest reports whether a statement has a line charging the interest its summary states
+func listsInterest(lines []*Transaction, interest float64) bool {
+	for _, tx := range lines {
+		if tx.TxDirection == Out && math.Abs(tx.TxAmount-interest) < 0.005 && interestLine.MatchString(tx.TxDesc) {
+			return true
+		}
+	}
+	return false
+}
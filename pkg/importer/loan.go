@@ -0,0 +1,54 @@
+package importer
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"arian-statement-parser/internal/domain"
+)
+
+// SplitLoanPayments replaces each loan payment split says to split with two transactions, one for
+// the principal and one for the interest in category. Both keep the payment's date and direction,
+// and note the payment they came from so they can be told apart from other lines. Payments not
+// split keep their breakdown in the notes. Returns the transactions and how many payments were split.
+func SplitLoanPayments(transactions []*Transaction, split func(*Transaction) bool, category string) ([]*Transaction, int) {
+	out := make([]*Transaction, 0, len(transactions))
+	count := 0
+	for _, tx := range transactions {
+		if tx.Loan == nil || !split(tx) {
+			out = append(out, tx)
+			continue
+		}
+
+		payment := fmt.Sprintf("payment: %s %.2f", tx.TxDate.Format(time.DateOnly), tx.TxAmount)
+		principal, interest := *tx, *tx
+		principal.Loan, interest.Loan = nil, nil
+		interest.ConfidenceReasons = slices.Clone(tx.ConfidenceReasons)
+
+		principal.TxAmount = tx.Loan.Principal
+		principal.TxDesc = tx.TxDesc + " - PRINCIPAL"
+		principal.UserNotes = joinNotes(tx.UserNotes, payment)
+
+		interest.TxAmount = tx.Loan.Interest
+		interest.TxDesc = tx.TxDesc + " - INTEREST"
+		interest.UserNotes = joinNotes(tx.UserNotes, payment)
+		interest.Method = domain.MethodFee
+		interest.Category = category
+		interest.CategoryID = nil
+
+		out = append(out, &principal)
+		if interest.TxAmount > 0 {
+			out = append(out, &interest)
+		}
+		count++
+	}
+	return out, count
+}
+
+func joinNotes(notes, line string) string {
+	if notes == "" {
+		return line
+	}
+	return notes + "\n" + line
+}
@@ -0,0 +1,137 @@
+package importer
+
+import (
+	"fmt"
+
+	"arian-statement-parser/internal/parser"
+	"arian-statement-parser/internal/validate"
+)
+
+// ParseOptions say what to parse and how
+type ParseOptions struct {
+	// Path is a statement file, or a directory of them: PDFs, CSV exports and text statements
+	Path string
+	// ParserConfig is a parser config file, empty for the defaults
+	ParserConfig string
+	// ParserDir is the checkout of the Python PDF parser, rbc-statement-parser when empty
+	ParserDir string
+	// TemplateDir holds templates for text statements and other banks' PDFs, only the built-in ones
+	// are used when empty
+	TemplateDir string
+	// Institution reads every PDF as this bank's statement, "rbc" or a template's name or bank,
+	// instead of detecting it
+	Institution string
+	// CacheDir keeps parse results of PDFs between runs, nothing is cached when empty
+	CacheDir string
+	// Strict fails on any line of a CSV or text statement that can't be read, instead of leaving the
+	// line out and listing it in Parsed.SkippedLines
+	Strict bool
+}
+
+// Period is what is known of the time a single statement covers
+type Period = validate.Period
+
+// SkippedLine is a line of a statement Parse left out because it couldn't be read
+type SkippedLine = parser.SkippedLine
+
+// Parsed is what Parse read
+type Parsed struct {
+	Transactions []*Transaction
+	// Skipped are the files no transactions could be read from
+	Skipped []string
+	// Statements are the summaries of card statements, by file, see AddInterestCharges
+	Statements map[string]*Statement
+	// Periods are the periods of the files that are a single statement, like a PDF, by file. CSV
+	// exports, OFX downloads and backups can span years and have none.
+	Periods map[string]Period
+	// SkippedLines are the lines that couldn't be read, by file, unless ParseOptions.Strict
+	SkippedLines map[string][]SkippedLine
+	// SummaryLines counts the lines left out for only restating a balance or total, by file
+	SummaryLines map[string]int
+
+	cache  *parser.Cache
+	result *parser.ParseResult
+}
+
+// Remember records the statements that were parsed as uploaded in the cache of ParseOptions.CacheDir,
+// so the next version of one with other bytes only gives the lines that changed. Call it once the
+// upload succeeded. Without a cache it does nothing.
+func (p *Parsed) Remember() error {
+	if p.cache == nil {
+		return nil
+	}
+	return p.cache.Remember(p.result)
+}
+
+// Parse reads every statement under opts.Path into transactions
+func Parse(opts ParseOptions) (*Parsed, error) {
+	var cache *parser.Cache
+	pythonParser := parser.NewPythonParser()
+	if opts.Strict {
+		pythonParser.WithStrict()
+	}
+	if opts.ParserDir != "" {
+		pythonParser.WithDir(opts.ParserDir)
+	}
+	if opts.CacheDir != "" {
+		var err error
+		cache, err = parser.NewCache(opts.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		pythonParser.WithCache(cache)
+	}
+
+	templates, err := parser.LoadTemplates(opts.TemplateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load templates: %w", err)
+	}
+	pythonParser.WithTemplates(templates)
+	if opts.Institution != "" {
+		if err := parser.CheckInstitution(opts.Institution, templates); err != nil {
+			return nil, err
+		}
+		pythonParser.WithInstitution(opts.Institution)
+	}
+
+	result, transactions, err := parser.ParseAll(pythonParser, templates, opts.Path, opts.ParserConfig)
+	if err != nil {
+		return nil, fmt.Errorf("parse failed: %w", err)
+	}
+
+	parsed := &Parsed{Transactions: transactions, cache: cache, result: result}
+	regenerated := make(map[string]bool, len(result.Diffs))
+	for _, diff := range result.Diffs {
+		regenerated[diff.File] = true
+	}
+	for _, file := range result.FileResults {
+		if !file.Processed && !regenerated[file.File] {
+			parsed.Skipped = append(parsed.Skipped, file.File)
+		}
+		if file.Statement != nil {
+			if parsed.Statements == nil {
+				parsed.Statements = make(map[string]*Statement)
+			}
+			parsed.Statements[file.File] = file.Statement
+		}
+		if file.SinglePeriod() {
+			if parsed.Periods == nil {
+				parsed.Periods = make(map[string]Period)
+			}
+			parsed.Periods[file.File] = validate.StatementPeriod(file.Statement)
+		}
+		if len(file.SkippedLines) > 0 {
+			if parsed.SkippedLines == nil {
+				parsed.SkippedLines = make(map[string][]SkippedLine)
+			}
+			parsed.SkippedLines[file.File] = file.SkippedLines
+		}
+		if file.SummaryLines > 0 {
+			if parsed.SummaryLines == nil {
+				parsed.SummaryLines = make(map[string]int)
+			}
+			parsed.SummaryLines[file.File] = file.SummaryLines
+		}
+	}
+	return parsed, nil
+}
@@ -0,0 +1,273 @@
+package importer
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"time"
+
+	"arian-statement-parser/internal/dedupe"
+	"arian-statement-parser/internal/domain"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/validate"
+)
+
+// Card payment policies, for "PAYMENT - THANK YOU" lines on card statements
+const (
+	CardPaymentTransfer = "transfer" // upload with the transfer category so reports can leave it out
+	CardPaymentSkip     = "skip"     // don't upload, the chequing side already records the money leaving
+	CardPaymentIncome   = "income"   // upload as a plain credit, like refunds
+)
+
+// Accent policies, for how descriptions and merchant names spell accented letters
+const (
+	AccentsKeep  = "keep"  // as the statement prints them, "Café Dépôt"
+	AccentsASCII = "ascii" // without accents, "Cafe Depot"
+)
+
+// CheckAccentPolicy validates an accent policy, defaulting to keep
+func CheckAccentPolicy(policy string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", AccentsKeep:
+		return AccentsKeep, nil
+	case AccentsASCII:
+		return AccentsASCII, nil
+	}
+	return "", fmt.Errorf("unknown accent policy %q, expected %s or %s", policy, AccentsKeep, AccentsASCII)
+}
+
+// CheckCardPaymentPolicy validates a card payment policy, defaulting to transfer
+func CheckCardPaymentPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return CardPaymentTransfer, nil
+	case CardPaymentTransfer, CardPaymentSkip, CardPaymentIncome:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown card payment policy %q, want transfer, skip or income", policy)
+	}
+}
+
+// ApplyCardPaymentPolicy removes card payments or tags them with the transfer category, depending
+// on policy, and returns how many it removed
+func ApplyCardPaymentPolicy(transactions []*Transaction, policy, category string) ([]*Transaction, int) {
+	kept := make([]*Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		if tx.Kind == domain.KindCardPayment {
+			switch policy {
+			case CardPaymentSkip:
+				continue
+			case CardPaymentTransfer:
+				tx.Category = category
+			}
+		}
+		kept = append(kept, tx)
+	}
+	return kept, len(transactions) - len(kept)
+}
+
+// DropPending removes transactions the bank hasn't posted yet
+func DropPending(transactions []*Transaction) ([]*Transaction, int) {
+	kept := make([]*Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		if !tx.Pending {
+			kept = append(kept, tx)
+		}
+	}
+	return kept, len(transactions) - len(kept)
+}
+
+// AccountKey returns the identifier mappings use for a transaction's statement account
+func AccountKey(tx *Transaction) string {
+	if tx.StatementAccountNumber != nil && *tx.StatementAccountNumber != "" {
+		return *tx.StatementAccountNumber
+	}
+	// CSV exports have no account number, but each one names its account
+	if tx.StatementAccountName != "" {
+		return tx.StatementAccountName
+	}
+	return "Unknown"
+}
+
+// AccountType converts a statement account type, like visa or chequing, to ariand's
+func AccountType(accountType string) pb.AccountType {
+	switch accountType {
+	case "visa":
+		return pb.AccountType_ACCOUNT_CREDIT_CARD
+	case "savings":
+		return pb.AccountType_ACCOUNT_SAVINGS
+	case "chequing":
+		return pb.AccountType_ACCOUNT_CHEQUING
+	case "investment":
+		return pb.AccountType_ACCOUNT_INVESTMENT
+	case "other", "loan":
+		return pb.AccountType_ACCOUNT_OTHER
+	default:
+		return pb.AccountType_ACCOUNT_UNSPECIFIED
+	}
+}
+
+// MatchAccount finds the one account with the statement's name and type. Two that fit equally
+// well are a guess, so that counts as no match.
+func MatchAccount(accounts []*Account, accountName string, accountType string) *Account {
+	expectedType := AccountType(accountType)
+	var match *Account
+	for _, account := range accounts {
+		if account.Type == expectedType && strings.EqualFold(account.Name, accountName) {
+			if match != nil {
+				return nil
+			}
+			match = account
+		}
+	}
+	return match
+}
+
+// ResolveOptions say how transactions are matched to accounts and which are kept
+type ResolveOptions struct {
+	Transactions []*Transaction
+	// Accounts are the user's ariand accounts
+	Accounts []*Account
+	// Mappings map statement accounts, as AccountKey names them, to ariand account names. Accounts
+	// without a mapping are matched by name and type.
+	Mappings map[string]string
+	// Periods are the periods of single statements, by file, see Parsed.Periods. Dates are only
+	// checked against the period of a file that has one.
+	Periods map[string]Period
+	// Rules categorize and rewrite transactions before they are checked, nil for none
+	Rules *Rules
+	// IncludePending keeps transactions the bank hasn't posted yet
+	IncludePending bool
+	// CardPayments is a card payment policy, transfer when empty; CardPaymentCategory is the
+	// category slug the transfer policy sets, transfer when empty
+	CardPayments        string
+	CardPaymentCategory string
+	// InterestIncomeCategory is the category slug of interest paid into an account, interest-income
+	// when empty
+	InterestIncomeCategory string
+	// Accents is an accent policy, keep when empty
+	Accents string
+	// MaxDescription is the longest description kept, longer ones are cut; 255 characters when zero,
+	// no limit when negative
+	MaxDescription int
+	// Now is when the import runs, for spotting dates in the future; time.Now() when zero
+	Now time.Time
+}
+
+// Rejected is a transaction Resolve left out, and why
+type Rejected struct {
+	Transaction *Transaction
+	Reason      string
+}
+
+// Resolved is the outcome of Resolve
+type Resolved struct {
+	// Ready have an account and passed every check, for Upload
+	Ready []*Transaction
+	// Unresolved belong to statement accounts no mapping or single account matched
+	Unresolved []*Transaction
+	// Invalid failed a check, such as an amount of zero or an unknown currency
+	Invalid []Rejected
+	// Skipped counts pending lines, card payments the policy skips, and lines repeated across
+	// statements
+	Skipped int
+	// Excluded counts the lines each exclude in the rules left out
+	Excluded map[string]int
+}
+
+// Resolve applies the card payment policy, categorizes interest income and applies rules, cleans up
+// descriptions, leaves out what the rules exclude, drops duplicates and pending lines, assigns every
+// transaction an account and checks it. It never asks: what needs a person ends up in Unresolved or
+// Invalid.
+func Resolve(opts ResolveOptions) (*Resolved, error) {
+	policy, err := CheckCardPaymentPolicy(opts.CardPayments)
+	if err != nil {
+		return nil, err
+	}
+	accents, err := CheckAccentPolicy(opts.Accents)
+	if err != nil {
+		return nil, err
+	}
+	category := opts.CardPaymentCategory
+	if category == "" {
+		category = "transfer"
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	resolved := &Resolved{}
+	transactions := opts.Transactions
+	var dropped int
+	if !opts.IncludePending {
+		transactions, dropped = DropPending(transactions)
+		resolved.Skipped += dropped
+	}
+	if opts.Rules != nil {
+		ApplyDirectionRules(transactions, opts.Rules)
+	}
+	transactions, dropped = ApplyCardPaymentPolicy(transactions, policy, category)
+	resolved.Skipped += dropped
+	CategorizeInterest(transactions, cmp.Or(opts.InterestIncomeCategory, "interest-income"))
+
+	if opts.Rules != nil {
+		opts.Rules.Apply(transactions)
+	}
+
+	// PDFs leave control characters in descriptions that ariand rejects, and some lines none at all
+	maxDescription := cmp.Or(opts.MaxDescription, domain.MaxDescription)
+	for _, tx := range transactions {
+		if accents == AccentsASCII {
+			tx.FoldAccents()
+		}
+		tx.CleanDescription(maxDescription)
+		tx.FillDescription()
+	}
+	if opts.Rules != nil {
+		transactions, resolved.Excluded = opts.Rules.Exclude(transactions)
+	}
+
+	transactions, duplicates := dedupe.Collapse(transactions)
+	resolved.Skipped += len(duplicates)
+
+	problems := validate.Check(transactions, now, opts.Periods)
+	transactions = validate.Exclude(transactions, problems)
+
+	for _, tx := range transactions {
+		account := resolveAccount(opts, tx)
+		if account == nil {
+			resolved.Unresolved = append(resolved.Unresolved, tx)
+			continue
+		}
+		tx.AccountID = int(account.Id)
+		resolved.Ready = append(resolved.Ready, tx)
+	}
+
+	// A line can break several rules, it is still one rejected line
+	reasons := make(map[*Transaction][]string)
+	for _, problem := range problems {
+		if reasons[problem.Tx] == nil {
+			resolved.Invalid = append(resolved.Invalid, Rejected{Transaction: problem.Tx})
+		}
+		reasons[problem.Tx] = append(reasons[problem.Tx], problem.Rule+": "+problem.Message)
+	}
+	for i := range resolved.Invalid {
+		resolved.Invalid[i].Reason = strings.Join(reasons[resolved.Invalid[i].Transaction], "; ")
+	}
+	return resolved, nil
+}
+
+// resolveAccount finds the account of a transaction through its mapping, or by name and type
+func resolveAccount(opts ResolveOptions, tx *Transaction) *Account {
+	key := AccountKey(tx)
+	if name := opts.Mappings[key]; name != "" {
+		for _, account := range opts.Accounts {
+			if strings.EqualFold(account.Name, name) {
+				return account
+			}
+		}
+		return nil
+	}
+	return MatchAccount(opts.Accounts, key, tx.StatementAccountType)
+}
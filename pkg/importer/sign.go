@@ -0,0 +1,35 @@
+package importer
+
+import (
+	"fmt"
+
+	"arian-statement-parser/internal/parser"
+)
+
+// InvertSigns turns around the lines of statements that sign their amounts the other way, those
+// invert says, like a card export that lists purchases as positive numbers. Each line's kind is
+// worked out again from its new direction. Returns how many lines were turned around.
+func InvertSigns(transactions []*Transaction, invert func(*Transaction) bool) int {
+	count := 0
+	for _, tx := range transactions {
+		if !invert(tx) {
+			continue
+		}
+		tx.Invert()
+		tx.Kind = parser.Classify(tx)
+		tx.Provenance = append(tx.Provenance, fmt.Sprintf("direction: %s, sign inverted", tx.TxDirection))
+		count++
+	}
+	return count
+}
+
+// ApplyDirectionRules sets the direction of lines a rule gives one, like refunds a bank prints as
+// charges, and works out their kind again. It runs before the card payment policy, which goes by
+// direction. Returns how many lines were turned around.
+func ApplyDirectionRules(transactions []*Transaction, rules *Rules) int {
+	changed := rules.ApplyDirections(transactions)
+	for _, tx := range changed {
+		tx.Kind = parser.Classify(tx)
+	}
+	return len(changed)
+}
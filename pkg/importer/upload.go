@@ -0,0 +1,173 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"arian-statement-parser/internal/client"
+	pb "arian-statement-parser/internal/gen/arian/v1"
+	"arian-statement-parser/internal/pipeline"
+)
+
+// DefaultBatchSize is how many transactions go to ariand in one call
+const DefaultBatchSize = 1000
+
+// UploadOptions say what to upload where
+type UploadOptions struct {
+	// Backend is usually a Client from Connect
+	Backend Backend
+	UserID  string
+	// Transactions need an account, as Resolve gives them
+	Transactions []*Transaction
+	// BatchSize is DefaultBatchSize when 0
+	BatchSize int
+	// Concurrency is how many batches are in flight at once, 1 when 0. Each account's transactions
+	// still go up one batch after another, oldest first.
+	Concurrency int
+}
+
+// Uploaded is the outcome of Upload
+type Uploaded struct {
+	Created int
+	// IDs ariand gave the created transactions, when the backend says
+	IDs []int64
+	// Existing counts transactions left out because ariand already had them
+	Existing int
+	Failed   []Rejected
+}
+
+// Upload sends transactions to ariand in batches. Transactions ariand already has, with the same
+// account, date, direction, amount and description, are left out, so an upload that is repeated or
+// resumed after a failure doesn't create copies. The check needs a backend that can list
+// transactions; others get everything.
+func Upload(ctx context.Context, opts UploadOptions) (*Uploaded, error) {
+	if opts.Backend == nil {
+		return nil, fmt.Errorf("no backend to upload to")
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	for _, tx := range opts.Transactions {
+		if tx.AccountID == 0 {
+			return nil, fmt.Errorf("transaction %s %.2f %q has no account, resolve it first", tx.TxDate.Format(time.DateOnly), tx.TxAmount, tx.TxDesc)
+		}
+	}
+
+	uploaded := &Uploaded{}
+	transactions, err := LeaveOutExisting(opts.Backend, opts.UserID, opts.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	uploaded.Existing = len(opts.Transactions) - len(transactions)
+
+	// Running balances depend on the order an account's transactions arrive, so each account's go
+	// oldest first, and concurrent workers never share an account
+	transactions = slices.Clone(transactions)
+	slices.SortStableFunc(transactions, func(a, b *Transaction) int { return a.TxDate.Compare(b.TxDate) })
+	byAccount := func(tx *Transaction) int64 { return int64(tx.AccountID) }
+
+	var mu sync.Mutex
+	upload := pipeline.New(pipeline.Shards("upload", opts.Concurrency, batchSize, byAccount, func(_ context.Context, batch []*Transaction) ([]*Transaction, error) {
+		var created int
+		var ids []int64
+		var errs []error
+		if creator, ok := opts.Backend.(client.IDCreator); ok {
+			ids, errs = creator.CreateTransactionsWithIDs(opts.UserID, batch)
+			created = len(ids)
+		} else {
+			var count int32
+			count, errs = opts.Backend.CreateTransactionsBulk(opts.UserID, batch)
+			created = int(count)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		uploaded.Created += created
+		uploaded.IDs = append(uploaded.IDs, ids...)
+		// ariand takes or refuses a batch whole, so a failure is put on every line of it
+		if len(errs) > 0 {
+			for _, tx := range batch {
+				uploaded.Failed = append(uploaded.Failed, Rejected{Transaction: tx, Reason: errs[0].Error()})
+			}
+		}
+		return nil, nil
+	}))
+	_, err = upload.Run(ctx, transactions)
+	return uploaded, err
+}
+
+// LeaveOutExisting drops the transactions ariand already has. Identical lines on one day are real,
+// two coffees say, so each stored transaction accounts for one upload only. A backend that can't
+// list transactions gets them all back.
+func LeaveOutExisting(backend Backend, userID string, transactions []*Transaction) ([]*Transaction, error) {
+	lister, ok := backend.(client.RangeLister)
+	if !ok || len(transactions) == 0 {
+		return transactions, nil
+	}
+	if checker, ok := backend.(interface{ Supports(client.Feature) bool }); ok && !checker.Supports(client.FeatureHistory) {
+		return transactions, nil
+	}
+
+	accounts := make(map[int64]bool)
+	start, end := transactions[0].TxDate, transactions[0].TxDate
+	for _, tx := range transactions {
+		accounts[int64(tx.AccountID)] = true
+		if tx.TxDate.Before(start) {
+			start = tx.TxDate
+		}
+		if tx.TxDate.After(end) {
+			end = tx.TxDate
+		}
+	}
+	accountIDs := make([]int64, 0, len(accounts))
+	for id := range accounts {
+		accountIDs = append(accountIDs, id)
+	}
+
+	// A day either side, so time zones can't push a stored date out of the range
+	existing, err := lister.ListTransactionsBetween(userID, accountIDs, start.AddDate(0, 0, -1), end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check what ariand already has: %w", err)
+	}
+
+	stored := make(map[string]int, len(existing))
+	for _, tx := range existing {
+		stored[storedKey(tx)]++
+	}
+
+	kept := make([]*Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		k := uploadKey(tx)
+		if stored[k] > 0 {
+			stored[k]--
+			continue
+		}
+		kept = append(kept, tx)
+	}
+	return kept, nil
+}
+
+// uploadKey identifies a transaction the way it is stored in ariand
+func uploadKey(tx *Transaction) string {
+	direction := pb.TransactionDirection_DIRECTION_INCOMING
+	if tx.TxDirection == Out {
+		direction = pb.TransactionDirection_DIRECTION_OUTGOING
+	}
+	return matchKey(int64(tx.AccountID), tx.TxDate, direction, int64(math.Round(tx.TxAmount*100)), tx.TxDesc)
+}
+
+// storedKey identifies a transaction listed from ariand
+func storedKey(tx *pb.Transaction) string {
+	amount := tx.GetTxAmount()
+	cents := int64(math.Round(float64(amount.GetUnits())*100 + float64(amount.GetNanos())/1e7))
+	return matchKey(tx.GetAccountId(), tx.GetTxDate().AsTime(), tx.GetDirection(), cents, tx.GetDescription())
+}
+
+func matchKey(accountID int64, date time.Time, direction pb.TransactionDirection, cents int64, description string) string {
+	return fmt.Sprintf("%d|%s|%d|%d|%s", accountID, date.UTC().Format(time.DateOnly), direction, cents, description)
+}
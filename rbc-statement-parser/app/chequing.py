@@ -4,8 +4,19 @@ from typing import Dict, List, Optional
 
 from bs4 import BeautifulSoup
 
-from .entities import Transaction
-from .utils import match_category, parse_float, read_pdf, should_exclude
+from .entities import Profile, SkippedLine, Transaction
+from .utils import (
+  PAT_PAGE_DIV,
+  currency_sections,
+  extract_french_period_start,
+  flag,
+  in_ranges,
+  match_category,
+  parse_float,
+  read_pdf,
+  section_currency,
+  should_exclude,
+)
 
 PAT_FILE_PATH = r"(chequing|daily|savings|student)"
 PAT_MONTH_SHORT = r"jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec"
@@ -15,6 +26,31 @@ PAT_YEAR = r"\d{4}"
 PAT_DATE_SHORT = rf"{PAT_DAY} (?:{PAT_MONTH_SHORT})"
 PAT_DATE_LONG = rf"((?:{PAT_MONTH_LONG})) ({PAT_DAY})(?:, )?({PAT_YEAR})?"
 PAT_AMOUNT = r"-?\$?[\d,]+\.\d{2}"
+PAT_ACCOUNT = r"^(RBC .+?)\s+(\d{5}-\d{7})$"
+PAT_INTEREST = r"\binterest\b|\bint[ée]r[êe]ts?\b|\b(?:GIC|term deposit) INT\b|\bINT (?:PAID|GIC)\b"
+# The opening and closing balances are printed in the description column with no amount to go with them
+PAT_BALANCE_LINE = r"\bbalance\b|\bsolde\b"
+# Savings and GIC statements print the year's interest once more for tax purposes
+PAT_TAX_SUMMARY = r"tax year|for tax purposes|\bT5\b|fins de l[’']impôt|année d[’']imposition"
+
+# Left offsets in points of each column on RBC personal banking statements, a profile can override any of them
+DEFAULT_COLUMNS = {
+  "date": [[10, 20], [40, 50]],
+  "description": [[60, 75], [85, 100]],
+  "withdrawal": [[250, 360]],
+  "deposit": [[360, 460]],
+  "balance": [[460, 600]],
+}
+
+
+# English and French headings of chequing and savings statements
+CHEQUING_HEADINGS = [
+  "personal banking account statement",
+  "personal savings account statement",
+  "relevé de compte bancaire personnel",
+  "relevé de compte d'épargne personnel",
+  "relevé de compte d’épargne personnel",
+]
 
 
 def is_chequing(file_path: str) -> bool:
@@ -29,8 +65,7 @@ def is_chequing(file_path: str) -> bool:
   try:
     pdf_text = read_pdf(file_path)[:2000]
     # Both chequing and savings use the same parsing logic
-    return ("personal banking account statement" in pdf_text.lower() or
-            "personal savings account statement" in pdf_text.lower())
+    return any(heading in pdf_text.lower() for heading in CHEQUING_HEADINGS)
   except:
     return False
 
@@ -46,7 +81,7 @@ def extract_start_date(pdf: str) -> datetime | None:
 
     return datetime.strptime(f"{start_month} {start_day} {start_year}", "%B %d %Y")
 
-  return None
+  return extract_french_period_start(pdf)
 
 
 def parse_date(string: str) -> datetime:
@@ -72,10 +107,10 @@ def extract_left_padding(soup: BeautifulSoup) -> float:
   return padding
 
 
-def extract_date(soup: BeautifulSoup, start_date: datetime) -> Optional[str]:
+def extract_date(soup: BeautifulSoup, start_date: datetime, columns: dict = DEFAULT_COLUMNS) -> Optional[str]:
   padding = extract_left_padding(soup)
 
-  if (10 < padding < 20 or 40 < padding < 50) and re.match(
+  if in_ranges(padding, columns["date"]) and re.match(
     rf"^{PAT_DATE_SHORT}$", soup.text, re.IGNORECASE
   ):
     ref_date = parse_date(f"{soup.text} {start_date.year}")
@@ -86,37 +121,37 @@ def extract_date(soup: BeautifulSoup, start_date: datetime) -> Optional[str]:
   return None
 
 
-def extract_description(soup: BeautifulSoup) -> Optional[str]:
+def extract_description(soup: BeautifulSoup, columns: dict = DEFAULT_COLUMNS) -> Optional[str]:
   padding = extract_left_padding(soup)
 
-  if 60 < padding < 75 or 85 < padding < 100:
+  if in_ranges(padding, columns["description"]):
     return soup.text
 
   return None
 
 
-def extract_withdrawal_amount(soup: BeautifulSoup) -> Optional[float]:
+def extract_withdrawal_amount(soup: BeautifulSoup, columns: dict = DEFAULT_COLUMNS) -> Optional[float]:
   padding = extract_left_padding(soup)
 
-  if 250 < padding < 360 and re.match(rf"^{PAT_AMOUNT}$", soup.text, re.IGNORECASE):
+  if in_ranges(padding, columns["withdrawal"]) and re.match(rf"^{PAT_AMOUNT}$", soup.text, re.IGNORECASE):
     return parse_float(soup.text)
 
   return None
 
 
-def extract_deposit_amount(soup: BeautifulSoup) -> Optional[float]:
+def extract_deposit_amount(soup: BeautifulSoup, columns: dict = DEFAULT_COLUMNS) -> Optional[float]:
   padding = extract_left_padding(soup)
 
-  if 360 < padding < 460 and re.match(rf"^{PAT_AMOUNT}$", soup.text, re.IGNORECASE):
+  if in_ranges(padding, columns["deposit"]) and re.match(rf"^{PAT_AMOUNT}$", soup.text, re.IGNORECASE):
     return parse_float(soup.text)
 
   return None
 
 
-def extract_balance_amount(soup: BeautifulSoup) -> Optional[float]:
+def extract_balance_amount(soup: BeautifulSoup, columns: dict = DEFAULT_COLUMNS) -> Optional[float]:
   padding = extract_left_padding(soup)
 
-  if 460 < padding < 600 and re.match(rf"^{PAT_AMOUNT}$", soup.text, re.IGNORECASE):
+  if in_ranges(padding, columns["balance"]) and re.match(rf"^{PAT_AMOUNT}$", soup.text, re.IGNORECASE):
     return parse_float(soup.text)
 
   return None
@@ -126,41 +161,117 @@ def parse_chequing(
   pdf_path: str,
   categories: Dict[str, List[str]] = None,
   excludes: List[str] = None,
+  profile: Optional[Profile] = None,
+  skipped: Optional[List[SkippedLine]] = None,
 ) -> List[Transaction]:
-  pdf = read_pdf(pdf_path, html=True)
+  """Read the lines of a chequing or savings statement. A line whose description was read but no
+  amount after it is left out, and added to skipped when given."""
+  profile = profile or {}
+  columns = {**DEFAULT_COLUMNS, **profile.get("columns", {})}
+  sections = currency_sections(profile)
+  pdf = read_pdf(pdf_path, html=True, ignore_regions=profile.get("ignore_regions"))
   start_date = extract_start_date(pdf)
   lines = pdf.splitlines()
   transactions = []
   pat = r"^<p.*</p>$"
 
   tx = {}
+  fragments = 0
+
+  # The balance column is printed after each day's lines, so every amount since the last printed
+  # balance can be checked against the next one
+  running = None
+  unchecked = []
+
+  # Statements with a US dollar account print it as its own section, often with its own number
+  currency = None
+  account = None
+
+  # Where the line being read started, its page and its place among the text found on that page
+  page, row = 1, 0
+  started = (1, 0)
+
+  def skip(reason: str):
+    if skipped is None or not tx.get("description") or re.search(PAT_BALANCE_LINE, tx["description"], re.IGNORECASE):
+      return
+    skipped.append({"page": started[0], "line": started[1], "reason": f"{reason}: {tx['description']}"})
 
   for line in lines:
+    if match := re.match(PAT_PAGE_DIV, line):
+      page, row = int(match.group(1)) + 1, 0
     if re.match(pat, line, re.IGNORECASE):
+      row += 1
       soup = BeautifulSoup(line, "html.parser")
 
-      if date := extract_date(soup, start_date=start_date):
+      if section := section_currency(soup.text, sections):
+        skip("no amount before the next section")
+        currency = section
+        running = None
+        unchecked = []
+        tx = {}
+        continue
+
+      if match := re.match(PAT_ACCOUNT, soup.text.strip(), re.IGNORECASE):
+        account = (re.sub(r"TM$", "", match.group(1).strip()), match.group(2))
+        if section := section_currency(match.group(1), sections):
+          currency = section
+        continue
+
+      if date := extract_date(soup, start_date=start_date, columns=columns):
         tx["date"] = date
-      elif tx.get("date") and extract_description(soup):
+      elif tx.get("date") and extract_description(soup, columns):
+        fragments += 1
         if tx.get("description"):
           tx["description"] += f" {soup.text}"
         else:
           tx["description"] = soup.text
-      elif tx.get("description") and extract_withdrawal_amount(soup):
+          started = (page, row)
+      elif tx.get("description") and extract_withdrawal_amount(soup, columns):
         tx["amount"] = parse_float(soup.text) * -1
-      elif tx.get("description") and extract_deposit_amount(soup):
+      elif tx.get("description") and extract_deposit_amount(soup, columns):
         tx["amount"] = parse_float(soup.text)
+      elif (printed := extract_balance_amount(soup, columns)) is not None:
+        if running is not None and unchecked and abs(running - printed) > 0.005:
+          for checked in unchecked:
+            flag(checked, 0.5, f"running balance {running:.2f} does not match the statement's {printed:.2f}")
+        running = printed
+        unchecked = []
 
       if validate_transaction(tx):
         tx["method"] = "chequing"
         tx["category"] = match_category(tx.get("description"), categories)
         tx["posting_date"] = tx.get("date")
 
+        if currency:
+          tx["currency"] = currency
+        if account:
+          tx["account_name"], tx["account_number"] = account
+
+        if fragments > 2:
+          flag(tx, 0.7, f"description joined from {fragments} lines")
+
+        if running is not None:
+          running += tx["amount"]
+          tx["balance"] = round(running, 2)
+
         if not should_exclude(tx.get("description"), excludes):
           transactions.append(tx)
+          unchecked.append(tx)
 
         tx = {
           "date": tx.get("date"),
         }
+        fragments = 0
 
+  skip("no amount before the end of the statement")
+  flag_tax_summaries(transactions)
   return transactions
+
+
+def flag_tax_summaries(transactions: List[Transaction]):
+  """A tax summary line counts as the interest when nothing else pays it, otherwise it may repeat the postings"""
+  interest = [tx for tx in transactions if tx["amount"] > 0 and re.search(PAT_INTEREST, tx["description"], re.IGNORECASE)]
+  summaries = [tx for tx in interest if re.search(PAT_TAX_SUMMARY, tx["description"], re.IGNORECASE)]
+  if len(summaries) < len(interest):
+    for tx in summaries:
+      flag(tx, 0.6, "interest summary for tax purposes, the interest may already be posted")
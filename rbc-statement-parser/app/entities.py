@@ -2,10 +2,27 @@ from datetime import datetime
 from typing import Dict, List, Optional, TypedDict
 
 
+class Region(TypedDict, total=False):
+  page: int  # 1-based, 0 or missing means every page
+  top: float  # points from the top of the page
+  bottom: float
+
+
+class Profile(TypedDict, total=False):
+  name: str
+  header_keywords: List[str]  # any of these in the first page selects the profile
+  layout: str  # "chequing", "visa", "investment" or "loan"
+  account_type: str  # overrides detection, e.g. "savings"
+  columns: Dict[str, List[List[float]]]  # column name to [min, max] left offsets in points
+  ignore_regions: List[Region]
+  currency_sections: Dict[str, List[str]]  # currency code to heading regexes, added to the defaults
+
+
 class Config(TypedDict, total=False):
   format: Optional[str]
   categories: Optional[Dict[str, List[str]]]
   excludes: Optional[List[str]]
+  profiles: Optional[List[Profile]]
 
 
 class Transaction(TypedDict, total=False):
@@ -16,3 +33,24 @@ class Transaction(TypedDict, total=False):
   category: Optional[str]
   code: Optional[str]
   posting_date: datetime
+  currency: str  # only set once a section heading names one, CAD otherwise
+  confidence: float  # 1.0 unless something about the row looked off
+  confidence_reasons: List[str]
+  balance: float  # after this line, once the statement has printed one to count from
+  principal: float  # the part of a loan payment that paid down the loan
+  interest: float  # the part of a loan payment that paid interest
+  page: int  # 1-based page of the PDF the line is printed on
+  line: int  # the line's place among the lines found on that page, 1-based
+
+
+class SkippedLine(TypedDict):
+  page: int  # 1-based page of the PDF the line starts on
+  line: int  # the line's place among the text found on that page, 1-based
+  reason: str
+
+
+class Statement(TypedDict, total=False):
+  credit_limit: float
+  interest_charged: float
+  minimum_payment: float
+  closing_date: str  # YYYY-MM-DD, the last day the statement covers
@@ -0,0 +1,51 @@
+import re
+from typing import Optional
+
+import fitz
+
+# How much of the first page names the bank, its header and the start of the first table
+HEAD_LENGTH = 3000
+
+# What each bank calls itself on its statements, by the id the parser reports
+INSTITUTIONS = {
+  "rbc": [r"\broyal bank\b", r"\brbc\b", r"\bbanque royale\b"],
+  "td": [r"\btd canada trust\b", r"\btoronto-dominion\b", r"\btd bank\b"],
+  "scotiabank": [r"\bscotiabank\b", r"\bbank of nova scotia\b"],
+  "bmo": [r"\bbmo\b", r"\bbank of montreal\b", r"\bbanque de montr[ée]al\b"],
+  "cibc": [r"\bcibc\b", r"\bcanadian imperial bank\b"],
+  "desjardins": [r"\bdesjardins\b"],
+  "tangerine": [r"\btangerine\b"],
+  "chase": [r"\bjpmorgan chase\b", r"\bchase\.com\b", r"\bchase card services\b"],
+  "bofa": [r"\bbank of america\b", r"\bbankofamerica\.com\b"],
+  "capitalone": [r"\bcapital one\b", r"\bcapitalone\.com\b"],
+}
+
+
+def find_institution(text: str) -> Optional[str]:
+  """The bank named first in text. A statement names its own bank in the header, before any line that
+  pays another one."""
+  text = text.lower()
+  found, first = None, len(text)
+
+  for institution, markers in INSTITUTIONS.items():
+    for marker in markers:
+      if (match := re.search(marker, text)) and match.start() < first:
+        found, first = institution, match.start()
+
+  return found
+
+
+def detect_institution(pdf_path: str) -> Optional[str]:
+  """Tell which bank a statement is from by the PDF's metadata, or else by its first page"""
+  try:
+    document = fitz.open(pdf_path)
+  except Exception:
+    return None
+
+  metadata = " ".join(value for value in (document.metadata or {}).values() if isinstance(value, str))
+  if institution := find_institution(metadata):
+    return institution
+
+  if len(document) == 0:
+    return None
+  return find_institution(document.load_page(0).get_text("text")[:HEAD_LENGTH])
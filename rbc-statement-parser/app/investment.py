@@ -0,0 +1,171 @@
+import re
+from datetime import datetime
+from typing import List, Optional
+
+from .entities import Profile, Transaction
+from .utils import extract_french_period_start, flag, match_category, parse_float, read_pdf, should_exclude
+
+PAT_FILE_PATH = r"(direct.?investing|brokerage|investment.*statement|statement.*investment|placements.?en.?direct)"
+PAT_MONTH_SHORT = r"jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec"
+PAT_MONTH = rf"(?:january|february|march|april|june|july|august|september|october|november|december|{PAT_MONTH_SHORT})\.?"
+PAT_DAY = r"\d{1,2}"
+PAT_YEAR = r"\d{4}"
+PAT_DATE_SHORT = rf"(?:{PAT_MONTH_SHORT}) {PAT_DAY}"
+PAT_DATE_LONG = rf"({PAT_MONTH}) ({PAT_DAY})(?:, )?({PAT_YEAR})?"
+# Brokers print money going out in parentheses as often as with a minus
+PAT_AMOUNT = r"\(?-?\$?[\d,]+\.\d{2}\)?"
+PAT_NUMBER = r"-?[\d,]+(?:\.\d+)?"
+
+# English and French headings of RBC Direct Investing statements, for telling them apart by content
+INVESTMENT_HEADINGS = [
+  "rbc direct investing",
+  "placements en direct rbc",
+  "rbc placements en direct",
+]
+
+# Activity column values, English and French, to the method and the way the money goes: -1 out of the
+# account, 1 into it, 0 as the printed sign says
+ACTIVITIES = {
+  "buy": ("trade", -1),
+  "bought": ("trade", -1),
+  "achat": ("trade", -1),
+  "sell": ("trade", 1),
+  "sold": ("trade", 1),
+  "vente": ("trade", 1),
+  "dividend": ("dividend", 1),
+  "dividende": ("dividend", 1),
+  "distribution": ("dividend", 1),
+  "interest": ("fee", 1),
+  "intérêts": ("fee", 1),
+  "fee": ("fee", -1),
+  "frais": ("fee", -1),
+  "commission": ("fee", -1),
+  "withholding tax": ("fee", -1),
+  "retenue d'impôt": ("fee", -1),
+  "contribution": ("deposit", 1),
+  "cotisation": ("deposit", 1),
+  "deposit": ("deposit", 1),
+  "dépôt": ("deposit", 1),
+  "withdrawal": ("online", -1),
+  "retrait": ("online", -1),
+  "transfer": ("online", 0),
+  "transfert": ("online", 0),
+}
+
+# Trades and dividends name the security in the symbol column, the first thing after the activity
+SECURITY_METHODS = {"trade", "dividend"}
+PAT_SYMBOL = r"[A-Z][A-Z0-9]{0,5}(?:[.-][A-Z0-9]{1,3})?"
+
+
+def extract_start_date(pdf: str) -> Optional[datetime]:
+  regex = rf"(?:from|period:?)\s+{PAT_DATE_LONG} to {PAT_DATE_LONG}"
+
+  if match := re.search(regex, pdf, re.IGNORECASE):
+    start_year = match[3] or match[6]
+    if start_year:
+      return parse_date(f"{match[1]} {match[2]} {start_year}")
+
+  return extract_french_period_start(pdf)
+
+
+def parse_date(string: str) -> datetime:
+  month, day, year = string.replace(".", "").split()
+  return datetime.strptime(f"{month[:3]} {day} {year}", "%b %d %Y")
+
+
+def parse_amount(string: str) -> float:
+  """Read an amount, parentheses meaning negative"""
+  negative = string.startswith("(") and string.endswith(")")
+  amount = parse_float(string.strip("()"))
+  return -abs(amount) if negative else amount
+
+
+def parse_activity(
+  line: str,
+  start_date: datetime,
+  categories: dict,
+  excludes: list,
+) -> Optional[Transaction]:
+  activities = "|".join(re.escape(name) for name in sorted(ACTIVITIES, key=len, reverse=True))
+  if (
+    match := re.match(
+      rf"^({PAT_DATE_SHORT})\s+({activities})\b\s*(.*?)\s*({PAT_AMOUNT})$",
+      line,
+      re.IGNORECASE,
+    )
+  ) is None:
+    return None
+
+  date, activity, body, amount = match.groups()
+  method, direction = ACTIVITIES[activity.lower()]
+
+  symbol, quantity, price = None, None, None
+  if method in SECURITY_METHODS:
+    if found := re.match(rf"^({PAT_SYMBOL})\s+(.*)$", body):
+      symbol, body = found.groups()
+    if found := re.match(rf"^(.*?)\s+({PAT_NUMBER})\s+\$?({PAT_NUMBER})$", body):
+      body, quantity, price = found.groups()
+
+  description = f"{activity.upper()} {body}".strip() if body else activity.upper()
+  if should_exclude(description, lookup=excludes):
+    return None
+
+  value = parse_amount(amount)
+  if direction:
+    value = abs(value) * direction
+
+  ref_date = parse_date(f"{date} {start_date.year}")
+  ref_year = start_date.year + (1 if ref_date.month < start_date.month else 0)
+
+  tx = {
+    "amount": value,
+    "method": method,
+    "category": match_category(description, lookup=categories),
+    "date": parse_date(f"{date} {ref_year}"),
+    "description": description,
+    "posting_date": parse_date(f"{date} {ref_year}"),
+  }
+
+  # ariand has no investments yet, the security travels in the notes the way Wealthsimple's does
+  if symbol:
+    notes = f"asset: {symbol}"
+    if method == "trade" and quantity:
+      sold = "-" if value > 0 else ""
+      notes = f"asset: {sold}{quantity.replace(',', '').lstrip('-')} {symbol}"
+      if price:
+        notes += f" @ {price.replace(',', '')}"
+    tx["notes"] = notes
+  elif method in SECURITY_METHODS:
+    flag(tx, 0.7, "no security symbol found")
+
+  if method == "trade" and not quantity:
+    flag(tx, 0.7, "no quantity found for the trade")
+
+  return tx
+
+
+def parse_investment(
+  pdf_path: str,
+  categories: Optional[dict],
+  excludes: Optional[list],
+  profile: Optional[Profile] = None,
+) -> List[Transaction]:
+  pdf = read_pdf(pdf_path, ignore_regions=(profile or {}).get("ignore_regions"))
+  start_date = extract_start_date(pdf)
+  if start_date is None:
+    return []
+
+  # Each cell is its own line in the text, a row starts with a date
+  lines = re.sub(
+    rf"\n(?!(?:{PAT_DATE_SHORT})\b)",
+    " ",
+    pdf,
+    flags=re.IGNORECASE,
+  )
+
+  transactions = []
+  for line in lines.splitlines():
+    if tx := parse_activity(line.strip(), start_date, categories or {}, excludes or []):
+      transactions.append(tx)
+
+  return transactions
@@ -0,0 +1,86 @@
+import re
+from datetime import datetime
+from typing import List, Optional
+
+from .entities import Profile, Transaction
+from .utils import flag, match_category, parse_float, read_pdf, should_exclude
+
+PAT_FILE_PATH = r"(mortgage|hypoth[ée]caire|\bloan\b|\bpr[êe]t\b)"
+PAT_MONTH = r"jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec"
+# Loan statements cover a year or more, so every date carries its year
+PAT_DATE = rf"(?:(?:{PAT_MONTH})[a-z]*\.? \d{{1,2}},? \d{{4}}|\d{{4}}-\d{{2}}-\d{{2}})"
+PAT_AMOUNT = r"-?\$?[\d,]+\.\d{2}"
+
+# English and French headings of RBC mortgage and loan statements
+LOAN_HEADINGS = [
+  "mortgage statement",
+  "mortgage annual statement",
+  "rbc royal bank mortgage",
+  "personal loan statement",
+  "relevé de prêt hypothécaire",
+  "relevé annuel de prêt hypothécaire",
+  "relevé de prêt personnel",
+]
+
+
+def parse_date(string: str) -> datetime:
+  if re.match(r"\d{4}-", string):
+    return datetime.strptime(string, "%Y-%m-%d")
+  month, day, year = string.replace(",", "").replace(".", "").split()
+  return datetime.strptime(f"{month[:3]} {day} {year}", "%b %d %Y")
+
+
+def parse_payment(line: str, categories: dict, excludes: list) -> Optional[Transaction]:
+  """Read a payment row: date, description, payment, principal, interest and maybe the balance left"""
+  if (
+    match := re.match(
+      rf"^({PAT_DATE})\s+(.*?)\s+({PAT_AMOUNT})\s+({PAT_AMOUNT})\s+({PAT_AMOUNT})(?:\s+({PAT_AMOUNT}))?$",
+      line,
+      re.IGNORECASE,
+    )
+  ) is None:
+    return None
+
+  # The balance is what is still owed, not a running balance of the payments, so it is left out
+  date, description, payment, principal, interest, _ = match.groups()
+  if should_exclude(description, lookup=excludes):
+    return None
+
+  payment, principal, interest = abs(parse_float(payment)), abs(parse_float(principal)), abs(parse_float(interest))
+
+  # A payment reduces what is owed, so like a card payment it is money into the loan account
+  tx = {
+    "amount": payment,
+    "method": "loan",
+    "category": match_category(description, lookup=categories),
+    "date": parse_date(date),
+    "description": description,
+    "posting_date": parse_date(date),
+    "principal": principal,
+    "interest": interest,
+  }
+
+  # Property tax or insurance collected with the payment leaves a part neither principal nor interest
+  if abs(principal + interest - payment) > 0.005:
+    flag(tx, 0.7, f"principal {principal:.2f} and interest {interest:.2f} don't add up to the payment {payment:.2f}")
+
+  return tx
+
+
+def parse_loan(
+  pdf_path: str,
+  categories: Optional[dict],
+  excludes: Optional[list],
+  profile: Optional[Profile] = None,
+) -> List[Transaction]:
+  pdf = read_pdf(pdf_path, ignore_regions=(profile or {}).get("ignore_regions"))
+
+  # Each cell is its own line in the text, a row starts with a date
+  lines = re.sub(rf"\n(?!{PAT_DATE}\b)", " ", pdf, flags=re.IGNORECASE)
+
+  transactions = []
+  for line in lines.splitlines():
+    if tx := parse_payment(line.strip(), categories or {}, excludes or []):
+      transactions.append(tx)
+
+  return transactions
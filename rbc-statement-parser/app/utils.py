@@ -1,17 +1,18 @@
 import os
 import re
-from typing import Optional
+from datetime import datetime
+from typing import Dict, List, Optional, Tuple
 
 import fitz
 
-from .entities import Transaction
+from .entities import Profile, Region, Transaction
 
 
 def parse_float(string: str):
   return float(string.replace("$", "").replace(",", ""))
 
 
-def read_pdf(pdf_path: str, html: bool = False) -> str:
+def read_pdf(pdf_path: str, html: bool = False, ignore_regions: Optional[List[Region]] = None) -> str:
   if not os.path.exists(pdf_path):
     raise FileNotFoundError(f"File {pdf_path} not found")
 
@@ -23,11 +24,245 @@ def read_pdf(pdf_path: str, html: bool = False) -> str:
 
   for page_num in range(len(document)):
     page = document.load_page(page_num)
+    redact_regions(page, page_num + 1, ignore_regions)
     string += page.get_text("html" if html else "text")
 
+  if is_french(string):
+    string = normalize_french(string)
+
   return string
 
 
+def read_pages(pdf_path: str) -> List[str]:
+  """Read the text of each page, normalized the way read_pdf does it"""
+  pages = [page.get_text("text") for page in fitz.open(pdf_path)]
+
+  if is_french("".join(pages)):
+    pages = [normalize_french(page) for page in pages]
+
+  return pages
+
+
+def locate(transactions: List[Transaction], pages: List[str]):
+  """Set the page each line is printed on, and its place among the lines found on that page, by its
+  amount. Statements list lines in order, so the search goes on from where the line before was."""
+  page, offset = 0, 0
+  counts: Dict[int, int] = {}
+
+  for tx in transactions:
+    printed = f"{abs(tx['amount']):,.2f}"
+    amount = re.compile(rf"(?<![\d.,]){re.escape(printed)}(?!\d)")
+    for i in range(page, len(pages)):
+      if match := amount.search(pages[i], offset if i == page else 0):
+        counts[i] = counts.get(i, 0) + 1
+        tx["page"], tx["line"] = i + 1, counts[i]
+        page, offset = i, match.end()
+        break
+
+
+# Points of gap per space in layout_text: a gap between words stays one space, one between columns
+# becomes several
+SPACE_WIDTH = 3.0
+
+
+def layout_text(pdf_path: str) -> str:
+  """Read the text row by row, columns apart, the way pdftotext -layout prints it for the templates"""
+  document = fitz.open(pdf_path)
+  lines = []
+
+  for page in document:
+    start = len(lines)
+    # Words on the same baseline are one row, give or take a point
+    rows: Dict[int, list] = {}
+    for x0, _, x1, y1, word, *_ in page.get_text("words"):
+      rows.setdefault(round(y1 / 2), []).append((x0, x1, word))
+
+    for key in sorted(rows):
+      line, end = "", 0.0
+      for x0, x1, word in sorted(rows[key]):
+        line += " " * max(1 if line else 0, round((x0 - end) / SPACE_WIDTH))
+        line += word
+        end = x1
+      # A form feed starts each page after the first, as pdftotext prints it, so templates can
+      # tell which page a line is on
+      if page.number > 0 and len(lines) == start:
+        line = "\f" + line
+      lines.append(line)
+
+    if page.number > 0 and len(lines) == start:
+      lines.append("\f")
+
+  return "\n".join(lines)
+
+
+# French month names and abbreviations as printed on Québec statements, longest first
+FRENCH_MONTHS = [
+  ("janvier", "January"), ("février", "February"), ("fevrier", "February"), ("avril", "April"),
+  ("juillet", "July"), ("septembre", "September"), ("octobre", "October"), ("novembre", "November"),
+  ("décembre", "December"), ("decembre", "December"),
+  ("janv", "Jan"), ("févr", "Feb"), ("fevr", "Feb"), ("fév", "Feb"), ("mars", "Mar"), ("avr", "Apr"),
+  ("mai", "May"), ("juin", "Jun"), ("juil", "Jul"), ("août", "Aug"), ("aout", "Aug"), ("sept", "Sep"),
+  ("déc", "Dec"),
+]
+
+PAT_FRENCH_MONTH = "|".join(french for french, _ in FRENCH_MONTHS)
+
+# 1 234,56 $ or -1 234,56: spaces (often non-breaking) group thousands and a comma marks the cents
+PAT_FRENCH_AMOUNT = r"(?<![\d,.])(-?)(\d{1,3}(?:[ \u00a0\u202f]\d{3})*),(\d{2})(?:[ \u00a0\u202f]?\$)?(?![\d,])"
+
+
+def is_french(text: str) -> bool:
+  """Québec statements say relevé and solde where English ones say statement and balance"""
+  head = text[:5000].lower()
+  return "relevé" in head or "releve de" in head or "solde" in head
+
+
+def normalize_french(text: str) -> str:
+  """Rewrite French months and amounts the way English statements print them, so one set of patterns reads both"""
+
+  def month(match: re.Match) -> str:
+    word = match.group(1).lower()
+    for french, english in FRENCH_MONTHS:
+      if word == french:
+        return english
+    return match.group(0)
+
+  def amount(match: re.Match) -> str:
+    sign, whole, cents = match.group(1), match.group(2), match.group(3)
+    whole = re.sub(r"[ \u00a0\u202f]", ",", whole)
+    dollar = "$" if "$" in match.group(0) else ""
+    return f"{sign}{dollar}{whole}.{cents}"
+
+  text = re.sub(rf"\b({PAT_FRENCH_MONTH})\b\.?", month, text, flags=re.IGNORECASE)
+  return re.sub(PAT_FRENCH_AMOUNT, amount, text)
+
+
+def month_number(word: str) -> Optional[int]:
+  for layout in ("%B", "%b"):
+    try:
+      return datetime.strptime(word.strip(".").title(), layout).month
+    except ValueError:
+      continue
+  return None
+
+
+# "du 1er mars 2024 au 31 mars 2024", once the months are normalized
+PAT_FRENCH_PERIOD = r"du (\d{1,2})(?:er)? ([a-z]+)\.?,? ?(\d{4})? au (\d{1,2})(?:er)? ([a-z]+)\.?,? ?(\d{4})"
+
+
+def extract_french_period_start(pdf: str) -> Optional[datetime]:
+  """Read the statement's start date from a French period"""
+  if match := re.search(PAT_FRENCH_PERIOD, pdf, re.IGNORECASE):
+    start_month = month_number(match[2])
+    start_year = match[3] or match[6]
+    if start_month:
+      return datetime(int(start_year), start_month, int(match[1]))
+
+  return None
+
+
+def extract_french_period_end(pdf: str) -> Optional[datetime]:
+  """Read the statement's closing date from a French period"""
+  if match := re.search(PAT_FRENCH_PERIOD, pdf, re.IGNORECASE):
+    end_month = month_number(match[5])
+    if end_month:
+      return datetime(int(match[6]), end_month, int(match[4]))
+
+  return None
+
+
+def redact_regions(page, page_number: int, regions: Optional[List[Region]]):
+  """Blank out text in the ignored regions of a page, only in memory"""
+  redacted = False
+
+  for region in regions or []:
+    if region.get("page", 0) not in (0, page_number):
+      continue
+
+    rect = fitz.Rect(0, region.get("top", 0), page.rect.width, region.get("bottom", page.rect.height))
+    page.add_redact_annot(rect)
+    redacted = True
+
+  if redacted:
+    page.apply_redactions()
+
+
+# How read_pdf's HTML starts each page
+PAT_PAGE_DIV = r'^<div id="page(\d+)"'
+
+
+def fragments(html: str) -> List[Tuple[int, float, float, str]]:
+  """List the page, top, left and text of each piece of text in read_pdf's HTML, as the chequing
+  parser sees them when it sorts them into columns"""
+  from bs4 import BeautifulSoup
+
+  found = []
+  page = 1
+
+  for line in html.splitlines():
+    if match := re.match(PAT_PAGE_DIV, line):
+      page = int(match.group(1)) + 1
+    elif line.startswith("<p") and (top := re.search(r"top:([0-9.]+)pt", line)) and (left := re.search(r"left:([0-9.]+)pt", line)):
+      text = BeautifulSoup(line, "html.parser").text
+      found.append((page, float(top.group(1)), float(left.group(1)), text))
+
+  return found
+
+
+def select_profile(pdf_text: str, profiles: Optional[List[Profile]]) -> Optional[Profile]:
+  """Return the first profile with a header keyword in the statement text"""
+  text = pdf_text.lower()
+
+  for profile in profiles or []:
+    if any(keyword.lower() in text for keyword in profile.get("header_keywords", [])):
+      return profile
+
+  return None
+
+
+def flag(tx: Transaction, confidence: float, reason: str):
+  """Lower a transaction's confidence, keeping the lowest score and every reason"""
+  tx["confidence"] = min(tx.get("confidence", 1.0), confidence)
+  tx.setdefault("confidence_reasons", []).append(reason)
+
+
+# Headings that switch the currency of the lines below them, a profile can add its own
+DEFAULT_CURRENCY_SECTIONS = {
+  "USD": [
+    r"^u\.?s\.? dollar (account|statement|savings|chequing|high interest|visa)",
+    r"^rbc u\.?s\.? .*(account|esavings|visa)",
+    r"^(compte|relevé) .*en dollars américains",
+    r"\((usd|us\$)\)\s*$",
+  ],
+  "CAD": [
+    r"^canadian dollar (account|statement|savings|chequing|visa)",
+    r"^(compte|relevé) .*en dollars canadiens",
+    r"\(cad\)\s*$",
+  ],
+}
+
+
+def currency_sections(profile: Optional[Profile]) -> Dict[str, List[str]]:
+  sections = {currency: list(patterns) for currency, patterns in DEFAULT_CURRENCY_SECTIONS.items()}
+  for currency, patterns in ((profile or {}).get("currency_sections") or {}).items():
+    sections.setdefault(currency, []).extend(patterns)
+  return sections
+
+
+def section_currency(text: str, sections: Dict[str, List[str]]) -> Optional[str]:
+  """Return the currency a heading line starts, or None for any other line"""
+  line = text.strip()
+  for currency, patterns in sections.items():
+    if any(re.search(pattern, line, re.IGNORECASE) for pattern in patterns):
+      return currency
+
+  return None
+
+
+def in_ranges(value: float, ranges: List[List[float]]) -> bool:
+  return any(low < value < high for low, high in ranges)
+
+
 def read_file(file_path: str) -> str:
   if not os.path.exists(file_path):
     raise FileNotFoundError(f"File {file_path} not found")
@@ -1,11 +1,11 @@
 import re
 from datetime import datetime
-from typing import List, Optional
+from typing import List, Optional, Tuple
 
-from .entities import Transaction
-from .utils import match_category, parse_float, read_pdf, should_exclude
+from .entities import Profile, Statement, Transaction
+from .utils import currency_sections, extract_french_period_end, extract_french_period_start, flag, match_category, parse_float, read_pdf, section_currency, should_exclude
 
-PAT_FILE_PATH = r"(visa.*statement|statement.*visa|ion.*statement|statement.*ion|\d{4}\s+statement-\d{4})"
+PAT_FILE_PATH = r"(visa.*statement|statement.*visa|ion.*statement|statement.*ion|\d{4}\s+statement-\d{4}|visa.*relev[ée]|relev[ée].*visa)"
 PAT_MONTH = r"jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec"
 PAT_DAY = r"\d{1,2}"
 PAT_YEAR = r"\d{4}"
@@ -14,6 +14,13 @@ PAT_DATE_LONG = rf"((?:{PAT_MONTH})) ({PAT_DAY})(?:, )?({PAT_YEAR})?"
 PAT_AMOUNT = r"-?\$[\d,]+\.\d{2}"
 PAT_CODE = r"\d{23}"
 
+# Labels in the statement summary, English and French, each followed by its amount
+SUMMARY_LABELS = {
+  "credit_limit": r"credit limit|limite de cr[ée]dit",
+  "minimum_payment": r"minimum payment(?: due)?|paiement minimum(?: exig[ée])?",
+  "interest_charged": r"(?:purchase |total )?interest(?: charges?| charged)?|int[ée]r[êe]ts?(?: factur[ée]s)?",
+}
+
 
 def is_visa(file_path: str) -> bool:
   return bool(re.search(PAT_FILE_PATH, file_path, re.IGNORECASE))
@@ -30,7 +37,30 @@ def extract_start_date(pdf: str) -> Optional[datetime]:
 
     return parse_date(f"{start_month} {start_day} {start_year}")
 
-  return None
+  return extract_french_period_start(pdf)
+
+
+def extract_closing_date(pdf: str) -> Optional[datetime]:
+  regex = rf"statement from {PAT_DATE_LONG} to {PAT_DATE_LONG}"
+
+  if (match := re.search(regex, pdf, re.IGNORECASE)) and match[6]:
+    return parse_date(f"{match[4]} {match[5]} {match[6]}")
+
+  return extract_french_period_end(pdf)
+
+
+def extract_summary(pdf: str) -> Statement:
+  """Read the credit limit, interest and minimum payment the summary prints, and the closing date"""
+  statement = {}
+
+  for field, label in SUMMARY_LABELS.items():
+    if match := re.search(rf"^\s*(?:{label})\s*:?\s*({PAT_AMOUNT})", pdf, re.IGNORECASE | re.MULTILINE):
+      statement[field] = parse_float(match.group(1))
+
+  if closing_date := extract_closing_date(pdf):
+    statement["closing_date"] = closing_date.strftime("%Y-%m-%d")
+
+  return statement
 
 
 def parse_date(string: str) -> datetime:
@@ -63,7 +93,7 @@ def parse_transaction(
   ref_date = parse_date(f"{date} {start_date.year}")
   ref_year = start_date.year + (1 if ref_date.month < start_date.month else 0)
 
-  return {
+  tx = {
     "amount": parse_float(amount) * -1,
     "method": "visa",
     "category": category,
@@ -73,26 +103,52 @@ def parse_transaction(
     "posting_date": parse_date(f"{posting_date} {ref_year}"),
   }
 
+  # Lines are rejoined before matching, an amount left in the description means two rows merged
+  if re.search(PAT_AMOUNT, description):
+    flag(tx, 0.5, "amount-like text in the description, rows may have merged")
+
+  return tx
+
 
 def parse_visa(
   pdf_path: str,
   categories: Optional[dict],
   excludes: Optional[list],
+  profile: Optional[Profile] = None,
 ) -> List[Transaction]:
-  pdf = read_pdf(pdf_path)
+  pdf = read_pdf(pdf_path, ignore_regions=(profile or {}).get("ignore_regions"))
   start_date = extract_start_date(pdf)
+  transactions = []
+
+  for currency, section in split_sections(pdf, currency_sections(profile)):
+    lines = re.sub(
+      rf"\n(?!{PAT_DATE_SHORT}\n{PAT_DATE_SHORT})",
+      " ",
+      section,
+      flags=re.IGNORECASE,
+    )
 
-  lines = re.sub(
-    rf"\n(?!{PAT_DATE_SHORT}\n{PAT_DATE_SHORT})",
-    " ",
-    pdf,
-    flags=re.IGNORECASE,
-  )
-
-  transactions = [
-    tx
-    for line in lines.splitlines()
-    if (tx := parse_transaction(line, start_date, categories or {}, excludes or []))
-  ]
+    for line in lines.splitlines():
+      if tx := parse_transaction(line, start_date, categories or {}, excludes or []):
+        if currency:
+          tx["currency"] = currency
+        transactions.append(tx)
 
   return transactions
+
+
+def split_sections(pdf: str, sections: dict) -> List[Tuple[Optional[str], str]]:
+  """Cut the statement text at currency headings, pairing each part with its currency"""
+  parts = []
+  currency = None
+  current = []
+
+  for line in pdf.splitlines():
+    if section := section_currency(line, sections):
+      parts.append((currency, "\n".join(current)))
+      currency = section
+      current = []
+    current.append(line)
+
+  parts.append((currency, "\n".join(current)))
+  return parts
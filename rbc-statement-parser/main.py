@@ -2,11 +2,17 @@ import argparse
 import json
 import os
 import sys
+from typing import Tuple
 
 from app.chequing import is_chequing, parse_chequing
-from app.entities import Config
-from app.utils import format_transaction, write_file
-from app.visa import is_visa, parse_visa
+from app.entities import Config, Statement
+from app.investment import PAT_FILE_PATH as INVESTMENT_FILE_PATH
+from app.institution import detect_institution
+from app.investment import parse_investment
+from app.loan import PAT_FILE_PATH as LOAN_FILE_PATH
+from app.loan import parse_loan
+from app.utils import format_transaction, fragments, layout_text, locate, read_pages, read_pdf, select_profile, write_file
+from app.visa import extract_summary, is_visa, parse_visa
 
 
 def parse_config(path: str) -> Config:
@@ -32,15 +38,26 @@ def parse_files(path: str) -> list:
   return files
 
 
-def parse_args() -> tuple[list, dict, str, str]:
+def parse_args() -> tuple[list, dict, str, str, str, str, bool]:
   parser = argparse.ArgumentParser(
-    description="A script that parses RBC chequing and VISA statements in PDF format and extracts transactions"
+    description="A script that parses RBC chequing, VISA, Direct Investing, mortgage and loan statements in PDF format and extracts transactions"
   )
 
   parser.add_argument("path", help="Path or to PDF or directory of PDFs")
   parser.add_argument("--config", "-c", help="Path to config file", default=".rc")
   parser.add_argument("--out", "-o", help="Path to output file")
   parser.add_argument("--format", "-f", help="Output format", choices=["text", "json"], default="text")
+  parser.add_argument("--dump", help="Folder to write the text, HTML and text positions read from each PDF to, for debugging")
+  parser.add_argument(
+    "--institution",
+    "-i",
+    help="Bank the statements are from, rbc to parse them all as RBC's, anything else to hand back their text; detected per file when left out",
+  )
+  parser.add_argument(
+    "--strict",
+    action="store_true",
+    help="Fail on a line that can't be read instead of leaving it out and listing it under skipped_lines",
+  )
 
   args = parser.parse_args()
   config = parse_config(args.config)
@@ -50,11 +67,13 @@ def parse_args() -> tuple[list, dict, str, str]:
     print("No valid PDF files found in the specified directory.")
     sys.exit(1)
 
-  return (files, config, args.out, args.format)
+  return (files, config, args.out, args.format, args.institution, args.dump, args.strict)
 
 
 def extract_account_from_pdf(file_path: str) -> dict:
   """Auto-detect account type, number, and name from PDF content"""
+  from app.investment import INVESTMENT_HEADINGS
+  from app.loan import LOAN_HEADINGS
   from app.utils import read_pdf
   import re
 
@@ -69,15 +88,22 @@ def extract_account_from_pdf(file_path: str) -> dict:
     pdf_text = read_pdf(file_path)[:3000]  # First 3000 chars should contain all header info
 
     # Detect account type
-    if "personal savings account statement" in pdf_text.lower():
+    lower = pdf_text.lower()
+    if any(heading in lower for heading in INVESTMENT_HEADINGS):
+      result["type"] = "investment"
+      result["name"] = "RBC Direct Investing"
+    elif any(heading in lower for heading in LOAN_HEADINGS):
+      result["type"] = "loan"
+      result["name"] = "RBC Mortgage" if "mortgage" in lower or "hypoth" in lower else "RBC Loan"
+    elif "personal savings account statement" in lower or "compte d'épargne personnel" in lower or "compte d’épargne personnel" in lower:
       result["type"] = "savings"
-    elif "personal banking account statement" in pdf_text.lower():
+    elif "personal banking account statement" in lower or "compte bancaire personnel" in lower:
       result["type"] = "chequing"
-    elif "visa" in pdf_text.lower() or "credit card" in pdf_text.lower():
+    elif "visa" in lower or "credit card" in lower or "carte de crédit" in lower:
       result["type"] = "visa"
 
-    # Extract account number (for chequing/savings)
-    if match := re.search(r'account number[:\s]+([0-9-]+)', pdf_text, re.IGNORECASE):
+    # Extract account number (for chequing/savings, mortgages and loans)
+    if match := re.search(r'(?:account number|numéro de compte|mortgage number|loan number|numéro de prêt)[:\s]+([0-9-]+)', pdf_text, re.IGNORECASE):
       result["number"] = match.group(1)
 
     # Extract account name (for chequing/savings)
@@ -118,6 +144,10 @@ def extract_account_info(file_path: str) -> dict:
   if not account_type:
     if 'visa' in filename.lower():
       account_type = "visa"
+    elif re.search(INVESTMENT_FILE_PATH, filename, re.IGNORECASE):
+      account_type = "investment"
+    elif re.search(LOAN_FILE_PATH, filename, re.IGNORECASE):
+      account_type = "loan"
     else:
       # Extract first word to determine account type
       first_word = filename.split()[0].lower() if filename.split() else ""
@@ -149,6 +179,10 @@ def extract_account_info(file_path: str) -> dict:
       account_name = f"VISA {account_number}" if account_number else "VISA"
     elif account_type == "savings":
       account_name = "Savings"
+    elif account_type == "investment":
+      account_name = "Direct Investing"
+    elif account_type == "loan":
+      account_name = "Loan"
     else:
       account_name = "Chequing"
 
@@ -160,40 +194,116 @@ def extract_account_info(file_path: str) -> dict:
   }
 
 
-def parse_pdf(file_path: str, categories: dict, excludes: list) -> list:
+def parse_pdf(file_path: str, categories: dict, excludes: list, profiles: list = None, skipped: list = None) -> Tuple[list, Statement]:
+  """Parse one statement into its transactions and, for cards, what its summary says. Lines that
+  can't be read are added to skipped."""
   account_info = extract_account_info(file_path)
-  
-  if is_chequing(file_path):
-    transactions = parse_chequing(file_path, categories, excludes)
+  profile = select_profile(read_pdf(file_path)[:3000], profiles) if profiles else None
+  statement = {}
+
+  if profile:
+    if profile.get("account_type"):
+      account_info["account_type"] = profile["account_type"]
+
+    layout = profile.get("layout") or {"visa": "visa", "investment": "investment", "loan": "loan"}.get(account_info["account_type"], "chequing")
+    if layout == "loan":
+      transactions = parse_loan(file_path, categories, excludes, profile)
+    elif layout == "investment":
+      transactions = parse_investment(file_path, categories, excludes, profile)
+    elif layout == "visa":
+      transactions = parse_visa(file_path, categories, excludes, profile)
+      statement = extract_summary(read_pdf(file_path, ignore_regions=profile.get("ignore_regions")))
+    else:
+      transactions = parse_chequing(file_path, categories, excludes, profile, skipped)
+  elif account_info["account_type"] == "investment":
+    transactions = parse_investment(file_path, categories, excludes)
+  elif account_info["account_type"] == "loan":
+    transactions = parse_loan(file_path, categories, excludes)
+  elif is_chequing(file_path):
+    transactions = parse_chequing(file_path, categories, excludes, skipped=skipped)
   elif is_visa(file_path):
     transactions = parse_visa(file_path, categories, excludes)
+    statement = extract_summary(read_pdf(file_path))
   else:
-    return []
+    return [], {}
   
-  # Add account info and source file to each transaction
+  locate(transactions, read_pages(file_path))
+
+  # Add account info and source file to each transaction, sections that name their own account keep it
   for tx in transactions:
-    tx["account_number"] = account_info["account_number"]
+    tx.setdefault("account_number", account_info["account_number"])
+    tx.setdefault("account_name", account_info["account_name"])
     tx["account_type"] = account_info["account_type"]
-    tx["account_name"] = account_info["account_name"]
     tx["source_file"] = file_path
+
+    # A US dollar section without its own number is still a separate account in arian
+    if tx.get("currency", "CAD") != "CAD" and tx["account_number"] == account_info["account_number"]:
+      number = account_info["account_number"]
+      tx["account_number"] = f"{number} {tx['currency']}" if number else tx["currency"]
+      tx["account_name"] = f"{account_info['account_name']} {tx['currency']}"
   
-  return transactions
+  return transactions, statement
+
+
+def dump(file_path: str, out_dir: str, profiles: list = None):
+  """Write what is read from a PDF before any parsing, to see why a line was misread: the text
+  the card, investment and loan parsers read, the text templates read, the HTML the chequing
+  parser reads and where on the page each piece of its text is"""
+  profile = select_profile(read_pdf(file_path)[:3000], profiles) or {}
+  regions = profile.get("ignore_regions")
+  stem = os.path.join(out_dir, os.path.splitext(os.path.basename(file_path))[0])
+  os.makedirs(out_dir, exist_ok=True)
+
+  html = read_pdf(file_path, html=True, ignore_regions=regions)
+  write_file(read_pdf(file_path, ignore_regions=regions), f"{stem}.text.txt")
+  write_file(layout_text(file_path), f"{stem}.layout.txt")
+  write_file(html, f"{stem}.html")
+
+  rows = ["page\ttop\tleft\ttext"]
+  rows += [f"{page}\t{top:.1f}\t{left:.1f}\t{text}" for page, top, left, text in fragments(html)]
+  write_file("\n".join(rows) + "\n", f"{stem}.fragments.tsv")
 
 
 def main():
-  files, config, out_file, output_format = parse_args()
+  files, config, out_file, output_format, institution, dump_dir, strict = parse_args()
   
   # Parse transactions and track file processing
   file_results = []
   transactions = []
   
   for file in files:
-    file_transactions = parse_pdf(file, config.get("categories"), config.get("excludes"))
-    file_results.append({
+    if dump_dir:
+      dump(file, dump_dir, config.get("profiles"))
+
+    # Statements no bank is recognized on are tried as RBC's, as they always were
+    file_institution = (institution or detect_institution(file) or "").lower()
+    skipped = []
+    if file_institution in ("", "rbc"):
+      file_transactions, statement = parse_pdf(file, config.get("categories"), config.get("excludes"), config.get("profiles"), skipped)
+    else:
+      file_transactions, statement = [], {}
+
+    if strict and skipped:
+      line = skipped[0]
+      print(f"{os.path.basename(file)} page {line['page']} line {line['line']}: {line['reason']}", file=sys.stderr)
+      sys.exit(1)
+
+    file_result = {
       "file": file,
       "transaction_count": len(file_transactions),
       "processed": len(file_transactions) > 0
-    })
+    }
+    if file_institution:
+      file_result["institution"] = file_institution
+    if statement:
+      file_result["statement"] = statement
+    if skipped:
+      file_result["skipped_lines"] = skipped
+    # Other banks' statements, and any the RBC parsers read nothing from, go back as text for the
+    # templates to read
+    if not file_transactions:
+      file_result["text"] = layout_text(file)
+    file_results.append(file_result)
     transactions.extend(file_transactions)
   
   # Sort all transactions by date